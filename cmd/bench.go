@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/build"
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench <files...>",
+	Short: "Measure how much the build cache saves for SIMPL+ file(s)",
+	Long: `Compile each file repeatedly, storing every build in a scratch cache and restoring it
+again, and report the median compile time, cache store and restore times, and how many times
+faster a cache hit is than compiling. Your own cache is not touched. Useful for quantifying
+the benefit of the cache per module and per machine.`,
+	RunE:         runBench,
+	SilenceUsage: true,
+}
+
+func init() {
+	benchCmd.Flags().Int("runs", 5, "Number of times to build each file")
+	benchCmd.Flags().Bool("json", false, "Output as JSON")
+}
+
+// benchResult is the JSON form of a benchmark result
+type benchResult struct {
+	File    string  `json:"file"`
+	Runs    int     `json:"runs"`
+	Compile float64 `json:"compile_seconds"`
+	Store   float64 `json:"store_seconds"`
+	Restore float64 `json:"restore_seconds"`
+	Speedup float64 `json:"speedup"`
+}
+
+func runBench(cmd *cobra.Command, args []string) error {
+	cfg, args, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	files, err := expandSources(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	runs, _ := cmd.Flags().GetInt("runs")
+	if runs < 1 {
+		return fmt.Errorf("--runs must be at least 1")
+	}
+
+	// Each run clears the cache, so benchmark against a scratch one
+	dir, err := os.MkdirTemp("", "spc-bench-*")
+	if err != nil {
+		return fmt.Errorf("failed to create benchmark cache: %w", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	cache.SetCompareAlgorithm(cfg.Cache.Hash)
+	cache.SetLockRetry(cfg.Cache.LockRetries, cfg.Cache.LockDelay)
+
+	scratch, err := cache.New(dir)
+	if err != nil {
+		return err
+	}
+
+	defer scratch.Close()
+
+	session := build.NewSession(cfg, scratch)
+	session.Stdout = io.Discard
+	session.Stderr = io.Discard
+
+	var results []build.BenchResult
+	for _, file := range files {
+		logger.Infof("Benchmarking %s (%d runs)...", file, runs)
+
+		result, err := session.Bench(cmd.Context(), file, runs)
+		if err != nil {
+			return fmt.Errorf("failed to benchmark %s: %w", file, err)
+		}
+
+		results = append(results, result)
+	}
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		out := make([]benchResult, 0, len(results))
+		for _, r := range results {
+			out = append(out, benchResult{
+				File:    r.File,
+				Runs:    r.Runs,
+				Compile: r.Compile.Seconds(),
+				Store:   r.Store.Seconds(),
+				Restore: r.Restore.Seconds(),
+				Speedup: r.Speedup(),
+			})
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tCOMPILE\tSTORE\tRESTORE\tSPEEDUP")
+
+	total := build.BenchResult{File: "total", Runs: runs}
+	for _, r := range results {
+		printBenchResult(w, r)
+
+		total.Compile += r.Compile
+		total.Store += r.Store
+		total.Restore += r.Restore
+	}
+
+	if len(results) > 1 {
+		printBenchResult(w, total)
+	}
+
+	return w.Flush()
+}
+
+// printBenchResult writes a row of the benchmark table
+func printBenchResult(w io.Writer, r build.BenchResult) {
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.File, benchDuration(r.Compile), benchDuration(r.Store), benchDuration(r.Restore), speedup(r.Speedup()))
+}
+
+// benchDuration rounds a time for display, keeping some precision for the
+// sub-millisecond times of small modules
+func benchDuration(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+
+	return d.Round(time.Millisecond).String()
+}
+
+// speedup formats how many times faster a cache hit is
+func speedup(x float64) string {
+	if x >= 10 {
+		return fmt.Sprintf("%.0fx", x)
+	}
+
+	return fmt.Sprintf("%.1fx", x)
+}
@@ -1,117 +1,536 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
+	"github.com/Norgate-AV/spc/internal/artifact"
+	"github.com/Norgate-AV/spc/internal/build"
 	"github.com/Norgate-AV/spc/internal/cache"
-	"github.com/Norgate-AV/spc/internal/compiler"
+	"github.com/Norgate-AV/spc/internal/ci"
 	"github.com/Norgate-AV/spc/internal/config"
-	"github.com/Norgate-AV/spc/internal/utils"
+	"github.com/Norgate-AV/spc/internal/deps"
+	"github.com/Norgate-AV/spc/internal/diag"
+	"github.com/Norgate-AV/spc/internal/discovery"
+	"github.com/Norgate-AV/spc/internal/git"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/Norgate-AV/spc/internal/network"
+	"github.com/Norgate-AV/spc/internal/notify"
+	"github.com/Norgate-AV/spc/internal/remote"
+	"github.com/Norgate-AV/spc/internal/webhook"
 	"github.com/spf13/cobra"
 )
 
 var buildCmd = &cobra.Command{
-	Use:          "build",
-	Short:        "Build SIMPL+ file(s)",
-	Long:         `Build a SIMPL+ file(s) for the specified target series.`,
+	Use:   "build",
+	Short: "Build SIMPL+ file(s)",
+	Long: `Build a SIMPL+ file(s) for the specified target series. Arguments may be files,
+directories (searched recursively) or glob patterns. Libraries are compiled before the
+modules that use them. Arguments after "--" are passed through to the compiler.
+
+With --changed, only the files changed in git since a commit (default HEAD, including
+uncommitted and untracked files) and the files that use a changed library are built.
+
+With --workspace, every project listed in spc.workspace.yml (found in the working
+directory or its parents, or the given file) is built with its own local config,
+sharing one cache, followed by a report of each project's results.`,
 	RunE:         runBuild,
 	SilenceUsage: true,
 }
 
+func init() {
+	// spc with no command builds too
+	for _, c := range []*cobra.Command{buildCmd, rootCmd} {
+		c.Flags().String("changed", "", "Only build files changed in git since the given commit (default HEAD)")
+		c.Flags().Lookup("changed").NoOptDefVal = "HEAD"
+		c.Flags().String("format", "text", "Output format: text, json or tap (results on stdout, everything else on stderr)")
+		c.Flags().String("checksums", "", "After a successful build, write a sha256sum manifest of every artifact to this file (e.g. SHA256SUMS)")
+		c.Flags().String("workspace", "", "Build every project of a workspace file (default spc.workspace.yml in the working directory or its parents)")
+		c.Flags().Lookup("workspace").NoOptDefVal = "."
+	}
+}
+
+// buildReport is the output of spc build --format json
+type buildReport struct {
+	Results []buildReportEntry `json:"results"`
+	Failed  int                `json:"failed"`
+}
+
+// buildReportEntry is the result of one file in a build report
+type buildReportEntry struct {
+	File     string        `json:"file"`
+	Status   string        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
 func runBuild(cmd *cobra.Command, args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("no files specified")
+	if cmd.Flags().Changed("workspace") {
+		return runWorkspaceBuild(cmd, args)
 	}
 
 	// Load and validate configuration
-	configLoader := config.NewLoader()
-	cfg, err := configLoader.LoadForBuild(cmd, args)
+	cfg, args, err := loadBuildConfig(cmd, args)
 	if err != nil {
 		return err
 	}
 
-	// Check if cache is disabled
-	noCache, _ := cmd.Flags().GetBool("no-cache")
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "json" && format != "tap" {
+		return fmt.Errorf("invalid format %q (expected text, json or tap)", format)
+	}
 
-	// Initialize cache (unless disabled)
-	var buildCache *cache.Cache
-	if !noCache {
-		buildCache, err = cache.New("")
+	// Enable chaos testing of the cache if requested
+	if err := setupFaultInjection(cmd); err != nil {
+		return err
+	}
+
+	// Keep stdout for the report
+	if format != "text" {
+		logger.Default().SetOutput(os.Stderr, os.Stderr)
+	}
+
+	files, err := expandSources(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	if ref, _ := cmd.Flags().GetString("changed"); ref != "" {
+		files, err = changedSources(cfg, files, ref)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Failed to initialize cache: %v\n", err)
-			// Continue without cache
-			noCache = true
+			return err
+		}
+
+		if len(files) == 0 {
+			logger.Infof("No changed SIMPL+ files to build")
+		}
+	}
+
+	files = orderSources(cfg, files)
+
+	// Fail fast is the default; --keep-going (or keep_going in config) compiles
+	// every file and reports all failures. An explicit --fail-fast always wins
+	keepGoing := cfg.KeepGoing
+	if failFast, _ := cmd.Flags().GetBool("fail-fast"); failFast {
+		keepGoing = false
+	}
+
+	// Initialize cache (unless disabled)
+	buildCache := openCache(cmd.Context(), cfg)
+	if buildCache != nil {
+		defer buildCache.Close()
+	}
+
+	session := build.NewSession(cfg, buildCache)
+	if format != "text" {
+		session.Stdout = os.Stderr
+	}
+
+	// Keep each file's diagnostics for CI, webhook and TAP reports. Under CI,
+	// compile errors are printed in the form the CI system annotates
+	ciOut := os.Stdout
+	if format != "text" {
+		ciOut = os.Stderr
+	}
+
+	diagnostics := make(map[string][]diag.Diagnostic)
+	session.OnDiagnostic = collectDiagnostics(ciOut, diagnostics)
+
+	if ciProvider.Active() {
+		session.OnStatus = reportProgress(ciOut, len(files))
+	}
+
+	start := time.Now()
+	results := session.Build(cmd.Context(), files, keepGoing)
+
+	if buildCache != nil {
+		pruneCache(buildCache, cfg)
+	}
+
+	failures := build.Failed(results)
+
+	elapsed := time.Since(start)
+
+	if buildCache != nil && len(results) > 0 {
+		if err := buildCache.RecordBuild(build.Record(cfg, results, start, elapsed)); err != nil {
+			logger.Warnf("Failed to record build history: %v", err)
+		}
+	}
+
+	if ciProvider.Active() {
+		reportCI(ciOut, results, diagnostics, elapsed)
+	}
+
+	if len(cfg.Webhooks) > 0 {
+		report := webhook.NewReport(cfg, results, diagnostics, elapsed)
+		if err := webhook.Send(cmd.Context(), http.DefaultClient, cfg.Webhooks, report); err != nil {
+			logger.Warnf("Failed to send build notification: %v", err)
+		}
+	}
+
+	notifyDesktop(cfg, results, elapsed)
+
+	switch format {
+	case "json":
+		if err := writeBuildReport(results); err != nil {
+			return err
+		}
+	case "tap":
+		if err := build.WriteTAP(os.Stdout, files, results, diagnostics); err != nil {
+			return err
+		}
+	}
+
+	if len(failures) == 0 {
+		if sums, _ := cmd.Flags().GetString("checksums"); sums != "" {
+			return writeChecksums(cfg, results, sums)
+		}
+
+		return nil
+	}
+
+	if !keepGoing {
+		return failures[0].Err
+	}
+
+	logger.Errorf("\n%d of %d file(s) failed to compile:", len(failures), len(files))
+	for _, r := range failures {
+		logger.Errorf("  ✗ %s", r.File)
+	}
+
+	return fmt.Errorf("%d file(s) failed to compile", len(failures))
+}
+
+// notifyDesktop shows a desktop notification for a finished build when the
+// notify: settings (or --notify) ask for one
+func notifyDesktop(cfg *config.Config, results []build.Result, elapsed time.Duration) {
+	failed := len(build.Failed(results))
+	if len(results) == 0 || !cfg.Notify.Fires(failed > 0, elapsed) {
+		return
+	}
+
+	if err := notify.Send(notify.ForBuild(cfg.ProjectDir, len(results), failed, elapsed)); err != nil {
+		logger.Warnf("Failed to show desktop notification: %v", err)
+	}
+}
+
+// loadBuildConfig loads and validates configuration and applies the log level.
+// Arguments after "--" are passed through to the compiler (after any
+// extra_args from the config); the remaining source arguments are returned
+func loadBuildConfig(cmd *cobra.Command, args []string) (*config.Config, []string, error) {
+	var extraArgs []string
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		args, extraArgs = args[:dash], args[dash:]
+	}
+
+	configLoader := config.NewLoader()
+	cfg, err := configLoader.LoadForBuild(cmd, args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg.ExtraArgs = append(cfg.ExtraArgs, extraArgs...)
+
+	logger.SetLevel(logger.LevelFromFlags(cfg.Verbosity, cfg.Quiet))
+
+	return cfg, args, nil
+}
+
+// collectDiagnostics returns a diagnostic handler that keeps each file's
+// diagnostics and prints them, in the form the CI system annotates under CI
+func collectDiagnostics(ciOut io.Writer, diagnostics map[string][]diag.Diagnostic) func(diag.Diagnostic) {
+	return func(d diag.Diagnostic) {
+		diagnostics[d.File] = append(diagnostics[d.File], d)
+
+		if ciProvider.Active() {
+			fmt.Fprintln(ciOut, ciProvider.Annotate(d))
 		} else {
-			defer buildCache.Close()
+			logger.Errorf("%s", d.WithExcerpt())
 		}
 	}
+}
 
-	// Process each source file
-	for _, file := range args {
-		absFile, err := filepath.Abs(file)
-		if err != nil {
-			return fmt.Errorf("failed to resolve path for %s: %w", file, err)
-		}
-
-		// Check cache (if enabled)
-		if !noCache && buildCache != nil {
-			entry, err := buildCache.Get(absFile, cfg)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Cache lookup failed: %v\n", err)
-			} else if entry != nil && entry.Success {
-				// Cache hit! Restore to source directory
-				sourceDir := filepath.Dir(absFile)
-				if err := buildCache.Restore(entry, sourceDir); err != nil {
-					fmt.Fprintf(os.Stderr, "Warning: Failed to restore from cache: %v\n", err)
-				} else {
-					if cfg.Verbose {
-						fmt.Printf("✓ Using cached build for %s\n", filepath.Base(file))
-					}
-					continue // Skip compilation
-				}
-			}
+// expandSources expands file, directory and glob arguments into the source
+// files to operate on, applying the include/exclude patterns from the config.
+// With no arguments, the project's files: list is used
+func expandSources(cfg *config.Config, args []string) ([]string, error) {
+	if len(args) == 0 {
+		args = cfg.ProjectFiles()
+		if len(args) == 0 {
+			return nil, fmt.Errorf("no files specified (pass files or set files: in .spc.yml)")
+		}
+	}
+
+	filter, err := discovery.NewFilter(cfg.Include, cfg.Exclude, cfg.ProjectDir)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := discovery.ExpandFiltered(args, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no SIMPL+ files found")
+	}
+
+	return files, nil
+}
+
+// changedSources narrows files to those changed in git since ref and the files
+// that use a changed library, directly or through other libraries
+func changedSources(cfg *config.Config, files []string, ref string) ([]string, error) {
+	dir := cfg.ProjectDir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+
+	changed, err := git.ChangedFiles(dir, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %w", err)
+	}
+
+	return deps.Affected(files, changed, &deps.Resolver{UserFolders: cfg.UserFolders})
+}
+
+// writeBuildReport prints the results of a build as JSON
+func writeBuildReport(results []build.Result) error {
+	report := buildReport{Results: []buildReportEntry{}}
+	for _, r := range results {
+		entry := buildReportEntry{File: r.File, Status: r.Status.String(), Duration: r.Duration}
+		if r.Err != nil {
+			entry.Error = r.Err.Error()
+			report.Failed++
 		}
 
-		// Cache miss or disabled - compile
-		if cfg.Verbose {
-			fmt.Printf("Compiling %s...\n", filepath.Base(file))
+		report.Results = append(report.Results, entry)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// reportCI annotates failures that produced no compiler diagnostics and prints
+// a one-line summary of the build, also adding it to the CI job summary where
+// the CI system has one
+func reportCI(w io.Writer, results []build.Result, diagnostics map[string][]diag.Diagnostic, elapsed time.Duration) {
+	summary := ci.Summary{Files: len(results), Duration: elapsed}
+	for _, r := range results {
+		switch r.Status {
+		case build.StatusSucceeded:
+			summary.Compiled++
+		case build.StatusCached:
+			summary.Cached++
 		}
 
-		if err := compileSingle(cfg, absFile); err != nil {
-			// Store failed build in cache too (so we don't retry immediately)
-			if !noCache && buildCache != nil {
-				_ = buildCache.Store(absFile, cfg, false)
+		result := ci.Result{File: r.File, Cached: r.Status == build.StatusCached, Err: r.Err, Duration: r.Duration, Saved: r.Saved}
+		for _, d := range diagnostics[r.Path] {
+			if d.Severity == diag.SeverityError {
+				result.Errors = append(result.Errors, d.String())
 			}
-			return err
 		}
 
-		// Store successful build in cache
-		if !noCache && buildCache != nil {
-			if err := buildCache.Store(absFile, cfg, true); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Failed to cache build: %v\n", err)
+		summary.Results = append(summary.Results, result)
+
+		if r.Err == nil {
+			continue
+		}
+
+		summary.Failed++
+		summary.Failures = append(summary.Failures, r.File)
+
+		if len(diagnostics[r.Path]) == 0 {
+			fmt.Fprintln(w, ciProvider.AnnotateFailure(r.File, r.Err))
+		}
+	}
+
+	ciProvider.WriteResults(w, summary)
+	fmt.Fprintln(w, summary.String())
+
+	if err := ciProvider.WriteStepSummary(summary); err != nil {
+		logger.Warnf("Failed to write CI job summary: %v", err)
+	}
+}
+
+// reportProgress returns a status handler that wraps each compile's output
+// in a section of the job log and prints the progress as each of total files
+// finishes, where the CI system has sections and shows progress
+func reportProgress(w io.Writer, total int) func(build.Result) {
+	done := 0
+	sections := make(map[string]bool)
+
+	return func(r build.Result) {
+		if r.Status == build.StatusCompiling {
+			if line := ciProvider.SectionStart(r.File, time.Now()); line != "" {
+				fmt.Fprintln(w, line)
+				sections[r.File] = true
 			}
+
+			return
+		}
+
+		if !r.Status.Done() {
+			return
+		}
+
+		if sections[r.File] {
+			fmt.Fprintln(w, ciProvider.SectionEnd(r.File, time.Now()))
+			delete(sections, r.File)
+		}
+
+		done++
+		if line := ciProvider.Progress(r.File, done, total); line != "" {
+			fmt.Fprintln(w, line)
 		}
 	}
+}
 
-	return nil
+// orderSources sorts files so that libraries are compiled before the modules
+// that use them. Falls back to the given order (with a warning) when the
+// dependencies cannot be worked out
+func orderSources(cfg *config.Config, files []string) []string {
+	ordered, err := deps.BuildOrder(files, &deps.Resolver{UserFolders: cfg.UserFolders})
+	if err != nil {
+		logger.Warnf("Building files in the order given: %v", err)
+		return files
+	}
+
+	return ordered
+}
+
+// openCache opens the build cache unless it is disabled (--no-cache, no_cache
+// or cache.enabled: false). Returns nil (and warns) if the cache cannot be
+// opened, so builds continue uncached. Artifacts are compared with the
+// cache.hash algorithm, and locked artifacts retried, either way
+func openCache(ctx context.Context, cfg *config.Config) *cache.Cache {
+	cache.SetCompareAlgorithm(cfg.Cache.Hash)
+	cache.SetLockRetry(cfg.Cache.LockRetries, cfg.Cache.LockDelay)
+
+	if cfg.NoCache {
+		return nil
+	}
+
+	buildCache, err := cache.New(cfg.Cache.Dir)
+	if err != nil {
+		logger.Warnf("Failed to initialize cache: %v", err)
+		return nil
+	}
+
+	if cfg.Cache.ReadOnly {
+		buildCache.SetReadOnly()
+	}
+
+	if cfg.Cache.Remote.Enabled() {
+		r, err := openRemote(cfg)
+		if err != nil {
+			logger.Warnf("Ignoring the remote cache: %v", err)
+		} else {
+			buildCache.SetRemote(ctx, r, cfg.Cache.Remote.ReadOnly)
+		}
+	}
+
+	return buildCache
 }
 
-// compileSingle compiles a single source file
-func compileSingle(cfg *config.Config, sourceFile string) error {
-	builder := compiler.NewCommandBuilder()
-	cmdArgs, err := builder.BuildCommandArgs(cfg, []string{sourceFile})
+// openRemote connects to the remote cache through the proxies and CA
+// certificates of the network settings
+func openRemote(cfg *config.Config) (cache.Remote, error) {
+	transport, err := network.Transport(cfg.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	return remote.New(cfg.Cache.Remote, transport)
+}
+
+// writeChecksums writes a sha256sum manifest of the artifacts of built files
+func writeChecksums(cfg *config.Config, results []build.Result, dest string) error {
+	files, err := artifactFiles(cfg, results)
 	if err != nil {
 		return err
 	}
 
-	// Print build info if verbose mode is enabled
-	if cfg.Verbose {
-		series := utils.ParseTarget(cfg.Target)
-		builder.PrintBuildInfo(cfg, series, []string{sourceFile}, cmdArgs)
+	return writeSums(files, dest)
+}
+
+// artifactFiles returns the paths of the artifacts of built files
+func artifactFiles(cfg *config.Config, results []build.Result) ([]string, error) {
+	var files []string
+	for _, r := range results {
+		outputs, err := cache.CollectOutputs(r.Path, cfg.ForFile(r.Path).Target)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, output := range outputs {
+			files = append(files, filepath.Join(filepath.Dir(r.Path), output))
+		}
 	}
 
-	// Execute the compiler command
-	return builder.ExecuteCommand(cfg.CompilerPath, cmdArgs)
+	return files, nil
+}
+
+// writeSums writes a sha256sum manifest of artifact files
+func writeSums(files []string, dest string) error {
+	if err := artifact.WriteSums(dest, files); err != nil {
+		return err
+	}
+
+	logger.Infof("Wrote checksums of %d artifact(s) to %s", len(files), dest)
+
+	return nil
+}
+
+// pruneCache evicts cache entries beyond the cache.max_size and cache.ttl limits
+func pruneCache(c *cache.Cache, cfg *config.Config) {
+	removed, err := c.Prune(cfg.Cache.MaxSize, cfg.Cache.TTL)
+	if err != nil {
+		logger.Warnf("Failed to prune cache: %v", err)
+		return
+	}
+
+	if removed > 0 {
+		logger.Infof("Evicted %d old cache entries", removed)
+	}
+}
+
+// setupFaultInjection enables cache fault injection when --fault-inject is
+// given and the environment explicitly allows it
+func setupFaultInjection(cmd *cobra.Command) error {
+	rate, _ := cmd.Flags().GetFloat64("fault-inject")
+	if err := cache.ValidateFaultRate(rate); err != nil {
+		return fmt.Errorf("invalid --fault-inject: %w", err)
+	}
+
+	if rate == 0 {
+		return nil
+	}
+
+	if !cache.FaultInjectionAllowed() {
+		return fmt.Errorf("--fault-inject requires %s=1", cache.FaultInjectionEnv)
+	}
+
+	seed := time.Now().UnixNano()
+	if s := os.Getenv("SPC_FAULT_SEED"); s != "" {
+		parsed, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid SPC_FAULT_SEED: %w", err)
+		}
+
+		seed = parsed
+	}
+
+	logger.Warnf("Cache fault injection enabled (rate %.2f, seed %d)", rate, seed)
+	cache.EnableFaultInjection(cache.NewFaultInjector(rate, seed))
+
+	return nil
 }
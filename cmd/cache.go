@@ -0,0 +1,326 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and manage the build cache",
+}
+
+var cacheInspectCmd = &cobra.Command{
+	Use:   "inspect <files...>",
+	Short: "Show the cached builds of SIMPL+ file(s)",
+	Long: `Show every cached build of each SIMPL+ file, newest first, with its target, result and
+the SIMPL# libraries (path, hash and assembly version) it was compiled against.`,
+	Args:         cobra.MinimumNArgs(1),
+	RunE:         runCacheInspect,
+	SilenceUsage: true,
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the number of cached builds and the size of their artifacts",
+	Long: `Show the number of cached builds and the total size of their artifacts. The totals are kept
+up to date as builds are stored and evicted; --recalculate walks the cached artifacts to
+correct them, e.g. after files were deleted from the cache by hand.`,
+	Args:         cobra.NoArgs,
+	RunE:         runCacheStats,
+	SilenceUsage: true,
+}
+
+var cacheDuCmd = &cobra.Command{
+	Use:   "du",
+	Short: "Show the disk space cached builds take by source file and by target",
+	Long: `Show the total size of the cached artifacts of each source file and of each target,
+largest first, with the number of cached builds behind each. Sizes are those recorded
+as builds were stored; run spc cache stats --recalculate first if artifacts were
+deleted from the cache by hand.`,
+	Args:         cobra.NoArgs,
+	RunE:         runCacheDu,
+	SilenceUsage: true,
+}
+
+var cacheCompactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Shrink the cache database after heavy pruning",
+	Long: `Rewrite the cache database into a fresh file holding only the space in use, and report
+how much was reclaimed. The database never shrinks by itself: the space of pruned
+entries is reused by later builds but not returned to the disk. Other spc processes
+must not be using the cache while it is compacted.`,
+	Args:         cobra.NoArgs,
+	RunE:         runCacheCompact,
+	SilenceUsage: true,
+}
+
+var cacheKeyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Print a key for caching the build cache directory in CI",
+	Long: `Print a key that names the contents of the build cache directory, for CI cache
+configuration such as GitLab's cache:key. It changes only when the cached builds can no
+longer be reused: a new cache format, cache.hash algorithm or compiler container image.
+Changes to sources, targets and libraries are part of each cached build's own key and
+don't change it.`,
+	Args:         cobra.NoArgs,
+	RunE:         runCacheKey,
+	SilenceUsage: true,
+}
+
+func init() {
+	cacheInspectCmd.Flags().Bool("json", false, "Output as JSON")
+	cacheStatsCmd.Flags().Bool("json", false, "Output as JSON")
+	cacheStatsCmd.Flags().Bool("recalculate", false, "Recalculate the totals from the cached artifacts")
+	cacheCmd.AddCommand(cacheInspectCmd)
+	cacheDuCmd.Flags().Bool("json", false, "Output as JSON")
+	cacheDuCmd.Flags().Int("top", 0, "Only show the largest n source files and targets (0 for all)")
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheDuCmd)
+	cacheCmd.AddCommand(cacheCompactCmd)
+	cacheCmd.AddCommand(cacheKeyCmd)
+}
+
+// cacheStats is the spc cache stats output
+type cacheStats struct {
+	Dir     string `json:"dir"`
+	Entries int    `json:"entries"`
+	Size    int64  `json:"size"`
+}
+
+// inspectedFile is the spc cache inspect output for one source file
+type inspectedFile struct {
+	SourceFile string        `json:"source_file"`
+	Entries    []cache.Entry `json:"entries"`
+}
+
+func runCacheInspect(cmd *cobra.Command, args []string) error {
+	cfg, args, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	files, err := expandSources(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	if cfg.NoCache {
+		return fmt.Errorf("the build cache is disabled")
+	}
+
+	buildCache := openCache(cmd.Context(), cfg)
+	if buildCache == nil {
+		return fmt.Errorf("build cache is unavailable")
+	}
+
+	defer buildCache.Close()
+
+	var inspected []inspectedFile
+	for _, file := range files {
+		absFile, err := filepath.Abs(file)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path for %s: %w", file, err)
+		}
+
+		entries, err := buildCache.Entries(absFile)
+		if err != nil {
+			return err
+		}
+
+		if entries == nil {
+			entries = []cache.Entry{}
+		}
+
+		inspected = append(inspected, inspectedFile{SourceFile: absFile, Entries: entries})
+	}
+
+	asJSON, _ := cmd.Flags().GetBool("json")
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(inspected)
+	}
+
+	for i, f := range inspected {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		fmt.Printf("%s\n", f.SourceFile)
+		if len(f.Entries) == 0 {
+			fmt.Println("  (not cached)")
+			continue
+		}
+
+		for _, e := range f.Entries {
+			result := "success"
+			if !e.Success {
+				result = "failed"
+			}
+
+			fmt.Printf("  %s  %s  target %s  %s\n", shortKey(e.Hash), e.Timestamp.Format("2006-01-02 15:04:05"), e.Target, result)
+
+			for _, lib := range e.Libraries {
+				version := lib.Version
+				if version == "" {
+					version = "(unknown version)"
+				}
+
+				fmt.Printf("    %s %s  %s  %s\n", filepath.Base(lib.Path), version, shortKey(lib.Hash), lib.Path)
+			}
+		}
+	}
+
+	return nil
+}
+
+func runCacheStats(cmd *cobra.Command, args []string) error {
+	cfg, _, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	if cfg.NoCache {
+		return fmt.Errorf("the build cache is disabled")
+	}
+
+	buildCache := openCache(cmd.Context(), cfg)
+	if buildCache == nil {
+		return fmt.Errorf("build cache is unavailable")
+	}
+
+	defer buildCache.Close()
+
+	stats := buildCache.Stats
+	if recalculate, _ := cmd.Flags().GetBool("recalculate"); recalculate {
+		stats = buildCache.Recalculate
+	}
+
+	entries, size, err := stats()
+	if err != nil {
+		return err
+	}
+
+	result := cacheStats{Dir: buildCache.Dir(), Entries: entries, Size: size}
+
+	asJSON, _ := cmd.Flags().GetBool("json")
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	fmt.Printf("Directory: %s\n", result.Dir)
+	fmt.Printf("Entries:   %d\n", result.Entries)
+	fmt.Printf("Size:      %.2f MB\n", float64(result.Size)/(1024*1024))
+
+	return nil
+}
+
+func runCacheDu(cmd *cobra.Command, args []string) error {
+	cfg, _, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	if cfg.NoCache {
+		return fmt.Errorf("the build cache is disabled")
+	}
+
+	buildCache := openCache(cmd.Context(), cfg)
+	if buildCache == nil {
+		return fmt.Errorf("build cache is unavailable")
+	}
+
+	defer buildCache.Close()
+
+	usage, err := buildCache.DiskUsage()
+	if err != nil {
+		return err
+	}
+
+	if top, _ := cmd.Flags().GetInt("top"); top > 0 {
+		usage.BySource = usage.BySource[:min(top, len(usage.BySource))]
+		usage.ByTarget = usage.ByTarget[:min(top, len(usage.ByTarget))]
+	}
+
+	asJSON, _ := cmd.Flags().GetBool("json")
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(usage)
+	}
+
+	printUsage("By source file:", usage.BySource)
+	fmt.Println()
+	printUsage("By target:", usage.ByTarget)
+
+	return nil
+}
+
+func runCacheCompact(cmd *cobra.Command, args []string) error {
+	cfg, _, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	if cfg.NoCache {
+		return fmt.Errorf("the build cache is disabled")
+	}
+
+	buildCache := openCache(cmd.Context(), cfg)
+	if buildCache == nil {
+		return fmt.Errorf("build cache is unavailable")
+	}
+
+	defer buildCache.Close()
+
+	before, after, err := buildCache.Compact()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Compacted %s from %.2f MB to %.2f MB (reclaimed %.2f MB)\n", buildCache.Dir(),
+		float64(before)/(1024*1024), float64(after)/(1024*1024), float64(before-after)/(1024*1024))
+
+	return nil
+}
+
+// printUsage prints a heading and a line per source file or target of a
+// cache disk usage report
+func printUsage(heading string, usages []cache.Usage) {
+	fmt.Println(heading)
+	if len(usages) == 0 {
+		fmt.Println("  (empty)")
+		return
+	}
+
+	for _, u := range usages {
+		fmt.Printf("  %10.2f MB  %4d build(s)  %s\n", float64(u.Size)/(1024*1024), u.Entries, u.Name)
+	}
+}
+
+// shortKey abbreviates a hash for display
+func shortKey(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+
+	return hash
+}
+
+func runCacheKey(cmd *cobra.Command, args []string) error {
+	cfg, _, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(cache.Key(cfg))
+	return nil
+}
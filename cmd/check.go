@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Norgate-AV/spc/internal/build"
+	"github.com/Norgate-AV/spc/internal/check"
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/deps"
+	"github.com/Norgate-AV/spc/internal/diag"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check [paths...]",
+	Short: "Check SIMPL+ sources for syntax errors without building them",
+	Long: `Check SIMPL+ sources for the errors that can be found without the compiler: comments
+and strings that are never closed, braces, parentheses and brackets that don't pair up,
+and user libraries that can't be found. Nothing is compiled, written or cached, so it is
+quick enough for editors and pull request checks.
+
+With --compile, files that pass are also compiled, each in a temporary directory that is
+thrown away afterwards, to find the errors only the compiler can (SPlusCC has no
+syntax-only mode). The cache is neither read nor written and the sources' SPlsWork is
+left alone. With --lint, the problems spc lint finds are reported too.
+
+Paths may be files, directories or glob patterns (default: the project's files: list).
+Fails when any error is found.`,
+	RunE:         runCheck,
+	SilenceUsage: true,
+}
+
+func init() {
+	checkCmd.Flags().Bool("compile", false, "Also compile each file that passes in a throwaway sandbox")
+	checkCmd.Flags().Bool("lint", false, "Also report the problems found by spc lint")
+	checkCmd.Flags().String("format", "text", "Output format: text or json (results on stdout, everything else on stderr)")
+}
+
+func runCheck(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "json" {
+		return fmt.Errorf("invalid format %q (expected text or json)", format)
+	}
+
+	if format == "json" {
+		logger.Default().SetOutput(os.Stderr, os.Stderr)
+	}
+
+	cfg, args, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	files, err := expandSources(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	report := newProblemReport(len(files))
+
+	var passed []string
+	for _, file := range files {
+		diagnostics, err := check.File(file, &deps.Resolver{UserFolders: cfg.ForFile(file).UserFolders})
+		if err != nil {
+			return err
+		}
+
+		if len(diagnostics) == 0 {
+			passed = append(passed, file)
+		}
+
+		report.add(diagnostics...)
+	}
+
+	if compile, _ := cmd.Flags().GetBool("compile"); compile {
+		if err := compileCheck(cmd.Context(), cfg, passed, report); err != nil {
+			return err
+		}
+	}
+
+	if withLint, _ := cmd.Flags().GetBool("lint"); withLint {
+		linted, err := lintFiles(cfg, files)
+		if err != nil {
+			return err
+		}
+
+		report.add(linted.Diagnostics...)
+	}
+
+	if err := report.write(format); err != nil {
+		return err
+	}
+
+	if report.Errors > 0 {
+		return fmt.Errorf("%d error(s) found", report.Errors)
+	}
+
+	return nil
+}
+
+// compileCheck compiles files in throwaway sandboxes, adding the compile
+// errors to the report. Fails when a compile fails without diagnostics, e.g.
+// because the compiler could not be started
+func compileCheck(ctx context.Context, cfg *config.Config, files []string, report *problemReport) error {
+	session := build.NewSession(cfg, nil)
+	if !cfg.Verbose {
+		session.Stdout, session.Stderr = io.Discard, io.Discard
+	}
+
+	for _, file := range files {
+		var diagnostics []diag.Diagnostic
+		session.OnDiagnostic = func(d diag.Diagnostic) { diagnostics = append(diagnostics, d) }
+
+		result := session.CheckFile(ctx, file)
+		if result.Err != nil && len(diagnostics) == 0 {
+			return fmt.Errorf("failed to compile %s: %w", file, result.Err)
+		}
+
+		report.add(diagnostics...)
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+var cleanCmd = &cobra.Command{
+	Use:   "clean [paths...]",
+	Short: "Remove compiled outputs from SPlsWork",
+	Long: `Remove the .ush header and SPlsWork outputs of SIMPL+ sources, for every series, so the
+next build compiles them from scratch, along with their outputs in the SPlsWork_S2,
+SPlsWork_S3 and SPlsWork_S4 folders of --split-work builds. Files SPlsWork shares
+between modules are kept.
+Paths may be files, directories or glob patterns (default: the project's files: list).
+
+With --stale, remove instead the outputs of modules whose source has been deleted or
+renamed: every SPlsWork directory under the given directories (default: the project
+directory) is compared against the .usp and .usl files beside it, and the files named
+after a module that no longer exists are removed, along with its .ush. SIMPL# library
+assemblies and shared files are never removed.
+
+Use --dry-run to list the files without removing them.`,
+	RunE:         runClean,
+	SilenceUsage: true,
+}
+
+func init() {
+	cleanCmd.Flags().Bool("stale", false, "Remove the outputs of deleted or renamed modules rather than of the given sources")
+	cleanCmd.Flags().Bool("dry-run", false, "List the files that would be removed")
+}
+
+func runClean(cmd *cobra.Command, args []string) error {
+	cfg, args, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	// outputs maps each source directory to the files to remove from it
+	outputs := make(map[string][]string)
+
+	if stale, _ := cmd.Flags().GetBool("stale"); stale {
+		if len(args) == 0 {
+			dir := cfg.ProjectDir
+			if dir == "" {
+				dir, _ = os.Getwd()
+			}
+
+			args = []string{dir}
+		}
+
+		dirs, err := outputDirs(args)
+		if err != nil {
+			return err
+		}
+
+		for _, dir := range dirs {
+			if outputs[dir], err = cache.CollectStaleOutputs(dir); err != nil {
+				return err
+			}
+		}
+	} else {
+		files, err := expandSources(cfg, args)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			found, err := cache.CollectOutputs(file, "234")
+			if err != nil {
+				return err
+			}
+
+			split, err := cache.CollectSeriesOutputs(file)
+			if err != nil {
+				return err
+			}
+
+			found = append(found, split...)
+
+			dir := filepath.Dir(file)
+			outputs[dir] = append(outputs[dir], found...)
+		}
+	}
+
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	dirs := make([]string, 0, len(outputs))
+	for dir := range outputs {
+		dirs = append(dirs, dir)
+	}
+
+	sort.Strings(dirs)
+
+	removed := 0
+	for _, dir := range dirs {
+		for _, output := range outputs[dir] {
+			fmt.Println(filepath.Join(dir, output))
+		}
+
+		if !dryRun {
+			if err := cache.RemoveArtifacts(dir, outputs[dir]); err != nil {
+				return err
+			}
+		}
+
+		removed += len(outputs[dir])
+	}
+
+	switch {
+	case removed == 0:
+		logger.Successf("✓ Nothing to clean")
+	case dryRun:
+		logger.Infof("Would remove %d file(s)", removed)
+	default:
+		logger.Successf("✓ Removed %d file(s)", removed)
+	}
+
+	return nil
+}
+
+// outputDirs returns the directories under roots that hold a SPlsWork
+// directory, skipping hidden directories
+func outputDirs(roots []string) ([]string, error) {
+	var dirs []string
+
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !d.IsDir() {
+				return nil
+			}
+
+			switch name := d.Name(); {
+			case strings.EqualFold(name, "SPlsWork"):
+				dirs = append(dirs, filepath.Dir(path))
+				return filepath.SkipDir
+			case path != root && strings.HasPrefix(name, "."):
+				return filepath.SkipDir
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", root, err)
+		}
+	}
+
+	return dirs, nil
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Norgate-AV/spc/internal/compiler"
+	"github.com/spf13/cobra"
+)
+
+var codesCmd = &cobra.Command{
+	Use:   "codes",
+	Short: "List and explain SIMPL+ compiler exit codes",
+	Long:  `List the exit codes returned by the Crestron SIMPL+ compiler (SPlusCC.exe) and explain what they mean.`,
+}
+
+var codesListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List all known compiler exit codes",
+	Args:         cobra.NoArgs,
+	RunE:         runCodesList,
+	SilenceUsage: true,
+}
+
+var codesExplainCmd = &cobra.Command{
+	Use:          "explain <code>",
+	Short:        "Explain a compiler exit code and suggest a fix",
+	Args:         cobra.ExactArgs(1),
+	RunE:         runCodesExplain,
+	SilenceUsage: true,
+}
+
+func init() {
+	codesCmd.AddCommand(codesListCmd)
+	codesCmd.AddCommand(codesExplainCmd)
+}
+
+func runCodesList(cmd *cobra.Command, args []string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CODE\tRESULT\tDESCRIPTION")
+
+	for _, code := range compiler.Codes() {
+		result := "failure"
+		if compiler.IsSuccess(code) {
+			result = "success"
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\n", code, result, compiler.GetErrorMessage(code))
+	}
+
+	return w.Flush()
+}
+
+func runCodesExplain(cmd *cobra.Command, args []string) error {
+	code, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid exit code: %s", args[0])
+	}
+
+	if _, ok := compiler.ErrorCodes[code]; !ok {
+		return fmt.Errorf("unknown exit code: %d", code)
+	}
+
+	result := "failure"
+	if compiler.IsSuccess(code) {
+		result = "success"
+	}
+
+	fmt.Printf("Exit code %d (%s): %s\n\n%s\n", code, result, compiler.GetErrorMessage(code), compiler.GetRemediation(code))
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and manage spc configuration",
+	Long:  `Inspect and manage the global and local (.spc.yml) configuration files.`,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [dir]",
+	Short: "Validate the global and local config files",
+	Long: `Load the global config and the local config for a directory (default: current directory)
+and report unknown keys, invalid targets and compiler/user folder paths that do not exist,
+along with the file each problem came from. Exits non-zero if any problem is found.`,
+	Args:         cobra.MaximumNArgs(1),
+	RunE:         runConfigValidate,
+	SilenceUsage: true,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a config key",
+	Long: `Print the effective value of a config key for the current directory (global config
+overlaid by the local .spc.yml), or only the global config with --global.
+Keys inside profiles use dots, e.g. profiles.release.target.`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runConfigGet,
+	SilenceUsage: true,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a config key in the local or global config file",
+	Long: `Set a config key in the local .spc.yml (found in the current directory or upwards,
+created in the current directory if there is none) or, with --global, in the global config.
+Comments and key order in the file are preserved. List settings (usersplusfolder, include,
+exclude) take a comma separated value.`,
+	Args:         cobra.ExactArgs(2),
+	RunE:         runConfigSet,
+	SilenceUsage: true,
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+
+	configGetCmd.Flags().Bool("global", false, "Read the global config only")
+	configSetCmd.Flags().Bool("global", false, "Write the global config instead of the local one")
+}
+
+// configFiles returns the global config file and the local config file for
+// dir, skipping any that do not exist
+func configFiles(dir string) ([]string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path for %s: %w", dir, err)
+	}
+
+	var files []string
+	if global := config.FindGlobalConfig(); global != "" {
+		files = append(files, global)
+	}
+
+	if local := config.FindLocalConfig(absDir); local != "" {
+		files = append(files, local)
+	}
+
+	return files, nil
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	files, err := configFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		fmt.Println("No config files found")
+		return nil
+	}
+
+	count := 0
+	for _, file := range files {
+		problems, err := config.ValidateFile(file)
+		if err != nil {
+			logger.Errorf("✗ %v", err)
+			count++
+			continue
+		}
+
+		if len(problems) == 0 {
+			fmt.Printf("✓ %s\n", file)
+			continue
+		}
+
+		for _, p := range problems {
+			logger.Errorf("✗ %s", p)
+		}
+
+		count += len(problems)
+	}
+
+	if count > 0 {
+		return fmt.Errorf("%d problem(s) found", count)
+	}
+
+	return nil
+}
+
+func runConfigGet(cmd *cobra.Command, args []string) error {
+	key := args[0]
+
+	var value any
+	var err error
+
+	if global, _ := cmd.Flags().GetBool("global"); global {
+		path := config.FindGlobalConfig()
+		if path == "" {
+			return fmt.Errorf("no global config file found")
+		}
+
+		value, err = config.GetValue(path, key)
+	} else {
+		value, err = config.NewLoader().Lookup(".", key)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if value == nil {
+		return fmt.Errorf("%s is not set", key)
+	}
+
+	if list, ok := value.([]any); ok {
+		for _, item := range list {
+			fmt.Println(item)
+		}
+
+		return nil
+	}
+
+	fmt.Println(value)
+
+	return nil
+}
+
+func runConfigSet(cmd *cobra.Command, args []string) error {
+	path, err := writableConfigPath(cmd)
+	if err != nil {
+		return err
+	}
+
+	if err := config.SetValue(path, args[0], args[1]); err != nil {
+		return err
+	}
+
+	fmt.Printf("Set %s in %s\n", args[0], path)
+
+	return nil
+}
+
+// writableConfigPath returns the config file `config set` should modify
+func writableConfigPath(cmd *cobra.Command) (string, error) {
+	if global, _ := cmd.Flags().GetBool("global"); global {
+		if path := config.FindGlobalConfig(); path != "" {
+			return path, nil
+		}
+
+		dirs := config.GlobalConfigDirs()
+		if len(dirs) == 0 {
+			return "", fmt.Errorf("cannot determine the global config directory")
+		}
+
+		return filepath.Join(dirs[0], "config.yml"), nil
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	if path := config.FindLocalConfig(cwd); path != "" {
+		return path, nil
+	}
+
+	return filepath.Join(cwd, ".spc.yml"), nil
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/daemon"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/Norgate-AV/spc/internal/metrics"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon [projects...]",
+	Short: "Run a build server for editors and build tools",
+	Long: `Run spc as a long-lived build server. The daemon keeps the build cache open, watches the
+given project directories (default: the current directory) and rebuilds sources as they are
+saved, and answers Build, Status and CacheStats requests (JSON-RPC over a Unix socket, by
+default <cache dir>/daemon.sock).`,
+	RunE:         runDaemon,
+	SilenceUsage: true,
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:          "status",
+	Short:        "Show the status of a running daemon",
+	Args:         cobra.NoArgs,
+	RunE:         runDaemonStatus,
+	SilenceUsage: true,
+}
+
+func init() {
+	daemonCmd.PersistentFlags().String("socket", "", "Socket to listen on or connect to (default <cache dir>/daemon.sock)")
+	daemonCmd.Flags().Bool("no-watch", false, "Only build on request, without watching projects")
+	daemonCmd.Flags().String("metrics", "", "Serve Prometheus metrics on this address (e.g. :9090)")
+	daemonStatusCmd.Flags().Bool("json", false, "Output as JSON")
+	daemonCmd.AddCommand(daemonStatusCmd)
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	cfg, _, err := loadBuildConfig(cmd, nil)
+	if err != nil {
+		return err
+	}
+
+	if cfg.NoCache {
+		return fmt.Errorf("the daemon requires the build cache")
+	}
+
+	cacheDir, err := daemonCacheDir(cfg)
+	if err != nil {
+		return err
+	}
+
+	socket, _ := cmd.Flags().GetString("socket")
+	if socket == "" {
+		socket = daemon.DefaultSocket(cacheDir)
+	}
+
+	buildCache, err := cache.New(cacheDir)
+	if err != nil {
+		return err
+	}
+
+	listener, err := daemon.Listen(socket)
+	if err != nil {
+		buildCache.Close()
+		return err
+	}
+
+	defer os.Remove(socket)
+
+	server := daemon.NewServer(buildCache, cacheDir)
+	defer server.Close()
+
+	// --notify covers the watched projects
+	server.Notify = cfg.Notify.Enabled
+
+	if addr, _ := cmd.Flags().GetString("metrics"); addr != "" {
+		metricsServer, err := serveMetrics(server, addr)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+
+		defer metricsServer.Close()
+	}
+
+	if noWatch, _ := cmd.Flags().GetBool("no-watch"); !noWatch {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+
+		profile, _ := cmd.Flags().GetString("profile")
+		for _, dir := range args {
+			if err := server.Watch(dir, profile); err != nil {
+				listener.Close()
+				return fmt.Errorf("failed to watch %s: %w", dir, err)
+			}
+
+			logger.Infof("Watching %s", dir)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	fmt.Printf("spc daemon listening on %s\n", socket)
+
+	return server.Serve(listener)
+}
+
+// serveMetrics records the daemon's builds and serves them as Prometheus
+// metrics on addr
+func serveMetrics(server *daemon.Server, addr string) (*http.Server, error) {
+	m := metrics.New()
+	server.OnResult = m.Observe
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve metrics: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(metrics.Path, m.Handler())
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+			logger.Warnf("Metrics server stopped: %v", err)
+		}
+	}()
+
+	logger.Infof("Serving metrics on http://%s%s", l.Addr(), metrics.Path)
+
+	return srv, nil
+}
+
+func runDaemonStatus(cmd *cobra.Command, args []string) error {
+	socket, err := daemonSocket(cmd)
+	if err != nil {
+		return err
+	}
+
+	client, err := daemon.Dial(socket)
+	if err != nil {
+		return err
+	}
+
+	defer client.Close()
+
+	status, err := client.Status()
+	if err != nil {
+		return err
+	}
+
+	stats, err := client.CacheStats()
+	if err != nil {
+		return err
+	}
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			*daemon.StatusReply
+			Cache *daemon.CacheStatsReply `json:"cache"`
+		}{status, stats})
+	}
+
+	fmt.Printf("PID:     %d\n", status.PID)
+	fmt.Printf("Socket:  %s\n", status.Socket)
+	fmt.Printf("Uptime:  %s\n", time.Since(status.Started).Round(time.Second))
+	fmt.Printf("Cache:   %s (%d entries, %.2f MB)\n", stats.Dir, stats.Entries, float64(stats.Size)/(1024*1024))
+
+	for _, p := range status.Projects {
+		state := "idle"
+		switch {
+		case p.Building:
+			state = "building"
+		case p.Watching:
+			state = "watching"
+		}
+
+		last := "never built"
+		if !p.LastBuild.IsZero() {
+			failed := 0
+			for _, r := range p.Results {
+				if r.Error != "" {
+					failed++
+				}
+			}
+
+			last = fmt.Sprintf("last build %s, %d file(s), %d failed", p.LastBuild.Format("15:04:05"), len(p.Results), failed)
+		}
+
+		fmt.Printf("\n%s\n  %s, %s\n", p.Dir, state, last)
+	}
+
+	return nil
+}
+
+// daemonSocket returns the socket given with --socket, or the default one for
+// the current project's cache
+func daemonSocket(cmd *cobra.Command) (string, error) {
+	if socket, _ := cmd.Flags().GetString("socket"); socket != "" {
+		return socket, nil
+	}
+
+	cfg, _, err := loadBuildConfig(cmd, nil)
+	if err != nil {
+		return "", err
+	}
+
+	cacheDir, err := daemonCacheDir(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	return daemon.DefaultSocket(cacheDir), nil
+}
+
+// daemonCacheDir returns the absolute cache directory the daemon serves
+func daemonCacheDir(cfg *config.Config) (string, error) {
+	if cfg.Cache.Dir != "" {
+		return cfg.Cache.Dir, nil
+	}
+
+	return filepath.Abs(cache.DefaultCacheDir)
+}
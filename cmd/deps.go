@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/deps"
+	"github.com/spf13/cobra"
+)
+
+var depsCmd = &cobra.Command{
+	Use:   "deps <file>",
+	Short: "List the dependencies of a SIMPL+ file",
+	Long: `List the libraries a SIMPL+ file uses, directly or through other libraries, with their
+resolved paths and content hashes. With --reverse, list the project files that use the given
+library instead (searching the project's files: list, or the current directory).`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runDeps,
+	SilenceUsage: true,
+}
+
+func init() {
+	depsCmd.Flags().BoolP("reverse", "r", false, "List the files that depend on the given library")
+	depsCmd.Flags().Bool("json", false, "Output as JSON")
+}
+
+// depEntry is a single line of spc deps output
+type depEntry struct {
+	Name    string        `json:"name"`
+	Kind    deps.NodeKind `json:"kind"`
+	Path    string        `json:"path,omitempty"`
+	Hash    string        `json:"hash,omitempty"`
+	Missing bool          `json:"missing,omitempty"`
+}
+
+func runDeps(cmd *cobra.Command, args []string) error {
+	reverse, _ := cmd.Flags().GetBool("reverse")
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	file, err := filepath.Abs(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to resolve path for %s: %w", args[0], err)
+	}
+
+	if _, err := os.Stat(file); err != nil {
+		return fmt.Errorf("file not found: %s", args[0])
+	}
+
+	var entries []depEntry
+	if reverse {
+		entries, err = reverseDeps(cmd, file)
+	} else {
+		entries, err = forwardDeps(cmd, file)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		if entries == nil {
+			entries = []depEntry{}
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	if len(entries) == 0 {
+		if reverse {
+			fmt.Printf("No files depend on %s\n", args[0])
+		} else {
+			fmt.Printf("%s has no dependencies\n", args[0])
+		}
+
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tKIND\tHASH\tPATH")
+
+	for _, e := range entries {
+		path := e.Path
+		switch {
+		case e.Missing:
+			path = "(missing)"
+		case e.Kind == deps.NodeCrestron:
+			path = "(SIMPL)"
+		}
+
+		hash := shortKey(e.Hash)
+		if hash == "" {
+			hash = "-"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Name, e.Kind, hash, path)
+	}
+
+	return w.Flush()
+}
+
+// forwardDeps lists the libraries file uses, nearest first
+func forwardDeps(cmd *cobra.Command, file string) ([]depEntry, error) {
+	cfg, _, err := loadBuildConfig(cmd, []string{file})
+	if err != nil {
+		return nil, err
+	}
+
+	cwd, _ := os.Getwd()
+
+	graph, err := deps.BuildGraph([]string{file}, &deps.Resolver{UserFolders: cfg.UserFolders}, cwd)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []depEntry
+	for _, n := range graph.TransitiveDependencies(file) {
+		entry, err := newDepEntry(n)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// reverseDeps lists the project files that use the library, nearest first
+func reverseDeps(cmd *cobra.Command, library string) ([]depEntry, error) {
+	cfg, _, err := loadBuildConfig(cmd, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []string
+	if len(cfg.Files) == 0 {
+		args = []string{"."}
+	}
+
+	files, err := expandSources(cfg, args)
+	if err != nil {
+		return nil, err
+	}
+
+	cwd, _ := os.Getwd()
+
+	graph, err := deps.BuildGraph(files, &deps.Resolver{UserFolders: cfg.UserFolders}, cwd)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []depEntry
+	for _, n := range graph.TransitiveDependents(library) {
+		entry, err := newDepEntry(n)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// newDepEntry describes a graph node, hashing its content when it is a file
+func newDepEntry(n *deps.Node) (depEntry, error) {
+	entry := depEntry{Name: n.Label, Kind: n.Kind, Path: n.Path, Missing: n.Missing}
+
+	if n.Path != "" {
+		hash, err := cache.HashFile(n.Path)
+		if err != nil {
+			return entry, fmt.Errorf("failed to hash %s: %w", n.Path, err)
+		}
+
+		entry.Hash = hash
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/docs"
+	"github.com/Norgate-AV/spc/internal/ush"
+	"github.com/spf13/cobra"
+)
+
+var docsCmd = &cobra.Command{
+	Use:   "docs [paths...]",
+	Short: "Generate reference pages for SIMPL+ modules",
+	Long: `Write a Markdown (or --format html) reference page per module and library into a
+directory (-o, default docs) with an index page. Each page has the module's summary, help
+text, inputs, outputs, parameters and functions, read from the source and its /// doc
+comments:
+
+  /// Summary of the module (the first doc comment before #SYMBOL_NAME or a blank line)
+  #SYMBOL_NAME "Projector"
+
+  /// Polls the device while high
+  DIGITAL_INPUT Poll;
+  STRING_OUTPUT To_Device$; // a comment after a declaration also documents it
+
+  /// Sends a command to the device
+  /// @param command the command, without the delimiter
+  FUNCTION Send(STRING command)
+
+Parameters without a doc comment are described by their propShortDescription. Paths may
+be files, directories or glob patterns (default: the project's files: list, or the current
+directory). A .ush header is documented from the source next to it, or from the header
+alone when there is none.`,
+	RunE:         runDocs,
+	SilenceUsage: true,
+}
+
+func init() {
+	docsCmd.Flags().StringP("format", "f", docs.FormatMarkdown, "Output format (markdown, html)")
+}
+
+func runDocs(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	if format != docs.FormatMarkdown && format != docs.FormatHTML {
+		return fmt.Errorf("invalid format: %s (expected markdown or html)", format)
+	}
+
+	dest, _ := cmd.Flags().GetString("out")
+	if dest == "" {
+		dest = "docs"
+	}
+
+	var headers, sources []string
+	for _, arg := range args {
+		if strings.EqualFold(filepath.Ext(arg), ".ush") {
+			headers = append(headers, arg)
+		} else {
+			sources = append(sources, arg)
+		}
+	}
+
+	var modules []*docs.Module
+
+	for _, header := range headers {
+		m, err := headerDocs(header)
+		if err != nil {
+			return err
+		}
+
+		modules = append(modules, m)
+	}
+
+	if len(sources) > 0 || len(headers) == 0 {
+		cfg, _, err := loadBuildConfig(cmd, sources)
+		if err != nil {
+			return err
+		}
+
+		if len(sources) == 0 && len(cfg.Files) == 0 {
+			sources = []string{"."}
+		}
+
+		files, err := expandSources(cfg, sources)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			m, err := docs.ParseFile(file)
+			if err != nil {
+				return err
+			}
+
+			modules = append(modules, m)
+		}
+	}
+
+	paths, err := docs.Generate(modules, dest, format)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		fmt.Printf("Wrote %s\n", path)
+	}
+
+	return nil
+}
+
+// headerDocs documents a module from its source next to the header, or
+// from the header alone
+func headerDocs(header string) (*docs.Module, error) {
+	h, err := ush.Read(header)
+	if err != nil {
+		return nil, err
+	}
+
+	source := h.SourceFile
+	if source == "" {
+		source = strings.TrimSuffix(filepath.Base(header), filepath.Ext(header)) + ".usp"
+	}
+
+	source = filepath.Join(filepath.Dir(header), source)
+	if _, err := os.Stat(source); err == nil {
+		return docs.ParseFile(source)
+	}
+
+	return docs.FromHeader(h), nil
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Norgate-AV/spc/internal/deps"
+	"github.com/spf13/cobra"
+)
+
+var graphCmd = &cobra.Command{
+	Use:   "graph [paths...]",
+	Short: "Print the module dependency graph",
+	Long: `Scan SIMPL+ sources for library directives (#USER_LIBRARY, #CRESTRON_LIBRARY,
+#USER_SIMPLSHARP_LIBRARY, ...) and print the dependency graph as DOT, Mermaid or JSON.
+Paths may be files, directories or glob patterns (default: the project's files: list,
+or the current directory).`,
+	RunE:         runGraph,
+	SilenceUsage: true,
+}
+
+func init() {
+	graphCmd.Flags().StringP("format", "f", "dot", "Output format (dot, mermaid, json)")
+}
+
+func runGraph(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	if format != "dot" && format != "mermaid" && format != "json" {
+		return fmt.Errorf("invalid format: %s (expected dot, mermaid or json)", format)
+	}
+
+	cfg, args, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 && len(cfg.Files) == 0 {
+		args = []string{"."}
+	}
+
+	files, err := expandSources(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	cwd, _ := os.Getwd()
+
+	graph, err := deps.BuildGraph(files, &deps.Resolver{UserFolders: cfg.UserFolders}, cwd)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "mermaid":
+		return graph.WriteMermaid(os.Stdout)
+	case "json":
+		return graph.WriteJSON(os.Stdout)
+	default:
+		return graph.WriteDOT(os.Stdout)
+	}
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/spf13/cobra"
+)
+
+var hashCmd = &cobra.Command{
+	Use:   "hash <files...>",
+	Short: "Print the cache key for SIMPL+ file(s)",
+	Long: `Print the cache key computed for each SIMPL+ file along with a breakdown of its inputs
+(source hash, target, user folder digest, extra compiler arguments, library dependencies,
+container image or compiler versions). Useful for debugging why a file is or isn't a cache hit on different
+machines.`,
+	Args:         cobra.MinimumNArgs(1),
+	RunE:         runHash,
+	SilenceUsage: true,
+}
+
+func init() {
+	hashCmd.Flags().Bool("json", false, "Output as JSON")
+}
+
+func runHash(cmd *cobra.Command, args []string) error {
+	cfg, args, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	files, err := expandSources(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	var all []*cache.KeyInputs
+	for _, file := range files {
+		absFile, err := filepath.Abs(file)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path for %s: %w", file, err)
+		}
+
+		inputs, err := cache.ExplainKey(absFile, cfg.ForFile(absFile))
+		if err != nil {
+			return err
+		}
+
+		all = append(all, inputs)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(all)
+	}
+
+	for i, inputs := range all {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		compilerVersion := inputs.CompilerVersion
+		switch {
+		case inputs.Image != "":
+			compilerVersion = "(set by the container image)"
+		case compilerVersion == "":
+			compilerVersion = "(unknown, not part of the key)"
+		}
+
+		fmt.Printf("%s\n", inputs.SourceFile)
+		fmt.Printf("  Key:              %s\n", inputs.Key)
+		fmt.Printf("  Hash algorithm:   %s\n", inputs.Algorithm)
+		fmt.Printf("  Source hash:      %s\n", inputs.SourceHash)
+		fmt.Printf("  Target:           %s\n", inputs.Target)
+		fmt.Printf("  User folders:     %s\n", strings.Join(inputs.UserFolders, ", "))
+		fmt.Printf("  User folder hash: %s\n", inputs.UserFoldersDigest)
+		fmt.Printf("  Extra args:       %s\n", strings.Join(inputs.ExtraArgs, " "))
+
+		label := "  Dependencies:     "
+		if len(inputs.Dependencies) == 0 {
+			fmt.Printf("%s(none)\n", label)
+		}
+
+		for i, d := range inputs.Dependencies {
+			if i > 0 {
+				label = strings.Repeat(" ", len(label))
+			}
+
+			hash := d.Hash
+			if d.Path == "" {
+				hash = "(missing)"
+			}
+
+			fmt.Printf("%s%s %s\n", label, d.Name, hash)
+		}
+
+		if inputs.Image != "" {
+			fmt.Printf("  Container image:  %s\n", inputs.Image)
+		}
+
+		fmt.Printf("  Compiler version: %s\n", compilerVersion)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/git"
+	"github.com/spf13/cobra"
+)
+
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "Manage git hooks that build changed SIMPL+ files",
+}
+
+var hookInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install a git hook that blocks commits which fail to compile",
+	Long: `Install a git hook in the current repository that runs
+"spc build --changed --cache-readonly --format json" and blocks the commit (pre-commit,
+the default) or push (pre-push, building everything changed since the upstream branch)
+when a changed module, or a module using a changed library, fails to compile.
+Existing hooks that were not installed by spc are kept unless --force is given.`,
+	Args:         cobra.NoArgs,
+	RunE:         runHookInstall,
+	SilenceUsage: true,
+}
+
+var hookUninstallCmd = &cobra.Command{
+	Use:          "uninstall",
+	Short:        "Remove a git hook installed by spc",
+	Args:         cobra.NoArgs,
+	RunE:         runHookUninstall,
+	SilenceUsage: true,
+}
+
+func init() {
+	hookCmd.PersistentFlags().String("type", "pre-commit", "Hook to manage: "+strings.Join(git.Hooks, " or "))
+	hookInstallCmd.Flags().Bool("force", false, "Replace an existing hook that was not installed by spc")
+	hookCmd.AddCommand(hookInstallCmd)
+	hookCmd.AddCommand(hookUninstallCmd)
+}
+
+// hookScripts are the scripts installed for each hook
+var hookScripts = map[string]string{
+	"pre-commit": `#!/bin/sh
+` + git.HookMarker + ` (spc hook install): build changed SIMPL+ files before committing
+exec spc build --changed --cache-readonly --format json
+`,
+	"pre-push": `#!/bin/sh
+` + git.HookMarker + ` (spc hook install --type pre-push): build SIMPL+ files changed since the upstream branch before pushing
+base=$(git rev-parse --verify --quiet '@{upstream}') || base=HEAD
+exec spc build --changed="$base" --cache-readonly --format json
+`,
+}
+
+func runHookInstall(cmd *cobra.Command, args []string) error {
+	name, path, err := hookPath(cmd)
+	if err != nil {
+		return err
+	}
+
+	force, _ := cmd.Flags().GetBool("force")
+	if err := git.InstallHook(path, hookScripts[name], force); err != nil {
+		return err
+	}
+
+	fmt.Printf("Installed %s hook at %s\n", name, path)
+
+	return nil
+}
+
+func runHookUninstall(cmd *cobra.Command, args []string) error {
+	name, path, err := hookPath(cmd)
+	if err != nil {
+		return err
+	}
+
+	removed, err := git.UninstallHook(path)
+	if err != nil {
+		return err
+	}
+
+	if !removed {
+		fmt.Printf("No %s hook installed\n", name)
+		return nil
+	}
+
+	fmt.Printf("Removed %s hook from %s\n", name, path)
+
+	return nil
+}
+
+// hookPath returns the hook selected with --type and its path in the
+// repository containing the working directory
+func hookPath(cmd *cobra.Command) (string, string, error) {
+	name, _ := cmd.Flags().GetString("type")
+	if !slices.Contains(git.Hooks, name) {
+		return "", "", fmt.Errorf("unsupported hook %q (expected %s)", name, strings.Join(git.Hooks, " or "))
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", "", err
+	}
+
+	path, err := git.HookPath(cwd, name)
+	if err != nil {
+		return "", "", err
+	}
+
+	return name, path, nil
+}
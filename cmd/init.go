@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/vscode"
+	"github.com/spf13/cobra"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init [dir]",
+	Short: "Set up a project for spc",
+	Long: `Create a .spc.yml in a project directory (default: current directory) that builds every
+SIMPL+ file in the project for the given --target. With --vscode, also write
+.vscode/tasks.json with build tasks whose problem matcher puts compile errors in the
+VS Code Problems panel. Existing files are kept unless --force is given.`,
+	Args:         cobra.MaximumNArgs(1),
+	RunE:         runInit,
+	SilenceUsage: true,
+}
+
+func init() {
+	initCmd.Flags().Bool("vscode", false, "Also write .vscode/tasks.json")
+	initCmd.Flags().Bool("force", false, "Overwrite existing files")
+}
+
+func runInit(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", dir)
+	}
+
+	force, _ := cmd.Flags().GetBool("force")
+
+	target, _ := cmd.Flags().GetString("target")
+	if target == "" {
+		target = config.DefaultTarget
+	}
+
+	configFile := filepath.Join(dir, ".spc.yml")
+	if existing := config.FindLocalConfig(dir); existing != "" && filepath.Dir(existing) == filepath.Clean(dir) && !force {
+		fmt.Printf("Keeping existing %s\n", existing)
+	} else {
+		content := fmt.Sprintf("# spc project configuration\ntarget: %q\nfiles:\n  - \".\"\n", target)
+		if err := writeInitFile(configFile, []byte(content)); err != nil {
+			return err
+		}
+	}
+
+	if withVSCode, _ := cmd.Flags().GetBool("vscode"); withVSCode {
+		tasksFile := filepath.Join(dir, filepath.FromSlash(vscode.TasksFile))
+		if _, err := os.Stat(tasksFile); err == nil && !force {
+			return fmt.Errorf("%s already exists (use --force to overwrite)", tasksFile)
+		}
+
+		tasks, err := vscode.Tasks()
+		if err != nil {
+			return err
+		}
+
+		if err := writeInitFile(tasksFile, tasks); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeInitFile writes a generated file, creating its directory
+func writeInitFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	fmt.Printf("Wrote %s\n", path)
+
+	return nil
+}
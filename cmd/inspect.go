@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Norgate-AV/spc/internal/ush"
+	"github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <files...>",
+	Short: "Show the inputs, outputs, parameters and functions of compiled modules",
+	Long: `Read the .ush header the compiler generated for each module and print its inputs,
+outputs and parameters in symbol order, and the functions declared in its source when the
+source is next to the header. A .usp file can be given in place of its .ush.`,
+	Args:         cobra.MinimumNArgs(1),
+	RunE:         runInspect,
+	SilenceUsage: true,
+}
+
+func init() {
+	inspectCmd.Flags().Bool("json", false, "Output as JSON")
+}
+
+func runInspect(cmd *cobra.Command, args []string) error {
+	var modules []*ush.Module
+
+	for _, arg := range args {
+		header := arg
+		if !strings.EqualFold(filepath.Ext(arg), ".ush") {
+			header = strings.TrimSuffix(arg, filepath.Ext(arg)) + ".ush"
+		}
+
+		m, err := ush.Read(header)
+		if err != nil {
+			return err
+		}
+
+		modules = append(modules, m)
+	}
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(modules)
+	}
+
+	for i, m := range modules {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		if err := printModule(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printModule prints a module's interface as tables
+func printModule(m *ush.Module) error {
+	fmt.Printf("%s (%s)\n", m.Name, m.Header)
+
+	if m.SourceFile != "" {
+		fmt.Printf("Source: %s\n", m.SourceFile)
+	}
+
+	if m.CompilerVersion != "" {
+		fmt.Printf("Compiler: %s\n", m.CompilerVersion)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	sections := []struct {
+		title   string
+		signals []ush.Signal
+	}{
+		{"INPUTS", m.Inputs},
+		{"OUTPUTS", m.Outputs},
+		{"PARAMETERS", m.Parameters},
+	}
+
+	for _, section := range sections {
+		fmt.Fprintf(w, "\n%s (%d)\n", section.title, len(section.signals))
+
+		for _, s := range section.signals {
+			fmt.Fprintf(w, "  %d\t%s\t%s\n", s.Index, s.Name, s.Type)
+		}
+	}
+
+	if m.Functions == nil {
+		fmt.Fprintln(w, "\nFUNCTIONS (source not found)")
+		return w.Flush()
+	}
+
+	fmt.Fprintf(w, "\nFUNCTIONS (%d)\n", len(m.Functions))
+
+	for _, f := range m.Functions {
+		returns := f.Returns
+		if returns == "" {
+			returns = "-"
+		}
+
+		fmt.Fprintf(w, "  %s(%s)\t%s\tline %d\n", f.Name, f.Params, returns, f.Line)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/diag"
+	"github.com/Norgate-AV/spc/internal/lint"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [paths...]",
+	Short: "Check SIMPL+ sources for common mistakes",
+	Long: `Check SIMPL+ sources for problems the compiler accepts: unused variables, a missing
+#DEFAULT_VOLATILE, local variables and parameters that shadow a signal, deprecated
+functions and wait times written as bare numbers. Problems are reported like compile
+errors (as CI annotations under CI), with the rule as the code.
+
+Each rule's severity can be changed in the lint: section of .spc.yml:
+
+  lint:
+    magic_wait: off
+    unused_variable: error
+
+Paths may be files, directories or glob patterns (default: the project's files: list).
+Fails when any problem has the severity error.`,
+	RunE:         runLint,
+	SilenceUsage: true,
+}
+
+func init() {
+	lintCmd.Flags().String("format", "text", "Output format: text or json (results on stdout, everything else on stderr)")
+	lintCmd.Flags().Bool("list-rules", false, "List the rules and their default severity")
+}
+
+// problemReport is the output of spc lint and spc check --format json
+type problemReport struct {
+	Files       int               `json:"files"`
+	Errors      int               `json:"errors"`
+	Warnings    int               `json:"warnings"`
+	Diagnostics []diag.Diagnostic `json:"diagnostics"`
+}
+
+// newProblemReport creates an empty report on files
+func newProblemReport(files int) *problemReport {
+	return &problemReport{Files: files, Diagnostics: []diag.Diagnostic{}}
+}
+
+// add counts and keeps diagnostics
+func (r *problemReport) add(diagnostics ...diag.Diagnostic) {
+	for _, d := range diagnostics {
+		if d.Severity == diag.SeverityError {
+			r.Errors++
+		} else {
+			r.Warnings++
+		}
+	}
+
+	r.Diagnostics = append(r.Diagnostics, diagnostics...)
+}
+
+// write prints the report as JSON, or prints each diagnostic the way compile
+// errors are printed, followed by a count of them
+func (r *problemReport) write(format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+
+	printed := collectDiagnostics(os.Stdout, make(map[string][]diag.Diagnostic))
+	for _, d := range r.Diagnostics {
+		printed(d)
+	}
+
+	if len(r.Diagnostics) == 0 {
+		logger.Successf("✓ No problems found in %d file(s)", r.Files)
+	} else {
+		logger.Infof("%d error(s) and %d warning(s) in %d file(s)", r.Errors, r.Warnings, r.Files)
+	}
+
+	return nil
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	if list, _ := cmd.Flags().GetBool("list-rules"); list {
+		return printLintRules()
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "json" {
+		return fmt.Errorf("invalid format %q (expected text or json)", format)
+	}
+
+	if format == "json" {
+		logger.Default().SetOutput(os.Stderr, os.Stderr)
+	}
+
+	cfg, args, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	files, err := expandSources(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	report, err := lintFiles(cfg, files)
+	if err != nil {
+		return err
+	}
+
+	if err := report.write(format); err != nil {
+		return err
+	}
+
+	if report.Errors > 0 {
+		return fmt.Errorf("%d lint error(s)", report.Errors)
+	}
+
+	return nil
+}
+
+// lintFiles checks files with the rule severities of their config
+func lintFiles(cfg *config.Config, files []string) (*problemReport, error) {
+	report := newProblemReport(len(files))
+
+	linter, err := lint.New(cfg.Lint)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		diagnostics, err := linter.File(file)
+		if err != nil {
+			return nil, err
+		}
+
+		report.add(diagnostics...)
+	}
+
+	return report, nil
+}
+
+// printLintRules lists the lint rules
+func printLintRules() error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RULE\tSEVERITY\tDESCRIPTION")
+
+	for _, r := range lint.Rules {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.ID, r.Severity, r.Description)
+	}
+
+	return w.Flush()
+}
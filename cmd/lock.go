@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Norgate-AV/spc/internal/build"
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/lock"
+	"github.com/Norgate-AV/spc/internal/version"
+	"github.com/spf13/cobra"
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock [files...]",
+	Short: "Build SIMPL+ files and pin the build set in spc.lock",
+	Long: `Build the given files (or the project's files: list) and, if every one succeeds, write
+spc.lock recording the compiler used for each series, the hash of every source and library,
+and the checksum of every artifact. Commit it alongside a release so the build can be
+reproduced and audited later.
+
+The lock is written to the project directory, or to the file given with -o. It contains no
+timestamps, so locking an unchanged build leaves it unchanged. Nothing is written if any
+file fails to build.`,
+	RunE:         runLock,
+	SilenceUsage: true,
+}
+
+func runLock(cmd *cobra.Command, args []string) error {
+	cfg, args, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	files, err := expandSources(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	// -o is the lock file here, not the compiler log
+	dest, _ := cmd.Flags().GetString("out")
+	if dest == "" {
+		dest = filepath.Join(cfg.ProjectDir, lock.FileName)
+	}
+
+	buildCache := openCache(cmd.Context(), cfg)
+	if buildCache != nil {
+		defer buildCache.Close()
+	}
+
+	results := build.NewSession(cfg, buildCache).Build(cmd.Context(), files, false)
+	if failures := build.Failed(results); len(failures) > 0 {
+		return fmt.Errorf("%d file(s) failed to build, %s not written", len(failures), dest)
+	}
+
+	lockFile, err := lock.Generate(cfg, files, lockDir(cfg))
+	if err != nil {
+		return err
+	}
+
+	lockFile.SPC = version.Version
+
+	if err := lock.Write(dest, lockFile); err != nil {
+		return err
+	}
+
+	fmt.Printf("Locked %d module(s) in %s\n", len(lockFile.Modules), dest)
+
+	return nil
+}
+
+// lockDir is the directory paths in the lock are relative to: the project
+// directory, or the working directory without one
+func lockDir(cfg *config.Config) string {
+	if cfg.ProjectDir != "" {
+		return cfg.ProjectDir
+	}
+
+	dir, _ := os.Getwd()
+
+	return dir
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Norgate-AV/spc/internal/build"
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/diag"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/Norgate-AV/spc/internal/lsp"
+	"github.com/Norgate-AV/spc/internal/version"
+	"github.com/spf13/cobra"
+)
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Run a language server that compiles SIMPL+ files on save",
+	Long: `Run a minimal Language Server on stdin/stdout. When the editor saves a .usp or .usl
+file, it is compiled in a sandbox with the config of its project (restoring it from the
+cache when unchanged) and the compiler's errors and warnings are published as diagnostics,
+along with the problems found by spc lint.
+
+Point any LSP client at "spc lsp", e.g. in Neovim:
+
+  vim.lsp.start({ name = "spc", cmd = { "spc", "lsp" }, root_dir = vim.fn.getcwd() })`,
+	Args:         cobra.NoArgs,
+	RunE:         runLSP,
+	SilenceUsage: true,
+}
+
+func runLSP(cmd *cobra.Command, _ []string) error {
+	// stdout carries the protocol
+	logger.Default().SetOutput(os.Stderr, os.Stderr)
+
+	profile, _ := cmd.Flags().GetString("profile")
+
+	server := lsp.NewServer(os.Stdin, os.Stdout, func(file string) ([]diag.Diagnostic, error) {
+		return lspCompile(cmd.Context(), file, profile)
+	})
+	server.Version = version.Version
+
+	return server.Serve()
+}
+
+// lspCompile compiles and lints a saved file in a sandbox with its project's
+// config and returns the diagnostics found. The cache is only open while
+// compiling, so command line builds can use it in between
+func lspCompile(ctx context.Context, file, profile string) ([]diag.Diagnostic, error) {
+	cfg, err := config.NewLoader().LoadProject(filepath.Dir(file), profile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Sandbox = true
+
+	buildCache := openCache(ctx, cfg)
+	if buildCache != nil {
+		defer buildCache.Close()
+	}
+
+	var diagnostics []diag.Diagnostic
+
+	session := build.NewSession(cfg, buildCache)
+	session.Stdout, session.Stderr = io.Discard, io.Discard
+	session.OnDiagnostic = func(d diag.Diagnostic) { diagnostics = append(diagnostics, d) }
+
+	result := session.BuildFile(ctx, file)
+	if result.Err != nil && len(diagnostics) == 0 {
+		return nil, result.Err
+	}
+
+	linted, err := lintFiles(cfg, []string{file})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(diagnostics, linted.Diagnostics...), nil
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/git"
+	"github.com/Norgate-AV/spc/internal/scaffold"
+	"github.com/spf13/cobra"
+)
+
+var newCmd = &cobra.Command{
+	Use:   "new",
+	Short: "Create new SIMPL+ files from templates",
+}
+
+var newModuleCmd = &cobra.Command{
+	Use:   "module <Name>",
+	Short: "Create a SIMPL+ module from a template",
+	Long: `Create <Name>/<Name>.usp from a template (default: basic), with the standard header block,
+compiler directives and parameter conventions, plus any other files the template has, such as
+the test harness of the serial-driver template. Existing files are kept unless --force is given.
+
+User templates are directories in the templates directory of the global config (e.g.
+~/.config/spc/templates/<template>/), rendered with Go text/template. "__Name__" in a file
+name is replaced by the module name and a ".tmpl" suffix is dropped. Templates can use
+{{.Name}}, {{.Author}}, {{.Date}}, {{.Year}} and {{.Target}}.`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runNewModule,
+	SilenceUsage: true,
+}
+
+var newTemplatesCmd = &cobra.Command{
+	Use:          "templates",
+	Short:        "List the available module templates",
+	Args:         cobra.NoArgs,
+	RunE:         runNewTemplates,
+	SilenceUsage: true,
+}
+
+func init() {
+	newModuleCmd.Flags().String("template", scaffold.DefaultTemplate, "Template to create the module from")
+	newModuleCmd.Flags().String("dir", ".", "Directory to create the module in")
+	newModuleCmd.Flags().String("author", "", "Author in the header block (default: git user.name)")
+	newModuleCmd.Flags().Bool("force", false, "Overwrite existing files")
+	newCmd.AddCommand(newModuleCmd)
+	newCmd.AddCommand(newTemplatesCmd)
+}
+
+func runNewModule(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := scaffold.ValidateName(name); err != nil {
+		return err
+	}
+
+	dir, _ := cmd.Flags().GetString("dir")
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", dir)
+	}
+
+	templateName, _ := cmd.Flags().GetString("template")
+
+	tmpl, err := scaffold.Find(templateName, templateDirs())
+	if err != nil {
+		return err
+	}
+
+	force, _ := cmd.Flags().GetBool("force")
+
+	paths, err := tmpl.Render(dir, newModuleData(cmd, name, dir), force)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		fmt.Printf("Wrote %s\n", path)
+	}
+
+	return nil
+}
+
+func runNewTemplates(_ *cobra.Command, _ []string) error {
+	templates, err := scaffold.Templates(templateDirs())
+	if err != nil {
+		return err
+	}
+
+	for _, t := range templates {
+		fmt.Printf("%-20s %s\n", t.Name, t.Source)
+	}
+
+	return nil
+}
+
+// templateDirs returns the user template directories, in the order the
+// global config is looked up
+func templateDirs() []string {
+	var dirs []string
+	for _, dir := range config.GlobalConfigDirs() {
+		dirs = append(dirs, filepath.Join(dir, "templates"))
+	}
+
+	return dirs
+}
+
+// newModuleData returns what a new module's template is rendered with. The
+// target is the project's, from --target or its config
+func newModuleData(cmd *cobra.Command, name, dir string) scaffold.Data {
+	now := time.Now()
+
+	author, _ := cmd.Flags().GetString("author")
+	if author == "" {
+		author = git.UserName(dir)
+	}
+
+	if author == "" {
+		author = os.Getenv("USER")
+	}
+
+	if author == "" {
+		author = os.Getenv("USERNAME")
+	}
+
+	target, _ := cmd.Flags().GetString("target")
+	if target == "" {
+		profile, _ := cmd.Flags().GetString("profile")
+		if cfg, err := config.NewLoader().LoadProject(dir, profile); err == nil {
+			target = cfg.Target
+		}
+	}
+
+	if target == "" {
+		target = config.DefaultTarget
+	}
+
+	return scaffold.Data{
+		Name:   name,
+		Author: author,
+		Date:   now.Format(time.DateOnly),
+		Year:   now.Format("2006"),
+		Target: target,
+	}
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/pack"
+	"github.com/Norgate-AV/spc/internal/version"
+	"github.com/spf13/cobra"
+)
+
+var packCmd = &cobra.Command{
+	Use:   "pack [files...]",
+	Short: "Archive built SIMPL+ modules into a zip",
+	Long: `Collect the artifacts of built SIMPL+ modules (the .ush and the SPlsWork outputs for the
+target series) into a zip for handing off to integrators. Each module goes in a directory
+named after it, and manifest.json lists every file with its SHA256, the module's source
+hash, target and cache key, the --release version and the spc version.
+
+The archive is written to the file given with -o (default modules.zip). Modules must be
+built first; with no files, the project's files: list is packed.`,
+	RunE:         runPack,
+	SilenceUsage: true,
+}
+
+func init() {
+	packCmd.Flags().String("release", "", "Version recorded in the manifest")
+}
+
+func runPack(cmd *cobra.Command, args []string) error {
+	cfg, args, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	// -o is the archive here, not the compiler log
+	dest, _ := cmd.Flags().GetString("out")
+	if dest == "" {
+		dest = "modules.zip"
+	}
+
+	files, err := expandSources(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	release, _ := cmd.Flags().GetString("release")
+	manifest := &pack.Manifest{
+		Format:  pack.FormatVersion,
+		Release: release,
+		SPC:     version.Version,
+		Created: time.Now().UTC().Truncate(time.Second),
+	}
+
+	for _, file := range files {
+		absFile, err := filepath.Abs(file)
+		if err != nil {
+			return fmt.Errorf("failed to resolve path for %s: %w", file, err)
+		}
+
+		fileCfg := cfg.ForFile(absFile)
+
+		module, err := pack.Collect(absFile, fileCfg.Target, cfg.ProjectDir)
+		if err != nil {
+			return err
+		}
+
+		if key, err := cache.HashSource(absFile, fileCfg); err == nil {
+			module.Key = key
+		}
+
+		manifest.Modules = append(manifest.Modules, module)
+	}
+
+	if err := pack.Write(dest, manifest); err != nil {
+		return err
+	}
+
+	fmt.Printf("Packed %d module(s) into %s\n", len(manifest.Modules), dest)
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/Norgate-AV/spc/internal/plugin"
+	"github.com/Norgate-AV/spc/internal/version"
+)
+
+// runPlugin runs spc-<name> when the first argument names a plugin on PATH
+// rather than a command or a source file. Reports whether a plugin ran,
+// with its exit code
+func runPlugin(args []string) (int, bool) {
+	if len(args) == 0 || isCommand(args[0]) {
+		return 0, false
+	}
+
+	if _, err := os.Stat(args[0]); err == nil {
+		return 0, false
+	}
+
+	path, ok := plugin.Find(args[0])
+	if !ok {
+		return 0, false
+	}
+
+	code, err := plugin.Run(path, args[1:], pluginContext(), os.Stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		logger.Errorf("%v", err)
+	}
+
+	return code, true
+}
+
+// isCommand reports whether a name is one of spc's own commands, which
+// plugins can't replace
+func isCommand(name string) bool {
+	if name == "help" || name == "completion" || strings.HasPrefix(name, "__complete") {
+		return true
+	}
+
+	for _, c := range rootCmd.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pluginContext describes spc and the project in the working directory to
+// a plugin. A config that fails to load is reported in the context rather
+// than stopping the plugin
+func pluginContext() plugin.Context {
+	exe, _ := os.Executable()
+	cwd, _ := os.Getwd()
+
+	ctx := plugin.Context{
+		Version:    version.Version,
+		Executable: exe,
+		WorkDir:    cwd,
+		ConfigFile: config.FindLocalConfig(cwd),
+	}
+
+	cfg, err := config.NewLoader().LoadProject(cwd, "")
+	if err != nil {
+		ctx.ConfigError = err.Error()
+		return ctx
+	}
+
+	ctx.ProjectDir = cfg.ProjectDir
+	ctx.Config = &plugin.Config{
+		Profile:       cfg.Profile,
+		Target:        cfg.Target,
+		CompilerPath:  cfg.CompilerPath,
+		CompilerPaths: cfg.CompilerPaths,
+		UserFolders:   cfg.UserFolders,
+		OutDir:        cfg.OutDir,
+		CacheDir:      cfg.Cache.Dir,
+	}
+
+	sources := cfg.ProjectFiles()
+	if len(sources) == 0 {
+		sources = []string{cfg.ProjectDir}
+	}
+
+	// A project without sources still gets its plugins
+	if files, err := expandSources(cfg, sources); err == nil {
+		ctx.Files = files
+	}
+
+	return ctx
+}
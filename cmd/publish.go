@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Norgate-AV/spc/internal/pack"
+	"github.com/Norgate-AV/spc/internal/publish"
+	"github.com/spf13/cobra"
+)
+
+var publishCmd = &cobra.Command{
+	Use:   "publish [archive]",
+	Short: "Upload an archive created by spc pack",
+	Long: `Upload an archive created by spc pack (default modules.zip) to the destination in
+publish.destination (or --to):
+
+  //server/share/modules       a directory, such as a network share
+  https://repo.example.com/... a server accepting HTTP PUT (Artifactory, Nexus, ...)
+  github:owner/repo            an asset of the GitHub release tagged with the version
+
+The archive is published as <name>-<version>.zip (in a <version>/ directory, except on
+GitHub), where the version is --release or the one recorded by spc pack --release.
+HTTP uploads authenticate with SPC_PUBLISH_TOKEN or SPC_PUBLISH_USER and
+SPC_PUBLISH_PASSWORD; GitHub uploads with GITHUB_TOKEN.`,
+	Args:         cobra.MaximumNArgs(1),
+	RunE:         runPublish,
+	SilenceUsage: true,
+}
+
+func init() {
+	publishCmd.Flags().String("to", "", "Destination (overrides publish.destination)")
+	publishCmd.Flags().String("release", "", "Version to publish as (default: the version recorded in the archive)")
+	publishCmd.Flags().Bool("force", false, "Replace an archive already published with the same version")
+}
+
+func runPublish(cmd *cobra.Command, args []string) error {
+	cfg, _, err := loadBuildConfig(cmd, nil)
+	if err != nil {
+		return err
+	}
+
+	archive := "modules.zip"
+	if len(args) > 0 {
+		archive = args[0]
+	}
+
+	manifest, err := pack.Read(archive)
+	if err != nil {
+		return err
+	}
+
+	version, _ := cmd.Flags().GetString("release")
+	if version == "" {
+		version = manifest.Release
+	}
+
+	if err := publish.ValidVersion(version); err != nil {
+		return err
+	}
+
+	dest, _ := cmd.Flags().GetString("to")
+	if dest == "" {
+		dest = cfg.Publish.Destination
+	}
+
+	force, _ := cmd.Flags().GetBool("force")
+
+	publisher, err := publish.New(dest, force)
+	if err != nil {
+		return err
+	}
+
+	location, err := publisher.Publish(cmd.Context(), archive, version)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Published %s (%d module(s)) as %s to %s\n", archive, len(manifest.Modules), version, location)
+
+	return nil
+}
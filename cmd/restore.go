@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Norgate-AV/spc/internal/build"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <files...>",
+	Short: "Restore SIMPL+ build artifacts from the cache",
+	Long: `Restore cached artifacts (SPlsWork outputs and the adjacent .ush) for each file without
+invoking the compiler. Files with no cached build fail individually. Useful for provisioning
+a fresh checkout before opening SIMPL Windows.`,
+	RunE:         runRestore,
+	SilenceUsage: true,
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	cfg, args, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	files, err := expandSources(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	if cfg.NoCache {
+		return fmt.Errorf("restore cannot be used with the build cache disabled")
+	}
+
+	buildCache := openCache(cmd.Context(), cfg)
+	if buildCache == nil {
+		return fmt.Errorf("build cache is unavailable")
+	}
+
+	defer buildCache.Close()
+
+	session := build.NewSession(cfg, buildCache)
+
+	var failures []build.Result
+	for _, file := range files {
+		result := session.RestoreFile(cmd.Context(), file)
+		if result.Status == build.StatusFailed {
+			logger.Errorf("✗ %v", result.Err)
+			failures = append(failures, result)
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d file(s) could not be restored", len(failures), len(files))
+	}
+
+	return nil
+}
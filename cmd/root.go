@@ -1,12 +1,16 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/Norgate-AV/spc/internal/ci"
+	"github.com/Norgate-AV/spc/internal/logger"
 	"github.com/Norgate-AV/spc/internal/version"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var rootCmd = &cobra.Command{
@@ -16,10 +20,67 @@ var rootCmd = &cobra.Command{
 	RunE:         runBuild,
 	SilenceUsage: true,
 	Args:         cobra.ArbitraryArgs,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("ci")
+		provider, err := ci.Parse(name)
+		if err != nil {
+			return err
+		}
+
+		// --report picks the output wherever spc runs, like --ci
+		report, _ := cmd.Flags().GetString("report")
+		if report != "" {
+			reportProvider, err := ci.ParseReport(report)
+			if err != nil {
+				return err
+			}
+
+			if cmd.Flags().Changed("ci") && provider != reportProvider {
+				return fmt.Errorf("--report %s conflicts with --ci %s", report, name)
+			}
+
+			provider = reportProvider
+		}
+
+		ciProvider = provider
+
+		// CI logs rarely render ANSI escapes
+		if noColor, _ := cmd.Flags().GetBool("no-color"); noColor || ciProvider.Active() {
+			logger.SetColorMode(logger.ColorNever)
+		}
+
+		return openLogFile(cmd)
+	},
 }
 
+// ciProvider is the CI system spc is running under (ci.None outside CI)
+var ciProvider = ci.None
+
+// logFile is the --log-file being written (nil without one)
+var logFile *os.File
+
 func Execute() {
-	err := rootCmd.Execute()
+	// spc <name> runs a spc-<name> plugin when name isn't a command or source
+	if code, ok := runPlugin(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+
+	// Ctrl+C cancels the command's context, stopping a running compiler.
+	// A second one exits at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+
+	if logFile != nil {
+		logger.SetFile(nil, "")
+		_ = logFile.Close()
+	}
+
 	if err != nil {
 		os.Exit(1)
 	}
@@ -27,16 +88,91 @@ func Execute() {
 
 func init() {
 	rootCmd.Version = fmt.Sprintf("%s (%s) %s", version.Version, version.Commit, version.BuildTime)
-	rootCmd.PersistentFlags().StringP("target", "t", "", "Target series to compile for (e.g., 3, 34, 234)")
+	rootCmd.PersistentFlags().StringP("profile", "p", "", "Config profile to use (from the profiles: section)")
+	rootCmd.PersistentFlags().String("compiler-path", "", "SIMPL+ compiler (SPlusCC.exe) to use, overriding compiler_path and SPC_COMPILER_PATH")
+	rootCmd.PersistentFlags().StringP("target", "t", "", "Target series to compile for (e.g., 3, 34, 234, an alias such as all or modern, or auto to detect each file's)")
 	rootCmd.PersistentFlags().BoolP("silent", "s", false, "Suppress console output from the SIMPL+ compiler")
-	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Verbose output")
+	rootCmd.PersistentFlags().CountP("verbose", "v", "Verbose output (-vv for debug output)")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Only show errors")
+	rootCmd.MarkFlagsMutuallyExclusive("verbose", "quiet")
 	rootCmd.PersistentFlags().StringP("out", "o", "", "Output file for compilation logs")
+	rootCmd.PersistentFlags().String("out-dir", "", "Collect each module's artifacts into <dir>/<module>/")
+	rootCmd.PersistentFlags().Bool("install", false, "Copy each module's .ush and outputs into the SIMPL Windows user SIMPL+ folder (install_dir)")
 	rootCmd.PersistentFlags().StringSliceP("usersplusfolder", "u", []string{}, "User SIMPL+ folders")
+	rootCmd.PersistentFlags().Bool("no-color", false, "Disable colored output (also honors NO_COLOR)")
+	rootCmd.PersistentFlags().String("ci", "auto", "CI system to format output for: auto, none, github, gitlab, azure, jenkins, teamcity, buildkite or generic")
+	rootCmd.PersistentFlags().String("report", "", "Report build progress, problems and statistics for a CI server wherever spc runs: teamcity")
+	rootCmd.PersistentFlags().String("log-file", "", "Also write every log record, including debug records, to this file")
+	rootCmd.PersistentFlags().String("log-format", "text", "Format of --log-file: text or json")
 	rootCmd.PersistentFlags().Bool("no-cache", false, "Disable build cache")
+	rootCmd.PersistentFlags().Bool("force", false, "Recompile every file without restoring it from the build cache, still caching the results")
+	rootCmd.MarkFlagsMutuallyExclusive("no-cache", "force")
+	rootCmd.PersistentFlags().Bool("cache-readonly", false, "Restore from the build cache without storing new builds")
+	rootCmd.PersistentFlags().Bool("fail-fast", false, "Stop at the first file that fails to compile (default)")
+	rootCmd.PersistentFlags().Bool("keep-going", false, "Compile all files and report every failure at the end")
+	rootCmd.MarkFlagsMutuallyExclusive("fail-fast", "keep-going")
+	rootCmd.PersistentFlags().Bool("notify", false, "Show a desktop notification when a build taking longer than notify.after (default 10s) finishes")
+	rootCmd.PersistentFlags().String("runner", "", "Run the compiler locally or in a container: local or docker (see docker: in the config)")
+	rootCmd.PersistentFlags().Bool("depfile", false, "Write a make-style dependency file (<module>.d) next to each built source")
+	rootCmd.PersistentFlags().Bool("sandbox", false, "Compile each file in its own temporary directory and copy its artifacts back")
+	rootCmd.PersistentFlags().Bool("parallel-series", false, "Compile each series of a multi-series target at the same time, each in its own sandbox")
+	rootCmd.PersistentFlags().Bool("split-work", false, "Compile each series separately and also keep its outputs in its own SPlsWork_S2, SPlsWork_S3 or SPlsWork_S4 folder")
+	rootCmd.PersistentFlags().Float64("fault-inject", 0, "Randomly inject cache faults with the given probability (testing only)")
+	_ = rootCmd.PersistentFlags().MarkHidden("fault-inject")
+	rootCmd.AddCommand(benchCmd)
 	rootCmd.AddCommand(buildCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(checkCmd)
+	rootCmd.AddCommand(cleanCmd)
+	rootCmd.AddCommand(codesCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(depsCmd)
+	rootCmd.AddCommand(docsCmd)
+	rootCmd.AddCommand(graphCmd)
+	rootCmd.AddCommand(hashCmd)
+	rootCmd.AddCommand(hookCmd)
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(inspectCmd)
+	rootCmd.AddCommand(lintCmd)
+	rootCmd.AddCommand(lockCmd)
+	rootCmd.AddCommand(lspCmd)
+	rootCmd.AddCommand(newCmd)
+	rootCmd.AddCommand(packCmd)
+	rootCmd.AddCommand(publishCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(toolchainsCmd)
+	rootCmd.AddCommand(tuiCmd)
+	rootCmd.AddCommand(upgradeCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(whichCmd)
+}
+
+// openLogFile starts appending log records to --log-file, tagged with the
+// source file, target and phase of each build step, so CI builds can be
+// analysed after the fact
+func openLogFile(cmd *cobra.Command) error {
+	path, _ := cmd.Flags().GetString("log-file")
+	name, _ := cmd.Flags().GetString("log-format")
+
+	format, err := logger.ParseFormat(name)
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	logFile = f
+	logger.SetFile(f, format)
+	logger.With("command", cmd.CommandPath(), "version", version.Version).Debugf("spc started")
 
-	viper.SetDefault("compiler_path", "C:/Program Files (x86)/Crestron/Simpl/SPlusCC.exe")
-	viper.SetDefault("target", "234")
-	viper.SetDefault("silent", false)
-	viper.SetDefault("verbose", false)
+	return nil
 }
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show build time, failure and cache hit trends per module",
+	Long: `Show how each module's builds have gone over a recent period (--last, default 30d), from
+the build history kept in the cache: how often it was built, its average compile time,
+failure rate and cache hit rate, each with a trend line across the period (oldest on the
+left).`,
+	Args:         cobra.NoArgs,
+	RunE:         runStats,
+	SilenceUsage: true,
+}
+
+func init() {
+	statsCmd.Flags().String("last", "30d", "Period to report on, e.g. 7d or 72h")
+	statsCmd.Flags().Bool("json", false, "Output as JSON")
+}
+
+// trendIntervals is the number of intervals the period is split into, the
+// width of each trend line
+const trendIntervals = 20
+
+// buildStats is the output of spc stats --json
+type buildStats struct {
+	Since   time.Time           `json:"since"`
+	Until   time.Time           `json:"until"`
+	Builds  int                 `json:"builds"`
+	Modules []cache.ModuleTrend `json:"modules"`
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	cfg, _, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	last, _ := cmd.Flags().GetString("last")
+	period, err := config.ParseTTL(last)
+	if err != nil || period <= 0 {
+		return fmt.Errorf("invalid --last %q: expected a duration such as 7d or 72h", last)
+	}
+
+	if cfg.NoCache {
+		return fmt.Errorf("the build cache is disabled")
+	}
+
+	buildCache := openCache(cmd.Context(), cfg)
+	if buildCache == nil {
+		return fmt.Errorf("build cache is unavailable")
+	}
+
+	defer buildCache.Close()
+
+	until := time.Now()
+	since := until.Add(-period)
+
+	records, err := buildCache.History(since)
+	if err != nil {
+		return fmt.Errorf("failed to read build history: %w", err)
+	}
+
+	stats := buildStats{
+		Since:   since,
+		Until:   until,
+		Builds:  len(records),
+		Modules: cache.Trends(records, since, until, trendIntervals),
+	}
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	if len(records) == 0 {
+		fmt.Printf("No builds recorded in the last %s\n", last)
+		return nil
+	}
+
+	var total cache.TrendPoint
+	for _, m := range stats.Modules {
+		total.Builds += m.Total.Builds
+		total.Cached += m.Total.Cached
+		total.Failed += m.Total.Failed
+	}
+
+	fmt.Printf("Last %s: %d build(s) of %d file(s), %.0f%% from cache, %.0f%% failed\n\n",
+		last, len(records), total.Builds, total.HitRate()*100, total.FailureRate()*100)
+
+	cwd, _ := os.Getwd()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODULE\tBUILDS\tCOMPILE\tTREND\tFAILED\tTREND\tCACHE HITS\tTREND")
+
+	for _, m := range stats.Modules {
+		name := m.Path
+		if rel, err := filepath.Rel(cwd, m.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+
+		compile := "-"
+		if m.Total.Compiled() > 0 {
+			compile = m.Total.MeanCompileTime().Round(10 * time.Millisecond).String()
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%.0f%%\t%s\t%.0f%%\t%s\n",
+			name, m.Total.Builds,
+			compile, trendLine(m.Points, compileTrend, false),
+			m.Total.FailureRate()*100, trendLine(m.Points, cache.TrendPoint.FailureRate, true),
+			m.Total.HitRate()*100, trendLine(m.Points, cache.TrendPoint.HitRate, true))
+	}
+
+	return w.Flush()
+}
+
+// compileTrend is the value plotted for compile times (intervals with only
+// cache hits have none)
+func compileTrend(p cache.TrendPoint) float64 {
+	return p.MeanCompileTime().Seconds()
+}
+
+// sparks are the bars of a trend line, lowest first
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// trendLine draws a value of each interval as a bar, scaled to the highest
+// value (or to 0-1 for rates). Intervals without builds are left blank
+func trendLine(points []cache.TrendPoint, value func(cache.TrendPoint) float64, rate bool) string {
+	top := 1.0
+	if !rate {
+		top = 0
+		for _, p := range points {
+			if p.Compiled() > 0 {
+				top = max(top, value(p))
+			}
+		}
+	}
+
+	var line strings.Builder
+	for _, p := range points {
+		if p.Builds == 0 || (!rate && p.Compiled() == 0) {
+			line.WriteRune(' ')
+			continue
+		}
+
+		i := 0
+		if top > 0 {
+			i = min(int(value(p)/top*float64(len(sparks)-1)+0.5), len(sparks)-1)
+		}
+
+		line.WriteRune(sparks[i])
+	}
+
+	return line.String()
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Norgate-AV/spc/internal/toolchain"
+	"github.com/spf13/cobra"
+)
+
+var toolchainsCmd = &cobra.Command{
+	Use:   "toolchains",
+	Short: "List the Crestron compilers and tools installed on this machine",
+	Long: `List every SIMPL+ cross compiler (SPlusCC.exe), SIMPL Windows and SIMPL+ editor installed
+on this machine, with its version and path, found in the installed programs recorded in the
+registry and by scanning the Crestron directory under Program Files (or the wine prefix on
+other platforms). Use --json to collect the toolchains of a fleet of build machines.`,
+	Args:         cobra.NoArgs,
+	RunE:         runToolchains,
+	SilenceUsage: true,
+}
+
+func init() {
+	toolchainsCmd.Flags().Bool("json", false, "Output as JSON")
+}
+
+// toolchainsReport is the output of spc toolchains --json
+type toolchainsReport struct {
+	Host  string           `json:"host"`
+	Tools []toolchain.Tool `json:"tools"`
+}
+
+func runToolchains(cmd *cobra.Command, args []string) error {
+	tools := toolchain.Discover(toolchain.InstallRoots())
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		host, _ := os.Hostname()
+		if tools == nil {
+			tools = []toolchain.Tool{}
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(toolchainsReport{Host: host, Tools: tools})
+	}
+
+	if len(tools) == 0 {
+		fmt.Println("No Crestron toolchains found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tVERSION\tPATH\tSOURCE")
+
+	for _, t := range tools {
+		version := t.Version
+		if version == "" {
+			version = "-"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.Kind, t.Name, version, t.Path, t.Source)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Norgate-AV/spc/internal/build"
+	"github.com/Norgate-AV/spc/internal/tui"
+	"github.com/spf13/cobra"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui <files...>",
+	Short: "Build SIMPL+ file(s) in an interactive terminal UI",
+	Long: `Build SIMPL+ file(s) with a live terminal UI showing per-file status, cache hits and
+compiler output. Files can be rebuilt and their logs inspected without leaving the UI.`,
+	RunE:         runTUI,
+	SilenceUsage: true,
+}
+
+func runTUI(cmd *cobra.Command, args []string) error {
+	cfg, args, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	files, err := expandSources(cfg, args)
+	if err != nil {
+		return err
+	}
+
+	files = orderSources(cfg, files)
+
+	buildCache := openCache(cmd.Context(), cfg)
+	if buildCache != nil {
+		defer buildCache.Close()
+	}
+
+	results, err := tui.Run(cmd.Context(), build.NewSession(cfg, buildCache), files)
+	if err != nil {
+		return err
+	}
+
+	if failures := build.Failed(results); len(failures) > 0 {
+		return fmt.Errorf("%d file(s) failed to compile", len(failures))
+	}
+
+	return nil
+}
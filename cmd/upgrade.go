@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/Norgate-AV/spc/internal/network"
+	"github.com/Norgate-AV/spc/internal/update"
+	"github.com/Norgate-AV/spc/internal/version"
+	"github.com/spf13/cobra"
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:          "upgrade",
+	Short:        "Upgrade spc to the latest release",
+	Long:         `Check GitHub for a newer release of spc, verify its checksum and replace the current executable.`,
+	Args:         cobra.NoArgs,
+	RunE:         runUpgrade,
+	SilenceUsage: true,
+}
+
+func init() {
+	upgradeCmd.Flags().Bool("check", false, "Only check whether a newer version is available")
+	upgradeCmd.Flags().Bool("force", false, "Install the latest release even if it is not newer")
+}
+
+func runUpgrade(cmd *cobra.Command, args []string) error {
+	checkOnly, _ := cmd.Flags().GetBool("check")
+	force, _ := cmd.Flags().GetBool("force")
+
+	updater := update.NewUpdater(runtime.GOOS, runtime.GOARCH)
+
+	transport, err := upgradeTransport(cmd)
+	if err != nil {
+		return err
+	}
+
+	updater.SetTransport(transport)
+
+	release, err := updater.Latest(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	newer, err := update.IsNewer(version.Version, release.Version())
+	if err != nil && !force {
+		return fmt.Errorf("cannot compare current version %q with %s (use --force to install anyway): %w",
+			version.Version, release.TagName, err)
+	}
+
+	if !newer && !force {
+		fmt.Printf("spc is up to date (%s)\n", version.Version)
+		return nil
+	}
+
+	if checkOnly {
+		fmt.Printf("A new version of spc is available: %s (current: %s)\n", release.Version(), version.Version)
+		return nil
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate current executable: %w", err)
+	}
+
+	if resolved, err := filepath.EvalSymlinks(exePath); err == nil {
+		exePath = resolved
+	}
+
+	fmt.Printf("Downloading spc %s...\n", release.Version())
+
+	binary, err := updater.Download(cmd.Context(), release)
+	if err != nil {
+		return err
+	}
+
+	if err := update.Replace(exePath, binary); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Upgraded spc to %s\n", release.Version())
+
+	return nil
+}
+
+// upgradeTransport returns the transport for downloading releases. A broken
+// config shouldn't stop spc upgrading, so it only loses the network settings
+func upgradeTransport(cmd *cobra.Command) (http.RoundTripper, error) {
+	var settings config.NetworkConfig
+
+	cfg, _, err := loadBuildConfig(cmd, nil)
+	if err != nil {
+		logger.Warnf("Ignoring the config: %v", err)
+	} else {
+		settings = cfg.Network
+	}
+
+	return network.Transport(settings)
+}
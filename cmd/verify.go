@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/Norgate-AV/spc/internal/build"
+	"github.com/Norgate-AV/spc/internal/lock"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [files...]",
+	Short: "Check that a build reproduces the artifacts pinned in spc.lock",
+	Long: `Build the modules in spc.lock (or only the given files), restoring them from the cache
+where possible, and compare the sources, libraries, compilers and artifacts against the lock.
+Every difference is listed and the command fails if there are any, proving the shipped .ush
+and SPlsWork outputs were built from the locked source.
+
+The lock is read from the project directory, or from the file given with --lock. Use
+--no-cache to rebuild every module rather than restore it.`,
+	RunE:         runVerify,
+	SilenceUsage: true,
+}
+
+func init() {
+	verifyCmd.Flags().String("lock", "", "Lock file to verify against (default: spc.lock in the project directory)")
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	cfg, args, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	dir := lockDir(cfg)
+
+	path, _ := cmd.Flags().GetString("lock")
+	if path == "" {
+		path = filepath.Join(dir, lock.FileName)
+	}
+
+	locked, err := lock.Read(path)
+	if err != nil {
+		return fmt.Errorf("failed to read lock file: %w", err)
+	}
+
+	files := locked.Sources(dir)
+	if len(args) > 0 {
+		if files, err = expandSources(cfg, args); err != nil {
+			return err
+		}
+	}
+
+	if len(files) == 0 {
+		return fmt.Errorf("%s has no modules to verify", path)
+	}
+
+	buildCache := openCache(cmd.Context(), cfg)
+	if buildCache != nil {
+		defer buildCache.Close()
+	}
+
+	results := build.NewSession(cfg, buildCache).Build(cmd.Context(), files, true)
+	if failures := build.Failed(results); len(failures) > 0 {
+		return fmt.Errorf("%d file(s) failed to build", len(failures))
+	}
+
+	built, err := lock.Generate(cfg, files, dir)
+	if err != nil {
+		return err
+	}
+
+	diffs := lock.Diff(locked, built)
+	if len(diffs) > 0 {
+		for _, d := range diffs {
+			logger.Errorf("%s", d)
+		}
+
+		return fmt.Errorf("build does not match %s (%d difference(s))", path, len(diffs))
+	}
+
+	fmt.Printf("Verified %d module(s) against %s\n", len(built.Modules), path)
+
+	return nil
+}
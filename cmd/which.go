@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+var whichCmd = &cobra.Command{
+	Use:   "which [path]",
+	Short: "Show which SIMPL+ compiler would be used and why",
+	Long: `Print the SIMPL+ compiler a build of path (default: current directory) would run, then
+every place the compiler path can be set, highest precedence first: the --compiler-path flag,
+the SPC_COMPILER_PATH environment variable, the --profile, the local and global config files,
+the SIMPL Windows install in the registry, and the built-in default. The source that wins is
+marked with *. Per-series compilers (compiler_paths) are listed after it.`,
+	Args:         cobra.MaximumNArgs(1),
+	RunE:         runWhich,
+	SilenceUsage: true,
+}
+
+func init() {
+	whichCmd.Flags().Bool("json", false, "Output as JSON")
+}
+
+// whichReport is the output of spc which --json
+type whichReport struct {
+	Compiler string                  `json:"compiler"`
+	Exists   bool                    `json:"exists"`
+	Runner   string                  `json:"runner,omitempty"`
+	Sources  []config.CompilerSource `json:"sources"`
+	Series   map[string]string       `json:"series,omitempty"`
+}
+
+func runWhich(cmd *cobra.Command, args []string) error {
+	cfg, args, err := loadBuildConfig(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	sources, err := config.NewLoader().CompilerChain(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	report := whichReport{Compiler: cfg.CompilerPath, Runner: cfg.Runner, Sources: sources, Series: cfg.CompilerPaths}
+	if _, err := os.Stat(cfg.CompilerPath); err == nil {
+		report.Exists = true
+	}
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	fmt.Println(report.Compiler)
+	if !report.Exists {
+		logger.Warnf("%s does not exist", report.Compiler)
+	}
+
+	if cfg.Runner == config.RunnerDocker {
+		fmt.Printf("(runs in %s as %s)\n", cfg.Docker.Image, cfg.Docker.CompilerPath)
+	}
+
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	used := false
+	for _, s := range sources {
+		mark := " "
+		if s.Value != "" && !used {
+			mark, used = "*", true
+		}
+
+		value := s.Value
+		if value == "" {
+			value = "-"
+		}
+
+		origin := s.Origin
+		if origin == "" {
+			origin = "-"
+		}
+
+		fmt.Fprintf(w, "%s %s\t%s\t%s\n", mark, s.Layer, origin, value)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.Series) > 0 {
+		fmt.Println("\nPer-series compilers (compiler_paths):")
+
+		names := make([]string, 0, len(report.Series))
+		for name := range report.Series {
+			names = append(names, name)
+		}
+
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Printf("  %s: %s\n", name, report.Series[name])
+		}
+	}
+
+	return nil
+}
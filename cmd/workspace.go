@@ -0,0 +1,311 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/build"
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/diag"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/Norgate-AV/spc/internal/webhook"
+	"github.com/spf13/cobra"
+)
+
+// workspaceProject is the outcome of building one project of a workspace
+type workspaceProject struct {
+	// Name is the project directory relative to the workspace
+	Name string
+
+	cfg     *config.Config
+	results []build.Result
+
+	// err is set when the project's files could not be worked out
+	err error
+}
+
+// failed returns the number of files of the project that failed, counting a
+// project that could not be built as one
+func (p *workspaceProject) failed() int {
+	if p.err != nil {
+		return 1
+	}
+
+	return len(build.Failed(p.results))
+}
+
+// workspaceReport is the output of spc build --workspace --format json
+type workspaceReport struct {
+	Workspace string                   `json:"workspace"`
+	Projects  []workspaceReportProject `json:"projects"`
+	Failed    int                      `json:"failed"`
+}
+
+// workspaceReportProject is the results of one project in a workspace report
+type workspaceReportProject struct {
+	Project string             `json:"project"`
+	Error   string             `json:"error,omitempty"`
+	Results []buildReportEntry `json:"results"`
+	Failed  int                `json:"failed"`
+}
+
+// runWorkspaceBuild builds every project of a workspace in turn, each with
+// its own local config, sharing the cache of the workspace's config
+func runWorkspaceBuild(cmd *cobra.Command, args []string) error {
+	var extraArgs []string
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		args, extraArgs = args[:dash], args[dash:]
+	}
+
+	if len(args) > 0 {
+		return fmt.Errorf("--workspace builds the files of each project; source arguments are not allowed")
+	}
+
+	path, _ := cmd.Flags().GetString("workspace")
+	ws, err := openWorkspace(path)
+	if err != nil {
+		return err
+	}
+
+	// The workspace's own config (if any) sets up logging and the cache
+	root, err := config.NewLoader().LoadForWorkspaceProject(cmd, ws.Dir)
+	if err != nil {
+		return err
+	}
+
+	logger.SetLevel(logger.LevelFromFlags(root.Verbosity, root.Quiet))
+
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "json" {
+		return fmt.Errorf("invalid format %q (expected text or json)", format)
+	}
+
+	out := io.Writer(os.Stdout)
+	if format == "json" {
+		logger.Default().SetOutput(os.Stderr, os.Stderr)
+		out = os.Stderr
+	}
+
+	keepGoing := root.KeepGoing
+	if failFast, _ := cmd.Flags().GetBool("fail-fast"); failFast {
+		keepGoing = false
+	}
+
+	if err := setupFaultInjection(cmd); err != nil {
+		return err
+	}
+
+	buildCache := openCache(cmd.Context(), root)
+	if buildCache != nil {
+		defer buildCache.Close()
+	}
+
+	ref, _ := cmd.Flags().GetString("changed")
+	diagnostics := make(map[string][]diag.Diagnostic)
+
+	var projects []*workspaceProject
+	start := time.Now()
+
+	for _, dir := range ws.Projects {
+		p := &workspaceProject{Name: ws.Name(dir)}
+		projects = append(projects, p)
+
+		var files []string
+		p.cfg, err = config.NewLoader().LoadForWorkspaceProject(cmd, dir)
+		if err == nil {
+			p.cfg.ExtraArgs = append(p.cfg.ExtraArgs, extraArgs...)
+			files, err = workspaceSources(p.cfg, dir, ref)
+		}
+
+		if err != nil {
+			p.err = err
+
+			if !keepGoing {
+				break
+			}
+
+			continue
+		}
+
+		logger.Infof("Building %s (%d file(s))", p.Name, len(files))
+
+		session := build.NewSession(p.cfg, buildCache)
+		session.Stdout = out
+		session.OnDiagnostic = collectDiagnostics(out, diagnostics)
+
+		if ciProvider.Active() {
+			session.OnStatus = reportProgress(out, len(files))
+		}
+
+		projectStart := time.Now()
+		p.results = session.Build(cmd.Context(), files, keepGoing)
+		recordProject(cmd, buildCache, p, diagnostics, projectStart)
+
+		if !keepGoing && p.failed() > 0 {
+			break
+		}
+	}
+
+	if buildCache != nil {
+		pruneCache(buildCache, root)
+	}
+
+	var results []build.Result
+	failed := 0
+	for _, p := range projects {
+		results = append(results, p.results...)
+		failed += p.failed()
+	}
+
+	if ciProvider.Active() {
+		reportCI(out, results, diagnostics, time.Since(start))
+	}
+
+	if format == "json" {
+		if err := writeWorkspaceReport(ws, projects); err != nil {
+			return err
+		}
+	} else {
+		printWorkspaceSummary(ws, projects)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d failure(s) in workspace %s", failed, ws.Path)
+	}
+
+	if sums, _ := cmd.Flags().GetString("checksums"); sums != "" {
+		var files []string
+		for _, p := range projects {
+			artifacts, err := artifactFiles(p.cfg, p.results)
+			if err != nil {
+				return err
+			}
+
+			files = append(files, artifacts...)
+		}
+
+		return writeSums(files, sums)
+	}
+
+	return nil
+}
+
+// openWorkspace loads a workspace file, looking for spc.workspace.yml in a
+// directory and its parents when given a directory
+func openWorkspace(path string) (*config.Workspace, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Stat(abs); err == nil && info.IsDir() {
+		found := config.FindWorkspace(abs)
+		if found == "" {
+			return nil, fmt.Errorf("no %s found in %s or its parents", config.WorkspaceFile, abs)
+		}
+
+		abs = found
+	}
+
+	return config.LoadWorkspace(abs)
+}
+
+// workspaceSources returns the files to build for a workspace project, in
+// build order: the files: list of the project's own config, or everything in
+// its directory, narrowed to those changed since ref when set
+func workspaceSources(cfg *config.Config, dir, ref string) ([]string, error) {
+	args := []string{dir}
+	if cfg.ProjectDir == dir {
+		if files := cfg.ProjectFiles(); len(files) > 0 {
+			args = files
+		}
+	}
+
+	files, err := expandSources(cfg, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref != "" {
+		if files, err = changedSources(cfg, files, ref); err != nil {
+			return nil, err
+		}
+	}
+
+	return orderSources(cfg, files), nil
+}
+
+// recordProject records a project's build in the cache history and sends
+// its webhook and desktop notifications
+func recordProject(cmd *cobra.Command, buildCache *cache.Cache, p *workspaceProject, diagnostics map[string][]diag.Diagnostic, start time.Time) {
+	elapsed := time.Since(start)
+
+	if buildCache != nil && len(p.results) > 0 {
+		if err := buildCache.RecordBuild(build.Record(p.cfg, p.results, start, elapsed)); err != nil {
+			logger.Warnf("Failed to record build history: %v", err)
+		}
+	}
+
+	if len(p.cfg.Webhooks) > 0 {
+		report := webhook.NewReport(p.cfg, p.results, diagnostics, elapsed)
+		if err := webhook.Send(cmd.Context(), http.DefaultClient, p.cfg.Webhooks, report); err != nil {
+			logger.Warnf("Failed to send build notification for %s: %v", p.Name, err)
+		}
+	}
+
+	notifyDesktop(p.cfg, p.results, elapsed)
+}
+
+// printWorkspaceSummary prints a line per project of a workspace build,
+// listing projects that were skipped after a failure
+func printWorkspaceSummary(ws *config.Workspace, projects []*workspaceProject) {
+	fmt.Printf("\nWorkspace %s:\n", ws.Path)
+
+	for _, p := range projects {
+		switch {
+		case p.err != nil:
+			fmt.Printf("  ✗ %s: %v\n", p.Name, p.err)
+		case p.failed() > 0:
+			fmt.Printf("  ✗ %s: %d of %d file(s) failed\n", p.Name, p.failed(), len(p.results))
+		default:
+			fmt.Printf("  ✓ %s: %d file(s)\n", p.Name, len(p.results))
+		}
+	}
+
+	for _, dir := range ws.Projects[len(projects):] {
+		fmt.Printf("  - %s: skipped\n", ws.Name(dir))
+	}
+}
+
+// writeWorkspaceReport prints the results of a workspace build as JSON
+func writeWorkspaceReport(ws *config.Workspace, projects []*workspaceProject) error {
+	report := workspaceReport{Workspace: ws.Path, Projects: []workspaceReportProject{}}
+	for _, p := range projects {
+		entry := workspaceReportProject{Project: p.Name, Results: []buildReportEntry{}, Failed: p.failed()}
+		if p.err != nil {
+			entry.Error = p.err.Error()
+		}
+
+		for _, r := range p.results {
+			result := buildReportEntry{File: r.File, Status: r.Status.String(), Duration: r.Duration}
+			if r.Err != nil {
+				result.Error = r.Err.Error()
+			}
+
+			entry.Results = append(entry.Results, result)
+		}
+
+		report.Failed += entry.Failed
+		report.Projects = append(report.Projects, entry)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
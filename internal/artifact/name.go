@@ -0,0 +1,120 @@
+// Package artifact matches the files the SIMPL+ compiler writes to the module
+// they were compiled from.
+//
+// The compiler names its outputs after the module, but not consistently. For
+// a module "example 3.usp":
+//
+//	example 3.ush                    header beside the source, every series
+//	SPlsWork/example 3.inf           Series 3/4, name kept
+//	SPlsWork/example_3.cs, .dll      Series 3/4, spaces become underscores
+//	SPlsWork/S2_example_3.c, .h      Series 2, prefixed, spaces become underscores
+//
+// Names are compared the way Windows compares file names: case-insensitively,
+// and after Unicode normalization, so a name typed in a different case or
+// composed differently (e.g. copied from macOS) still matches.
+package artifact
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Series an output belongs to, as returned by Module.Match
+const (
+	// AllSeries is the series of the .ush header, generated for every target
+	AllSeries = ""
+	// Series34 is the series of unprefixed outputs, shared by Series 3 and 4
+	Series34 = "34"
+)
+
+// HeaderExt is the extension of the header the compiler writes beside the source
+const HeaderExt = ".ush"
+
+// Module is a SIMPL+ module, identified by the name its outputs are given
+type Module struct {
+	name        string
+	folded      string
+	underscored string
+}
+
+// NewModule returns the module compiled from sourceFile ("example 3.usp" is
+// module "example 3")
+func NewModule(sourceFile string) Module {
+	base := filepath.Base(sourceFile)
+	return ModuleNamed(strings.TrimSuffix(base, filepath.Ext(base)))
+}
+
+// ModuleNamed returns the module with the given name
+func ModuleNamed(name string) Module {
+	return Module{
+		name:        name,
+		folded:      Fold(name),
+		underscored: Fold(Underscore(name)),
+	}
+}
+
+// Name returns the module name as written
+func (m Module) Name() string {
+	return m.name
+}
+
+// Match reports whether filename is an output of the module and, if so, the
+// series it belongs to: AllSeries for the header, Series34 for unprefixed
+// outputs, and "2", "3" or "4" for outputs prefixed S2_, S3_ or S4_
+func (m Module) Match(filename string) (string, bool) {
+	ext := filepath.Ext(filename)
+	base := Fold(strings.TrimSuffix(filename, ext))
+
+	if m.is(base) {
+		if Fold(ext) == HeaderExt {
+			return AllSeries, true
+		}
+
+		return Series34, true
+	}
+
+	// Series prefixes are ASCII, so survive folding as "s2_" and so on
+	if len(base) > 3 && base[0] == 's' && base[2] == '_' && base[1] >= '2' && base[1] <= '4' && m.is(base[3:]) {
+		return base[1:2], true
+	}
+
+	return "", false
+}
+
+// ForTarget reports whether filename is an output of the module for a target
+// such as "34" or "234"
+func (m Module) ForTarget(filename, target string) bool {
+	series, ok := m.Match(filename)
+	if !ok {
+		return false
+	}
+
+	return series == AllSeries || strings.ContainsAny(target, series)
+}
+
+// is reports whether a folded file name is the module's name, as written or
+// with the compiler's underscores
+func (m Module) is(folded string) bool {
+	return folded == m.folded || folded == m.underscored
+}
+
+// Underscore returns a module name the way the compiler writes it in the names
+// of generated code: spaces become underscores
+func Underscore(name string) string {
+	return strings.ReplaceAll(name, " ", "_")
+}
+
+// Fold returns the form file names are compared in: NFC normalized and case
+// folded
+func Fold(name string) string {
+	// A Caser keeps state between calls, so one can't be shared
+	return cases.Fold().String(norm.NFC.String(name))
+}
+
+// Equal reports whether two file names name the same file on Windows
+func Equal(a, b string) bool {
+	return Fold(a) == Fold(b)
+}
@@ -0,0 +1,103 @@
+package artifact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModule_Match(t *testing.T) {
+	tests := []struct {
+		source   string
+		filename string
+		series   string
+		ok       bool
+	}{
+		// Plain names
+		{"example.usp", "example.ush", AllSeries, true},
+		{"example.usp", "example.dll", Series34, true},
+		{"example.usp", "example.cs", Series34, true},
+		{"example.usp", "example.inf", Series34, true},
+		{"example.usp", "S2_example.c", "2", true},
+		{"example.usp", "S2_example.h", "2", true},
+		{"example.usp", "S2_example.elf", "2", true},
+		{"example.usp", "S3_example.c", "3", true},
+		{"example.usp", "S4_example.c", "4", true},
+
+		// Spaces are kept in the header and .inf, underscored in code files
+		{"example 3.usp", "example 3.ush", AllSeries, true},
+		{"example 3.usp", "example 3.inf", Series34, true},
+		{"example 3.usp", "example_3.cs", Series34, true},
+		{"example 3.usp", "example_3.dll", Series34, true},
+		{"example 3.usp", "S2_example_3.c", "2", true},
+		{"example 3.usp", "S2_example_3.h", "2", true},
+		{"example 3.usp", "example_3.ush", AllSeries, true},
+		{"room  audio.usp", "room__audio.dll", Series34, true},
+		{"room  audio.usp", "room_audio.dll", "", false},
+
+		// Windows compares names case-insensitively
+		{"Example.usp", "EXAMPLE.DLL", Series34, true},
+		{"Example.usp", "example.USH", AllSeries, true},
+		{"example.usp", "s2_Example.c", "2", true},
+
+		// Unicode names match however they are composed or cased
+		{"caf\u00e9.usp", "cafe\u0301.dll", Series34, true},
+		{"\u00c9cran 2.usp", "\u00e9cran_2.cs", Series34, true},
+		{"stra\u00dfe.usp", "STRASSE.dll", Series34, true},
+
+		// Other modules and shared files
+		{"example.usp", "example1.dll", "", false},
+		{"example 3.usp", "example.dll", "", false},
+		{"example.usp", "other_example.cs", "", false},
+		{"example.usp", "S5_example.c", "", false},
+		{"example.usp", "SX_example.c", "", false},
+		{"example.usp", "S2example.c", "", false},
+		{"example.usp", "ManagedUtilities.dll", "", false},
+		{"example.usp", "Version.ini", "", false},
+
+		// A module whose own name looks prefixed is matched as written first
+		{"S2_legacy.usp", "S2_legacy.dll", Series34, true},
+		{"S2_legacy.usp", "S2_S2_legacy.c", "2", true},
+	}
+
+	for _, test := range tests {
+		series, ok := NewModule(test.source).Match(test.filename)
+		assert.Equal(t, test.ok, ok, "Match(%q) for %s", test.filename, test.source)
+		assert.Equal(t, test.series, series, "Match(%q) for %s", test.filename, test.source)
+	}
+}
+
+func TestModule_ForTarget(t *testing.T) {
+	module := NewModule("/src/example 3.usp")
+
+	tests := []struct {
+		filename string
+		target   string
+		want     bool
+	}{
+		{"example 3.ush", "2", true},
+		{"example 3.ush", "34", true},
+		{"example_3.dll", "34", true},
+		{"example_3.dll", "4", true},
+		{"example_3.dll", "2", false},
+		{"S2_example_3.c", "2", true},
+		{"S2_example_3.c", "234", true},
+		{"S2_example_3.c", "34", false},
+		{"S3_example_3.c", "4", false},
+		{"other.dll", "234", false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, module.ForTarget(test.filename, test.target), "ForTarget(%q, %q)", test.filename, test.target)
+	}
+}
+
+func TestNames(t *testing.T) {
+	assert.Equal(t, "example 3", NewModule("/src/example 3.usp").Name())
+	assert.Equal(t, "example_3", NewModule("example_3.usl").Name())
+	assert.Equal(t, "a_b_c", Underscore("a b c"))
+
+	assert.True(t, Equal("SPlsWork", "splswork"))
+	assert.True(t, Equal("caf\u00e9", "CAFE\u0301"))
+	assert.False(t, Equal("example 3", "example_3"))
+}
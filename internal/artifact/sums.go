@@ -0,0 +1,67 @@
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// WriteSums writes a checksums manifest of files in the format of sha256sum
+// ("<hash>  <path>" per line), so it can be checked with sha256sum -c and
+// signed for distribution. Paths are relative to the manifest's directory
+// when inside it and use forward slashes; lines are sorted by path
+func WriteSums(path string, files []string) error {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	lines := make([]string, 0, len(files))
+	for _, file := range files {
+		sum, err := sha256File(file)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %w", file, err)
+		}
+
+		name := file
+		if rel, err := filepath.Rel(dir, file); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+
+		lines = append(lines, sum+"  "+filepath.ToSlash(name))
+	}
+
+	sort.Slice(lines, func(i, j int) bool { return lines[i][sha256.Size*2:] < lines[j][sha256.Size*2:] })
+
+	var content strings.Builder
+	for _, line := range lines {
+		content.WriteString(line + "\n")
+	}
+
+	if err := os.WriteFile(path, []byte(content.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// sha256File returns the hex SHA256 of a file's content
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
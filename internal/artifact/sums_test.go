@@ -0,0 +1,49 @@
+package artifact
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSums(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "other.ush")
+
+	files := map[string]string{
+		filepath.Join(dir, "src", "example.ush"):             "header",
+		filepath.Join(dir, "src", "SPlsWork", "example.dll"): "",
+		outside: "other",
+	}
+
+	for path, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	sums := filepath.Join(dir, "SHA256SUMS")
+	require.NoError(t, WriteSums(sums, []string{
+		filepath.Join(dir, "src", "example.ush"),
+		outside,
+		filepath.Join(dir, "src", "SPlsWork", "example.dll"),
+	}))
+
+	data, err := os.ReadFile(sums)
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		"d9298a10d1b0735837dc4bd85dac641b0f3cef27a47e5d53a54f2f3f5b2fcffa  "+filepath.ToSlash(outside)+"\n"+
+			"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  src/SPlsWork/example.dll\n"+
+			"1e0584a25d9f43bf5cbd0aec01eb1af2220ed085b4e7f1837b0d89958cae353a  src/example.ush\n",
+		string(data))
+}
+
+func TestWriteSums_MissingFile(t *testing.T) {
+	dir := t.TempDir()
+
+	err := WriteSums(filepath.Join(dir, "SHA256SUMS"), []string{filepath.Join(dir, "missing.ush")})
+	assert.ErrorContains(t, err, "failed to hash")
+}
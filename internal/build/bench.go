@@ -0,0 +1,148 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/logger"
+)
+
+// BenchSample is one run of a benchmark
+type BenchSample struct {
+	// Compile is the time taken to compile the file
+	Compile time.Duration
+
+	// Store is the time taken to store the build in the cache
+	Store time.Duration
+
+	// Restore is the time taken to look the build up and restore its artifacts
+	Restore time.Duration
+}
+
+// BenchResult is the outcome of benchmarking a file
+type BenchResult struct {
+	// File is the source file as given by the user
+	File string
+
+	// Runs is the number of runs the medians are taken over
+	Runs int
+
+	// Compile, Store and Restore are the median times of the runs
+	Compile time.Duration
+	Store   time.Duration
+	Restore time.Duration
+}
+
+// Speedup is how many times faster a cache hit is than compiling
+func (r BenchResult) Speedup() float64 {
+	if r.Restore <= 0 {
+		return 0
+	}
+
+	return float64(r.Compile) / float64(r.Restore)
+}
+
+// Bench compiles a file runs times, storing each build in the cache and
+// restoring it again, and reports the median times. The session's cache must
+// be a scratch cache: it is cleared before every store so each run measures
+// a cold store rather than a re-store of identical artifacts
+func (s *Session) Bench(ctx context.Context, file string, runs int) (BenchResult, error) {
+	result := BenchResult{File: file, Runs: runs}
+
+	if s.cache == nil {
+		return result, fmt.Errorf("benchmarks need a cache")
+	}
+
+	if runs < 1 {
+		return result, fmt.Errorf("runs must be at least 1")
+	}
+
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return result, fmt.Errorf("failed to resolve path for %s: %w", file, err)
+	}
+
+	cfg := s.cfg.ForFile(absFile)
+	log := logger.ForFile(absFile, cfg.Target)
+
+	samples := make([]BenchSample, 0, runs)
+	for run := 1; run <= runs; run++ {
+		var sample BenchSample
+
+		start := time.Now()
+		if err := s.compile(ctx, absFile, cfg); err != nil {
+			return result, err
+		}
+
+		sample.Compile = time.Since(start)
+
+		if err := s.cache.Clear(); err != nil {
+			return result, fmt.Errorf("failed to clear benchmark cache: %w", err)
+		}
+
+		start = time.Now()
+		if err := s.cache.StoreTimed(ctx, absFile, cfg, true, sample.Compile); err != nil {
+			return result, fmt.Errorf("failed to store %s: %w", file, err)
+		}
+
+		sample.Store = time.Since(start)
+
+		start = time.Now()
+		entry, err := s.cache.Get(ctx, absFile, cfg)
+		if err != nil {
+			return result, fmt.Errorf("failed to look up %s: %w", file, err)
+		}
+
+		if entry == nil || !entry.Success {
+			return result, fmt.Errorf("%s was not cached after storing it", file)
+		}
+
+		lookup := time.Since(start)
+		sourceDir := filepath.Dir(absFile)
+
+		// Remove the outputs so the restore copies them as it would after a clean
+		for _, output := range entry.Outputs {
+			if err := os.Remove(filepath.Join(sourceDir, output.Path)); err != nil {
+				return result, fmt.Errorf("failed to remove %s: %w", output.Path, err)
+			}
+		}
+
+		start = time.Now()
+		if err := s.cache.Restore(ctx, entry, sourceDir); err != nil {
+			return result, fmt.Errorf("failed to restore %s: %w", file, err)
+		}
+
+		sample.Restore = lookup + time.Since(start)
+		samples = append(samples, sample)
+
+		log.Phase(logger.PhaseCompile).Debugf("run %d of %s: compile %s, store %s, restore %s",
+			run, filepath.Base(file), sample.Compile, sample.Store, sample.Restore)
+	}
+
+	result.Compile = median(samples, func(s BenchSample) time.Duration { return s.Compile })
+	result.Store = median(samples, func(s BenchSample) time.Duration { return s.Store })
+	result.Restore = median(samples, func(s BenchSample) time.Duration { return s.Restore })
+
+	return result, nil
+}
+
+// median returns the median of one of the times of samples
+func median(samples []BenchSample, field func(BenchSample) time.Duration) time.Duration {
+	times := make([]time.Duration, len(samples))
+	for i, sample := range samples {
+		times[i] = field(sample)
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	mid := len(times) / 2
+	if len(times)%2 == 0 {
+		return (times[mid-1] + times[mid]) / 2
+	}
+
+	return times[mid]
+}
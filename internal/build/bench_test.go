@@ -0,0 +1,75 @@
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+func TestSession_Bench(t *testing.T) {
+	files := writeSources(t, "a.usp")
+
+	c, err := cache.New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	var calls []string
+	s := NewSession(&config.Config{Target: "34"}, c)
+	s.compile = fakeCompile(&calls)
+
+	result, err := s.Bench(context.Background(), files[0], 3)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, result.Runs)
+	assert.Len(t, calls, 3, "every run compiles")
+	assert.Positive(t, result.Compile)
+	assert.Positive(t, result.Store)
+	assert.Positive(t, result.Restore)
+
+	// The outputs are left in place after the last restore
+	_, err = os.Stat(filepath.Join(filepath.Dir(files[0]), "a.ush"))
+	assert.NoError(t, err)
+}
+
+func TestSession_Bench_Errors(t *testing.T) {
+	files := writeSources(t, "a.usp", "b.usp")
+
+	var calls []string
+	_, err := NewSession(&config.Config{Target: "34"}, nil).Bench(context.Background(), files[0], 1)
+	assert.EqualError(t, err, "benchmarks need a cache")
+
+	c, err := cache.New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	s := NewSession(&config.Config{Target: "34"}, c)
+	s.compile = fakeCompile(&calls, "b.usp")
+
+	_, err = s.Bench(context.Background(), files[0], 0)
+	assert.EqualError(t, err, "runs must be at least 1")
+
+	_, err = s.Bench(context.Background(), files[1], 2)
+	assert.EqualError(t, err, "compile errors")
+}
+
+func TestBenchResult_Speedup(t *testing.T) {
+	r := BenchResult{Compile: 4 * time.Second, Restore: 20 * time.Millisecond}
+	assert.InDelta(t, 200.0, r.Speedup(), 0.001)
+	assert.Zero(t, BenchResult{Compile: time.Second}.Speedup())
+}
+
+func TestMedian(t *testing.T) {
+	samples := []BenchSample{{Compile: 3}, {Compile: 1}, {Compile: 2}}
+	compile := func(s BenchSample) time.Duration { return s.Compile }
+
+	assert.Equal(t, time.Duration(2), median(samples, compile))
+	assert.Equal(t, time.Duration(2), median(append(samples, BenchSample{Compile: 10}), compile))
+}
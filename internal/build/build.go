@@ -0,0 +1,563 @@
+// Package build orchestrates compiling SIMPL+ source files.
+//
+// A Session compiles files one at a time with a shared configuration. For each
+// file it first consults the build cache and restores cached artifacts on a hit;
+// otherwise it invokes the compiler and stores the result in the cache.
+// Observers (the CLI, the interactive TUI) are notified of every status change.
+package build
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/compiler"
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/diag"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/Norgate-AV/spc/internal/utils"
+)
+
+// ErrNotCached is returned by RestoreFile when there is no successful cached build
+var ErrNotCached = errors.New("no cached build")
+
+// Status is the state of a source file within a build
+type Status int
+
+const (
+	// StatusPending means the file has not been built yet
+	StatusPending Status = iota
+	// StatusCompiling means the compiler is running for the file
+	StatusCompiling
+	// StatusCached means the file's artifacts were restored from the cache
+	StatusCached
+	// StatusSucceeded means the file compiled successfully
+	StatusSucceeded
+	// StatusFailed means the file failed to compile
+	StatusFailed
+)
+
+// String returns a human readable name for the status
+func (s Status) String() string {
+	switch s {
+	case StatusPending:
+		return "pending"
+	case StatusCompiling:
+		return "compiling"
+	case StatusCached:
+		return "cached"
+	case StatusSucceeded:
+		return "success"
+	case StatusFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+// Done reports whether the status is final
+func (s Status) Done() bool {
+	return s == StatusCached || s == StatusSucceeded || s == StatusFailed
+}
+
+// Result is the outcome of building a single source file
+type Result struct {
+	// File is the source file as given by the user
+	File string
+
+	// Path is the absolute path to the source file
+	Path string
+
+	// Status is the current (or final) state of the build
+	Status Status
+
+	// Err is the build error when Status is StatusFailed
+	Err error
+
+	// Duration is how long the build took
+	Duration time.Duration
+
+	// Saved is the compile time a cache hit avoided, when the cache recorded it
+	Saved time.Duration
+}
+
+// Session compiles source files using a shared configuration and cache
+type Session struct {
+	cfg   *config.Config
+	cache *cache.Cache // nil when caching is disabled
+
+	// Stdout and Stderr receive compiler output (nil means os.Stdout/os.Stderr)
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// OnStatus, if set, is called whenever a file's status changes
+	OnStatus func(Result)
+
+	// OnDiagnostic, if set, is called for each error or warning found in the
+	// output of a failed compile instead of logging it
+	OnDiagnostic func(diag.Diagnostic)
+
+	// Runner runs the compiler (nil starts it as a process). A
+	// compiler.FakeRunner simulates it
+	Runner compiler.Runner
+
+	// compile invokes the compiler for a single file (replaced in tests)
+	compile func(ctx context.Context, sourceFile string, cfg *config.Config) error
+
+	// outLog collects the compile log of every run (nil without --out)
+	outLog *buildLog
+}
+
+// NewSession creates a build session. buildCache may be nil to disable caching
+func NewSession(cfg *config.Config, buildCache *cache.Cache) *Session {
+	s := &Session{
+		cfg:    cfg,
+		cache:  buildCache,
+		outLog: newBuildLog(cfg.OutputFile),
+	}
+
+	s.compile = s.compileFile
+
+	return s
+}
+
+// Build builds each file in order. Unless keepGoing is set, it stops at the
+// first failure, and cancelling ctx stops it before the next file. Returns
+// the results of every file that was attempted. Library hashes are shared
+// between the files, so a library is read once per build
+func (s *Session) Build(ctx context.Context, files []string, keepGoing bool) []Result {
+	if s.cache != nil {
+		done := s.cache.ShareHashes()
+		defer done()
+	}
+
+	results := make([]Result, 0, len(files))
+
+	for _, file := range files {
+		if ctx.Err() != nil {
+			break
+		}
+
+		result := s.BuildFile(ctx, file)
+		results = append(results, result)
+
+		if result.Status == StatusFailed && !keepGoing {
+			break
+		}
+	}
+
+	return results
+}
+
+// BuildFile builds a single file, restoring it from the cache when possible.
+// The config's hooks run before and after it. Cancelling ctx stops the
+// compiler, failing the file
+func (s *Session) BuildFile(ctx context.Context, file string) Result {
+	start := time.Now()
+	result := Result{File: file, Status: StatusPending}
+
+	var absFile string
+	var cfg *config.Config
+
+	finish := func(status Status, err error) Result {
+		if cfg != nil && status != StatusFailed {
+			if hookErr := s.runHooks(config.HookPostBuild, cfg.Hooks.PostBuild, absFile, cfg, status, nil); hookErr != nil {
+				status, err = StatusFailed, hookErr
+			}
+		}
+
+		if cfg != nil && status == StatusFailed {
+			if hookErr := s.runHooks(config.HookOnFailure, cfg.Hooks.OnFailure, absFile, cfg, status, err); hookErr != nil {
+				logger.ForFile(absFile, cfg.Target).Phase(logger.PhasePublish).Warnf("%v", hookErr)
+			}
+		}
+
+		result.Status = status
+		result.Err = err
+		result.Duration = time.Since(start)
+		s.notify(result)
+
+		return result
+	}
+
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return finish(StatusFailed, fmt.Errorf("failed to resolve path for %s: %w", file, err))
+	}
+
+	result.Path = absFile
+	cfg = s.cfg.ForFile(absFile)
+	log := logger.ForFile(absFile, cfg.Target)
+
+	if strings.EqualFold(s.cfg.Target, config.TargetAuto) {
+		log.Debugf("detected target %s for %s", cfg.Target, filepath.Base(file))
+	}
+
+	if err := s.runHooks(config.HookPreBuild, cfg.Hooks.PreBuild, absFile, cfg, StatusPending, nil); err != nil {
+		return finish(StatusFailed, err)
+	}
+
+	// Check cache (if enabled)
+	if saved, ok := s.restore(ctx, absFile, cfg); ok {
+		result.Saved = saved
+		log.Phase(logger.PhaseCache).Successf("✓ Using cached build for %s", filepath.Base(file))
+
+		if err := s.publish(absFile, cfg); err != nil {
+			return finish(StatusFailed, err)
+		}
+
+		return finish(StatusCached, nil)
+	}
+
+	// Cache miss or disabled - compile
+	log.Phase(logger.PhaseCompile).Infof("Compiling %s...", filepath.Base(file))
+
+	result.Status = StatusCompiling
+	s.notify(result)
+
+	compileStart := time.Now()
+	if err := s.compile(ctx, absFile, cfg); err != nil {
+		log.Phase(logger.PhaseCompile).Debugf("compiling %s failed after %s: %v", filepath.Base(file), time.Since(compileStart).Round(time.Millisecond), err)
+
+		// Store failed build in cache too (so we don't retry immediately),
+		// unless it failed because the build was cancelled
+		if s.cache != nil {
+			_ = s.cache.Store(ctx, absFile, cfg, false)
+		}
+
+		return finish(StatusFailed, err)
+	}
+
+	compileTime := time.Since(compileStart)
+	log.Phase(logger.PhaseCompile).Debugf("compiled %s in %s", filepath.Base(file), compileTime.Round(time.Millisecond))
+
+	// Store successful build in cache
+	if s.cache != nil {
+		if err := s.cache.StoreTimed(ctx, absFile, cfg, true, compileTime); err != nil {
+			log.Phase(logger.PhaseStore).Warnf("Failed to cache build: %v", err)
+		}
+	}
+
+	if err := s.publish(absFile, cfg); err != nil {
+		return finish(StatusFailed, err)
+	}
+
+	return finish(StatusSucceeded, nil)
+}
+
+// RestoreFile restores a single file's artifacts from the cache without ever
+// invoking the compiler. It fails with ErrNotCached on a cache miss
+func (s *Session) RestoreFile(ctx context.Context, file string) Result {
+	start := time.Now()
+	result := Result{File: file, Status: StatusPending}
+
+	finish := func(status Status, err error) Result {
+		result.Status = status
+		result.Err = err
+		result.Duration = time.Since(start)
+		s.notify(result)
+
+		return result
+	}
+
+	if s.cache == nil {
+		return finish(StatusFailed, errors.New("build cache is disabled"))
+	}
+
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return finish(StatusFailed, fmt.Errorf("failed to resolve path for %s: %w", file, err))
+	}
+
+	result.Path = absFile
+	cfg := s.cfg.ForFile(absFile)
+
+	entry, err := s.cache.Get(ctx, absFile, cfg)
+	if err != nil {
+		return finish(StatusFailed, fmt.Errorf("cache lookup failed for %s: %w", file, err))
+	}
+
+	if entry == nil || !entry.Success {
+		return finish(StatusFailed, fmt.Errorf("%w for %s", ErrNotCached, file))
+	}
+
+	if err := s.cache.Restore(ctx, entry, filepath.Dir(absFile)); err != nil {
+		return finish(StatusFailed, fmt.Errorf("failed to restore %s from cache: %w", file, err))
+	}
+
+	result.Saved = entry.CompileTime
+	logger.ForFile(absFile, cfg.Target).Phase(logger.PhaseCache).Successf("✓ Restored %s from cache", filepath.Base(file))
+
+	if err := s.publish(absFile, cfg); err != nil {
+		return finish(StatusFailed, err)
+	}
+
+	return finish(StatusCached, nil)
+}
+
+// publish makes a built file's outputs available: its artifacts are exported
+// to the output directory, installed into the SIMPL Windows user folder and
+// its dependency file written, when enabled
+func (s *Session) publish(absFile string, cfg *config.Config) error {
+	if err := s.export(absFile, cfg); err != nil {
+		return err
+	}
+
+	if err := s.install(absFile, cfg); err != nil {
+		return err
+	}
+
+	return s.writeDepfile(absFile, cfg)
+}
+
+// export copies a built file's artifacts into <OutDir>/<module>/ when an
+// output directory is configured
+func (s *Session) export(absFile string, cfg *config.Config) error {
+	if cfg.OutDir == "" {
+		return nil
+	}
+
+	module := strings.TrimSuffix(filepath.Base(absFile), filepath.Ext(absFile))
+	destDir := filepath.Join(cfg.OutDir, module)
+
+	exported, err := cache.ExportArtifacts(absFile, cfg.Target, destDir)
+	if err != nil {
+		return fmt.Errorf("failed to copy artifacts for %s to %s: %w", filepath.Base(absFile), cfg.OutDir, err)
+	}
+
+	logger.ForFile(absFile, cfg.Target).Phase(logger.PhasePublish).Debugf("exported %d artifact(s) for %s to %s", len(exported), filepath.Base(absFile), destDir)
+
+	return nil
+}
+
+// install copies a built file's .ush and SPlsWork outputs into the SIMPL
+// Windows user SIMPL+ folder, keeping the SPlsWork layout, so SIMPL Windows
+// picks up the new module without copying it by hand
+func (s *Session) install(absFile string, cfg *config.Config) error {
+	if !cfg.Install {
+		return nil
+	}
+
+	outputs, err := cache.CollectOutputs(absFile, cfg.Target)
+	if err != nil {
+		return fmt.Errorf("failed to collect outputs for %s: %w", filepath.Base(absFile), err)
+	}
+
+	if err := cache.CopyArtifacts(filepath.Dir(absFile), cfg.InstallDir, outputs); err != nil {
+		return fmt.Errorf("failed to install %s into %s: %w", filepath.Base(absFile), cfg.InstallDir, err)
+	}
+
+	logger.ForFile(absFile, cfg.Target).Phase(logger.PhasePublish).Debugf("installed %d artifact(s) for %s into %s", len(outputs), filepath.Base(absFile), cfg.InstallDir)
+
+	return nil
+}
+
+// restore attempts to restore a file's artifacts from the cache when caching
+// is enabled and not forced off. A split build always compiles, since the
+// cache doesn't hold the per-series folders. Returns true on a successful
+// cache hit, along with the compile time the hit saved
+func (s *Session) restore(ctx context.Context, absFile string, cfg *config.Config) (time.Duration, bool) {
+	if s.cache == nil || cfg.Force || cfg.SplitWork {
+		return 0, false
+	}
+
+	log := logger.ForFile(absFile, cfg.Target).Phase(logger.PhaseCache)
+
+	entry, err := s.cache.Get(ctx, absFile, cfg)
+	if err != nil {
+		log.Warnf("Cache lookup failed: %v", err)
+		return 0, false
+	}
+
+	if entry == nil || !entry.Success {
+		return 0, false
+	}
+
+	// Cache hit! Restore to source directory
+	if err := s.cache.Restore(ctx, entry, filepath.Dir(absFile)); err != nil {
+		log.Warnf("Failed to restore from cache: %v", err)
+		return 0, false
+	}
+
+	return entry.CompileTime, true
+}
+
+// compileFile invokes the compiler for a single source file, in a sandbox
+// when enabled, or a sandbox per series with parallel series or a split build
+func (s *Session) compileFile(ctx context.Context, sourceFile string, cfg *config.Config) error {
+	if series := parallelSeries(cfg); series != nil {
+		return s.compileSeries(ctx, sourceFile, cfg, series, true)
+	}
+
+	if series := splitSeries(cfg); series != nil {
+		return s.compileSeries(ctx, sourceFile, cfg, series, false)
+	}
+
+	if cfg.Sandbox {
+		return s.compileSandboxed(ctx, sourceFile, cfg)
+	}
+
+	return s.run(ctx, sourceFile, sourceFile, cfg)
+}
+
+// run invokes the compiler on compiled, the source file itself or its copy in
+// a sandbox. On failure, the errors found in the compiler output are reported
+// as diagnostics of sourceFile. Cancelling ctx stops the compiler
+func (s *Session) run(ctx context.Context, sourceFile, compiled string, cfg *config.Config) error {
+	stdout, stderr := s.Stdout, s.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	log := logger.ForFile(sourceFile, cfg.Target).Phase(logger.PhaseCompile)
+
+	var output bytes.Buffer
+	builder := compiler.NewCommandBuilder()
+	builder.SetOutput(io.MultiWriter(stdout, &output), io.MultiWriter(stderr, &output))
+	builder.SetRunner(s.Runner)
+
+	invocations, err := builder.BuildInvocations(cfg, []string{compiled})
+	if err != nil {
+		return err
+	}
+
+	for _, inv := range invocations {
+		// Print build info if verbose mode is enabled
+		if cfg.Verbose {
+			series := utils.ParseTarget(inv.Config.Target)
+			builder.PrintBuildInfo(inv.Config, series, []string{compiled}, inv.Args)
+		}
+
+		// Each run writes its own compile log, added to the build log after
+		name, args := inv.Config.CompilerPath, inv.Args
+
+		runLog := ""
+		if s.outLog != nil {
+			if runLog, err = s.outLog.runLog(); err != nil {
+				return err
+			}
+
+			args = withOutput(args, runLog)
+		}
+
+		// Run the compiler in a container when configured
+		if inv.Config.Runner == config.RunnerDocker {
+			name, args, err = compiler.ContainerCommand(inv.Config, args)
+			if err != nil {
+				if runLog != "" {
+					_ = os.Remove(runLog)
+				}
+
+				return err
+			}
+		}
+
+		log.Debugf("running %s %s", name, strings.Join(args, " "))
+
+		// Execute the compiler command
+		runStart := time.Now()
+		err := builder.ExecuteCommand(ctx, name, args)
+
+		compileLog := ""
+		if runLog != "" {
+			var logErr error
+			if compileLog, logErr = s.outLog.section(sourceFile, utils.ParseTarget(inv.Config.Target), runLog, runStart, err); logErr != nil {
+				log.Warnf("%v", logErr)
+			}
+		}
+
+		if err != nil {
+			diagnostics := diag.Parse(sourceFile, output.String())
+
+			// With /silent the errors are only in the compile log
+			if cfg.Silent && len(diagnostics) == 0 {
+				diagnostics = s.silentErrors(stderr, sourceFile, compileLog)
+			}
+
+			for _, d := range diagnostics {
+				if s.OnDiagnostic != nil {
+					s.OnDiagnostic(d)
+				} else {
+					log.Errorf("%s", d.WithExcerpt())
+				}
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// maxSilentErrors is the most compile log lines shown for a failed silent run
+const maxSilentErrors = 20
+
+// silentErrors prints the error lines of a silent run's compile log to
+// stderr and returns the diagnostics found in it
+func (s *Session) silentErrors(stderr io.Writer, sourceFile, compileLog string) []diag.Diagnostic {
+	lines := diag.ErrorLines(compileLog, maxSilentErrors)
+	if len(lines) == 0 {
+		if s.outLog == nil {
+			logger.ForFile(sourceFile, s.cfg.Target).Phase(logger.PhaseCompile).Warnf("The compiler ran with /silent and no compile log; pass --out to see its errors")
+		}
+
+		return nil
+	}
+
+	fmt.Fprintf(stderr, "%s failed to compile:\n", filepath.Base(sourceFile))
+	for _, line := range lines {
+		fmt.Fprintf(stderr, "  %s\n", line)
+	}
+
+	return diag.Parse(sourceFile, compileLog)
+}
+
+func (s *Session) notify(result Result) {
+	if s.OnStatus != nil {
+		s.OnStatus(result)
+	}
+}
+
+// Failed returns the results that failed
+func Failed(results []Result) []Result {
+	var failed []Result
+	for _, r := range results {
+		if r.Status == StatusFailed {
+			failed = append(failed, r)
+		}
+	}
+
+	return failed
+}
+
+// Record describes a finished build for the cache's build history
+func Record(cfg *config.Config, results []Result, start time.Time, elapsed time.Duration) cache.BuildRecord {
+	record := cache.BuildRecord{
+		Time:     start,
+		Duration: elapsed,
+		Target:   cfg.Target,
+		Project:  cfg.ProjectDir,
+		Files:    make([]cache.FileRecord, 0, len(results)),
+	}
+
+	for _, r := range results {
+		if !r.Status.Done() || r.Path == "" {
+			continue
+		}
+
+		record.Files = append(record.Files, cache.FileRecord{Path: r.Path, Status: r.Status.String(), Duration: r.Duration})
+	}
+
+	return record
+}
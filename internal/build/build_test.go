@@ -0,0 +1,338 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/compiler"
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+// writeSources creates source files in a temp directory and returns their paths
+func writeSources(t *testing.T, names ...string) []string {
+	dir := t.TempDir()
+
+	var files []string
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte("// "+name), 0o644))
+		files = append(files, path)
+	}
+
+	return files
+}
+
+// fakeCompile simulates the compiler by writing a .ush next to each source
+// and failing for the files listed in fail
+func fakeCompile(calls *[]string, fail ...string) func(context.Context, string, *config.Config) error {
+	return func(_ context.Context, sourceFile string, _ *config.Config) error {
+		*calls = append(*calls, filepath.Base(sourceFile))
+
+		for _, f := range fail {
+			if filepath.Base(sourceFile) == f {
+				return errors.New("compile errors")
+			}
+		}
+
+		ush := sourceFile[:len(sourceFile)-len(filepath.Ext(sourceFile))] + ".ush"
+		return os.WriteFile(ush, []byte("header"), 0o644)
+	}
+}
+
+func TestSession_Build_FailFast(t *testing.T) {
+	files := writeSources(t, "a.usp", "b.usp", "c.usp")
+
+	var calls []string
+	s := NewSession(&config.Config{Target: "34"}, nil)
+	s.compile = fakeCompile(&calls, "b.usp")
+
+	results := s.Build(context.Background(), files, false)
+	require.Len(t, results, 2)
+	assert.Equal(t, StatusSucceeded, results[0].Status)
+	assert.Equal(t, StatusFailed, results[1].Status)
+	assert.EqualError(t, results[1].Err, "compile errors")
+	assert.Equal(t, []string{"a.usp", "b.usp"}, calls)
+}
+
+func TestSession_Build_KeepGoing(t *testing.T) {
+	files := writeSources(t, "a.usp", "b.usp", "c.usp")
+
+	var calls []string
+	s := NewSession(&config.Config{Target: "34"}, nil)
+	s.compile = fakeCompile(&calls, "a.usp", "c.usp")
+
+	results := s.Build(context.Background(), files, true)
+	require.Len(t, results, 3)
+	assert.Equal(t, []string{"a.usp", "b.usp", "c.usp"}, calls)
+
+	failed := Failed(results)
+	require.Len(t, failed, 2)
+	assert.Equal(t, files[0], failed[0].File)
+	assert.Equal(t, files[2], failed[1].File)
+}
+
+func TestSession_BuildFile_UsesCache(t *testing.T) {
+	files := writeSources(t, "a.usp")
+
+	c, err := cache.New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	var calls []string
+	s := NewSession(&config.Config{Target: "34"}, c)
+	s.compile = fakeCompile(&calls)
+
+	var statuses []Status
+	s.OnStatus = func(r Result) {
+		statuses = append(statuses, r.Status)
+	}
+
+	first := s.BuildFile(context.Background(), files[0])
+	assert.Equal(t, StatusSucceeded, first.Status)
+	assert.Equal(t, []Status{StatusCompiling, StatusSucceeded}, statuses)
+
+	// Remove the output so the restore is observable
+	ush := filepath.Join(filepath.Dir(files[0]), "a.ush")
+	require.NoError(t, os.Remove(ush))
+
+	statuses = nil
+	second := s.BuildFile(context.Background(), files[0])
+	assert.Equal(t, StatusCached, second.Status)
+	assert.Equal(t, []Status{StatusCached}, statuses)
+	assert.Len(t, calls, 1, "cached build should not invoke the compiler")
+	assert.FileExists(t, ush)
+}
+
+func TestSession_BuildFile_Force(t *testing.T) {
+	files := writeSources(t, "a.usp")
+
+	c, err := cache.New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	var calls []string
+	s := NewSession(&config.Config{Target: "34", Force: true}, c)
+	s.compile = fakeCompile(&calls)
+
+	assert.Equal(t, StatusSucceeded, s.BuildFile(context.Background(), files[0]).Status)
+	assert.Equal(t, StatusSucceeded, s.BuildFile(context.Background(), files[0]).Status)
+	assert.Len(t, calls, 2, "a forced build always compiles")
+
+	// The forced builds were still stored
+	restored := NewSession(&config.Config{Target: "34"}, c)
+	restored.compile = fakeCompile(&calls)
+	assert.Equal(t, StatusCached, restored.BuildFile(context.Background(), files[0]).Status)
+	assert.Len(t, calls, 2)
+}
+
+func TestSession_BuildFile_CacheSaved(t *testing.T) {
+	files := writeSources(t, "a.usp")
+
+	c, err := cache.New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	s := NewSession(&config.Config{Target: "34"}, c)
+	s.compile = func(_ context.Context, sourceFile string, _ *config.Config) error {
+		time.Sleep(20 * time.Millisecond)
+		return os.WriteFile(sourceFile[:len(sourceFile)-len(filepath.Ext(sourceFile))]+".ush", []byte("header"), 0o644)
+	}
+
+	first := s.BuildFile(context.Background(), files[0])
+	require.Equal(t, StatusSucceeded, first.Status)
+	assert.Zero(t, first.Saved)
+
+	second := s.BuildFile(context.Background(), files[0])
+	require.Equal(t, StatusCached, second.Status)
+	assert.GreaterOrEqual(t, second.Saved, 20*time.Millisecond, "a hit saves the recorded compile time")
+}
+
+func TestStatus_String(t *testing.T) {
+	assert.Equal(t, "cached", StatusCached.String())
+	assert.Equal(t, "failed", StatusFailed.String())
+	assert.True(t, StatusSucceeded.Done())
+	assert.False(t, StatusCompiling.Done())
+}
+
+func TestSession_RestoreFile(t *testing.T) {
+	files := writeSources(t, "a.usp", "b.usp")
+
+	c, err := cache.New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	var calls []string
+	s := NewSession(&config.Config{Target: "34"}, c)
+	s.compile = fakeCompile(&calls)
+
+	require.Equal(t, StatusSucceeded, s.BuildFile(context.Background(), files[0]).Status)
+
+	ush := filepath.Join(filepath.Dir(files[0]), "a.ush")
+	require.NoError(t, os.Remove(ush))
+
+	restored := s.RestoreFile(context.Background(), files[0])
+	assert.Equal(t, StatusCached, restored.Status)
+	assert.FileExists(t, ush)
+
+	missed := s.RestoreFile(context.Background(), files[1])
+	assert.Equal(t, StatusFailed, missed.Status)
+	assert.ErrorIs(t, missed.Err, ErrNotCached)
+	assert.Len(t, calls, 1, "restore should never invoke the compiler")
+}
+
+func TestSession_RestoreFile_NoCache(t *testing.T) {
+	files := writeSources(t, "a.usp")
+
+	result := NewSession(&config.Config{Target: "34"}, nil).RestoreFile(context.Background(), files[0])
+	assert.Equal(t, StatusFailed, result.Status)
+	assert.Error(t, result.Err)
+}
+
+func TestSession_BuildFile_OutDir(t *testing.T) {
+	files := writeSources(t, "a.usp")
+	outDir := filepath.Join(t.TempDir(), "dist")
+
+	var calls []string
+	s := NewSession(&config.Config{Target: "34", OutDir: outDir}, nil)
+	s.compile = fakeCompile(&calls)
+
+	result := s.BuildFile(context.Background(), files[0])
+	require.Equal(t, StatusSucceeded, result.Status)
+	assert.FileExists(t, filepath.Join(outDir, "a", "a.ush"))
+}
+
+func TestSession_BuildFile_Install(t *testing.T) {
+	files := writeSources(t, "a.usp")
+	installDir := filepath.Join(t.TempDir(), "Usrsplus")
+
+	c, err := cache.New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	var calls []string
+	s := NewSession(&config.Config{Target: "34", Install: true, InstallDir: installDir}, c)
+	s.compile = fakeCompile(&calls)
+
+	require.Equal(t, StatusSucceeded, s.BuildFile(context.Background(), files[0]).Status)
+	assert.FileExists(t, filepath.Join(installDir, "a.ush"))
+
+	// Restoring installs again
+	require.NoError(t, os.RemoveAll(installDir))
+	require.Equal(t, StatusCached, s.RestoreFile(context.Background(), files[0]).Status)
+	assert.FileExists(t, filepath.Join(installDir, "a.ush"))
+}
+
+func TestSession_BuildFile_Overrides(t *testing.T) {
+	files := writeSources(t, "a.usp", "legacy_b.usp")
+
+	targets := map[string]string{}
+	s := NewSession(&config.Config{
+		Target:     "34",
+		ProjectDir: filepath.Dir(files[0]),
+		Overrides:  []config.Override{{Pattern: "legacy_*.usp", Target: "2"}},
+	}, nil)
+	s.compile = func(_ context.Context, sourceFile string, cfg *config.Config) error {
+		targets[filepath.Base(sourceFile)] = cfg.Target
+		return nil
+	}
+
+	s.Build(context.Background(), files, false)
+	assert.Equal(t, map[string]string{"a.usp": "34", "legacy_b.usp": "2"}, targets)
+}
+
+func TestRecord(t *testing.T) {
+	start := time.Now()
+	cfg := &config.Config{Target: "34", ProjectDir: "/src"}
+	results := []Result{
+		{File: "a.usp", Path: "/src/a.usp", Status: StatusCached, Duration: time.Millisecond},
+		{File: "b.usp", Path: "/src/b.usp", Status: StatusFailed, Duration: time.Second},
+		{File: "c.usp", Status: StatusFailed},
+	}
+
+	record := Record(cfg, results, start, 2*time.Second)
+	assert.Equal(t, start, record.Time)
+	assert.Equal(t, 2*time.Second, record.Duration)
+	assert.Equal(t, "/src", record.Project)
+	assert.Equal(t, []cache.FileRecord{
+		{Path: "/src/a.usp", Status: "cached", Duration: time.Millisecond},
+		{Path: "/src/b.usp", Status: "failed", Duration: time.Second},
+	}, record.Files)
+}
+
+func TestSession_Build_Runner(t *testing.T) {
+	files := writeSources(t, "a.usp", "b.usp")
+
+	runner := &compiler.FakeRunner{Respond: func(call compiler.Call, stdout, _ io.Writer) (int, error) {
+		if filepath.Base(call.Args[len(call.Args)-1]) == "b.usp" {
+			fmt.Fprintln(stdout, "Error 1300 (Line 3) - Invalid constant")
+			return 106, nil
+		}
+
+		return 0, nil
+	}}
+
+	s := NewSession(&config.Config{Target: "3", CompilerPath: "SPlusCC.exe"}, nil)
+	s.Stdout, s.Stderr = io.Discard, io.Discard
+	s.Runner = runner
+
+	var diagnostics []diag.Diagnostic
+	s.OnDiagnostic = func(d diag.Diagnostic) {
+		diagnostics = append(diagnostics, d)
+	}
+
+	results := s.Build(context.Background(), files, true)
+	require.Len(t, results, 2)
+	assert.Equal(t, StatusSucceeded, results[0].Status)
+	assert.Equal(t, StatusFailed, results[1].Status)
+
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, 3, diagnostics[0].Line)
+	assert.Equal(t, "1300", diagnostics[0].Code)
+
+	calls := runner.Calls()
+	require.Len(t, calls, 2)
+	assert.Equal(t, "SPlusCC.exe", calls[0].Name)
+	assert.Equal(t, "series3", calls[0].Arg("/target"))
+}
+
+func TestSession_Build_Cancel(t *testing.T) {
+	files := writeSources(t, "a.usp", "b.usp")
+
+	c, err := cache.New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	// The build is cancelled while the first file compiles
+	ctx, cancel := context.WithCancel(context.Background())
+	runner := &compiler.FakeRunner{Respond: func(compiler.Call, io.Writer, io.Writer) (int, error) {
+		cancel()
+		return 1, nil
+	}}
+
+	cfg := &config.Config{Target: "3", CompilerPath: "SPlusCC.exe"}
+	s := NewSession(cfg, c)
+	s.Stdout, s.Stderr = io.Discard, io.Discard
+	s.Runner = runner
+
+	results := s.Build(ctx, files, true)
+	require.Len(t, results, 1)
+	assert.Equal(t, StatusFailed, results[0].Status)
+	assert.ErrorIs(t, results[0].Err, context.Canceled)
+	assert.Len(t, runner.Calls(), 1)
+
+	// The interrupted compile isn't cached as a failure
+	entry, err := c.Get(context.Background(), files[0], cfg)
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
@@ -0,0 +1,109 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// buildLog is the compile log (--out) of a session. The compiler overwrites
+// its /out file on every run, so each run writes its own temporary log,
+// which is appended to the build log as a section headed with the file, the
+// series and when it ran. The build log is truncated by the first section
+// of the session
+type buildLog struct {
+	path string
+
+	mu      sync.Mutex
+	started bool
+}
+
+// newBuildLog returns the build log written to path, or nil when empty
+func newBuildLog(path string) *buildLog {
+	if path == "" {
+		return nil
+	}
+
+	return &buildLog{path: path}
+}
+
+// runLog creates the temporary log of one compiler run. It is created next
+// to the build log, so a compiler in a container sees it too
+func (l *buildLog) runLog() (string, error) {
+	dir := filepath.Dir(l.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(dir, ".spc-out-*.log")
+	if err != nil {
+		return "", fmt.Errorf("failed to create compile log: %w", err)
+	}
+
+	return f.Name(), f.Close()
+}
+
+// section appends the log of a compiler run on sourceFile to the build log
+// and removes it. Returns the run's log
+func (l *buildLog) section(sourceFile string, series []string, runLog string, start time.Time, runErr error) (string, error) {
+	defer os.Remove(runLog)
+
+	data, err := os.ReadFile(runLog)
+	if err != nil {
+		return "", fmt.Errorf("failed to read compile log: %w", err)
+	}
+
+	result := "succeeded"
+	if runErr != nil {
+		result = "failed: " + runErr.Error()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "==> %s (%s) started %s\n", sourceFile, strings.Join(series, " "), start.Format(time.RFC3339))
+
+	if text := strings.TrimRight(string(data), "\r\n"); text != "" {
+		b.WriteString(text)
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "<== %s %s in %s\n\n", filepath.Base(sourceFile), result, time.Since(start).Round(time.Millisecond))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !l.started {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(l.path, flags, 0o644)
+	if err != nil {
+		return string(data), fmt.Errorf("failed to open build log: %w", err)
+	}
+
+	l.started = true
+
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return string(data), fmt.Errorf("failed to write build log: %w", err)
+	}
+
+	return string(data), f.Close()
+}
+
+// withOutput returns compiler arguments with the /out file replaced
+func withOutput(args []string, path string) []string {
+	replaced := make([]string, len(args))
+	copy(replaced, args)
+
+	for i := 0; i < len(replaced)-1; i++ {
+		if strings.EqualFold(replaced[i], "/out") {
+			replaced[i+1] = path
+		}
+	}
+
+	return replaced
+}
@@ -0,0 +1,113 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+// logCompiler writes a fake compiler that overwrites its /out file with a
+// line naming the source, printing nothing. Sources containing "fail" fail
+// with a compile error
+func logCompiler(t *testing.T) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the compiler")
+	}
+
+	compiler := filepath.Join(t.TempDir(), "SPlusCC")
+	writeFile(t, compiler, `#!/bin/sh
+while [ $# -gt 0 ]; do
+	case "$1" in
+	/out) out=$2; shift ;;
+	*.usp) src=$1 ;;
+	esac
+	shift
+done
+echo "Compiling $(basename "$src")" > "$out"
+if grep -q fail "$src"; then
+	echo "Error 1001 (Line 3) - Undefined variable" >> "$out"
+	echo "Compile failed" >> "$out"
+	exit 1
+fi
+`)
+	require.NoError(t, os.Chmod(compiler, 0o755))
+
+	return compiler
+}
+
+func TestSession_Build_OutputFile(t *testing.T) {
+	compiler := logCompiler(t)
+
+	files := writeSources(t, "first.usp", "second.usp")
+	writeFile(t, files[1], "// fail")
+
+	logDir := t.TempDir()
+	out := filepath.Join(logDir, "build.log")
+	writeFile(t, out, "previous build\n")
+
+	s := NewSession(&config.Config{CompilerPath: compiler, Target: "34", OutputFile: out}, nil)
+	s.Stdout, s.Stderr = io.Discard, io.Discard
+
+	results := s.Build(context.Background(), files, true)
+	require.Len(t, results, 2)
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+
+	log := string(data)
+	assert.NotContains(t, log, "previous build")
+	assert.Contains(t, log, "==> "+files[0]+" (series3 series4) started ")
+	assert.Contains(t, log, "Compiling first.usp\n<== first.usp succeeded in ")
+	assert.Contains(t, log, "==> "+files[1]+" (series3 series4) started ")
+	assert.Contains(t, log, "Compile failed\n<== second.usp failed: ")
+	assert.Less(t, strings.Index(log, "first.usp"), strings.Index(log, "second.usp"))
+
+	entries, err := os.ReadDir(logDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "run logs are removed")
+}
+
+func TestSession_BuildFile_SilentErrors(t *testing.T) {
+	compiler := logCompiler(t)
+
+	source := writeSources(t, "main.usp")[0]
+	writeFile(t, source, "// fail")
+
+	var stdout, stderr bytes.Buffer
+	var diagnostics []diag.Diagnostic
+
+	cfg := &config.Config{CompilerPath: compiler, Target: "3", Silent: true, OutputFile: filepath.Join(t.TempDir(), "build.log")}
+	s := NewSession(cfg, nil)
+	s.Stdout, s.Stderr = &stdout, &stderr
+	s.OnDiagnostic = func(d diag.Diagnostic) { diagnostics = append(diagnostics, d) }
+
+	result := s.BuildFile(context.Background(), source)
+	require.Error(t, result.Err)
+
+	assert.Empty(t, stdout.String())
+	assert.Equal(t, "main.usp failed to compile:\n  Error 1001 (Line 3) - Undefined variable\n", stderr.String())
+
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, 3, diagnostics[0].Line)
+	assert.Equal(t, "Undefined variable", diagnostics[0].Message)
+}
+
+func TestWithOutput(t *testing.T) {
+	args := []string{"/target", "series3", "/rebuild", "main.usp", "/out", "build.log"}
+
+	assert.Equal(t, []string{"/target", "series3", "/rebuild", "main.usp", "/out", "run.log"}, withOutput(args, "run.log"))
+	assert.Equal(t, "build.log", args[5])
+}
@@ -0,0 +1,48 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// CheckFile compiles a copy of a file in a sandbox to find its compile
+// errors, reported through OnDiagnostic. Nothing is restored from or stored
+// in the cache, no hooks run and the artifacts are thrown away with the
+// sandbox, so the file's SPlsWork is left as it was
+func (s *Session) CheckFile(ctx context.Context, file string) Result {
+	start := time.Now()
+	result := Result{File: file, Status: StatusCompiling}
+
+	finish := func(status Status, err error) Result {
+		result.Status = status
+		result.Err = err
+		result.Duration = time.Since(start)
+		s.notify(result)
+
+		return result
+	}
+
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return finish(StatusFailed, fmt.Errorf("failed to resolve path for %s: %w", file, err))
+	}
+
+	result.Path = absFile
+	cfg := s.cfg.ForFile(absFile)
+	s.notify(result)
+
+	sb, err := newSandbox(absFile, cfg)
+	if err != nil {
+		return finish(StatusFailed, err)
+	}
+
+	defer sb.Close()
+
+	if err := s.run(ctx, absFile, sb.source, cfg); err != nil {
+		return finish(StatusFailed, err)
+	}
+
+	return finish(StatusSucceeded, nil)
+}
@@ -0,0 +1,61 @@
+package build
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+func TestSession_CheckFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the compiler")
+	}
+
+	// The fake compiler writes the module's outputs and fails with an error
+	bin := t.TempDir()
+	compiler := filepath.Join(bin, "SPlusCC")
+	writeFile(t, compiler, `#!/bin/sh
+for arg; do
+	case "$arg" in
+	*.usp)
+		dir=$(dirname "$arg")
+		mkdir -p "$dir/SPlsWork"
+		echo header > "${arg%.usp}.ush"
+		echo module > "$dir/SPlsWork/$(basename "${arg%.usp}").dll"
+		;;
+	esac
+done
+echo "Error 1300 (Line 2) - Invalid constant"
+exit 1
+`)
+	require.NoError(t, os.Chmod(compiler, 0o755))
+
+	source := writeSources(t, "main.usp")[0]
+	dir := filepath.Dir(source)
+
+	var diagnostics []diag.Diagnostic
+
+	s := NewSession(&config.Config{CompilerPath: compiler, Target: "34"}, nil)
+	s.Stdout, s.Stderr = io.Discard, io.Discard
+	s.OnDiagnostic = func(d diag.Diagnostic) { diagnostics = append(diagnostics, d) }
+
+	result := s.CheckFile(context.Background(), source)
+	assert.Equal(t, StatusFailed, result.Status)
+	require.Error(t, result.Err)
+
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, source, diagnostics[0].File)
+	assert.Equal(t, 2, diagnostics[0].Line)
+
+	assert.NoFileExists(t, filepath.Join(dir, "main.ush"))
+	assert.NoDirExists(t, filepath.Join(dir, "SPlsWork"))
+}
@@ -0,0 +1,105 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/deps"
+	"github.com/Norgate-AV/spc/internal/logger"
+)
+
+// DepfilePath returns where the dependency file for a source is written:
+// <module>.d next to the source (and its .ush)
+func DepfilePath(sourceFile string) string {
+	return strings.TrimSuffix(sourceFile, filepath.Ext(sourceFile)) + ".d"
+}
+
+// writeDepfile writes a make-style dependency file for a built source when
+// depfiles are enabled. The rule's targets are the source's outputs and its
+// prerequisites are the source and every library it uses, so make (or an
+// MSBuild target reading the file) knows when spc needs to run again
+func (s *Session) writeDepfile(absFile string, cfg *config.Config) error {
+	if !cfg.Depfile {
+		return nil
+	}
+
+	outputs, err := cache.CollectOutputs(absFile, cfg.Target)
+	if err != nil {
+		return fmt.Errorf("failed to collect outputs for %s: %w", filepath.Base(absFile), err)
+	}
+
+	targets := make([]string, 0, len(outputs))
+	for _, out := range outputs {
+		targets = append(targets, filepath.Join(filepath.Dir(absFile), out))
+	}
+
+	if len(targets) == 0 {
+		targets = append(targets, strings.TrimSuffix(absFile, filepath.Ext(absFile))+".ush")
+	}
+
+	g, err := deps.BuildGraph([]string{absFile}, &deps.Resolver{UserFolders: cfg.UserFolders}, "")
+	if err != nil {
+		return fmt.Errorf("failed to scan dependencies of %s: %w", filepath.Base(absFile), err)
+	}
+
+	var inputs []string
+	for _, n := range g.TransitiveDependencies(absFile) {
+		if n.Path != "" && !n.Missing {
+			inputs = append(inputs, n.Path)
+		}
+	}
+
+	path := DepfilePath(absFile)
+	if err := os.WriteFile(path, []byte(formatDepfile(targets, absFile, inputs)), 0o644); err != nil {
+		return fmt.Errorf("failed to write dependency file: %w", err)
+	}
+
+	logger.ForFile(absFile, cfg.Target).Phase(logger.PhasePublish).Debugf("wrote %s", path)
+
+	return nil
+}
+
+// formatDepfile renders a make rule "targets: source inputs..." followed by an
+// empty rule for each input, so deleting a library does not break make
+func formatDepfile(targets []string, source string, inputs []string) string {
+	var b strings.Builder
+
+	for i, t := range targets {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+
+		b.WriteString(escapeMake(t))
+	}
+
+	b.WriteString(": ")
+	b.WriteString(escapeMake(source))
+
+	for _, in := range inputs {
+		b.WriteString(" \\\n  ")
+		b.WriteString(escapeMake(in))
+	}
+
+	b.WriteByte('\n')
+
+	for _, in := range inputs {
+		fmt.Fprintf(&b, "\n%s:\n", escapeMake(in))
+	}
+
+	return b.String()
+}
+
+// escapeMake escapes a path for use in a make rule. Paths use forward slashes,
+// which both make and MSBuild accept on Windows
+func escapeMake(path string) string {
+	path = filepath.ToSlash(path)
+	path = strings.ReplaceAll(path, "$", "$$")
+	path = strings.ReplaceAll(path, "#", `\#`)
+	path = strings.ReplaceAll(path, " ", `\ `)
+
+	return path
+}
@@ -0,0 +1,61 @@
+package build
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+func TestFormatDepfile(t *testing.T) {
+	got := formatDepfile(
+		[]string{"/p/a.ush", "/p/SPlsWork/a.dll"},
+		"/p/a.usp",
+		[]string{"/lib dir/b.usl", "/p/#c$.clz"},
+	)
+
+	want := `/p/a.ush /p/SPlsWork/a.dll: /p/a.usp \
+  /lib\ dir/b.usl \
+  /p/\#c$$.clz
+
+/lib\ dir/b.usl:
+
+/p/\#c$$.clz:
+`
+	assert.Equal(t, want, got)
+	assert.Equal(t, "/p/a.ush: /p/a.usp\n", formatDepfile([]string{"/p/a.ush"}, "/p/a.usp", nil))
+}
+
+func TestSession_BuildFile_Depfile(t *testing.T) {
+	files := writeSources(t, "a.usp", "b.usl")
+	require.NoError(t, os.WriteFile(files[0], []byte(`#USER_LIBRARY "b"`), 0o644))
+
+	var calls []string
+	s := NewSession(&config.Config{Target: "34", Depfile: true}, nil)
+	s.compile = fakeCompile(&calls)
+
+	result := s.BuildFile(context.Background(), files[0])
+	require.Equal(t, StatusSucceeded, result.Status)
+
+	data, err := os.ReadFile(DepfilePath(files[0]))
+	require.NoError(t, err)
+
+	dir := filepath.ToSlash(filepath.Dir(files[0]))
+	assert.Equal(t, dir+"/a.ush: "+dir+"/a.usp \\\n  "+dir+"/b.usl\n\n"+dir+"/b.usl:\n", string(data))
+}
+
+func TestSession_BuildFile_NoDepfile(t *testing.T) {
+	files := writeSources(t, "a.usp")
+
+	var calls []string
+	s := NewSession(&config.Config{Target: "34"}, nil)
+	s.compile = fakeCompile(&calls)
+
+	require.Equal(t, StatusSucceeded, s.BuildFile(context.Background(), files[0]).Status)
+	assert.NoFileExists(t, DepfilePath(files[0]))
+}
@@ -0,0 +1,108 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/logger"
+)
+
+// runHooks runs the commands of a hook event for a file, in order, stopping
+// at the first that fails. They run in the project directory with the
+// session's output and these environment variables:
+//
+//	SPC_HOOK         pre_build, post_build or on_failure
+//	SPC_FILE         absolute path of the source file
+//	SPC_MODULE       source file name without its extension
+//	SPC_HOOK_TARGET  target series of the file (e.g. 34)
+//	SPC_STATUS       pending, success, cached or failed
+//	SPC_ERROR        why the file failed (on_failure)
+//	SPC_ARTIFACTS    the file's .ush and SPlsWork outputs, separated by the
+//	                 platform's path list separator (post_build)
+//
+// The target isn't exported as SPC_TARGET, which would override the target
+// of any spc the hook runs
+func (s *Session) runHooks(event string, commands []string, absFile string, cfg *config.Config, status Status, buildErr error) error {
+	if len(commands) == 0 {
+		return nil
+	}
+
+	env := append(os.Environ(),
+		"SPC_HOOK="+event,
+		"SPC_FILE="+absFile,
+		"SPC_MODULE="+strings.TrimSuffix(filepath.Base(absFile), filepath.Ext(absFile)),
+		"SPC_HOOK_TARGET="+cfg.Target,
+		"SPC_STATUS="+status.String(),
+	)
+
+	if buildErr != nil {
+		env = append(env, "SPC_ERROR="+buildErr.Error())
+	}
+
+	if event == config.HookPostBuild {
+		env = append(env, "SPC_ARTIFACTS="+strings.Join(artifactPaths(absFile, cfg), string(os.PathListSeparator)))
+	}
+
+	dir := cfg.ProjectDir
+	if dir == "" {
+		dir = filepath.Dir(absFile)
+	}
+
+	stdout, stderr := s.Stdout, s.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	log := logger.ForFile(absFile, cfg.Target).Phase(logger.PhasePublish)
+
+	for _, command := range commands {
+		log.Debugf("running %s hook: %s", event, command)
+
+		if err := hookCommand(command, dir, env, stdout, stderr).Run(); err != nil {
+			return fmt.Errorf("%s hook %q failed: %w", event, command, err)
+		}
+	}
+
+	return nil
+}
+
+// hookCommand returns a hook command line run with the platform's shell
+func hookCommand(command, dir string, env []string, stdout, stderr io.Writer) *exec.Cmd {
+	cmd := exec.Command("sh", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	}
+
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	return cmd
+}
+
+// artifactPaths returns the absolute paths of a built file's outputs
+func artifactPaths(absFile string, cfg *config.Config) []string {
+	outputs, err := cache.CollectOutputs(absFile, cfg.Target)
+	if err != nil {
+		return nil
+	}
+
+	paths := make([]string, 0, len(outputs))
+	for _, output := range outputs {
+		paths = append(paths, filepath.Join(filepath.Dir(absFile), output))
+	}
+
+	return paths
+}
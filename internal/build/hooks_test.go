@@ -0,0 +1,148 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// hookLog returns a hook command appending a line of SPC_* variables to a
+// log, and a function reading the lines logged
+func hookLog(t *testing.T) (string, func() []string) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh hook commands")
+	}
+
+	log := filepath.Join(t.TempDir(), "hooks.log")
+	command := `echo "$SPC_HOOK $SPC_MODULE $SPC_HOOK_TARGET $SPC_STATUS [$SPC_ERROR] [$(basename "$SPC_ARTIFACTS")]" >> ` + log
+
+	return command, func() []string {
+		data, err := os.ReadFile(log)
+		require.NoError(t, err)
+
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+func TestSession_Build_Hooks(t *testing.T) {
+	command, logged := hookLog(t)
+	files := writeSources(t, "a.usp", "b.usp")
+
+	cfg := &config.Config{Target: "34", Hooks: config.HooksConfig{
+		PreBuild:  []string{command},
+		PostBuild: []string{command},
+		OnFailure: []string{command},
+	}}
+
+	var calls []string
+	s := NewSession(cfg, nil)
+	s.Stdout, s.Stderr = io.Discard, io.Discard
+	s.compile = fakeCompile(&calls, "b.usp")
+
+	results := s.Build(context.Background(), files, true)
+	require.Len(t, results, 2)
+
+	assert.Equal(t, []string{
+		"pre_build a 34 pending [] []",
+		"post_build a 34 success [] [a.ush]",
+		"pre_build b 34 pending [] []",
+		"on_failure b 34 failed [compile errors] []",
+	}, logged())
+}
+
+func TestSession_BuildFile_HookFailures(t *testing.T) {
+	command, logged := hookLog(t)
+	source := writeSources(t, "a.usp")[0]
+
+	var calls []string
+	s := NewSession(&config.Config{Target: "3", Hooks: config.HooksConfig{
+		PreBuild:  []string{"exit 3"},
+		OnFailure: []string{command},
+	}}, nil)
+	s.Stdout, s.Stderr = io.Discard, io.Discard
+	s.compile = fakeCompile(&calls)
+
+	result := s.BuildFile(context.Background(), source)
+	assert.Equal(t, StatusFailed, result.Status)
+	assert.ErrorContains(t, result.Err, `pre_build hook "exit 3" failed`)
+	assert.Empty(t, calls, "a failing pre_build hook stops the build")
+
+	s = NewSession(&config.Config{Target: "3", Hooks: config.HooksConfig{
+		PostBuild: []string{"exit 1"},
+		OnFailure: []string{command},
+	}}, nil)
+	s.Stdout, s.Stderr = io.Discard, io.Discard
+	s.compile = fakeCompile(&calls)
+
+	result = s.BuildFile(context.Background(), source)
+	assert.Equal(t, StatusFailed, result.Status)
+	assert.ErrorContains(t, result.Err, `post_build hook "exit 1" failed`)
+
+	lines := logged()
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], `on_failure a 3 failed [pre_build hook "exit 3" failed`))
+	assert.True(t, strings.HasPrefix(lines[1], `on_failure a 3 failed [post_build hook "exit 1" failed`))
+}
+
+// nestedEnv is set for the test binary when a hook runs it as a nested spc,
+// to the project directory it loads the config of
+const nestedEnv = "SPC_TEST_NESTED_PROJECT"
+
+// TestNestedInvocation is the nested spc run by TestSession_Build_HooksNestedInvocation,
+// printing the target it would build for
+func TestNestedInvocation(t *testing.T) {
+	dir := os.Getenv(nestedEnv)
+	if dir == "" {
+		t.Skip("only run from a hook")
+	}
+
+	cfg, err := config.NewLoader().LoadForBuild(&cobra.Command{}, []string{dir})
+	require.NoError(t, err)
+
+	fmt.Printf("nested target %s\n", cfg.Target)
+}
+
+func TestSession_Build_HooksNestedInvocation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh hook commands")
+	}
+
+	t.Setenv("APPDATA", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(config.TargetEnv, "")
+
+	source := writeSources(t, "a.usp")[0]
+	dir := filepath.Dir(source)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".spc.yml"), []byte(`target: "34"`), 0o644))
+
+	// A hook for a file built for another target runs spc in the project
+	command := fmt.Sprintf("%s=%s %s -test.run '^TestNestedInvocation$' -test.v",
+		nestedEnv, dir, os.Args[0])
+
+	var out strings.Builder
+	var calls []string
+	s := NewSession(&config.Config{Target: "4", ProjectDir: dir, Hooks: config.HooksConfig{
+		PreBuild: []string{command},
+	}}, nil)
+	s.Stdout, s.Stderr = &out, io.Discard
+	s.compile = fakeCompile(&calls)
+
+	result := s.BuildFile(context.Background(), source)
+	require.NoError(t, result.Err)
+
+	assert.Contains(t, out.String(), "nested target 34", "the hook's target doesn't leak into the nested spc")
+}
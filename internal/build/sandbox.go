@@ -0,0 +1,198 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/deps"
+	"github.com/Norgate-AV/spc/internal/logger"
+)
+
+// sandbox is a temporary copy of a source file and the user libraries it
+// needs, laid out as they are in the project so relative #INCLUDEPATH
+// directives still resolve
+type sandbox struct {
+	// dir is the temporary directory holding the copy
+	dir string
+
+	// source is the path of the copied source file
+	source string
+}
+
+// newSandbox copies a source file and the libraries it uses into a new
+// temporary directory. Libraries in the user SIMPL+ folders are left where
+// they are, since the compiler is given those folders by absolute path.
+// Under the docker runner the sandbox is created in the mounted project
+// directory, so the container can see it
+func newSandbox(sourceFile string, cfg *config.Config) (*sandbox, error) {
+	g, err := deps.BuildGraph([]string{sourceFile}, &deps.Resolver{UserFolders: cfg.UserFolders}, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan dependencies of %s: %w", filepath.Base(sourceFile), err)
+	}
+
+	files := []string{sourceFile}
+	for _, n := range g.TransitiveDependencies(sourceFile) {
+		if n.Path != "" && !n.Missing && !inAny(n.Path, cfg.UserFolders) {
+			files = append(files, n.Path)
+		}
+	}
+
+	root, inputs := commonRoot(files)
+
+	parent := ""
+	if cfg.Runner == config.RunnerDocker {
+		if parent, err = projectRoot(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	dir, err := os.MkdirTemp(parent, ".spc-sandbox-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sandbox: %w", err)
+	}
+
+	if err := cache.CopyArtifacts(root, dir, inputs); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to copy %s into sandbox: %w", filepath.Base(sourceFile), err)
+	}
+
+	return &sandbox{dir: dir, source: filepath.Join(dir, inputs[0])}, nil
+}
+
+// harvest copies the module's artifacts, and the shared files the compiler
+// wrote to SPlsWork, from the sandbox into the source file's directory
+func (sb *sandbox) harvest(sourceFile string, cfg *config.Config) error {
+	outputs, err := cache.CollectOutputs(sb.source, cfg.Target)
+	if err != nil {
+		return err
+	}
+
+	shared, err := cache.CollectSharedFiles(filepath.Dir(sb.source))
+	if err != nil {
+		return err
+	}
+
+	outputs = append(outputs, shared...)
+
+	if err := cache.CopyArtifacts(filepath.Dir(sb.source), filepath.Dir(sourceFile), outputs); err != nil {
+		return fmt.Errorf("failed to copy artifacts of %s out of sandbox: %w", filepath.Base(sourceFile), err)
+	}
+
+	logger.ForFile(sourceFile, cfg.Target).Phase(logger.PhaseCompile).Debugf("harvested %d file(s) from %s", len(outputs), sb.dir)
+
+	return nil
+}
+
+// harvestSeries copies the SPlsWork outputs of a single series run, and the
+// shared files beside them, from the sandbox into the series' own
+// SPlsWork_S<n> directory next to the source file
+func (sb *sandbox) harvestSeries(sourceFile string, cfg *config.Config) error {
+	outputs, err := cache.CollectOutputs(sb.source, cfg.Target)
+	if err != nil {
+		return err
+	}
+
+	shared, err := cache.CollectSharedFiles(filepath.Dir(sb.source))
+	if err != nil {
+		return err
+	}
+
+	var work []string
+	for _, output := range append(outputs, shared...) {
+		if name, ok := strings.CutPrefix(output, "SPlsWork"+string(filepath.Separator)); ok {
+			work = append(work, name)
+		}
+	}
+
+	workDir := cache.SeriesWorkDir(cfg.Series[0])
+	if err := cache.CopyArtifacts(filepath.Join(filepath.Dir(sb.source), "SPlsWork"), filepath.Join(filepath.Dir(sourceFile), workDir), work); err != nil {
+		return fmt.Errorf("failed to copy artifacts of %s into %s: %w", filepath.Base(sourceFile), workDir, err)
+	}
+
+	logger.ForFile(sourceFile, cfg.Target).Phase(logger.PhaseCompile).Debugf("kept %d file(s) in %s", len(work), workDir)
+
+	return nil
+}
+
+// Close removes the sandbox
+func (sb *sandbox) Close() error {
+	return os.RemoveAll(sb.dir)
+}
+
+// compileSandboxed compiles a copy of a source file in its own sandbox and,
+// if it succeeds, copies the artifacts back next to the real source
+func (s *Session) compileSandboxed(ctx context.Context, sourceFile string, cfg *config.Config) error {
+	sb, err := newSandbox(sourceFile, cfg)
+	if err != nil {
+		return err
+	}
+
+	defer sb.Close()
+
+	if err := s.run(ctx, sourceFile, sb.source, cfg); err != nil {
+		return err
+	}
+
+	return sb.harvest(sourceFile, cfg)
+}
+
+// commonRoot returns the deepest directory containing every file, with the
+// files' paths relative to it. Files that share no directory with the first
+// (another drive on Windows) are dropped; they are only reachable by
+// absolute path, which still works from the sandbox
+func commonRoot(files []string) (string, []string) {
+	root := filepath.Dir(files[0])
+
+	var kept []string
+	for _, f := range files {
+		r := root
+		for !within(r, f) && filepath.Dir(r) != r {
+			r = filepath.Dir(r)
+		}
+
+		if within(r, f) {
+			root = r
+			kept = append(kept, f)
+		}
+	}
+
+	rel := make([]string, 0, len(kept))
+	for _, f := range kept {
+		p, _ := filepath.Rel(root, f)
+		rel = append(rel, p)
+	}
+
+	return root, rel
+}
+
+// within reports whether path is inside dir
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// inAny reports whether path is inside any of the directories
+func inAny(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if dir != "" && within(dir, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// projectRoot returns the project directory, or the working directory when
+// there is no project config
+func projectRoot(cfg *config.Config) (string, error) {
+	if cfg.ProjectDir != "" {
+		return cfg.ProjectDir, nil
+	}
+
+	return os.Getwd()
+}
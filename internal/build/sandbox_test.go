@@ -0,0 +1,112 @@
+package build
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestNewSandbox(t *testing.T) {
+	project := t.TempDir()
+	userFolder := t.TempDir()
+
+	source := filepath.Join(project, "src", "main.usp")
+	writeFile(t, source, "#INCLUDEPATH \"../shared\"\n#USER_LIBRARY \"helpers\"\n#USER_LIBRARY \"global\"\n")
+	writeFile(t, filepath.Join(project, "shared", "helpers.usl"), "#USER_LIBRARY \"strings\"\n")
+	writeFile(t, filepath.Join(project, "shared", "strings.usl"), "")
+	writeFile(t, filepath.Join(userFolder, "global.usl"), "")
+
+	// Leftovers in the real SPlsWork stay out of the sandbox
+	writeFile(t, filepath.Join(project, "src", "SPlsWork", "other.dll"), "stale")
+
+	sb, err := newSandbox(source, &config.Config{UserFolders: []string{userFolder}})
+	require.NoError(t, err)
+
+	assert.Equal(t, filepath.Join(sb.dir, "src", "main.usp"), sb.source)
+	assert.FileExists(t, sb.source)
+	assert.FileExists(t, filepath.Join(sb.dir, "shared", "helpers.usl"))
+	assert.FileExists(t, filepath.Join(sb.dir, "shared", "strings.usl"))
+	assert.NoFileExists(t, filepath.Join(sb.dir, "global.usl"))
+	assert.NoDirExists(t, filepath.Join(sb.dir, "src", "SPlsWork"))
+
+	require.NoError(t, sb.Close())
+	assert.NoDirExists(t, sb.dir)
+}
+
+func TestSession_BuildFile_Sandbox(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the compiler")
+	}
+
+	// The fake compiler records where it ran and writes the module's outputs
+	// next to the file it was given
+	bin := t.TempDir()
+	compiler := filepath.Join(bin, "SPlusCC")
+	writeFile(t, compiler, `#!/bin/sh
+for arg; do
+	case "$arg" in
+	*.usp)
+		dir=$(dirname "$arg")
+		echo "$dir" > "`+filepath.Join(bin, "ran-in")+`"
+		mkdir -p "$dir/SPlsWork"
+		echo header > "${arg%.usp}.ush"
+		echo module > "$dir/SPlsWork/$(basename "${arg%.usp}").dll"
+		echo shared > "$dir/SPlsWork/Version.ini"
+		;;
+	esac
+done
+`)
+	require.NoError(t, os.Chmod(compiler, 0o755))
+
+	source := writeSources(t, "main.usp")[0]
+	dir := filepath.Dir(source)
+
+	s := NewSession(&config.Config{CompilerPath: compiler, Target: "34", Sandbox: true}, nil)
+	s.Stdout, s.Stderr = io.Discard, io.Discard
+
+	result := s.BuildFile(context.Background(), source)
+	require.NoError(t, result.Err)
+
+	ranIn, err := os.ReadFile(filepath.Join(bin, "ran-in"))
+	require.NoError(t, err)
+
+	sandboxDir := strings.TrimSpace(string(ranIn))
+	assert.NotEqual(t, dir, sandboxDir)
+	assert.NoDirExists(t, sandboxDir, "the sandbox is removed")
+
+	assert.FileExists(t, filepath.Join(dir, "main.ush"))
+	assert.FileExists(t, filepath.Join(dir, "SPlsWork", "main.dll"))
+	assert.FileExists(t, filepath.Join(dir, "SPlsWork", "Version.ini"))
+}
+
+func TestCommonRoot(t *testing.T) {
+	base := filepath.Join(string(filepath.Separator), "work")
+
+	root, rel := commonRoot([]string{
+		filepath.Join(base, "project", "src", "main.usp"),
+		filepath.Join(base, "project", "src", "a.usl"),
+		filepath.Join(base, "shared", "b.usl"),
+	})
+
+	assert.Equal(t, base, root)
+	assert.Equal(t, []string{
+		filepath.Join("project", "src", "main.usp"),
+		filepath.Join("project", "src", "a.usl"),
+		filepath.Join("shared", "b.usl"),
+	}, rel)
+}
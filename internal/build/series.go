@@ -0,0 +1,162 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/diag"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/Norgate-AV/spc/internal/utils"
+)
+
+// seriesRun is the compile of one series of a file
+type seriesRun struct {
+	cfg         *config.Config
+	sandbox     *sandbox
+	output      bytes.Buffer
+	diagnostics []diag.Diagnostic
+	err         error
+}
+
+// compileSeries compiles every series of a file's target in its own sandbox,
+// all at the same time when parallel is set, else one after another until one
+// fails, then merges their artifacts next to the real source (and, for a
+// split build, into each series' own SPlsWork_S<n>). Compiler output is
+// printed a series at a time once all have finished, and errors found in
+// several series are reported once
+func (s *Session) compileSeries(ctx context.Context, sourceFile string, cfg *config.Config, series []string, parallel bool) error {
+	runs := make([]*seriesRun, 0, len(series))
+
+	defer func() {
+		for _, r := range runs {
+			r.sandbox.Close()
+		}
+	}()
+
+	for _, name := range series {
+		runCfg := *cfg
+		runCfg.Target = strings.TrimPrefix(name, "series")
+		runCfg.Series = []string{name}
+
+		sb, err := newSandbox(sourceFile, &runCfg)
+		if err != nil {
+			return err
+		}
+
+		runs = append(runs, &seriesRun{cfg: &runCfg, sandbox: sb})
+	}
+
+	if parallel {
+		logger.ForFile(sourceFile, cfg.Target).Phase(logger.PhaseCompile).Debugf("compiling %d series in parallel", len(runs))
+
+		var wg sync.WaitGroup
+		for _, r := range runs {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				s.runSeries(ctx, sourceFile, r)
+			}()
+		}
+
+		wg.Wait()
+	} else {
+		for _, r := range runs {
+			s.runSeries(ctx, sourceFile, r)
+			if r.err != nil {
+				break
+			}
+		}
+	}
+
+	s.replay(runs)
+
+	for _, r := range runs {
+		if r.err != nil {
+			return r.err
+		}
+	}
+
+	for _, r := range runs {
+		if err := r.sandbox.harvest(sourceFile, r.cfg); err != nil {
+			return err
+		}
+
+		if cfg.SplitWork {
+			if err := r.sandbox.harvestSeries(sourceFile, r.cfg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// runSeries compiles one series of a file in its sandbox, collecting its
+// compiler output and diagnostics to print once every series has finished
+func (s *Session) runSeries(ctx context.Context, sourceFile string, r *seriesRun) {
+	sub := *s
+	sub.Stdout, sub.Stderr = &r.output, &r.output
+	sub.OnDiagnostic = func(d diag.Diagnostic) { r.diagnostics = append(r.diagnostics, d) }
+
+	r.err = sub.run(ctx, sourceFile, r.sandbox.source, r.cfg)
+}
+
+// replay prints each series' compiler output in order and reports the
+// diagnostics found, skipping those already reported for another series
+func (s *Session) replay(runs []*seriesRun) {
+	var stdout io.Writer = os.Stdout
+	if s.Stdout != nil {
+		stdout = s.Stdout
+	}
+
+	seen := make(map[string]bool)
+
+	for _, r := range runs {
+		_, _ = r.output.WriteTo(stdout)
+
+		for _, d := range r.diagnostics {
+			if seen[d.String()] {
+				continue
+			}
+
+			seen[d.String()] = true
+
+			if s.OnDiagnostic != nil {
+				s.OnDiagnostic(d)
+			} else {
+				logger.ForFile(d.File, r.cfg.Target).Phase(logger.PhaseCompile).Errorf("%s", d.WithExcerpt())
+			}
+		}
+	}
+}
+
+// parallelSeries returns the series of a file's target to compile in
+// parallel, or nil to compile them in one run
+func parallelSeries(cfg *config.Config) []string {
+	if !cfg.ParallelSeries {
+		return nil
+	}
+
+	series := utils.ParseTarget(cfg.Target)
+	if len(series) < 2 {
+		return nil
+	}
+
+	return series
+}
+
+// splitSeries returns the series of a file's target to compile one at a time
+// for a split build, or nil when the build isn't split
+func splitSeries(cfg *config.Config) []string {
+	if !cfg.SplitWork {
+		return nil
+	}
+
+	return utils.ParseTarget(cfg.Target)
+}
@@ -0,0 +1,187 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+// seriesCompiler writes a fake compiler that logs the directory and target of
+// each run and writes the outputs of the series it is given. Sources
+// containing "fail" fail with a compile error
+func seriesCompiler(t *testing.T) (compiler, log string) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the compiler")
+	}
+
+	bin := t.TempDir()
+	compiler, log = filepath.Join(bin, "SPlusCC"), filepath.Join(bin, "runs")
+
+	writeFile(t, compiler, `#!/bin/sh
+target=
+while [ $# -gt 0 ]; do
+	case "$1" in
+	/target) target=${2#series}; shift ;;
+	*.usp) src=$1 ;;
+	esac
+	shift
+done
+dir=$(dirname "$src")
+name=$(basename "${src%.usp}")
+echo "$target $dir" >> "`+log+`"
+if grep -q fail "$src"; then
+	echo "Error 1001 (Line 3) - Undefined variable"
+	exit 1
+fi
+mkdir -p "$dir/SPlsWork"
+echo header > "$dir/$name.ush"
+case "$target" in
+2) echo "series 2" > "$dir/SPlsWork/S2_$name.c" ;;
+*) echo "series 3/4" > "$dir/SPlsWork/$name.dll" ;;
+esac
+`)
+	require.NoError(t, os.Chmod(compiler, 0o755))
+
+	return compiler, log
+}
+
+func TestSession_BuildFile_ParallelSeries(t *testing.T) {
+	compiler, log := seriesCompiler(t)
+
+	source := writeSources(t, "main.usp")[0]
+	dir := filepath.Dir(source)
+
+	var output bytes.Buffer
+	s := NewSession(&config.Config{CompilerPath: compiler, Target: "234", ParallelSeries: true}, nil)
+	s.Stdout, s.Stderr = &output, &output
+
+	result := s.BuildFile(context.Background(), source)
+	require.NoError(t, result.Err)
+
+	data, err := os.ReadFile(log)
+	require.NoError(t, err)
+
+	runs := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, runs, 3)
+
+	dirs := make(map[string]bool)
+	var targets []string
+	for _, run := range runs {
+		target, runDir, _ := strings.Cut(run, " ")
+		targets = append(targets, target)
+		dirs[runDir] = true
+	}
+
+	assert.ElementsMatch(t, []string{"2", "3", "4"}, targets)
+	assert.Len(t, dirs, 3, "each series runs in its own sandbox")
+	assert.NotContains(t, dirs, dir)
+
+	assert.FileExists(t, filepath.Join(dir, "main.ush"))
+	assert.FileExists(t, filepath.Join(dir, "SPlsWork", "S2_main.c"))
+	assert.FileExists(t, filepath.Join(dir, "SPlsWork", "main.dll"))
+}
+
+func TestSession_BuildFile_ParallelSeries_Errors(t *testing.T) {
+	compiler, _ := seriesCompiler(t)
+
+	source := filepath.Join(t.TempDir(), "main.usp")
+	writeFile(t, source, "// fail")
+
+	var output bytes.Buffer
+	var diagnostics []diag.Diagnostic
+
+	s := NewSession(&config.Config{CompilerPath: compiler, Target: "34", ParallelSeries: true}, nil)
+	s.Stdout, s.Stderr = &output, &output
+	s.OnDiagnostic = func(d diag.Diagnostic) { diagnostics = append(diagnostics, d) }
+
+	result := s.BuildFile(context.Background(), source)
+	require.Error(t, result.Err)
+
+	assert.Equal(t, 2, strings.Count(output.String(), "Undefined variable"), "each series' output is printed")
+	require.Len(t, diagnostics, 1, "an error found in both series is reported once")
+	assert.Equal(t, source, diagnostics[0].File)
+	assert.Equal(t, 3, diagnostics[0].Line)
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(source), "main.ush"))
+}
+
+func TestSession_BuildFile_SplitWork(t *testing.T) {
+	compiler, log := seriesCompiler(t)
+
+	source := writeSources(t, "main.usp")[0]
+	dir := filepath.Dir(source)
+
+	var output bytes.Buffer
+	s := NewSession(&config.Config{CompilerPath: compiler, Target: "234", SplitWork: true}, nil)
+	s.Stdout, s.Stderr = &output, &output
+
+	result := s.BuildFile(context.Background(), source)
+	require.NoError(t, result.Err)
+
+	data, err := os.ReadFile(log)
+	require.NoError(t, err)
+
+	runs := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, runs, 3)
+
+	var targets []string
+	for _, run := range runs {
+		target, _, _ := strings.Cut(run, " ")
+		targets = append(targets, target)
+	}
+
+	assert.Equal(t, []string{"2", "3", "4"}, targets, "series are compiled one at a time, in order")
+
+	assert.FileExists(t, filepath.Join(dir, "main.ush"))
+	assert.FileExists(t, filepath.Join(dir, "SPlsWork", "S2_main.c"))
+	assert.FileExists(t, filepath.Join(dir, "SPlsWork", "main.dll"))
+
+	assert.FileExists(t, filepath.Join(dir, "SPlsWork_S2", "S2_main.c"))
+	assert.NoFileExists(t, filepath.Join(dir, "SPlsWork_S2", "main.dll"))
+	assert.FileExists(t, filepath.Join(dir, "SPlsWork_S3", "main.dll"))
+	assert.FileExists(t, filepath.Join(dir, "SPlsWork_S4", "main.dll"))
+	assert.NoFileExists(t, filepath.Join(dir, "SPlsWork_S4", "main.ush"), "the header stays next to the source")
+}
+
+func TestSession_BuildFile_SplitWork_StopsAtFailure(t *testing.T) {
+	compiler, log := seriesCompiler(t)
+
+	source := filepath.Join(t.TempDir(), "main.usp")
+	writeFile(t, source, "// fail")
+
+	var output bytes.Buffer
+	s := NewSession(&config.Config{CompilerPath: compiler, Target: "34", SplitWork: true}, nil)
+	s.Stdout, s.Stderr = &output, &output
+
+	result := s.BuildFile(context.Background(), source)
+	require.Error(t, result.Err)
+
+	data, err := os.ReadFile(log)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(data)), "\n"), 1, "series 4 is not compiled after series 3 fails")
+	assert.NoDirExists(t, filepath.Join(filepath.Dir(source), "SPlsWork_S3"))
+}
+
+func TestSplitSeries(t *testing.T) {
+	assert.Nil(t, splitSeries(&config.Config{Target: "234"}))
+	assert.Equal(t, []string{"series3"}, splitSeries(&config.Config{Target: "3", SplitWork: true}))
+	assert.Equal(t, []string{"series2", "series3", "series4"}, splitSeries(&config.Config{Target: "234", SplitWork: true}))
+}
+
+func TestParallelSeries(t *testing.T) {
+	assert.Nil(t, parallelSeries(&config.Config{Target: "234"}))
+	assert.Nil(t, parallelSeries(&config.Config{Target: "3", ParallelSeries: true}))
+	assert.Equal(t, []string{"series3", "series4"}, parallelSeries(&config.Config{Target: "34", ParallelSeries: true}))
+	assert.Equal(t, []string{"series3", "series4"}, parallelSeries(&config.Config{Target: "34", ParallelSeries: true, OutputFile: "build.log"}), "each run writes its own compile log")
+}
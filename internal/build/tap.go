@@ -0,0 +1,81 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+// tapDetails is the YAML block following a failed TAP test point
+type tapDetails struct {
+	Message     string   `yaml:"message"`
+	Severity    string   `yaml:"severity"`
+	Diagnostics []string `yaml:"diagnostics,omitempty"`
+	DurationMS  int64    `yaml:"duration_ms"`
+}
+
+// WriteTAP writes the results of building files as Test Anything Protocol
+// (version 13), a test point per file. Files a fail-fast build never got to
+// are skipped, and a failure's error and diagnostics follow it as YAML
+func WriteTAP(w io.Writer, files []string, results []Result, diagnostics map[string][]diag.Diagnostic) error {
+	byFile := make(map[string]Result, len(results))
+	for _, r := range results {
+		byFile[r.File] = r
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "TAP version 13\n1..%d\n", len(files))
+
+	for i, file := range files {
+		r, ok := byFile[file]
+
+		switch {
+		case !ok:
+			fmt.Fprintf(&b, "ok %d - %s # SKIP not built after an earlier failure\n", i+1, tapDescription(file))
+		case r.Status == StatusFailed:
+			fmt.Fprintf(&b, "not ok %d - %s\n", i+1, tapDescription(file))
+
+			details := tapDetails{Severity: "fail", DurationMS: r.Duration.Milliseconds()}
+			if r.Err != nil {
+				details.Message = r.Err.Error()
+			}
+
+			for _, d := range diagnostics[r.Path] {
+				details.Diagnostics = append(details.Diagnostics, d.String())
+			}
+
+			var data strings.Builder
+			enc := yaml.NewEncoder(&data)
+			enc.SetIndent(2)
+			if err := enc.Encode(details); err != nil {
+				return err
+			}
+
+			b.WriteString("  ---\n")
+			for _, line := range strings.SplitAfter(strings.TrimSuffix(data.String(), "\n"), "\n") {
+				b.WriteString("  " + line)
+			}
+
+			b.WriteString("\n  ...\n")
+		case r.Status == StatusCached:
+			fmt.Fprintf(&b, "ok %d - %s (cached)\n", i+1, tapDescription(file))
+		default:
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, tapDescription(file))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// tapDescription returns the description of a file's test point, with
+// forward slashes and the characters TAP gives a meaning escaped
+func tapDescription(file string) string {
+	file = strings.ReplaceAll(filepath.ToSlash(file), `\`, `\\`)
+	return strings.ReplaceAll(file, "#", `\#`)
+}
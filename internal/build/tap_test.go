@@ -0,0 +1,50 @@
+package build
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+func TestWriteTAP(t *testing.T) {
+	files := []string{"a.usp", "b #2.usp", "c.usp", "d.usp"}
+	results := []Result{
+		{File: "a.usp", Path: "/src/a.usp", Status: StatusSucceeded},
+		{File: "b #2.usp", Path: "/src/b #2.usp", Status: StatusCached},
+		{File: "c.usp", Path: "/src/c.usp", Status: StatusFailed, Err: errors.New("compilation failed"), Duration: 1500 * time.Millisecond},
+	}
+
+	diagnostics := map[string][]diag.Diagnostic{
+		"/src/c.usp": {{File: "/src/c.usp", Line: 14, Severity: diag.SeverityError, Code: "1300", Message: "Invalid constant"}},
+	}
+
+	var out strings.Builder
+	require.NoError(t, WriteTAP(&out, files, results, diagnostics))
+
+	assert.Equal(t, `TAP version 13
+1..4
+ok 1 - a.usp
+ok 2 - b \#2.usp (cached)
+not ok 3 - c.usp
+  ---
+  message: compilation failed
+  severity: fail
+  diagnostics:
+    - '/src/c.usp(14): error 1300: Invalid constant'
+  duration_ms: 1500
+  ...
+ok 4 - d.usp # SKIP not built after an earlier failure
+`, out.String())
+}
+
+func TestWriteTAP_NoFiles(t *testing.T) {
+	var out strings.Builder
+	require.NoError(t, WriteTAP(&out, nil, nil, nil))
+	assert.Equal(t, "TAP version 13\n1..0\n", out.String())
+}
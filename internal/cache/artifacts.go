@@ -19,18 +19,22 @@ package cache
 
 import (
 	"bytes"
-	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+
+	"github.com/Norgate-AV/spc/internal/artifact"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/Norgate-AV/spc/internal/utils"
 )
 
 // CopyArtifacts copies compiled outputs from a base directory to cache
 // The outputs paths are relative to baseDir (e.g., "SPlsWork/example.dll", "example.ush")
 func CopyArtifacts(baseDir, destDir string, outputs []string) error {
-	if err := os.MkdirAll(destDir, 0o755); err != nil {
+	if err := os.MkdirAll(utils.LongPath(destDir), 0o755); err != nil {
 		return fmt.Errorf("failed to create artifact directory: %w", err)
 	}
 
@@ -52,13 +56,17 @@ func CopyArtifacts(baseDir, destDir string, outputs []string) error {
 func RestoreArtifacts(cacheDir, destDir string, outputs []string) error {
 	for _, output := range outputs {
 		src := filepath.Join(cacheDir, output)
-		dst := filepath.Join(destDir, output)
+		dir := filepath.Dir(filepath.Join(destDir, output))
 
 		// Create parent directory if needed (e.g., for SPlsWork/...)
-		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		if err := os.MkdirAll(utils.LongPath(dir), 0o755); err != nil {
 			return fmt.Errorf("failed to create output directory: %w", err)
 		}
 
+		// Replace the file already there under another case or composition
+		// rather than adding a second one on case-sensitive file systems
+		dst := filepath.Join(dir, existingName(dir, filepath.Base(output)))
+
 		// Only copy if file doesn't exist or differs
 		if _, err := copyFileIfNeeded(src, dst); err != nil {
 			return fmt.Errorf("failed to restore %s: %w", output, err)
@@ -68,6 +76,55 @@ func RestoreArtifacts(cacheDir, destDir string, outputs []string) error {
 	return nil
 }
 
+// existingName returns the name of the file in dir that Windows would treat
+// as name, or name itself if there is none
+func existingName(dir, name string) string {
+	if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+		return name
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return name
+	}
+
+	for _, entry := range entries {
+		if artifact.Equal(entry.Name(), name) {
+			return entry.Name()
+		}
+	}
+
+	return name
+}
+
+// ExportArtifacts copies a source file's outputs for the given target into
+// destDir, flattening the SPlsWork layout so every artifact sits side by side.
+// Returns the names of the exported files
+func ExportArtifacts(sourceFile, target, destDir string) ([]string, error) {
+	outputs, err := CollectOutputs(sourceFile, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(utils.LongPath(destDir), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	sourceDir := filepath.Dir(sourceFile)
+	exported := make([]string, 0, len(outputs))
+
+	for _, output := range outputs {
+		name := filepath.Base(output)
+		if _, err := copyFileIfNeeded(filepath.Join(sourceDir, output), filepath.Join(destDir, name)); err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", output, err)
+		}
+
+		exported = append(exported, name)
+	}
+
+	return exported, nil
+}
+
 // CollectOutputs scans for compiled output files specific to the given source file.
 // It checks two locations:
 //  1. The source file directory for .ush header files
@@ -78,15 +135,14 @@ func RestoreArtifacts(cacheDir, destDir string, outputs []string) error {
 func CollectOutputs(sourceFile string, target string) ([]string, error) {
 	var outputs []string
 
-	// Extract base name without extension (e.g., "example1" from "example1.usp")
-	baseName := filepath.Base(sourceFile)
-	baseName = baseName[:len(baseName)-len(filepath.Ext(baseName))]
+	// The module's outputs are named after it (e.g., "example1" from "example1.usp")
+	module := artifact.NewModule(sourceFile)
 
 	sourceDir := filepath.Dir(sourceFile)
 	splsWorkDir := filepath.Join(sourceDir, "SPlsWork")
 
 	// Check for .ush file adjacent to source
-	ushFile := baseName + ".ush"
+	ushFile := module.Name() + artifact.HeaderExt
 	ushPath := filepath.Join(sourceDir, ushFile)
 	if _, err := os.Stat(ushPath); err == nil {
 		outputs = append(outputs, ushFile)
@@ -115,7 +171,7 @@ func CollectOutputs(sourceFile string, target string) ([]string, error) {
 
 		// Check if this file belongs to our source file AND target
 		// Match patterns: {basename}.* or S2_{basename}.* (depending on target)
-		if isOutputFileForTarget(name, baseName, target) {
+		if module.ForTarget(name, target) {
 			// Store with SPlsWork/ prefix for proper path handling
 			outputs = append(outputs, filepath.Join("SPlsWork", name))
 		}
@@ -124,6 +180,42 @@ func CollectOutputs(sourceFile string, target string) ([]string, error) {
 	return outputs, nil
 }
 
+// SeriesWorkDir returns the directory, next to the source files, that a split
+// build keeps a series' outputs in (e.g., "SPlsWork_S3" for "series3")
+func SeriesWorkDir(series string) string {
+	return "SPlsWork_S" + strings.TrimPrefix(series, "series")
+}
+
+// CollectSeriesOutputs scans the SPlsWork_S2, SPlsWork_S3 and SPlsWork_S4
+// directories of a split build for compiled output files specific to the given
+// source file. Returns paths relative to the source directory (e.g.,
+// "SPlsWork_S3/example.dll")
+func CollectSeriesOutputs(sourceFile string) ([]string, error) {
+	var outputs []string
+
+	module := artifact.NewModule(sourceFile)
+
+	for _, series := range utils.ParseTarget("234") {
+		workDir := SeriesWorkDir(series)
+
+		entries, err := os.ReadDir(filepath.Join(filepath.Dir(sourceFile), workDir))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // Not a split build, or not for this series
+			}
+			return nil, fmt.Errorf("failed to read %s directory: %w", workDir, err)
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() && module.ForTarget(entry.Name(), "234") {
+				outputs = append(outputs, filepath.Join(workDir, entry.Name()))
+			}
+		}
+	}
+
+	return outputs, nil
+}
+
 // CollectSharedFiles scans the SPlsWork directory for shared library files
 // that are not specific to any source file (DLLs, config files, etc.)
 // Returns paths relative to the source directory (e.g., "SPlsWork/Version.ini")
@@ -203,82 +295,12 @@ func findSubstring(s, substr string) bool {
 	return false
 }
 
-// isOutputFileForTarget checks if a file belongs to the given source AND target
-// For target "34", only matches example1.* (not S2_example1.*)
-// For target "234", matches both example1.* and S2_example1.*
-//
-// Handles the compiler's space-to-underscore conversion:
-// - Source: "example 3.usp"
-// - .ush/.inf files: "example 3.ush" (spaces kept)
-// - .cs/.dll files: "example_3.cs" (spaces→underscores)
-// - S2_* files: "S2_example_3.c" (spaces→underscores)
-//
-// Important: .cs, .dll, .inf files are Series 3/4 specific (no S3_/S4_ prefix)
-// For target "2", only match S2_* files (and .ush which is always present)
-func isOutputFileForTarget(filename, baseName, target string) bool {
-	fileBase := filename[:len(filename)-len(filepath.Ext(filename))]
-	ext := filepath.Ext(filename)
-
-	// Create underscore version of baseName for comparison
-	// The compiler converts spaces to underscores in certain file types
-	baseNameWithUnderscore := strings.ReplaceAll(baseName, " ", "_")
-
-	// .ush files are always included (generated for all targets)
-	if ext == ".ush" && (fileBase == baseName || fileBase == baseNameWithUnderscore) {
-		return true
-	}
-
-	// Direct match: example1.dll, example1.cs, example1.inf, etc. or example_3.dll, example_3.cs
-	// These are for Series 3 and 4 ONLY (they have no prefix)
-	// Skip these if target is only Series 2
-	if fileBase == baseName || fileBase == baseNameWithUnderscore {
-		// .cs, .dll, .inf are Series 3/4 specific
-		if ext == ".cs" || ext == ".dll" || ext == ".inf" {
-			return contains(target, '3') || contains(target, '4')
-		}
-		// Other extensions (if any) also belong to Series 3/4
-		return contains(target, '3') || contains(target, '4')
-	}
-
-	// Target-prefixed match: S2_example1.c, S2_example1.h, S3_example1.*, S4_example1.*
-	// Also matches: S2_example_3.c when source is "example 3.usp"
-	if len(fileBase) > 3 && fileBase[0] == 'S' && fileBase[2] == '_' {
-		// Extract the series number
-		seriesChar := fileBase[1]
-
-		// Extract the base name after prefix (e.g., "example1" from "S2_example1")
-		nameAfterPrefix := fileBase[3:]
-
-		// Check if this matches our base name (with or without underscores)
-		if nameAfterPrefix == baseName || nameAfterPrefix == baseNameWithUnderscore {
-			// Check if this series is in the target
-			// For example, if target="34", we want Series 3 and 4, not Series 2
-			switch seriesChar {
-			case '2':
-				return contains(target, '2')
-			case '3':
-				return contains(target, '3')
-			case '4':
-				return contains(target, '4')
-			}
-		}
-	}
-
-	return false
-}
-
-// contains checks if a string contains a specific character
-func contains(s string, ch byte) bool {
-	for i := 0; i < len(s); i++ {
-		if s[i] == ch {
-			return true
-		}
+// copyFile copies a file from src to dst, replacing dst atomically
+func copyFile(src, dst string) error {
+	if err := faults.failCopy(); err != nil {
+		return err
 	}
-	return false
-}
 
-// copyFile copies a file from src to dst
-func copyFile(src, dst string) error {
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return err
@@ -286,29 +308,54 @@ func copyFile(src, dst string) error {
 
 	defer srcFile.Close()
 
+	srcInfo, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+
 	// Create parent directory if needed
 	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
 		return err
 	}
 
-	dstFile, err := os.Create(dst)
+	// Write to a temp file beside dst and rename it into place, so a crash
+	// mid-copy never leaves a truncated file at dst
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
 	if err != nil {
 		return err
 	}
 
-	defer dstFile.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := writeTemp(tmp, srcFile, srcInfo.Mode()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
+
+// writeTemp copies r into tmp, syncs it to disk and closes it
+func writeTemp(tmp *os.File, r io.Reader, mode os.FileMode) error {
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		return err
+	}
 
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
+	if err := faults.truncateWrite(tmp); err != nil {
 		return err
 	}
 
 	// Preserve file permissions
-	srcInfo, err := os.Stat(src)
-	if err != nil {
+	if err := tmp.Chmod(mode); err != nil {
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
 		return err
 	}
 
-	return os.Chmod(dst, srcInfo.Mode())
+	return tmp.Close()
 }
 
 // filesAreIdentical checks if two files have the same content
@@ -353,7 +400,17 @@ func filesAreIdentical(file1, file2 string) bool {
 	return bytes.Equal(hash1, hash2)
 }
 
-// hashFile computes SHA256 hash of a file
+// compareAlgorithm is the cache.hash algorithm used to compare large
+// artifacts (unset for sha256)
+var compareAlgorithm atomic.Value
+
+// SetCompareAlgorithm sets the cache.hash algorithm used to tell whether an
+// artifact already at its destination is identical to the one being copied
+func SetCompareAlgorithm(algorithm string) {
+	compareAlgorithm.Store(algorithm)
+}
+
+// hashFile hashes a file for comparison with the compare algorithm
 func hashFile(path string) ([]byte, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -361,7 +418,9 @@ func hashFile(path string) ([]byte, error) {
 	}
 	defer file.Close()
 
-	hash := sha256.New()
+	algorithm, _ := compareAlgorithm.Load().(string)
+
+	hash := newDigest(algorithm)
 	if _, err := io.Copy(hash, file); err != nil {
 		return nil, err
 	}
@@ -372,15 +431,22 @@ func hashFile(path string) ([]byte, error) {
 // copyFileIfNeeded copies a file only if destination doesn't exist or differs from source
 // Returns true if file was copied, false if copy was skipped
 func copyFileIfNeeded(src, dst string) (bool, error) {
+	// Deeply nested user folders can push SPlsWork paths past MAX_PATH
+	src, dst = utils.LongPath(src), utils.LongPath(dst)
+
 	// Check if files are already identical
 	if filesAreIdentical(src, dst) {
+		logger.Debugf("skipped identical %s", dst)
 		return false, nil // Skip copy
 	}
 
-	// Files differ or destination doesn't exist, perform copy
-	if err := copyFile(src, dst); err != nil {
+	// Files differ or destination doesn't exist, perform copy. SIMPL Windows
+	// or antivirus may hold the destination open for a moment
+	if err := retryLocked(dst, func() error { return copyFile(src, dst) }); err != nil {
 		return false, err
 	}
 
+	logger.Debugf("copied %s -> %s", src, dst)
+
 	return true, nil
 }
@@ -1,12 +1,15 @@
 package cache
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/Norgate-AV/spc/internal/artifact"
 )
 
-func TestIsOutputFileForTarget_SpacesInFilename(t *testing.T) {
+func TestOutputFileForTarget_SpacesInFilename(t *testing.T) {
 	tests := []struct {
 		name     string
 		filename string
@@ -77,9 +80,9 @@ func TestIsOutputFileForTarget_SpacesInFilename(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := isOutputFileForTarget(tt.filename, tt.baseName, tt.target)
+			got := artifact.ModuleNamed(tt.baseName).ForTarget(tt.filename, tt.target)
 			if got != tt.want {
-				t.Errorf("isOutputFileForTarget(%q, %q, %q) = %v, want %v",
+				t.Errorf("ForTarget(%q) for %q, target %q = %v, want %v",
 					tt.filename, tt.baseName, tt.target, got, tt.want)
 			}
 		})
@@ -217,3 +220,184 @@ func TestCollectOutputs_SpacesInFilename(t *testing.T) {
 		})
 	}
 }
+
+func TestExportArtifacts(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	sourceFile := filepath.Join(tmpDir, "example.usp")
+	files := []string{
+		"example.usp",
+		"example.ush",
+		filepath.Join("SPlsWork", "example.dll"),
+		filepath.Join("SPlsWork", "S2_example.elf"),
+		filepath.Join("SPlsWork", "other.dll"),
+		filepath.Join("SPlsWork", "Version.ini"),
+	}
+
+	for _, f := range files {
+		path := filepath.Join(tmpDir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(f), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	destDir := filepath.Join(tmpDir, "dist", "example")
+	exported, err := ExportArtifacts(sourceFile, "34", destDir)
+	if err != nil {
+		t.Fatalf("ExportArtifacts() error = %v", err)
+	}
+
+	if len(exported) != 2 {
+		t.Errorf("ExportArtifacts() exported %v, want example.ush and example.dll", exported)
+	}
+
+	for _, name := range []string{"example.ush", "example.dll"} {
+		if _, err := os.Stat(filepath.Join(destDir, name)); err != nil {
+			t.Errorf("expected %s in output directory: %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"S2_example.elf", "other.dll", "Version.ini", "SPlsWork"} {
+		if _, err := os.Stat(filepath.Join(destDir, name)); err == nil {
+			t.Errorf("did not expect %s in output directory", name)
+		}
+	}
+}
+
+func TestCollectSeriesOutputs(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	sourceFile := filepath.Join(tmpDir, "example.usp")
+	files := []string{
+		filepath.Join("SPlsWork", "example.dll"),
+		filepath.Join("SPlsWork_S2", "S2_example.c"),
+		filepath.Join("SPlsWork_S2", "Version.ini"),
+		filepath.Join("SPlsWork_S4", "example.dll"),
+		filepath.Join("SPlsWork_S4", "other.dll"),
+	}
+
+	for _, f := range files {
+		path := filepath.Join(tmpDir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(f), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outputs, err := CollectSeriesOutputs(sourceFile)
+	if err != nil {
+		t.Fatalf("CollectSeriesOutputs() error = %v", err)
+	}
+
+	want := []string{filepath.Join("SPlsWork_S2", "S2_example.c"), filepath.Join("SPlsWork_S4", "example.dll")}
+	if len(outputs) != len(want) || outputs[0] != want[0] || outputs[1] != want[1] {
+		t.Errorf("CollectSeriesOutputs() = %v, want %v", outputs, want)
+	}
+
+	if got := SeriesWorkDir("series3"); got != "SPlsWork_S3" {
+		t.Errorf("SeriesWorkDir(series3) = %q, want SPlsWork_S3", got)
+	}
+}
+
+func TestCopyFile_Atomic(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.dll")
+	dst := filepath.Join(dir, "out", "dst.dll")
+
+	if err := os.WriteFile(src, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		t.Fatalf("copyFile() error = %v", err)
+	}
+
+	if err := os.WriteFile(src, []byte("new content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A copy that fails leaves the previous dst in place
+	EnableFaultInjection(NewFaultInjector(1, 1))
+	err := copyFile(src, dst)
+	EnableFaultInjection(nil)
+
+	if !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("copyFile() error = %v, want injected fault", err)
+	}
+
+	content, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "content" {
+		t.Errorf("dst = %q, want the previous content", content)
+	}
+
+	// A copy that fails after writing the temp file removes it
+	blocked := filepath.Join(dir, "out", "blocked.dll")
+	if err := os.MkdirAll(filepath.Join(blocked, "child"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := copyFile(src, blocked); err == nil {
+		t.Fatal("copyFile() onto a directory should fail")
+	}
+
+	if err := os.RemoveAll(blocked); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(dst))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Name() != "dst.dll" {
+		t.Errorf("expected only dst.dll after copying, found %v", entries)
+	}
+}
+
+func TestRestoreArtifacts_ReplacesDifferentlyCasedFile(t *testing.T) {
+	cacheDir := t.TempDir()
+	destDir := t.TempDir()
+
+	for dir, name := range map[string]string{cacheDir: "example_3.dll", destDir: "Example_3.DLL"} {
+		if err := os.MkdirAll(filepath.Join(dir, "SPlsWork"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, "SPlsWork", name), []byte(dir), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := RestoreArtifacts(cacheDir, destDir, []string{filepath.Join("SPlsWork", "example_3.dll")}); err != nil {
+		t.Fatalf("RestoreArtifacts() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(destDir, "SPlsWork"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("expected the existing file to be replaced, found %v", entries)
+	}
+
+	content, err := os.ReadFile(filepath.Join(destDir, "SPlsWork", entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != cacheDir {
+		t.Errorf("restored content = %q, want the cached artifact", content)
+	}
+}
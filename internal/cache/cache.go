@@ -6,7 +6,8 @@
 //
 //  1. Filters artifacts by source file name (e.g., example1.dll, S2_example1.c)
 //  2. Stores only relevant artifacts per source file in separate cache entries
-//  3. Uses SHA256 hashing of source content + configuration for cache keys
+//  3. Uses SHA256 (or BLAKE3 or XXH3, see cache.hash) hashing of source content
+//     + configuration for cache keys
 //  4. Stores metadata in BoltDB and artifacts in the filesystem
 //
 // This allows incremental compilation where each source file can be cached
@@ -14,15 +15,23 @@
 package cache
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.etcd.io/bbolt"
 
 	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/deps"
+	"github.com/Norgate-AV/spc/internal/logger"
 )
 
 const (
@@ -37,6 +46,17 @@ const (
 type Cache struct {
 	db   *bbolt.DB
 	root string // Root directory for cache (.spc-cache/)
+	mode Mode   // Access mode negotiated from the stored format version
+
+	// remote is the cache shared with other machines, if any, and whether
+	// builds may only be fetched from it
+	remote         Remote
+	remoteReadOnly atomic.Bool
+
+	// shared remembers library hashes between the files of a build, by
+	// algorithm (nil outside one, when every lookup hashes afresh)
+	mu     sync.Mutex
+	shared map[string]*Hasher
 }
 
 // New creates a new cache instance
@@ -73,12 +93,86 @@ func New(cacheDir string) (*Cache, error) {
 		return nil, fmt.Errorf("failed to create cache bucket: %w", err)
 	}
 
+	// Make sure we understand the format of an existing cache
+	mode, err := negotiateFormat(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read cache format: %w", err)
+	}
+
+	if mode != ModeReadWrite {
+		logger.Warnf("Cache at %s was written by a newer version of spc, using it %s", cacheDir, mode)
+	}
+
 	return &Cache{
 		db:   db,
 		root: cacheDir,
+		mode: mode,
 	}, nil
 }
 
+// Dir returns the cache directory
+func (c *Cache) Dir() string {
+	return c.root
+}
+
+// Mode returns the access mode negotiated for this cache
+func (c *Cache) Mode() Mode {
+	return c.mode
+}
+
+// SetReadOnly stops the cache from storing or evicting entries; cached
+// builds can still be restored
+func (c *Cache) SetReadOnly() {
+	if c.mode == ModeReadWrite {
+		c.mode = ModeReadOnly
+	}
+}
+
+// ShareHashes makes lookups and stores remember the hashes of the libraries
+// they read until the returned function is called, so a build reads each
+// library once however many of its files use it
+func (c *Cache) ShareHashes() (done func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.shared = make(map[string]*Hasher)
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.shared = nil
+	}
+}
+
+// hasher returns the hasher for a file's cache.hash algorithm: the shared
+// one during a build, a new one otherwise
+func (c *Cache) hasher(cfg *config.Config) *Hasher {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.shared == nil {
+		return c.newHasher(cfg.Cache.Hash)
+	}
+
+	algorithm := algorithmName(cfg.Cache.Hash)
+	if c.shared[algorithm] == nil {
+		c.shared[algorithm] = c.newHasher(algorithm)
+	}
+
+	return c.shared[algorithm]
+}
+
+// newHasher creates a hasher that remembers file hashes in the cache
+// database between runs
+func (c *Cache) newHasher(algorithm string) *Hasher {
+	h := NewHasher(algorithm, 0)
+	h.stats = c
+
+	return h
+}
+
 // Close closes the cache database
 func (c *Cache) Close() error {
 	if c.db != nil {
@@ -90,12 +184,28 @@ func (c *Cache) Close() error {
 
 // Get retrieves a cache entry by source file and configuration
 // Returns nil if cache miss
-func (c *Cache) Get(sourceFile string, cfg *config.Config) (*Entry, error) {
-	hash, err := HashSource(sourceFile, cfg)
+func (c *Cache) Get(ctx context.Context, sourceFile string, cfg *config.Config) (*Entry, error) {
+	if c.mode == ModeBypass {
+		return nil, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	log := logger.ForFile(sourceFile, cfg.Target)
+	hasher := c.hasher(cfg)
+
+	hash, err := hasher.HashSource(sourceFile, cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash source: %w", err)
 	}
 
+	log.Phase(logger.PhaseHash).Debugf("hashed %s (%s %s)", filepath.Base(sourceFile), hasher.Algorithm(), shortHash(hash))
+	log = log.Phase(logger.PhaseCache)
+
+	faults.delayLock()
+
 	var entry Entry
 	err = c.db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte(bucketName))
@@ -112,74 +222,231 @@ func (c *Cache) Get(sourceFile string, cfg *config.Config) (*Entry, error) {
 	}
 
 	if entry.Hash == "" {
-		return nil, nil // Cache miss
+		log.Debugf("cache miss for %s (%s)", filepath.Base(sourceFile), shortHash(hash))
+		return c.fetchRemote(ctx, hash, sourceFile, hasher.Algorithm(), log), nil
+	}
+
+	// Entries from a newer schema can't be trusted to mean what we think
+	if entry.Version > FormatVersion {
+		log.Debugf("ignoring cache entry for %s with newer format %d", filepath.Base(sourceFile), entry.Version)
+		return nil, nil
+	}
+
+	// A key from another algorithm matching is a coincidence, not a hit
+	if algorithmName(entry.HashAlgorithm) != hasher.Algorithm() {
+		log.Debugf("ignoring cache entry for %s hashed with %s", filepath.Base(sourceFile), algorithmName(entry.HashAlgorithm))
+		return nil, nil
+	}
+
+	// An entry whose artifacts are gone can't be restored
+	if entry.Success && !c.hasArtifacts(&entry) {
+		log.Debugf("ignoring cache entry for %s with missing artifacts", filepath.Base(sourceFile))
+		return c.fetchRemote(ctx, hash, sourceFile, hasher.Algorithm(), log), nil
 	}
 
+	log.Debugf("cache hit for %s (%s, success=%t)", filepath.Base(sourceFile), shortHash(hash), entry.Success)
+
 	return &entry, nil
 }
 
 // Store saves a cache entry and copies artifacts
-func (c *Cache) Store(sourceFile string, cfg *config.Config, success bool) error {
-	hash, err := HashSource(sourceFile, cfg)
+func (c *Cache) Store(ctx context.Context, sourceFile string, cfg *config.Config, success bool) error {
+	return c.StoreTimed(ctx, sourceFile, cfg, success, 0)
+}
+
+// StoreTimed saves a cache entry and copies artifacts, recording how long the
+// compile took so later hits can report the time they saved. Nothing is
+// stored once ctx is cancelled
+func (c *Cache) StoreTimed(ctx context.Context, sourceFile string, cfg *config.Config, success bool, compileTime time.Duration) error {
+	log := logger.ForFile(sourceFile, cfg.Target).Phase(logger.PhaseStore)
+
+	if c.mode != ModeReadWrite {
+		log.Debugf("not storing %s, cache is %s", filepath.Base(sourceFile), c.mode)
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	hasher := c.hasher(cfg)
+
+	hash, err := hasher.HashSource(sourceFile, cfg)
 	if err != nil {
 		return fmt.Errorf("failed to hash source: %w", err)
 	}
 
 	// Collect outputs from both source dir and SPlsWork dir
 	// Only collect files for the current target (prevents caching leftover files)
-	outputs, err := CollectOutputs(sourceFile, cfg.Target)
+	paths, err := CollectOutputs(sourceFile, cfg.Target)
 	if err != nil {
 		return fmt.Errorf("failed to collect outputs: %w", err)
 	}
 
+	// Only successful builds have their artifacts cached, so only they need
+	// checksums to verify them by on restore
+	sourceDir := filepath.Dir(sourceFile)
+	outputs := make([]Output, len(paths))
+	for i, path := range paths {
+		outputs[i] = Output{Path: path}
+	}
+
+	if success {
+		if outputs, err = checksumOutputs(sourceDir, paths); err != nil {
+			return err
+		}
+	}
+
+	libraries, err := libraryRefs(hasher, sourceFile, cfg)
+	if err != nil {
+		return err
+	}
+
 	// Create cache entry
 	entry := Entry{
+		Version:         FormatVersion,
 		Hash:            hash,
 		SourceFile:      sourceFile,
 		Target:          cfg.Target,
-		CompilerVersion: "", // TODO: detect compiler version
+		CompilerVersion: strings.Join(hasher.compilerVersions(cfg), ", "),
 		UserFolders:     cfg.UserFolders,
 		Timestamp:       time.Now(),
 		Outputs:         outputs,
 		Success:         success,
+		Libraries:       libraries,
+		CompileTime:     compileTime,
+		HashAlgorithm:   hasher.Algorithm(),
 	}
 
-	// Store metadata in BoltDB
-	err = c.db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte(bucketName))
-
-		data, err := json.Marshal(entry)
-		if err != nil {
-			return err
+	if success {
+		for _, o := range outputs {
+			entry.Size += o.Size
 		}
-
-		return b.Put([]byte(hash), data)
-	})
-	if err != nil {
-		return fmt.Errorf("failed to store cache entry: %w", err)
 	}
 
 	// Copy artifacts to cache (outputs are relative to source directory)
+	// before recording the entry, so an interrupted store never leaves an
+	// entry without its artifacts
 	if success && len(outputs) > 0 {
-		artifactDir := c.artifactDir(hash)
-		sourceDir := filepath.Dir(sourceFile)
-		if err := CopyArtifacts(sourceDir, artifactDir, outputs); err != nil {
+		if err := c.storeArtifacts(hash, sourceDir, outputs); err != nil {
 			return fmt.Errorf("failed to copy artifacts: %w", err)
 		}
 	}
 
+	if err := c.putEntry(&entry); err != nil {
+		return fmt.Errorf("failed to store cache entry: %w", err)
+	}
+
+	log.Debugf("stored cache entry for %s (%s, success=%t, %d outputs)",
+		filepath.Base(sourceFile), shortHash(hash), success, len(outputs))
+
+	if success {
+		c.uploadRemote(ctx, &entry, log)
+	}
+
 	// Cache shared files (only once, if not already cached)
 	if success {
-		sourceDir := filepath.Dir(sourceFile)
 		if err := c.cacheSharedFiles(sourceDir); err != nil {
 			// Don't fail the whole operation if shared files caching fails
-			fmt.Fprintf(os.Stderr, "Warning: Failed to cache shared files: %v\n", err)
+			log.Warnf("Failed to cache shared files: %v", err)
 		}
 	}
 
 	return nil
 }
 
+// putEntry records an entry in BoltDB, keeping the totals in step
+func (c *Cache) putEntry(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	faults.delayLock()
+
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucketName))
+
+		added, size := int64(1), entry.Size
+		if old := b.Get([]byte(entry.Hash)); old != nil {
+			var previous Entry
+			_ = json.Unmarshal(old, &previous)
+			added, size = 0, size-previous.Size
+		}
+
+		if err := b.Put([]byte(entry.Hash), data); err != nil {
+			return err
+		}
+
+		return addTotals(tx, added, size)
+	})
+}
+
+// libraryRefs records the SIMPL# libraries a source file is compiled against
+func libraryRefs(hasher *Hasher, sourceFile string, cfg *config.Config) ([]LibraryRef, error) {
+	dependencies, err := hasher.HashDependencies(sourceFile, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []LibraryRef
+	for _, d := range dependencies {
+		if d.Path == "" || !strings.EqualFold(filepath.Ext(d.Path), ".clz") {
+			continue
+		}
+
+		version, err := deps.AssemblyVersion(d.Path)
+		if err != nil {
+			logger.Debugf("could not read assembly version: %v", err)
+		}
+
+		refs = append(refs, LibraryRef{Path: d.Path, Hash: d.Hash, Version: version})
+	}
+
+	return refs, nil
+}
+
+// Entries returns every stored entry for a source file, newest first
+func (c *Cache) Entries(sourceFile string) ([]Entry, error) {
+	var entries []Entry
+
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucketName))
+
+		return b.ForEach(func(_, data []byte) error {
+			var entry Entry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return nil // Skip unreadable entries
+			}
+
+			if sameFile(entry.SourceFile, sourceFile) {
+				entries = append(entries, entry)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache entries: %w", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+
+	return entries, nil
+}
+
+// sameFile reports whether two absolute paths name the same source file
+// (case-insensitively on Windows)
+func sameFile(a, b string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+
+	return a == b
+}
+
 // cacheSharedFiles caches shared library files if not already cached
 func (c *Cache) cacheSharedFiles(sourceDir string) error {
 	sharedDir := filepath.Join(c.root, "shared")
@@ -201,6 +468,7 @@ func (c *Cache) cacheSharedFiles(sourceDir string) error {
 
 	// If all files already cached, skip
 	if len(missingFiles) == 0 {
+		logger.Debugf("shared files already cached")
 		return nil
 	}
 
@@ -212,15 +480,26 @@ func (c *Cache) cacheSharedFiles(sourceDir string) error {
 	return nil
 }
 
-// Restore copies cached artifacts back to the source directory
-func (c *Cache) Restore(entry *Entry, destDir string) error {
+// Restore copies cached artifacts back to the source directory, unless ctx
+// is cancelled first
+func (c *Cache) Restore(ctx context.Context, entry *Entry, destDir string) error {
 	if !entry.Success || len(entry.Outputs) == 0 {
 		return fmt.Errorf("cannot restore failed build or build with no outputs")
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Restore source-specific artifacts
+	// Check the cached copies before touching the destination, so a corrupt
+	// or truncated artifact is never restored
 	artifactDir := c.artifactDir(entry.Hash)
-	if err := RestoreArtifacts(artifactDir, destDir, entry.Outputs); err != nil {
+	if err := verifyOutputs(artifactDir, entry.Outputs); err != nil {
+		return err
+	}
+
+	if err := RestoreArtifacts(artifactDir, destDir, outputPaths(entry.Outputs)); err != nil {
 		return err
 	}
 
@@ -228,7 +507,7 @@ func (c *Cache) Restore(entry *Entry, destDir string) error {
 	if err := c.restoreSharedFiles(destDir); err != nil {
 		// Don't fail if shared files restoration fails - they might already exist
 		// or will be recreated on next full compile
-		fmt.Fprintf(os.Stderr, "Warning: Failed to restore shared files: %v\n", err)
+		logger.Warnf("Failed to restore shared files: %v", err)
 	}
 
 	return nil
@@ -288,8 +567,18 @@ func checkSharedFilesExist(splsWorkDir string) (bool, error) {
 
 // Clear removes all cache entries and artifacts
 func (c *Cache) Clear() error {
-	// Clear BoltDB
+	if c.mode != ModeReadWrite {
+		return fmt.Errorf("cannot clear cache in %s mode", c.mode)
+	}
+
+	// Clear BoltDB, including the remembered file hashes
 	err := c.db.Update(func(tx *bbolt.Tx) error {
+		if tx.Bucket([]byte(statsBucket)) != nil {
+			if err := tx.DeleteBucket([]byte(statsBucket)); err != nil {
+				return err
+			}
+		}
+
 		return tx.DeleteBucket([]byte(bucketName))
 	})
 	if err != nil {
@@ -298,8 +587,11 @@ func (c *Cache) Clear() error {
 
 	// Recreate bucket
 	err = c.db.Update(func(tx *bbolt.Tx) error {
-		_, err := tx.CreateBucket([]byte(bucketName))
-		return err
+		if _, err := tx.CreateBucket([]byte(bucketName)); err != nil {
+			return err
+		}
+
+		return writeTotals(tx, 0, 0)
 	})
 	if err != nil {
 		return err
@@ -314,36 +606,81 @@ func (c *Cache) Clear() error {
 	return nil
 }
 
-// Stats returns cache statistics
+// Stats returns the number of entries and the total size of their artifacts,
+// from totals kept up to date as entries are stored and evicted. The first
+// call on a cache without totals calculates them with Recalculate
 func (c *Cache) Stats() (int, int64, error) {
-	var count int
-	var totalSize int64
+	var entries, size int64
+	var ok bool
 
 	err := c.db.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte(bucketName))
-
-		count = b.Stats().KeyN
+		entries, size, ok = readTotals(tx)
 		return nil
 	})
 	if err != nil {
 		return 0, 0, err
 	}
 
-	// Calculate total artifact size
-	artifactsDir := filepath.Join(c.root, "artifacts")
-	_ = filepath.Walk(artifactsDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip errors
-		}
+	if !ok {
+		return c.Recalculate()
+	}
 
-		if !info.IsDir() {
-			totalSize += info.Size()
-		}
+	return int(entries), size, nil
+}
+
+// shortHash abbreviates a cache hash for log output
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+
+	return hash
+}
+
+// storeArtifacts copies a build's outputs into the artifact directory for
+// hash. They are copied to a temporary directory and renamed into place, so
+// the artifact directory only ever holds a complete build
+func (c *Cache) storeArtifacts(hash, sourceDir string, outputs []Output) error {
+	artifactDir := c.artifactDir(hash)
 
+	// Storing the same build again keeps the artifacts already in place
+	if verifyOutputs(artifactDir, outputs) == nil {
 		return nil
-	})
+	}
+
+	parent := filepath.Dir(artifactDir)
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.MkdirTemp(parent, "."+hash+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(tmp)
+
+	if err := CopyArtifacts(sourceDir, tmp, outputPaths(outputs)); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(artifactDir); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, artifactDir)
+}
+
+// hasArtifacts reports whether every output of an entry is in the cache
+func (c *Cache) hasArtifacts(entry *Entry) bool {
+	artifactDir := c.artifactDir(entry.Hash)
+	for _, o := range entry.Outputs {
+		if _, err := os.Stat(filepath.Join(artifactDir, o.Path)); err != nil {
+			return false
+		}
+	}
 
-	return count, totalSize, nil
+	return true
 }
 
 // artifactDir returns the directory path for a given cache hash
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -214,16 +215,16 @@ func TestCache_StoreAndGet(t *testing.T) {
 	}
 
 	// Cache miss initially
-	entry, err := cache.Get(sourceFile, cfg)
+	entry, err := cache.Get(context.Background(), sourceFile, cfg)
 	require.NoError(t, err)
 	assert.Nil(t, entry, "Should be cache miss initially")
 
 	// Store in cache
-	err = cache.Store(sourceFile, cfg, true)
+	err = cache.Store(context.Background(), sourceFile, cfg, true)
 	require.NoError(t, err)
 
 	// Cache hit now
-	entry, err = cache.Get(sourceFile, cfg)
+	entry, err = cache.Get(context.Background(), sourceFile, cfg)
 	require.NoError(t, err)
 	require.NotNil(t, entry, "Should be cache hit after storing")
 
@@ -291,16 +292,16 @@ func TestCache_Restore(t *testing.T) {
 		UserFolders: []string{},
 	}
 
-	err = cache.Store(sourceFile, cfg, true)
+	err = cache.Store(context.Background(), sourceFile, cfg, true)
 	require.NoError(t, err)
 
 	// Get entry
-	entry, err := cache.Get(sourceFile, cfg)
+	entry, err := cache.Get(context.Background(), sourceFile, cfg)
 	require.NoError(t, err)
 	require.NotNil(t, entry)
 
 	// Restore to different directory
-	err = cache.Restore(entry, restoreDir)
+	err = cache.Restore(context.Background(), entry, restoreDir)
 	require.NoError(t, err)
 
 	// Verify .ush file was restored
@@ -346,11 +347,11 @@ func TestCache_Clear(t *testing.T) {
 
 	cfg := &config.Config{Target: "234"}
 
-	err = cache.Store(sourceFile, cfg, true)
+	err = cache.Store(context.Background(), sourceFile, cfg, true)
 	require.NoError(t, err)
 
 	// Verify entry exists
-	entry, err := cache.Get(sourceFile, cfg)
+	entry, err := cache.Get(context.Background(), sourceFile, cfg)
 	require.NoError(t, err)
 	assert.NotNil(t, entry)
 
@@ -359,7 +360,7 @@ func TestCache_Clear(t *testing.T) {
 	require.NoError(t, err)
 
 	// Verify entry is gone
-	entry, err = cache.Get(sourceFile, cfg)
+	entry, err = cache.Get(context.Background(), sourceFile, cfg)
 	require.NoError(t, err)
 	assert.Nil(t, entry, "Cache should be empty after clear")
 
@@ -396,7 +397,7 @@ func TestCache_Stats(t *testing.T) {
 		require.NoError(t, err)
 
 		cfg := &config.Config{Target: "234"}
-		err = cache.Store(sourceFile, cfg, true)
+		err = cache.Store(context.Background(), sourceFile, cfg, true)
 		require.NoError(t, err)
 	}
 
@@ -442,7 +443,7 @@ func TestCache_DifferentTargets(t *testing.T) {
 		require.NoError(t, err)
 
 		// Store in cache
-		err = cache.Store(sourceFile, cfg, true)
+		err = cache.Store(context.Background(), sourceFile, cfg, true)
 		require.NoError(t, err)
 
 		// Get hash for this target
@@ -451,7 +452,7 @@ func TestCache_DifferentTargets(t *testing.T) {
 		hashes[target] = hash
 
 		// Verify we can retrieve the entry
-		entry, err := cache.Get(sourceFile, cfg)
+		entry, err := cache.Get(context.Background(), sourceFile, cfg)
 		require.NoError(t, err)
 		require.NotNil(t, entry, "Should find cache entry for target %s", target)
 		assert.Equal(t, target, entry.Target)
@@ -508,7 +509,7 @@ func TestCache_SharedFiles_IncrementalCaching(t *testing.T) {
 	}
 
 	cfg2 := &config.Config{Target: "2", UserFolders: []string{}}
-	err = cache.Store(sourceFile, cfg2, true)
+	err = cache.Store(context.Background(), sourceFile, cfg2, true)
 	require.NoError(t, err)
 
 	// Verify Version.ini was cached as shared file
@@ -543,7 +544,7 @@ func TestCache_SharedFiles_IncrementalCaching(t *testing.T) {
 	}
 
 	cfg3 := &config.Config{Target: "3", UserFolders: []string{}}
-	err = cache.Store(sourceFile, cfg3, true)
+	err = cache.Store(context.Background(), sourceFile, cfg3, true)
 	require.NoError(t, err)
 
 	// Verify all shared files are now cached (Version.ini + 5 series3 files = 6 total)
@@ -605,16 +606,16 @@ func TestCache_SharedFiles_Restoration(t *testing.T) {
 	defer cache.Close()
 
 	cfg := &config.Config{Target: "3", UserFolders: []string{}}
-	err = cache.Store(sourceFile, cfg, true)
+	err = cache.Store(context.Background(), sourceFile, cfg, true)
 	require.NoError(t, err)
 
 	// Get entry
-	entry, err := cache.Get(sourceFile, cfg)
+	entry, err := cache.Get(context.Background(), sourceFile, cfg)
 	require.NoError(t, err)
 	require.NotNil(t, entry)
 
 	// Restore to different directory
-	err = cache.Restore(entry, restoreDir)
+	err = cache.Restore(context.Background(), entry, restoreDir)
 	require.NoError(t, err)
 
 	// Verify source-specific files were restored
@@ -664,7 +665,7 @@ func TestCache_MixedTargets_Isolation(t *testing.T) {
 	require.NoError(t, err)
 
 	cfg2 := &config.Config{Target: "2", UserFolders: []string{}}
-	err = cache.Store(sourceFile, cfg2, true)
+	err = cache.Store(context.Background(), sourceFile, cfg2, true)
 	require.NoError(t, err)
 
 	// Build for series3 only
@@ -673,7 +674,7 @@ func TestCache_MixedTargets_Isolation(t *testing.T) {
 	require.NoError(t, err)
 
 	cfg3 := &config.Config{Target: "3", UserFolders: []string{}}
-	err = cache.Store(sourceFile, cfg3, true)
+	err = cache.Store(context.Background(), sourceFile, cfg3, true)
 	require.NoError(t, err)
 
 	// Build for series2+3 mixed
@@ -688,7 +689,7 @@ func TestCache_MixedTargets_Isolation(t *testing.T) {
 	}
 
 	cfg23 := &config.Config{Target: "23", UserFolders: []string{}}
-	err = cache.Store(sourceFile, cfg23, true)
+	err = cache.Store(context.Background(), sourceFile, cfg23, true)
 	require.NoError(t, err)
 
 	// Verify all three builds created separate cache entries
@@ -701,17 +702,17 @@ func TestCache_MixedTargets_Isolation(t *testing.T) {
 	assert.NotEqual(t, hash3, hash23, "Series3 and Series23 should have different hashes")
 
 	// Verify we can retrieve each entry independently
-	entry2, err := cache.Get(sourceFile, cfg2)
+	entry2, err := cache.Get(context.Background(), sourceFile, cfg2)
 	require.NoError(t, err)
 	require.NotNil(t, entry2)
 	assert.Equal(t, "2", entry2.Target)
 
-	entry3, err := cache.Get(sourceFile, cfg3)
+	entry3, err := cache.Get(context.Background(), sourceFile, cfg3)
 	require.NoError(t, err)
 	require.NotNil(t, entry3)
 	assert.Equal(t, "3", entry3.Target)
 
-	entry23, err := cache.Get(sourceFile, cfg23)
+	entry23, err := cache.Get(context.Background(), sourceFile, cfg23)
 	require.NoError(t, err)
 	require.NotNil(t, entry23)
 	assert.Equal(t, "23", entry23.Target)
@@ -751,7 +752,7 @@ func TestCache_SharedFiles_NotDuplicated(t *testing.T) {
 
 	// Store first build
 	cfg1 := &config.Config{Target: "3", UserFolders: []string{}}
-	err = cache.Store(sourceFile, cfg1, true)
+	err = cache.Store(context.Background(), sourceFile, cfg1, true)
 	require.NoError(t, err)
 
 	// Verify shared file was cached
@@ -772,7 +773,7 @@ func TestCache_SharedFiles_NotDuplicated(t *testing.T) {
 
 	// Store second build with different target (should NOT overwrite cached shared file)
 	cfg2 := &config.Config{Target: "4", UserFolders: []string{}}
-	err = cache.Store(sourceFile, cfg2, true)
+	err = cache.Store(context.Background(), sourceFile, cfg2, true)
 	require.NoError(t, err)
 
 	// Verify cached shared file was NOT overwritten (should still have original content)
@@ -837,7 +838,7 @@ func TestCache_UshFiles_TargetSpecific(t *testing.T) {
 		cfg := &config.Config{Target: tt.target, UserFolders: []string{}}
 
 		// Store in cache
-		err = cache.Store(sourceFile, cfg, true)
+		err = cache.Store(context.Background(), sourceFile, cfg, true)
 		require.NoError(t, err)
 
 		// Remember what we cached
@@ -865,13 +866,13 @@ func TestCache_UshFiles_TargetSpecific(t *testing.T) {
 		cfg := &config.Config{Target: tt.target, UserFolders: []string{}}
 
 		// Get cache entry
-		entry, err := cache.Get(sourceFile, cfg)
+		entry, err := cache.Get(context.Background(), sourceFile, cfg)
 		require.NoError(t, err)
 		require.NotNil(t, entry, "Should have cache entry for target %s", tt.target)
 
 		// Restore to a clean directory
 		restoreDir := t.TempDir()
-		err = cache.Restore(entry, restoreDir)
+		err = cache.Restore(context.Background(), entry, restoreDir)
 		require.NoError(t, err)
 
 		// Verify the correct .ush file was restored
@@ -928,17 +929,17 @@ func TestCache_Restore_SkipsIdenticalFiles(t *testing.T) {
 	defer cache.Close()
 
 	cfg := &config.Config{Target: "3", UserFolders: []string{}}
-	err = cache.Store(sourceFile, cfg, true)
+	err = cache.Store(context.Background(), sourceFile, cfg, true)
 	require.NoError(t, err)
 
 	// Get entry
-	entry, err := cache.Get(sourceFile, cfg)
+	entry, err := cache.Get(context.Background(), sourceFile, cfg)
 	require.NoError(t, err)
 	require.NotNil(t, entry)
 
 	// First restoration (files don't exist) - should copy all files
 	restoreDir1 := t.TempDir()
-	err = cache.Restore(entry, restoreDir1)
+	err = cache.Restore(context.Background(), entry, restoreDir1)
 	require.NoError(t, err)
 
 	// Verify files were created
@@ -956,7 +957,7 @@ func TestCache_Restore_SkipsIdenticalFiles(t *testing.T) {
 	time.Sleep(10 * time.Millisecond)
 
 	// Second restoration (files already exist and are identical) - should skip copying
-	err = cache.Restore(entry, restoreDir1)
+	err = cache.Restore(context.Background(), entry, restoreDir1)
 	require.NoError(t, err)
 
 	// Verify file timestamp didn't change (file wasn't copied)
@@ -974,7 +975,7 @@ func TestCache_Restore_SkipsIdenticalFiles(t *testing.T) {
 
 	// Third restoration (file exists but differs) - should copy the modified file
 	time.Sleep(10 * time.Millisecond)
-	err = cache.Restore(entry, restoreDir1)
+	err = cache.Restore(context.Background(), entry, restoreDir1)
 	require.NoError(t, err)
 
 	// Verify file was restored (timestamp changed and content correct)
@@ -988,3 +989,84 @@ func TestCache_Restore_SkipsIdenticalFiles(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "content of test.dll", string(content), "Content should be restored correctly")
 }
+
+func TestCache_Store_RecordsLibraries(t *testing.T) {
+	sourceDir := t.TempDir()
+	sourceFile := filepath.Join(sourceDir, "test.usp")
+	driver := filepath.Join(sourceDir, "Driver.clz")
+
+	require.NoError(t, os.WriteFile(sourceFile, []byte("#USER_SIMPLSHARP_LIBRARY \"Driver\"\n#USER_LIBRARY \"helpers\"\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "helpers.usl"), []byte(""), 0o644))
+	require.NoError(t, os.WriteFile(driver, []byte("not really an assembly"), 0o644))
+
+	cache, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer cache.Close()
+
+	cfg := &config.Config{Target: "3"}
+	require.NoError(t, cache.Store(context.Background(), sourceFile, cfg, false))
+
+	entry, err := cache.Get(context.Background(), sourceFile, cfg)
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+
+	hash, err := HashFile(driver)
+	require.NoError(t, err)
+
+	// Only SIMPL# libraries are recorded; the version is unknown for a non-assembly
+	assert.Equal(t, []LibraryRef{{Path: driver, Hash: hash}}, entry.Libraries)
+
+	// Entries finds the entry by source file even after the library changes
+	require.NoError(t, os.WriteFile(driver, []byte("a newer build"), 0o644))
+	require.NoError(t, cache.Store(context.Background(), sourceFile, cfg, false))
+
+	entries, err := cache.Entries(sourceFile)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.NotEqual(t, entries[0].Libraries[0].Hash, entries[1].Libraries[0].Hash)
+	assert.False(t, entries[0].Timestamp.Before(entries[1].Timestamp))
+
+	others, err := cache.Entries(filepath.Join(sourceDir, "other.usp"))
+	require.NoError(t, err)
+	assert.Empty(t, others)
+}
+
+func TestCache_Store_InterruptedCopyRecordsNothing(t *testing.T) {
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	sourceDir := t.TempDir()
+	sourceFile := filepath.Join(sourceDir, "test.usp")
+	require.NoError(t, os.WriteFile(sourceFile, []byte("source"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(sourceDir, "SPlsWork"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "SPlsWork", "test.dll"), []byte("dll"), 0o644))
+
+	cfg := &config.Config{Target: "3"}
+
+	EnableFaultInjection(NewFaultInjector(1, 1))
+	err = c.Store(context.Background(), sourceFile, cfg, true)
+	EnableFaultInjection(nil)
+	require.Error(t, err)
+
+	entry, err := c.Get(context.Background(), sourceFile, cfg)
+	require.NoError(t, err)
+	assert.Nil(t, entry, "no entry should be recorded without its artifacts")
+
+	// Neither the artifacts nor the temporary copy are left behind
+	dirs, _ := os.ReadDir(filepath.Join(c.Dir(), "artifacts"))
+	assert.Empty(t, dirs)
+
+	require.NoError(t, c.Store(context.Background(), sourceFile, cfg, true))
+
+	entry, err = c.Get(context.Background(), sourceFile, cfg)
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+
+	// An entry whose artifacts have gone is a miss rather than a failed restore
+	require.NoError(t, os.Remove(filepath.Join(c.artifactDir(entry.Hash), "SPlsWork", "test.dll")))
+
+	entry, err = c.Get(context.Background(), sourceFile, cfg)
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
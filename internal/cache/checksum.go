@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrCorruptArtifact is returned when a cached artifact no longer matches the
+// checksum recorded when it was stored
+var ErrCorruptArtifact = errors.New("cached artifact is corrupt")
+
+// checksumOutputs records the SHA256 and size of each output in baseDir
+func checksumOutputs(baseDir string, paths []string) ([]Output, error) {
+	outputs := make([]Output, 0, len(paths))
+	for _, path := range paths {
+		sum, size, err := checksumFile(filepath.Join(baseDir, path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to checksum %s: %w", path, err)
+		}
+
+		outputs = append(outputs, Output{Path: path, SHA256: sum, Size: size})
+	}
+
+	return outputs, nil
+}
+
+// verifyOutputs checks each output in baseDir against its recorded checksum.
+// Outputs without one (from format 1 entries) are not checked
+func verifyOutputs(baseDir string, outputs []Output) error {
+	for _, o := range outputs {
+		if o.SHA256 == "" {
+			continue
+		}
+
+		sum, size, err := checksumFile(filepath.Join(baseDir, o.Path))
+		if err != nil {
+			return fmt.Errorf("failed to checksum %s: %w", o.Path, err)
+		}
+
+		if size != o.Size || sum != o.SHA256 {
+			return fmt.Errorf("%w: %s (%d bytes, sha256 %s; stored as %d bytes, sha256 %s)",
+				ErrCorruptArtifact, o.Path, size, shortHash(sum), o.Size, shortHash(o.SHA256))
+		}
+	}
+
+	return nil
+}
+
+// checksumFile returns the hex SHA256 and size of a file
+func checksumFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
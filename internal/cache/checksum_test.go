@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+func TestCache_StoreRecordsChecksums(t *testing.T) {
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	sourceFile := storeBuilt(t, c, "source")
+
+	entry, err := c.Get(context.Background(), sourceFile, &config.Config{Target: "3"})
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+
+	require.Len(t, entry.Outputs, 1)
+	assert.Equal(t, filepath.Join("SPlsWork", "test.dll"), entry.Outputs[0].Path)
+	assert.Equal(t, int64(100), entry.Outputs[0].Size)
+
+	sum, _, err := checksumFile(filepath.Join(filepath.Dir(sourceFile), "SPlsWork", "test.dll"))
+	require.NoError(t, err)
+	assert.Equal(t, sum, entry.Outputs[0].SHA256)
+}
+
+func TestCache_RestoreRejectsCorruptArtifacts(t *testing.T) {
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	sourceFile := storeBuilt(t, c, "source")
+
+	entry, err := c.Get(context.Background(), sourceFile, &config.Config{Target: "3"})
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+
+	// Truncate the cached copy, as a crash or disk fault might
+	cached := filepath.Join(c.artifactDir(entry.Hash), "SPlsWork", "test.dll")
+	require.NoError(t, os.WriteFile(cached, make([]byte, 50), 0o644))
+
+	destDir := t.TempDir()
+	err = c.Restore(context.Background(), entry, destDir)
+	assert.ErrorIs(t, err, ErrCorruptArtifact)
+	assert.Contains(t, err.Error(), "test.dll")
+
+	_, err = os.Stat(filepath.Join(destDir, "SPlsWork", "test.dll"))
+	assert.True(t, os.IsNotExist(err), "corrupt artifact should not be restored")
+}
+
+func TestOutput_UnmarshalLegacyPaths(t *testing.T) {
+	var entry Entry
+	require.NoError(t, json.Unmarshal([]byte(`{"version":1,"outputs":["SPlsWork/a.dll",{"path":"a.ush","sha256":"abc","size":3}]}`), &entry))
+
+	assert.Equal(t, []Output{
+		{Path: "SPlsWork/a.dll"},
+		{Path: "a.ush", SHA256: "abc", Size: 3},
+	}, entry.Outputs)
+
+	// Outputs without checksums are restored unchecked
+	assert.NoError(t, verifyOutputs(t.TempDir(), entry.Outputs[:1]))
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// compactTxSize is how many bytes of the database Compact copies per
+// transaction
+const compactTxSize = 64 * 1024
+
+// Compact rewrites the cache database into a fresh file holding only the
+// pages in use, since BoltDB files never shrink after entries are deleted,
+// and returns the file's size before and after
+func (c *Cache) Compact() (before, after int64, err error) {
+	if c.mode != ModeReadWrite {
+		return 0, 0, fmt.Errorf("cannot compact cache in %s mode", c.mode)
+	}
+
+	dbPath := c.db.Path()
+	info, err := os.Stat(dbPath)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	before = info.Size()
+
+	tmpPath := dbPath + ".compact"
+	defer os.Remove(tmpPath)
+
+	dst, err := bbolt.Open(tmpPath, 0o600, &bbolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create compacted database: %w", err)
+	}
+
+	if err := bbolt.Compact(dst, c.db, compactTxSize); err != nil {
+		dst.Close()
+		return 0, 0, fmt.Errorf("failed to compact cache database: %w", err)
+	}
+
+	if err := dst.Close(); err != nil {
+		return 0, 0, fmt.Errorf("failed to write compacted database: %w", err)
+	}
+
+	// The database is closed to replace it, so the rename works on Windows,
+	// and reopened from the compacted file
+	if err := c.db.Close(); err != nil {
+		return 0, 0, err
+	}
+
+	renameErr := retryLocked(dbPath, func() error {
+		return os.Rename(tmpPath, dbPath)
+	})
+
+	if c.db, err = bbolt.Open(dbPath, 0o600, &bbolt.Options{Timeout: 1 * time.Second}); err != nil {
+		c.db = nil
+		return 0, 0, fmt.Errorf("failed to reopen cache database: %w", err)
+	}
+
+	if renameErr != nil {
+		return 0, 0, fmt.Errorf("failed to replace %s: %w", filepath.Base(dbPath), renameErr)
+	}
+
+	if info, err = os.Stat(dbPath); err != nil {
+		return 0, 0, err
+	}
+
+	return before, info.Size(), nil
+}
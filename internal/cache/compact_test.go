@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+func TestCache_Compact(t *testing.T) {
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	var kept string
+	for i := range 200 {
+		kept = storeBuilt(t, c, fmt.Sprintf("content %d", i))
+	}
+
+	removed, err := c.Prune(100, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 199, removed)
+
+	before, after, err := c.Compact()
+	require.NoError(t, err)
+	assert.Less(t, after, before)
+
+	// The cache is still usable, with the entry that was kept
+	entry, err := c.Get(context.Background(), kept, &config.Config{Target: "3"})
+	require.NoError(t, err)
+	assert.NotNil(t, entry)
+
+	count, _, err := c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	storeBuilt(t, c, "after compaction")
+	count, _, err = c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestCache_Compact_ReadOnly(t *testing.T) {
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	c.SetReadOnly()
+
+	_, _, err = c.Compact()
+	assert.Error(t, err)
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"hash"
+
+	"github.com/zeebo/xxh3"
+	"lukechampine.com/blake3"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// newDigest returns a hash for one of the cache.hash algorithms ("" is SHA256)
+func newDigest(algorithm string) hash.Hash {
+	switch algorithm {
+	case config.HashBLAKE3:
+		return blake3.New(32, nil)
+	case config.HashXXH3:
+		return &xxh3128{xxh3.New()}
+	default:
+		return sha256.New()
+	}
+}
+
+// algorithmName returns the name of an algorithm as recorded in cache entries
+func algorithmName(algorithm string) string {
+	if algorithm == "" {
+		return config.HashSHA256
+	}
+
+	return algorithm
+}
+
+// xxh3128 is XXH3 with a 128-bit sum: 64 bits are too few for cache keys
+type xxh3128 struct {
+	*xxh3.Hasher
+}
+
+func (h *xxh3128) Size() int {
+	return 16
+}
+
+func (h *xxh3128) Sum(b []byte) []byte {
+	sum := h.Sum128().Bytes()
+	return append(b, sum[:]...)
+}
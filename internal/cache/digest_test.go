@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+func TestHashFileWith(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib.clz")
+	require.NoError(t, os.WriteFile(path, []byte("assembly"), 0o644))
+
+	sizes := map[string]int{
+		"":                64,
+		config.HashSHA256: 64,
+		config.HashBLAKE3: 64,
+		config.HashXXH3:   32,
+	}
+
+	seen := map[string]string{}
+	for algorithm, size := range sizes {
+		hash, err := HashFileWith(algorithm, path)
+		require.NoError(t, err)
+		assert.Len(t, hash, size, algorithm)
+
+		seen[algorithmName(algorithm)] = hash
+	}
+
+	assert.Len(t, seen, 3)
+
+	sha, err := HashFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, seen[config.HashSHA256], sha)
+}
+
+func TestHashSource_Algorithm(t *testing.T) {
+	sourceFile := filepath.Join(t.TempDir(), "test.usp")
+	require.NoError(t, os.WriteFile(sourceFile, []byte("test content"), 0o644))
+
+	cfg := &config.Config{Target: "34"}
+	sha, err := HashSource(sourceFile, cfg)
+	require.NoError(t, err)
+
+	// sha256 keys are the same whether or not the algorithm is set
+	cfg.Cache.Hash = config.HashSHA256
+	explicit, err := HashSource(sourceFile, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, sha, explicit)
+
+	cfg.Cache.Hash = config.HashBLAKE3
+	blake, err := HashSource(sourceFile, cfg)
+	require.NoError(t, err)
+	assert.NotEqual(t, sha, blake)
+}
+
+func TestCache_MixedAlgorithms(t *testing.T) {
+	sourceDir := t.TempDir()
+	sourceFile := filepath.Join(sourceDir, "test.usp")
+	require.NoError(t, os.WriteFile(sourceFile, []byte("test content"), 0o644))
+
+	cache, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer cache.Close()
+
+	xxh3 := &config.Config{Target: "3", Cache: config.CacheConfig{Hash: config.HashXXH3}}
+	require.NoError(t, cache.Store(context.Background(), sourceFile, xxh3, false))
+
+	entry, err := cache.Get(context.Background(), sourceFile, xxh3)
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	assert.Equal(t, config.HashXXH3, entry.HashAlgorithm)
+
+	// An entry is only a hit for the algorithm it was stored with
+	key, err := HashSource(sourceFile, xxh3)
+	require.NoError(t, err)
+
+	entry.HashAlgorithm = config.HashBLAKE3
+	data, err := json.Marshal(entry)
+	require.NoError(t, err)
+	require.NoError(t, cache.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(bucketName)).Put([]byte(key), data)
+	}))
+
+	entry, err = cache.Get(context.Background(), sourceFile, xxh3)
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
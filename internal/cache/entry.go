@@ -1,9 +1,16 @@
 package cache
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Entry represents a cached build result
 type Entry struct {
+	// Version is the cache format version the entry was written with
+	// Entries from before versioning was introduced have version 0
+	Version int `json:"version"`
+
 	// Hash is the unique identifier for this cache entry
 	// Computed from: source file content + target + compiler version + user folders
 	Hash string `json:"hash"`
@@ -14,7 +21,8 @@ type Entry struct {
 	// Target is the compilation target (e.g., "234")
 	Target string `json:"target"`
 
-	// CompilerVersion is the version of SPlusCC.exe used
+	// CompilerVersion is the version of SPlusCC.exe used for each series
+	// (e.g. "series3=4.3100.0.0"), empty when it couldn't be read
 	CompilerVersion string `json:"compiler_version"`
 
 	// UserFolders are the include paths used during compilation
@@ -24,9 +32,72 @@ type Entry struct {
 	Timestamp time.Time `json:"timestamp"`
 
 	// Outputs lists the compiled artifact files with their relative locations
-	// Format: "SPlsWork/example.dll" or "example.ush" (adjacent to source)
-	Outputs []string `json:"outputs"`
+	// and checksums
+	Outputs []Output `json:"outputs"`
 
 	// Success indicates if the build was successful
 	Success bool `json:"success"`
+
+	// Libraries are the SIMPL# libraries (.clz) the file was compiled against
+	Libraries []LibraryRef `json:"libraries,omitempty"`
+
+	// CompileTime is how long the compile took, i.e. the time a cache hit saves
+	// (zero for entries stored without it)
+	CompileTime time.Duration `json:"compile_time,omitempty"`
+
+	// HashAlgorithm is the cache.hash algorithm of Hash and the library hashes
+	// (empty for entries from before it was configurable, which used sha256)
+	HashAlgorithm string `json:"hash_algorithm,omitempty"`
+
+	// Size is the total size of the cached artifacts in bytes (zero for
+	// failed builds and entries stored before sizes were recorded)
+	Size int64 `json:"size,omitempty"`
+}
+
+// LibraryRef identifies the exact SIMPL# library a build used
+type LibraryRef struct {
+	// Path is where the library was resolved at build time
+	Path string `json:"path"`
+
+	// Hash is the hash of the library file, with the entry's HashAlgorithm
+	Hash string `json:"hash"`
+
+	// Version is the assembly version, empty if it could not be read
+	Version string `json:"version,omitempty"`
+}
+
+// Output is a compiled artifact of a cache entry
+type Output struct {
+	// Path is relative to the source directory: "SPlsWork/example.dll" or
+	// "example.ush" (adjacent to source)
+	Path string `json:"path"`
+
+	// SHA256 is the hex SHA256 of the artifact, checked before it is restored
+	// (empty for failed builds and entries from format 1)
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Size is the artifact size in bytes
+	Size int64 `json:"size,omitempty"`
+}
+
+// UnmarshalJSON reads an output, accepting the bare paths of format 1 entries
+func (o *Output) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*o = Output{Path: path}
+		return nil
+	}
+
+	type output Output
+	return json.Unmarshal(data, (*output)(o))
+}
+
+// outputPaths returns the paths of outputs
+func outputPaths(outputs []Output) []string {
+	paths := make([]string, len(outputs))
+	for i, o := range outputs {
+		paths[i] = o.Path
+	}
+
+	return paths
 }
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+func TestFaultInjector_Rates(t *testing.T) {
+	never := NewFaultInjector(0, 1)
+	always := NewFaultInjector(1, 1)
+
+	for i := 0; i < 100; i++ {
+		assert.False(t, never.trigger())
+		assert.True(t, always.trigger())
+	}
+
+	// A nil injector never triggers
+	var disabled *FaultInjector
+	assert.False(t, disabled.trigger())
+	assert.NoError(t, disabled.failCopy())
+}
+
+func TestFaultInjector_FailsCopies(t *testing.T) {
+	EnableFaultInjection(NewFaultInjector(1, 1))
+	defer EnableFaultInjection(nil)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.dll")
+	require.NoError(t, os.WriteFile(src, []byte("content"), 0o644))
+
+	err := copyFile(src, filepath.Join(dir, "dst.dll"))
+	assert.True(t, errors.Is(err, ErrInjectedFault))
+}
+
+func TestFaultInjector_TruncatesWrites(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.dll")
+	require.NoError(t, os.WriteFile(path, []byte("0123456789"), 0o644))
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	require.NoError(t, err)
+
+	err = NewFaultInjector(1, 1).truncateWrite(f)
+	f.Close()
+	assert.True(t, errors.Is(err, ErrInjectedFault))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "01234", string(content))
+}
+
+func TestFaultInjector_StoreSurfacesFaults(t *testing.T) {
+	cacheDir := t.TempDir()
+	sourceDir := t.TempDir()
+
+	sourceFile := filepath.Join(sourceDir, "example.usp")
+	require.NoError(t, os.WriteFile(sourceFile, []byte("source"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "example.ush"), []byte("header"), 0o644))
+
+	c, err := New(cacheDir)
+	require.NoError(t, err)
+	defer c.Close()
+
+	EnableFaultInjection(NewFaultInjector(1, 1))
+	defer EnableFaultInjection(nil)
+
+	cfg := &config.Config{Target: "34"}
+	err = c.Store(context.Background(), sourceFile, cfg, true)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInjectedFault))
+}
+
+func TestFaultInjectionAllowed(t *testing.T) {
+	t.Setenv(FaultInjectionEnv, "")
+	assert.False(t, FaultInjectionAllowed())
+
+	t.Setenv(FaultInjectionEnv, "1")
+	assert.True(t, FaultInjectionAllowed())
+}
+
+func TestValidateFaultRate(t *testing.T) {
+	for _, rate := range []float64{0, 0.25, 1} {
+		assert.NoError(t, ValidateFaultRate(rate))
+	}
+
+	for _, rate := range []float64{-0.1, 1.5, math.NaN()} {
+		assert.ErrorContains(t, ValidateFaultRate(rate), "not between 0 and 1")
+	}
+}
@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// FormatVersion is the version of the cache entry schema written by this build.
+	// Bump it whenever Entry changes in a way older readers cannot understand
+	//
+	// Version 2 records a checksum and size with each output
+	FormatVersion = 2
+
+	// MinReadVersion is the oldest client format version that can still read
+	// entries written by this build. Older clients fall back to bypassing the cache
+	MinReadVersion = 2
+
+	// metaBucketName is the BoltDB bucket holding cache format metadata
+	metaBucketName = "meta"
+)
+
+var (
+	formatVersionKey  = []byte("format_version")
+	minReadVersionKey = []byte("min_read_version")
+)
+
+// Mode describes how a cache may be used after format negotiation
+type Mode int
+
+const (
+	// ModeReadWrite means the cache format is fully supported
+	ModeReadWrite Mode = iota
+	// ModeReadOnly means entries can be read but must not be written,
+	// because a newer client owns the format
+	ModeReadOnly
+	// ModeBypass means the cache format is not understood and must be ignored
+	ModeBypass
+)
+
+// String returns a human readable name for the mode
+func (m Mode) String() string {
+	switch m {
+	case ModeReadWrite:
+		return "read-write"
+	case ModeReadOnly:
+		return "read-only"
+	case ModeBypass:
+		return "bypass"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+}
+
+// NegotiateMode decides how a client at clientVersion may use a cache whose
+// stored format is storedVersion, readable by clients at minReadVersion or later.
+// A storedVersion of 0 means the cache has no recorded format yet
+func NegotiateMode(clientVersion, storedVersion, minReadVersion int) Mode {
+	switch {
+	case storedVersion <= clientVersion:
+		return ModeReadWrite
+	case clientVersion >= minReadVersion:
+		return ModeReadOnly
+	default:
+		return ModeBypass
+	}
+}
+
+// negotiateFormat reads the stored format metadata, upgrades it when this
+// client is newer, and returns the resulting access mode
+func negotiateFormat(db *bbolt.DB) (Mode, error) {
+	mode := ModeReadWrite
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(metaBucketName))
+		if err != nil {
+			return err
+		}
+
+		stored := readVersion(b, formatVersionKey)
+		minRead := readVersion(b, minReadVersionKey)
+
+		mode = NegotiateMode(FormatVersion, stored, minRead)
+		if mode != ModeReadWrite || stored == FormatVersion {
+			return nil
+		}
+
+		// Record our (newer) format so older clients know how to behave
+		if err := writeVersion(b, formatVersionKey, FormatVersion); err != nil {
+			return err
+		}
+
+		return writeVersion(b, minReadVersionKey, MinReadVersion)
+	})
+
+	return mode, err
+}
+
+func readVersion(b *bbolt.Bucket, key []byte) int {
+	data := b.Get(key)
+	if len(data) != 8 {
+		return 0
+	}
+
+	return int(binary.BigEndian.Uint64(data))
+}
+
+func writeVersion(b *bbolt.Bucket, key []byte, version int) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(version))
+
+	return b.Put(key, data)
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+func TestNegotiateMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  int
+		stored  int
+		minRead int
+		want    Mode
+	}{
+		{"fresh cache", 1, 0, 0, ModeReadWrite},
+		{"same version", 1, 1, 1, ModeReadWrite},
+		{"older cache", 2, 1, 1, ModeReadWrite},
+		{"newer cache readable by us", 1, 2, 1, ModeReadOnly},
+		{"newer cache not readable by us", 1, 3, 2, ModeBypass},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NegotiateMode(tt.client, tt.stored, tt.minRead))
+		})
+	}
+}
+
+// setStoredFormat overwrites the format metadata of a cache directory
+func setStoredFormat(t *testing.T, cacheDir string, version, minRead int) {
+	db, err := bbolt.Open(filepath.Join(cacheDir, "cache.db"), 0o600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(metaBucketName))
+		if err != nil {
+			return err
+		}
+
+		if err := writeVersion(b, formatVersionKey, version); err != nil {
+			return err
+		}
+
+		return writeVersion(b, minReadVersionKey, minRead)
+	})
+	require.NoError(t, err)
+}
+
+func TestCache_FormatNegotiation(t *testing.T) {
+	sourceDir := t.TempDir()
+	sourceFile := filepath.Join(sourceDir, "example.usp")
+	require.NoError(t, os.WriteFile(sourceFile, []byte("source"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "example.ush"), []byte("header"), 0o644))
+
+	cfg := &config.Config{Target: "34"}
+
+	t.Run("new cache records format and is writable", func(t *testing.T) {
+		cacheDir := t.TempDir()
+
+		c, err := New(cacheDir)
+		require.NoError(t, err)
+		assert.Equal(t, ModeReadWrite, c.Mode())
+
+		require.NoError(t, c.Store(context.Background(), sourceFile, cfg, true))
+
+		entry, err := c.Get(context.Background(), sourceFile, cfg)
+		require.NoError(t, err)
+		require.NotNil(t, entry)
+		assert.Equal(t, FormatVersion, entry.Version)
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("newer compatible cache is read-only", func(t *testing.T) {
+		cacheDir := t.TempDir()
+
+		c, err := New(cacheDir)
+		require.NoError(t, err)
+		require.NoError(t, c.Store(context.Background(), sourceFile, cfg, true))
+		require.NoError(t, c.Close())
+
+		setStoredFormat(t, cacheDir, FormatVersion+1, FormatVersion)
+
+		c, err = New(cacheDir)
+		require.NoError(t, err)
+		defer c.Close()
+		assert.Equal(t, ModeReadOnly, c.Mode())
+
+		// Existing entries can still be read
+		entry, err := c.Get(context.Background(), sourceFile, cfg)
+		require.NoError(t, err)
+		assert.NotNil(t, entry)
+
+		// But nothing is written and the cache can't be cleared
+		require.NoError(t, c.Store(context.Background(), sourceFile, &config.Config{Target: "3"}, true))
+		entry, err = c.Get(context.Background(), sourceFile, &config.Config{Target: "3"})
+		require.NoError(t, err)
+		assert.Nil(t, entry)
+
+		assert.Error(t, c.Clear())
+	})
+
+	t.Run("incompatible cache is bypassed", func(t *testing.T) {
+		cacheDir := t.TempDir()
+
+		c, err := New(cacheDir)
+		require.NoError(t, err)
+		require.NoError(t, c.Store(context.Background(), sourceFile, cfg, true))
+		require.NoError(t, c.Close())
+
+		setStoredFormat(t, cacheDir, FormatVersion+2, FormatVersion+1)
+
+		c, err = New(cacheDir)
+		require.NoError(t, err)
+		defer c.Close()
+		assert.Equal(t, ModeBypass, c.Mode())
+
+		entry, err := c.Get(context.Background(), sourceFile, cfg)
+		require.NoError(t, err)
+		assert.Nil(t, entry)
+	})
+}
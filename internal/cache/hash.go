@@ -1,25 +1,38 @@
 package cache
 
 import (
-	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 
 	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/deps"
+	"github.com/Norgate-AV/spc/internal/utils"
 )
 
 // HashSource creates a unique hash for a source file and its build configuration
 // The hash is based on:
 // - Source file content
 // - Target series
-// - Compiler version (TODO: detect from SPlusCC.exe)
 // - User folders (sorted for consistency)
+// - Extra compiler arguments (in order, only when present)
+// - Container image, when the compiler runs in a container
+// - Version of the compiler of each series built, when it can be read
+// - Content of the user libraries and SIMPL# libraries the file uses,
+// directly or through other libraries (only when present)
 func HashSource(sourceFile string, cfg *config.Config) (string, error) {
-	h := sha256.New()
+	return NewHasher(cfg.Cache.Hash, 0).HashSource(sourceFile, cfg)
+}
+
+// HashSource computes the cache key of a source file like the HashSource
+// function, with the hasher's algorithm, reusing the hashes of libraries
+// already hashed
+func (h *Hasher) HashSource(sourceFile string, cfg *config.Config) (string, error) {
+	digest := newDigest(h.algorithm)
 
 	// Hash source file content
 	f, err := os.Open(sourceFile)
@@ -29,28 +42,251 @@ func HashSource(sourceFile string, cfg *config.Config) (string, error) {
 
 	defer f.Close()
 
-	if _, err := io.Copy(h, f); err != nil {
+	if _, err := io.Copy(digest, f); err != nil {
 		return "", fmt.Errorf("failed to hash source file: %w", err)
 	}
 
 	// Hash target
-	h.Write([]byte(cfg.Target))
+	digest.Write([]byte(cfg.Target))
 
 	// Hash user folders (sorted for consistency)
 	sortedFolders := make([]string, len(cfg.UserFolders))
 	copy(sortedFolders, cfg.UserFolders)
 	sort.Strings(sortedFolders)
-	h.Write([]byte(strings.Join(sortedFolders, "|")))
+	digest.Write([]byte(strings.Join(sortedFolders, "|")))
 
-	// TODO: Hash compiler version
-	// For now, we assume compiler version doesn't change
-	// In future, detect version from SPlusCC.exe
+	// Hash extra compiler arguments. Skipped when there are none so keys
+	// from before extra_args existed stay valid
+	if len(cfg.ExtraArgs) > 0 {
+		digest.Write([]byte("\x00extra_args\x00"))
+		digest.Write([]byte(strings.Join(cfg.ExtraArgs, "\x00")))
+	}
 
-	return hex.EncodeToString(h.Sum(nil)), nil
+	// Hash the container image, which determines the compiler used. Skipped
+	// for local builds so their keys stay valid
+	if image := containerImage(cfg); image != "" {
+		digest.Write([]byte("\x00image\x00"))
+		digest.Write([]byte(image))
+	}
+
+	// Hash dependencies, so a changed library invalidates every file using it.
+	// Skipped when there are none so keys of standalone files stay valid
+	dependencies, err := h.HashDependencies(sourceFile, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	if len(dependencies) > 0 {
+		parts := make([]string, 0, len(dependencies))
+		for _, d := range dependencies {
+			parts = append(parts, d.keyPart())
+		}
+
+		sort.Strings(parts)
+		digest.Write([]byte("\x00dependencies\x00"))
+		digest.Write([]byte(strings.Join(parts, "\x00")))
+	}
+
+	// Hash the compiler versions, so upgrading SIMPL invalidates every file.
+	// Skipped when none can be read so keys stay valid where the compiler
+	// isn't installed
+	if versions := h.compilerVersions(cfg); len(versions) > 0 {
+		digest.Write([]byte("\x00compiler\x00"))
+		digest.Write([]byte(strings.Join(versions, "\x00")))
+	}
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// compilerVersions returns the version of the compiler of each series the
+// target builds for (e.g. "series3=4.3100.0.0"), leaving out compilers whose
+// version can't be read. Containers are left out too; their image identifies
+// the compiler
+func (h *Hasher) compilerVersions(cfg *config.Config) []string {
+	if cfg.Runner == config.RunnerDocker {
+		return nil
+	}
+
+	var versions []string
+	for _, series := range utils.ParseTarget(cfg.Target) {
+		if version := h.compilerVersion(cfg.CompilerFor(series)); version != "" {
+			versions = append(versions, series+"="+version)
+		}
+	}
+
+	return versions
 }
 
-// HashFile creates a hash of a file's content
+// containerImage returns the image the compiler runs in, or "" for local builds
+func containerImage(cfg *config.Config) string {
+	if cfg.Runner != config.RunnerDocker {
+		return ""
+	}
+
+	return cfg.Docker.Image
+}
+
+// Dependency is a library a source file depends on, as included in its cache key
+type Dependency struct {
+	// Name is the library file name (e.g. "helpers.usl")
+	Name string `json:"name"`
+
+	// Path is the resolved library path, empty when it could not be found
+	Path string `json:"path,omitempty"`
+
+	// Hash is the hash of the library content with the cache.hash algorithm,
+	// empty when it could not be found
+	Hash string `json:"hash,omitempty"`
+}
+
+// keyPart is the dependency as hashed into a cache key. The path is left out
+// so keys don't depend on where a checkout lives
+func (d Dependency) keyPart() string {
+	if d.Path == "" {
+		return "missing:" + d.Name
+	}
+
+	return d.Name + ":" + d.Hash
+}
+
+// HashDependencies resolves the user and SIMPL# libraries a source file uses,
+// directly or through other libraries, and hashes their content. Libraries
+// shipped with SIMPL are not included
+func HashDependencies(sourceFile string, cfg *config.Config) ([]Dependency, error) {
+	return NewHasher(cfg.Cache.Hash, 0).HashDependencies(sourceFile, cfg)
+}
+
+// HashDependencies resolves and hashes the libraries a source file uses like
+// the HashDependencies function, hashing them concurrently and reusing the
+// hashes of libraries already hashed
+func (h *Hasher) HashDependencies(sourceFile string, cfg *config.Config) ([]Dependency, error) {
+	g, err := deps.BuildGraph([]string{sourceFile}, &deps.Resolver{UserFolders: cfg.UserFolders}, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan dependencies: %w", err)
+	}
+
+	abs, err := filepath.Abs(sourceFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var dependencies []Dependency
+	var paths []string
+	var found []int
+
+	for _, n := range g.TransitiveDependencies(abs) {
+		if n.Kind == deps.NodeCrestron {
+			continue
+		}
+
+		if n.Missing {
+			dependencies = append(dependencies, Dependency{Name: n.Label})
+			continue
+		}
+
+		found = append(found, len(dependencies))
+		paths = append(paths, n.Path)
+		dependencies = append(dependencies, Dependency{Name: filepath.Base(n.Path), Path: n.Path})
+	}
+
+	hashes, err := h.HashFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, hash := range hashes {
+		dependencies[found[i]].Hash = hash
+	}
+
+	return dependencies, nil
+}
+
+// KeyInputs is a breakdown of the inputs that make up a cache key
+type KeyInputs struct {
+	// Key is the cache key computed by HashSource
+	Key string `json:"key"`
+
+	// SourceFile is the source file that was hashed
+	SourceFile string `json:"source_file"`
+
+	// SourceHash is the hash of the source file content
+	SourceHash string `json:"source_hash"`
+
+	// Target is the compilation target
+	Target string `json:"target"`
+
+	// UserFolders are the user folders in the order they are hashed
+	UserFolders []string `json:"user_folders"`
+
+	// UserFoldersDigest is the hash of the sorted user folders, as they are
+	// written into the key
+	UserFoldersDigest string `json:"user_folders_digest"`
+
+	// ExtraArgs are the extra compiler arguments, in the order they are hashed
+	ExtraArgs []string `json:"extra_args"`
+
+	// Dependencies are the libraries the file uses, with their content hashes
+	Dependencies []Dependency `json:"dependencies"`
+
+	// Image is the container image the compiler runs in (empty for local builds)
+	Image string `json:"image,omitempty"`
+
+	// Algorithm is the cache.hash algorithm of the key and the other hashes
+	Algorithm string `json:"algorithm"`
+
+	// CompilerVersion is the version of the compiler of each series included
+	// in the key (e.g. "series3=4.3100.0.0"), empty when none could be read
+	CompilerVersion string `json:"compiler_version"`
+}
+
+// ExplainKey computes the cache key for a source file along with each of its inputs
+func ExplainKey(sourceFile string, cfg *config.Config) (*KeyInputs, error) {
+	h := NewHasher(cfg.Cache.Hash, 0)
+
+	key, err := h.HashSource(sourceFile, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	dependencies, err := h.HashDependencies(sourceFile, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceHash, err := HashFileWith(h.Algorithm(), sourceFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash source file: %w", err)
+	}
+
+	sortedFolders := make([]string, len(cfg.UserFolders))
+	copy(sortedFolders, cfg.UserFolders)
+	sort.Strings(sortedFolders)
+
+	folderDigest := newDigest(h.Algorithm())
+	folderDigest.Write([]byte(strings.Join(sortedFolders, "|")))
+
+	return &KeyInputs{
+		Key:               key,
+		SourceFile:        sourceFile,
+		SourceHash:        sourceHash,
+		Target:            cfg.Target,
+		UserFolders:       sortedFolders,
+		UserFoldersDigest: hex.EncodeToString(folderDigest.Sum(nil)),
+		ExtraArgs:         cfg.ExtraArgs,
+		Dependencies:      dependencies,
+		Image:             containerImage(cfg),
+		Algorithm:         h.Algorithm(),
+		CompilerVersion:   strings.Join(h.compilerVersions(cfg), ", "),
+	}, nil
+}
+
+// HashFile creates a SHA256 hash of a file's content
 func HashFile(path string) (string, error) {
+	return HashFileWith(config.HashSHA256, path)
+}
+
+// HashFileWith hashes a file's content with one of the cache.hash algorithms
+func HashFileWith(algorithm, path string) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return "", err
@@ -58,7 +294,7 @@ func HashFile(path string) (string, error) {
 
 	defer f.Close()
 
-	h := sha256.New()
+	h := newDigest(algorithm)
 	if _, err := io.Copy(h, f); err != nil {
 		return "", err
 	}
@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+func TestExplainKey(t *testing.T) {
+	sourceFile := filepath.Join(t.TempDir(), "test.usp")
+	require.NoError(t, os.WriteFile(sourceFile, []byte("test content"), 0o644))
+
+	cfg := &config.Config{
+		Target:      "34",
+		UserFolders: []string{"/b", "/a"},
+	}
+
+	inputs, err := ExplainKey(sourceFile, cfg)
+	require.NoError(t, err)
+
+	key, err := HashSource(sourceFile, cfg)
+	require.NoError(t, err)
+
+	sourceHash, err := HashFile(sourceFile)
+	require.NoError(t, err)
+
+	assert.Equal(t, key, inputs.Key)
+	assert.Equal(t, sourceHash, inputs.SourceHash)
+	assert.Equal(t, "34", inputs.Target)
+	assert.Equal(t, []string{"/a", "/b"}, inputs.UserFolders)
+	assert.Len(t, inputs.UserFoldersDigest, 64)
+
+	// Folder order doesn't change the digest
+	reordered, err := ExplainKey(sourceFile, &config.Config{Target: "34", UserFolders: []string{"/a", "/b"}})
+	require.NoError(t, err)
+	assert.Equal(t, inputs.UserFoldersDigest, reordered.UserFoldersDigest)
+	assert.Equal(t, inputs.Key, reordered.Key)
+
+	// Hashes use the key's algorithm
+	xxh3 := &config.Config{Target: "34", UserFolders: []string{"/a", "/b"}, Cache: config.CacheConfig{Hash: config.HashXXH3}}
+	inputs, err = ExplainKey(sourceFile, xxh3)
+	require.NoError(t, err)
+
+	sourceHash, err = HashFileWith(config.HashXXH3, sourceFile)
+	require.NoError(t, err)
+	assert.Equal(t, sourceHash, inputs.SourceHash)
+	assert.Len(t, inputs.UserFoldersDigest, 32)
+}
+
+// fakeCompiler writes an executable whose version resource holds the given
+// file version
+func fakeCompiler(t *testing.T, major, minor uint16) string {
+	info := make([]byte, 16)
+	binary.LittleEndian.PutUint32(info, 0xfeef04bd)
+	binary.LittleEndian.PutUint32(info[4:], 0x00010000)
+	binary.LittleEndian.PutUint32(info[8:], uint32(major)<<16|uint32(minor))
+
+	path := filepath.Join(t.TempDir(), "SPlusCC.exe")
+	require.NoError(t, os.WriteFile(path, append([]byte("MZ VS_VERSION_INFO "), info...), 0o644))
+
+	return path
+}
+
+func TestHashSource_CompilerVersion(t *testing.T) {
+	sourceFile := filepath.Join(t.TempDir(), "test.usp")
+	require.NoError(t, os.WriteFile(sourceFile, []byte("test content"), 0o644))
+
+	hash := func(cfg *config.Config) string {
+		key, err := HashSource(sourceFile, cfg)
+		require.NoError(t, err)
+		return key
+	}
+
+	v1 := fakeCompiler(t, 4, 3100)
+	v2 := fakeCompiler(t, 4, 3200)
+
+	unknown := hash(&config.Config{Target: "34", CompilerPath: filepath.Join(t.TempDir(), "missing.exe")})
+	assert.Equal(t, hash(&config.Config{Target: "34"}), unknown, "an unreadable compiler leaves the key as it was")
+	assert.NotEqual(t, unknown, hash(&config.Config{Target: "34", CompilerPath: v1}))
+	assert.NotEqual(t, hash(&config.Config{Target: "34", CompilerPath: v1}), hash(&config.Config{Target: "34", CompilerPath: v2}))
+
+	cfg := &config.Config{Target: "34", CompilerPath: v1, CompilerPaths: map[string]string{"series4": v2}}
+	inputs, err := ExplainKey(sourceFile, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "series3=4.3100.0.0, series4=4.3200.0.0", inputs.CompilerVersion)
+	assert.Equal(t, hash(cfg), inputs.Key)
+
+	// A container's image identifies the compiler instead
+	docker := &config.Config{Target: "34", CompilerPath: v1, Runner: config.RunnerDocker, Docker: config.DockerConfig{Image: "spc:1"}}
+	inputs, err = ExplainKey(sourceFile, docker)
+	require.NoError(t, err)
+	assert.Empty(t, inputs.CompilerVersion)
+}
+
+func TestExplainKey_MissingFile(t *testing.T) {
+	_, err := ExplainKey(filepath.Join(t.TempDir(), "missing.usp"), &config.Config{Target: "3"})
+	assert.Error(t, err)
+}
+
+func TestHashSource_ExtraArgs(t *testing.T) {
+	sourceFile := filepath.Join(t.TempDir(), "test.usp")
+	require.NoError(t, os.WriteFile(sourceFile, []byte("test content"), 0o644))
+
+	hash := func(args ...string) string {
+		key, err := HashSource(sourceFile, &config.Config{Target: "34", ExtraArgs: args})
+		require.NoError(t, err)
+		return key
+	}
+
+	none := hash()
+	assert.Equal(t, none, hash([]string{}...), "no extra args should not change the key")
+	assert.NotEqual(t, none, hash("/someflag"))
+	assert.NotEqual(t, hash("/a", "/b"), hash("/b", "/a"), "argument order matters")
+	assert.NotEqual(t, hash("/a b"), hash("/a", "b"))
+
+	inputs, err := ExplainKey(sourceFile, &config.Config{Target: "34", ExtraArgs: []string{"/someflag", "value"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/someflag", "value"}, inputs.ExtraArgs)
+	assert.Equal(t, hash("/someflag", "value"), inputs.Key)
+}
+
+func TestHashSource_Dependencies(t *testing.T) {
+	dir := t.TempDir()
+	userFolder := t.TempDir()
+
+	write := func(path, content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	sourceFile := filepath.Join(dir, "main.usp")
+	helpers := filepath.Join(dir, "helpers.usl")
+	strs := filepath.Join(userFolder, "strings.usl")
+
+	write(sourceFile, "#USER_LIBRARY \"helpers\"\n#CRESTRON_LIBRARY \"Crestron Strings\"\n")
+	write(helpers, `#USER_LIBRARY "strings"`)
+
+	cfg := &config.Config{Target: "34", UserFolders: []string{userFolder}}
+
+	hash := func() string {
+		key, err := HashSource(sourceFile, cfg)
+		require.NoError(t, err)
+		return key
+	}
+
+	missing := hash()
+
+	write(strs, "// v1")
+	v1 := hash()
+	assert.NotEqual(t, missing, v1, "a library appearing should change the key")
+
+	write(strs, "// v2")
+	assert.NotEqual(t, v1, hash(), "a changed transitive library should change the key")
+
+	inputs, err := ExplainKey(sourceFile, cfg)
+	require.NoError(t, err)
+
+	strsHash, err := HashFile(strs)
+	require.NoError(t, err)
+
+	require.Len(t, inputs.Dependencies, 2)
+	assert.Equal(t, Dependency{Name: "helpers.usl", Path: helpers, Hash: inputs.Dependencies[0].Hash}, inputs.Dependencies[0])
+	assert.Equal(t, Dependency{Name: "strings.usl", Path: strs, Hash: strsHash}, inputs.Dependencies[1])
+
+	// The same library content in another checkout gives the same key
+	otherDir := t.TempDir()
+	write(filepath.Join(otherDir, "main.usp"), "#USER_LIBRARY \"helpers\"\n#CRESTRON_LIBRARY \"Crestron Strings\"\n")
+	write(filepath.Join(otherDir, "helpers.usl"), `#USER_LIBRARY "strings"`)
+
+	other, err := HashSource(filepath.Join(otherDir, "main.usp"), cfg)
+	require.NoError(t, err)
+	assert.Equal(t, hash(), other)
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/Norgate-AV/spc/internal/toolchain"
+)
+
+// Hasher hashes files with a bounded pool of workers and remembers each
+// file's hash, so a library shared by many sources is read once per build
+type Hasher struct {
+	algorithm string
+	workers   int
+
+	// stats remembers hashes between runs (nil to always read files)
+	stats statStore
+
+	mu       sync.Mutex
+	files    map[string]*fileHash
+	versions map[string]string
+}
+
+// statStore remembers file hashes between runs by size and modification
+// time (implemented by Cache)
+type statStore interface {
+	lookupHash(algorithm, path string, info os.FileInfo) (string, bool)
+	recordHash(algorithm, path string, info os.FileInfo, hash string)
+}
+
+// fileHash is a file's hash, or the error hashing it, once done is closed
+type fileHash struct {
+	done chan struct{}
+	hash string
+	err  error
+}
+
+// NewHasher creates a hasher using one of the cache.hash algorithms that
+// reads at most workers files at a time (runtime.NumCPU when workers is 0 or
+// less)
+func NewHasher(algorithm string, workers int) *Hasher {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	return &Hasher{algorithm: algorithmName(algorithm), workers: workers, files: make(map[string]*fileHash), versions: make(map[string]string)}
+}
+
+// Algorithm returns the hash algorithm the hasher uses
+func (h *Hasher) Algorithm() string {
+	return h.algorithm
+}
+
+// HashFile returns the hash of a file's content, reading the file only the
+// first time it is asked for. Concurrent calls for the same file share a read
+func (h *Hasher) HashFile(path string) (string, error) {
+	h.mu.Lock()
+	f, ok := h.files[path]
+	if !ok {
+		f = &fileHash{done: make(chan struct{})}
+		h.files[path] = f
+	}
+	h.mu.Unlock()
+
+	if ok {
+		<-f.done
+		return f.hash, f.err
+	}
+
+	f.hash, f.err = h.hashFile(path)
+	close(f.done)
+
+	return f.hash, f.err
+}
+
+// hashFile hashes a file, or returns its remembered hash when its size and
+// modification time are unchanged since an earlier run hashed it
+func (h *Hasher) hashFile(path string) (string, error) {
+	if h.stats == nil {
+		return HashFileWith(h.algorithm, path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if hash, ok := h.stats.lookupHash(h.algorithm, path, info); ok {
+		return hash, nil
+	}
+
+	hash, err := HashFileWith(h.algorithm, path)
+	if err != nil {
+		return "", err
+	}
+
+	h.stats.recordHash(h.algorithm, path, info, hash)
+
+	return hash, nil
+}
+
+// HashFiles hashes files concurrently and returns their hashes in order
+func (h *Hasher) HashFiles(paths []string) ([]string, error) {
+	hashes := make([]string, len(paths))
+	errs := make([]error, len(paths))
+	sem := make(chan struct{}, h.workers)
+
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var err error
+			if hashes[i], err = h.HashFile(path); err != nil {
+				errs[i] = fmt.Errorf("failed to hash %s: %w", path, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
+
+// Reset forgets every hash, so files changed since are read again
+func (h *Hasher) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.files = make(map[string]*fileHash)
+	h.versions = make(map[string]string)
+}
+
+// compilerVersion returns the file version of a compiler, reading it only the
+// first time it is asked for, or "" when it can't be read
+func (h *Hasher) compilerVersion(path string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	version, ok := h.versions[path]
+	if !ok {
+		version, _ = toolchain.FileVersion(path)
+		h.versions[path] = version
+	}
+
+	return version
+}
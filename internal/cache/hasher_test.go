@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+func TestHasher_HashFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib.usl")
+	require.NoError(t, os.WriteFile(path, []byte("v1"), 0o644))
+
+	h := NewHasher("", 2)
+	first, err := h.HashFile(path)
+	require.NoError(t, err)
+
+	// The hash is remembered until the hasher is reset
+	require.NoError(t, os.WriteFile(path, []byte("v2"), 0o644))
+	again, err := h.HashFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, first, again)
+
+	h.Reset()
+	changed, err := h.HashFile(path)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, changed)
+}
+
+func TestHasher_HashFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	var paths, want []string
+	for i := range 20 {
+		path := filepath.Join(dir, fmt.Sprintf("lib%d.usl", i))
+		require.NoError(t, os.WriteFile(path, []byte(path), 0o644))
+
+		hash, err := HashFile(path)
+		require.NoError(t, err)
+
+		paths = append(paths, path)
+		want = append(want, hash)
+	}
+
+	h := NewHasher("", 3)
+	hashes, err := h.HashFiles(paths)
+	require.NoError(t, err)
+	assert.Equal(t, want, hashes)
+
+	_, err = h.HashFiles(append(paths, filepath.Join(dir, "missing.usl")))
+	assert.ErrorContains(t, err, "missing.usl")
+}
+
+func TestCache_ShareHashes(t *testing.T) {
+	sourceDir := t.TempDir()
+	sourceFile := filepath.Join(sourceDir, "test.usp")
+	library := filepath.Join(sourceDir, "helpers.usl")
+
+	require.NoError(t, os.WriteFile(sourceFile, []byte("#USER_LIBRARY \"helpers\"\n"), 0o644))
+	require.NoError(t, os.WriteFile(library, []byte("v1"), 0o644))
+
+	cache, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer cache.Close()
+
+	cfg := &config.Config{Target: "3"}
+	done := cache.ShareHashes()
+
+	first, err := cache.hasher(cfg).HashSource(sourceFile, cfg)
+	require.NoError(t, err)
+
+	// Within a build the library is not read again
+	require.NoError(t, os.WriteFile(library, []byte("v2"), 0o644))
+	shared, err := cache.hasher(cfg).HashSource(sourceFile, cfg)
+	require.NoError(t, err)
+	assert.Equal(t, first, shared)
+
+	done()
+
+	changed, err := cache.hasher(cfg).HashSource(sourceFile, cfg)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, changed)
+}
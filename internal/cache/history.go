@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// historyBucket is the BoltDB bucket recording past builds, keyed by start
+// time so they are stored oldest first
+const historyBucket = "history"
+
+// HistoryRetention is how long build records are kept
+const HistoryRetention = 90 * 24 * time.Hour
+
+// BuildRecord is a build invocation recorded in the history
+type BuildRecord struct {
+	// Time is when the build started
+	Time time.Time `json:"time"`
+
+	// Duration is how long the whole build took
+	Duration time.Duration `json:"duration"`
+
+	// Target is the target series built for
+	Target string `json:"target"`
+
+	// Project is the project directory, if the build had one
+	Project string `json:"project,omitempty"`
+
+	// Files are the results of each file the build attempted
+	Files []FileRecord `json:"files"`
+}
+
+// FileRecord is the result of one file in a recorded build
+type FileRecord struct {
+	// Path is the absolute path to the source file
+	Path string `json:"path"`
+
+	// Status is the final status: "success", "cached" or "failed"
+	Status string `json:"status"`
+
+	// Duration is how long the file took to build or restore
+	Duration time.Duration `json:"duration"`
+}
+
+// Hits counts the files of a build restored from the cache
+func (r BuildRecord) Hits() int {
+	hits := 0
+	for _, f := range r.Files {
+		if f.Status == statusCached {
+			hits++
+		}
+	}
+
+	return hits
+}
+
+// Misses counts the files of a build that were compiled, successfully or not
+func (r BuildRecord) Misses() int {
+	return len(r.Files) - r.Hits()
+}
+
+// File statuses of a FileRecord
+const (
+	statusSucceeded = "success"
+	statusCached    = "cached"
+	statusFailed    = "failed"
+)
+
+// RecordBuild adds a build to the history, dropping records older than
+// HistoryRetention. Nothing is recorded unless the cache is read-write
+func (c *Cache) RecordBuild(record BuildRecord) error {
+	if c.mode != ModeReadWrite {
+		return nil
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(historyBucket))
+		if err != nil {
+			return err
+		}
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		if err := b.Put(historyKey(record.Time, seq), data); err != nil {
+			return err
+		}
+
+		// Expire old records, which are at the start of the bucket
+		cutoff := historyKey(time.Now().Add(-HistoryRetention), 0)
+		cursor := b.Cursor()
+		for k, _ := cursor.First(); k != nil && bytes.Compare(k, cutoff) < 0; k, _ = cursor.Next() {
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// History returns the builds recorded since a time, oldest first
+func (c *Cache) History(since time.Time) ([]BuildRecord, error) {
+	records := []BuildRecord{}
+
+	if c.mode == ModeBypass {
+		return records, nil
+	}
+
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(historyBucket))
+		if b == nil {
+			return nil
+		}
+
+		cursor := b.Cursor()
+		for k, data := cursor.Seek(historyKey(since, 0)); k != nil; k, data = cursor.Next() {
+			var record BuildRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				continue // Skip records we can't read
+			}
+
+			records = append(records, record)
+		}
+
+		return nil
+	})
+
+	return records, err
+}
+
+// historyKey orders records by start time, then by the order they were added
+func historyKey(t time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], seq)
+
+	return key
+}
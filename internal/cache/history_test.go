@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_History(t *testing.T) {
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	now := time.Now()
+	old := BuildRecord{Time: now.Add(-HistoryRetention - time.Hour), Target: "3"}
+	week := BuildRecord{Time: now.Add(-7 * 24 * time.Hour), Target: "34", Files: []FileRecord{{Path: "/src/a.usp", Status: "cached"}}}
+	today := BuildRecord{Time: now, Target: "34", Files: []FileRecord{{Path: "/src/a.usp", Status: "success", Duration: time.Second}}}
+
+	require.NoError(t, c.RecordBuild(old))
+	require.NoError(t, c.RecordBuild(today))
+	require.NoError(t, c.RecordBuild(week))
+
+	// Records come back oldest first, and expired ones are dropped
+	records, err := c.History(now.Add(-HistoryRetention - 2*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "34", records[0].Target)
+	assert.Equal(t, 1, records[0].Hits())
+	assert.Equal(t, 1, records[1].Misses())
+
+	records, err = c.History(now.Add(-24 * time.Hour))
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, time.Second, records[0].Files[0].Duration)
+}
+
+func TestCache_History_ReadOnly(t *testing.T) {
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	c.SetReadOnly()
+	require.NoError(t, c.RecordBuild(BuildRecord{Time: time.Now()}))
+
+	records, err := c.History(time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// Key returns a name for the whole cache directory that changes only when the
+// builds in it can no longer be reused: a new cache format, cache.hash
+// algorithm, compiler container image or compiler version. CI systems can key
+// their cache of the directory on it (e.g. "spc-v2-3f9a1c2b7d4e")
+func Key(cfg *config.Config) string {
+	digest := sha256.New()
+	digest.Write([]byte(algorithmName(cfg.Cache.Hash)))
+	digest.Write([]byte("\x00image\x00"))
+	digest.Write([]byte(containerImage(cfg)))
+
+	// The compilers of every series, whatever the target. Skipped when none
+	// can be read so the key stays the same where SIMPL isn't installed
+	every := *cfg
+	every.Target = "234"
+
+	if versions := NewHasher(cfg.Cache.Hash, 1).compilerVersions(&every); len(versions) > 0 {
+		digest.Write([]byte("\x00compiler\x00"))
+		digest.Write([]byte(strings.Join(versions, "\x00")))
+	}
+
+	return fmt.Sprintf("spc-v%d-%s", FormatVersion, hex.EncodeToString(digest.Sum(nil))[:12])
+}
@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+func TestKey(t *testing.T) {
+	base := Key(&config.Config{Target: "34"})
+	assert.Regexp(t, `^spc-v2-[0-9a-f]{12}$`, base)
+
+	// Settings every cached build is keyed on anyway don't change it
+	assert.Equal(t, base, Key(&config.Config{Target: "4", ExtraArgs: []string{"/x"}}))
+	assert.Equal(t, base, Key(&config.Config{Cache: config.CacheConfig{Hash: config.HashSHA256}}))
+
+	assert.NotEqual(t, base, Key(&config.Config{Cache: config.CacheConfig{Hash: config.HashXXH3}}))
+
+	docker := func(image string) string {
+		return Key(&config.Config{Runner: config.RunnerDocker, Docker: config.DockerConfig{Image: image}})
+	}
+
+	assert.NotEqual(t, base, docker("spc:1"))
+	assert.NotEqual(t, docker("spc:1"), docker("spc:2"))
+
+	// So does upgrading the compiler of any series, whatever the target
+	compiler := fakeCompiler(t, 4, 3100)
+	installed := Key(&config.Config{Target: "3", CompilerPath: compiler})
+	assert.NotEqual(t, base, installed)
+	assert.Equal(t, installed, Key(&config.Config{Target: "4", CompilerPath: compiler}))
+	assert.NotEqual(t, installed, Key(&config.Config{Target: "3", CompilerPath: compiler, CompilerPaths: map[string]string{"series2": fakeCompiler(t, 2, 800)}}))
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/logger"
+)
+
+// Windows error codes for a file another process has open without sharing
+// it (SIMPL Windows holding a DLL, an antivirus scan)
+const (
+	errorSharingViolation = syscall.Errno(32)
+	errorLockViolation    = syscall.Errno(33)
+)
+
+// ErrFileLocked is returned when an artifact stays locked by another process
+// after every retry
+var ErrFileLocked = errors.New("file is locked by another process")
+
+// retryPolicy is how often and how long to wait for a locked artifact
+type retryPolicy struct {
+	retries int
+	delay   time.Duration
+}
+
+// lockRetry is the policy for locked artifacts (unset for the defaults)
+var lockRetry atomic.Pointer[retryPolicy]
+
+// SetLockRetry sets how many times to retry copying an artifact another
+// process has locked, and the delay before the first retry (doubled after
+// each attempt)
+func SetLockRetry(retries int, delay time.Duration) {
+	lockRetry.Store(&retryPolicy{retries: retries, delay: delay})
+}
+
+// isLocked reports whether err is a sharing or lock violation (replaced in tests)
+var isLocked = func(err error) bool {
+	var errno syscall.Errno
+	if runtime.GOOS != "windows" || !errors.As(err, &errno) {
+		return false
+	}
+
+	return errno == errorSharingViolation || errno == errorLockViolation
+}
+
+// retryLocked runs op, retrying with backoff while path is locked by
+// another process
+func retryLocked(path string, op func() error) error {
+	policy := lockRetry.Load()
+	if policy == nil {
+		policy = &retryPolicy{retries: config.DefaultLockRetries, delay: config.DefaultLockDelay}
+	}
+
+	delay := policy.delay
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || !isLocked(err) {
+			return err
+		}
+
+		if attempt >= policy.retries {
+			return fmt.Errorf("%w: %s is still in use after %d attempts; close SIMPL Windows or anything else holding it open, or exclude SPlsWork from antivirus scanning: %w",
+				ErrFileLocked, path, attempt+1, err)
+		}
+
+		logger.Debugf("%s is locked by another process, retrying in %s", path, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errTestLocked = errors.New("sharing violation")
+
+func withLockedErrors(t *testing.T) {
+	t.Helper()
+
+	previous := isLocked
+	isLocked = func(err error) bool { return errors.Is(err, errTestLocked) }
+	t.Cleanup(func() {
+		isLocked = previous
+		lockRetry.Store(nil)
+	})
+}
+
+func TestRetryLocked_SucceedsOnceReleased(t *testing.T) {
+	withLockedErrors(t)
+	SetLockRetry(3, time.Millisecond)
+
+	attempts := 0
+	err := retryLocked("SPlsWork/example.dll", func() error {
+		if attempts++; attempts < 3 {
+			return errTestLocked
+		}
+
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestRetryLocked_GivesUp(t *testing.T) {
+	withLockedErrors(t)
+	SetLockRetry(2, time.Millisecond)
+
+	attempts := 0
+	err := retryLocked("SPlsWork/example.dll", func() error {
+		attempts++
+		return errTestLocked
+	})
+
+	assert.Equal(t, 3, attempts)
+	assert.ErrorIs(t, err, ErrFileLocked)
+	assert.ErrorIs(t, err, errTestLocked)
+	assert.Contains(t, err.Error(), "SPlsWork/example.dll is still in use after 3 attempts")
+	assert.Contains(t, err.Error(), "SIMPL Windows")
+}
+
+func TestRetryLocked_OtherErrorsNotRetried(t *testing.T) {
+	withLockedErrors(t)
+	SetLockRetry(5, time.Millisecond)
+
+	attempts := 0
+	failed := errors.New("disk full")
+	err := retryLocked("example.ush", func() error {
+		attempts++
+		return failed
+	})
+
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, failed, err)
+}
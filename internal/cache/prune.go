@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/Norgate-AV/spc/internal/logger"
+)
+
+// prunable is a stored entry considered for eviction
+type prunable struct {
+	hash      string
+	timestamp time.Time
+	size      int64
+}
+
+// Prune evicts entries older than ttl, then the oldest remaining entries
+// until the cached artifacts fit in maxSize bytes. A zero ttl or maxSize
+// disables that limit. Returns the number of entries removed
+func (c *Cache) Prune(maxSize int64, ttl time.Duration) (int, error) {
+	if maxSize <= 0 && ttl <= 0 {
+		return 0, nil
+	}
+
+	if c.mode != ModeReadWrite {
+		logger.Debugf("not pruning cache, cache is %s", c.mode)
+		return 0, nil
+	}
+
+	entries, err := c.prunable()
+	if err != nil {
+		return 0, err
+	}
+
+	// Oldest first
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].timestamp.Before(entries[j].timestamp)
+	})
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+
+	var evict []prunable
+	cutoff := time.Now().Add(-ttl)
+
+	for _, e := range entries {
+		expired := ttl > 0 && e.timestamp.Before(cutoff)
+		oversize := maxSize > 0 && total > maxSize
+		if !expired && !oversize {
+			break
+		}
+
+		evict = append(evict, e)
+		total -= e.size
+	}
+
+	if len(evict) == 0 {
+		return 0, nil
+	}
+
+	err = c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucketName))
+
+		var size int64
+		for _, e := range evict {
+			if err := b.Delete([]byte(e.hash)); err != nil {
+				return err
+			}
+
+			size += e.size
+		}
+
+		return addTotals(tx, -int64(len(evict)), -size)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove cache entries: %w", err)
+	}
+
+	for _, e := range evict {
+		if err := os.RemoveAll(c.artifactDir(e.hash)); err != nil {
+			logger.Warnf("Failed to remove cached artifacts for %s: %v", shortHash(e.hash), err)
+		}
+	}
+
+	logger.Debugf("pruned %d cache entries", len(evict))
+
+	return len(evict), nil
+}
+
+// prunable lists every stored entry with the size of its artifacts
+func (c *Cache) prunable() ([]prunable, error) {
+	var entries []prunable
+
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucketName))
+
+		return b.ForEach(func(k, data []byte) error {
+			var entry Entry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				// Unreadable entries are the first to go
+				entries = append(entries, prunable{hash: string(k)})
+				return nil
+			}
+
+			entries = append(entries, prunable{hash: string(k), timestamp: entry.Timestamp, size: entry.Size})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache entries: %w", err)
+	}
+
+	// Entries stored before sizes were recorded are measured on disk
+	for i := range entries {
+		if entries[i].size == 0 {
+			entries[i].size = dirSize(c.artifactDir(entries[i].hash))
+		}
+	}
+
+	return entries, nil
+}
+
+// dirSize returns the total size of the files under dir
+func dirSize(dir string) int64 {
+	var size int64
+
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip errors
+		}
+
+		if !info.IsDir() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+// putEntry stores an entry with size bytes of artifacts, bypassing Store
+func putEntry(t *testing.T, c *Cache, hash string, age time.Duration, size int) {
+	t.Helper()
+
+	entry := Entry{Version: FormatVersion, Hash: hash, Timestamp: time.Now().Add(-age), Success: true}
+	data, err := json.Marshal(entry)
+	require.NoError(t, err)
+
+	require.NoError(t, c.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(bucketName)).Put([]byte(hash), data)
+	}))
+
+	dir := c.artifactDir(hash)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "out.dll"), make([]byte, size), 0o644))
+}
+
+func TestCache_Prune(t *testing.T) {
+	newCache := func(t *testing.T) *Cache {
+		c, err := New(t.TempDir())
+		require.NoError(t, err)
+		t.Cleanup(func() { c.Close() })
+
+		putEntry(t, c, "old", 48*time.Hour, 100)
+		putEntry(t, c, "middle", 2*time.Hour, 100)
+		putEntry(t, c, "new", time.Minute, 100)
+
+		return c
+	}
+
+	t.Run("no limits keeps everything", func(t *testing.T) {
+		c := newCache(t)
+
+		removed, err := c.Prune(0, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 0, removed)
+	})
+
+	t.Run("ttl evicts expired entries", func(t *testing.T) {
+		c := newCache(t)
+
+		removed, err := c.Prune(0, 24*time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, 1, removed)
+
+		count, size, err := c.Stats()
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		assert.Equal(t, int64(200), size)
+		assert.NoDirExists(t, c.artifactDir("old"))
+	})
+
+	t.Run("max size evicts oldest entries first", func(t *testing.T) {
+		c := newCache(t)
+
+		removed, err := c.Prune(150, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2, removed)
+		assert.DirExists(t, c.artifactDir("new"))
+		assert.NoDirExists(t, c.artifactDir("middle"))
+	})
+}
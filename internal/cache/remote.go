@@ -0,0 +1,397 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/logger"
+)
+
+// Errors returned by a Remote
+var (
+	// ErrRemoteMiss means nothing is stored under a key
+	ErrRemoteMiss = errors.New("not in remote cache")
+
+	// ErrRemoteForbidden means the credentials don't allow uploading
+	ErrRemoteForbidden = errors.New("not allowed to upload to remote cache")
+)
+
+// Remote is a cache shared with other machines, such as a CI service's. It
+// holds an archive of each successful build (its entry and artifacts) by key
+type Remote interface {
+	// Fetch downloads the archive stored under key, or returns ErrRemoteMiss
+	Fetch(ctx context.Context, key string) ([]byte, error)
+
+	// Upload stores an archive under key. An archive already stored under the
+	// key (by another machine building the same source) counts as uploaded
+	Upload(ctx context.Context, key string, archive []byte) error
+}
+
+// archiveEntry is the name of the entry in a remote archive; the artifacts
+// follow under archiveArtifacts
+const (
+	archiveEntry     = "entry.json"
+	archiveArtifacts = "artifacts/"
+)
+
+// formatKey is the key of the remote cache's format marker
+const formatKey = "spc-format"
+
+// formatMarker records the format of the first spc to use a remote cache and
+// the oldest format that can read its archives
+type formatMarker struct {
+	FormatVersion  int `json:"format_version"`
+	MinReadVersion int `json:"min_read_version"`
+}
+
+// SetRemote shares builds with a remote cache: local misses are looked up in
+// it and successful builds uploaded to it, unless readOnly. Builds fetched
+// from it are added to the local cache, so they need a read-write one.
+//
+// The remote's format marker is checked first, so a remote written by a newer
+// spc is used read-only, or not at all when this version can't read it. Each
+// archive also records its format, which catches newer clients that start
+// sharing a remote after the marker was written
+func (c *Cache) SetRemote(ctx context.Context, remote Remote, readOnly bool) {
+	c.remote = remote
+	c.remoteReadOnly.Store(readOnly)
+
+	if remote != nil {
+		c.probeRemote(ctx)
+	}
+}
+
+// probeRemote negotiates the format of the remote cache from its marker,
+// writing one if there is none. Failures are logged and leave the format to
+// be negotiated archive by archive
+func (c *Cache) probeRemote(ctx context.Context) {
+	data, err := c.remote.Fetch(ctx, formatKey)
+	if errors.Is(err, ErrRemoteMiss) {
+		c.writeFormatMarker(ctx)
+		return
+	}
+
+	if err != nil {
+		logger.Debugf("could not read the remote cache format: %v", err)
+		return
+	}
+
+	var marker formatMarker
+	if err := json.Unmarshal(data, &marker); err != nil || marker.FormatVersion == 0 {
+		logger.Debugf("ignoring invalid remote cache format marker")
+		return
+	}
+
+	minRead := marker.MinReadVersion
+	if minRead == 0 {
+		minRead = marker.FormatVersion
+	}
+
+	switch NegotiateMode(FormatVersion, marker.FormatVersion, minRead) {
+	case ModeReadOnly:
+		if !c.remoteReadOnly.Swap(true) {
+			logger.Warnf("The remote cache holds builds in a newer format, using it read-only")
+		}
+	case ModeBypass:
+		c.remote = nil
+		logger.Warnf("Ignoring the remote cache: it has format %d, which needs format %d or later", marker.FormatVersion, minRead)
+	}
+}
+
+// writeFormatMarker stores this version's format as the remote's marker
+func (c *Cache) writeFormatMarker(ctx context.Context) {
+	if c.remoteReadOnly.Load() {
+		return
+	}
+
+	data, err := json.Marshal(formatMarker{FormatVersion: FormatVersion, MinReadVersion: MinReadVersion})
+	if err == nil {
+		err = c.remote.Upload(ctx, formatKey, data)
+	}
+
+	if err != nil {
+		logger.Debugf("could not write the remote cache format: %v", err)
+	}
+}
+
+// archivedEntry is the entry.json of a remote archive: the entry, in the
+// uploader's format, and the oldest format that can still read it
+type archivedEntry struct {
+	Entry
+	MinReadVersion int `json:"min_read_version"`
+}
+
+// remoteKey is the key a build is stored under remotely. It includes the
+// oldest format that can read the archive, so clients whose formats differ
+// but can read each other's entries share archives
+func remoteKey(hash string) string {
+	return fmt.Sprintf("spc-v%d-%s", MinReadVersion, hash)
+}
+
+// fetchRemote looks a build up in the remote cache after a local miss and
+// adds it to the local cache when found. Remote failures are logged and
+// count as a miss, so an unreachable remote never fails a build
+func (c *Cache) fetchRemote(ctx context.Context, hash, sourceFile, algorithm string, log *logger.Logger) *Entry {
+	if c.remote == nil || c.mode != ModeReadWrite {
+		return nil
+	}
+
+	archive, err := c.remote.Fetch(ctx, remoteKey(hash))
+	if errors.Is(err, ErrRemoteMiss) {
+		log.Debugf("remote cache miss for %s (%s)", filepath.Base(sourceFile), shortHash(hash))
+		return nil
+	}
+
+	if err != nil {
+		log.Warnf("Failed to fetch %s from the remote cache: %v", filepath.Base(sourceFile), err)
+		return nil
+	}
+
+	entry, mode, err := c.importArchive(hash, algorithm, archive)
+	if err != nil {
+		log.Warnf("Ignoring remote cache entry for %s: %v", filepath.Base(sourceFile), err)
+		return nil
+	}
+
+	// A newer client shares this remote; keep our older archives out of it
+	if mode == ModeReadOnly && !c.remoteReadOnly.Swap(true) {
+		log.Warnf("The remote cache holds builds in a newer format, using it read-only")
+	}
+
+	entry.SourceFile = sourceFile
+	if err := c.putEntry(entry); err != nil {
+		log.Warnf("Failed to store remote cache entry for %s: %v", filepath.Base(sourceFile), err)
+		return nil
+	}
+
+	log.Debugf("remote cache hit for %s (%s)", filepath.Base(sourceFile), shortHash(hash))
+
+	return entry
+}
+
+// uploadRemote shares a stored build with the remote cache. Failures are
+// logged; the build is still in the local cache. Once the remote refuses an
+// upload, no more are attempted
+func (c *Cache) uploadRemote(ctx context.Context, entry *Entry, log *logger.Logger) {
+	if c.remote == nil || c.remoteReadOnly.Load() {
+		return
+	}
+
+	archive, err := c.exportArchive(entry)
+	if err == nil {
+		err = c.remote.Upload(ctx, remoteKey(entry.Hash), archive)
+	}
+
+	if errors.Is(err, ErrRemoteForbidden) {
+		c.remoteReadOnly.Store(true)
+		log.Warnf("The remote cache refused an upload, using it read-only: %v", err)
+
+		return
+	}
+
+	if err != nil {
+		log.Warnf("Failed to upload %s to the remote cache: %v", filepath.Base(entry.SourceFile), err)
+		return
+	}
+
+	log.Debugf("uploaded %s to the remote cache (%s, %d bytes)", filepath.Base(entry.SourceFile), shortHash(entry.Hash), len(archive))
+}
+
+// exportArchive packs an entry and its artifacts into a gzipped tar
+func (c *Cache) exportArchive(entry *Entry) ([]byte, error) {
+	// The source path is the uploader's; the fetching machine sets its own
+	shared := archivedEntry{Entry: *entry, MinReadVersion: MinReadVersion}
+	shared.SourceFile = filepath.Base(entry.SourceFile)
+
+	data, err := json.Marshal(shared)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	if err := writeArchiveFile(tw, archiveEntry, bytes.NewReader(data), int64(len(data))); err != nil {
+		return nil, err
+	}
+
+	artifactDir := c.artifactDir(entry.Hash)
+	for _, o := range entry.Outputs {
+		f, err := os.Open(filepath.Join(artifactDir, o.Path))
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := f.Stat()
+		if err == nil {
+			err = writeArchiveFile(tw, archiveArtifacts+filepath.ToSlash(o.Path), f, info.Size())
+		}
+
+		f.Close()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeArchiveFile adds a file to a tar archive
+func writeArchiveFile(tw *tar.Writer, name string, r io.Reader, size int64) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: size, Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(tw, r)
+
+	return err
+}
+
+// importArchive unpacks a remote archive of the build with the given hash
+// into the artifact directory and returns its entry, in this client's format,
+// and how the archive's format may be used. The artifacts must match the
+// entry's checksums
+func (c *Cache) importArchive(hash, algorithm string, archive []byte) (*Entry, Mode, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid archive: %w", err)
+	}
+
+	tr := tar.NewReader(gz)
+
+	header, err := tr.Next()
+	if err != nil || header.Name != archiveEntry {
+		return nil, 0, fmt.Errorf("invalid archive: missing %s", archiveEntry)
+	}
+
+	var shared archivedEntry
+	if err := json.NewDecoder(tr).Decode(&shared); err != nil {
+		return nil, 0, fmt.Errorf("invalid archive: %w", err)
+	}
+
+	// Archives that don't say otherwise are only readable in their own format
+	minRead := shared.MinReadVersion
+	if minRead == 0 {
+		minRead = shared.Version
+	}
+
+	mode := NegotiateMode(FormatVersion, shared.Version, minRead)
+	entry := shared.Entry
+
+	switch {
+	case entry.Hash != hash:
+		return nil, 0, fmt.Errorf("archive is for %s", shortHash(entry.Hash))
+	case mode == ModeBypass:
+		return nil, 0, fmt.Errorf("archive has format %d, which needs format %d or later", entry.Version, minRead)
+	case algorithmName(entry.HashAlgorithm) != algorithm:
+		return nil, 0, fmt.Errorf("archive was hashed with %s", algorithmName(entry.HashAlgorithm))
+	case !entry.Success || len(entry.Outputs) == 0:
+		return nil, 0, fmt.Errorf("archive has no artifacts")
+	}
+
+	outputs := make(map[string]bool)
+	for _, o := range entry.Outputs {
+		if o.SHA256 == "" {
+			return nil, 0, fmt.Errorf("archive has no checksum for %s", o.Path)
+		}
+
+		if !filepath.IsLocal(o.Path) {
+			return nil, 0, fmt.Errorf("invalid archive: output %s is outside the source directory", o.Path)
+		}
+
+		outputs[filepath.ToSlash(o.Path)] = true
+	}
+
+	artifactDir := c.artifactDir(hash)
+	parent := filepath.Dir(artifactDir)
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return nil, 0, err
+	}
+
+	tmp, err := os.MkdirTemp(parent, "."+hash+".tmp-*")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer os.RemoveAll(tmp)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid archive: %w", err)
+		}
+
+		// Only the entry's outputs are extracted, which keeps files from
+		// being written outside the artifact directory
+		name := path.Clean(strings.TrimPrefix(header.Name, archiveArtifacts))
+		if header.Typeflag != tar.TypeReg || !strings.HasPrefix(header.Name, archiveArtifacts) || !outputs[name] {
+			return nil, 0, fmt.Errorf("invalid archive: unexpected %s", header.Name)
+		}
+
+		if err := extractFile(filepath.Join(tmp, filepath.FromSlash(name)), tr); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if err := verifyOutputs(tmp, entry.Outputs); err != nil {
+		return nil, 0, err
+	}
+
+	if err := os.RemoveAll(artifactDir); err != nil {
+		return nil, 0, err
+	}
+
+	if err := os.Rename(tmp, artifactDir); err != nil {
+		return nil, 0, err
+	}
+
+	// A newer entry was decoded into this client's schema
+	if entry.Version > FormatVersion {
+		entry.Version = FormatVersion
+	}
+
+	return &entry, mode, nil
+}
+
+// extractFile writes a file from an archive
+func extractFile(dst string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
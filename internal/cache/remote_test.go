@@ -0,0 +1,383 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// memoryRemote is a Remote keeping archives in memory
+type memoryRemote struct {
+	archives map[string][]byte
+	err      error
+}
+
+func newMemoryRemote() *memoryRemote {
+	return &memoryRemote{archives: make(map[string][]byte)}
+}
+
+func (m *memoryRemote) Fetch(_ context.Context, key string) ([]byte, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	archive, ok := m.archives[key]
+	if !ok {
+		return nil, ErrRemoteMiss
+	}
+
+	return archive, nil
+}
+
+func (m *memoryRemote) Upload(_ context.Context, key string, archive []byte) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	if _, ok := m.archives[key]; !ok {
+		m.archives[key] = archive
+	}
+
+	return nil
+}
+
+// buildSource writes a source file with the outputs the compiler would leave
+func buildSource(t *testing.T, dir string) string {
+	source := filepath.Join(dir, "example.usp")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "SPlsWork"), 0o755))
+	require.NoError(t, os.WriteFile(source, []byte("// example"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "example.ush"), []byte("header"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "SPlsWork", "example.dll"), []byte("dll"), 0o644))
+
+	return source
+}
+
+func TestCache_Remote_SharesBuilds(t *testing.T) {
+	remote := newMemoryRemote()
+	cfg := &config.Config{Target: "34"}
+
+	// One machine builds and uploads
+	uploader, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer uploader.Close()
+
+	uploader.SetRemote(context.Background(), remote, false)
+
+	source := buildSource(t, t.TempDir())
+	require.NoError(t, uploader.Store(context.Background(), source, cfg, true))
+	assert.Len(t, remote.archives, 2, "the build and the format marker")
+
+	// Another, with an empty cache, fetches it
+	fetcher, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer fetcher.Close()
+
+	fetcher.SetRemote(context.Background(), remote, true)
+
+	dir := t.TempDir()
+	source = filepath.Join(dir, "example.usp")
+	require.NoError(t, os.WriteFile(source, []byte("// example"), 0o644))
+
+	entry, err := fetcher.Get(context.Background(), source, cfg)
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	assert.Equal(t, source, entry.SourceFile)
+	assert.True(t, entry.Success)
+
+	require.NoError(t, fetcher.Restore(context.Background(), entry, dir))
+
+	data, err := os.ReadFile(filepath.Join(dir, "SPlsWork", "example.dll"))
+	require.NoError(t, err)
+	assert.Equal(t, "dll", string(data))
+
+	// It is now in the local cache, without the remote
+	fetcher.SetRemote(context.Background(), nil, false)
+
+	entry, err = fetcher.Get(context.Background(), source, cfg)
+	require.NoError(t, err)
+	assert.NotNil(t, entry)
+}
+
+func TestCache_Remote_ReadOnly(t *testing.T) {
+	remote := newMemoryRemote()
+
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	c.SetRemote(context.Background(), remote, true)
+
+	require.NoError(t, c.Store(context.Background(), buildSource(t, t.TempDir()), &config.Config{Target: "34"}, true))
+	assert.Empty(t, remote.archives)
+}
+
+func TestCache_Remote_ForbiddenStopsUploads(t *testing.T) {
+	remote := newMemoryRemote()
+	remote.err = fmt.Errorf("%w: 403 Forbidden", ErrRemoteForbidden)
+	cfg := &config.Config{Target: "34"}
+
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	c.SetRemote(context.Background(), remote, false)
+
+	require.NoError(t, c.Store(context.Background(), buildSource(t, t.TempDir()), cfg, true))
+	assert.True(t, c.remoteReadOnly.Load())
+}
+
+func TestCache_Remote_FailuresAreMisses(t *testing.T) {
+	remote := newMemoryRemote()
+	remote.err = errors.New("connection refused")
+	cfg := &config.Config{Target: "34"}
+
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	c.SetRemote(context.Background(), remote, false)
+
+	source := buildSource(t, t.TempDir())
+
+	entry, err := c.Get(context.Background(), source, cfg)
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+
+	// Uploading fails too, but the build is stored locally
+	require.NoError(t, c.Store(context.Background(), source, cfg, true))
+
+	entry, err = c.Get(context.Background(), source, cfg)
+	require.NoError(t, err)
+	assert.NotNil(t, entry)
+}
+
+func TestCache_Remote_RejectsTamperedArchive(t *testing.T) {
+	remote := newMemoryRemote()
+	cfg := &config.Config{Target: "34"}
+
+	uploader, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer uploader.Close()
+
+	uploader.SetRemote(context.Background(), remote, false)
+
+	source := buildSource(t, t.TempDir())
+	require.NoError(t, uploader.Store(context.Background(), source, cfg, true))
+
+	// Replace an artifact in the stored archive
+	for key, archive := range remote.archives {
+		if key == formatKey {
+			continue
+		}
+
+		remote.archives[key] = rewriteArchive(t, archive, archiveArtifacts+"example.ush", "tampered")
+	}
+
+	fetcher, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer fetcher.Close()
+
+	fetcher.SetRemote(context.Background(), remote, true)
+
+	entry, err := fetcher.Get(context.Background(), source, cfg)
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestCache_Remote_NegotiatesFormat(t *testing.T) {
+	remote := newMemoryRemote()
+	cfg := &config.Config{Target: "34"}
+
+	uploader, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer uploader.Close()
+
+	uploader.SetRemote(context.Background(), remote, false)
+
+	source := buildSource(t, t.TempDir())
+	require.NoError(t, uploader.Store(context.Background(), source, cfg, true))
+
+	entry, err := uploader.Get(context.Background(), source, cfg)
+	require.NoError(t, err)
+
+	uploaded := remote.archives[remoteKey(entry.Hash)]
+	require.NotNil(t, uploaded)
+
+	// Pretend a newer client uploaded the archive
+	fetch := func(t *testing.T, version, minRead int) (*Cache, *Entry) {
+		data, err := json.Marshal(archivedEntry{Entry: Entry{
+			Version:       version,
+			Hash:          entry.Hash,
+			SourceFile:    filepath.Base(source),
+			Target:        entry.Target,
+			Outputs:       entry.Outputs,
+			Success:       true,
+			HashAlgorithm: entry.HashAlgorithm,
+		}, MinReadVersion: minRead})
+		require.NoError(t, err)
+
+		remote.archives[remoteKey(entry.Hash)] = rewriteArchive(t, uploaded, archiveEntry, string(data))
+
+		fetcher, err := New(t.TempDir())
+		require.NoError(t, err)
+		t.Cleanup(func() { fetcher.Close() })
+
+		fetcher.SetRemote(context.Background(), remote, false)
+
+		fetched, err := fetcher.Get(context.Background(), source, cfg)
+		require.NoError(t, err)
+
+		return fetcher, fetched
+	}
+
+	t.Run("readable newer format makes the remote read-only", func(t *testing.T) {
+		fetcher, fetched := fetch(t, FormatVersion+1, MinReadVersion)
+		require.NotNil(t, fetched)
+		assert.Equal(t, FormatVersion, fetched.Version)
+		assert.True(t, fetcher.remoteReadOnly.Load())
+	})
+
+	t.Run("unreadable newer format is a miss", func(t *testing.T) {
+		fetcher, fetched := fetch(t, FormatVersion+1, FormatVersion+1)
+		assert.Nil(t, fetched)
+		assert.False(t, fetcher.remoteReadOnly.Load())
+	})
+
+	t.Run("newer format without a minimum is a miss", func(t *testing.T) {
+		_, fetched := fetch(t, FormatVersion+1, 0)
+		assert.Nil(t, fetched)
+	})
+}
+
+func TestCache_SetRemote_FormatMarker(t *testing.T) {
+	marker := func(version, minRead int) []byte {
+		data, err := json.Marshal(formatMarker{FormatVersion: version, MinReadVersion: minRead})
+		require.NoError(t, err)
+
+		return data
+	}
+
+	setRemote := func(t *testing.T, remote Remote, readOnly bool) *Cache {
+		c, err := New(t.TempDir())
+		require.NoError(t, err)
+		t.Cleanup(func() { c.Close() })
+
+		c.SetRemote(context.Background(), remote, readOnly)
+
+		return c
+	}
+
+	t.Run("a new remote gets this version's marker", func(t *testing.T) {
+		remote := newMemoryRemote()
+
+		c := setRemote(t, remote, false)
+		assert.Equal(t, marker(FormatVersion, MinReadVersion), remote.archives[formatKey])
+		assert.False(t, c.remoteReadOnly.Load())
+	})
+
+	t.Run("a read-only client doesn't write the marker", func(t *testing.T) {
+		remote := newMemoryRemote()
+
+		setRemote(t, remote, true)
+		assert.Empty(t, remote.archives)
+	})
+
+	t.Run("readable newer format makes the remote read-only", func(t *testing.T) {
+		remote := newMemoryRemote()
+		remote.archives[formatKey] = marker(FormatVersion+1, MinReadVersion)
+
+		c := setRemote(t, remote, false)
+		assert.Equal(t, remote, c.remote)
+		assert.True(t, c.remoteReadOnly.Load())
+	})
+
+	t.Run("unreadable newer format stops using the remote", func(t *testing.T) {
+		remote := newMemoryRemote()
+		remote.archives[formatKey] = marker(FormatVersion+1, FormatVersion+1)
+
+		c := setRemote(t, remote, false)
+		assert.Nil(t, c.remote)
+	})
+
+	t.Run("unreachable remote is left to the archives", func(t *testing.T) {
+		remote := newMemoryRemote()
+		remote.err = errors.New("connection refused")
+
+		c := setRemote(t, remote, false)
+		assert.Equal(t, remote, c.remote)
+		assert.False(t, c.remoteReadOnly.Load())
+	})
+}
+
+func TestCache_ImportArchive_RejectsUnexpectedFiles(t *testing.T) {
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	source := buildSource(t, t.TempDir())
+	require.NoError(t, c.Store(context.Background(), source, &config.Config{Target: "34"}, true))
+
+	entry, err := c.Get(context.Background(), source, &config.Config{Target: "34"})
+	require.NoError(t, err)
+
+	archive, err := c.exportArchive(entry)
+	require.NoError(t, err)
+
+	archive = rewriteArchive(t, archive, archiveArtifacts+"../../escape.txt", "x")
+
+	_, _, err = c.importArchive(entry.Hash, entry.HashAlgorithm, archive)
+	assert.ErrorContains(t, err, "unexpected")
+}
+
+// rewriteArchive replaces (or adds) a file in a remote archive
+func rewriteArchive(t *testing.T, archive []byte, name, content string) []byte {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	require.NoError(t, err)
+
+	tr := tar.NewReader(gz)
+
+	var buf bytes.Buffer
+	out := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(out)
+
+	replaced := false
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+
+		var data bytes.Buffer
+		_, err = data.ReadFrom(tr)
+		require.NoError(t, err)
+
+		if header.Name == name {
+			data.Reset()
+			data.WriteString(content)
+			replaced = true
+		}
+
+		require.NoError(t, writeArchiveFile(tw, header.Name, &data, int64(data.Len())))
+	}
+
+	if !replaced {
+		require.NoError(t, writeArchiveFile(tw, name, bytes.NewReader([]byte(content)), int64(len(content))))
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, out.Close())
+
+	return buf.Bytes()
+}
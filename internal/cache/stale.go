@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/artifact"
+	"github.com/Norgate-AV/spc/internal/utils"
+)
+
+// CollectStaleOutputs scans sourceDir's SPlsWork directory for the outputs of
+// modules that no longer have a source (.usp or .usl) in sourceDir, because
+// they were deleted or renamed, and their .ush headers beside the sources.
+//
+// Only files the compiler names after a module are considered: series
+// prefixed files (S2_example.c), and the .cs and .inf files of Series 3/4 along
+// with the .dll compiled from them. Shared files and SIMPL# library assemblies
+// are never stale. Returns paths relative to sourceDir, sorted
+func CollectStaleOutputs(sourceDir string) ([]string, error) {
+	entries, err := os.ReadDir(sourceDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source directory: %w", err)
+	}
+
+	var modules []artifact.Module
+	var headers []string
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		switch artifact.Fold(filepath.Ext(entry.Name())) {
+		case ".usp", ".usl":
+			modules = append(modules, artifact.NewModule(entry.Name()))
+		case artifact.HeaderExt:
+			headers = append(headers, entry.Name())
+		}
+	}
+
+	current := func(name string) bool {
+		for _, m := range modules {
+			if _, ok := m.Match(name); ok {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	work, err := os.ReadDir(filepath.Join(sourceDir, "SPlsWork"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read SPlsWork directory: %w", err)
+	}
+
+	var stale []string
+
+	// dead holds the modules with stale outputs, by underscored folded name
+	dead := make(map[string]bool)
+	var assemblies []string
+
+	for _, entry := range work {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || name == "metadata.json" || isSharedFile(name) || current(name) {
+			continue
+		}
+
+		module, prefixed := outputModule(name)
+
+		switch ext := artifact.Fold(filepath.Ext(name)); {
+		case prefixed, ext == ".cs", ext == ".inf":
+			stale = append(stale, filepath.Join("SPlsWork", name))
+			dead[artifact.Fold(artifact.Underscore(module))] = true
+		case ext == ".dll":
+			// Only a module's if its code is there too; otherwise it may be
+			// a SIMPL# library a module uses
+			assemblies = append(assemblies, name)
+		}
+	}
+
+	for _, name := range assemblies {
+		module, _ := outputModule(name)
+		if dead[artifact.Fold(artifact.Underscore(module))] {
+			stale = append(stale, filepath.Join("SPlsWork", name))
+		}
+	}
+
+	for _, name := range headers {
+		module, _ := outputModule(name)
+		if dead[artifact.Fold(artifact.Underscore(module))] && !current(name) {
+			stale = append(stale, name)
+		}
+	}
+
+	sort.Strings(stale)
+
+	return stale, nil
+}
+
+// outputModule returns the name of the module an output file is named after,
+// and whether it carries a series prefix (S2_, S3_ or S4_)
+func outputModule(filename string) (string, bool) {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+
+	if len(base) > 3 && (base[0] == 'S' || base[0] == 's') && base[1] >= '2' && base[1] <= '4' && base[2] == '_' {
+		return base[3:], true
+	}
+
+	return base, false
+}
+
+// RemoveArtifacts deletes outputs from baseDir. The outputs paths are
+// relative to baseDir (e.g., "SPlsWork/example.dll", "example.ush")
+func RemoveArtifacts(baseDir string, outputs []string) error {
+	for _, output := range outputs {
+		path := filepath.Join(baseDir, output)
+
+		// SIMPL Windows or antivirus may hold the file open for a moment
+		err := retryLocked(path, func() error { return os.Remove(utils.LongPath(path)) })
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", output, err)
+		}
+	}
+
+	return nil
+}
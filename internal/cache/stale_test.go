@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectStaleOutputs(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := []string{
+		"room.usp",
+		"room.ush",
+		"helpers.usl",
+		"old module.ush",
+		"vendor.ush",
+		filepath.Join("SPlsWork", "room.dll"),
+		filepath.Join("SPlsWork", "room.cs"),
+		filepath.Join("SPlsWork", "S2_room.c"),
+		filepath.Join("SPlsWork", "S2_helpers.h"),
+		filepath.Join("SPlsWork", "old_module.cs"),
+		filepath.Join("SPlsWork", "old_module.dll"),
+		filepath.Join("SPlsWork", "old module.inf"),
+		filepath.Join("SPlsWork", "S2_old_module.c"),
+		filepath.Join("SPlsWork", "s3_Gone.elf"),
+		filepath.Join("SPlsWork", "DeviceDriver.dll"),
+		filepath.Join("SPlsWork", "SplusLibrary.dll"),
+		filepath.Join("SPlsWork", "Version.ini"),
+		filepath.Join("SPlsWork", "metadata.json"),
+	}
+
+	for _, f := range files {
+		path := filepath.Join(tmpDir, f)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(f), 0o644))
+	}
+
+	stale, err := CollectStaleOutputs(tmpDir)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		filepath.Join("SPlsWork", "S2_old_module.c"),
+		filepath.Join("SPlsWork", "old module.inf"),
+		filepath.Join("SPlsWork", "old_module.cs"),
+		filepath.Join("SPlsWork", "old_module.dll"),
+		filepath.Join("SPlsWork", "s3_Gone.elf"),
+		"old module.ush",
+	}, stale)
+
+	require.NoError(t, RemoveArtifacts(tmpDir, stale))
+
+	for _, f := range stale {
+		assert.NoFileExists(t, filepath.Join(tmpDir, f))
+	}
+
+	assert.FileExists(t, filepath.Join(tmpDir, "SPlsWork", "room.dll"))
+	assert.FileExists(t, filepath.Join(tmpDir, "SPlsWork", "DeviceDriver.dll"))
+	assert.FileExists(t, filepath.Join(tmpDir, "vendor.ush"))
+}
+
+func TestCollectStaleOutputs_NoSPlsWork(t *testing.T) {
+	stale, err := CollectStaleOutputs(t.TempDir())
+	require.NoError(t, err)
+	assert.Empty(t, stale)
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// statsBucket is the BoltDB bucket remembering file hashes by path, size and
+// modification time
+const statsBucket = "stats"
+
+// racyWindow is how recently a file may have been modified for its hash to
+// be remembered: a change made within the same timestamp tick as the hash
+// would otherwise go unnoticed
+const racyWindow = 2 * time.Second
+
+// statEntry is a remembered file hash
+type statEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+	Hash    string `json:"hash"`
+}
+
+// statKey is the key of a file's remembered hash for an algorithm
+func statKey(algorithm, path string) []byte {
+	return []byte(algorithm + "\x00" + path)
+}
+
+// lookupHash returns the remembered hash of a file, if its size and
+// modification time are unchanged since it was hashed
+func (c *Cache) lookupHash(algorithm, path string, info os.FileInfo) (string, bool) {
+	if c.mode == ModeBypass {
+		return "", false
+	}
+
+	var entry statEntry
+	_ = c.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(statsBucket))
+		if b == nil {
+			return nil
+		}
+
+		if data := b.Get(statKey(algorithm, path)); data != nil {
+			return json.Unmarshal(data, &entry)
+		}
+
+		return nil
+	})
+
+	if entry.Hash == "" || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() {
+		return "", false
+	}
+
+	return entry.Hash, true
+}
+
+// recordHash remembers the hash of a file for later runs. Failures are
+// ignored: the file is simply hashed again next time
+func (c *Cache) recordHash(algorithm, path string, info os.FileInfo, hash string) {
+	if c.mode != ModeReadWrite || time.Since(info.ModTime()) < racyWindow {
+		return
+	}
+
+	data, err := json.Marshal(statEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Hash: hash})
+	if err != nil {
+		return
+	}
+
+	// Batch coalesces the writes of files hashed concurrently
+	_ = c.db.Batch(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(statsBucket))
+		if err != nil {
+			return err
+		}
+
+		return b.Put(statKey(algorithm, path), data)
+	})
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeAged writes a file and backdates it past the racy window
+func writeAged(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestCache_RemembersFileHashes(t *testing.T) {
+	library := filepath.Join(t.TempDir(), "helpers.usl")
+	modTime := time.Now().Add(-time.Hour)
+	writeAged(t, library, "v1", modTime)
+
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	first, err := c.newHasher("").HashFile(library)
+	require.NoError(t, err)
+
+	// Same size and mtime: a later run trusts the remembered hash
+	writeAged(t, library, "v2", modTime)
+	remembered, err := c.newHasher("").HashFile(library)
+	require.NoError(t, err)
+	assert.Equal(t, first, remembered)
+
+	// A new mtime means the file is read again
+	writeAged(t, library, "v2", modTime.Add(time.Minute))
+	changed, err := c.newHasher("").HashFile(library)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, changed)
+
+	// Hashes are remembered per algorithm
+	blake, err := c.newHasher("blake3").HashFile(library)
+	require.NoError(t, err)
+	assert.NotEqual(t, changed, blake)
+
+	// Clearing the cache forgets them
+	require.NoError(t, c.Clear())
+	_, ok := c.lookupHash("sha256", library, mustStat(t, library))
+	assert.False(t, ok)
+}
+
+func TestCache_RecordHash_Skips(t *testing.T) {
+	dir := t.TempDir()
+
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	// Files modified just now may change again within the same mtime tick
+	recent := filepath.Join(dir, "recent.usl")
+	require.NoError(t, os.WriteFile(recent, []byte("v1"), 0o644))
+	_, err = c.newHasher("").HashFile(recent)
+	require.NoError(t, err)
+
+	_, ok := c.lookupHash("sha256", recent, mustStat(t, recent))
+	assert.False(t, ok)
+
+	// Read-only caches are never written
+	old := filepath.Join(dir, "old.usl")
+	writeAged(t, old, "v1", time.Now().Add(-time.Hour))
+
+	c.SetReadOnly()
+	_, err = c.newHasher("").HashFile(old)
+	require.NoError(t, err)
+
+	_, ok = c.lookupHash("sha256", old, mustStat(t, old))
+	assert.False(t, ok)
+}
+
+func mustStat(t *testing.T, path string) os.FileInfo {
+	t.Helper()
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	return info
+}
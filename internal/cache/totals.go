@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// Running totals kept in the meta bucket, so Stats doesn't walk the artifacts
+var (
+	totalEntriesKey = []byte("total_entries")
+	totalSizeKey    = []byte("total_size")
+)
+
+// readTotals returns the entry count and artifact size recorded in the meta
+// bucket. ok is false when they have not been calculated yet
+func readTotals(tx *bbolt.Tx) (entries, size int64, ok bool) {
+	b := tx.Bucket([]byte(metaBucketName))
+	if b == nil || b.Get(totalEntriesKey) == nil {
+		return 0, 0, false
+	}
+
+	return int64(readVersion(b, totalEntriesKey)), int64(readVersion(b, totalSizeKey)), true
+}
+
+// writeTotals records the entry count and artifact size
+func writeTotals(tx *bbolt.Tx, entries, size int64) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(metaBucketName))
+	if err != nil {
+		return err
+	}
+
+	if err := writeVersion(b, totalEntriesKey, int(max(entries, 0))); err != nil {
+		return err
+	}
+
+	return writeVersion(b, totalSizeKey, int(max(size, 0)))
+}
+
+// addTotals adjusts the recorded totals. Nothing is recorded until the
+// totals have been calculated once, by Stats or Recalculate
+func addTotals(tx *bbolt.Tx, entries, size int64) error {
+	current, currentSize, ok := readTotals(tx)
+	if !ok {
+		return nil
+	}
+
+	return writeTotals(tx, current+entries, currentSize+size)
+}
+
+// Recalculate walks the cached artifacts to correct each entry's recorded
+// size and the totals reported by Stats, for caches whose totals have
+// drifted (e.g. after artifacts were deleted by hand)
+func (c *Cache) Recalculate() (int, int64, error) {
+	type sized struct {
+		key   []byte
+		entry Entry
+	}
+
+	var entries int64
+	var total int64
+	var changed []sized
+
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(bucketName)).ForEach(func(k, data []byte) error {
+			entries++
+			size := dirSize(c.artifactDir(string(k)))
+			total += size
+
+			var entry Entry
+			if err := json.Unmarshal(data, &entry); err == nil && entry.Size != size {
+				entry.Size = size
+				changed = append(changed, sized{key: append([]byte(nil), k...), entry: entry})
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read cache entries: %w", err)
+	}
+
+	if c.mode != ModeReadWrite {
+		return int(entries), total, nil
+	}
+
+	err = c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucketName))
+		for _, s := range changed {
+			data, err := json.Marshal(s.entry)
+			if err != nil {
+				return err
+			}
+
+			if err := b.Put(s.key, data); err != nil {
+				return err
+			}
+		}
+
+		return writeTotals(tx, entries, total)
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to record cache totals: %w", err)
+	}
+
+	return int(entries), total, nil
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// storeBuilt stores a successful build of a source file with a 100 byte DLL
+func storeBuilt(t *testing.T, c *Cache, content string) string {
+	t.Helper()
+
+	sourceDir := t.TempDir()
+	sourceFile := filepath.Join(sourceDir, "test.usp")
+	require.NoError(t, os.WriteFile(sourceFile, []byte(content), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(sourceDir, "SPlsWork"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "SPlsWork", "test.dll"), make([]byte, 100), 0o644))
+
+	require.NoError(t, c.Store(context.Background(), sourceFile, &config.Config{Target: "3"}, true))
+
+	return sourceFile
+}
+
+func TestCache_Totals(t *testing.T) {
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	// The first call calculates the totals, later stores keep them up to date
+	count, size, err := c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, int64(0), size)
+
+	first := storeBuilt(t, c, "one")
+	storeBuilt(t, c, "two")
+
+	entry, err := c.Get(context.Background(), first, &config.Config{Target: "3"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), entry.Size)
+
+	count, size, err = c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, int64(200), size)
+
+	// Storing the same build again replaces its entry
+	require.NoError(t, c.Store(context.Background(), first, &config.Config{Target: "3"}, true))
+	count, size, err = c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, int64(200), size)
+
+	removed, err := c.Prune(150, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	count, size, err = c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, int64(100), size)
+
+	require.NoError(t, c.Clear())
+	count, size, err = c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, int64(0), size)
+}
+
+func TestCache_Recalculate(t *testing.T) {
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	// Stores before the totals exist are picked up when they are calculated
+	sourceFile := storeBuilt(t, c, "one")
+
+	count, size, err := c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, int64(100), size)
+
+	// Artifacts deleted by hand leave the totals stale until recalculated
+	entry, err := c.Get(context.Background(), sourceFile, &config.Config{Target: "3"})
+	require.NoError(t, err)
+	require.NoError(t, os.RemoveAll(c.artifactDir(entry.Hash)))
+
+	_, size, err = c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), size)
+
+	count, size, err = c.Recalculate()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, int64(0), size)
+
+	// The entry is now a miss, its size recorded as zero
+	missing, err := c.Get(context.Background(), sourceFile, &config.Config{Target: "3"})
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	require.NoError(t, c.db.View(func(tx *bbolt.Tx) error {
+		var stored Entry
+		require.NoError(t, json.Unmarshal(tx.Bucket([]byte(bucketName)).Get([]byte(entry.Hash)), &stored))
+		assert.Equal(t, int64(0), stored.Size)
+
+		_, size, ok := readTotals(tx)
+		assert.True(t, ok)
+		assert.Equal(t, int64(0), size)
+		return nil
+	}))
+}
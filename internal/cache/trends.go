@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"sort"
+	"time"
+)
+
+// TrendPoint totals a module's builds over a period
+type TrendPoint struct {
+	// Builds is the number of times the module was built
+	Builds int `json:"builds"`
+
+	// Cached and Failed count the builds restored from the cache and those
+	// that failed to compile
+	Cached int `json:"cached"`
+	Failed int `json:"failed"`
+
+	// CompileTime is the total time spent compiling the module
+	CompileTime time.Duration `json:"compile_time"`
+}
+
+// Compiled is the number of builds that ran the compiler
+func (p TrendPoint) Compiled() int {
+	return p.Builds - p.Cached
+}
+
+// MeanCompileTime is the average time a compile took (zero without compiles)
+func (p TrendPoint) MeanCompileTime() time.Duration {
+	if p.Compiled() == 0 {
+		return 0
+	}
+
+	return p.CompileTime / time.Duration(p.Compiled())
+}
+
+// FailureRate is the fraction of builds that failed
+func (p TrendPoint) FailureRate() float64 {
+	if p.Builds == 0 {
+		return 0
+	}
+
+	return float64(p.Failed) / float64(p.Builds)
+}
+
+// HitRate is the fraction of builds restored from the cache
+func (p TrendPoint) HitRate() float64 {
+	if p.Builds == 0 {
+		return 0
+	}
+
+	return float64(p.Cached) / float64(p.Builds)
+}
+
+// add counts a file's result
+func (p *TrendPoint) add(f FileRecord) {
+	p.Builds++
+
+	switch f.Status {
+	case statusCached:
+		p.Cached++
+		return
+	case statusFailed:
+		p.Failed++
+	}
+
+	p.CompileTime += f.Duration
+}
+
+// ModuleTrend is how a module's builds changed over a period
+type ModuleTrend struct {
+	// Path is the absolute path to the module's source
+	Path string `json:"path"`
+
+	// Total covers the whole period
+	Total TrendPoint `json:"total"`
+
+	// Points split the period into equal intervals, oldest first
+	Points []TrendPoint `json:"points"`
+}
+
+// Trends summarizes the builds of each module between since and until, split
+// into the given number of intervals. Modules are sorted by path
+func Trends(records []BuildRecord, since, until time.Time, intervals int) []ModuleTrend {
+	if intervals < 1 {
+		intervals = 1
+	}
+
+	width := until.Sub(since) / time.Duration(intervals)
+	modules := make(map[string]*ModuleTrend)
+
+	for _, record := range records {
+		if record.Time.Before(since) || record.Time.After(until) {
+			continue
+		}
+
+		i := intervals - 1
+		if width > 0 {
+			i = min(int(record.Time.Sub(since)/width), intervals-1)
+		}
+
+		for _, f := range record.Files {
+			trend, ok := modules[f.Path]
+			if !ok {
+				trend = &ModuleTrend{Path: f.Path, Points: make([]TrendPoint, intervals)}
+				modules[f.Path] = trend
+			}
+
+			trend.Total.add(f)
+			trend.Points[i].add(f)
+		}
+	}
+
+	trends := make([]ModuleTrend, 0, len(modules))
+	for _, trend := range modules {
+		trends = append(trends, *trend)
+	}
+
+	sort.Slice(trends, func(i, j int) bool { return trends[i].Path < trends[j].Path })
+
+	return trends
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrends(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := since.Add(4 * 24 * time.Hour)
+	day := func(n int) time.Time { return since.Add(time.Duration(n)*24*time.Hour + time.Hour) }
+
+	records := []BuildRecord{
+		{Time: since.Add(-time.Hour), Files: []FileRecord{{Path: "/src/old.usp", Status: statusSucceeded}}},
+		{Time: day(0), Files: []FileRecord{
+			{Path: "/src/b.usp", Status: statusSucceeded, Duration: 2 * time.Second},
+			{Path: "/src/a.usp", Status: statusFailed, Duration: time.Second},
+		}},
+		{Time: day(0), Files: []FileRecord{{Path: "/src/a.usp", Status: statusSucceeded, Duration: 3 * time.Second}}},
+		{Time: day(3), Files: []FileRecord{{Path: "/src/a.usp", Status: statusCached, Duration: time.Millisecond}}},
+	}
+
+	trends := Trends(records, since, until, 4)
+	require.Len(t, trends, 2)
+	assert.Equal(t, "/src/a.usp", trends[0].Path)
+	assert.Equal(t, "/src/b.usp", trends[1].Path)
+
+	a := trends[0]
+	assert.Equal(t, TrendPoint{Builds: 3, Cached: 1, Failed: 1, CompileTime: 4 * time.Second}, a.Total)
+	assert.Equal(t, 2*time.Second, a.Total.MeanCompileTime())
+	assert.InDelta(t, 1.0/3, a.Total.FailureRate(), 0.001)
+	assert.InDelta(t, 1.0/3, a.Total.HitRate(), 0.001)
+
+	require.Len(t, a.Points, 4)
+	assert.Equal(t, 2, a.Points[0].Builds)
+	assert.Zero(t, a.Points[1].Builds)
+	assert.Equal(t, 1, a.Points[3].Cached)
+	assert.Zero(t, a.Points[3].MeanCompileTime())
+}
+
+func TestTrendPoint_Empty(t *testing.T) {
+	var p TrendPoint
+	assert.Zero(t, p.MeanCompileTime())
+	assert.Zero(t, p.FailureRate())
+	assert.Zero(t, p.HitRate())
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"go.etcd.io/bbolt"
+)
+
+// Usage is the disk space taken by the cached builds of one source file or
+// target
+type Usage struct {
+	// Name is the source file or the target
+	Name string `json:"name"`
+
+	// Entries is the number of cached builds
+	Entries int `json:"entries"`
+
+	// Size is the total size of their artifacts in bytes
+	Size int64 `json:"size"`
+}
+
+// DiskUsage is the disk space taken by the cache's artifacts, by source file
+// and by target, each largest first
+type DiskUsage struct {
+	BySource []Usage `json:"by_source"`
+	ByTarget []Usage `json:"by_target"`
+}
+
+// DiskUsage adds up the recorded artifact sizes of every entry by source file
+// and by target
+func (c *Cache) DiskUsage() (*DiskUsage, error) {
+	sources := make(map[string]*Usage)
+	targets := make(map[string]*Usage)
+
+	add := func(totals map[string]*Usage, name string, size int64) {
+		u, ok := totals[name]
+		if !ok {
+			u = &Usage{Name: name}
+			totals[name] = u
+		}
+
+		u.Entries++
+		u.Size += size
+	}
+
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(bucketName)).ForEach(func(_, data []byte) error {
+			var entry Entry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return nil // Skip unreadable entries
+			}
+
+			add(sources, entry.SourceFile, entry.Size)
+			add(targets, entry.Target, entry.Size)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache entries: %w", err)
+	}
+
+	return &DiskUsage{BySource: largestFirst(sources), ByTarget: largestFirst(targets)}, nil
+}
+
+// largestFirst returns the usages sorted by size, largest first, then by name
+func largestFirst(totals map[string]*Usage) []Usage {
+	usages := make([]Usage, 0, len(totals))
+	for _, u := range totals {
+		usages = append(usages, *u)
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Size != usages[j].Size {
+			return usages[i].Size > usages[j].Size
+		}
+
+		return usages[i].Name < usages[j].Name
+	})
+
+	return usages
+}
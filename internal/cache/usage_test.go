@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+func TestCache_DiskUsage(t *testing.T) {
+	c, err := New(t.TempDir())
+	require.NoError(t, err)
+	defer c.Close()
+
+	first := storeBuilt(t, c, "one")
+	second := storeBuilt(t, c, "two")
+
+	// A second build of the first file, for another target
+	require.NoError(t, c.Store(context.Background(), first, &config.Config{Target: "4"}, true))
+
+	usage, err := c.DiskUsage()
+	require.NoError(t, err)
+
+	assert.Equal(t, []Usage{
+		{Name: first, Entries: 2, Size: 200},
+		{Name: second, Entries: 1, Size: 100},
+	}, usage.BySource)
+
+	assert.Equal(t, []Usage{
+		{Name: "3", Entries: 2, Size: 200},
+		{Name: "4", Entries: 1, Size: 100},
+	}, usage.ByTarget)
+}
@@ -0,0 +1,243 @@
+// Package check finds the syntax errors in SIMPL+ sources that can be found
+// without the compiler: comments and strings that are never closed, braces,
+// parentheses and brackets that don't pair up, and user libraries that can't
+// be found. It reads each file once, so it is fast enough to run on every
+// save.
+//
+// Problems are reported as diag.Diagnostic errors, the code naming the check.
+package check
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/deps"
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+// Diagnostic codes
+const (
+	CodeUnterminatedComment = "unterminated_comment"
+	CodeUnterminatedString  = "unterminated_string"
+	CodeUnmatchedBracket    = "unmatched_bracket"
+	CodeUnclosedBracket     = "unclosed_bracket"
+	CodeMissingLibrary      = "missing_library"
+)
+
+// File checks the syntax of a source file and that the user libraries it
+// uses can be found with the resolver
+func File(path string, resolver *deps.Resolver) ([]diag.Diagnostic, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	diagnostics, err := Source(path, f)
+	if err != nil {
+		return nil, err
+	}
+
+	directives, err := deps.ScanFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	searchPaths := resolver.SearchPaths(path, directives)
+
+	for _, d := range directives {
+		if d.Kind != deps.KindUserLibrary && d.Kind != deps.KindUserSimplSharpLibrary {
+			continue
+		}
+
+		if resolver.Resolve(d, searchPaths) == "" {
+			diagnostics = append(diagnostics, diag.Diagnostic{
+				File:     path,
+				Line:     d.Line,
+				Severity: diag.SeverityError,
+				Code:     CodeMissingLibrary,
+				Message:  fmt.Sprintf("Library '%s' not found in the source directory, #INCLUDEPATH directories or user SIMPL+ folders", d.Value),
+			})
+		}
+	}
+
+	return diagnostics, nil
+}
+
+// bracket is an opening brace, parenthesis or bracket
+type bracket struct {
+	char         byte
+	line, column int
+}
+
+// closers maps the closing brackets to their opening ones
+var closers = map[byte]byte{'}': '{', ')': '(', ']': '['}
+
+// Source checks the syntax of a source read from r; path names it in the
+// diagnostics. After the first brace that doesn't pair up (or string that
+// isn't closed), the rest of the file's braces are not checked, as they would
+// all be reported
+func Source(path string, r io.Reader) ([]diag.Diagnostic, error) {
+	var diagnostics []diag.Diagnostic
+
+	report := func(line, column int, code, format string, args ...any) {
+		diagnostics = append(diagnostics, diag.Diagnostic{
+			File:     path,
+			Line:     line,
+			Column:   column,
+			Severity: diag.SeverityError,
+			Code:     code,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	var open []bracket
+
+	bracketsOK := true
+	closeBracket := func(b bracket) {
+		if !bracketsOK {
+			return
+		}
+
+		if len(open) == 0 {
+			report(b.line, b.column, CodeUnmatchedBracket, "'%c' has no matching '%c'", b.char, closers[b.char])
+			bracketsOK = false
+
+			return
+		}
+
+		top := open[len(open)-1]
+		open = open[:len(open)-1]
+
+		if top.char != closers[b.char] {
+			report(b.line, b.column, CodeUnmatchedBracket, "'%c' does not close the '%c' on line %d", b.char, top.char, top.line)
+			bracketsOK = false
+		}
+	}
+
+	// endStatement reports parentheses and brackets still open where a
+	// statement or block starts or ends
+	endStatement := func(char byte, line, column int) {
+		if !bracketsOK || len(open) == 0 {
+			return
+		}
+
+		if top := open[len(open)-1]; top.char != '{' {
+			report(top.line, top.column, CodeUnclosedBracket, "'%c' is not closed before the '%c' on line %d", top.char, char, line)
+			bracketsOK = false
+		}
+	}
+
+	var comment *bracket
+	inHelp := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	num := 0
+	for scanner.Scan() {
+		num++
+		text := strings.TrimRight(scanner.Text(), "\r")
+
+		// Help text is free text
+		if comment == nil {
+			directive := strings.ToUpper(strings.TrimSpace(text))
+			switch {
+			case strings.HasPrefix(directive, "#HELP_BEGIN"):
+				inHelp = true
+				continue
+			case strings.HasPrefix(directive, "#HELP_END"):
+				inHelp = false
+				continue
+			case inHelp:
+				continue
+			}
+		}
+
+	line:
+		for i := 0; i < len(text); i++ {
+			c := text[i]
+
+			switch {
+			case comment != nil:
+				if strings.HasPrefix(text[i:], "*/") {
+					comment = nil
+					i++
+				}
+			case strings.HasPrefix(text[i:], "//"):
+				break line
+			case strings.HasPrefix(text[i:], "/*"):
+				comment = &bracket{char: '*', line: num, column: i + 1}
+				i++
+			case c == '"' || c == '\'':
+				end := literalEnd(text, i)
+				if end < 0 {
+					kind := "String"
+					if c == '\'' {
+						kind = "Character constant"
+					}
+
+					report(num, i+1, CodeUnterminatedString, "%s is not closed on this line", kind)
+
+					// the brackets in the rest of the line are lost
+					bracketsOK = false
+
+					break line
+				}
+
+				i = end
+			case c == '{' || c == '(' || c == '[':
+				if c == '{' {
+					endStatement(c, num, i+1)
+				}
+
+				open = append(open, bracket{char: c, line: num, column: i + 1})
+			case c == '}' || c == ')' || c == ']':
+				if c == '}' {
+					endStatement(c, num, i+1)
+				}
+
+				closeBracket(bracket{char: c, line: num, column: i + 1})
+			case c == ';':
+				endStatement(c, num, i+1)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if comment != nil {
+		report(comment.line, comment.column, CodeUnterminatedComment, "Comment is not closed before the end of the file")
+	}
+
+	if bracketsOK && len(open) > 0 {
+		b := open[len(open)-1]
+		report(b.line, b.column, CodeUnclosedBracket, "'%c' is not closed before the end of the file", b.char)
+	}
+
+	return diagnostics, nil
+}
+
+// literalEnd returns the index of the quote closing the string or character
+// constant starting at i, or -1 if it isn't closed on the line. A backslash
+// escapes the character after it
+func literalEnd(text string, i int) int {
+	quote := text[i]
+
+	for j := i + 1; j < len(text); j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case quote:
+			return j
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,109 @@
+package check
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/deps"
+)
+
+func messages(t *testing.T, source string) []string {
+	t.Helper()
+
+	diagnostics, err := Source("Room.usp", strings.NewReader(source))
+	require.NoError(t, err)
+
+	var got []string
+	for _, d := range diagnostics {
+		got = append(got, d.String())
+	}
+
+	return got
+}
+
+func TestSource_Valid(t *testing.T) {
+	assert.Empty(t, messages(t, `#SYMBOL_NAME "Room"
+#HELP_BEGIN
+Free text: (unbalanced { and "quotes
+#HELP_END
+
+STRING_OUTPUT Out$;
+
+/* a block
+   comment with } in it */
+FUNCTION Send(STRING s) // {
+{
+    Out$ = s + "\x0D" + "}" + "\"(";
+    if (Byte(s, 1) = '{')
+    {
+        Out$ = "[";
+    }
+}
+`))
+}
+
+func TestSource_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "unterminated string",
+			source: "FUNCTION Main()\n{\n    Print(\"hello);\n}\n",
+			want:   []string{"Room.usp(3,11): error unterminated_string: String is not closed on this line"},
+		},
+		{
+			name:   "unterminated comment",
+			source: "FUNCTION Main()\n{\n}\n/* never\nclosed\n",
+			want:   []string{"Room.usp(4,1): error unterminated_comment: Comment is not closed before the end of the file"},
+		},
+		{
+			name:   "missing close paren",
+			source: "FUNCTION Main()\n{\n    Print(\"%d\", Len(x);\n    y = 1;\n}\n",
+			want:   []string{"Room.usp(3,10): error unclosed_bracket: '(' is not closed before the ';' on line 3"},
+		},
+		{
+			name:   "missing close brace",
+			source: "FUNCTION Main()\n{\n    if (x)\n    {\n        y = 1;\n}\n",
+			want:   []string{"Room.usp(2,1): error unclosed_bracket: '{' is not closed before the end of the file"},
+		},
+		{
+			name:   "extra close brace",
+			source: "FUNCTION Main()\n{\n}\n}\nFUNCTION Other()\n{\n}\n}\n",
+			want:   []string{"Room.usp(4,1): error unmatched_bracket: '}' has no matching '{'"},
+		},
+		{
+			name:   "mismatched bracket",
+			source: "INTEGER x[10);\n",
+			want:   []string{"Room.usp(1,13): error unmatched_bracket: ')' does not close the '[' on line 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, messages(t, tt.source))
+		})
+	}
+}
+
+func TestFile_MissingLibrary(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "helpers.usl"), nil, 0o644))
+
+	path := filepath.Join(dir, "Room.usp")
+	require.NoError(t, os.WriteFile(path, []byte("#USER_LIBRARY \"helpers\"\n#USER_LIBRARY \"missing\"\n#CRESTRON_LIBRARY \"Crestron Strings\"\n"), 0o644))
+
+	diagnostics, err := File(path, &deps.Resolver{})
+	require.NoError(t, err)
+
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, CodeMissingLibrary, diagnostics[0].Code)
+	assert.Equal(t, 2, diagnostics[0].Line)
+	assert.Contains(t, diagnostics[0].Message, "'missing'")
+}
@@ -0,0 +1,90 @@
+package ci
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// buildkiteErrors is how many of a failed file's errors its annotation shows
+const buildkiteErrors = 3
+
+// annotateBuildkite adds the summary to the Buildkite build page as an
+// annotation, replacing the one added by an earlier build of the same job
+func annotateBuildkite(s Summary) error {
+	cmd := buildkiteAnnotate(s, os.Getenv("BUILDKITE_JOB_ID"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("buildkite-agent annotate: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// buildkiteAnnotate returns the buildkite-agent command that annotates the
+// build of job with the summary
+func buildkiteAnnotate(s Summary, job string) *exec.Cmd {
+	style := "success"
+	if s.Failed > 0 {
+		style = "error"
+	}
+
+	context := "spc"
+	if job != "" {
+		context += "-" + job
+	}
+
+	cmd := exec.Command("buildkite-agent", "annotate", "--style", style, "--context", context)
+	cmd.Stdin = strings.NewReader(buildkiteMarkdown(s))
+
+	return cmd
+}
+
+// buildkiteMarkdown formats the summary as the Markdown of an annotation:
+// the counts, what the cache saved and the first errors of each failed file
+func buildkiteMarkdown(s Summary) string {
+	var b strings.Builder
+	writeSummaryTable(&b, s)
+
+	if built := s.Compiled + s.Cached; built > 0 {
+		var saved time.Duration
+		for _, r := range s.Results {
+			saved += r.Saved
+		}
+
+		fmt.Fprintf(&b, "\nCache: %d of %d file(s) restored (%d%% hit rate)", s.Cached, built, s.Cached*100/built)
+		if saved > 0 {
+			fmt.Fprintf(&b, ", saving %s of compiling", saved.Round(time.Second))
+		}
+
+		b.WriteString("\n")
+	}
+
+	for _, r := range s.Results {
+		if r.Err == nil {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\n#### ✗ `%s`\n\n", filepath.ToSlash(r.File))
+
+		errs := r.Errors
+		if len(errs) == 0 {
+			errs = []string{r.Err.Error()}
+		}
+
+		b.WriteString("```\n")
+		for _, line := range errs[:min(len(errs), buildkiteErrors)] {
+			b.WriteString(line + "\n")
+		}
+
+		b.WriteString("```\n")
+
+		if more := len(errs) - buildkiteErrors; more > 0 {
+			fmt.Fprintf(&b, "\nand %d more error(s)\n", more)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,320 @@
+// Package ci detects the continuous integration system spc is running under
+// and formats output the way that system understands: annotations that show
+// compile errors on the build page and summaries of each build.
+package ci
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+// Provider is a CI system
+type Provider string
+
+const (
+	// None means spc is not running under CI (or CI handling is disabled)
+	None Provider = "none"
+	// GitHubActions is GitHub Actions (GITHUB_ACTIONS)
+	GitHubActions Provider = "github"
+	// GitLab is GitLab CI (GITLAB_CI)
+	GitLab Provider = "gitlab"
+	// AzurePipelines is Azure Pipelines (TF_BUILD)
+	AzurePipelines Provider = "azure"
+	// Jenkins is Jenkins (JENKINS_URL)
+	Jenkins Provider = "jenkins"
+	// TeamCity is JetBrains TeamCity (TEAMCITY_VERSION)
+	TeamCity Provider = "teamcity"
+	// Buildkite is Buildkite (BUILDKITE)
+	Buildkite Provider = "buildkite"
+	// Generic is any other CI system that sets CI=true
+	Generic Provider = "generic"
+)
+
+// Providers are the names accepted by Parse, besides "auto"
+var Providers = []Provider{None, GitHubActions, GitLab, AzurePipelines, Jenkins, TeamCity, Buildkite, Generic}
+
+// Detect returns the CI system described by the environment
+func Detect() Provider {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return GitHubActions
+	case os.Getenv("GITLAB_CI") != "":
+		return GitLab
+	case strings.EqualFold(os.Getenv("TF_BUILD"), "true"):
+		return AzurePipelines
+	case os.Getenv("JENKINS_URL") != "":
+		return Jenkins
+	case os.Getenv("TEAMCITY_VERSION") != "":
+		return TeamCity
+	case os.Getenv("BUILDKITE") == "true":
+		return Buildkite
+	case isTrue(os.Getenv("CI")):
+		return Generic
+	default:
+		return None
+	}
+}
+
+// isTrue reports whether an environment variable is set to a true value
+func isTrue(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// Parse returns the provider named by the --ci flag. "auto" (or empty)
+// detects it from the environment
+func Parse(name string) (Provider, error) {
+	if name == "" || name == "auto" {
+		return Detect(), nil
+	}
+
+	for _, p := range Providers {
+		if string(p) == name {
+			return p, nil
+		}
+	}
+
+	names := make([]string, 0, len(Providers)+1)
+	names = append(names, "auto")
+	for _, p := range Providers {
+		names = append(names, string(p))
+	}
+
+	return None, fmt.Errorf("unknown CI provider %q (expected %s)", name, strings.Join(names, ", "))
+}
+
+// Reports are the names accepted by ParseReport
+var Reports = []Provider{TeamCity}
+
+// ParseReport returns the provider named by the --report flag, whose output
+// is written wherever spc runs. Empty means no report
+func ParseReport(name string) (Provider, error) {
+	if name == "" {
+		return None, nil
+	}
+
+	for _, p := range Reports {
+		if string(p) == name {
+			return p, nil
+		}
+	}
+
+	names := make([]string, 0, len(Reports))
+	for _, p := range Reports {
+		names = append(names, string(p))
+	}
+
+	return None, fmt.Errorf("unknown report %q (expected %s)", name, strings.Join(names, ", "))
+}
+
+// Active reports whether spc is running under a CI system
+func (p Provider) Active() bool {
+	return p != None && p != ""
+}
+
+// Annotate formats a compiler diagnostic. GitHub Actions and Azure Pipelines
+// get their workflow commands, so the error is attached to the file on the
+// build page, and TeamCity a service message; other systems get the standard
+// diagnostic line
+func (p Provider) Annotate(d diag.Diagnostic) string {
+	switch p {
+	case GitHubActions:
+		props := fmt.Sprintf("file=%s,line=%d", escapeProperty(filepath.ToSlash(d.File)), d.Line)
+		if d.Column > 0 {
+			props += fmt.Sprintf(",col=%d", d.Column)
+		}
+
+		props += ",title=" + escapeProperty("SIMPL+ "+string(d.Severity)+" "+d.Code)
+
+		return fmt.Sprintf("::%s %s::%s", d.Severity, props, escapeData(d.Message))
+	case AzurePipelines:
+		props := fmt.Sprintf("type=%s;sourcepath=%s;linenumber=%d", d.Severity, escapeAzure(d.File), d.Line)
+		if d.Column > 0 {
+			props += fmt.Sprintf(";columnnumber=%d", d.Column)
+		}
+
+		return fmt.Sprintf("##vso[task.logissue %s;code=%s]%s", props, escapeAzure(d.Code), escapeAzure(d.Message))
+	case TeamCity:
+		return teamCityMessage(d)
+	default:
+		return d.String()
+	}
+}
+
+// AnnotateFailure formats a file that failed to build without a diagnostic
+// pointing at a line (for example, when the compiler could not be started)
+func (p Provider) AnnotateFailure(file string, err error) string {
+	switch p {
+	case GitHubActions:
+		return fmt.Sprintf("::error file=%s,title=spc::%s", escapeProperty(filepath.ToSlash(file)), escapeData(err.Error()))
+	case AzurePipelines:
+		return fmt.Sprintf("##vso[task.logissue type=error;sourcepath=%s]%s", escapeAzure(file), escapeAzure(err.Error()))
+	case TeamCity:
+		return serviceMessage("buildProblem", "description", filepath.ToSlash(file)+": "+err.Error())
+	default:
+		return fmt.Sprintf("%s: error: %v", filepath.ToSlash(file), err)
+	}
+}
+
+// Progress formats the progress of a build after file, the done'th of total,
+// has been built: a progress message on TeamCity and the task's percentage
+// on Azure Pipelines. Other providers show no progress and get ""
+func (p Provider) Progress(file string, done, total int) string {
+	message := fmt.Sprintf("Built %d of %d file(s): %s", done, total, filepath.Base(file))
+
+	switch p {
+	case TeamCity:
+		// progressMessage takes a single value rather than attributes
+		return fmt.Sprintf("##teamcity[progressMessage '%s']", escapeTeamCity(message))
+	case AzurePipelines:
+		return fmt.Sprintf("##vso[task.setprogress value=%d;]%s", done*100/max(total, 1), escapeAzure(message))
+	default:
+		return ""
+	}
+}
+
+// SectionStart formats the start of a collapsible section of the job log
+// holding the output of building file, on GitLab. Other providers have no
+// sections and get ""
+func (p Provider) SectionStart(file string, at time.Time) string {
+	if p != GitLab {
+		return ""
+	}
+
+	return fmt.Sprintf("\x1b[0Ksection_start:%d:%s\r\x1b[0KBuilding %s", at.Unix(), sectionName(file), filepath.ToSlash(file))
+}
+
+// SectionEnd formats the end of the section started by SectionStart
+func (p Provider) SectionEnd(file string, at time.Time) string {
+	if p != GitLab {
+		return ""
+	}
+
+	return fmt.Sprintf("\x1b[0Ksection_end:%d:%s\r\x1b[0K", at.Unix(), sectionName(file))
+}
+
+// sectionName returns the GitLab section name for a file, which may only
+// hold letters, digits, '_', '.' and '-'
+func sectionName(file string) string {
+	return "spc_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, filepath.ToSlash(file))
+}
+
+// escapeData escapes a GitHub workflow command message
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a GitHub workflow command property value
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// escapeAzure escapes an Azure Pipelines logging command value
+func escapeAzure(s string) string {
+	return strings.NewReplacer("%", "%AZP25", ";", "%3B", "]", "%5D", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// Summary counts the outcome of a build
+type Summary struct {
+	Files    int
+	Compiled int
+	Cached   int
+	Failed   int
+	Duration time.Duration
+	Failures []string
+
+	// Results of each file, for the providers that list them
+	Results []Result
+}
+
+// Result is the outcome of building one file
+type Result struct {
+	File     string
+	Cached   bool
+	Err      error
+	Duration time.Duration
+
+	// Saved is the compile time a cache hit avoided, when known
+	Saved time.Duration
+
+	// Errors are the compiler's error lines for a failed file
+	Errors []string
+}
+
+// String formats the summary as a single key=value line that is easy to
+// grep for in build logs
+func (s Summary) String() string {
+	return fmt.Sprintf("spc summary: files=%d compiled=%d cached=%d failed=%d duration=%s",
+		s.Files, s.Compiled, s.Cached, s.Failed, s.Duration.Round(time.Millisecond))
+}
+
+// WriteStepSummary adds the summary to the build's page: the GitHub Actions
+// job summary (GITHUB_STEP_SUMMARY) or a Buildkite annotation. It does
+// nothing for other providers
+func (p Provider) WriteStepSummary(s Summary) error {
+	switch p {
+	case GitHubActions:
+		return writeGitHubSummary(s)
+	case Buildkite:
+		return annotateBuildkite(s)
+	default:
+		return nil
+	}
+}
+
+// writeSummaryTable writes the heading and table of counts of a Markdown
+// summary
+func writeSummaryTable(b *strings.Builder, s Summary) {
+	b.WriteString("### SIMPL+ build\n\n")
+	b.WriteString("| Files | Compiled | Cached | Failed | Duration |\n")
+	b.WriteString("| ---: | ---: | ---: | ---: | ---: |\n")
+	fmt.Fprintf(b, "| %d | %d | %d | %d | %s |\n", s.Files, s.Compiled, s.Cached, s.Failed, s.Duration.Round(time.Millisecond))
+}
+
+// writeGitHubSummary appends the summary to the GitHub Actions job summary
+func writeGitHubSummary(s Summary) error {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return nil
+	}
+
+	var b strings.Builder
+	writeSummaryTable(&b, s)
+
+	if len(s.Failures) > 0 {
+		b.WriteString("\nFailed:\n\n")
+		for _, f := range s.Failures {
+			fmt.Fprintf(&b, "- `%s`\n", f)
+		}
+	}
+
+	b.WriteString("\n")
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
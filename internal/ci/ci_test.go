@@ -0,0 +1,206 @@
+package ci
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/diag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearEnv unsets every variable Detect looks at
+func clearEnv(t *testing.T) {
+	for _, name := range []string{"GITHUB_ACTIONS", "GITLAB_CI", "TF_BUILD", "JENKINS_URL", "TEAMCITY_VERSION", "BUILDKITE", "CI"} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want Provider
+	}{
+		{"none", nil, None},
+		{"github", map[string]string{"GITHUB_ACTIONS": "true", "CI": "true"}, GitHubActions},
+		{"gitlab", map[string]string{"GITLAB_CI": "true"}, GitLab},
+		{"azure", map[string]string{"TF_BUILD": "True"}, AzurePipelines},
+		{"jenkins", map[string]string{"JENKINS_URL": "http://jenkins/"}, Jenkins},
+		{"teamcity", map[string]string{"TEAMCITY_VERSION": "2024.12"}, TeamCity},
+		{"buildkite", map[string]string{"BUILDKITE": "true", "CI": "true"}, Buildkite},
+		{"generic", map[string]string{"CI": "1"}, Generic},
+		{"ci false", map[string]string{"CI": "false"}, None},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			assert.Equal(t, tt.want, Detect())
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("GITLAB_CI", "true")
+
+	p, err := Parse("auto")
+	require.NoError(t, err)
+	assert.Equal(t, GitLab, p)
+
+	p, err = Parse("none")
+	require.NoError(t, err)
+	assert.False(t, p.Active())
+
+	p, err = Parse("azure")
+	require.NoError(t, err)
+	assert.Equal(t, AzurePipelines, p)
+
+	_, err = Parse("travis")
+	assert.ErrorContains(t, err, "unknown CI provider")
+}
+
+func TestParseReport(t *testing.T) {
+	p, err := ParseReport("teamcity")
+	require.NoError(t, err)
+	assert.Equal(t, TeamCity, p)
+
+	p, err = ParseReport("")
+	require.NoError(t, err)
+	assert.False(t, p.Active())
+
+	_, err = ParseReport("github")
+	assert.ErrorContains(t, err, `unknown report "github" (expected teamcity)`)
+}
+
+func TestAnnotate(t *testing.T) {
+	d := diag.Diagnostic{File: "src/a.usp", Line: 14, Column: 3, Severity: diag.SeverityError, Code: "1300", Message: "Undefined variable: x"}
+
+	assert.Equal(t, "::error file=src/a.usp,line=14,col=3,title=SIMPL+ error 1300::Undefined variable: x", GitHubActions.Annotate(d))
+	assert.Equal(t, "##vso[task.logissue type=error;sourcepath=src/a.usp;linenumber=14;columnnumber=3;code=1300]Undefined variable: x", AzurePipelines.Annotate(d))
+	assert.Equal(t, d.String(), GitLab.Annotate(d))
+
+	d.Message = "100% wrong\nreally"
+	assert.Equal(t, "::error file=src/a.usp,line=14,col=3,title=SIMPL+ error 1300::100%25 wrong%0Areally", GitHubActions.Annotate(d))
+}
+
+func TestProgress(t *testing.T) {
+	assert.Equal(t, "##vso[task.setprogress value=40;]Built 2 of 5 file(s): a.usp", AzurePipelines.Progress("src/a.usp", 2, 5))
+	assert.Equal(t, "##teamcity[progressMessage 'Built 2 of 5 file(s): a.usp']", TeamCity.Progress("src/a.usp", 2, 5))
+	assert.Empty(t, GitHubActions.Progress("src/a.usp", 2, 5))
+}
+
+func TestSection(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+
+	assert.Equal(t, "\x1b[0Ksection_start:1700000000:spc_src_my_room.usp\r\x1b[0KBuilding src/my room.usp", GitLab.SectionStart("src/my room.usp", at))
+	assert.Equal(t, "\x1b[0Ksection_end:1700000000:spc_src_my_room.usp\r\x1b[0K", GitLab.SectionEnd("src/my room.usp", at))
+	assert.Empty(t, GitHubActions.SectionStart("a.usp", at))
+	assert.Empty(t, TeamCity.SectionEnd("a.usp", at))
+}
+
+func TestAnnotateFailure(t *testing.T) {
+	err := errors.New("compiler not found")
+
+	assert.Equal(t, "::error file=a.usp,title=spc::compiler not found", GitHubActions.AnnotateFailure("a.usp", err))
+	assert.Equal(t, "##vso[task.logissue type=error;sourcepath=a.usp]compiler not found", AzurePipelines.AnnotateFailure("a.usp", err))
+	assert.Equal(t, "a.usp: error: compiler not found", Jenkins.AnnotateFailure("a.usp", err))
+}
+
+func TestSummary(t *testing.T) {
+	s := Summary{Files: 3, Compiled: 1, Cached: 1, Failed: 1, Duration: 1500 * time.Millisecond, Failures: []string{"b.usp"}}
+	assert.Equal(t, "spc summary: files=3 compiled=1 cached=1 failed=1 duration=1.5s", s.String())
+
+	path := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv("GITHUB_STEP_SUMMARY", path)
+
+	require.NoError(t, GitLab.WriteStepSummary(s))
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "only GitHub Actions has a step summary")
+
+	require.NoError(t, GitHubActions.WriteStepSummary(s))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "| 3 | 1 | 1 | 1 | 1.5s |")
+	assert.Contains(t, string(data), "- `b.usp`")
+}
+
+func TestTeamCity(t *testing.T) {
+	d := diag.Diagnostic{File: "src/a.usp", Line: 14, Severity: diag.SeverityWarning, Code: "1300", Message: "Don't [do] this"}
+	assert.Equal(t, "##teamcity[message text='src/a.usp(14): warning 1300: Don|'t |[do|] this' status='WARNING']", TeamCity.Annotate(d))
+	assert.Equal(t, "##teamcity[buildProblem description='a.usp: compiler not found']", TeamCity.AnnotateFailure("a.usp", errors.New("compiler not found")))
+
+	s := Summary{Files: 2, Compiled: 1, Cached: 1, Duration: 1500 * time.Millisecond, Results: []Result{
+		{File: "a.usp", Duration: time.Second},
+		{File: "b.usp", Cached: true, Duration: 5 * time.Millisecond},
+	}}
+
+	var b strings.Builder
+	TeamCity.WriteResults(&b, s)
+	assert.Equal(t, `##teamcity[testSuiteStarted name='SIMPL+']
+##teamcity[testStarted name='a.usp']
+##teamcity[testFinished name='a.usp' duration='1000']
+##teamcity[testStarted name='b.usp']
+##teamcity[testStdOut name='b.usp' out='Restored from the build cache']
+##teamcity[testFinished name='b.usp' duration='5']
+##teamcity[testSuiteFinished name='SIMPL+']
+##teamcity[buildStatisticValue key='spc.files' value='2']
+##teamcity[buildStatisticValue key='spc.compiled' value='1']
+##teamcity[buildStatisticValue key='spc.cached' value='1']
+##teamcity[buildStatisticValue key='spc.failed' value='0']
+##teamcity[buildStatisticValue key='spc.durationMs' value='1500']
+`, b.String())
+
+	s.Results[0].Err = errors.New("2 errors")
+	b.Reset()
+	TeamCity.WriteResults(&b, s)
+	assert.Contains(t, b.String(), "##teamcity[testFailed name='a.usp' message='2 errors']")
+
+	b.Reset()
+	Jenkins.WriteResults(&b, s)
+	assert.Empty(t, b.String())
+}
+
+func TestEscapeTeamCity(t *testing.T) {
+	assert.Equal(t, "src/a.usp", escapeTeamCity("src/a.usp"))
+	assert.Equal(t, "||x|' |[y|]|n|r", escapeTeamCity("|x' [y]\n\r"))
+	assert.Equal(t, "Caf|0x00E9 |0x2192 |0xD83D|0xDE00", escapeTeamCity("Café → 😀"))
+}
+
+func TestBuildkite(t *testing.T) {
+	s := Summary{
+		Files: 4, Compiled: 1, Cached: 2, Failed: 1, Duration: 1500 * time.Millisecond,
+		Failures: []string{"src/c.usp"},
+		Results: []Result{
+			{File: "src/a.usp"},
+			{File: "src/b.usp", Cached: true, Saved: 40 * time.Second},
+			{File: "src/d.usp", Cached: true, Saved: 20 * time.Second},
+			{File: "src/c.usp", Err: errors.New("compilation failed"), Errors: []string{"e1", "e2", "e3", "e4"}},
+		},
+	}
+
+	md := buildkiteMarkdown(s)
+	assert.Contains(t, md, "| 4 | 1 | 2 | 1 | 1.5s |")
+	assert.Contains(t, md, "Cache: 2 of 3 file(s) restored (66% hit rate), saving 1m0s of compiling")
+	assert.Contains(t, md, "#### ✗ `src/c.usp`\n\n```\ne1\ne2\ne3\n```\n\nand 1 more error(s)\n")
+
+	cmd := buildkiteAnnotate(s, "0190-abc")
+	assert.Equal(t, []string{"buildkite-agent", "annotate", "--style", "error", "--context", "spc-0190-abc"}, cmd.Args)
+
+	// A failure without compiler errors shows its error instead
+	s.Results = []Result{{File: "x.usp", Err: errors.New("compiler not found")}}
+	assert.Contains(t, buildkiteMarkdown(s), "```\ncompiler not found\n```")
+
+	s.Failed = 0
+	assert.Contains(t, buildkiteAnnotate(s, "").Args, "success")
+	assert.Contains(t, buildkiteAnnotate(s, "").Args, "spc")
+}
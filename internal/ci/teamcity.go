@@ -0,0 +1,107 @@
+package ci
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+// teamCitySuite is the test suite each built file is reported in, so TeamCity
+// lists every module's result on its Tests tab
+const teamCitySuite = "SIMPL+"
+
+// serviceMessage formats a TeamCity service message from its name and
+// attribute name, value pairs
+func serviceMessage(name string, attrs ...string) string {
+	var b strings.Builder
+	b.WriteString("##teamcity[" + name)
+
+	for i := 0; i+1 < len(attrs); i += 2 {
+		fmt.Fprintf(&b, " %s='%s'", attrs[i], escapeTeamCity(attrs[i+1]))
+	}
+
+	b.WriteString("]")
+
+	return b.String()
+}
+
+// teamCityEscaper escapes the ASCII characters special in service messages
+var teamCityEscaper = strings.NewReplacer("|", "||", "'", "|'", "\n", "|n", "\r", "|r", "[", "|[", "]", "|]")
+
+// escapeTeamCity escapes a service message attribute value. Characters
+// outside ASCII become |0xNNNN (UTF-16 code units), so file names and
+// messages survive agents whose console encoding isn't UTF-8
+func escapeTeamCity(s string) string {
+	s = teamCityEscaper.Replace(s)
+
+	var b strings.Builder
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+			continue
+		}
+
+		for _, unit := range utf16.Encode([]rune{r}) {
+			fmt.Fprintf(&b, "|0x%04X", unit)
+		}
+	}
+
+	return b.String()
+}
+
+// teamCityMessage formats a diagnostic as a build log message with the
+// severity's status
+func teamCityMessage(d diag.Diagnostic) string {
+	status := "ERROR"
+	if d.Severity == diag.SeverityWarning {
+		status = "WARNING"
+	}
+
+	return serviceMessage("message", "text", d.String(), "status", status)
+}
+
+// WriteResults reports each file's result as a test, and the summary's
+// counts as build statistics, for providers that show them (TeamCity). It
+// does nothing for other providers
+func (p Provider) WriteResults(w io.Writer, s Summary) {
+	if p != TeamCity {
+		return
+	}
+
+	fmt.Fprintln(w, serviceMessage("testSuiteStarted", "name", teamCitySuite))
+
+	for _, r := range s.Results {
+		name := filepath.ToSlash(r.File)
+		fmt.Fprintln(w, serviceMessage("testStarted", "name", name))
+
+		switch {
+		case r.Err != nil:
+			fmt.Fprintln(w, serviceMessage("testFailed", "name", name, "message", r.Err.Error()))
+		case r.Cached:
+			fmt.Fprintln(w, serviceMessage("testStdOut", "name", name, "out", "Restored from the build cache"))
+		}
+
+		fmt.Fprintln(w, serviceMessage("testFinished", "name", name, "duration", fmt.Sprint(r.Duration.Milliseconds())))
+	}
+
+	fmt.Fprintln(w, serviceMessage("testSuiteFinished", "name", teamCitySuite))
+
+	for _, stat := range []struct {
+		key   string
+		value int64
+	}{
+		{"spc.files", int64(s.Files)},
+		{"spc.compiled", int64(s.Compiled)},
+		{"spc.cached", int64(s.Cached)},
+		{"spc.failed", int64(s.Failed)},
+		{"spc.durationMs", s.Duration.Round(time.Millisecond).Milliseconds()},
+	} {
+		fmt.Fprintln(w, serviceMessage("buildStatisticValue", "key", stat.key, "value", fmt.Sprint(stat.value)))
+	}
+}
@@ -1,35 +1,72 @@
 package compiler
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/logger"
 	"github.com/Norgate-AV/spc/internal/utils"
 )
 
-// Commander interface for testing
-type Commander interface {
-	Run() error
+// Invocation is a single run of the compiler
+type Invocation struct {
+	// Config is the configuration for this run; its CompilerPath and Target
+	// are narrowed to the compiler and series the run covers
+	Config *config.Config
+
+	// Args are the compiler command line arguments
+	Args []string
 }
 
 // CommandBuilder handles building compiler commands
 type CommandBuilder struct {
-	execCommand func(name string, args ...string) Commander
+	runner Runner
+	stdout io.Writer
+	stderr io.Writer
 }
 
 // NewCommandBuilder creates a new command builder
 func NewCommandBuilder() *CommandBuilder {
 	return &CommandBuilder{
-		execCommand: func(name string, args ...string) Commander {
-			return exec.Command(name, args...)
-		},
+		runner: ExecRunner{},
 	}
 }
 
+// SetRunner replaces how the compiler is run (nil restores ExecRunner)
+func (cb *CommandBuilder) SetRunner(r Runner) {
+	if r == nil {
+		r = ExecRunner{}
+	}
+
+	cb.runner = r
+}
+
+// SetOutput redirects compiler output (nil restores os.Stdout/os.Stderr)
+func (cb *CommandBuilder) SetOutput(stdout, stderr io.Writer) {
+	cb.stdout = stdout
+	cb.stderr = stderr
+}
+
+// outputs returns the writers compiler output is sent to
+func (cb *CommandBuilder) outputs() (stdout, stderr io.Writer) {
+	stdout, stderr = cb.stdout, cb.stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	return stdout, stderr
+}
+
 // BuildCommandArgs builds the command arguments for the compiler
 func (cb *CommandBuilder) BuildCommandArgs(cfg *config.Config, files []string) ([]string, error) {
 	series := utils.ParseTarget(cfg.Target)
@@ -43,7 +80,7 @@ func (cb *CommandBuilder) BuildCommandArgs(cfg *config.Config, files []string) (
 
 	for _, folder := range cfg.UserFolders {
 		if folder != "" {
-			cmdArgs = append(cmdArgs, "/usersplusfolder", folder)
+			cmdArgs = append(cmdArgs, "/usersplusfolder", utils.LongPath(folder))
 		}
 	}
 
@@ -55,7 +92,7 @@ func (cb *CommandBuilder) BuildCommandArgs(cfg *config.Config, files []string) (
 			return nil, fmt.Errorf("failed to resolve absolute path for %s: %w", file, err)
 		}
 
-		cmdArgs = append(cmdArgs, absFile)
+		cmdArgs = append(cmdArgs, utils.LongPath(absFile))
 	}
 
 	if cfg.OutputFile != "" {
@@ -66,20 +103,63 @@ func (cb *CommandBuilder) BuildCommandArgs(cfg *config.Config, files []string) (
 		cmdArgs = append(cmdArgs, "/silent")
 	}
 
+	cmdArgs = append(cmdArgs, cfg.ExtraArgs...)
+
 	return cmdArgs, nil
 }
 
-// ExecuteCommand executes the compiler command
-func (cb *CommandBuilder) ExecuteCommand(compilerPath string, cmdArgs []string) error {
-	c := cb.execCommand(compilerPath, cmdArgs...)
-	if cmd, ok := c.(*exec.Cmd); ok {
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+// BuildInvocations builds every compiler run needed to compile the files.
+// Series mapped to different compilers (compiler_paths) are compiled in
+// separate runs
+func (cb *CommandBuilder) BuildInvocations(cfg *config.Config, files []string) ([]Invocation, error) {
+	series := utils.ParseTarget(cfg.Target)
+	if len(series) == 0 {
+		return nil, fmt.Errorf("invalid target series")
+	}
+
+	// Group series by compiler, keeping the order in which compilers first appear
+	var compilers []string
+	targets := make(map[string]string)
+
+	for _, s := range series {
+		path := cfg.CompilerFor(s)
+		if _, ok := targets[path]; !ok {
+			compilers = append(compilers, path)
+		}
+
+		targets[path] += strings.TrimPrefix(s, "series")
+	}
+
+	var invocations []Invocation
+	for _, path := range compilers {
+		runCfg := *cfg
+		runCfg.CompilerPath = path
+		runCfg.Target = targets[path]
+
+		args, err := cb.BuildCommandArgs(&runCfg, files)
+		if err != nil {
+			return nil, err
+		}
+
+		invocations = append(invocations, Invocation{Config: &runCfg, Args: args})
+	}
+
+	return invocations, nil
+}
+
+// ExecuteCommand executes the compiler command, stopping the compiler when
+// ctx is cancelled
+func (cb *CommandBuilder) ExecuteCommand(ctx context.Context, compilerPath string, cmdArgs []string) error {
+	stdout, stderr := cb.outputs()
+
+	err := cb.runner.Run(ctx, compilerPath, cmdArgs, stdout, stderr)
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return ctxErr
 	}
 
-	err := c.Run()
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
+		var exitErr interface{ ExitCode() int }
+		if errors.As(err, &exitErr) {
 			code := exitErr.ExitCode()
 			if IsSuccess(code) {
 				// Crestron compiler success (may have warnings)
@@ -87,7 +167,7 @@ func (cb *CommandBuilder) ExecuteCommand(compilerPath string, cmdArgs []string)
 			}
 
 			// Print descriptive error message
-			fmt.Fprintf(os.Stderr, "Compilation failed (exit code %d): %s\n", code, GetErrorMessage(code))
+			logger.Errorf("Compilation failed (exit code %d): %s", code, GetErrorMessage(code))
 		}
 
 		return err
@@ -98,6 +178,7 @@ func (cb *CommandBuilder) ExecuteCommand(compilerPath string, cmdArgs []string)
 
 // PrintBuildInfo prints verbose build information
 func (cb *CommandBuilder) PrintBuildInfo(cfg *config.Config, series []string, args []string, cmdArgs []string) {
-	fmt.Printf("Compiler: %s\nTarget: %s\nSeries: %v\nFiles: %v\nOut: %s\nUsersPlusFolders: %v\nCommand: %s %s\n",
+	stdout, _ := cb.outputs()
+	fmt.Fprintf(stdout, "Compiler: %s\nTarget: %s\nSeries: %v\nFiles: %v\nOut: %s\nUsersPlusFolders: %v\nCommand: %s %s\n",
 		cfg.CompilerPath, cfg.Target, series, args, cfg.OutputFile, cfg.UserFolders, cfg.CompilerPath, strings.Join(cmdArgs, " "))
 }
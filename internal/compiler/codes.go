@@ -1,5 +1,7 @@
 package compiler
 
+import "sort"
+
 // ErrorCodes maps Crestron SIMPL+ compiler exit codes to their descriptions
 var ErrorCodes = map[int]string{
 	0:   "Success",
@@ -36,6 +38,53 @@ var ErrorCodes = map[int]string{
 	130: "Error found while signing. Unable to cleanup unsigned assembly.",
 }
 
+// Remediations maps compiler exit codes to suggested steps for resolving them
+var Remediations = map[int]string{
+	0:   "No action needed.",
+	100: "Re-run with --verbose and without --silent to see the compiler output, and check the compilation log (--out).",
+	101: "Check that the source file exists, is readable and is not locked by another program (e.g., SIMPL+ editor).",
+	102: "Check that the SPlsWork directory next to the source is writable and not open in another program.",
+	103: "Check that the SPlsWork directory is writable; delete a stale globals.h if one is locked.",
+	104: "The SIMPL installation may be incomplete. Repair or reinstall SIMPL Windows.",
+	105: "Check free disk space and that SPlsWork is writable.",
+	106: "Fix the syntax or semantic errors reported by the compiler. Use --out to capture the full error list.",
+	107: "Check #USER_LIBRARY/#CRESTRON_LIBRARY references and that required libraries exist in the user SIMPL+ folders (-u).",
+	108: "Check permissions on the SPlsWork directory and close programs that may hold output files open.",
+	109: "Check permissions on the SPlsWork directory; the GNU toolchain files may be missing from the SIMPL install.",
+	110: "The 2-Series GNU compiler could not be started. Repair the SIMPL installation or drop series 2 from the target.",
+	111: "Check the module's NVRAM declarations; a clean rebuild (delete SPlsWork) often resolves this.",
+	112: "Install the Crestron 2-Series GNU toolchain, or drop series 2 from the target (e.g., --target 34).",
+	113: "The .NET compiler could not be loaded. Repair the .NET Framework and SIMPL installation.",
+	114: "The .NET compiler failed to initialize. Repair the SIMPL installation.",
+	115: "The .NET compiler returned invalid results. Re-run the build; if it persists, repair the SIMPL installation.",
+	116: "Compilation succeeded with warnings. Review the compiler output if needed.",
+	117: "The .NET compiler returned invalid results. Re-run the build; if it persists, repair the SIMPL installation.",
+	118: "Include.dat could not be read. Repair the SIMPL installation or update the Crestron Database.",
+	119: "The generated C# file is missing. Check for earlier translation errors and that SPlsWork is writable.",
+	120: "The NVRAM utility could not be started. Repair the SIMPL installation.",
+	121: "The NVRAM utility produced no output. Perform a clean rebuild (delete SPlsWork).",
+	122: "Check that the temp directory (%TEMP%) is writable.",
+	123: "Internal compiler error. Re-run the build; if it persists, repair the SIMPL installation.",
+	124: "Check that the temp directory (%TEMP%) is writable and has free space.",
+	125: "The signing certificate is invalid. Repair the SIMPL installation.",
+	126: "Assembly signing failed. Check the signing configuration and repair the SIMPL installation if needed.",
+	127: "A temporary file could not be removed. Check %TEMP% for locked files; the build output may still be valid.",
+	128: "The signing registry key is missing. Repair or reinstall SIMPL Windows.",
+	129: "CAPICOM is not registered. Reinstall SIMPL Windows or register capicom.dll with regsvr32.",
+	130: "Signing failed and cleanup was incomplete. Delete SPlsWork outputs for the module and rebuild.",
+}
+
+// Codes returns all known exit codes in ascending order
+func Codes() []int {
+	codes := make([]int, 0, len(ErrorCodes))
+	for code := range ErrorCodes {
+		codes = append(codes, code)
+	}
+
+	sort.Ints(codes)
+	return codes
+}
+
 // IsSuccess returns true if the exit code indicates successful compilation
 func IsSuccess(code int) bool {
 	return code == 0 || code == 116
@@ -49,3 +98,8 @@ func GetErrorMessage(code int) string {
 
 	return "Unknown error"
 }
+
+// GetRemediation returns the suggested remediation for a given exit code, or an empty string if unknown
+func GetRemediation(code int) string {
+	return Remediations[code]
+}
@@ -127,3 +127,20 @@ func TestErrorCodes_Coverage(t *testing.T) {
 		assert.NotEmpty(t, msg, "Code %d should have a non-empty message", code)
 	}
 }
+
+func TestRemediations_Coverage(t *testing.T) {
+	// Every known error code should have remediation text
+	for code := range ErrorCodes {
+		assert.NotEmpty(t, GetRemediation(code), "Code %d should have a remediation", code)
+	}
+
+	assert.Empty(t, GetRemediation(999))
+}
+
+func TestCodes(t *testing.T) {
+	codes := Codes()
+	assert.Len(t, codes, len(ErrorCodes))
+	assert.Equal(t, 0, codes[0])
+	assert.Equal(t, 130, codes[len(codes)-1])
+	assert.IsIncreasing(t, codes)
+}
@@ -0,0 +1,111 @@
+package compiler
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// ContainerCommand wraps a compiler run so that it executes inside the
+// container image configured in cfg.Docker. The project directory (the
+// working directory when there is no project config) is mounted at the
+// workspace, and absolute paths under it in the arguments are translated to
+// the container's view. The compiler writes SPlsWork through the mount, so
+// its outputs land on the host where the build picks them up as usual
+func ContainerCommand(cfg *config.Config, args []string) (string, []string, error) {
+	root := cfg.ProjectDir
+	if root == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", nil, err
+		}
+
+		root = cwd
+	}
+
+	m := mount{host: root, container: cfg.Docker.Workspace, wine: cfg.Docker.Wine}
+
+	translated := make([]string, 0, len(args))
+	for i, arg := range args {
+		if !m.isPath(arg, i > 0 && pathFlags[strings.ToLower(args[i-1])]) {
+			translated = append(translated, arg)
+			continue
+		}
+
+		p, err := m.translate(arg)
+		if err != nil {
+			return "", nil, err
+		}
+
+		translated = append(translated, p)
+	}
+
+	run := []string{"run", "--rm", "-v", root + ":" + cfg.Docker.Workspace, "-w", cfg.Docker.Workspace}
+	run = append(run, cfg.Docker.Args...)
+	run = append(run, cfg.Docker.Image)
+
+	if cfg.Docker.Wine {
+		run = append(run, "wine")
+	}
+
+	run = append(run, cfg.Docker.CompilerPath)
+	run = append(run, translated...)
+
+	return cfg.Docker.Engine, run, nil
+}
+
+// mount maps a host directory to its path inside the container
+type mount struct {
+	host      string
+	container string
+	wine      bool
+}
+
+// pathFlags are the compiler flags whose value is a path
+var pathFlags = map[string]bool{
+	"/usersplusfolder": true,
+	"/out":             true,
+}
+
+// isPath reports whether a compiler argument is a host path to translate.
+// Compiler flags also start with "/", so on Unix hosts an absolute argument
+// only counts as a path if it follows a path flag, is under the mount or exists
+func (m mount) isPath(arg string, afterFlag bool) bool {
+	if !filepath.IsAbs(arg) {
+		return false
+	}
+
+	if afterFlag || m.contains(arg) {
+		return true
+	}
+
+	_, err := os.Stat(arg)
+	return err == nil
+}
+
+// contains reports whether a host path is under the mount
+func (m mount) contains(hostPath string) bool {
+	rel, err := filepath.Rel(m.host, hostPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// translate returns the container path of a host path under the mount. Under
+// wine, paths are given on the Z: drive, which wine maps to the container root
+func (m mount) translate(hostPath string) (string, error) {
+	if !m.contains(hostPath) {
+		return "", fmt.Errorf("%s is outside the directory mounted in the container (%s)", hostPath, m.host)
+	}
+
+	rel, _ := filepath.Rel(m.host, hostPath)
+
+	p := path.Join(filepath.ToSlash(m.container), filepath.ToSlash(rel))
+	if m.wine {
+		return `Z:` + strings.ReplaceAll(p, "/", `\`), nil
+	}
+
+	return p, nil
+}
@@ -0,0 +1,57 @@
+package compiler
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+func TestContainerCommand(t *testing.T) {
+	root := t.TempDir()
+	cfg := &config.Config{
+		ProjectDir: root,
+		Docker: config.DockerConfig{
+			Image:        "example/simpl",
+			Engine:       "docker",
+			Workspace:    "C:/workspace",
+			CompilerPath: "C:/Simpl/SPlusCC.exe",
+			Args:         []string{"--isolation", "process"},
+		},
+	}
+
+	args := []string{"/target", "34", "/usersplusfolder", filepath.Join(root, "lib"), "/rebuild", filepath.Join(root, "src", "a.usp"), "/out", filepath.Join(root, "build.log")}
+
+	name, run, err := ContainerCommand(cfg, args)
+	require.NoError(t, err)
+	assert.Equal(t, "docker", name)
+	assert.Equal(t, []string{
+		"run", "--rm", "-v", root + ":C:/workspace", "-w", "C:/workspace", "--isolation", "process", "example/simpl",
+		"C:/Simpl/SPlusCC.exe", "/target", "34", "/usersplusfolder", "C:/workspace/lib", "/rebuild", "C:/workspace/src/a.usp", "/out", "C:/workspace/build.log",
+	}, run)
+}
+
+func TestContainerCommand_Wine(t *testing.T) {
+	root := t.TempDir()
+	cfg := &config.Config{
+		ProjectDir: root,
+		Docker:     config.DockerConfig{Image: "example/wine", Engine: "podman", Workspace: "/workspace", CompilerPath: "C:/Simpl/SPlusCC.exe", Wine: true},
+	}
+
+	_, run, err := ContainerCommand(cfg, []string{"/rebuild", filepath.Join(root, "a.usp")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"wine", "C:/Simpl/SPlusCC.exe", "/rebuild", `Z:\workspace\a.usp`}, run[len(run)-4:])
+}
+
+func TestContainerCommand_OutsideWorkspace(t *testing.T) {
+	cfg := &config.Config{
+		ProjectDir: t.TempDir(),
+		Docker:     config.DockerConfig{Image: "example/simpl", Engine: "docker", Workspace: "C:/workspace"},
+	}
+
+	_, _, err := ContainerCommand(cfg, []string{"/usersplusfolder", filepath.Join(t.TempDir(), "lib")})
+	assert.ErrorContains(t, err, "outside the directory mounted")
+}
@@ -0,0 +1,117 @@
+package compiler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Runner runs the compiler, or the container runtime that runs it. ExecRunner
+// starts a process; FakeRunner simulates the compiler, so tools and tests can
+// exercise a build without one
+type Runner interface {
+	// Run runs name with args, sending its output to stdout and stderr, and
+	// stops it when ctx is cancelled. A non-zero exit is reported as an error
+	// with an ExitCode() int method, such as *exec.ExitError or *ExitError
+	Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error
+}
+
+// ExecRunner is the Runner that starts the compiler as a process
+type ExecRunner struct{}
+
+// killWait is how long a cancelled run waits for the output of processes the
+// compiler started, which outlive it, before giving up on them
+const killWait = time.Second
+
+// Run runs name as a process, killed when ctx is cancelled
+func (ExecRunner) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	cmd.WaitDelay = killWait
+
+	return cmd.Run()
+}
+
+// ExitError is a non-zero exit code of a simulated compiler run
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode returns the exit code
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// Call is one run of a FakeRunner
+type Call struct {
+	Name string
+	Args []string
+}
+
+// Arg returns the value following flag in the call's arguments (e.g. the
+// compile log of "/out"), or "" when flag isn't given
+func (c Call) Arg(flag string) string {
+	i := slices.Index(c.Args, flag)
+	if i < 0 || i+1 >= len(c.Args) {
+		return ""
+	}
+
+	return c.Args[i+1]
+}
+
+// FakeRunner is a Runner that records each run and simulates the compiler
+// instead of starting it. It is safe for concurrent use
+type FakeRunner struct {
+	// Respond, if set, simulates the compiler for a call: it may write
+	// output, or the compile log named by call.Arg("/out"), and returns the
+	// exit code, or an error when the compiler could not be started.
+	// Without it, every run succeeds without output
+	Respond func(call Call, stdout, stderr io.Writer) (int, error)
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Run records the call and responds to it, unless ctx is cancelled
+func (f *FakeRunner) Run(ctx context.Context, name string, args []string, stdout, stderr io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	call := Call{Name: name, Args: slices.Clone(args)}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+
+	if f.Respond == nil {
+		return nil
+	}
+
+	code, err := f.Respond(call, stdout, stderr)
+	if err != nil {
+		return err
+	}
+
+	if code != 0 {
+		return &ExitError{Code: code}
+	}
+
+	return nil
+}
+
+// Calls returns the runs made so far, in order
+func (f *FakeRunner) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.calls)
+}
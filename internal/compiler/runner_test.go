@@ -0,0 +1,47 @@
+package compiler
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCall_Arg(t *testing.T) {
+	call := Call{Name: "SPlusCC.exe", Args: []string{"/target", "series3", "/out", "build.log", "/silent"}}
+
+	assert.Equal(t, "build.log", call.Arg("/out"))
+	assert.Equal(t, "series3", call.Arg("/target"))
+	assert.Empty(t, call.Arg("/silent"))
+	assert.Empty(t, call.Arg("/usersplusfolder"))
+}
+
+func TestFakeRunner(t *testing.T) {
+	runner := &FakeRunner{Respond: func(call Call, _, _ io.Writer) (int, error) {
+		if call.Args[0] == "bad.usp" {
+			return 106, nil
+		}
+
+		return 0, nil
+	}}
+
+	var wg sync.WaitGroup
+	for _, file := range []string{"a.usp", "b.usp", "bad.usp"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := runner.Run(context.Background(), "SPlusCC.exe", []string{file}, io.Discard, io.Discard)
+			if file == "bad.usp" {
+				assert.Equal(t, &ExitError{Code: 106}, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.Len(t, runner.Calls(), 3)
+}
@@ -0,0 +1,331 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Hash algorithms for cache keys and artifact comparison (the cache.hash setting)
+const (
+	// HashSHA256 is the default algorithm
+	HashSHA256 = "sha256"
+	// HashBLAKE3 is a cryptographic hash several times faster than SHA256
+	HashBLAKE3 = "blake3"
+	// HashXXH3 is a non-cryptographic hash, faster still
+	HashXXH3 = "xxh3"
+)
+
+// Default retries for artifacts locked by another process (cache.lock_retries
+// and cache.lock_delay)
+const (
+	DefaultLockRetries = 5
+	DefaultLockDelay   = 250 * time.Millisecond
+)
+
+// CacheConfig holds the settings of the cache: section.
+// Disabling the cache (enabled: false) is reflected in Config.NoCache
+type CacheConfig struct {
+	// Directory holding the cache (empty for .spc-cache in the working directory)
+	Dir string
+
+	// Total artifact size in bytes above which the oldest entries are evicted (0 for no limit)
+	MaxSize int64
+
+	// Age after which entries are evicted (0 to keep entries until evicted by size)
+	TTL time.Duration
+
+	// Restore from the cache but never store or evict entries (cache.read_only
+	// or --cache-readonly)
+	ReadOnly bool
+
+	// Remote cache backend
+	Remote RemoteCacheConfig
+
+	// Algorithm used to hash cache keys and compare artifacts (sha256, blake3
+	// or xxh3; empty for sha256)
+	Hash string
+
+	// Times to retry copying an artifact another process has locked (SIMPL
+	// Windows or antivirus on Windows), and the delay before the first retry,
+	// doubled after each attempt
+	LockRetries int
+	LockDelay   time.Duration
+}
+
+// Remote cache backends (the cache.remote.type setting)
+const (
+	// RemoteHTTP is a cache served over HTTP at cache.remote.url (the default)
+	RemoteHTTP = "http"
+	// RemoteGitHubActions is the cache service of the GitHub Actions workflow
+	// spc runs in, which needs no URL
+	RemoteGitHubActions = "github-actions"
+)
+
+// RemoteCacheConfig holds the settings of the cache.remote: section
+type RemoteCacheConfig struct {
+	// Backend of the remote cache (RemoteHTTP or RemoteGitHubActions; empty
+	// for RemoteHTTP)
+	Type string
+
+	// Base URL of the remote cache (empty to disable an http cache)
+	URL string
+
+	// Only read from the remote cache, never upload to it
+	ReadOnly bool
+
+	// How requests to an http cache are authenticated (RemoteAuthNone,
+	// RemoteAuthToken or RemoteAuthOIDC; empty to use a token when one is set)
+	Auth string
+
+	// Audience requested for CI OIDC tokens (empty for DefaultOIDCAudience)
+	Audience string
+
+	// Endpoint an OIDC token is exchanged at for an access token (empty to
+	// send the OIDC token itself)
+	TokenURL string
+
+	// Encrypt archives before uploading them, with the key in SPC_CACHE_KEY
+	// or printed by KeyCommand
+	Encrypt bool
+
+	// Command printing the encryption key, such as a keychain lookup
+	KeyCommand string
+}
+
+// Authentication of remote caches (the cache.remote.auth setting)
+const (
+	// RemoteAuthNone sends no credentials
+	RemoteAuthNone = "none"
+	// RemoteAuthToken sends a static bearer token from the environment
+	RemoteAuthToken = "token"
+	// RemoteAuthOIDC sends the CI system's OIDC identity token, optionally
+	// exchanged at cache.remote.token_url
+	RemoteAuthOIDC = "oidc"
+)
+
+// DefaultOIDCAudience is the audience requested for CI OIDC tokens
+const DefaultOIDCAudience = "spc"
+
+// cacheKeys are the keys allowed in the cache: section
+var cacheKeys = map[string]bool{
+	"enabled":      true,
+	"dir":          true,
+	"max_size":     true,
+	"ttl":          true,
+	"read_only":    true,
+	"remote":       true,
+	"hash":         true,
+	"lock_retries": true,
+	"lock_delay":   true,
+}
+
+// remoteCacheKeys are the keys allowed in the cache.remote: section
+var remoteCacheKeys = map[string]bool{
+	"type":        true,
+	"url":         true,
+	"read_only":   true,
+	"auth":        true,
+	"audience":    true,
+	"token_url":   true,
+	"encrypt":     true,
+	"key_command": true,
+}
+
+// loadCache reads the cache: section from v
+func loadCache(v *viper.Viper) (CacheConfig, error) {
+	c := CacheConfig{
+		Dir:      v.GetString("cache.dir"),
+		ReadOnly: v.GetBool("cache.read_only"),
+		Hash:     v.GetString("cache.hash"),
+		Remote: RemoteCacheConfig{
+			Type:       v.GetString("cache.remote.type"),
+			Auth:       v.GetString("cache.remote.auth"),
+			Audience:   v.GetString("cache.remote.audience"),
+			TokenURL:   v.GetString("cache.remote.token_url"),
+			Encrypt:    v.GetBool("cache.remote.encrypt"),
+			KeyCommand: v.GetString("cache.remote.key_command"),
+			URL:        v.GetString("cache.remote.url"),
+			ReadOnly:   v.GetBool("cache.remote.read_only"),
+		},
+	}
+
+	var err error
+	if c.MaxSize, err = ParseSize(v.GetString("cache.max_size")); err != nil {
+		return c, fmt.Errorf("invalid cache.max_size: %w", err)
+	}
+
+	if c.TTL, err = ParseTTL(v.GetString("cache.ttl")); err != nil {
+		return c, fmt.Errorf("invalid cache.ttl: %w", err)
+	}
+
+	if err := validateRemoteType(c.Remote.Type); err != nil {
+		return c, fmt.Errorf("invalid cache.remote.type: %w", err)
+	}
+
+	if err := validateRemoteURL(c.Remote.URL); err != nil {
+		return c, fmt.Errorf("invalid cache.remote.url: %w", err)
+	}
+
+	if err := validateRemoteAuth(c.Remote.Auth); err != nil {
+		return c, fmt.Errorf("invalid cache.remote.auth: %w", err)
+	}
+
+	if err := validateRemoteURL(c.Remote.TokenURL); err != nil {
+		return c, fmt.Errorf("invalid cache.remote.token_url: %w", err)
+	}
+
+	c.LockRetries, c.LockDelay = DefaultLockRetries, DefaultLockDelay
+
+	if v.IsSet("cache.lock_retries") {
+		if c.LockRetries, err = parseRetries(v.GetString("cache.lock_retries")); err != nil {
+			return c, fmt.Errorf("invalid cache.lock_retries: %w", err)
+		}
+	}
+
+	if v.IsSet("cache.lock_delay") {
+		if c.LockDelay, err = parseDelay(v.GetString("cache.lock_delay")); err != nil {
+			return c, fmt.Errorf("invalid cache.lock_delay: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+// parseRetries parses a retry count (0 for no retries)
+func parseRetries(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("expected a number of retries such as 5")
+	}
+
+	return n, nil
+}
+
+// parseDelay parses a retry delay such as "250ms" or "1s"
+func parseDelay(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("expected a duration such as 250ms or 1s")
+	}
+
+	return d, nil
+}
+
+// validateHash checks a cache.hash algorithm is known
+func validateHash(s string) error {
+	switch s {
+	case "", HashSHA256, HashBLAKE3, HashXXH3:
+		return nil
+	default:
+		return fmt.Errorf("unknown hash algorithm %q (want sha256, blake3 or xxh3)", s)
+	}
+}
+
+// sizeUnits are the suffixes accepted by ParseSize (binary multiples)
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+}
+
+// ParseSize parses a size such as "500MB", "2GB" or "1048576" into bytes.
+// An empty string is 0 (no limit)
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("expected a size such as 500MB or 2GB")
+	}
+
+	return int64(n * float64(multiplier)), nil
+}
+
+// ParseTTL parses a duration such as "72h" or "30d". An empty string is 0 (no expiry)
+func ParseTTL(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("expected a duration such as 72h or 30d")
+		}
+
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("expected a duration such as 72h or 30d")
+	}
+
+	return d, nil
+}
+
+// Enabled reports whether a remote cache is configured
+func (r RemoteCacheConfig) Enabled() bool {
+	return r.Type == RemoteGitHubActions || r.URL != ""
+}
+
+// validateRemoteType checks cache.remote.type names a known backend
+func validateRemoteType(t string) error {
+	switch t {
+	case "", RemoteHTTP, RemoteGitHubActions:
+		return nil
+	default:
+		return fmt.Errorf("unknown remote cache type %q (expected %s or %s)", t, RemoteHTTP, RemoteGitHubActions)
+	}
+}
+
+// validateRemoteAuth checks cache.remote.auth names a known method
+func validateRemoteAuth(auth string) error {
+	switch auth {
+	case "", RemoteAuthNone, RemoteAuthToken, RemoteAuthOIDC:
+		return nil
+	default:
+		return fmt.Errorf("unknown authentication %q (expected %s, %s or %s)", auth, RemoteAuthNone, RemoteAuthToken, RemoteAuthOIDC)
+	}
+}
+
+// validateRemoteURL checks a remote cache URL is an absolute http(s) URL
+func validateRemoteURL(s string) error {
+	if s == "" {
+		return nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("expected an http or https URL: %s", s)
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+	}{
+		{"", 0},
+		{"1024", 1024},
+		{"512B", 512},
+		{"10KB", 10 << 10},
+		{"500MB", 500 << 20},
+		{"500mb", 500 << 20},
+		{"1.5GB", 3 << 29},
+		{"2 GB", 2 << 30},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSize(tt.input)
+		require.NoError(t, err, "ParseSize(%q)", tt.input)
+		assert.Equal(t, tt.want, got, "ParseSize(%q)", tt.input)
+	}
+
+	for _, input := range []string{"lots", "MB", "-1GB", "5XB"} {
+		_, err := ParseSize(input)
+		assert.Error(t, err, "ParseSize(%q)", input)
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"72h", 72 * time.Hour},
+		{"90m", 90 * time.Minute},
+		{"30d", 30 * 24 * time.Hour},
+		{"0.5d", 12 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTTL(tt.input)
+		require.NoError(t, err, "ParseTTL(%q)", tt.input)
+		assert.Equal(t, tt.want, got, "ParseTTL(%q)", tt.input)
+	}
+
+	for _, input := range []string{"forever", "d", "-3d", "-1h"} {
+		_, err := ParseTTL(input)
+		assert.Error(t, err, "ParseTTL(%q)", input)
+	}
+}
+
+func TestLoad_Cache(t *testing.T) {
+	newViper := func() *viper.Viper {
+		v := viper.New()
+		v.Set("compiler_path", "C:/SPlusCC.exe")
+		v.Set("target", "34")
+		return v
+	}
+
+	t.Run("enabled by default", func(t *testing.T) {
+		cfg, err := Load(newViper())
+		require.NoError(t, err)
+		assert.False(t, cfg.NoCache)
+		assert.Equal(t, CacheConfig{LockRetries: DefaultLockRetries, LockDelay: DefaultLockDelay}, cfg.Cache)
+	})
+
+	t.Run("reads the cache section", func(t *testing.T) {
+		v := newViper()
+		v.Set("cache", map[string]any{
+			"dir":          "build/cache",
+			"max_size":     "2GB",
+			"ttl":          "7d",
+			"hash":         "blake3",
+			"lock_retries": 0,
+			"lock_delay":   "1s",
+			"remote":       map[string]any{"url": "https://cache.example.com", "read_only": true},
+		})
+
+		cfg, err := Load(v)
+		require.NoError(t, err)
+
+		dir, _ := filepath.Abs("build/cache")
+		assert.Equal(t, CacheConfig{
+			Dir:         dir,
+			MaxSize:     2 << 30,
+			TTL:         7 * 24 * time.Hour,
+			Hash:        HashBLAKE3,
+			LockRetries: 0,
+			LockDelay:   time.Second,
+			Remote:      RemoteCacheConfig{URL: "https://cache.example.com", ReadOnly: true},
+		}, cfg.Cache)
+		assert.False(t, cfg.NoCache)
+	})
+
+	t.Run("reads the remote cache type", func(t *testing.T) {
+		v := newViper()
+		v.Set("cache.remote.type", RemoteGitHubActions)
+
+		cfg, err := Load(v)
+		require.NoError(t, err)
+		assert.Equal(t, RemoteCacheConfig{Type: RemoteGitHubActions}, cfg.Cache.Remote)
+		assert.True(t, cfg.Cache.Remote.Enabled())
+	})
+
+	t.Run("reads remote cache authentication and encryption", func(t *testing.T) {
+		v := newViper()
+		v.Set("cache.remote", map[string]any{
+			"url":         "https://cache.example.com",
+			"auth":        "oidc",
+			"audience":    "cache.example.com",
+			"token_url":   "https://sts.example.com/token",
+			"encrypt":     true,
+			"key_command": "security find-generic-password -s spc -w",
+		})
+
+		cfg, err := Load(v)
+		require.NoError(t, err)
+		assert.Equal(t, RemoteCacheConfig{
+			URL:        "https://cache.example.com",
+			Auth:       RemoteAuthOIDC,
+			Audience:   "cache.example.com",
+			TokenURL:   "https://sts.example.com/token",
+			Encrypt:    true,
+			KeyCommand: "security find-generic-password -s spc -w",
+		}, cfg.Cache.Remote)
+	})
+
+	t.Run("enabled false disables the cache", func(t *testing.T) {
+		v := newViper()
+		v.Set("cache.enabled", false)
+
+		cfg, err := Load(v)
+		require.NoError(t, err)
+		assert.True(t, cfg.NoCache)
+	})
+
+	t.Run("invalid values are errors", func(t *testing.T) {
+		v := newViper()
+		v.Set("cache.ttl", "forever")
+		_, err := Load(v)
+		assert.ErrorContains(t, err, "invalid cache.ttl")
+
+		v = newViper()
+		v.Set("cache.remote.url", "cache.example.com")
+		_, err = Load(v)
+		assert.ErrorContains(t, err, "invalid cache.remote.url")
+
+		v = newViper()
+		v.Set("cache.remote.auth", "password")
+		_, err = Load(v)
+		assert.ErrorContains(t, err, "invalid cache.remote.auth")
+
+		v = newViper()
+		v.Set("cache.remote.type", "s3")
+		_, err = Load(v)
+		assert.ErrorContains(t, err, "invalid cache.remote.type")
+
+		v = newViper()
+		v.Set("cache.hash", "md5")
+		_, err = Load(v)
+		assert.ErrorContains(t, err, "invalid cache.hash")
+	})
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Norgate-AV/spc/internal/toolchain"
+	"github.com/spf13/cobra"
+)
+
+// Layers the compiler path can come from, highest precedence first
+const (
+	LayerFlag     = "flag"
+	LayerEnv      = "env"
+	LayerProfile  = "profile"
+	LayerLocal    = "local"
+	LayerGlobal   = "global"
+	LayerRegistry = "registry"
+	LayerDefault  = "default"
+)
+
+// registryCompiler returns the compiler of the SIMPL Windows install in the
+// registry. The registry is read once; tests replace it
+var registryCompiler = sync.OnceValue(toolchain.RegistryCompiler)
+
+// defaultCompiler returns the compiler used when no config sets one: the
+// registered SIMPL Windows install's, else DefaultCompilerPath
+func defaultCompiler() string {
+	if path := registryCompiler(); path != "" {
+		return path
+	}
+
+	return DefaultCompilerPath
+}
+
+// CompilerSource is one place the compiler path can be set
+type CompilerSource struct {
+	// Layer is flag, env, profile, local, global, registry or default
+	Layer string `json:"layer"`
+
+	// Origin names the flag, environment variable, config file, profile or
+	// registry key
+	Origin string `json:"origin,omitempty"`
+
+	// Value is the compiler path it sets; empty when it sets none
+	Value string `json:"value,omitempty"`
+}
+
+// CompilerChain returns every place the compiler path of a build of args
+// can come from, highest precedence first. The first with a Value is used
+func (l *Loader) CompilerChain(cmd *cobra.Command, args []string) ([]CompilerSource, error) {
+	l.reset()
+	l.setupViperDefaults()
+	l.loadGlobalConfig()
+	l.loadLocalConfig(args)
+
+	var chain []CompilerSource
+
+	flag := CompilerSource{Layer: LayerFlag, Origin: "--compiler-path"}
+	if f := cmd.Flags().Lookup("compiler-path"); f != nil && f.Changed {
+		flag.Value = f.Value.String()
+	}
+
+	chain = append(chain, flag, CompilerSource{Layer: LayerEnv, Origin: CompilerPathEnv, Value: os.Getenv(CompilerPathEnv)})
+
+	if profile, _ := cmd.Flags().GetString("profile"); profile != "" {
+		source := CompilerSource{Layer: LayerProfile, Origin: profile}
+		if settings, ok := l.v.GetStringMap("profiles")[strings.ToLower(profile)].(map[string]any); ok && settings["compiler_path"] != nil {
+			source.Value = fmt.Sprint(settings["compiler_path"])
+		}
+
+		chain = append(chain, source)
+	}
+
+	for _, file := range []struct{ layer, path string }{{LayerLocal, l.localConfig}, {LayerGlobal, l.globalConfig}} {
+		source := CompilerSource{Layer: file.layer, Origin: file.path}
+		if file.path != "" {
+			value, err := GetValue(file.path, "compiler_path")
+			if err != nil {
+				return nil, err
+			}
+
+			if value != nil {
+				source.Value = fmt.Sprint(value)
+			}
+		}
+
+		chain = append(chain, source)
+	}
+
+	return append(chain,
+		CompilerSource{Layer: LayerRegistry, Origin: toolchain.SIMPLWindowsKey, Value: registryCompiler()},
+		CompilerSource{Layer: LayerDefault, Value: DefaultCompilerPath},
+	), nil
+}
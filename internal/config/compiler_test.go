@@ -0,0 +1,132 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeRegistryCompiler(t *testing.T, path string) {
+	saved := registryCompiler
+	registryCompiler = func() string { return path }
+	t.Cleanup(func() { registryCompiler = saved })
+}
+
+func TestLoader_CompilerChain(t *testing.T) {
+	globalDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(globalDir, "spc"), 0o755))
+	globalConfig := filepath.Join(globalDir, "spc", "config.yml")
+	require.NoError(t, os.WriteFile(globalConfig, []byte(`compiler_path: "C:/Global/SPlusCC.exe"`), 0o644))
+
+	localDir := t.TempDir()
+	localConfig := filepath.Join(localDir, ".spc.yml")
+	require.NoError(t, os.WriteFile(localConfig, []byte(`target: "34"
+profiles:
+  legacy:
+    compiler_path: "C:/Legacy/SPlusCC.exe"`), 0o644))
+
+	t.Setenv("APPDATA", globalDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(CompilerPathEnv, "")
+	fakeRegistryCompiler(t, "C:/Registry/SPlusCC.exe")
+
+	newCmd := func() *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("compiler-path", "", "Compiler")
+		cmd.Flags().String("profile", "", "Profile")
+		return cmd
+	}
+
+	abs := func(path string) string {
+		abs, _ := filepath.Abs(path)
+		return abs
+	}
+
+	t.Run("global config beats the registry", func(t *testing.T) {
+		chain, err := NewLoader().CompilerChain(newCmd(), []string{localDir})
+		require.NoError(t, err)
+
+		assert.Equal(t, []CompilerSource{
+			{Layer: LayerFlag, Origin: "--compiler-path"},
+			{Layer: LayerEnv, Origin: CompilerPathEnv},
+			{Layer: LayerLocal, Origin: localConfig},
+			{Layer: LayerGlobal, Origin: globalConfig, Value: "C:/Global/SPlusCC.exe"},
+			{Layer: LayerRegistry, Origin: `HKLM\SOFTWARE\WOW6432Node\Crestron Electronics Inc.\SIMPL Windows`, Value: "C:/Registry/SPlusCC.exe"},
+			{Layer: LayerDefault, Value: DefaultCompilerPath},
+		}, chain)
+
+		cfg, err := NewLoader().LoadForBuild(newCmd(), []string{localDir})
+		require.NoError(t, err)
+		assert.Equal(t, abs("C:/Global/SPlusCC.exe"), cfg.CompilerPath)
+	})
+
+	t.Run("profile and environment beat the config files", func(t *testing.T) {
+		cmd := newCmd()
+		_ = cmd.Flags().Set("profile", "legacy")
+
+		chain, err := NewLoader().CompilerChain(cmd, []string{localDir})
+		require.NoError(t, err)
+		assert.Equal(t, CompilerSource{Layer: LayerProfile, Origin: "legacy", Value: "C:/Legacy/SPlusCC.exe"}, chain[2])
+
+		cfg, err := NewLoader().LoadForBuild(cmd, []string{localDir})
+		require.NoError(t, err)
+		assert.Equal(t, abs("C:/Legacy/SPlusCC.exe"), cfg.CompilerPath)
+
+		t.Setenv(CompilerPathEnv, "C:/Env/SPlusCC.exe")
+
+		chain, err = NewLoader().CompilerChain(cmd, []string{localDir})
+		require.NoError(t, err)
+		assert.Equal(t, "C:/Env/SPlusCC.exe", chain[1].Value)
+
+		cfg, err = NewLoader().LoadForBuild(cmd, []string{localDir})
+		require.NoError(t, err)
+		assert.Equal(t, abs("C:/Env/SPlusCC.exe"), cfg.CompilerPath)
+	})
+
+	t.Run("profile name is case-insensitive", func(t *testing.T) {
+		cmd := newCmd()
+		_ = cmd.Flags().Set("profile", "Legacy")
+
+		chain, err := NewLoader().CompilerChain(cmd, []string{localDir})
+		require.NoError(t, err)
+		assert.Equal(t, CompilerSource{Layer: LayerProfile, Origin: "Legacy", Value: "C:/Legacy/SPlusCC.exe"}, chain[2])
+
+		cfg, err := NewLoader().LoadForBuild(cmd, []string{localDir})
+		require.NoError(t, err)
+		assert.Equal(t, abs("C:/Legacy/SPlusCC.exe"), cfg.CompilerPath)
+	})
+
+	t.Run("flag beats everything", func(t *testing.T) {
+		t.Setenv(CompilerPathEnv, "C:/Env/SPlusCC.exe")
+
+		cmd := newCmd()
+		_ = cmd.Flags().Set("compiler-path", "C:/Flag/SPlusCC.exe")
+
+		chain, err := NewLoader().CompilerChain(cmd, []string{localDir})
+		require.NoError(t, err)
+		assert.Equal(t, "C:/Flag/SPlusCC.exe", chain[0].Value)
+
+		cfg, err := NewLoader().LoadForBuild(cmd, []string{localDir})
+		require.NoError(t, err)
+		assert.Equal(t, abs("C:/Flag/SPlusCC.exe"), cfg.CompilerPath)
+	})
+}
+
+func TestLoader_RegistryCompilerIsDefault(t *testing.T) {
+	t.Setenv("APPDATA", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(CompilerPathEnv, "")
+	fakeRegistryCompiler(t, "C:/Registry/SPlusCC.exe")
+
+	cfg, err := NewLoader().LoadForBuild(&cobra.Command{}, []string{t.TempDir()})
+	require.NoError(t, err)
+
+	compiler, _ := filepath.Abs("C:/Registry/SPlusCC.exe")
+	assert.Equal(t, compiler, cfg.CompilerPath)
+}
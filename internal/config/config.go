@@ -2,9 +2,16 @@ package config
 
 import (
 	"fmt"
+	"maps"
+	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 
+	"github.com/Norgate-AV/spc/internal/deps"
+	"github.com/Norgate-AV/spc/internal/glob"
 	"github.com/Norgate-AV/spc/internal/utils"
 	"github.com/spf13/viper"
 )
@@ -15,15 +22,31 @@ const (
 	DefaultTarget       = "34"
 	DefaultSilent       = false
 	DefaultVerbose      = false
+	DefaultInstallDir   = "C:/Users/Public/Documents/Crestron/SIMPL/Usrsplus"
 )
 
+// TargetAuto is the target that picks each file's series from its source:
+// every series unless something in it (or its libraries) rules out the
+// 2-Series
+const TargetAuto = "auto"
+
 // Holds the configuration options for spc
 type Config struct {
 	// Path to the Crestron SIMPL+ compiler
 	CompilerPath string
 
-	// Compilation target series (e.g., 2, 23, 234)
+	// Compiler to use for specific series (keys series2, series3, series4),
+	// falling back to CompilerPath for series that are not listed
+	CompilerPaths map[string]string
+
+	// Compilation target series (e.g., 2, 23, 234). Aliases (all, modern and
+	// those in TargetAliases) are resolved to their series by Load
 	Target string
+
+	// User-defined target names (target_aliases:), mapping each to series
+	// digits or a built-in alias
+	TargetAliases map[string]string
+
 	// Parsed target series
 	Series []string
 
@@ -33,21 +56,199 @@ type Config struct {
 	// Output file for compilation log
 	OutputFile string
 
+	// Directory to collect each module's artifacts into (empty to disable)
+	OutDir string
+
+	// Copy each module's outputs into InstallDir after building or restoring it
+	Install bool
+
+	// SIMPL Windows user SIMPL+ folder modules are installed into
+	InstallDir string
+
+	// Extra arguments appended to the compiler command line (extra_args, then
+	// anything after "--")
+	ExtraArgs []string
+
 	// Suppress console output from the SIMPL+ compiler
 	Silent bool
 
 	// Enable verbose output
 	Verbose bool
+
+	// Verbosity level (0 = normal, 1 = -v, 2 = -vv)
+	Verbosity int
+
+	// Suppress everything except errors
+	Quiet bool
+
+	// Disable the build cache (--no-cache, no_cache or cache.enabled: false)
+	NoCache bool
+
+	// Recompile every file rather than restore it from the cache, still
+	// storing the results (--force)
+	Force bool
+
+	// Build cache settings
+	Cache CacheConfig
+
+	// Compile every file and report all failures instead of stopping at the first
+	KeepGoing bool
+
+	// Write a make-style dependency file (<module>.d) next to each built source
+	Depfile bool
+
+	// Compile each file in its own temporary copy of its directory, so builds
+	// don't share an SPlsWork, and copy its artifacts back afterwards
+	Sandbox bool
+
+	// Compile each series of a multi-series target in its own sandbox, all
+	// at once, and merge their artifacts
+	ParallelSeries bool
+
+	// Compile each series of the target separately and also keep its
+	// outputs in its own SPlsWork_S2, SPlsWork_S3 or SPlsWork_S4 folder
+	SplitWork bool
+
+	// How the compiler is run: local (default) or docker
+	Runner string
+
+	// Container settings used when Runner is docker
+	Docker DockerConfig
+
+	// Where spc publish uploads archives
+	Publish PublishConfig
+
+	// CA certificates for remote cache and self-update traffic
+	Network NetworkConfig
+
+	// Commands run before and after each file is built
+	Hooks HooksConfig
+
+	// Severity (error, warning or off) of lint rules that don't use their
+	// default
+	Lint map[string]string
+
+	// Name of the selected config profile (empty for none)
+	Profile string
+
+	// Per-file settings applied to sources matching a glob pattern
+	Overrides []Override
+
+	// URLs notified when a build finishes
+	Webhooks []Webhook
+
+	// Desktop notification shown when a build finishes
+	Notify NotifyConfig
+
+	// When spc daemon rebuilds a watched project
+	Watch WatchConfig
+
+	// Files, directories or glob patterns to build when none are given on the
+	// command line (relative to ProjectDir)
+	Files []string
+
+	// Glob patterns limiting which files directory/glob arguments pick up
+	Include []string
+
+	// Glob patterns for files and directories that directory/glob arguments skip
+	Exclude []string
+
+	// Directory that relative glob patterns in the config are matched from
+	// (the local config file's directory, or the working directory)
+	ProjectDir string
 }
 
-func Load() (*Config, error) {
+// PublishConfig holds the settings of the publish: section
+type PublishConfig struct {
+	// Directory, http(s) URL or github:owner/repo archives are published to
+	Destination string
+}
+
+// publishKeys are the keys allowed in the publish: section
+var publishKeys = map[string]bool{
+	"destination": true,
+}
+
+// Override changes settings for source files matching a glob pattern
+type Override struct {
+	// Glob pattern (e.g. "legacy/**" or "*_old.usp")
+	Pattern string `mapstructure:"pattern"`
+
+	// Compilation target series for matching files
+	Target string `mapstructure:"target"`
+
+	// User SIMPL+ folders for matching files (replaces the top-level list)
+	UserFolders []string `mapstructure:"usersplusfolder"`
+
+	// Extra compiler arguments for matching files (added after the top-level
+	// list and any given after "--")
+	ExtraArgs []string `mapstructure:"extra_args"`
+}
+
+// Load builds a Config from the settings in v and validates it
+func Load(v *viper.Viper) (*Config, error) {
 	cfg := &Config{
-		CompilerPath: viper.GetString("compiler_path"),
-		Target:       viper.GetString("target"),
-		UserFolders:  viper.GetStringSlice("usersplusfolder"),
-		OutputFile:   viper.GetString("out"),
-		Silent:       viper.GetBool("silent"),
-		Verbose:      viper.GetBool("verbose"),
+		CompilerPath:   v.GetString("compiler_path"),
+		CompilerPaths:  v.GetStringMapString("compiler_paths"),
+		Target:         v.GetString("target"),
+		TargetAliases:  v.GetStringMapString("target_aliases"),
+		UserFolders:    v.GetStringSlice("usersplusfolder"),
+		OutputFile:     v.GetString("out"),
+		OutDir:         v.GetString("out_dir"),
+		Install:        v.GetBool("install"),
+		InstallDir:     v.GetString("install_dir"),
+		ExtraArgs:      v.GetStringSlice("extra_args"),
+		Silent:         v.GetBool("silent"),
+		Verbosity:      parseVerbosity(v.GetString("verbose")),
+		Quiet:          v.GetBool("quiet"),
+		NoCache:        v.GetBool("no_cache") || (v.IsSet("cache.enabled") && !v.GetBool("cache.enabled")),
+		Force:          v.GetBool("force"),
+		KeepGoing:      v.GetBool("keep_going"),
+		Depfile:        v.GetBool("depfile"),
+		Sandbox:        v.GetBool("sandbox"),
+		ParallelSeries: v.GetBool("parallel_series"),
+		SplitWork:      v.GetBool("split_work"),
+		Runner:         v.GetString("runner"),
+		Docker:         loadDocker(v),
+		Publish:        PublishConfig{Destination: v.GetString("publish.destination")},
+		Network:        loadNetwork(v),
+		Hooks:          loadHooks(v),
+		Lint:           loadLint(v),
+		Profile:        v.GetString("profile"),
+		Files:          v.GetStringSlice("files"),
+		Include:        v.GetStringSlice("include"),
+		Exclude:        v.GetStringSlice("exclude"),
+	}
+
+	cfg.Verbose = cfg.Verbosity > 0
+
+	cache, err := loadCache(v)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Cache = cache
+
+	if cfg.Notify, err = loadNotify(v); err != nil {
+		return nil, err
+	}
+
+	if cfg.Watch, err = loadWatch(v); err != nil {
+		return nil, err
+	}
+
+	if err := v.UnmarshalKey("overrides", &cfg.Overrides); err != nil {
+		return nil, fmt.Errorf("invalid overrides: %w", err)
+	}
+
+	if err := v.UnmarshalKey("webhooks", &cfg.Webhooks); err != nil {
+		return nil, fmt.Errorf("invalid webhooks: %w", err)
+	}
+
+	for i := range cfg.Webhooks {
+		if err := cfg.Webhooks[i].resolve(); err != nil {
+			return nil, fmt.Errorf("webhook %d: %w", i+1, err)
+		}
 	}
 
 	// Apply defaults if not set
@@ -61,6 +262,10 @@ func Load() (*Config, error) {
 		cfg.Target = DefaultTarget
 	}
 
+	if cfg.InstallDir == "" {
+		cfg.InstallDir = DefaultInstallDir
+	}
+
 	// Validate required fields
 	if err := cfg.Validate(); err != nil {
 		return nil, err
@@ -70,10 +275,28 @@ func Load() (*Config, error) {
 }
 
 func (c *Config) Validate() error {
+	c.CompilerPath = ExpandPath(c.CompilerPath)
+	c.OutputFile = ExpandPath(c.OutputFile)
+	c.OutDir = ExpandPath(c.OutDir)
+	c.InstallDir = ExpandPath(c.InstallDir)
+	c.Cache.Dir = ExpandPath(c.Cache.Dir)
+	c.Network.CAFile = ExpandPath(c.Network.CAFile)
+
 	if abs, err := filepath.Abs(c.CompilerPath); err == nil {
 		c.CompilerPath = abs
 	}
 
+	// Validate and resolve per-series compilers
+	for series, path := range c.CompilerPaths {
+		if !isSeriesKey(series) {
+			return fmt.Errorf("invalid compiler_paths key: %s (expected series2, series3 or series4)", series)
+		}
+
+		if abs, err := filepath.Abs(ExpandPath(path)); err == nil {
+			c.CompilerPaths[series] = abs
+		}
+	}
+
 	// Resolve output file path
 	if c.OutputFile != "" {
 		abs, err := filepath.Abs(c.OutputFile)
@@ -84,27 +307,257 @@ func (c *Config) Validate() error {
 		c.OutputFile = abs
 	}
 
-	// Validate target
-	if !isValidTarget(c.Target) {
+	// Resolve artifact output directory
+	if c.OutDir != "" {
+		abs, err := filepath.Abs(c.OutDir)
+		if err != nil {
+			return fmt.Errorf("invalid output directory: %v", err)
+		}
+
+		c.OutDir = abs
+	}
+
+	// Resolve install directory
+	if c.InstallDir != "" {
+		abs, err := filepath.Abs(c.InstallDir)
+		if err != nil {
+			return fmt.Errorf("invalid install directory: %v", err)
+		}
+
+		c.InstallDir = abs
+	}
+
+	// Resolve CA bundle
+	if c.Network.CAFile != "" {
+		abs, err := filepath.Abs(c.Network.CAFile)
+		if err != nil {
+			return fmt.Errorf("invalid network.ca_file path: %v", err)
+		}
+
+		c.Network.CAFile = abs
+	}
+
+	// Resolve cache directory
+	if c.Cache.Dir != "" {
+		abs, err := filepath.Abs(c.Cache.Dir)
+		if err != nil {
+			return fmt.Errorf("invalid cache directory: %v", err)
+		}
+
+		c.Cache.Dir = abs
+	}
+
+	if err := validateHash(c.Cache.Hash); err != nil {
+		return fmt.Errorf("invalid cache.hash: %w", err)
+	}
+
+	if err := validateRunner(c.Runner); err != nil {
+		return err
+	}
+
+	if c.Runner == RunnerDocker && c.Docker.Image == "" {
+		return fmt.Errorf("runner: docker requires docker.image")
+	}
+
+	if err := validateLint(c.Lint); err != nil {
+		return err
+	}
+
+	// Validate target aliases, then resolve the targets that use them
+	if err := validateTargetAliases(c.TargetAliases); err != nil {
+		return err
+	}
+
+	c.Target = utils.ResolveTarget(c.Target, c.TargetAliases)
+	if !isValidTarget(c.Target, nil) {
 		return fmt.Errorf("invalid target series: %s", c.Target)
 	}
 
 	// Resolve user folders
-	for i, folder := range c.UserFolders {
+	if err := resolveFolders(c.UserFolders); err != nil {
+		return err
+	}
+
+	// Validate overrides
+	for i := range c.Overrides {
+		o := &c.Overrides[i]
+		if o.Pattern == "" {
+			return fmt.Errorf("override %d: missing pattern", i+1)
+		}
+
+		o.Target = utils.ResolveTarget(o.Target, c.TargetAliases)
+		if o.Target != "" && !isValidTarget(o.Target, nil) {
+			return fmt.Errorf("override %q: invalid target series: %s", o.Pattern, o.Target)
+		}
+
+		if err := resolveFolders(o.UserFolders); err != nil {
+			return fmt.Errorf("override %q: %w", o.Pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// ForFile returns the configuration for a single source file, with the
+// settings of every matching override applied in order (later ones win) and
+// a target of auto replaced by the detected series. Returns c itself when
+// neither changes anything
+func (c *Config) ForFile(file string) *Config {
+	resolved := c
+
+	for _, o := range c.Overrides {
+		if !glob.MatchFile(o.Pattern, c.ProjectDir, file) {
+			continue
+		}
+
+		if resolved == c {
+			copied := *c
+			resolved = &copied
+		}
+
+		if o.Target != "" {
+			resolved.Target = o.Target
+			resolved.Series = utils.ParseTarget(o.Target)
+		}
+
+		if o.UserFolders != nil {
+			resolved.UserFolders = o.UserFolders
+		}
+
+		if len(o.ExtraArgs) > 0 {
+			resolved.ExtraArgs = append(slices.Clip(resolved.ExtraArgs), o.ExtraArgs...)
+		}
+	}
+
+	if strings.EqualFold(resolved.Target, TargetAuto) {
+		if resolved == c {
+			copied := *c
+			resolved = &copied
+		}
+
+		resolved.Target = detectTarget(file, resolved.UserFolders)
+		resolved.Series = utils.ParseTarget(resolved.Target)
+	}
+
+	return resolved
+}
+
+// detectTarget returns the series a file can be compiled for. A file that
+// can't be scanned gets every series, and fails to compile as it would anyway
+func detectTarget(file string, userFolders []string) string {
+	d, err := deps.DetectTarget(file, &deps.Resolver{UserFolders: userFolders})
+	if err != nil {
+		return deps.TargetAll
+	}
+
+	return d.Target
+}
+
+// ProjectFiles returns the configured project files, resolved against ProjectDir
+func (c *Config) ProjectFiles() []string {
+	files := make([]string, 0, len(c.Files))
+	for _, f := range c.Files {
+		if !filepath.IsAbs(f) && c.ProjectDir != "" {
+			f = filepath.Join(c.ProjectDir, f)
+		}
+
+		files = append(files, f)
+	}
+
+	return files
+}
+
+// CompilerFor returns the compiler to use for a series (e.g. "series4")
+func (c *Config) CompilerFor(series string) string {
+	if path := c.CompilerPaths[series]; path != "" {
+		return path
+	}
+
+	return c.CompilerPath
+}
+
+// isSeriesKey reports whether key names a series (series2, series3 or series4)
+func isSeriesKey(key string) bool {
+	return key == "series2" || key == "series3" || key == "series4"
+}
+
+// ExpandPath expands environment variables ($VAR or ${VAR}) and a leading ~
+// (the user's home directory) in a path from the config
+func ExpandPath(path string) string {
+	path = os.ExpandEnv(path)
+
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+
+	return path
+}
+
+// resolveFolders expands and makes folder paths absolute in place
+func resolveFolders(folders []string) error {
+	for i, folder := range folders {
 		if folder != "" {
-			abs, err := filepath.Abs(folder)
+			abs, err := filepath.Abs(ExpandPath(folder))
 			if err != nil {
 				return fmt.Errorf("invalid user folder path: %v", err)
 			}
 
-			c.UserFolders[i] = abs
+			folders[i] = abs
 		}
 	}
 
 	return nil
 }
 
-func isValidTarget(target string) bool {
+// parseVerbosity interprets the verbose setting, which is a boolean in config
+// files but a repeat count on the command line (-v, -vv)
+func parseVerbosity(s string) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+
+	if b, err := strconv.ParseBool(s); err == nil && b {
+		return 1
+	}
+
+	return 0
+}
+
+// isValidTarget reports whether target is auto, or names at least one series
+// itself or through an alias (one of aliases or a built-in one)
+func isValidTarget(target string, aliases map[string]string) bool {
+	target = utils.ResolveTarget(target, aliases)
+	if strings.EqualFold(target, TargetAuto) {
+		return true
+	}
+
 	series := utils.ParseTarget(target)
 	return len(series) > 0
 }
+
+// validateTargetAliases checks that alias names can't be mistaken for series
+// digits or auto, and that each names a valid target
+func validateTargetAliases(aliases map[string]string) error {
+	for _, name := range slices.Sorted(maps.Keys(aliases)) {
+		if err := validateTargetAlias(name, aliases[name]); err != nil {
+			return fmt.Errorf("invalid target_aliases.%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// validateTargetAlias checks a single entry of target_aliases
+func validateTargetAlias(name, target string) error {
+	if name == "" || strings.EqualFold(name, TargetAuto) || strings.ContainsAny(name, "0123456789") {
+		return fmt.Errorf("alias names may not contain digits or be %s", TargetAuto)
+	}
+
+	if !isValidTarget(target, nil) {
+		return fmt.Errorf("invalid target series: %s", target)
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -12,19 +13,18 @@ import (
 func TestLoad(t *testing.T) {
 	tests := []struct {
 		name        string
-		setupViper  func()
+		setupViper  func(v *viper.Viper)
 		wantConfig  *Config
 		wantErr     bool
 		errContains string
 	}{
 		{
 			name: "load with all defaults",
-			setupViper: func() {
-				viper.Reset()
-				viper.SetDefault("compiler_path", DefaultCompilerPath)
-				viper.SetDefault("target", DefaultTarget)
-				viper.SetDefault("silent", DefaultSilent)
-				viper.SetDefault("verbose", DefaultVerbose)
+			setupViper: func(v *viper.Viper) {
+				v.SetDefault("compiler_path", DefaultCompilerPath)
+				v.SetDefault("target", DefaultTarget)
+				v.SetDefault("silent", DefaultSilent)
+				v.SetDefault("verbose", DefaultVerbose)
 			},
 			wantConfig: &Config{
 				CompilerPath: func() string {
@@ -40,14 +40,13 @@ func TestLoad(t *testing.T) {
 		},
 		{
 			name: "load with custom values",
-			setupViper: func() {
-				viper.Reset()
-				viper.Set("compiler_path", "C:/Custom/SPlusCC.exe")
-				viper.Set("target", "3")
-				viper.Set("silent", true)
-				viper.Set("verbose", true)
-				viper.Set("out", "custom.log")
-				viper.Set("usersplusfolder", []string{"C:/Include1", "C:/Include2"})
+			setupViper: func(v *viper.Viper) {
+				v.Set("compiler_path", "C:/Custom/SPlusCC.exe")
+				v.Set("target", "3")
+				v.Set("silent", true)
+				v.Set("verbose", true)
+				v.Set("out", "custom.log")
+				v.Set("usersplusfolder", []string{"C:/Include1", "C:/Include2"})
 			},
 			wantConfig: &Config{
 				CompilerPath: func() string {
@@ -71,10 +70,9 @@ func TestLoad(t *testing.T) {
 		},
 		{
 			name: "empty compiler path gets default on Windows",
-			setupViper: func() {
-				viper.Reset()
-				viper.Set("compiler_path", "")
-				viper.Set("target", "234")
+			setupViper: func(v *viper.Viper) {
+				v.Set("compiler_path", "")
+				v.Set("target", "234")
 			},
 			wantConfig: &Config{
 				CompilerPath: func() string {
@@ -89,10 +87,9 @@ func TestLoad(t *testing.T) {
 		},
 		{
 			name: "empty target gets default",
-			setupViper: func() {
-				viper.Reset()
-				viper.Set("compiler_path", "C:/SPlusCC.exe")
-				viper.Set("target", "")
+			setupViper: func(v *viper.Viper) {
+				v.Set("compiler_path", "C:/SPlusCC.exe")
+				v.Set("target", "")
 			},
 			wantConfig: &Config{
 				CompilerPath: func() string {
@@ -106,20 +103,18 @@ func TestLoad(t *testing.T) {
 		},
 		{
 			name: "invalid target",
-			setupViper: func() {
-				viper.Reset()
-				viper.Set("compiler_path", "C:/SPlusCC.exe")
-				viper.Set("target", "invalid")
+			setupViper: func(v *viper.Viper) {
+				v.Set("compiler_path", "C:/SPlusCC.exe")
+				v.Set("target", "invalid")
 			},
 			wantErr:     true,
 			errContains: "invalid target series",
 		},
 		{
 			name: "invalid target - only contains invalid characters",
-			setupViper: func() {
-				viper.Reset()
-				viper.Set("compiler_path", "C:/SPlusCC.exe")
-				viper.Set("target", "567")
+			setupViper: func(v *viper.Viper) {
+				v.Set("compiler_path", "C:/SPlusCC.exe")
+				v.Set("target", "567")
 			},
 			wantErr:     true,
 			errContains: "invalid target series",
@@ -128,9 +123,10 @@ func TestLoad(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.setupViper()
+			v := viper.New()
+			tt.setupViper(v)
 
-			cfg, err := Load()
+			cfg, err := Load(v)
 
 			if tt.wantErr {
 				require.Error(t, err)
@@ -260,6 +256,199 @@ func TestConfig_Validate(t *testing.T) {
 	}
 }
 
+func TestParseVerbosity(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"", 0},
+		{"false", 0},
+		{"true", 1},
+		{"0", 0},
+		{"1", 1},
+		{"2", 2},
+		{"invalid", 0},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, parseVerbosity(tt.input), "parseVerbosity(%q)", tt.input)
+	}
+}
+
+func TestLoad_VerbosityAndQuiet(t *testing.T) {
+	v := viper.New()
+	v.Set("compiler_path", "C:/SPlusCC.exe")
+	v.Set("target", "3")
+	v.Set("verbose", "2")
+	v.Set("quiet", true)
+
+	cfg, err := Load(v)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cfg.Verbosity)
+	assert.True(t, cfg.Verbose)
+	assert.True(t, cfg.Quiet)
+}
+
+func TestLoad_Overrides(t *testing.T) {
+	v := viper.New()
+	v.Set("compiler_path", "C:/SPlusCC.exe")
+	v.Set("target", "34")
+	v.Set("overrides", []any{
+		map[string]any{"pattern": "legacy/**", "target": "2"},
+		map[string]any{"pattern": "*_old.usp", "usersplusfolder": []any{"old-libs"}},
+	})
+
+	cfg, err := Load(v)
+	require.NoError(t, err)
+	require.Len(t, cfg.Overrides, 2)
+	assert.Equal(t, Override{Pattern: "legacy/**", Target: "2"}, cfg.Overrides[0])
+
+	abs, _ := filepath.Abs("old-libs")
+	assert.Equal(t, []string{abs}, cfg.Overrides[1].UserFolders)
+
+	v.Set("overrides", []any{map[string]any{"pattern": "legacy/**", "target": "9"}})
+	_, err = Load(v)
+	assert.ErrorContains(t, err, "invalid target series: 9")
+}
+
+func TestLoad_CompilerPaths(t *testing.T) {
+	v := viper.New()
+	v.Set("compiler_path", "C:/Simpl/SPlusCC.exe")
+	v.Set("target", "234")
+	v.Set("compiler_paths", map[string]any{"series2": "C:/Legacy/SPlusCC.exe"})
+
+	cfg, err := Load(v)
+	require.NoError(t, err)
+
+	legacy, _ := filepath.Abs("C:/Legacy/SPlusCC.exe")
+	assert.Equal(t, legacy, cfg.CompilerFor("series2"))
+	assert.Equal(t, cfg.CompilerPath, cfg.CompilerFor("series4"))
+
+	v.Set("compiler_paths", map[string]any{"series5": "C:/Other/SPlusCC.exe"})
+	_, err = Load(v)
+	assert.ErrorContains(t, err, "invalid compiler_paths key: series5")
+}
+
+func TestLoad_TargetAliases(t *testing.T) {
+	v := viper.New()
+	v.Set("compiler_path", "C:/SPlusCC.exe")
+	v.Set("target", "All")
+	v.Set("target_aliases", map[string]any{"legacy": "2", "current": "modern"})
+	v.Set("overrides", []any{
+		map[string]any{"pattern": "legacy/**", "target": "legacy"},
+		map[string]any{"pattern": "new/**", "target": "current"},
+	})
+
+	cfg, err := Load(v)
+	require.NoError(t, err)
+	assert.Equal(t, "234", cfg.Target)
+	assert.Equal(t, "2", cfg.Overrides[0].Target)
+	assert.Equal(t, "34", cfg.Overrides[1].Target)
+
+	v.Set("target_aliases", map[string]any{"s4": "4"})
+	_, err = Load(v)
+	assert.ErrorContains(t, err, "invalid target_aliases.s4")
+
+	v.Set("target_aliases", map[string]any{"future": "5"})
+	_, err = Load(v)
+	assert.ErrorContains(t, err, "invalid target_aliases.future: invalid target series: 5")
+}
+
+func TestExpandPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("CRESTRON_HOME", "/opt/crestron")
+
+	assert.Equal(t, "/opt/crestron/SPlusCC.exe", ExpandPath("$CRESTRON_HOME/SPlusCC.exe"))
+	assert.Equal(t, "/opt/crestron/SPlusCC.exe", ExpandPath("${CRESTRON_HOME}/SPlusCC.exe"))
+	assert.Equal(t, filepath.Join(home, "crestron", "lib"), ExpandPath("~/crestron/lib"))
+	assert.Equal(t, home, ExpandPath("~"))
+	assert.Equal(t, "C:/Program Files (x86)/SPlusCC.exe", ExpandPath("C:/Program Files (x86)/SPlusCC.exe"))
+	assert.Equal(t, "~other/lib", ExpandPath("~other/lib"))
+}
+
+func TestLoad_ExpandsPaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("CRESTRON_HOME", filepath.Join(home, "crestron"))
+
+	v := viper.New()
+	v.Set("compiler_path", "$CRESTRON_HOME/SPlusCC.exe")
+	v.Set("target", "3")
+	v.Set("usersplusfolder", []string{"~/libs"})
+
+	cfg, err := Load(v)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "crestron", "SPlusCC.exe"), cfg.CompilerPath)
+	assert.Equal(t, []string{filepath.Join(home, "libs")}, cfg.UserFolders)
+}
+
+func TestConfig_ProjectFiles(t *testing.T) {
+	project := t.TempDir()
+	abs := filepath.Join(t.TempDir(), "shared.usp")
+
+	cfg := &Config{Files: []string{"src/**/*.usp", abs}, ProjectDir: project}
+	assert.Equal(t, []string{filepath.Join(project, "src/**/*.usp"), abs}, cfg.ProjectFiles())
+	assert.Empty(t, (&Config{}).ProjectFiles())
+}
+
+func TestConfig_ForFile(t *testing.T) {
+	project := filepath.Join("project")
+	cfg := &Config{
+		Target:      "34",
+		UserFolders: []string{"libs"},
+		ExtraArgs:   []string{"/a"},
+		ProjectDir:  project,
+		Overrides: []Override{
+			{Pattern: "legacy/**", Target: "2"},
+			{Pattern: "legacy/special.usp", UserFolders: []string{"special"}, ExtraArgs: []string{"/b"}},
+		},
+	}
+
+	t.Run("no match returns the config unchanged", func(t *testing.T) {
+		assert.Same(t, cfg, cfg.ForFile(filepath.Join(project, "src", "a.usp")))
+	})
+
+	t.Run("matching override is applied to a copy", func(t *testing.T) {
+		got := cfg.ForFile(filepath.Join(project, "legacy", "a.usp"))
+		assert.Equal(t, "2", got.Target)
+		assert.Equal(t, []string{"libs"}, got.UserFolders)
+		assert.Equal(t, "34", cfg.Target)
+	})
+
+	t.Run("overrides are applied in order", func(t *testing.T) {
+		got := cfg.ForFile(filepath.Join(project, "legacy", "special.usp"))
+		assert.Equal(t, "2", got.Target)
+		assert.Equal(t, []string{"special"}, got.UserFolders)
+		assert.Equal(t, []string{"/a", "/b"}, got.ExtraArgs)
+		assert.Equal(t, []string{"/a"}, cfg.ExtraArgs)
+	})
+}
+
+func TestConfig_ForFile_Auto(t *testing.T) {
+	dir := t.TempDir()
+
+	plain := filepath.Join(dir, "plain.usp")
+	require.NoError(t, os.WriteFile(plain, []byte("DIGITAL_INPUT go;\n"), 0o644))
+
+	events := filepath.Join(dir, "events.usp")
+	require.NoError(t, os.WriteFile(events, []byte("TRY { x = 1; } CATCH { }\n"), 0o644))
+
+	cfg := &Config{Target: "auto", ProjectDir: dir, Overrides: []Override{{Pattern: "legacy/**", Target: "2"}}}
+
+	got := cfg.ForFile(plain)
+	assert.Equal(t, "234", got.Target)
+	assert.Equal(t, []string{"series2", "series3", "series4"}, got.Series)
+
+	assert.Equal(t, "34", cfg.ForFile(events).Target)
+	assert.Equal(t, "auto", cfg.Target)
+
+	// Overrides with a target win
+	assert.Equal(t, "2", cfg.ForFile(filepath.Join(dir, "legacy", "old.usp")).Target)
+}
+
 func TestIsValidTarget(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -272,6 +461,7 @@ func TestIsValidTarget(t *testing.T) {
 		{"multiple series 23", "23", true},
 		{"multiple series 34", "34", true},
 		{"all series 234", "234", true},
+		{"auto", "auto", true},
 		{"empty string", "", false},
 		{"invalid series 1", "1", false},
 		{"invalid series 5", "5", false},
@@ -285,7 +475,7 @@ func TestIsValidTarget(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := isValidTarget(tt.target)
+			got := isValidTarget(tt.target, nil)
 			assert.Equal(t, tt.want, got)
 		})
 	}
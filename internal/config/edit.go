@@ -0,0 +1,383 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/lint"
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+// boolKeys are the settings that hold a boolean value
+var boolKeys = map[string]bool{
+	"silent":          true,
+	"quiet":           true,
+	"no_cache":        true,
+	"keep_going":      true,
+	"depfile":         true,
+	"sandbox":         true,
+	"parallel_series": true,
+	"split_work":      true,
+	"install":         true,
+
+	"cache.enabled":          true,
+	"cache.read_only":        true,
+	"cache.remote.read_only": true,
+	"cache.remote.encrypt":   true,
+	"docker.wine":            true,
+	"notify.enabled":         true,
+}
+
+// settingName validates a dotted config key ("target",
+// "compiler_paths.series2", "cache.ttl" or "profiles.<name>.target") and
+// returns the name of the setting it refers to
+func settingName(key string) (string, error) {
+	parts := strings.Split(strings.ToLower(key), ".")
+	if len(parts) > 2 && parts[0] == "profiles" && parts[1] != "" {
+		parts = parts[2:]
+	}
+
+	switch {
+	case len(parts) == 1 && settingKeys[parts[0]]:
+		return parts[0], nil
+	case len(parts) == 2 && parts[0] == "compiler_paths" && isSeriesKey(parts[1]):
+		return parts[0], nil
+	case len(parts) == 2 && parts[0] == "target_aliases" && parts[1] != "":
+		return parts[0], nil
+	case len(parts) == 2 && parts[0] == "cache" && cacheKeys[parts[1]]:
+		return strings.Join(parts, "."), nil
+	case len(parts) == 3 && parts[0] == "cache" && parts[1] == "remote" && remoteCacheKeys[parts[2]]:
+		return strings.Join(parts, "."), nil
+	case len(parts) == 2 && parts[0] == "publish" && publishKeys[parts[1]]:
+		return strings.Join(parts, "."), nil
+	case len(parts) == 2 && parts[0] == "docker" && dockerKeys[parts[1]]:
+		return strings.Join(parts, "."), nil
+	case len(parts) == 2 && parts[0] == "network" && networkKeys[parts[1]]:
+		return strings.Join(parts, "."), nil
+	case len(parts) == 2 && parts[0] == "hooks" && hookKeys[parts[1]]:
+		return strings.Join(parts, "."), nil
+	case len(parts) == 2 && parts[0] == "notify" && notifyKeys[parts[1]]:
+		return strings.Join(parts, "."), nil
+	case len(parts) == 2 && parts[0] == "watch" && watchKeys[parts[1]]:
+		return strings.Join(parts, "."), nil
+	case len(parts) == 2 && parts[0] == "lint" && isLintRule(parts[1]):
+		return strings.Join(parts, "."), nil
+	default:
+		return "", fmt.Errorf("unknown config key: %s", key)
+	}
+}
+
+// GetValue returns the value of a key in a single config file, or nil if unset
+func GetValue(path, key string) (any, error) {
+	if _, err := settingName(key); err != nil {
+		return nil, err
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return v.Get(key), nil
+}
+
+// listKeys are the settings that hold a list, given as a comma separated value
+var listKeys = map[string]bool{
+	"usersplusfolder": true,
+	"files":           true,
+	"include":         true,
+	"exclude":         true,
+	"extra_args":      true,
+	"docker.args":     true,
+}
+
+// SetValue sets a key in a YAML config file, creating the file if needed.
+// Comments and the order of existing keys are preserved. List settings
+// (usersplusfolder, files, include, exclude, extra_args) take a comma separated value
+func SetValue(path, key, value string) error {
+	if ext := strings.ToLower(filepath.Ext(path)); ext != ".yml" && ext != ".yaml" {
+		return fmt.Errorf("only YAML config files can be edited: %s", path)
+	}
+
+	name, err := settingName(key)
+	if err != nil {
+		return err
+	}
+
+	parts := strings.Split(strings.ToLower(key), ".")
+	if parts[len(parts)-1] == "compiler_paths" {
+		return fmt.Errorf("set compiler_paths.<series> (e.g. compiler_paths.series2) instead of %s", key)
+	}
+
+	if parts[len(parts)-1] == "target_aliases" {
+		return fmt.Errorf("set target_aliases.<name> (e.g. target_aliases.legacy) instead of %s", key)
+	}
+
+	if name == "target_aliases" {
+		if err := validateTargetAlias(parts[len(parts)-1], value); err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+
+	if name == "cache" || name == "cache.remote" {
+		return fmt.Errorf("set the individual settings (e.g. cache.enabled) instead of %s", key)
+	}
+
+	if name == "docker" {
+		return fmt.Errorf("set the individual settings (e.g. docker.image) instead of %s", key)
+	}
+
+	if name == "publish" {
+		return fmt.Errorf("set publish.destination instead of %s", key)
+	}
+
+	if name == "network" {
+		return fmt.Errorf("set network.ca_file instead of %s", key)
+	}
+
+	if name == "hooks" {
+		return fmt.Errorf("set hooks.pre_build, hooks.post_build or hooks.on_failure instead of %s", key)
+	}
+
+	if name == "notify" {
+		return fmt.Errorf("set notify.enabled, notify.after or notify.on instead of %s", key)
+	}
+
+	if name == "watch" {
+		return fmt.Errorf("set watch.debounce, watch.settle or watch.batch instead of %s", key)
+	}
+
+	if name == "lint" {
+		return fmt.Errorf("set lint.<rule> (e.g. lint.magic_wait) instead of %s", key)
+	}
+
+	node, err := valueNode(name, value, fileTargetAliases(path))
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+
+	mapping := doc.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: top level must be a map of settings", path)
+	}
+
+	for _, part := range parts[:len(parts)-1] {
+		if mapping, err = childMapping(mapping, part); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	setMappingValue(mapping, parts[len(parts)-1], node)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// fileTargetAliases returns the target_aliases of a config file, or nil when
+// it has none or can't be read
+func fileTargetAliases(path string) map[string]string {
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil
+	}
+
+	return v.GetStringMapString("target_aliases")
+}
+
+// valueNode converts a command line value into a YAML node for a setting. A
+// target may use the given aliases
+func valueNode(name, value string, aliases map[string]string) (*yaml.Node, error) {
+	switch {
+	case listKeys[name]:
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				seq.Content = append(seq.Content, stringNode(item))
+			}
+		}
+
+		return seq, nil
+	case boolKeys[name]:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false: %s", name, value)
+		}
+
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(b)}, nil
+	case name == "verbose":
+		if n, err := strconv.Atoi(value); err == nil {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(n)}, nil
+		}
+
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("verbose must be true, false or a level: %s", value)
+		}
+
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(b)}, nil
+	case name == "target":
+		if !isValidTarget(value, aliases) {
+			return nil, fmt.Errorf("invalid target series: %s", value)
+		}
+
+		return stringNode(value), nil
+	case name == "cache.max_size":
+		if _, err := ParseSize(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		return stringNode(value), nil
+	case name == "cache.ttl":
+		if _, err := ParseTTL(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		return stringNode(value), nil
+	case name == "cache.hash":
+		if err := validateHash(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		return stringNode(value), nil
+	case name == "cache.lock_retries":
+		n, err := parseRetries(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(n)}, nil
+	case name == "notify.after", strings.HasPrefix(name, "watch."):
+		if _, err := parseDelay(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		return stringNode(value), nil
+	case name == "notify.on":
+		if err := validateNotifyOn(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		return stringNode(value), nil
+	case name == "cache.lock_delay":
+		if _, err := parseDelay(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		return stringNode(value), nil
+	case name == "runner":
+		if err := validateRunner(value); err != nil {
+			return nil, err
+		}
+
+		return stringNode(value), nil
+	case name == "cache.remote.type":
+		if err := validateRemoteType(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		return stringNode(value), nil
+	case name == "cache.remote.auth":
+		if err := validateRemoteAuth(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		return stringNode(value), nil
+	case name == "cache.remote.url" || name == "cache.remote.token_url":
+		if err := validateRemoteURL(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		return stringNode(value), nil
+	case strings.HasPrefix(name, "lint."):
+		severity, err := lint.ParseSeverity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+
+		return stringNode(severity), nil
+	default:
+		return stringNode(value), nil
+	}
+}
+
+func stringNode(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s, Style: yaml.DoubleQuotedStyle}
+}
+
+// childMapping returns the mapping stored under key, creating it if missing
+func childMapping(mapping *yaml.Node, key string) (*yaml.Node, error) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if strings.EqualFold(mapping.Content[i].Value, key) {
+			child := mapping.Content[i+1]
+			if child.Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("%s is not a map", key)
+			}
+
+			return child, nil
+		}
+	}
+
+	child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+
+	return child, nil
+}
+
+// setMappingValue replaces (or appends) the value of key, keeping any
+// comments attached to the old value
+func setMappingValue(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if strings.EqualFold(mapping.Content[i].Value, key) {
+			old := mapping.Content[i+1]
+			value.HeadComment = old.HeadComment
+			value.LineComment = old.LineComment
+			value.FootComment = old.FootComment
+			mapping.Content[i+1] = value
+
+			return
+		}
+	}
+
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetValue(t *testing.T) {
+	t.Run("creates a new file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "spc", "config.yml")
+
+		require.NoError(t, SetValue(path, "target", "34"))
+		require.NoError(t, SetValue(path, "usersplusfolder", "C:/Libs, C:/More"))
+		require.NoError(t, SetValue(path, "no_cache", "true"))
+		require.NoError(t, SetValue(path, "compiler_paths.series2", "C:/Legacy/SPlusCC.exe"))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, `target: "34"
+usersplusfolder:
+  - "C:/Libs"
+  - "C:/More"
+no_cache: true
+compiler_paths:
+  series2: "C:/Legacy/SPlusCC.exe"
+`, string(data))
+	})
+
+	t.Run("preserves comments and key order", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".spc.yml")
+		original := `# Project settings
+compiler_path: "C:/SPlusCC.exe"
+target: "4" # fast dev builds
+silent: false
+`
+		require.NoError(t, os.WriteFile(path, []byte(original), 0o644))
+
+		require.NoError(t, SetValue(path, "target", "234"))
+		require.NoError(t, SetValue(path, "profiles.release.target", "234"))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, `# Project settings
+compiler_path: "C:/SPlusCC.exe"
+target: "234" # fast dev builds
+silent: false
+profiles:
+  release:
+    target: "234"
+`, string(data))
+
+		value, err := GetValue(path, "profiles.release.target")
+		require.NoError(t, err)
+		assert.Equal(t, "234", value)
+	})
+
+	t.Run("rejects invalid keys and values", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".spc.yml")
+
+		assert.EqualError(t, SetValue(path, "colour", "true"), "unknown config key: colour")
+		assert.EqualError(t, SetValue(path, "target", "9"), "invalid target series: 9")
+		assert.EqualError(t, SetValue(path, "compiler_paths.series5", "C:/x.exe"), "unknown config key: compiler_paths.series5")
+		assert.ErrorContains(t, SetValue(path, "compiler_paths", "C:/x.exe"), "compiler_paths.<series>")
+		assert.EqualError(t, SetValue(path, "silent", "maybe"), "silent must be true or false: maybe")
+		assert.ErrorContains(t, SetValue(path, "cache", "false"), "cache.enabled")
+		assert.ErrorContains(t, SetValue(path, "cache.ttl", "forever"), "invalid cache.ttl")
+		assert.EqualError(t, SetValue(path, "cache.size", "1GB"), "unknown config key: cache.size")
+		assert.ErrorContains(t, SetValue(filepath.Join(t.TempDir(), ".spc.json"), "target", "3"), "only YAML")
+		assert.ErrorContains(t, SetValue(path, "target_aliases", "2"), "target_aliases.<name>")
+		assert.ErrorContains(t, SetValue(path, "target_aliases.s2", "2"), "may not contain digits")
+		assert.ErrorContains(t, SetValue(path, "target_aliases.legacy", "9"), "invalid target series: 9")
+		assert.NoFileExists(t, path)
+	})
+
+	t.Run("targets may use aliases", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".spc.yml")
+
+		require.NoError(t, SetValue(path, "target", "modern"))
+		assert.EqualError(t, SetValue(path, "target", "legacy"), "invalid target series: legacy")
+		require.NoError(t, SetValue(path, "target_aliases.legacy", "2"))
+		require.NoError(t, SetValue(path, "target", "legacy"))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, `target: "legacy"
+target_aliases:
+  legacy: "2"
+`, string(data))
+	})
+}
+
+func TestSetValue_Cache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".spc.yml")
+
+	require.NoError(t, SetValue(path, "cache.enabled", "false"))
+	require.NoError(t, SetValue(path, "cache.max_size", "500MB"))
+	require.NoError(t, SetValue(path, "cache.remote.read_only", "true"))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `cache:
+  enabled: false
+  max_size: "500MB"
+  remote:
+    read_only: true
+`, string(data))
+
+	value, err := GetValue(path, "cache.max_size")
+	require.NoError(t, err)
+	assert.Equal(t, "500MB", value)
+}
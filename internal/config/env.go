@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Environment variables that override the config files for one invocation.
+// Command line flags still take precedence over them
+const (
+	// CompilerPathEnv overrides compiler_path
+	CompilerPathEnv = "SPC_COMPILER_PATH"
+
+	// TargetEnv overrides target
+	TargetEnv = "SPC_TARGET"
+
+	// SilentEnv overrides silent (true or false)
+	SilentEnv = "SPC_SILENT"
+
+	// UserFoldersEnv overrides usersplusfolder with a list of folders
+	// separated like PATH (';' on Windows, ':' elsewhere)
+	UserFoldersEnv = "SPC_USERSPLUSFOLDER"
+)
+
+// envSettings returns the settings given by the SPC_* environment variables
+// that are set
+func envSettings(getenv func(string) string) (map[string]any, error) {
+	settings := make(map[string]any)
+
+	if path := getenv(CompilerPathEnv); path != "" {
+		settings["compiler_path"] = path
+	}
+
+	if target := getenv(TargetEnv); target != "" {
+		settings["target"] = target
+	}
+
+	if value := getenv(SilentEnv); value != "" {
+		silent, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q (expected true or false)", SilentEnv, value)
+		}
+
+		settings["silent"] = silent
+	}
+
+	if value := getenv(UserFoldersEnv); value != "" {
+		var folders []any
+		for _, folder := range filepath.SplitList(value) {
+			if folder != "" {
+				folders = append(folders, folder)
+			}
+		}
+
+		settings["usersplusfolder"] = folders
+	}
+
+	return settings, nil
+}
+
+// applyEnv overlays the settings of the SPC_* environment variables on top
+// of the config files and profile. Command line flags still take precedence
+// since they are bound afterwards
+func (l *Loader) applyEnv() error {
+	settings, err := envSettings(os.Getenv)
+	if err != nil {
+		return err
+	}
+
+	if len(settings) == 0 {
+		return nil
+	}
+
+	return l.v.MergeConfigMap(settings)
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvSettings(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(name string) string { return values[name] }
+	}
+
+	settings, err := envSettings(env(nil))
+	require.NoError(t, err)
+	assert.Empty(t, settings)
+
+	list := strings.Join([]string{"libs", "", "more"}, string(os.PathListSeparator))
+	settings, err = envSettings(env(map[string]string{TargetEnv: "all", SilentEnv: "1", UserFoldersEnv: list}))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"target":          "all",
+		"silent":          true,
+		"usersplusfolder": []any{"libs", "more"},
+	}, settings)
+
+	_, err = envSettings(env(map[string]string{SilentEnv: "sometimes"}))
+	assert.ErrorContains(t, err, "invalid SPC_SILENT")
+}
+
+func TestLoader_LoadForBuild_Env(t *testing.T) {
+	t.Setenv("APPDATA", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(CompilerPathEnv, "")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".spc.yml"), []byte(`target: "34"
+silent: false
+usersplusfolder:
+  - "C:/ConfigLibs"
+profiles:
+  dev:
+    target: "4"`), 0o644))
+
+	newCmd := func() *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().StringP("target", "t", "", "Target series")
+		cmd.Flags().BoolP("silent", "s", false, "Silent mode")
+		cmd.Flags().StringSliceP("usersplusfolder", "u", []string{}, "User folders")
+		cmd.Flags().String("profile", "", "Profile")
+		return cmd
+	}
+
+	abs := func(path string) string {
+		abs, _ := filepath.Abs(path)
+		return abs
+	}
+
+	t.Setenv(TargetEnv, "2")
+	t.Setenv(SilentEnv, "true")
+	t.Setenv(UserFoldersEnv, "env-libs"+string(os.PathListSeparator)+"more-libs")
+
+	t.Run("environment overrides config files and profiles", func(t *testing.T) {
+		cmd := newCmd()
+		_ = cmd.Flags().Set("profile", "dev")
+
+		cfg, err := NewLoader().LoadForBuild(cmd, []string{dir})
+		require.NoError(t, err)
+		assert.Equal(t, "2", cfg.Target)
+		assert.True(t, cfg.Silent)
+		assert.Equal(t, []string{abs("env-libs"), abs("more-libs")}, cfg.UserFolders)
+
+		value, err := NewLoader().Lookup(dir, "target")
+		require.NoError(t, err)
+		assert.Equal(t, "2", value)
+	})
+
+	t.Run("flags override the environment", func(t *testing.T) {
+		cmd := newCmd()
+		_ = cmd.Flags().Set("target", "3")
+		_ = cmd.Flags().Set("silent", "false")
+		_ = cmd.Flags().Set("usersplusfolder", "C:/FlagLibs")
+
+		cfg, err := NewLoader().LoadForBuild(cmd, []string{dir})
+		require.NoError(t, err)
+		assert.Equal(t, "3", cfg.Target)
+		assert.False(t, cfg.Silent)
+		assert.Equal(t, []string{abs("C:/FlagLibs")}, cfg.UserFolders)
+	})
+
+	t.Run("invalid value is an error", func(t *testing.T) {
+		t.Setenv(SilentEnv, "maybe")
+
+		_, err := NewLoader().LoadForBuild(newCmd(), []string{dir})
+		assert.ErrorContains(t, err, SilentEnv)
+	})
+}
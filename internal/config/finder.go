@@ -3,12 +3,16 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 )
 
+// configExtensions are the supported config file formats, in lookup order
+var configExtensions = []string{"yml", "yaml", "json", "toml"}
+
 // FindLocalConfig finds local config file by walking up directories
 func FindLocalConfig(dir string) string {
 	for {
-		for _, ext := range []string{"yml", "yaml", "json", "toml"} {
+		for _, ext := range configExtensions {
 			path := filepath.Join(dir, ".spc."+ext)
 
 			if _, err := os.Stat(path); err == nil {
@@ -26,3 +30,51 @@ func FindLocalConfig(dir string) string {
 
 	return ""
 }
+
+// GlobalConfigDirs returns the directories searched for the global config, in order:
+//
+//	%APPDATA%\spc                         (Windows)
+//	$XDG_CONFIG_HOME/spc                  (when set)
+//	~/Library/Application Support/spc     (macOS)
+//	~/.config/spc                         (non-Windows XDG default)
+func GlobalConfigDirs() []string {
+	return globalConfigDirs(os.Getenv, runtime.GOOS)
+}
+
+func globalConfigDirs(getenv func(string) string, goos string) []string {
+	var dirs []string
+
+	if appdata := getenv("APPDATA"); appdata != "" {
+		dirs = append(dirs, filepath.Join(appdata, "spc"))
+	}
+
+	if xdg := getenv("XDG_CONFIG_HOME"); xdg != "" {
+		dirs = append(dirs, filepath.Join(xdg, "spc"))
+	}
+
+	home := getenv("HOME")
+	if home == "" || goos == "windows" {
+		return dirs
+	}
+
+	if goos == "darwin" {
+		dirs = append(dirs, filepath.Join(home, "Library", "Application Support", "spc"))
+	}
+
+	return append(dirs, filepath.Join(home, ".config", "spc"))
+}
+
+// FindGlobalConfig returns the first global config file found, or an empty string
+func FindGlobalConfig() string {
+	for _, dir := range GlobalConfigDirs() {
+		for _, ext := range configExtensions {
+			path := filepath.Join(dir, "config."+ext)
+
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+	}
+
+	return ""
+}
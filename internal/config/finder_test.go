@@ -32,3 +32,45 @@ func TestFindLocalConfig(t *testing.T) {
 	result = FindLocalConfig(tempDir)
 	assert.Equal(t, "", result)
 }
+
+func TestGlobalConfigDirs(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+
+	t.Run("windows uses APPDATA", func(t *testing.T) {
+		dirs := globalConfigDirs(env(map[string]string{"APPDATA": "C:/Users/me/AppData/Roaming", "HOME": "C:/Users/me"}), "windows")
+		assert.Equal(t, []string{filepath.Join("C:/Users/me/AppData/Roaming", "spc")}, dirs)
+	})
+
+	t.Run("linux honors XDG_CONFIG_HOME before ~/.config", func(t *testing.T) {
+		dirs := globalConfigDirs(env(map[string]string{"XDG_CONFIG_HOME": "/xdg", "HOME": "/home/me"}), "linux")
+		assert.Equal(t, []string{
+			filepath.Join("/xdg", "spc"),
+			filepath.Join("/home/me", ".config", "spc"),
+		}, dirs)
+	})
+
+	t.Run("macOS adds Application Support", func(t *testing.T) {
+		dirs := globalConfigDirs(env(map[string]string{"HOME": "/Users/me"}), "darwin")
+		assert.Equal(t, []string{
+			filepath.Join("/Users/me", "Library", "Application Support", "spc"),
+			filepath.Join("/Users/me", ".config", "spc"),
+		}, dirs)
+	})
+}
+
+func TestFindGlobalConfig(t *testing.T) {
+	xdg := t.TempDir()
+	t.Setenv("APPDATA", "")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+
+	assert.Equal(t, "", FindGlobalConfig())
+
+	configPath := filepath.Join(xdg, "spc", "config.yml")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(configPath), 0o755))
+	assert.NoError(t, os.WriteFile(configPath, []byte("target: \"3\""), 0o644))
+
+	assert.Equal(t, configPath, FindGlobalConfig())
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+)
+
+// Hook events
+const (
+	HookPreBuild  = "pre_build"
+	HookPostBuild = "post_build"
+	HookOnFailure = "on_failure"
+)
+
+// HooksConfig holds the commands of the hooks: section. Each is run with the
+// platform's shell for every file built, with SPC_* environment variables
+// describing the build
+type HooksConfig struct {
+	// PreBuild run before a file is built (or restored from the cache). A
+	// failing command fails the file
+	PreBuild []string
+
+	// PostBuild run after a file is built or restored. A failing command
+	// fails the file
+	PostBuild []string
+
+	// OnFailure run after a file fails, including when a hook failed it
+	OnFailure []string
+}
+
+// hookKeys are the keys allowed in the hooks: section
+var hookKeys = map[string]bool{
+	HookPreBuild:  true,
+	HookPostBuild: true,
+	HookOnFailure: true,
+}
+
+// loadHooks reads the hooks: section from v
+func loadHooks(v *viper.Viper) HooksConfig {
+	return HooksConfig{
+		PreBuild:  hookCommands(v, HookPreBuild),
+		PostBuild: hookCommands(v, HookPostBuild),
+		OnFailure: hookCommands(v, HookOnFailure),
+	}
+}
+
+// hookCommands returns the commands of a hook. A single command may be
+// given as a string
+func hookCommands(v *viper.Viper, event string) []string {
+	key := "hooks." + event
+
+	if command, ok := v.Get(key).(string); ok {
+		if command == "" {
+			return nil
+		}
+
+		return []string{command}
+	}
+
+	return v.GetStringSlice(key)
+}
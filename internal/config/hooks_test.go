@@ -0,0 +1,62 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Hooks(t *testing.T) {
+	v := viper.New()
+	v.Set("compiler_path", "C:/SPlusCC.exe")
+	v.Set("target", "34")
+	v.Set("hooks", map[string]any{
+		"pre_build":  "./bump-version.sh",
+		"post_build": []any{"cp $SPC_MODULE.ush dist/", "echo done"},
+	})
+
+	cfg, err := Load(v)
+	require.NoError(t, err)
+
+	assert.Equal(t, HooksConfig{
+		PreBuild:  []string{"./bump-version.sh"},
+		PostBuild: []string{"cp $SPC_MODULE.ush dist/", "echo done"},
+	}, cfg.Hooks)
+}
+
+func TestValidateFile_Hooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".spc.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`hooks:
+  pre_build: "./bump-version.sh"
+  post_build:
+    - "cp $SPC_MODULE.ush dist/"
+  on_failure:
+    command: "notify"
+  after_build: "x"
+`), 0o644))
+
+	problems, err := ValidateFile(path)
+	require.NoError(t, err)
+
+	var keys []string
+	for _, p := range problems {
+		keys = append(keys, p.Key)
+	}
+
+	assert.Equal(t, []string{"hooks.after_build", "hooks.on_failure"}, keys)
+}
+
+func TestSetValue_Hooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".spc.yml")
+
+	require.NoError(t, SetValue(path, "hooks.post_build", "./copy.sh"))
+	value, err := GetValue(path, "hooks.post_build")
+	require.NoError(t, err)
+	assert.Equal(t, "./copy.sh", value)
+
+	assert.ErrorContains(t, SetValue(path, "hooks", "x"), "set hooks.pre_build")
+}
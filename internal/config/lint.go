@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Norgate-AV/spc/internal/lint"
+	"github.com/spf13/viper"
+)
+
+// loadLint reads the lint: section from v, a map of lint rules to their
+// severity (error, warning or off)
+func loadLint(v *viper.Viper) map[string]string {
+	rules := v.GetStringMapString("lint")
+	if len(rules) == 0 {
+		return nil
+	}
+
+	return rules
+}
+
+// isLintRule reports whether id names a lint rule
+func isLintRule(id string) bool {
+	_, ok := lint.Lookup(id)
+	return ok
+}
+
+// validateLint checks the rules and severities of the lint: section
+func validateLint(rules map[string]string) error {
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if !isLintRule(id) {
+			return fmt.Errorf("unknown lint rule: %s", id)
+		}
+
+		if _, err := lint.ParseSeverity(rules[id]); err != nil {
+			return fmt.Errorf("invalid lint.%s: %w", id, err)
+		}
+	}
+
+	return nil
+}
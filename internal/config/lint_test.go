@@ -0,0 +1,62 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Lint(t *testing.T) {
+	v := viper.New()
+	v.Set("compiler_path", "C:/SPlusCC.exe")
+	v.Set("target", "34")
+	v.Set("lint", map[string]any{"magic_wait": "off", "unused_variable": "error"})
+
+	cfg, err := Load(v)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"magic_wait": "off", "unused_variable": "error"}, cfg.Lint)
+
+	v.Set("lint", map[string]any{"magic_wait": "loud"})
+	_, err = Load(v)
+	assert.ErrorContains(t, err, "invalid lint.magic_wait")
+
+	v.Set("lint", map[string]any{"tabs": "error"})
+	_, err = Load(v)
+	assert.ErrorContains(t, err, "unknown lint rule: tabs")
+}
+
+func TestValidateFile_Lint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".spc.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`lint:
+  magic_wait: off
+  unused_variable: info
+  tabs: error
+`), 0o644))
+
+	problems, err := ValidateFile(path)
+	require.NoError(t, err)
+
+	var keys []string
+	for _, p := range problems {
+		keys = append(keys, p.Key)
+	}
+
+	assert.Equal(t, []string{"lint.tabs", "lint.unused_variable"}, keys)
+}
+
+func TestSetValue_Lint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".spc.yml")
+
+	require.NoError(t, SetValue(path, "lint.magic_wait", "Off"))
+	value, err := GetValue(path, "lint.magic_wait")
+	require.NoError(t, err)
+	assert.Equal(t, "off", value)
+
+	assert.ErrorContains(t, SetValue(path, "lint.magic_wait", "loud"), "invalid severity")
+	assert.ErrorContains(t, SetValue(path, "lint", "x"), "set lint.<rule>")
+	assert.ErrorContains(t, SetValue(path, "lint.tabs", "off"), "unknown config key")
+}
@@ -1,53 +1,165 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-// Loader handles configuration loading from various sources
-type Loader struct{}
+// Loader handles configuration loading from various sources.
+// Each load uses its own viper instance, so loaders never share state and
+// the returned Config is self-contained
+type Loader struct {
+	// v holds the settings of the current load
+	v *viper.Viper
+
+	// localConfig is the path of the local config file that was loaded, if any
+	localConfig string
+
+	// globalConfig is the path of the global config file that was loaded, if any
+	globalConfig string
+}
 
 // NewLoader creates a new configuration loader
 func NewLoader() *Loader {
-	return &Loader{}
+	return &Loader{v: viper.New()}
+}
+
+// reset discards the settings of any previous load
+func (l *Loader) reset() {
+	l.v = viper.New()
+	l.localConfig = ""
+	l.globalConfig = ""
 }
 
 // LoadForBuild loads configuration specifically for build operations
 func (l *Loader) LoadForBuild(cmd *cobra.Command, args []string) (*Config, error) {
+	l.reset()
 	l.setupViperDefaults()
 	l.loadGlobalConfig()
 	l.loadLocalConfig(args)
+
+	profile, _ := cmd.Flags().GetString("profile")
+	if err := l.applyProfile(profile); err != nil {
+		return nil, err
+	}
+
+	if err := l.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	l.bindCommandFlags(cmd)
+
+	cfg, err := Load(l.v)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ProjectDir = l.projectDir()
+
+	return cfg, nil
+}
+
+// LoadProject loads configuration for a project directory without any
+// command line flags: defaults, global config, the local config found in dir
+// (or its parents), then the named profile (empty for none)
+func (l *Loader) LoadProject(dir, profile string) (*Config, error) {
+	l.reset()
+	l.setupViperDefaults()
+	l.loadGlobalConfig()
+	l.loadLocalConfigFrom(dir)
+
+	if err := l.applyProfile(profile); err != nil {
+		return nil, err
+	}
+
+	if err := l.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	cfg, err := Load(l.v)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.ProjectDir = dir
+	if l.localConfig != "" {
+		cfg.ProjectDir = filepath.Dir(l.localConfig)
+	}
+
+	return cfg, nil
+}
+
+// LoadForWorkspaceProject loads build configuration for one project of a
+// workspace: like LoadForBuild, with the local config found in dir (or its
+// parents) and the command line flags applied on top
+func (l *Loader) LoadForWorkspaceProject(cmd *cobra.Command, dir string) (*Config, error) {
+	l.reset()
+	l.setupViperDefaults()
+	l.loadGlobalConfig()
+	l.loadLocalConfigFrom(dir)
+
+	profile, _ := cmd.Flags().GetString("profile")
+	if err := l.applyProfile(profile); err != nil {
+		return nil, err
+	}
+
+	if err := l.applyEnv(); err != nil {
+		return nil, err
+	}
+
 	l.bindCommandFlags(cmd)
 
-	return Load()
+	cfg, err := Load(l.v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+
+	cfg.ProjectDir = dir
+	if l.localConfig != "" {
+		cfg.ProjectDir = filepath.Dir(l.localConfig)
+	}
+
+	return cfg, nil
+}
+
+// projectDir returns the directory of the local config file, falling back
+// to the working directory
+func (l *Loader) projectDir() string {
+	if l.localConfig != "" {
+		return filepath.Dir(l.localConfig)
+	}
+
+	cwd, _ := os.Getwd()
+	return cwd
 }
 
-// setupViperDefaults sets up default values for viper
+// setupViperDefaults sets up default values
 func (l *Loader) setupViperDefaults() {
-	viper.SetDefault("compiler_path", DefaultCompilerPath)
-	viper.SetDefault("target", DefaultTarget)
-	viper.SetDefault("silent", DefaultSilent)
-	viper.SetDefault("verbose", DefaultVerbose)
+	l.v.SetDefault("compiler_path", defaultCompiler())
+	l.v.SetDefault("target", DefaultTarget)
+	l.v.SetDefault("silent", DefaultSilent)
+	l.v.SetDefault("verbose", DefaultVerbose)
 }
 
-// loadGlobalConfig loads global configuration from APPDATA
+// loadGlobalConfig loads global configuration from the first config
+// directory that has one (see GlobalConfigDirs)
 func (l *Loader) loadGlobalConfig() {
-	appdata := os.Getenv("APPDATA")
-	if appdata != "" {
-		globalDir := filepath.Join(appdata, "spc")
-
-		for _, ext := range []string{"yml", "yaml", "json", "toml"} {
+	for _, globalDir := range GlobalConfigDirs() {
+		for _, ext := range configExtensions {
 			globalPath := filepath.Join(globalDir, "config."+ext)
 
 			if _, err := os.Stat(globalPath); err == nil {
-				viper.SetConfigFile(globalPath)
+				l.v.SetConfigFile(globalPath)
 
-				if err := viper.ReadInConfig(); err == nil {
-					break
+				if err := l.v.ReadInConfig(); err == nil {
+					l.globalConfig = globalPath
+					return
 				}
 			}
 		}
@@ -55,26 +167,121 @@ func (l *Loader) loadGlobalConfig() {
 }
 
 // loadLocalConfig loads local configuration from project directory
+// (the first argument itself when it is a directory, else its parent,
+// or the working directory when there are no arguments)
 func (l *Loader) loadLocalConfig(args []string) {
-	if len(args) > 0 {
-		absFirstFile, err := filepath.Abs(args[0])
-		if err != nil {
-			return // silently ignore, config.Load() will handle validation
+	if len(args) == 0 {
+		if cwd, err := os.Getwd(); err == nil {
+			l.loadLocalConfigFrom(cwd)
+		}
+
+		return
+	}
+
+	absFirstFile, err := filepath.Abs(args[0])
+	if err != nil {
+		return // silently ignore, config.Load() will handle validation
+	}
+
+	if info, err := os.Stat(absFirstFile); err == nil && info.IsDir() {
+		l.loadLocalConfigFrom(absFirstFile)
+		return
+	}
+
+	l.loadLocalConfigFrom(filepath.Dir(absFirstFile))
+}
+
+// loadLocalConfigFrom loads the local config found in dir or its parents,
+// merging it over the global config so keys it doesn't set are kept
+func (l *Loader) loadLocalConfigFrom(dir string) {
+	localPath := FindLocalConfig(dir)
+	if localPath != "" {
+		l.v.SetConfigFile(localPath)
+		if err := l.v.MergeInConfig(); err == nil {
+			l.localConfig = localPath
+		}
+	}
+}
+
+// Lookup returns the effective value of a config key for a project directory
+// (defaults, then global config, then local config, then the SPC_*
+// environment variables), or nil if it is not set
+func (l *Loader) Lookup(dir, key string) (any, error) {
+	if _, err := settingName(key); err != nil {
+		return nil, err
+	}
+
+	l.reset()
+	l.setupViperDefaults()
+	l.loadGlobalConfig()
+	l.loadLocalConfigFrom(dir)
+
+	if err := l.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	return l.v.Get(key), nil
+}
+
+// applyProfile overlays the settings of a named profile from the `profiles:`
+// section on top of the loaded config files. Command line flags still take
+// precedence since they are bound afterwards
+func (l *Loader) applyProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	profiles := l.v.GetStringMap("profiles")
+
+	raw, ok := profiles[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
 		}
 
-		dir := filepath.Dir(absFirstFile)
-		localPath := FindLocalConfig(dir)
-		if localPath != "" {
-			viper.SetConfigFile(localPath)
-			_ = viper.ReadInConfig()
+		sort.Strings(names)
+
+		if len(names) == 0 {
+			return fmt.Errorf("unknown profile %q: no profiles are defined", name)
 		}
+
+		return fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(names, ", "))
+	}
+
+	settings, ok := raw.(map[string]any)
+	if !ok {
+		return fmt.Errorf("profile %q must be a map of settings", name)
+	}
+
+	if err := l.v.MergeConfigMap(settings); err != nil {
+		return fmt.Errorf("failed to apply profile %q: %w", name, err)
 	}
+
+	l.v.Set("profile", name)
+
+	return nil
 }
 
 // bindCommandFlags binds command flags to viper
 func (l *Loader) bindCommandFlags(cmd *cobra.Command) {
-	_ = viper.BindPFlag("target", cmd.Flags().Lookup("target"))
-	_ = viper.BindPFlag("verbose", cmd.Flags().Lookup("verbose"))
-	_ = viper.BindPFlag("out", cmd.Flags().Lookup("out"))
-	_ = viper.BindPFlag("usersplusfolder", cmd.Flags().Lookup("usersplusfolder"))
+	_ = l.v.BindPFlag("compiler_path", cmd.Flags().Lookup("compiler-path"))
+	_ = l.v.BindPFlag("target", cmd.Flags().Lookup("target"))
+	_ = l.v.BindPFlag("silent", cmd.Flags().Lookup("silent"))
+	_ = l.v.BindPFlag("verbose", cmd.Flags().Lookup("verbose"))
+	_ = l.v.BindPFlag("quiet", cmd.Flags().Lookup("quiet"))
+	_ = l.v.BindPFlag("out", cmd.Flags().Lookup("out"))
+	_ = l.v.BindPFlag("out_dir", cmd.Flags().Lookup("out-dir"))
+	_ = l.v.BindPFlag("install", cmd.Flags().Lookup("install"))
+	_ = l.v.BindPFlag("usersplusfolder", cmd.Flags().Lookup("usersplusfolder"))
+	_ = l.v.BindPFlag("no_cache", cmd.Flags().Lookup("no-cache"))
+	_ = l.v.BindPFlag("force", cmd.Flags().Lookup("force"))
+	_ = l.v.BindPFlag("keep_going", cmd.Flags().Lookup("keep-going"))
+	_ = l.v.BindPFlag("depfile", cmd.Flags().Lookup("depfile"))
+	_ = l.v.BindPFlag("sandbox", cmd.Flags().Lookup("sandbox"))
+	_ = l.v.BindPFlag("parallel_series", cmd.Flags().Lookup("parallel-series"))
+	_ = l.v.BindPFlag("split_work", cmd.Flags().Lookup("split-work"))
+	_ = l.v.BindPFlag("cache.read_only", cmd.Flags().Lookup("cache-readonly"))
+	_ = l.v.BindPFlag("notify.enabled", cmd.Flags().Lookup("notify"))
+	_ = l.v.BindPFlag("runner", cmd.Flags().Lookup("runner"))
 }
@@ -3,10 +3,10 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -17,14 +17,13 @@ func TestNewLoader(t *testing.T) {
 }
 
 func TestLoader_SetupViperDefaults(t *testing.T) {
-	viper.Reset()
 	loader := NewLoader()
 	loader.setupViperDefaults()
 
-	assert.Equal(t, "C:/Program Files (x86)/Crestron/Simpl/SPlusCC.exe", viper.GetString("compiler_path"))
-	assert.Equal(t, "34", viper.GetString("target"))
-	assert.Equal(t, false, viper.GetBool("silent"))
-	assert.Equal(t, false, viper.GetBool("verbose"))
+	assert.Equal(t, "C:/Program Files (x86)/Crestron/Simpl/SPlusCC.exe", loader.v.GetString("compiler_path"))
+	assert.Equal(t, "34", loader.v.GetString("target"))
+	assert.Equal(t, false, loader.v.GetBool("silent"))
+	assert.Equal(t, false, loader.v.GetBool("verbose"))
 }
 
 func TestLoader_LoadGlobalConfig(t *testing.T) {
@@ -36,7 +35,6 @@ func TestLoader_LoadGlobalConfig(t *testing.T) {
 
 	// Test with YAML config
 	t.Run("loads yaml config", func(t *testing.T) {
-		viper.Reset()
 		configPath := filepath.Join(spcDir, "config.yml")
 		configContent := `compiler_path: "C:/Custom/SPlusCC.exe"
 target: "3"
@@ -53,15 +51,13 @@ verbose: true`
 		loader.loadGlobalConfig()
 
 		// Viper should have read the config
-		assert.Equal(t, "C:/Custom/SPlusCC.exe", viper.GetString("compiler_path"))
-		assert.Equal(t, "3", viper.GetString("target"))
-		assert.Equal(t, true, viper.GetBool("verbose"))
+		assert.Equal(t, "C:/Custom/SPlusCC.exe", loader.v.GetString("compiler_path"))
+		assert.Equal(t, "3", loader.v.GetString("target"))
+		assert.Equal(t, true, loader.v.GetBool("verbose"))
 	})
 
 	// Test with JSON config
 	t.Run("loads json config", func(t *testing.T) {
-		viper.Reset()
-
 		// Remove YAML file
 		os.Remove(filepath.Join(spcDir, "config.yml"))
 
@@ -81,14 +77,12 @@ verbose: true`
 		loader := NewLoader()
 		loader.loadGlobalConfig()
 
-		assert.Equal(t, "C:/Json/SPlusCC.exe", viper.GetString("compiler_path"))
-		assert.Equal(t, "4", viper.GetString("target"))
+		assert.Equal(t, "C:/Json/SPlusCC.exe", loader.v.GetString("compiler_path"))
+		assert.Equal(t, "4", loader.v.GetString("target"))
 	})
 
 	// Test with no APPDATA
 	t.Run("handles missing APPDATA gracefully", func(t *testing.T) {
-		viper.Reset()
-
 		oldAppData := os.Getenv("APPDATA")
 		defer os.Setenv("APPDATA", oldAppData)
 		os.Setenv("APPDATA", "")
@@ -105,8 +99,6 @@ verbose: true`
 
 func TestLoader_LoadLocalConfig(t *testing.T) {
 	t.Run("loads local config from file directory", func(t *testing.T) {
-		viper.Reset()
-
 		tempDir := t.TempDir()
 		configPath := filepath.Join(tempDir, ".spc.yml")
 		configContent := `compiler_path: "C:/Local/SPlusCC.exe"
@@ -122,13 +114,11 @@ target: "34"`
 		loader := NewLoader()
 		loader.loadLocalConfig([]string{testFile})
 
-		assert.Equal(t, "C:/Local/SPlusCC.exe", viper.GetString("compiler_path"))
-		assert.Equal(t, "34", viper.GetString("target"))
+		assert.Equal(t, "C:/Local/SPlusCC.exe", loader.v.GetString("compiler_path"))
+		assert.Equal(t, "34", loader.v.GetString("target"))
 	})
 
 	t.Run("walks up directory tree to find config", func(t *testing.T) {
-		viper.Reset()
-
 		tempDir := t.TempDir()
 		subDir := filepath.Join(tempDir, "subdir", "nested")
 		err := os.MkdirAll(subDir, 0o755)
@@ -148,12 +138,21 @@ target: "34"`
 		loader := NewLoader()
 		loader.loadLocalConfig([]string{testFile})
 
-		assert.Equal(t, "2", viper.GetString("target"))
+		assert.Equal(t, "2", loader.v.GetString("target"))
 	})
 
-	t.Run("handles empty args", func(t *testing.T) {
-		viper.Reset()
+	t.Run("directory argument uses its own config", func(t *testing.T) {
+		tempDir := t.TempDir()
+		err := os.WriteFile(filepath.Join(tempDir, ".spc.yml"), []byte(`target: "4"`), 0o644)
+		require.NoError(t, err)
+
+		loader := NewLoader()
+		loader.loadLocalConfig([]string{tempDir})
 
+		assert.Equal(t, "4", loader.v.GetString("target"))
+	})
+
+	t.Run("handles empty args", func(t *testing.T) {
 		loader := NewLoader()
 		loader.loadLocalConfig([]string{})
 
@@ -164,8 +163,6 @@ target: "34"`
 	})
 
 	t.Run("handles invalid file path", func(t *testing.T) {
-		viper.Reset()
-
 		loader := NewLoader()
 		loader.loadLocalConfig([]string{"nonexistent/file.usp"})
 
@@ -177,8 +174,6 @@ target: "34"`
 }
 
 func TestLoader_BindCommandFlags(t *testing.T) {
-	viper.Reset()
-
 	cmd := &cobra.Command{}
 	cmd.Flags().StringP("target", "t", "", "Target series")
 	cmd.Flags().BoolP("verbose", "v", false, "Verbose output")
@@ -194,18 +189,16 @@ func TestLoader_BindCommandFlags(t *testing.T) {
 	loader := NewLoader()
 	loader.bindCommandFlags(cmd)
 
-	assert.Equal(t, "3", viper.GetString("target"))
-	assert.Equal(t, true, viper.GetBool("verbose"))
-	assert.Equal(t, "custom.log", viper.GetString("out"))
-	folders := viper.GetStringSlice("usersplusfolder")
+	assert.Equal(t, "3", loader.v.GetString("target"))
+	assert.Equal(t, true, loader.v.GetBool("verbose"))
+	assert.Equal(t, "custom.log", loader.v.GetString("out"))
+	folders := loader.v.GetStringSlice("usersplusfolder")
 	assert.Contains(t, folders, "C:/Include1")
 	assert.Contains(t, folders, "C:/Include2")
 }
 
 func TestLoader_LoadForBuild_Integration(t *testing.T) {
 	t.Run("hierarchical config loading - flags override local override global", func(t *testing.T) {
-		viper.Reset()
-
 		// Setup temp directories
 		tempDir := t.TempDir()
 		spcDir := filepath.Join(tempDir, "spc")
@@ -262,3 +255,249 @@ verbose: true`
 		assert.NotEmpty(t, cfg.CompilerPath)
 	})
 }
+
+func TestLoader_ApplyProfile(t *testing.T) {
+	writeConfig := func(t *testing.T) string {
+		dir := t.TempDir()
+		content := `target: "34"
+profiles:
+  dev:
+    target: "4"
+  release:
+    target: "234"
+    no_cache: true
+    keep_going: true`
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".spc.yml"), []byte(content), 0o644))
+
+		testFile := filepath.Join(dir, "test.usp")
+		require.NoError(t, os.WriteFile(testFile, []byte("// test"), 0o644))
+
+		return testFile
+	}
+
+	newCmd := func() *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("profile", "", "Profile")
+		cmd.Flags().StringP("target", "t", "", "Target series")
+		cmd.Flags().Bool("no-cache", false, "Disable cache")
+		return cmd
+	}
+
+	t.Setenv("APPDATA", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", t.TempDir())
+
+	t.Run("no profile uses top-level settings", func(t *testing.T) {
+		cfg, err := NewLoader().LoadForBuild(newCmd(), []string{writeConfig(t)})
+		require.NoError(t, err)
+		assert.Equal(t, "34", cfg.Target)
+		assert.Equal(t, "", cfg.Profile)
+	})
+
+	t.Run("profile overrides config file settings", func(t *testing.T) {
+		cmd := newCmd()
+		_ = cmd.Flags().Set("profile", "release")
+
+		cfg, err := NewLoader().LoadForBuild(cmd, []string{writeConfig(t)})
+		require.NoError(t, err)
+		assert.Equal(t, "234", cfg.Target)
+		assert.True(t, cfg.NoCache)
+		assert.True(t, cfg.KeepGoing)
+		assert.Equal(t, "release", cfg.Profile)
+	})
+
+	t.Run("flags override profile settings", func(t *testing.T) {
+		cmd := newCmd()
+		_ = cmd.Flags().Set("profile", "dev")
+		_ = cmd.Flags().Set("target", "3")
+
+		cfg, err := NewLoader().LoadForBuild(cmd, []string{writeConfig(t)})
+		require.NoError(t, err)
+		assert.Equal(t, "3", cfg.Target)
+	})
+
+	t.Run("unknown profile is an error", func(t *testing.T) {
+		cmd := newCmd()
+		_ = cmd.Flags().Set("profile", "ci")
+
+		_, err := NewLoader().LoadForBuild(cmd, []string{writeConfig(t)})
+		assert.EqualError(t, err, `unknown profile "ci" (available: dev, release)`)
+	})
+}
+
+func TestLoader_LoadForBuild_MergesGlobalAndLocal(t *testing.T) {
+	globalDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(globalDir, "spc"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(globalDir, "spc", "config.yml"), []byte(`compiler_path: "C:/Global/SPlusCC.exe"
+target: "2"
+usersplusfolder:
+  - "C:/GlobalLibs"
+silent: true`), 0o644))
+
+	t.Setenv("APPDATA", globalDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", t.TempDir())
+
+	newCmd := func() *cobra.Command {
+		cmd := &cobra.Command{}
+		cmd.Flags().StringP("target", "t", "", "Target series")
+		cmd.Flags().BoolP("silent", "s", false, "Silent mode")
+		return cmd
+	}
+
+	localDir := t.TempDir()
+	testFile := filepath.Join(localDir, "test.usp")
+	require.NoError(t, os.WriteFile(testFile, []byte("// test"), 0o644))
+
+	t.Run("local config only overrides the keys it sets", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(localDir, ".spc.yml"), []byte(`target: "34"`), 0o644))
+
+		cfg, err := NewLoader().LoadForBuild(newCmd(), []string{testFile})
+		require.NoError(t, err)
+
+		compiler, _ := filepath.Abs("C:/Global/SPlusCC.exe")
+		libs, _ := filepath.Abs("C:/GlobalLibs")
+		assert.Equal(t, compiler, cfg.CompilerPath)
+		assert.Equal(t, "34", cfg.Target)
+		assert.Equal(t, []string{libs}, cfg.UserFolders)
+		assert.True(t, cfg.Silent)
+	})
+
+	t.Run("local lists replace global lists", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(localDir, ".spc.yml"), []byte(`usersplusfolder:
+  - "C:/LocalLibs"
+silent: false`), 0o644))
+
+		cfg, err := NewLoader().LoadForBuild(newCmd(), []string{testFile})
+		require.NoError(t, err)
+
+		libs, _ := filepath.Abs("C:/LocalLibs")
+		assert.Equal(t, "2", cfg.Target)
+		assert.Equal(t, []string{libs}, cfg.UserFolders)
+		assert.False(t, cfg.Silent)
+	})
+
+	t.Run("flags override both", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(localDir, ".spc.yml"), []byte(`target: "34"`), 0o644))
+
+		cmd := newCmd()
+		_ = cmd.Flags().Set("target", "4")
+
+		cfg, err := NewLoader().LoadForBuild(cmd, []string{testFile})
+		require.NoError(t, err)
+		assert.Equal(t, "4", cfg.Target)
+		assert.True(t, cfg.Silent)
+	})
+}
+
+func TestLoader_LoadForBuild_Isolated(t *testing.T) {
+	t.Setenv("APPDATA", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", t.TempDir())
+
+	writeProject := func(t *testing.T, target string) string {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".spc.yml"), []byte(`target: "`+target+`"`), 0o644))
+		return dir
+	}
+
+	projects := map[string]string{"2": writeProject(t, "2"), "3": writeProject(t, "3"), "4": writeProject(t, "4")}
+
+	var wg sync.WaitGroup
+	for target, dir := range projects {
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				cmd := &cobra.Command{}
+				cmd.Flags().StringP("target", "t", "", "Target series")
+
+				cfg, err := NewLoader().LoadForBuild(cmd, []string{dir})
+				assert.NoError(t, err)
+				if cfg != nil {
+					assert.Equal(t, target, cfg.Target)
+					assert.Equal(t, dir, cfg.ProjectDir)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	// A reused loader must not carry settings over from a previous load
+	loader := NewLoader()
+	_, err := loader.LoadForBuild(&cobra.Command{}, []string{projects["2"]})
+	require.NoError(t, err)
+
+	cfg, err := loader.LoadForBuild(&cobra.Command{}, []string{t.TempDir()})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTarget, cfg.Target)
+}
+
+func TestLoader_LoadProject(t *testing.T) {
+	t.Setenv("APPDATA", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", t.TempDir())
+
+	project := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(project, ".spc.yml"), []byte(`target: "3"
+profiles:
+  release:
+    target: "234"`), 0o644))
+
+	sub := filepath.Join(project, "src")
+	require.NoError(t, os.Mkdir(sub, 0o755))
+
+	cfg, err := NewLoader().LoadProject(sub, "")
+	require.NoError(t, err)
+	assert.Equal(t, "3", cfg.Target)
+	assert.Equal(t, project, cfg.ProjectDir)
+
+	cfg, err = NewLoader().LoadProject(sub, "release")
+	require.NoError(t, err)
+	assert.Equal(t, "234", cfg.Target)
+
+	empty := t.TempDir()
+	cfg, err = NewLoader().LoadProject(empty, "")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTarget, cfg.Target)
+	assert.Equal(t, empty, cfg.ProjectDir)
+}
+
+func TestLoader_LoadForWorkspaceProject(t *testing.T) {
+	t.Setenv("APPDATA", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", t.TempDir())
+
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, ".spc.yml"), []byte(`target: "2"
+verbose: true`), 0o644))
+
+	lighting := filepath.Join(workspace, "lighting")
+	require.NoError(t, os.Mkdir(lighting, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(lighting, ".spc.yml"), []byte(`target: "3"`), 0o644))
+
+	audio := filepath.Join(workspace, "audio")
+	require.NoError(t, os.Mkdir(audio, 0o755))
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("profile", "", "Profile")
+	cmd.Flags().StringP("target", "t", "", "Target series")
+
+	cfg, err := NewLoader().LoadForWorkspaceProject(cmd, lighting)
+	require.NoError(t, err)
+	assert.Equal(t, "3", cfg.Target)
+	assert.Equal(t, lighting, cfg.ProjectDir)
+
+	// A project without a config of its own uses the workspace's
+	cfg, err = NewLoader().LoadForWorkspaceProject(cmd, audio)
+	require.NoError(t, err)
+	assert.Equal(t, "2", cfg.Target)
+	assert.Equal(t, workspace, cfg.ProjectDir)
+
+	_ = cmd.Flags().Set("target", "4")
+
+	cfg, err = NewLoader().LoadForWorkspaceProject(cmd, lighting)
+	require.NoError(t, err)
+	assert.Equal(t, "4", cfg.Target)
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+)
+
+// NetworkConfig holds the settings of the network: section, used for remote
+// cache and self-update traffic. Proxies come from HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY
+type NetworkConfig struct {
+	// PEM bundle of extra CA certificates to trust, such as the root of a
+	// TLS-intercepting corporate proxy
+	CAFile string
+}
+
+// networkKeys are the keys allowed in the network: section
+var networkKeys = map[string]bool{
+	"ca_file": true,
+}
+
+// loadNetwork reads the network: section from v
+func loadNetwork(v *viper.Viper) NetworkConfig {
+	return NetworkConfig{CAFile: v.GetString("network.ca_file")}
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Network(t *testing.T) {
+	v := viper.New()
+	v.Set("compiler_path", "C:/SPlusCC.exe")
+	v.Set("target", "34")
+	v.Set("network", map[string]any{"ca_file": "certs/corp.pem"})
+
+	cfg, err := Load(v)
+	require.NoError(t, err)
+
+	abs, err := filepath.Abs("certs/corp.pem")
+	require.NoError(t, err)
+	assert.Equal(t, NetworkConfig{CAFile: abs}, cfg.Network)
+}
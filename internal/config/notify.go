@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// DefaultNotifyAfter is how long a build must take before it notifies
+const DefaultNotifyAfter = 10 * time.Second
+
+// NotifyConfig holds the settings of the notify: section, which shows a
+// desktop notification when a build or watch rebuild finishes
+type NotifyConfig struct {
+	// Show notifications (notify.enabled or --notify)
+	Enabled bool
+
+	// Builds quicker than this don't notify (notify.after, default 10s)
+	After time.Duration
+
+	// Which builds to notify about: always (default), failure or success
+	On string
+}
+
+// notifyKeys are the keys allowed in the notify: section
+var notifyKeys = map[string]bool{
+	"enabled": true,
+	"after":   true,
+	"on":      true,
+}
+
+// loadNotify reads the notify: section from v
+func loadNotify(v *viper.Viper) (NotifyConfig, error) {
+	n := NotifyConfig{
+		Enabled: v.GetBool("notify.enabled"),
+		After:   DefaultNotifyAfter,
+		On:      v.GetString("notify.on"),
+	}
+
+	if v.IsSet("notify.after") {
+		after, err := parseDelay(v.GetString("notify.after"))
+		if err != nil {
+			return n, fmt.Errorf("invalid notify.after: %w", err)
+		}
+
+		n.After = after
+	}
+
+	if n.On == "" {
+		n.On = WebhookAlways
+	}
+
+	if err := validateNotifyOn(n.On); err != nil {
+		return n, fmt.Errorf("invalid notify.on: %w", err)
+	}
+
+	return n, nil
+}
+
+// validateNotifyOn checks a notify.on value
+func validateNotifyOn(on string) error {
+	if !slices.Contains(webhookEvents, on) {
+		return fmt.Errorf("unknown on %q (expected always, failure or success)", on)
+	}
+
+	return nil
+}
+
+// Fires reports whether a build that failed or not and took elapsed is
+// notified about
+func (n NotifyConfig) Fires(failed bool, elapsed time.Duration) bool {
+	return n.Enabled && elapsed >= n.After && firesOn(n.On, failed)
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Notify(t *testing.T) {
+	v := viper.New()
+	v.Set("compiler_path", "C:/SPlusCC.exe")
+	v.Set("target", "34")
+
+	cfg, err := Load(v)
+	require.NoError(t, err)
+	assert.Equal(t, NotifyConfig{After: DefaultNotifyAfter, On: WebhookAlways}, cfg.Notify)
+
+	v.Set("notify", map[string]any{"enabled": true, "after": "1m", "on": "failure"})
+	cfg, err = Load(v)
+	require.NoError(t, err)
+	assert.Equal(t, NotifyConfig{Enabled: true, After: time.Minute, On: WebhookFailure}, cfg.Notify)
+
+	v.Set("notify", map[string]any{"on": "sometimes"})
+	_, err = Load(v)
+	assert.ErrorContains(t, err, "invalid notify.on")
+}
+
+func TestNotifyConfig_Fires(t *testing.T) {
+	n := NotifyConfig{Enabled: true, After: 10 * time.Second, On: WebhookFailure}
+
+	assert.True(t, n.Fires(true, time.Minute))
+	assert.False(t, n.Fires(false, time.Minute), "successes are not notified")
+	assert.False(t, n.Fires(true, time.Second), "quick builds are not notified")
+
+	n.Enabled = false
+	assert.False(t, n.Fires(true, time.Minute))
+}
+
+func TestValidateFile_Notify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".spc.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`notify:
+  enabled: yes please
+  after: soon
+  on: failure
+  sound: true
+`), 0o644))
+
+	problems, err := ValidateFile(path)
+	require.NoError(t, err)
+
+	var keys []string
+	for _, p := range problems {
+		keys = append(keys, p.Key)
+	}
+
+	assert.Equal(t, []string{"notify.after", "notify.enabled", "notify.sound"}, keys)
+}
+
+func TestSetValue_Notify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".spc.yml")
+
+	require.NoError(t, SetValue(path, "notify.enabled", "true"))
+	require.NoError(t, SetValue(path, "notify.after", "30s"))
+
+	value, err := GetValue(path, "notify.enabled")
+	require.NoError(t, err)
+	assert.Equal(t, true, value)
+
+	assert.ErrorContains(t, SetValue(path, "notify.on", "sometimes"), "invalid notify.on")
+	assert.ErrorContains(t, SetValue(path, "notify", "true"), "set notify.enabled")
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// Runners run the compiler (the runner: setting)
+const (
+	// RunnerLocal runs the compiler directly (the default)
+	RunnerLocal = "local"
+	// RunnerDocker runs the compiler inside the container image in docker:
+	RunnerDocker = "docker"
+)
+
+// Default container settings
+const (
+	DefaultDockerEngine        = "docker"
+	DefaultDockerWorkspace     = "C:/workspace"
+	DefaultWineDockerWorkspace = "/workspace"
+)
+
+// DockerConfig holds the settings of the docker: section, used when runner is docker
+type DockerConfig struct {
+	// Image containing the SIMPL+ compiler
+	Image string
+
+	// Container engine executable (docker, or a compatible one such as podman)
+	Engine string
+
+	// Directory the project is mounted at inside the container
+	Workspace string
+
+	// Path of SPlusCC.exe inside the container
+	CompilerPath string
+
+	// Run the compiler under wine (for Linux images)
+	Wine bool
+
+	// Extra arguments for "<engine> run"
+	Args []string
+}
+
+// dockerKeys are the keys allowed in the docker: section
+var dockerKeys = map[string]bool{
+	"image":         true,
+	"engine":        true,
+	"workspace":     true,
+	"compiler_path": true,
+	"wine":          true,
+	"args":          true,
+}
+
+// loadDocker reads the docker: section from v, applying defaults
+func loadDocker(v *viper.Viper) DockerConfig {
+	d := DockerConfig{
+		Image:        v.GetString("docker.image"),
+		Engine:       v.GetString("docker.engine"),
+		Workspace:    v.GetString("docker.workspace"),
+		CompilerPath: v.GetString("docker.compiler_path"),
+		Wine:         v.GetBool("docker.wine"),
+		Args:         v.GetStringSlice("docker.args"),
+	}
+
+	if d.Engine == "" {
+		d.Engine = DefaultDockerEngine
+	}
+
+	if d.Workspace == "" {
+		d.Workspace = DefaultDockerWorkspace
+		if d.Wine {
+			d.Workspace = DefaultWineDockerWorkspace
+		}
+	}
+
+	if d.CompilerPath == "" {
+		d.CompilerPath = DefaultCompilerPath
+	}
+
+	return d
+}
+
+// validateRunner checks the runner: setting names a known runner
+func validateRunner(runner string) error {
+	switch runner {
+	case "", RunnerLocal, RunnerDocker:
+		return nil
+	default:
+		return fmt.Errorf("unknown runner %q (expected %s or %s)", runner, RunnerLocal, RunnerDocker)
+	}
+}
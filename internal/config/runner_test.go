@@ -0,0 +1,62 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Runner(t *testing.T) {
+	newViper := func() *viper.Viper {
+		v := viper.New()
+		v.Set("compiler_path", "C:/SPlusCC.exe")
+		v.Set("target", "34")
+		return v
+	}
+
+	t.Run("local by default", func(t *testing.T) {
+		cfg, err := Load(newViper())
+		require.NoError(t, err)
+		assert.Empty(t, cfg.Runner)
+		assert.Equal(t, DockerConfig{Engine: "docker", Workspace: "C:/workspace", CompilerPath: DefaultCompilerPath}, cfg.Docker)
+	})
+
+	t.Run("reads the docker section", func(t *testing.T) {
+		v := newViper()
+		v.Set("runner", "docker")
+		v.Set("docker", map[string]any{
+			"image":  "example/simpl:latest",
+			"engine": "podman",
+			"wine":   true,
+			"args":   []string{"--network", "none"},
+		})
+
+		cfg, err := Load(v)
+		require.NoError(t, err)
+		assert.Equal(t, RunnerDocker, cfg.Runner)
+		assert.Equal(t, DockerConfig{
+			Image:        "example/simpl:latest",
+			Engine:       "podman",
+			Workspace:    "/workspace",
+			CompilerPath: DefaultCompilerPath,
+			Wine:         true,
+			Args:         []string{"--network", "none"},
+		}, cfg.Docker)
+	})
+
+	t.Run("docker requires an image", func(t *testing.T) {
+		v := newViper()
+		v.Set("runner", "docker")
+		_, err := Load(v)
+		assert.ErrorContains(t, err, "requires docker.image")
+	})
+
+	t.Run("unknown runner", func(t *testing.T) {
+		v := newViper()
+		v.Set("runner", "ssh")
+		_, err := Load(v)
+		assert.ErrorContains(t, err, "unknown runner")
+	})
+}
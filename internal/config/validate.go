@@ -0,0 +1,527 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/lint"
+	"github.com/spf13/viper"
+)
+
+// Problem is an issue found while validating a config file
+type Problem struct {
+	// File is the config file the problem came from
+	File string
+
+	// Key is the (dotted) setting the problem relates to
+	Key string
+
+	// Message describes the problem
+	Message string
+}
+
+// String formats the problem as "file: key: message"
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.File, p.Key, p.Message)
+}
+
+// settingKeys are the keys allowed at the top level of a config file and inside profiles
+var settingKeys = map[string]bool{
+	"compiler_path":   true,
+	"compiler_paths":  true,
+	"target":          true,
+	"target_aliases":  true,
+	"usersplusfolder": true,
+	"out":             true,
+	"out_dir":         true,
+	"install":         true,
+	"install_dir":     true,
+	"silent":          true,
+	"verbose":         true,
+	"quiet":           true,
+	"no_cache":        true,
+	"keep_going":      true,
+	"depfile":         true,
+	"sandbox":         true,
+	"parallel_series": true,
+	"split_work":      true,
+	"files":           true,
+	"include":         true,
+	"exclude":         true,
+	"extra_args":      true,
+	"cache":           true,
+	"runner":          true,
+	"docker":          true,
+	"publish":         true,
+	"network":         true,
+	"hooks":           true,
+	"lint":            true,
+	"notify":          true,
+	"watch":           true,
+}
+
+// overrideKeys are the keys allowed in an entry of the overrides list
+var overrideKeys = map[string]bool{
+	"pattern":         true,
+	"target":          true,
+	"usersplusfolder": true,
+	"extra_args":      true,
+}
+
+// ValidateFile reads a config file and reports unknown keys, invalid targets
+// and compiler/user folder paths that do not exist
+func ValidateFile(path string) ([]Problem, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	val := &validator{file: path, aliases: v.GetStringMapString("target_aliases")}
+	val.settings("", v.AllSettings(), true)
+
+	return val.problems, nil
+}
+
+// validator accumulates the problems found in a single file
+type validator struct {
+	file     string
+	problems []Problem
+
+	// aliases are the file's target_aliases, which its targets may use
+	aliases map[string]string
+}
+
+func (val *validator) add(key, format string, args ...any) {
+	val.problems = append(val.problems, Problem{File: val.file, Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// settings validates a map of settings. Sections (profiles, overrides) are
+// only allowed at the top level
+func (val *validator) settings(prefix string, settings map[string]any, topLevel bool) {
+	for _, key := range sortedKeys(settings) {
+		value := settings[key]
+		name := prefix + key
+
+		switch {
+		case topLevel && key == "profiles":
+			val.profiles(value)
+		case topLevel && key == "overrides":
+			val.overrides(value)
+		case topLevel && key == "webhooks":
+			val.webhooks(value)
+		case !settingKeys[key]:
+			val.add(name, "unknown key")
+		default:
+			val.setting(name, key, value)
+		}
+	}
+}
+
+// setting validates the value of a single known setting
+func (val *validator) setting(name, key string, value any) {
+	switch key {
+	case "target":
+		if target := fmt.Sprint(value); !isValidTarget(target, val.aliases) {
+			val.add(name, "invalid target series: %s", target)
+		}
+	case "target_aliases":
+		aliases, ok := value.(map[string]any)
+		if !ok {
+			val.add(name, "must be a map of names to targets")
+			return
+		}
+
+		for _, alias := range sortedKeys(aliases) {
+			if err := validateTargetAlias(alias, fmt.Sprint(aliases[alias])); err != nil {
+				val.add(name+"."+alias, "%v", err)
+			}
+		}
+	case "compiler_path":
+		val.path(name, fmt.Sprint(value), false)
+	case "compiler_paths":
+		paths, ok := value.(map[string]any)
+		if !ok {
+			val.add(name, "must be a map of series to compiler paths")
+			return
+		}
+
+		for _, series := range sortedKeys(paths) {
+			if !isSeriesKey(series) {
+				val.add(name+"."+series, "unknown series (expected series2, series3 or series4)")
+				continue
+			}
+
+			val.path(name+"."+series, fmt.Sprint(paths[series]), false)
+		}
+	case "files", "include", "exclude":
+		if _, ok := value.([]any); !ok {
+			val.add(name, "must be a list of paths or glob patterns")
+		}
+	case "extra_args":
+		if _, ok := value.([]any); !ok {
+			val.add(name, "must be a list of compiler arguments")
+		}
+	case "cache":
+		val.cache(name, value)
+	case "runner":
+		if err := validateRunner(fmt.Sprint(value)); err != nil {
+			val.add(name, "%v", err)
+		}
+	case "docker":
+		val.docker(name, value)
+	case "notify":
+		val.notify(name, value)
+	case "watch":
+		settings, ok := value.(map[string]any)
+		if !ok {
+			val.add(name, "must be a map of watch settings")
+			return
+		}
+
+		for _, key := range sortedKeys(settings) {
+			if !watchKeys[key] {
+				val.add(name+"."+key, "unknown key")
+				continue
+			}
+
+			if _, err := parseDelay(fmt.Sprint(settings[key])); err != nil {
+				val.add(name+"."+key, "%v", err)
+			}
+		}
+	case "publish":
+		settings, ok := value.(map[string]any)
+		if !ok {
+			val.add(name, "must be a map of publish settings")
+			return
+		}
+
+		for _, key := range sortedKeys(settings) {
+			if !publishKeys[key] {
+				val.add(name+"."+key, "unknown key")
+			}
+		}
+	case "network":
+		settings, ok := value.(map[string]any)
+		if !ok {
+			val.add(name, "must be a map of network settings")
+			return
+		}
+
+		for _, key := range sortedKeys(settings) {
+			switch {
+			case !networkKeys[key]:
+				val.add(name+"."+key, "unknown key")
+			case key == "ca_file":
+				val.path(name+"."+key, fmt.Sprint(settings[key]), false)
+			}
+		}
+	case "hooks":
+		settings, ok := value.(map[string]any)
+		if !ok {
+			val.add(name, "must be a map of hook commands")
+			return
+		}
+
+		for _, key := range sortedKeys(settings) {
+			if !hookKeys[key] {
+				val.add(name+"."+key, "unknown key")
+				continue
+			}
+
+			switch settings[key].(type) {
+			case string, []any:
+			default:
+				val.add(name+"."+key, "must be a command or a list of commands")
+			}
+		}
+	case "lint":
+		rules, ok := value.(map[string]any)
+		if !ok {
+			val.add(name, "must be a map of lint rules to severities")
+			return
+		}
+
+		for _, id := range sortedKeys(rules) {
+			if !isLintRule(id) {
+				val.add(name+"."+id, "unknown lint rule")
+				continue
+			}
+
+			if _, err := lint.ParseSeverity(fmt.Sprint(rules[id])); err != nil {
+				val.add(name+"."+id, "%v", err)
+			}
+		}
+	case "usersplusfolder":
+		folders, ok := value.([]any)
+		if !ok {
+			val.add(name, "must be a list of folders")
+			return
+		}
+
+		for _, folder := range folders {
+			val.path(name, fmt.Sprint(folder), true)
+		}
+	}
+}
+
+// path reports a path that does not exist (or is not of the expected type)
+func (val *validator) path(name, path string, wantDir bool) {
+	if path == "" {
+		return
+	}
+
+	abs, err := filepath.Abs(ExpandPath(path))
+	if err != nil {
+		val.add(name, "invalid path %s: %v", path, err)
+		return
+	}
+
+	info, err := os.Stat(abs)
+	switch {
+	case err != nil:
+		val.add(name, "path does not exist: %s", path)
+	case wantDir && !info.IsDir():
+		val.add(name, "not a directory: %s", path)
+	case !wantDir && info.IsDir():
+		val.add(name, "is a directory, expected a file: %s", path)
+	}
+}
+
+// cache validates the cache: section
+func (val *validator) cache(name string, value any) {
+	settings, ok := value.(map[string]any)
+	if !ok {
+		val.add(name, "must be a map of cache settings")
+		return
+	}
+
+	for _, key := range sortedKeys(settings) {
+		value, field := settings[key], name+"."+key
+
+		switch {
+		case !cacheKeys[key]:
+			val.add(field, "unknown key")
+		case key == "enabled" || key == "read_only":
+			if _, ok := value.(bool); !ok {
+				val.add(field, "must be true or false")
+			}
+		case key == "max_size":
+			if _, err := ParseSize(fmt.Sprint(value)); err != nil {
+				val.add(field, "%v", err)
+			}
+		case key == "ttl":
+			if _, err := ParseTTL(fmt.Sprint(value)); err != nil {
+				val.add(field, "%v", err)
+			}
+		case key == "remote":
+			val.remoteCache(field, value)
+		case key == "hash":
+			if err := validateHash(fmt.Sprint(value)); err != nil {
+				val.add(field, "%v", err)
+			}
+		case key == "lock_retries":
+			if _, err := parseRetries(fmt.Sprint(value)); err != nil {
+				val.add(field, "%v", err)
+			}
+		case key == "lock_delay":
+			if _, err := parseDelay(fmt.Sprint(value)); err != nil {
+				val.add(field, "%v", err)
+			}
+		}
+	}
+}
+
+// remoteCache validates the cache.remote: section
+func (val *validator) remoteCache(name string, value any) {
+	settings, ok := value.(map[string]any)
+	if !ok {
+		val.add(name, "must be a map of remote cache settings")
+		return
+	}
+
+	for _, key := range sortedKeys(settings) {
+		value, field := settings[key], name+"."+key
+
+		switch {
+		case !remoteCacheKeys[key]:
+			val.add(field, "unknown key")
+		case key == "type":
+			if err := validateRemoteType(fmt.Sprint(value)); err != nil {
+				val.add(field, "%v", err)
+			}
+		case key == "url" || key == "token_url":
+			if err := validateRemoteURL(fmt.Sprint(value)); err != nil {
+				val.add(field, "%v", err)
+			}
+		case key == "auth":
+			if err := validateRemoteAuth(fmt.Sprint(value)); err != nil {
+				val.add(field, "%v", err)
+			}
+		case key == "read_only" || key == "encrypt":
+			if _, ok := value.(bool); !ok {
+				val.add(field, "must be true or false")
+			}
+		}
+	}
+}
+
+// docker validates the docker: section
+func (val *validator) docker(name string, value any) {
+	settings, ok := value.(map[string]any)
+	if !ok {
+		val.add(name, "must be a map of container settings")
+		return
+	}
+
+	for _, key := range sortedKeys(settings) {
+		value, field := settings[key], name+"."+key
+
+		switch {
+		case !dockerKeys[key]:
+			val.add(field, "unknown key")
+		case key == "wine":
+			if _, ok := value.(bool); !ok {
+				val.add(field, "must be true or false")
+			}
+		case key == "args":
+			if _, ok := value.([]any); !ok {
+				val.add(field, "must be a list of arguments")
+			}
+		}
+	}
+}
+
+// notify validates the notify: section
+func (val *validator) notify(name string, value any) {
+	settings, ok := value.(map[string]any)
+	if !ok {
+		val.add(name, "must be a map of notification settings")
+		return
+	}
+
+	for _, key := range sortedKeys(settings) {
+		value, field := settings[key], name+"."+key
+
+		switch {
+		case !notifyKeys[key]:
+			val.add(field, "unknown key")
+		case key == "enabled":
+			if _, ok := value.(bool); !ok {
+				val.add(field, "must be true or false")
+			}
+		case key == "after":
+			if _, err := parseDelay(fmt.Sprint(value)); err != nil {
+				val.add(field, "%v", err)
+			}
+		case key == "on":
+			if err := validateNotifyOn(fmt.Sprint(value)); err != nil {
+				val.add(field, "%v", err)
+			}
+		}
+	}
+}
+
+func (val *validator) profiles(value any) {
+	profiles, ok := value.(map[string]any)
+	if !ok {
+		val.add("profiles", "must be a map of profile names to settings")
+		return
+	}
+
+	for _, name := range sortedKeys(profiles) {
+		settings, ok := profiles[name].(map[string]any)
+		if !ok {
+			val.add("profiles."+name, "must be a map of settings")
+			continue
+		}
+
+		val.settings("profiles."+name+".", settings, false)
+	}
+}
+
+func (val *validator) overrides(value any) {
+	overrides, ok := value.([]any)
+	if !ok {
+		val.add("overrides", "must be a list")
+		return
+	}
+
+	for i, raw := range overrides {
+		name := fmt.Sprintf("overrides[%d]", i)
+
+		override, ok := raw.(map[string]any)
+		if !ok {
+			val.add(name, "must be a map of settings")
+			continue
+		}
+
+		if pattern, _ := override["pattern"].(string); pattern == "" {
+			val.add(name, "missing pattern")
+		}
+
+		for _, key := range sortedKeys(override) {
+			if !overrideKeys[key] {
+				val.add(name+"."+key, "unknown key")
+				continue
+			}
+
+			val.setting(name+"."+key, key, override[key])
+		}
+	}
+}
+
+func (val *validator) webhooks(value any) {
+	webhooks, ok := value.([]any)
+	if !ok {
+		val.add("webhooks", "must be a list")
+		return
+	}
+
+	for i, raw := range webhooks {
+		name := fmt.Sprintf("webhooks[%d]", i)
+
+		settings, ok := raw.(map[string]any)
+		if !ok {
+			val.add(name, "must be a map of settings")
+			continue
+		}
+
+		for _, key := range sortedKeys(settings) {
+			if !webhookKeys[key] {
+				val.add(name+"."+key, "unknown key")
+			}
+		}
+
+		// URLs using environment variables are checked when loaded, as the
+		// variables may only be set where builds run
+		w := Webhook{URL: "https://example.com"}
+		if url, _ := settings["url"].(string); url == "" {
+			val.add(name, "missing url")
+		} else if !strings.Contains(url, "$") {
+			w.URL = url
+		}
+
+		w.Format, _ = settings["format"].(string)
+		w.On, _ = settings["on"].(string)
+
+		if err := w.resolve(); err != nil {
+			val.add(name, "%v", err)
+		}
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
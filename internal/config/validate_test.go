@@ -0,0 +1,122 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateFile(t *testing.T) {
+	dir := t.TempDir()
+	compiler := filepath.Join(dir, "SPlusCC.exe")
+	require.NoError(t, os.WriteFile(compiler, []byte("x"), 0o644))
+
+	write := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), ".spc.yml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	t.Run("valid config has no problems", func(t *testing.T) {
+		path := write(t, `compiler_path: "`+filepath.ToSlash(compiler)+`"
+target: "34"
+usersplusfolder:
+  - "`+filepath.ToSlash(dir)+`"
+profiles:
+  release:
+    target: "234"
+extra_args:
+  - "/someflag"
+target_aliases:
+  legacy: "2"
+overrides:
+  - pattern: "legacy/**"
+    target: "legacy"
+    extra_args: ["/legacy"]
+cache:
+  enabled: true
+  dir: ".spc-cache"
+  max_size: "2GB"
+  ttl: "30d"
+  remote:
+    url: "https://cache.example.com/spc"
+    read_only: true
+network:
+  ca_file: "`+filepath.ToSlash(compiler)+`"`)
+
+		problems, err := ValidateFile(path)
+		require.NoError(t, err)
+		assert.Empty(t, problems)
+	})
+
+	t.Run("reports every problem with its file and key", func(t *testing.T) {
+		path := write(t, `compiler_path: "`+filepath.ToSlash(filepath.Join(dir, "missing.exe"))+`"
+target: "9"
+tagret: "3"
+target_aliases:
+  s4: "4"
+compiler_paths:
+  series2: "`+filepath.ToSlash(compiler)+`"
+  series5: "`+filepath.ToSlash(compiler)+`"
+usersplusfolder:
+  - "`+filepath.ToSlash(compiler)+`"
+profiles:
+  release:
+    target: "7"
+    colour: true
+overrides:
+  - target: "2"
+    args: "/x"
+cache:
+  enabled: "sometimes"
+  max_size: "lots"
+  ttl: "forever"
+  size: 3
+  remote:
+    type: "s3"
+    url: "ftp://cache.example.com"
+    auth: "password"
+network:
+  ca_file: "`+filepath.ToSlash(filepath.Join(dir, "missing.pem"))+`"
+  proxy: "http://proxy:3128"`)
+
+		problems, err := ValidateFile(path)
+		require.NoError(t, err)
+
+		var keys []string
+		for _, p := range problems {
+			assert.Equal(t, path, p.File)
+			keys = append(keys, p.Key)
+		}
+
+		assert.Equal(t, []string{
+			"cache.enabled",
+			"cache.max_size",
+			"cache.remote.auth",
+			"cache.remote.type",
+			"cache.remote.url",
+			"cache.size",
+			"cache.ttl",
+			"compiler_path",
+			"compiler_paths.series5",
+			"network.ca_file",
+			"network.proxy",
+			"overrides[0]",
+			"overrides[0].args",
+			"profiles.release.colour",
+			"profiles.release.target",
+			"tagret",
+			"target",
+			"target_aliases.s4",
+			"usersplusfolder",
+		}, keys)
+	})
+
+	t.Run("unreadable file is an error", func(t *testing.T) {
+		_, err := ValidateFile(filepath.Join(dir, "missing.yml"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// DefaultWatchDebounce is how long a watched project must be quiet before it
+// is rebuilt
+const DefaultWatchDebounce = 300 * time.Millisecond
+
+// WatchConfig holds the settings of the watch: section, which control when
+// spc daemon rebuilds a watched project after its sources are saved
+type WatchConfig struct {
+	// Time without changes before a rebuild starts (default 300ms)
+	Debounce time.Duration
+
+	// Time the changed files' size and modification time must stay the same
+	// before they are built, for editors and network shares that write in
+	// several steps (0 to build straight away)
+	Settle time.Duration
+
+	// Time from the first change that further changes are collected into the
+	// same rebuild, even when they are further apart than the debounce (0 for
+	// the debounce alone)
+	Batch time.Duration
+}
+
+// watchKeys are the keys allowed in the watch: section
+var watchKeys = map[string]bool{
+	"debounce": true,
+	"settle":   true,
+	"batch":    true,
+}
+
+// loadWatch reads the watch: section from v
+func loadWatch(v *viper.Viper) (WatchConfig, error) {
+	w := WatchConfig{Debounce: DefaultWatchDebounce}
+
+	for key, d := range map[string]*time.Duration{"debounce": &w.Debounce, "settle": &w.Settle, "batch": &w.Batch} {
+		if !v.IsSet("watch." + key) {
+			continue
+		}
+
+		var err error
+		if *d, err = parseDelay(v.GetString("watch." + key)); err != nil {
+			return w, fmt.Errorf("invalid watch.%s: %w", key, err)
+		}
+	}
+
+	return w, nil
+}
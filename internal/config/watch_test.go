@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Watch(t *testing.T) {
+	v := viper.New()
+	v.Set("compiler_path", "C:/SPlusCC.exe")
+	v.Set("target", "34")
+
+	cfg, err := Load(v)
+	require.NoError(t, err)
+	assert.Equal(t, WatchConfig{Debounce: DefaultWatchDebounce}, cfg.Watch)
+
+	v.Set("watch", map[string]any{"debounce": "1s", "settle": "200ms", "batch": "5s"})
+	cfg, err = Load(v)
+	require.NoError(t, err)
+	assert.Equal(t, WatchConfig{Debounce: time.Second, Settle: 200 * time.Millisecond, Batch: 5 * time.Second}, cfg.Watch)
+
+	v.Set("watch", map[string]any{"batch": "soon"})
+	_, err = Load(v)
+	assert.ErrorContains(t, err, "invalid watch.batch")
+}
+
+func TestValidateFile_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".spc.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`watch:
+  debounce: 500ms
+  settle: -1s
+  delay: 1s
+`), 0o644))
+
+	problems, err := ValidateFile(path)
+	require.NoError(t, err)
+
+	var keys []string
+	for _, p := range problems {
+		keys = append(keys, p.Key)
+	}
+
+	assert.Equal(t, []string{"watch.delay", "watch.settle"}, keys)
+}
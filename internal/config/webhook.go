@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+)
+
+// Webhook payload formats
+const (
+	WebhookJSON  = "json"
+	WebhookSlack = "slack"
+	WebhookTeams = "teams"
+)
+
+// Builds a webhook fires for
+const (
+	WebhookAlways  = "always"
+	WebhookFailure = "failure"
+	WebhookSuccess = "success"
+)
+
+// Webhook is a URL notified when a build finishes (an entry of webhooks:)
+type Webhook struct {
+	// URL the notification is POSTed to. Environment variables are expanded,
+	// so secret URLs can be kept out of the config file
+	URL string `mapstructure:"url"`
+
+	// Payload format: json (default), slack or teams
+	Format string `mapstructure:"format"`
+
+	// Which builds to notify about: always (default), failure or success
+	On string `mapstructure:"on"`
+}
+
+// webhookKeys are the keys allowed in an entry of the webhooks list
+var webhookKeys = map[string]bool{
+	"url":    true,
+	"format": true,
+	"on":     true,
+}
+
+var (
+	webhookFormats = []string{WebhookJSON, WebhookSlack, WebhookTeams}
+	webhookEvents  = []string{WebhookAlways, WebhookFailure, WebhookSuccess}
+)
+
+// resolve applies defaults and expands the URL, then validates the webhook
+func (w *Webhook) resolve() error {
+	w.URL = os.ExpandEnv(w.URL)
+
+	if w.Format == "" {
+		w.Format = WebhookJSON
+	}
+
+	if w.On == "" {
+		w.On = WebhookAlways
+	}
+
+	return w.validate()
+}
+
+// validate checks the webhook's URL, format and event
+func (w *Webhook) validate() error {
+	if w.URL == "" {
+		return fmt.Errorf("missing url")
+	}
+
+	if err := validateRemoteURL(w.URL); err != nil {
+		return err
+	}
+
+	if !slices.Contains(webhookFormats, w.Format) {
+		return fmt.Errorf("unknown format %q (expected json, slack or teams)", w.Format)
+	}
+
+	if !slices.Contains(webhookEvents, w.On) {
+		return fmt.Errorf("unknown on %q (expected always, failure or success)", w.On)
+	}
+
+	return nil
+}
+
+// Fires reports whether the webhook is notified about a build that failed or not
+func (w *Webhook) Fires(failed bool) bool {
+	return firesOn(w.On, failed)
+}
+
+// firesOn reports whether a build that failed or not is one of the builds
+// named by on (always, failure or success)
+func firesOn(on string, failed bool) bool {
+	switch on {
+	case WebhookFailure:
+		return failed
+	case WebhookSuccess:
+		return !failed
+	default:
+		return true
+	}
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Webhooks(t *testing.T) {
+	t.Setenv("SLACK_WEBHOOK", "https://hooks.slack.com/services/T/B/X")
+
+	v := viper.New()
+	v.Set("compiler_path", "C:/SPlusCC.exe")
+	v.Set("webhooks", []any{
+		map[string]any{"url": "${SLACK_WEBHOOK}", "format": "slack", "on": "failure"},
+		map[string]any{"url": "https://example.com/builds"},
+	})
+
+	cfg, err := Load(v)
+	require.NoError(t, err)
+	assert.Equal(t, []Webhook{
+		{URL: "https://hooks.slack.com/services/T/B/X", Format: WebhookSlack, On: WebhookFailure},
+		{URL: "https://example.com/builds", Format: WebhookJSON, On: WebhookAlways},
+	}, cfg.Webhooks)
+
+	assert.True(t, cfg.Webhooks[0].Fires(true))
+	assert.False(t, cfg.Webhooks[0].Fires(false))
+
+	v.Set("webhooks", []any{map[string]any{"url": "https://example.com", "format": "discord"}})
+	_, err = Load(v)
+	assert.ErrorContains(t, err, "unknown format")
+}
+
+func TestValidateFile_Webhooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".spc.yml")
+	content := `webhooks:
+  - url: "${SLACK_WEBHOOK}"
+    format: slack
+  - format: json
+    channel: builds
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	problems, err := ValidateFile(path)
+	require.NoError(t, err)
+
+	var keys []string
+	for _, p := range problems {
+		keys = append(keys, p.Key)
+	}
+
+	assert.ElementsMatch(t, []string{"webhooks[1]", "webhooks[1].channel"}, keys)
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// WorkspaceFile lists the project roots of a multi-project workspace
+const WorkspaceFile = "spc.workspace.yml"
+
+// Workspace is a set of independent projects built together, each with its
+// own local config
+type Workspace struct {
+	// Path is the workspace file
+	Path string
+
+	// Dir is the directory of the workspace file, which project paths are
+	// relative to
+	Dir string
+
+	// Projects are the absolute project directories, in the order listed
+	Projects []string
+}
+
+// workspaceFile is the layout of spc.workspace.yml
+type workspaceFile struct {
+	Projects []string `yaml:"projects"`
+}
+
+// FindWorkspace finds the workspace file by walking up from dir. Returns an
+// empty string if there is none
+func FindWorkspace(dir string) string {
+	for {
+		path := filepath.Join(dir, WorkspaceFile)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+
+		dir = parent
+	}
+}
+
+// LoadWorkspace reads a workspace file. Project entries may be glob patterns
+// ("modules/*"), which match directories only
+func LoadWorkspace(path string) (*Workspace, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace: %w", err)
+	}
+
+	var file workspaceFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", abs, err)
+	}
+
+	ws := &Workspace{Path: abs, Dir: filepath.Dir(abs)}
+	seen := make(map[string]bool)
+
+	for _, entry := range file.Projects {
+		dirs, err := ws.expand(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, dir := range dirs {
+			if !seen[dir] {
+				seen[dir] = true
+				ws.Projects = append(ws.Projects, dir)
+			}
+		}
+	}
+
+	if len(ws.Projects) == 0 {
+		return nil, fmt.Errorf("%s lists no projects", abs)
+	}
+
+	return ws, nil
+}
+
+// expand resolves a projects: entry to the directories it names
+func (ws *Workspace) expand(entry string) ([]string, error) {
+	pattern := ExpandPath(entry)
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(ws.Dir, pattern)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid workspace project %q: %w", entry, err)
+	}
+
+	var dirs []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.IsDir() {
+			dirs = append(dirs, filepath.Clean(match))
+		}
+	}
+
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("workspace project %q does not match a directory", entry)
+	}
+
+	sort.Strings(dirs)
+
+	return dirs, nil
+}
+
+// Name returns a project directory relative to the workspace, for reports
+func (ws *Workspace) Name(dir string) string {
+	if rel, err := filepath.Rel(ws.Dir, dir); err == nil {
+		return filepath.ToSlash(rel)
+	}
+
+	return dir
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	for _, project := range []string{"modules/lighting", "modules/audio", "tools"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, project), 0o755))
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "modules", "README.md"), nil, 0o644))
+
+	path := filepath.Join(dir, WorkspaceFile)
+	require.NoError(t, os.WriteFile(path, []byte(`projects:
+  - tools
+  - modules/*
+  - modules/audio
+`), 0o644))
+
+	ws, err := LoadWorkspace(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, dir, ws.Dir)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "tools"),
+		filepath.Join(dir, "modules", "audio"),
+		filepath.Join(dir, "modules", "lighting"),
+	}, ws.Projects)
+	assert.Equal(t, "modules/audio", ws.Name(ws.Projects[1]))
+}
+
+func TestLoadWorkspace_Errors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, WorkspaceFile)
+
+	require.NoError(t, os.WriteFile(path, []byte("projects: []\n"), 0o644))
+	_, err := LoadWorkspace(path)
+	assert.ErrorContains(t, err, "lists no projects")
+
+	require.NoError(t, os.WriteFile(path, []byte("projects:\n  - missing\n"), 0o644))
+	_, err = LoadWorkspace(path)
+	assert.ErrorContains(t, err, `"missing" does not match a directory`)
+
+	require.NoError(t, os.WriteFile(path, []byte("projects: {\n"), 0o644))
+	_, err = LoadWorkspace(path)
+	assert.ErrorContains(t, err, "failed to parse")
+}
+
+func TestFindWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "modules", "audio")
+	require.NoError(t, os.MkdirAll(sub, 0o755))
+
+	assert.Empty(t, FindWorkspace(sub))
+
+	path := filepath.Join(dir, WorkspaceFile)
+	require.NoError(t, os.WriteFile(path, []byte("projects: [modules/audio]\n"), 0o644))
+
+	assert.Equal(t, path, FindWorkspace(sub))
+}
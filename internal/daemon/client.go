@@ -0,0 +1,59 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"time"
+)
+
+// Client talks to a running daemon
+type Client struct {
+	rpc *rpc.Client
+}
+
+// Dial connects to the daemon listening on socket
+func Dial(socket string) (*Client, error) {
+	conn, err := net.DialTimeout("unix", socket, 2*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("no daemon running on %s: %w", socket, err)
+	}
+
+	return &Client{rpc: jsonrpc.NewClient(conn)}, nil
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+// Build asks the daemon to build a project
+func (c *Client) Build(req BuildRequest) (*BuildReply, error) {
+	var reply BuildReply
+	if err := c.rpc.Call(ServiceName+".Build", req, &reply); err != nil {
+		return nil, err
+	}
+
+	return &reply, nil
+}
+
+// Status returns the daemon's status
+func (c *Client) Status() (*StatusReply, error) {
+	var reply StatusReply
+	if err := c.rpc.Call(ServiceName+".Status", StatusRequest{}, &reply); err != nil {
+		return nil, err
+	}
+
+	return &reply, nil
+}
+
+// CacheStats returns the daemon's cache statistics
+func (c *Client) CacheStats() (*CacheStatsReply, error) {
+	var reply CacheStatsReply
+	if err := c.rpc.Call(ServiceName+".CacheStats", CacheStatsRequest{}, &reply); err != nil {
+		return nil, err
+	}
+
+	return &reply, nil
+}
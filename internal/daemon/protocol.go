@@ -0,0 +1,94 @@
+// Package daemon implements spc daemon: a long-running build server that
+// keeps the cache database open, rebuilds watched projects as their sources
+// change and answers Build, Status and CacheStats requests from editors and
+// build orchestrators over a local socket.
+//
+// The protocol is JSON-RPC 1.0 (as implemented by net/rpc/jsonrpc) over a Unix
+// domain socket, so any language can talk to the daemon without generated
+// stubs. Methods are named "Daemon.Build", "Daemon.Status" and
+// "Daemon.CacheStats".
+package daemon
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// ServiceName is the name the daemon's methods are registered under
+const ServiceName = "Daemon"
+
+// SocketName is the file name of the daemon socket within the cache directory
+const SocketName = "daemon.sock"
+
+// DefaultSocket returns the socket path for a daemon serving cacheDir
+func DefaultSocket(cacheDir string) string {
+	return filepath.Join(cacheDir, SocketName)
+}
+
+// BuildRequest asks the daemon to build a project
+type BuildRequest struct {
+	// Dir is the project directory; its .spc.yml (or a parent's) is used
+	Dir string `json:"dir"`
+
+	// Profile is the config profile to apply (empty for none)
+	Profile string `json:"profile,omitempty"`
+
+	// Files are the files, directories or glob patterns to build, relative
+	// to Dir. Empty builds the project's files: list (or all of Dir)
+	Files []string `json:"files,omitempty"`
+
+	// KeepGoing builds every file instead of stopping at the first failure
+	KeepGoing bool `json:"keep_going,omitempty"`
+}
+
+// FileResult is the outcome of building one source file
+type FileResult struct {
+	File     string        `json:"file"`
+	Status   string        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// BuildReply is the result of a build
+type BuildReply struct {
+	Results []FileResult `json:"results"`
+
+	// Failed is the number of files that failed to compile
+	Failed int `json:"failed"`
+
+	// Output is the compiler's console output
+	Output string `json:"output,omitempty"`
+}
+
+// StatusRequest asks for the daemon's status
+type StatusRequest struct{}
+
+// ProjectStatus describes a project the daemon has built or is watching
+type ProjectStatus struct {
+	Dir       string    `json:"dir"`
+	Watching  bool      `json:"watching"`
+	Building  bool      `json:"building"`
+	LastBuild time.Time `json:"last_build,omitzero"`
+
+	// Results are the results of the last build
+	Results []FileResult `json:"results,omitempty"`
+}
+
+// StatusReply describes the running daemon
+type StatusReply struct {
+	PID      int             `json:"pid"`
+	Started  time.Time       `json:"started"`
+	Socket   string          `json:"socket"`
+	CacheDir string          `json:"cache_dir"`
+	Projects []ProjectStatus `json:"projects"`
+}
+
+// CacheStatsRequest asks for cache statistics
+type CacheStatsRequest struct{}
+
+// CacheStatsReply reports the size of the daemon's cache
+type CacheStatsReply struct {
+	Dir     string `json:"dir"`
+	Entries int    `json:"entries"`
+	Size    int64  `json:"size"`
+}
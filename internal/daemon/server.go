@@ -0,0 +1,374 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/build"
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/deps"
+	"github.com/Norgate-AV/spc/internal/discovery"
+	"github.com/Norgate-AV/spc/internal/logger"
+)
+
+// Server is a running spc daemon
+type Server struct {
+	cache    *cache.Cache
+	cacheDir string
+	started  time.Time
+
+	// OnResult, if set, is called with the result of every file the daemon
+	// builds (set before serving)
+	OnResult func(build.Result)
+
+	// Notify shows desktop notifications for watch rebuilds of every
+	// project, not only those whose config enables notify (set before
+	// serving)
+	Notify bool
+
+	// ctx is cancelled by Close, stopping the build in progress
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// buildMu allows one build at a time: compiler runs share SPlsWork
+	// directories and the cache
+	buildMu sync.Mutex
+
+	mu       sync.Mutex
+	projects map[string]*project
+	watcher  *watcher
+	listener net.Listener
+}
+
+// project is the state of a project the daemon has built or is watching
+type project struct {
+	dir       string
+	profile   string
+	watching  bool
+	watch     config.WatchConfig
+	building  bool
+	lastBuild time.Time
+	results   []FileResult
+
+	// userFolders are the project's user SIMPL+ folders, watched for
+	// changes to the libraries in them
+	userFolders []string
+}
+
+// NewServer creates a daemon serving builds with the cache in cacheDir. The
+// server owns the cache and closes it in Close
+func NewServer(c *cache.Cache, cacheDir string) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Server{
+		cache:    c,
+		cacheDir: cacheDir,
+		started:  time.Now(),
+		ctx:      ctx,
+		cancel:   cancel,
+		projects: make(map[string]*project),
+	}
+}
+
+// Listen creates the daemon socket. A leftover socket from a daemon that is
+// no longer running is replaced; a live one is an error
+func Listen(socket string) (net.Listener, error) {
+	if _, err := os.Stat(socket); err == nil {
+		if conn, err := net.DialTimeout("unix", socket, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("a daemon is already running on %s", socket)
+		}
+
+		if err := os.Remove(socket); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", socket, err)
+	}
+
+	return l, nil
+}
+
+// Serve accepts connections on l until Close is called
+func (s *Server) Serve(l net.Listener) error {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(ServiceName, &service{s: s}); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			return err
+		}
+
+		go srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// Close stops serving and watching and closes the cache. A build in progress
+// is cancelled, stopping the compiler, and closing waits for it to return
+func (s *Server) Close() error {
+	s.cancel()
+
+	s.mu.Lock()
+	l, w := s.listener, s.watcher
+	s.listener, s.watcher = nil, nil
+	s.mu.Unlock()
+
+	if l != nil {
+		l.Close()
+	}
+
+	if w != nil {
+		w.close()
+	}
+
+	s.buildMu.Lock()
+	defer s.buildMu.Unlock()
+
+	return s.cache.Close()
+}
+
+// Build builds a project (or some of its files) and records the result.
+// Cancelling ctx, or closing the server, stops the build
+func (s *Server) Build(ctx context.Context, req BuildRequest) (*BuildReply, error) {
+	dir, err := filepath.Abs(req.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path for %s: %w", req.Dir, err)
+	}
+
+	cfg, err := config.NewLoader().LoadProject(dir, req.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := projectSources(cfg, dir, req.Files)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.build(ctx, s.project(dir, req.Profile), cfg, files, cfg.KeepGoing || req.KeepGoing), nil
+}
+
+// build compiles files for a project, one build at a time, and records the
+// results as the project's last build
+func (s *Server) build(ctx context.Context, p *project, cfg *config.Config, files []string, keepGoing bool) *BuildReply {
+	s.buildMu.Lock()
+	defer s.buildMu.Unlock()
+
+	s.setBuilding(p, true)
+	defer s.setBuilding(p, false)
+
+	cache.SetCompareAlgorithm(cfg.Cache.Hash)
+	cache.SetLockRetry(cfg.Cache.LockRetries, cfg.Cache.LockDelay)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stop := context.AfterFunc(s.ctx, cancel)
+	defer stop()
+
+	var output bytes.Buffer
+	session := build.NewSession(cfg, s.cache)
+	session.Stdout = &output
+	session.Stderr = &output
+
+	start := time.Now()
+	results := session.Build(ctx, files, keepGoing)
+
+	if len(results) > 0 {
+		if err := s.cache.RecordBuild(build.Record(cfg, results, start, time.Since(start))); err != nil {
+			logger.Warnf("Failed to record build history: %v", err)
+		}
+	}
+
+	if s.OnResult != nil {
+		for _, r := range results {
+			s.OnResult(r)
+		}
+	}
+
+	if removed, err := s.cache.Prune(cfg.Cache.MaxSize, cfg.Cache.TTL); err != nil {
+		logger.Warnf("Failed to prune cache: %v", err)
+	} else if removed > 0 {
+		logger.Infof("Evicted %d old cache entries", removed)
+	}
+
+	reply := &BuildReply{Results: []FileResult{}, Output: output.String()}
+	for _, r := range results {
+		result := FileResult{File: r.File, Status: r.Status.String(), Duration: r.Duration}
+		if r.Err != nil {
+			result.Error = r.Err.Error()
+			reply.Failed++
+		}
+
+		reply.Results = append(reply.Results, result)
+	}
+
+	s.mu.Lock()
+	p.lastBuild = time.Now()
+	p.results = reply.Results
+	s.mu.Unlock()
+
+	return reply
+}
+
+// Status describes the daemon and its projects
+func (s *Server) Status() *StatusReply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reply := &StatusReply{
+		PID:      os.Getpid(),
+		Started:  s.started,
+		CacheDir: s.cacheDir,
+		Projects: []ProjectStatus{},
+	}
+
+	if s.listener != nil {
+		reply.Socket = s.listener.Addr().String()
+	}
+
+	for _, p := range s.projects {
+		reply.Projects = append(reply.Projects, ProjectStatus{
+			Dir:       p.dir,
+			Watching:  p.watching,
+			Building:  p.building,
+			LastBuild: p.lastBuild,
+			Results:   p.results,
+		})
+	}
+
+	sort.Slice(reply.Projects, func(i, j int) bool { return reply.Projects[i].Dir < reply.Projects[j].Dir })
+
+	return reply
+}
+
+// CacheStats reports the size of the cache
+func (s *Server) CacheStats() (*CacheStatsReply, error) {
+	entries, size, err := s.cache.Stats()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CacheStatsReply{Dir: s.cacheDir, Entries: entries, Size: size}, nil
+}
+
+// project returns the state for dir, creating it on first use
+func (s *Server) project(dir, profile string) *project {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.projects[dir]
+	if !ok {
+		p = &project{dir: dir}
+		s.projects[dir] = p
+	}
+
+	p.profile = profile
+
+	return p
+}
+
+func (s *Server) setBuilding(p *project, building bool) {
+	s.mu.Lock()
+	p.building = building
+	s.mu.Unlock()
+}
+
+// projectSources resolves the files to build for a project: the given
+// arguments (relative to dir), the project's files: list, or all of dir
+func projectSources(cfg *config.Config, dir string, args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		if !filepath.IsAbs(arg) {
+			arg = filepath.Join(dir, arg)
+		}
+
+		files = append(files, arg)
+	}
+
+	if len(files) == 0 {
+		files = cfg.ProjectFiles()
+	}
+
+	if len(files) == 0 {
+		files = []string{dir}
+	}
+
+	filter, err := discovery.NewFilter(cfg.Include, cfg.Exclude, cfg.ProjectDir)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err = discovery.ExpandFiltered(files, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no SIMPL+ files found in %s", dir)
+	}
+
+	if ordered, err := deps.BuildOrder(files, &deps.Resolver{UserFolders: cfg.UserFolders}); err == nil {
+		files = ordered
+	}
+
+	return files, nil
+}
+
+// service exposes the server's methods over net/rpc
+type service struct {
+	s *Server
+}
+
+// Build builds a project
+func (v *service) Build(req BuildRequest, reply *BuildReply) error {
+	r, err := v.s.Build(v.s.ctx, req)
+	if err != nil {
+		return err
+	}
+
+	*reply = *r
+	return nil
+}
+
+// Status describes the daemon
+func (v *service) Status(_ StatusRequest, reply *StatusReply) error {
+	*reply = *v.s.Status()
+	return nil
+}
+
+// CacheStats reports the size of the cache
+func (v *service) CacheStats(_ CacheStatsRequest, reply *CacheStatsReply) error {
+	r, err := v.s.CacheStats()
+	if err != nil {
+		return err
+	}
+
+	*reply = *r
+	return nil
+}
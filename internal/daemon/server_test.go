@@ -0,0 +1,271 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/build"
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// startServer runs a daemon on a temporary socket and returns a client for it
+func startServer(t *testing.T) (*Server, *Client) {
+	t.Setenv("HOME", t.TempDir())
+
+	cacheDir := t.TempDir()
+	c, err := cache.New(cacheDir)
+	require.NoError(t, err)
+
+	s := NewServer(c, cacheDir)
+
+	l, err := Listen(DefaultSocket(cacheDir))
+	require.NoError(t, err)
+
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+
+	client, err := Dial(DefaultSocket(cacheDir))
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	return s, client
+}
+
+// writeProject creates a project whose compiler does not exist, so every
+// build fails quickly without needing SIMPL installed
+func writeProject(t *testing.T, sources ...string) string {
+	dir := t.TempDir()
+
+	cfg := "compiler_path: " + filepath.ToSlash(filepath.Join(dir, "missing", "SPlusCC.exe")) + "\nkeep_going: true\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".spc.yml"), []byte(cfg), 0o644))
+
+	for _, name := range sources {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("// "+name), 0o644))
+	}
+
+	return dir
+}
+
+func TestListen_AlreadyRunning(t *testing.T) {
+	dir := t.TempDir()
+	socket := DefaultSocket(dir)
+
+	l, err := Listen(socket)
+	require.NoError(t, err)
+	defer l.Close()
+
+	_, err = Listen(socket)
+	assert.ErrorContains(t, err, "already running")
+}
+
+func TestListen_StaleSocket(t *testing.T) {
+	socket := DefaultSocket(t.TempDir())
+	require.NoError(t, os.WriteFile(socket, nil, 0o600))
+
+	l, err := Listen(socket)
+	require.NoError(t, err)
+	l.Close()
+}
+
+func TestServer_StatusAndCacheStats(t *testing.T) {
+	_, client := startServer(t)
+
+	status, err := client.Status()
+	require.NoError(t, err)
+	assert.Equal(t, os.Getpid(), status.PID)
+	assert.NotEmpty(t, status.Socket)
+	assert.Empty(t, status.Projects)
+
+	stats, err := client.CacheStats()
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Entries)
+	assert.Equal(t, status.CacheDir, stats.Dir)
+}
+
+func TestServer_Build(t *testing.T) {
+	_, client := startServer(t)
+	dir := writeProject(t, "a.usp", "b.usl")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.usp"), []byte(`#USER_LIBRARY "b"`), 0o644))
+
+	reply, err := client.Build(BuildRequest{Dir: dir})
+	require.NoError(t, err)
+	require.Len(t, reply.Results, 2)
+	assert.Equal(t, 2, reply.Failed)
+	assert.Equal(t, "b.usl", filepath.Base(reply.Results[0].File), "libraries build first")
+	assert.Equal(t, "failed", reply.Results[0].Status)
+
+	reply, err = client.Build(BuildRequest{Dir: dir, Files: []string{"a.usp"}})
+	require.NoError(t, err)
+	require.Len(t, reply.Results, 1)
+
+	status, err := client.Status()
+	require.NoError(t, err)
+	require.Len(t, status.Projects, 1)
+	assert.False(t, status.Projects[0].LastBuild.IsZero())
+	assert.Len(t, status.Projects[0].Results, 1)
+
+	_, err = client.Build(BuildRequest{Dir: dir, Profile: "missing"})
+	assert.Error(t, err)
+}
+
+func TestServer_Build_SpcIgnore(t *testing.T) {
+	_, client := startServer(t)
+	dir := writeProject(t, "a.usp")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "templates"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "templates", "blank.usp"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".spcignore"), []byte("templates/\n"), 0o644))
+
+	reply, err := client.Build(BuildRequest{Dir: dir})
+	require.NoError(t, err)
+	require.Len(t, reply.Results, 1)
+	assert.Equal(t, "a.usp", filepath.Base(reply.Results[0].File))
+}
+
+func TestServer_Watch(t *testing.T) {
+	s, client := startServer(t)
+	dir := writeProject(t, "a.usp", "b.usp")
+
+	require.NoError(t, s.Watch(dir, ""))
+
+	status, err := client.Status()
+	require.NoError(t, err)
+	require.Len(t, status.Projects, 1)
+	assert.True(t, status.Projects[0].Watching)
+	assert.True(t, status.Projects[0].LastBuild.IsZero())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.usp"), []byte("// changed"), 0o644))
+
+	require.Eventually(t, func() bool {
+		status, err := client.Status()
+		return err == nil && len(status.Projects) == 1 && !status.Projects[0].LastBuild.IsZero()
+	}, 5*time.Second, 50*time.Millisecond)
+
+	status, err = client.Status()
+	require.NoError(t, err)
+	require.Len(t, status.Projects[0].Results, 1, "only the changed file is rebuilt")
+	assert.Equal(t, "b.usp", filepath.Base(status.Projects[0].Results[0].File))
+}
+
+func TestServer_Watch_OnResult(t *testing.T) {
+	s, _ := startServer(t)
+	dir := writeProject(t, "a.usp")
+
+	// Watch rebuilds are reported like requested builds, so --metrics
+	// counts them
+	results := make(chan build.Result, 1)
+	s.OnResult = func(r build.Result) {
+		select {
+		case results <- r:
+		default:
+		}
+	}
+
+	require.NoError(t, s.Watch(dir, ""))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.usp"), []byte("// changed"), 0o644))
+
+	select {
+	case r := <-results:
+		assert.Equal(t, "a.usp", filepath.Base(r.File))
+		assert.Equal(t, build.StatusFailed, r.Status)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watch rebuild was not reported")
+	}
+}
+
+func TestServer_Watch_Libraries(t *testing.T) {
+	s, client := startServer(t)
+	dir := writeProject(t, "b.usp")
+	folder := t.TempDir()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.usp"), []byte("#USER_LIBRARY \"shared\"\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(folder, "shared.usl"), []byte("// shared"), 0o644))
+
+	cfg, err := os.OpenFile(filepath.Join(dir, ".spc.yml"), os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = cfg.WriteString("usersplusfolder:\n  - " + filepath.ToSlash(folder) + "\n")
+	require.NoError(t, err)
+	require.NoError(t, cfg.Close())
+
+	require.NoError(t, s.Watch(dir, ""))
+
+	require.NoError(t, os.WriteFile(filepath.Join(folder, "shared.usl"), []byte("// changed"), 0o644))
+
+	require.Eventually(t, func() bool {
+		status, err := client.Status()
+		return err == nil && len(status.Projects) == 1 && !status.Projects[0].LastBuild.IsZero()
+	}, 5*time.Second, 50*time.Millisecond)
+
+	status, err := client.Status()
+	require.NoError(t, err)
+	require.Len(t, status.Projects[0].Results, 1, "only the module using the library is rebuilt")
+	assert.Equal(t, "a.usp", filepath.Base(status.Projects[0].Results[0].File))
+}
+
+func TestServer_Watch_Batch(t *testing.T) {
+	s, client := startServer(t)
+	dir := writeProject(t, "a.usp", "b.usp")
+
+	cfg, err := os.OpenFile(filepath.Join(dir, ".spc.yml"), os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = cfg.WriteString("watch:\n  debounce: 50ms\n  batch: 1s\n")
+	require.NoError(t, err)
+	require.NoError(t, cfg.Close())
+
+	require.NoError(t, s.Watch(dir, ""))
+
+	// Further apart than the debounce, but within the batch window
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.usp"), []byte("// changed"), 0o644))
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.usp"), []byte("// changed"), 0o644))
+
+	require.Eventually(t, func() bool {
+		status, err := client.Status()
+		return err == nil && len(status.Projects) == 1 && !status.Projects[0].LastBuild.IsZero()
+	}, 5*time.Second, 50*time.Millisecond)
+
+	status, err := client.Status()
+	require.NoError(t, err)
+	assert.Len(t, status.Projects[0].Results, 2, "both changes are built together")
+}
+
+func TestRebuildDelay(t *testing.T) {
+	first := time.Now()
+	settings := config.WatchConfig{Debounce: 300 * time.Millisecond, Batch: 2 * time.Second}
+
+	assert.Equal(t, 2*time.Second, rebuildDelay(settings, first, first))
+	assert.Equal(t, 500*time.Millisecond, rebuildDelay(settings, first, first.Add(1500*time.Millisecond)))
+	assert.Equal(t, 300*time.Millisecond, rebuildDelay(settings, first, first.Add(5*time.Second)))
+
+	settings.Batch = 0
+	assert.Equal(t, 300*time.Millisecond, rebuildDelay(settings, first, first))
+}
+
+func TestSettled(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.usp")
+	require.NoError(t, os.WriteFile(file, []byte("// a"), 0o644))
+
+	files := map[string]bool{file: true}
+	assert.True(t, settled(files, 20*time.Millisecond, make(chan struct{})))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = os.WriteFile(file, []byte("// still writing"), 0o644)
+	}()
+
+	assert.False(t, settled(files, 100*time.Millisecond, make(chan struct{})))
+}
+
+func TestIsWithin(t *testing.T) {
+	dir := filepath.Join("projects", "room")
+
+	assert.True(t, isWithin(dir, filepath.Join(dir, "a.usp")))
+	assert.True(t, isWithin(dir, filepath.Join(dir, "lib", "b.usl")))
+	assert.False(t, isWithin(dir, filepath.Join("projects", "room2", "a.usp")))
+	assert.False(t, isWithin(dir, filepath.Join("projects", "a.usp")))
+}
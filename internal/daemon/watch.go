@@ -0,0 +1,403 @@
+package daemon
+
+import (
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/deps"
+	"github.com/Norgate-AV/spc/internal/discovery"
+	"github.com/Norgate-AV/spc/internal/logger"
+	"github.com/Norgate-AV/spc/internal/notify"
+)
+
+// watcher rebuilds projects when their sources change. A project is rebuilt
+// once it has been quiet for its watch.debounce, so that saving several files
+// (or an editor's write-rename dance) triggers a single build; watch.batch and
+// watch.settle can hold the build back further
+type watcher struct {
+	s  *Server
+	fs *fsnotify.Watcher
+
+	mu      sync.Mutex
+	pending map[*project]map[string]bool
+	first   map[*project]time.Time
+	timers  map[*project]*time.Timer
+	done    chan struct{}
+}
+
+// Watch watches a project directory and its user SIMPL+ folders, rebuilding
+// the project's changed sources, and the ones that use a changed library,
+// whenever they are saved
+func (s *Server) Watch(dir, profile string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	// Fail now rather than on the first change if the config is broken
+	cfg, err := config.NewLoader().LoadProject(dir, profile)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	w := s.watcher
+	if w == nil {
+		fw, err := fsnotify.NewWatcher()
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+
+		w = &watcher{
+			s:       s,
+			fs:      fw,
+			pending: make(map[*project]map[string]bool),
+			first:   make(map[*project]time.Time),
+			timers:  make(map[*project]*time.Timer),
+			done:    make(chan struct{}),
+		}
+		s.watcher = w
+
+		go w.run()
+	}
+	s.mu.Unlock()
+
+	ignore, err := discovery.LoadIgnore(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := w.addTree(dir, ignore); err != nil {
+		return err
+	}
+
+	// Libraries in user folders are resolved from the folder itself, not
+	// its subdirectories
+	for _, folder := range cfg.UserFolders {
+		if err := w.fs.Add(folder); err != nil {
+			logger.Warnf("Failed to watch user folder %s: %v", folder, err)
+		}
+	}
+
+	p := s.project(dir, profile)
+
+	s.mu.Lock()
+	p.watching = true
+	p.watch = cfg.Watch
+	p.userFolders = cfg.UserFolders
+	s.mu.Unlock()
+
+	return nil
+}
+
+// addTree watches dir and its subdirectories, skipping SPlsWork, hidden and
+// ignored directories
+func (w *watcher) addTree(dir string, ignore *discovery.Ignore) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+
+		if path != dir && (skipDir(d.Name()) || ignore.Ignores(path, true)) {
+			return filepath.SkipDir
+		}
+
+		return w.fs.Add(path)
+	})
+}
+
+// skipDir reports whether a directory never contains sources worth watching
+func skipDir(name string) bool {
+	return strings.EqualFold(name, "SPlsWork") || strings.HasPrefix(name, ".")
+}
+
+// run handles file system events until the watcher is closed
+func (w *watcher) run() {
+	for {
+		select {
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+
+			w.handle(event)
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+
+			logger.Warnf("Watch error: %v", err)
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// handle queues a changed source for rebuilding and starts watching new
+// directories
+func (w *watcher) handle(event fsnotify.Event) {
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			ignore := w.s.ignoreFor(event.Name)
+			if skipDir(info.Name()) || ignore.Ignores(event.Name, true) {
+				return
+			}
+
+			if err := w.addTree(event.Name, ignore); err != nil {
+				logger.Warnf("Failed to watch %s: %v", event.Name, err)
+			}
+
+			return
+		}
+	}
+
+	if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+		return
+	}
+
+	if !discovery.IsSource(event.Name) {
+		return
+	}
+
+	for _, p := range w.s.projectsUsing(event.Name) {
+		w.queue(p, w.s.watchConfig(p), event.Name)
+	}
+}
+
+// queue adds changed files to a project's next rebuild and (re)starts the
+// timer for it
+func (w *watcher) queue(p *project, settings config.WatchConfig, files ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	if w.pending[p] == nil {
+		w.pending[p] = make(map[string]bool)
+		w.first[p] = now
+	}
+
+	for _, file := range files {
+		w.pending[p][file] = true
+	}
+
+	delay := rebuildDelay(settings, w.first[p], now)
+
+	if t := w.timers[p]; t != nil {
+		t.Reset(delay)
+		return
+	}
+
+	w.timers[p] = time.AfterFunc(delay, func() { w.flush(p) })
+}
+
+// rebuildDelay returns how long after a change at now a project whose
+// pending changes started at first is rebuilt: once it has been quiet for
+// the debounce, and not before the batch window has passed
+func rebuildDelay(settings config.WatchConfig, first, now time.Time) time.Duration {
+	return max(settings.Debounce, first.Add(settings.Batch).Sub(now))
+}
+
+// flush rebuilds the sources of a project that changed since its last build,
+// once they have settled
+func (w *watcher) flush(p *project) {
+	w.mu.Lock()
+	changed := w.pending[p]
+	delete(w.pending, p)
+	delete(w.first, p)
+	delete(w.timers, p)
+	w.mu.Unlock()
+
+	settings := w.s.watchConfig(p)
+	if settings.Settle > 0 && !settled(changed, settings.Settle, w.done) {
+		// Still being written; try again once they are quiet
+		w.queue(p, settings, slices.Collect(maps.Keys(changed))...)
+		return
+	}
+
+	select {
+	case <-w.done:
+		return
+	default:
+	}
+
+	w.s.rebuild(p, changed)
+}
+
+// settled reports whether none of files changed size or modification time
+// over the settle time. It gives up when done is closed
+func settled(files map[string]bool, settle time.Duration, done chan struct{}) bool {
+	before := stats(files)
+
+	select {
+	case <-time.After(settle):
+	case <-done:
+		return true
+	}
+
+	return maps.Equal(before, stats(files))
+}
+
+// fileStat is what settled compares to tell whether a file is still changing
+type fileStat struct {
+	size    int64
+	modTime time.Time
+}
+
+// stats returns the size and modification time of each file that exists
+func stats(files map[string]bool) map[string]fileStat {
+	result := make(map[string]fileStat, len(files))
+
+	for file := range files {
+		if info, err := os.Stat(file); err == nil {
+			result[file] = fileStat{size: info.Size(), modTime: info.ModTime()}
+		}
+	}
+
+	return result
+}
+
+// watchConfig returns the watch settings of a project
+func (s *Server) watchConfig(p *project) config.WatchConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return p.watch
+}
+
+// close stops watching
+func (w *watcher) close() {
+	close(w.done)
+	w.fs.Close()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, t := range w.timers {
+		t.Stop()
+	}
+}
+
+// projectFor returns the watched project containing path (the innermost one
+// when projects are nested), or nil
+func (s *Server) projectFor(path string) *project {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var found *project
+	for dir, p := range s.projects {
+		if !p.watching || !isWithin(dir, path) {
+			continue
+		}
+
+		if found == nil || len(dir) > len(found.dir) {
+			found = p
+		}
+	}
+
+	return found
+}
+
+// projectsUsing returns the watched projects a changed source may belong to:
+// the one containing it and those with a user folder holding it
+func (s *Server) projectsUsing(path string) []*project {
+	var projects []*project
+	if p := s.projectFor(path); p != nil {
+		projects = append(projects, p)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := filepath.Dir(path)
+	for _, p := range s.projects {
+		if !p.watching || slices.Contains(projects, p) {
+			continue
+		}
+
+		if slices.ContainsFunc(p.userFolders, func(folder string) bool { return filepath.Clean(folder) == dir }) {
+			projects = append(projects, p)
+		}
+	}
+
+	return projects
+}
+
+// ignoreFor returns the .spcignore of the watched project containing path.
+// Unreadable files ignore nothing; the next build reports them
+func (s *Server) ignoreFor(path string) *discovery.Ignore {
+	p := s.projectFor(path)
+	if p == nil {
+		return nil
+	}
+
+	ignore, _ := discovery.LoadIgnore(p.dir)
+
+	return ignore
+}
+
+// isWithin reports whether path is inside dir
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// rebuild builds the changed files that belong to the project's build and
+// those that use a changed library, directly or through other libraries
+func (s *Server) rebuild(p *project, changed map[string]bool) {
+	s.mu.Lock()
+	dir, profile := p.dir, p.profile
+	s.mu.Unlock()
+
+	cfg, err := config.NewLoader().LoadProject(dir, profile)
+	if err != nil {
+		logger.Errorf("%s: %v", dir, err)
+		return
+	}
+
+	files, err := projectSources(cfg, dir, nil)
+	if err != nil {
+		logger.Errorf("%s: %v", dir, err)
+		return
+	}
+
+	files, err = deps.Affected(files, slices.Collect(maps.Keys(changed)), &deps.Resolver{UserFolders: cfg.UserFolders})
+	if err != nil {
+		logger.Errorf("%s: %v", dir, err)
+		return
+	}
+
+	if len(files) == 0 {
+		return
+	}
+
+	start := time.Now()
+	reply := s.build(s.ctx, p, cfg, files, cfg.KeepGoing)
+	elapsed := time.Since(start)
+
+	for _, r := range reply.Results {
+		if r.Error != "" {
+			logger.Errorf("✗ %s: %s", r.File, r.Error)
+		} else {
+			logger.Successf("✓ %s (%s)", r.File, r.Status)
+		}
+	}
+
+	settings := cfg.Notify
+	settings.Enabled = settings.Enabled || s.Notify
+
+	if settings.Fires(reply.Failed > 0, elapsed) {
+		if err := notify.Send(notify.ForBuild(dir, len(reply.Results), reply.Failed, elapsed)); err != nil {
+			logger.Warnf("Failed to show desktop notification: %v", err)
+		}
+	}
+}
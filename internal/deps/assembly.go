@@ -0,0 +1,389 @@
+package deps
+
+import (
+	"archive/zip"
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errNotAssembly is returned when a file has no .NET metadata
+var errNotAssembly = errors.New("not a .NET assembly")
+
+// AssemblyVersion returns the assembly version (e.g. "1.2.0.0") of a SIMPL#
+// library. Both .clz archives and bare .dll assemblies are supported
+func AssemblyVersion(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	if bytes.HasPrefix(data, []byte("PK")) {
+		if data, err = assemblyFromArchive(data, path); err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	version, err := peAssemblyVersion(data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+
+	return version, nil
+}
+
+// assemblyFromArchive extracts the assembly from a .clz archive, preferring
+// the DLL named after the archive
+func assemblyFromArchive(data []byte, path string) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	want := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + ".dll"
+
+	var dll *zip.File
+	for _, f := range r.File {
+		if !strings.EqualFold(filepath.Ext(f.Name), ".dll") {
+			continue
+		}
+
+		if dll == nil || strings.EqualFold(filepath.Base(f.Name), want) {
+			dll = f
+		}
+	}
+
+	if dll == nil {
+		return nil, errNotAssembly
+	}
+
+	rc, err := dll.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
+
+// peAssemblyVersion reads the assembly version from the CLI metadata of a PE image
+func peAssemblyVersion(data []byte) (string, error) {
+	f, err := pe.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return "", errNotAssembly
+	}
+
+	defer f.Close()
+
+	var dirs []pe.DataDirectory
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		dirs = oh.DataDirectory[:oh.NumberOfRvaAndSizes]
+	case *pe.OptionalHeader64:
+		dirs = oh.DataDirectory[:oh.NumberOfRvaAndSizes]
+	}
+
+	// The CLI header is data directory 14
+	if len(dirs) <= pe.IMAGE_DIRECTORY_ENTRY_COM_DESCRIPTOR || dirs[pe.IMAGE_DIRECTORY_ENTRY_COM_DESCRIPTOR].VirtualAddress == 0 {
+		return "", errNotAssembly
+	}
+
+	cli, err := readRVA(f, dirs[pe.IMAGE_DIRECTORY_ENTRY_COM_DESCRIPTOR].VirtualAddress, 16)
+	if err != nil {
+		return "", err
+	}
+
+	metadata, err := readRVA(f, binary.LittleEndian.Uint32(cli[8:]), binary.LittleEndian.Uint32(cli[12:]))
+	if err != nil {
+		return "", err
+	}
+
+	return metadataAssemblyVersion(metadata)
+}
+
+// readRVA reads size bytes at a relative virtual address
+func readRVA(f *pe.File, rva, size uint32) ([]byte, error) {
+	for _, s := range f.Sections {
+		if rva < s.VirtualAddress || rva >= s.VirtualAddress+s.VirtualSize {
+			continue
+		}
+
+		data, err := s.Data()
+		if err != nil {
+			return nil, err
+		}
+
+		start := rva - s.VirtualAddress
+		if uint64(start)+uint64(size) > uint64(len(data)) {
+			return nil, errors.New("truncated CLI metadata")
+		}
+
+		return data[start : start+size], nil
+	}
+
+	return nil, errors.New("CLI metadata outside of any section")
+}
+
+// Metadata table numbers (ECMA-335 II.22)
+const (
+	tableModule                 = 0x00
+	tableTypeRef                = 0x01
+	tableTypeDef                = 0x02
+	tableFieldPtr               = 0x03
+	tableField                  = 0x04
+	tableMethodPtr              = 0x05
+	tableMethodDef              = 0x06
+	tableParamPtr               = 0x07
+	tableParam                  = 0x08
+	tableInterfaceImpl          = 0x09
+	tableMemberRef              = 0x0A
+	tableConstant               = 0x0B
+	tableCustomAttribute        = 0x0C
+	tableFieldMarshal           = 0x0D
+	tableDeclSecurity           = 0x0E
+	tableClassLayout            = 0x0F
+	tableFieldLayout            = 0x10
+	tableStandAloneSig          = 0x11
+	tableEventMap               = 0x12
+	tableEventPtr               = 0x13
+	tableEvent                  = 0x14
+	tablePropertyMap            = 0x15
+	tablePropertyPtr            = 0x16
+	tableProperty               = 0x17
+	tableMethodSemantics        = 0x18
+	tableMethodImpl             = 0x19
+	tableModuleRef              = 0x1A
+	tableTypeSpec               = 0x1B
+	tableImplMap                = 0x1C
+	tableFieldRVA               = 0x1D
+	tableENCLog                 = 0x1E
+	tableENCMap                 = 0x1F
+	tableAssembly               = 0x20
+	tableAssemblyRef            = 0x23
+	tableFile                   = 0x26
+	tableExportedType           = 0x27
+	tableManifestResource       = 0x28
+	tableGenericParam           = 0x2A
+	tableMethodSpec             = 0x2B
+	tableGenericParamConstraint = 0x2C
+)
+
+// metadataAssemblyVersion finds the Assembly table in a CLI metadata root
+// and returns its version
+func metadataAssemblyVersion(md []byte) (string, error) {
+	if len(md) < 16 || binary.LittleEndian.Uint32(md) != 0x424A5342 {
+		return "", errors.New("invalid CLI metadata signature")
+	}
+
+	// Skip the runtime version string to reach the stream headers
+	pos := 16 + int(binary.LittleEndian.Uint32(md[12:]))
+	if pos+4 > len(md) {
+		return "", errors.New("truncated CLI metadata")
+	}
+
+	streams := int(binary.LittleEndian.Uint16(md[pos+2:]))
+	pos += 4
+
+	var tables []byte
+	for i := 0; i < streams; i++ {
+		if pos+8 > len(md) {
+			return "", errors.New("truncated CLI metadata")
+		}
+
+		offset := int(binary.LittleEndian.Uint32(md[pos:]))
+		size := int(binary.LittleEndian.Uint32(md[pos+4:]))
+
+		end := bytes.IndexByte(md[pos+8:], 0)
+		if end < 0 {
+			return "", errors.New("truncated CLI metadata")
+		}
+
+		name := string(md[pos+8 : pos+8+end])
+		pos += 8 + (end+4)&^3
+
+		if name == "#~" || name == "#-" {
+			if offset+size > len(md) {
+				return "", errors.New("truncated CLI metadata")
+			}
+
+			tables = md[offset : offset+size]
+		}
+	}
+
+	if tables == nil {
+		return "", errors.New("no metadata tables stream")
+	}
+
+	return tablesAssemblyVersion(tables)
+}
+
+// tablesAssemblyVersion reads the version from the Assembly table of a #~ stream
+func tablesAssemblyVersion(t []byte) (string, error) {
+	if len(t) < 24 {
+		return "", errors.New("truncated metadata tables")
+	}
+
+	heapSizes := t[6]
+	valid := binary.LittleEndian.Uint64(t[8:])
+
+	var rows [64]uint32
+	pos := 24
+	for i := 0; i < 64; i++ {
+		if valid&(1<<i) == 0 {
+			continue
+		}
+
+		if pos+4 > len(t) {
+			return "", errors.New("truncated metadata tables")
+		}
+
+		rows[i] = binary.LittleEndian.Uint32(t[pos:])
+		pos += 4
+	}
+
+	if heapSizes&0x40 != 0 {
+		pos += 4
+	}
+
+	if rows[tableAssembly] == 0 {
+		return "", errors.New("no assembly manifest")
+	}
+
+	sizes := newRowSizes(heapSizes, rows[:])
+	for table := 0; table < tableAssembly; table++ {
+		pos += int(rows[table]) * sizes.row(table)
+	}
+
+	// Assembly row: HashAlgId (4), MajorVersion, MinorVersion, BuildNumber, RevisionNumber (2 each)
+	if pos+12 > len(t) {
+		return "", errors.New("truncated metadata tables")
+	}
+
+	v := func(i int) uint16 { return binary.LittleEndian.Uint16(t[pos+4+2*i:]) }
+
+	return fmt.Sprintf("%d.%d.%d.%d", v(0), v(1), v(2), v(3)), nil
+}
+
+// rowSizes computes the size of metadata table rows, which depends on the
+// heap sizes and on how many rows the referenced tables have
+type rowSizes struct {
+	str, guid, blob int
+	rows            []uint32
+}
+
+func newRowSizes(heapSizes byte, rows []uint32) rowSizes {
+	size := func(bit byte) int {
+		if heapSizes&bit != 0 {
+			return 4
+		}
+
+		return 2
+	}
+
+	return rowSizes{str: size(0x01), guid: size(0x02), blob: size(0x04), rows: rows}
+}
+
+// index is the size of a simple index into a table
+func (s rowSizes) index(table int) int {
+	if s.rows[table] < 1<<16 {
+		return 2
+	}
+
+	return 4
+}
+
+// coded is the size of a coded index over the given tables
+func (s rowSizes) coded(tagBits uint, tables ...int) int {
+	for _, table := range tables {
+		if s.rows[table] >= 1<<(16-tagBits) {
+			return 4
+		}
+	}
+
+	return 2
+}
+
+// row returns the size of a row in one of the tables before Assembly
+func (s rowSizes) row(table int) int {
+	typeDefOrRef := s.coded(2, tableTypeDef, tableTypeRef, tableTypeSpec)
+
+	switch table {
+	case tableModule:
+		return 2 + s.str + 3*s.guid
+	case tableTypeRef:
+		return s.coded(2, tableModule, tableModuleRef, tableAssemblyRef, tableTypeRef) + 2*s.str
+	case tableTypeDef:
+		return 4 + 2*s.str + typeDefOrRef + s.index(tableField) + s.index(tableMethodDef)
+	case tableFieldPtr:
+		return s.index(tableField)
+	case tableField:
+		return 2 + s.str + s.blob
+	case tableMethodPtr:
+		return s.index(tableMethodDef)
+	case tableMethodDef:
+		return 8 + s.str + s.blob + s.index(tableParam)
+	case tableParamPtr:
+		return s.index(tableParam)
+	case tableParam:
+		return 4 + s.str
+	case tableInterfaceImpl:
+		return s.index(tableTypeDef) + typeDefOrRef
+	case tableMemberRef:
+		return s.coded(3, tableTypeDef, tableTypeRef, tableModuleRef, tableMethodDef, tableTypeSpec) + s.str + s.blob
+	case tableConstant:
+		return 2 + s.coded(2, tableField, tableParam, tableProperty) + s.blob
+	case tableCustomAttribute:
+		parent := s.coded(5, tableMethodDef, tableField, tableTypeRef, tableTypeDef, tableParam, tableInterfaceImpl,
+			tableMemberRef, tableModule, tableDeclSecurity, tableProperty, tableEvent, tableStandAloneSig,
+			tableModuleRef, tableTypeSpec, tableAssembly, tableAssemblyRef, tableFile, tableExportedType,
+			tableManifestResource, tableGenericParam, tableGenericParamConstraint, tableMethodSpec)
+		return parent + s.coded(3, tableMethodDef, tableMemberRef) + s.blob
+	case tableFieldMarshal:
+		return s.coded(1, tableField, tableParam) + s.blob
+	case tableDeclSecurity:
+		return 2 + s.coded(2, tableTypeDef, tableMethodDef, tableAssembly) + s.blob
+	case tableClassLayout:
+		return 6 + s.index(tableTypeDef)
+	case tableFieldLayout:
+		return 4 + s.index(tableField)
+	case tableStandAloneSig:
+		return s.blob
+	case tableEventMap:
+		return s.index(tableTypeDef) + s.index(tableEvent)
+	case tableEventPtr:
+		return s.index(tableEvent)
+	case tableEvent:
+		return 2 + s.str + typeDefOrRef
+	case tablePropertyMap:
+		return s.index(tableTypeDef) + s.index(tableProperty)
+	case tablePropertyPtr:
+		return s.index(tableProperty)
+	case tableProperty:
+		return 2 + s.str + s.blob
+	case tableMethodSemantics:
+		return 2 + s.index(tableMethodDef) + s.coded(1, tableEvent, tableProperty)
+	case tableMethodImpl:
+		return s.index(tableTypeDef) + 2*s.coded(1, tableMethodDef, tableMemberRef)
+	case tableModuleRef:
+		return s.str
+	case tableTypeSpec:
+		return s.blob
+	case tableImplMap:
+		return 2 + s.coded(1, tableField, tableMethodDef) + s.str + s.index(tableModuleRef)
+	case tableFieldRVA:
+		return 4 + s.index(tableField)
+	case tableENCLog:
+		return 8
+	case tableENCMap:
+		return 4
+	default:
+		return 0
+	}
+}
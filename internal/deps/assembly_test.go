@@ -0,0 +1,85 @@
+package deps
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildMetadata builds a CLI metadata root with a #~ stream holding one
+// Module row, two TypeRef rows and an Assembly row with the given version
+func buildMetadata(version [4]uint16) []byte {
+	le := binary.LittleEndian
+
+	var tables bytes.Buffer
+	tables.Write(make([]byte, 6)) // reserved, major, minor
+	tables.WriteByte(0)           // heap sizes
+	tables.WriteByte(1)           // reserved
+	_ = binary.Write(&tables, le, uint64(1<<tableModule|1<<tableTypeRef|1<<tableAssembly))
+	_ = binary.Write(&tables, le, uint64(0))
+	_ = binary.Write(&tables, le, []uint32{1, 2, 1})
+	tables.Write(make([]byte, 10))  // Module row
+	tables.Write(make([]byte, 2*6)) // TypeRef rows
+	_ = binary.Write(&tables, le, uint32(0x8004))
+	_ = binary.Write(&tables, le, version)
+	tables.Write(make([]byte, 4+3*2)) // flags, public key, name, culture
+
+	var md bytes.Buffer
+	runtime := []byte("v4.0.30319\x00\x00")
+	_ = binary.Write(&md, le, uint32(0x424A5342))
+	_ = binary.Write(&md, le, []uint16{1, 1})
+	_ = binary.Write(&md, le, uint32(0))
+	_ = binary.Write(&md, le, uint32(len(runtime)))
+	md.Write(runtime)
+	_ = binary.Write(&md, le, []uint16{0, 2}) // flags, streams
+
+	headerSize := (8 + 12) + (8 + 4) // "#Strings\0" padded to 12, "#~\0" padded to 4
+	offset := uint32(md.Len() + headerSize)
+	_ = binary.Write(&md, le, []uint32{offset, 4})
+	md.WriteString("#Strings\x00\x00\x00\x00")
+	_ = binary.Write(&md, le, []uint32{offset + 4, uint32(tables.Len())})
+	md.WriteString("#~\x00\x00")
+	md.Write(make([]byte, 4))
+	md.Write(tables.Bytes())
+
+	return md.Bytes()
+}
+
+func TestMetadataAssemblyVersion(t *testing.T) {
+	version, err := metadataAssemblyVersion(buildMetadata([4]uint16{1, 2, 3, 4}))
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", version)
+
+	_, err = metadataAssemblyVersion([]byte("not metadata at all"))
+	assert.Error(t, err)
+}
+
+func TestAssemblyVersion_NotAnAssembly(t *testing.T) {
+	dir := t.TempDir()
+
+	text := filepath.Join(dir, "Driver.clz")
+	require.NoError(t, os.WriteFile(text, []byte("not an assembly"), 0o644))
+
+	_, err := AssemblyVersion(text)
+	assert.ErrorContains(t, err, "not a .NET assembly")
+
+	// An archive without a DLL
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("ProgramInfo.config")
+	require.NoError(t, err)
+	_, _ = w.Write([]byte("<ProgramInfo />"))
+	require.NoError(t, zw.Close())
+
+	archive := filepath.Join(dir, "Empty.clz")
+	require.NoError(t, os.WriteFile(archive, buf.Bytes(), 0o644))
+
+	_, err = AssemblyVersion(archive)
+	assert.ErrorContains(t, err, "not a .NET assembly")
+}
@@ -0,0 +1,433 @@
+package deps
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NodeKind identifies the type of file a graph node represents
+type NodeKind string
+
+const (
+	NodeModule     NodeKind = "module"
+	NodeLibrary    NodeKind = "library"
+	NodeSimplSharp NodeKind = "simplsharp"
+	NodeCrestron   NodeKind = "crestron"
+)
+
+// Node is a source file or library in the dependency graph
+type Node struct {
+	// ID uniquely identifies the node (absolute path for files)
+	ID string `json:"id"`
+
+	// Label is a short display name (path relative to the graph base directory)
+	Label string `json:"label"`
+
+	// Kind is the type of file
+	Kind NodeKind `json:"kind"`
+
+	// Path is the resolved file path, empty for Crestron and missing libraries
+	Path string `json:"path,omitempty"`
+
+	// Missing is true when a referenced library could not be found
+	Missing bool `json:"missing,omitempty"`
+}
+
+// Edge is a dependency from one node to another
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind Kind   `json:"kind"`
+}
+
+// Graph is the dependency graph of a set of SIMPL+ sources
+type Graph struct {
+	Nodes []*Node `json:"nodes"`
+	Edges []Edge  `json:"edges"`
+
+	index   map[string]*Node
+	baseDir string
+}
+
+// Resolver locates the library files referenced by directives
+type Resolver struct {
+	// UserFolders are searched for user libraries after the source
+	// directory and any #INCLUDEPATH directories
+	UserFolders []string
+}
+
+// libraryExtension returns the file extension for a library directive kind
+func libraryExtension(kind Kind) string {
+	switch kind {
+	case KindUserLibrary:
+		return ".usl"
+	case KindUserSimplSharpLibrary:
+		return ".clz"
+	case KindCrestronLibrary:
+		return ".csl"
+	case KindCrestronSimplSharpLibrary:
+		return ".clz"
+	case KindIncludePath:
+		return ""
+	default:
+		return ""
+	}
+}
+
+// SearchPaths returns the directories searched for a source file's libraries
+func (r *Resolver) SearchPaths(sourceFile string, directives []Directive) []string {
+	sourceDir := filepath.Dir(sourceFile)
+	paths := []string{sourceDir}
+
+	for _, d := range directives {
+		if d.Kind != KindIncludePath {
+			continue
+		}
+
+		// Paths are written for Windows, so accept backslashes everywhere
+		p := filepath.FromSlash(strings.ReplaceAll(d.Value, `\`, "/"))
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(sourceDir, p)
+		}
+
+		paths = append(paths, p)
+	}
+
+	return append(paths, r.UserFolders...)
+}
+
+// Resolve returns the path of the library referenced by a directive,
+// or an empty string if it cannot be found. Crestron libraries ship with
+// SIMPL and are never resolved
+func (r *Resolver) Resolve(d Directive, searchPaths []string) string {
+	if d.Kind != KindUserLibrary && d.Kind != KindUserSimplSharpLibrary {
+		return ""
+	}
+
+	name := d.Value
+	if filepath.Ext(name) == "" {
+		name += libraryExtension(d.Kind)
+	}
+
+	for _, dir := range searchPaths {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			if abs, err := filepath.Abs(path); err == nil {
+				return abs
+			}
+
+			return path
+		}
+	}
+
+	return ""
+}
+
+// BuildGraph scans the given source files (and, transitively, the user
+// libraries they reference) and returns their dependency graph.
+// Node labels are made relative to baseDir
+func BuildGraph(files []string, resolver *Resolver, baseDir string) (*Graph, error) {
+	g := &Graph{Nodes: []*Node{}, Edges: []Edge{}, index: make(map[string]*Node), baseDir: baseDir}
+
+	queue := make([]string, 0, len(files))
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+
+		kind := NodeModule
+		if strings.EqualFold(filepath.Ext(abs), ".usl") {
+			kind = NodeLibrary
+		}
+
+		if g.addNode(abs, kind, abs, false) {
+			queue = append(queue, abs)
+		}
+	}
+
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+
+		directives, err := ScanFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		searchPaths := resolver.SearchPaths(file, directives)
+
+		for _, d := range directives {
+			if d.Kind == KindIncludePath {
+				continue
+			}
+
+			id, added := g.addDependency(d, resolver.Resolve(d, searchPaths))
+			g.Edges = append(g.Edges, Edge{From: file, To: id, Kind: d.Kind})
+
+			// Follow user libraries so their own dependencies are included
+			if added && d.Kind == KindUserLibrary && !g.index[id].Missing {
+				queue = append(queue, id)
+			}
+		}
+	}
+
+	return g, nil
+}
+
+// addDependency adds the node for a directive, returning its ID and whether it was new
+func (g *Graph) addDependency(d Directive, resolved string) (string, bool) {
+	switch {
+	case d.Kind == KindCrestronLibrary || d.Kind == KindCrestronSimplSharpLibrary:
+		id := "crestron:" + d.Value
+		return id, g.addNode(id, NodeCrestron, "", false)
+	case resolved == "":
+		name := d.Value
+		if filepath.Ext(name) == "" {
+			name += libraryExtension(d.Kind)
+		}
+
+		id := "missing:" + name
+		return id, g.addNode(id, nodeKindFor(d.Kind), "", true)
+	default:
+		return resolved, g.addNode(resolved, nodeKindFor(d.Kind), resolved, false)
+	}
+}
+
+func nodeKindFor(kind Kind) NodeKind {
+	if kind == KindUserSimplSharpLibrary || kind == KindCrestronSimplSharpLibrary {
+		return NodeSimplSharp
+	}
+
+	return NodeLibrary
+}
+
+// addNode adds a node if not already present. Returns true if it was added
+func (g *Graph) addNode(id string, kind NodeKind, path string, missing bool) bool {
+	if _, ok := g.index[id]; ok {
+		return false
+	}
+
+	node := &Node{ID: id, Label: g.label(id, path), Kind: kind, Path: path, Missing: missing}
+	g.Nodes = append(g.Nodes, node)
+	g.index[id] = node
+
+	return true
+}
+
+// label returns a short display name for a node
+func (g *Graph) label(id, path string) string {
+	if path == "" {
+		_, name, _ := strings.Cut(id, ":")
+		return name
+	}
+
+	if g.baseDir != "" {
+		if rel, err := filepath.Rel(g.baseDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+
+	return filepath.ToSlash(path)
+}
+
+// Node returns the node with the given ID, or nil
+func (g *Graph) Node(id string) *Node {
+	return g.index[id]
+}
+
+// Dependencies returns the nodes a node directly depends on, in the order
+// their directives appear
+func (g *Graph) Dependencies(id string) []*Node {
+	var nodes []*Node
+	seen := make(map[string]bool)
+
+	for _, e := range g.Edges {
+		if e.From == id && !seen[e.To] {
+			seen[e.To] = true
+			nodes = append(nodes, g.index[e.To])
+		}
+	}
+
+	return nodes
+}
+
+// Dependents returns the nodes that directly depend on a node
+func (g *Graph) Dependents(id string) []*Node {
+	var nodes []*Node
+	seen := make(map[string]bool)
+
+	for _, e := range g.Edges {
+		if e.To == id && !seen[e.From] {
+			seen[e.From] = true
+			nodes = append(nodes, g.index[e.From])
+		}
+	}
+
+	return nodes
+}
+
+// TransitiveDependencies returns every node a node depends on, directly or
+// through the libraries it uses, nearest first
+func (g *Graph) TransitiveDependencies(id string) []*Node {
+	var nodes []*Node
+	seen := map[string]bool{id: true}
+	queue := []string{id}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, n := range g.Dependencies(current) {
+			if seen[n.ID] {
+				continue
+			}
+
+			seen[n.ID] = true
+			nodes = append(nodes, n)
+			queue = append(queue, n.ID)
+		}
+	}
+
+	return nodes
+}
+
+// TransitiveDependents returns every node that depends on a node, directly
+// or through the libraries that use it, nearest first
+func (g *Graph) TransitiveDependents(id string) []*Node {
+	var nodes []*Node
+	seen := map[string]bool{id: true}
+	queue := []string{id}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, n := range g.Dependents(current) {
+			if seen[n.ID] {
+				continue
+			}
+
+			seen[n.ID] = true
+			nodes = append(nodes, n)
+			queue = append(queue, n.ID)
+		}
+	}
+
+	return nodes
+}
+
+// Affected returns the files that are among changed (absolute paths) or use
+// one of them, directly or through other libraries, in their original order
+func Affected(files, changed []string, resolver *Resolver) ([]string, error) {
+	g, err := BuildGraph(files, resolver, "")
+	if err != nil {
+		return nil, err
+	}
+
+	affected := make(map[string]bool)
+	for _, file := range changed {
+		affected[file] = true
+
+		for _, n := range g.TransitiveDependents(file) {
+			affected[n.Path] = true
+		}
+	}
+
+	var selected []string
+	for _, file := range files {
+		if abs, err := filepath.Abs(file); err == nil && affected[abs] {
+			selected = append(selected, file)
+		}
+	}
+
+	return selected, nil
+}
+
+// WriteDOT writes the graph in Graphviz DOT format
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("digraph spc {\n\trankdir=LR;\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(&b, "\t%q [label=%q, shape=%s%s];\n", n.ID, n.Label, dotShape(n.Kind), dotStyle(n))
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "\t%q -> %q;\n", e.From, e.To)
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotShape(kind NodeKind) string {
+	switch kind {
+	case NodeModule:
+		return "box"
+	case NodeLibrary:
+		return "component"
+	case NodeSimplSharp:
+		return "cylinder"
+	case NodeCrestron:
+		return "note"
+	default:
+		return "ellipse"
+	}
+}
+
+func dotStyle(n *Node) string {
+	if n.Missing {
+		return ", style=dashed, color=red"
+	}
+
+	return ""
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	var b strings.Builder
+
+	ids := make(map[string]string, len(g.Nodes))
+	b.WriteString("graph LR\n")
+
+	for i, n := range g.Nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[n.ID] = id
+
+		label := strings.ReplaceAll(n.Label, "\"", "'")
+		switch n.Kind {
+		case NodeModule:
+			fmt.Fprintf(&b, "    %s[\"%s\"]\n", id, label)
+		case NodeSimplSharp:
+			fmt.Fprintf(&b, "    %s[(\"%s\")]\n", id, label)
+		case NodeLibrary, NodeCrestron:
+			fmt.Fprintf(&b, "    %s([\"%s\"])\n", id, label)
+		}
+
+		if n.Missing {
+			fmt.Fprintf(&b, "    style %s stroke:#f00,stroke-dasharray: 5 5\n", id)
+		}
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "    %s --> %s\n", ids[e.From], ids[e.To])
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the graph as indented JSON
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(g)
+}
@@ -0,0 +1,163 @@
+package deps
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+// newProject creates a small project:
+//
+//	main.usp -> helpers.usl -> strings.usl (in user folder)
+//	main.usp -> Driver.clz (via #INCLUDEPATH)
+//	main.usp -> Crestron library
+//	main.usp -> missing.usl
+func newProject(t *testing.T) (dir, userFolder string) {
+	dir = t.TempDir()
+	userFolder = t.TempDir()
+
+	writeFile(t, filepath.Join(dir, "main.usp"), `#INCLUDEPATH "lib"
+#USER_LIBRARY "helpers"
+#USER_SIMPLSHARP_LIBRARY "Driver"
+#CRESTRON_LIBRARY "Crestron Strings"
+#USER_LIBRARY "missing"
+`)
+	writeFile(t, filepath.Join(dir, "helpers.usl"), `#USER_LIBRARY "strings"`)
+	writeFile(t, filepath.Join(dir, "lib", "Driver.clz"), "binary")
+	writeFile(t, filepath.Join(userFolder, "strings.usl"), "// no deps")
+
+	return dir, userFolder
+}
+
+func TestBuildGraph(t *testing.T) {
+	dir, userFolder := newProject(t)
+
+	g, err := BuildGraph([]string{filepath.Join(dir, "main.usp")}, &Resolver{UserFolders: []string{userFolder}}, dir)
+	require.NoError(t, err)
+
+	main := g.Node(filepath.Join(dir, "main.usp"))
+	require.NotNil(t, main)
+	assert.Equal(t, NodeModule, main.Kind)
+	assert.Equal(t, "main.usp", main.Label)
+
+	helpers := g.Node(filepath.Join(dir, "helpers.usl"))
+	require.NotNil(t, helpers)
+	assert.Equal(t, NodeLibrary, helpers.Kind)
+
+	// Transitive dependency resolved through the user folder
+	strs := g.Node(filepath.Join(userFolder, "strings.usl"))
+	require.NotNil(t, strs)
+
+	driver := g.Node(filepath.Join(dir, "lib", "Driver.clz"))
+	require.NotNil(t, driver)
+	assert.Equal(t, NodeSimplSharp, driver.Kind)
+	assert.Equal(t, "lib/Driver.clz", driver.Label)
+
+	crestron := g.Node("crestron:Crestron Strings")
+	require.NotNil(t, crestron)
+	assert.Equal(t, NodeCrestron, crestron.Kind)
+
+	missing := g.Node("missing:missing.usl")
+	require.NotNil(t, missing)
+	assert.True(t, missing.Missing)
+
+	assert.Len(t, g.Nodes, 6)
+	assert.Len(t, g.Edges, 5)
+	assert.Contains(t, g.Edges, Edge{From: helpers.ID, To: strs.ID, Kind: KindUserLibrary})
+}
+
+func TestGraph_Writers(t *testing.T) {
+	dir, userFolder := newProject(t)
+
+	g, err := BuildGraph([]string{filepath.Join(dir, "main.usp")}, &Resolver{UserFolders: []string{userFolder}}, dir)
+	require.NoError(t, err)
+
+	var dot bytes.Buffer
+	require.NoError(t, g.WriteDOT(&dot))
+	assert.Contains(t, dot.String(), "digraph spc {")
+	assert.Contains(t, dot.String(), `label="helpers.usl", shape=component`)
+	assert.Contains(t, dot.String(), "style=dashed, color=red")
+
+	var mermaid bytes.Buffer
+	require.NoError(t, g.WriteMermaid(&mermaid))
+	assert.Contains(t, mermaid.String(), "graph LR")
+	assert.Contains(t, mermaid.String(), `n0["main.usp"]`)
+	assert.Contains(t, mermaid.String(), "n0 --> n1")
+
+	var out bytes.Buffer
+	require.NoError(t, g.WriteJSON(&out))
+
+	var decoded Graph
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Len(t, decoded.Nodes, 6)
+	assert.Len(t, decoded.Edges, 5)
+}
+
+func TestGraph_Queries(t *testing.T) {
+	dir, userFolder := newProject(t)
+	writeFile(t, filepath.Join(dir, "other.usp"), `#USER_LIBRARY "strings"`)
+
+	files := []string{filepath.Join(dir, "main.usp"), filepath.Join(dir, "other.usp")}
+	g, err := BuildGraph(files, &Resolver{UserFolders: []string{userFolder}}, dir)
+	require.NoError(t, err)
+
+	labels := func(nodes []*Node) []string {
+		var out []string
+		for _, n := range nodes {
+			out = append(out, n.Label)
+		}
+		return out
+	}
+
+	main := filepath.Join(dir, "main.usp")
+	strs := filepath.Join(userFolder, "strings.usl")
+
+	assert.Equal(t, []string{"helpers.usl", "lib/Driver.clz", "Crestron Strings", "missing.usl"}, labels(g.Dependencies(main)))
+	assert.Equal(t, []string{"helpers.usl", "lib/Driver.clz", "Crestron Strings", "missing.usl", filepath.ToSlash(strs)},
+		labels(g.TransitiveDependencies(main)))
+	assert.Equal(t, []string{"other.usp", "helpers.usl"}, labels(g.Dependents(strs)))
+	assert.Equal(t, []string{"other.usp", "helpers.usl", "main.usp"}, labels(g.TransitiveDependents(strs)))
+	assert.Empty(t, g.Dependents(main))
+	assert.Empty(t, g.Dependencies(strs))
+}
+
+func TestAffected(t *testing.T) {
+	dir, userFolder := newProject(t)
+	writeFile(t, filepath.Join(dir, "other.usp"), `#USER_LIBRARY "strings"`)
+	writeFile(t, filepath.Join(dir, "alone.usp"), "// no deps")
+
+	files := []string{filepath.Join(dir, "alone.usp"), filepath.Join(dir, "helpers.usl"), filepath.Join(dir, "main.usp"), filepath.Join(dir, "other.usp")}
+	resolver := &Resolver{UserFolders: []string{userFolder}}
+
+	affected, err := Affected(files, []string{filepath.Join(userFolder, "strings.usl")}, resolver)
+	require.NoError(t, err)
+	assert.Equal(t, files[1:], affected)
+
+	affected, err = Affected(files, []string{filepath.Join(dir, "alone.usp")}, resolver)
+	require.NoError(t, err)
+	assert.Equal(t, files[:1], affected)
+}
+
+func TestBuildGraph_WindowsIncludePath(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "src", "main.usp"), `#INCLUDEPATH "..\shared\libs"
+#USER_LIBRARY "helpers"`)
+	writeFile(t, filepath.Join(dir, "shared", "libs", "helpers.usl"), "")
+
+	g, err := BuildGraph([]string{filepath.Join(dir, "src", "main.usp")}, &Resolver{}, dir)
+	require.NoError(t, err)
+
+	helpers := g.Node(filepath.Join(dir, "shared", "libs", "helpers.usl"))
+	require.NotNil(t, helpers)
+	assert.False(t, helpers.Missing)
+}
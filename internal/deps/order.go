@@ -0,0 +1,86 @@
+package deps
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// BuildOrder sorts source files so that every library is compiled before the
+// files in the set that use it, directly or through other libraries. Files
+// keep their original relative order wherever dependencies allow. Returns an
+// error naming the files involved when they depend on each other in a cycle
+func BuildOrder(files []string, resolver *Resolver) ([]string, error) {
+	g, err := BuildGraph(files, resolver, "")
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(files))
+	inSet := make(map[string]bool, len(files))
+
+	for i, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+
+		ids[i] = abs
+		inSet[abs] = true
+	}
+
+	// Dependencies of each file on other files in the set
+	requires := make([][]string, len(files))
+	for i, id := range ids {
+		for _, n := range g.TransitiveDependencies(id) {
+			if inSet[n.ID] && n.ID != id {
+				requires[i] = append(requires[i], n.ID)
+			}
+		}
+	}
+
+	ordered := make([]string, 0, len(files))
+	emitted := make(map[string]bool, len(files))
+	done := make([]bool, len(files))
+
+	for len(ordered) < len(files) {
+		progress := false
+
+		for i, id := range ids {
+			if done[i] || !allEmitted(requires[i], emitted) {
+				continue
+			}
+
+			ordered = append(ordered, files[i])
+			emitted[id] = true
+			done[i] = true
+			progress = true
+
+			// Start again from the top so earlier files keep their place
+			break
+		}
+
+		if !progress {
+			var cycle []string
+			for i, file := range files {
+				if !done[i] {
+					cycle = append(cycle, file)
+				}
+			}
+
+			return nil, fmt.Errorf("dependency cycle between %s", strings.Join(cycle, ", "))
+		}
+	}
+
+	return ordered, nil
+}
+
+func allEmitted(ids []string, emitted map[string]bool) bool {
+	for _, id := range ids {
+		if !emitted[id] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,52 @@
+package deps
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildOrder(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	writeFile(t, path("main.usp"), `#USER_LIBRARY "helpers"`)
+	writeFile(t, path("helpers.usl"), `#USER_LIBRARY "strings"`)
+	writeFile(t, path("strings.usl"), `#CRESTRON_LIBRARY "Crestron Strings"`)
+	writeFile(t, path("standalone.usp"), "")
+	writeFile(t, path("direct.usp"), `#USER_LIBRARY "strings"`)
+
+	t.Run("libraries are built before their users", func(t *testing.T) {
+		files := []string{path("main.usp"), path("standalone.usp"), path("helpers.usl"), path("direct.usp"), path("strings.usl")}
+
+		ordered, err := BuildOrder(files, &Resolver{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{path("standalone.usp"), path("strings.usl"), path("helpers.usl"), path("main.usp"), path("direct.usp")}, ordered)
+	})
+
+	t.Run("independent files keep their order", func(t *testing.T) {
+		files := []string{path("standalone.usp"), path("strings.usl"), path("main.usp")}
+
+		ordered, err := BuildOrder(files, &Resolver{})
+		require.NoError(t, err)
+		assert.Equal(t, files, ordered)
+	})
+
+	t.Run("main depends on strings through a library outside the set", func(t *testing.T) {
+		files := []string{path("main.usp"), path("strings.usl")}
+
+		ordered, err := BuildOrder(files, &Resolver{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{path("strings.usl"), path("main.usp")}, ordered)
+	})
+
+	t.Run("cycles are an error", func(t *testing.T) {
+		writeFile(t, path("a.usl"), `#USER_LIBRARY "b"`)
+		writeFile(t, path("b.usl"), `#USER_LIBRARY "a"`)
+
+		_, err := BuildOrder([]string{path("standalone.usp"), path("a.usl"), path("b.usl")}, &Resolver{})
+		assert.EqualError(t, err, "dependency cycle between "+path("a.usl")+", "+path("b.usl"))
+	})
+}
@@ -0,0 +1,157 @@
+// Package deps discovers the dependencies of SIMPL+ source files.
+//
+// SIMPL+ modules pull in other code through compiler directives:
+//
+//	#USER_LIBRARY "name"                  user library name.usl
+//	#CRESTRON_LIBRARY "name"              library shipped with SIMPL (name.csl)
+//	#USER_SIMPLSHARP_LIBRARY "name"       SIMPL# library name.clz
+//	#CRESTRON_SIMPLSHARP_LIBRARY "name"   SIMPL# library shipped with SIMPL
+//	#INCLUDEPATH "path"                   extra directory to search for libraries
+//
+// The scanner extracts these directives (ignoring commented-out ones) so they
+// can be resolved to files and assembled into a dependency graph.
+package deps
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Kind identifies the type of a dependency directive
+type Kind string
+
+const (
+	KindUserLibrary               Kind = "user_library"
+	KindCrestronLibrary           Kind = "crestron_library"
+	KindUserSimplSharpLibrary     Kind = "user_simplsharp_library"
+	KindCrestronSimplSharpLibrary Kind = "crestron_simplsharp_library"
+	KindIncludePath               Kind = "include_path"
+)
+
+// directiveKinds maps directive keywords (upper case) to their kind
+var directiveKinds = map[string]Kind{
+	"#USER_LIBRARY":                KindUserLibrary,
+	"#CRESTRON_LIBRARY":            KindCrestronLibrary,
+	"#USER_SIMPLSHARP_LIBRARY":     KindUserSimplSharpLibrary,
+	"#CRESTRON_SIMPLSHARP_LIBRARY": KindCrestronSimplSharpLibrary,
+	"#INCLUDEPATH":                 KindIncludePath,
+}
+
+// Directive is a dependency directive found in a source file
+type Directive struct {
+	Kind  Kind
+	Value string
+	Line  int
+}
+
+// ScanFile extracts dependency directives from a source file
+func ScanFile(path string) ([]Directive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	directives, err := Scan(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", path, err)
+	}
+
+	return directives, nil
+}
+
+// Scan extracts dependency directives from SIMPL+ source.
+// Directives inside // line comments and /* */ block comments are ignored
+func Scan(r io.Reader) ([]Directive, error) {
+	var directives []Directive
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	inComment := false
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+
+		var line string
+		line, inComment = StripComments(scanner.Text(), inComment)
+
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// The keyword ends at any whitespace, so tab-separated values count
+		keyword, rest := line, ""
+		if i := strings.IndexFunc(line, unicode.IsSpace); i >= 0 {
+			keyword, rest = line[:i], line[i:]
+		}
+
+		kind, ok := directiveKinds[strings.ToUpper(keyword)]
+		if !ok {
+			continue
+		}
+
+		value := unquote(strings.TrimSpace(rest))
+		if value == "" {
+			continue
+		}
+
+		directives = append(directives, Directive{Kind: kind, Value: value, Line: lineNum})
+	}
+
+	return directives, scanner.Err()
+}
+
+// StripComments removes comments from a line of SIMPL+, tracking whether a block
+// comment continues onto the next line. String literals are respected
+func StripComments(line string, inComment bool) (string, bool) {
+	var b strings.Builder
+	inString := false
+
+	for i := 0; i < len(line); i++ {
+		switch {
+		case inComment:
+			if strings.HasPrefix(line[i:], "*/") {
+				inComment = false
+				i++
+			}
+		case inString:
+			b.WriteByte(line[i])
+			if line[i] == '"' {
+				inString = false
+			}
+		case line[i] == '"':
+			inString = true
+			b.WriteByte(line[i])
+		case strings.HasPrefix(line[i:], "//"):
+			return b.String(), false
+		case strings.HasPrefix(line[i:], "/*"):
+			inComment = true
+			i++
+		default:
+			b.WriteByte(line[i])
+		}
+	}
+
+	return b.String(), inComment
+}
+
+// unquote extracts the value of a quoted directive argument
+func unquote(s string) string {
+	if strings.HasPrefix(s, "\"") {
+		if end := strings.Index(s[1:], "\""); end >= 0 {
+			return s[1 : end+1]
+		}
+
+		return s[1:]
+	}
+
+	return s
+}
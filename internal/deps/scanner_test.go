@@ -0,0 +1,51 @@
+package deps
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScan(t *testing.T) {
+	source := `/*
+#USER_LIBRARY "commented_block"
+*/
+// #USER_LIBRARY "commented_line"
+#DEFAULT_VOLATILE
+#USER_LIBRARY "helpers"
+#user_library "lowercase"
+#CRESTRON_LIBRARY "Crestron Strings"
+#USER_SIMPLSHARP_LIBRARY "MyCompany.Driver"
+#CRESTRON_SIMPLSHARP_LIBRARY "Crestron.SimplSharp"
+#INCLUDEPATH "..\libs" // trailing comment
+#USER_LIBRARY ""
+/* inline */ #USER_LIBRARY "after_inline_comment"
+#USER_LIBRARY "has // slashes"
+#USER_LIBRARY	"tabbed"
+#CRESTRON_LIBRARY 	 "Crestron Mixed"
+`
+
+	directives, err := Scan(strings.NewReader(source))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Directive{
+		{Kind: KindUserLibrary, Value: "helpers", Line: 6},
+		{Kind: KindUserLibrary, Value: "lowercase", Line: 7},
+		{Kind: KindCrestronLibrary, Value: "Crestron Strings", Line: 8},
+		{Kind: KindUserSimplSharpLibrary, Value: "MyCompany.Driver", Line: 9},
+		{Kind: KindCrestronSimplSharpLibrary, Value: "Crestron.SimplSharp", Line: 10},
+		{Kind: KindIncludePath, Value: `..\libs`, Line: 11},
+		{Kind: KindUserLibrary, Value: "after_inline_comment", Line: 13},
+		{Kind: KindUserLibrary, Value: "has // slashes", Line: 14},
+		{Kind: KindUserLibrary, Value: "tabbed", Line: 15},
+		{Kind: KindCrestronLibrary, Value: "Crestron Mixed", Line: 16},
+	}, directives)
+}
+
+func TestScan_ExampleHeaderHasNoDependencies(t *testing.T) {
+	directives, err := ScanFile("../../example/example1.usp")
+	require.NoError(t, err)
+	assert.Empty(t, directives)
+}
@@ -0,0 +1,164 @@
+package deps
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Targets chosen by DetectTarget
+const (
+	// TargetAll is every series, for modules that work on all of them
+	TargetAll = "234"
+	// TargetNo2Series is Series 3 and 4, for modules that can't run on a 2-Series
+	TargetNo2Series = "34"
+)
+
+// seriesThreeKeywords are the SIMPL+ keywords and functions added with the
+// 3-Series, which the 2-Series compiler rejects
+var seriesThreeKeywords = map[string]bool{
+	"TRY":                 true,
+	"CATCH":               true,
+	"GETEXCEPTIONMESSAGE": true,
+	"REGISTEREVENT":       true,
+	"UNREGISTEREVENT":     true,
+	"REGISTERDELEGATE":    true,
+	"EVENTHANDLER":        true,
+	"DELEGATEPROPERTY":    true,
+	"THREADSAFE":          true,
+}
+
+// Detection is the target series a module can be compiled for
+type Detection struct {
+	// Target is TargetAll, or TargetNo2Series when something rules out the
+	// 2-Series
+	Target string
+
+	// Reasons lists what ruled out the 2-Series ("uses SIMPL# library
+	// Driver", "TRY at helpers.usl:12")
+	Reasons []string
+}
+
+// DetectTarget works out which series a module can target from its source
+// and the user libraries it uses: SIMPL# libraries and 3-Series constructs
+// such as TRY/CATCH and event handlers rule out the 2-Series
+func DetectTarget(sourceFile string, resolver *Resolver) (Detection, error) {
+	abs, err := filepath.Abs(sourceFile)
+	if err != nil {
+		return Detection{}, err
+	}
+
+	g, err := BuildGraph([]string{abs}, resolver, "")
+	if err != nil {
+		return Detection{}, err
+	}
+
+	files := []string{abs}
+	for _, n := range g.TransitiveDependencies(abs) {
+		if n.Kind == NodeLibrary && n.Path != "" && !n.Missing {
+			files = append(files, n.Path)
+		}
+	}
+
+	var d Detection
+	for _, file := range files {
+		reasons, err := seriesThreeUsage(file)
+		if err != nil {
+			return Detection{}, err
+		}
+
+		d.Reasons = append(d.Reasons, reasons...)
+	}
+
+	d.Target = TargetAll
+	if len(d.Reasons) > 0 {
+		d.Target = TargetNo2Series
+	}
+
+	return d, nil
+}
+
+// seriesThreeUsage returns the uses of SIMPL# libraries and 3-Series
+// keywords in a file, ignoring comments and string literals
+func seriesThreeUsage(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var reasons []string
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	inComment := false
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+
+		var line string
+		line, inComment = StripComments(scanner.Text(), inComment)
+
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "#") {
+			keyword, rest, _ := strings.Cut(trimmed, " ")
+
+			kind := directiveKinds[strings.ToUpper(keyword)]
+			if kind == KindUserSimplSharpLibrary || kind == KindCrestronSimplSharpLibrary {
+				reasons = append(reasons, fmt.Sprintf("uses SIMPL# library %s at %s:%d", unquote(strings.TrimSpace(rest)), filepath.Base(path), lineNum))
+			}
+
+			continue
+		}
+
+		for _, word := range identifiers(line) {
+			if seriesThreeKeywords[word] && !seen[word] {
+				seen[word] = true
+				reasons = append(reasons, fmt.Sprintf("%s at %s:%d", word, filepath.Base(path), lineNum))
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", path, err)
+	}
+
+	return reasons, nil
+}
+
+// identifiers returns the words of a line of code outside string literals,
+// upper cased since SIMPL+ is case-insensitive
+func identifiers(line string) []string {
+	var words []string
+
+	inString := false
+	start := -1
+
+	for i := 0; i <= len(line); i++ {
+		var c byte
+		if i < len(line) {
+			c = line[i]
+		}
+
+		isWord := !inString && (c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || start >= 0 && c >= '0' && c <= '9')
+
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			words = append(words, strings.ToUpper(line[start:i]))
+			start = -1
+		}
+
+		if c == '"' {
+			inString = !inString
+		}
+	}
+
+	return words
+}
@@ -0,0 +1,66 @@
+package deps
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectTarget(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("plain modules target every series", func(t *testing.T) {
+		path := filepath.Join(dir, "plain.usp")
+		writeFile(t, path, `#DEFAULT_VOLATILE
+#USER_LIBRARY "plain"
+// TRY is only mentioned in a comment
+/* RegisterEvent(a, b, c); */
+DIGITAL_INPUT go;
+STRING_OUTPUT message[50];
+PUSH go { message = "try again"; }
+`)
+		writeFile(t, filepath.Join(dir, "plain.usl"), "INTEGER_FUNCTION Tries() { RETURN (1); }\n")
+
+		d, err := DetectTarget(path, &Resolver{})
+		require.NoError(t, err)
+		assert.Equal(t, TargetAll, d.Target)
+		assert.Empty(t, d.Reasons)
+	})
+
+	t.Run("SIMPL# libraries rule out the 2-Series", func(t *testing.T) {
+		path := filepath.Join(dir, "sharp.usp")
+		writeFile(t, path, "#USER_SIMPLSHARP_LIBRARY \"Driver\"\n")
+
+		d, err := DetectTarget(path, &Resolver{})
+		require.NoError(t, err)
+		assert.Equal(t, TargetNo2Series, d.Target)
+		assert.Equal(t, []string{"uses SIMPL# library Driver at sharp.usp:1"}, d.Reasons)
+	})
+
+	t.Run("3-Series keywords in a library rule out the 2-Series", func(t *testing.T) {
+		path := filepath.Join(dir, "events.usp")
+		writeFile(t, path, "#USER_LIBRARY \"events\"\n")
+		writeFile(t, filepath.Join(dir, "events.usl"), `FUNCTION Start()
+{
+	try { RegisterEvent(driver, OnChange, HandleChange); }
+	catch { }
+}
+`)
+
+		d, err := DetectTarget(path, &Resolver{})
+		require.NoError(t, err)
+		assert.Equal(t, TargetNo2Series, d.Target)
+		assert.Equal(t, []string{"TRY at events.usl:3", "REGISTEREVENT at events.usl:3", "CATCH at events.usl:4"}, d.Reasons)
+	})
+
+	t.Run("unreadable source", func(t *testing.T) {
+		_, err := DetectTarget(filepath.Join(dir, "missing.usp"), &Resolver{})
+		assert.Error(t, err)
+	})
+}
+
+func TestIdentifiers(t *testing.T) {
+	assert.Equal(t, []string{"PRINT", "X1", "_Y"}, identifiers(`Print("try %d", x1, _y);`))
+}
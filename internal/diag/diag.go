@@ -0,0 +1,174 @@
+// Package diag extracts compiler diagnostics (errors and warnings with a line
+// number) from SIMPL+ compiler output and formats them as single lines that
+// editors can parse:
+//
+//	C:/Project/Room.usp(14): error 1300: Invalid constant
+//	C:/Project/Room.usp(14,5): warning 1401: Unused variable 'x'
+//
+// The format follows the MSBuild convention, so it is recognized by VS Code's
+// $msCompile problem matcher as well as by spc's own (see Pattern).
+//
+// The format is a contract for problem matchers and log scrapers, and is the
+// same whichever way the compiler reported the problem: the path uses
+// forward slashes, the column is left out when unknown, the severity is
+// "error" or "warning" in lower case, the code is a word (0 when the compiler
+// gave none) and the message is one line. Nothing else spc prints matches
+// Pattern, so it can be used to pick diagnostics out of a build log.
+package diag
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Severity is the severity of a diagnostic
+type Severity string
+
+const (
+	// SeverityError is a compile error
+	SeverityError Severity = "error"
+	// SeverityWarning is a compiler warning
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is an error or warning reported by the compiler
+type Diagnostic struct {
+	// File is the source file the diagnostic refers to
+	File string `json:"file"`
+
+	// Line is the 1-based line number
+	Line int `json:"line"`
+
+	// Column is the 1-based column, or 0 when the compiler did not report one
+	Column int `json:"column,omitempty"`
+
+	// Severity is error or warning
+	Severity Severity `json:"severity"`
+
+	// Code is the compiler's error number (e.g. "1300")
+	Code string `json:"code"`
+
+	// Message is the compiler's description
+	Message string `json:"message"`
+}
+
+// String formats the diagnostic as "file(line[,col]): severity code: message"
+// (see the package documentation for the guarantees it makes)
+func (d Diagnostic) String() string {
+	location := strconv.Itoa(max(d.Line, 0))
+	if d.Column > 0 {
+		location += "," + strconv.Itoa(d.Column)
+	}
+
+	severity := SeverityError
+	if strings.EqualFold(string(d.Severity), string(SeverityWarning)) {
+		severity = SeverityWarning
+	}
+
+	code := nonWord.ReplaceAllString(d.Code, "")
+	if code == "" {
+		code = "0"
+	}
+
+	message := strings.Join(strings.Fields(d.Message), " ")
+
+	// Windows paths reported by a compiler under Wine keep their backslashes
+	// on other hosts, so they are converted whatever the host
+	file := strings.ReplaceAll(filepath.ToSlash(d.File), `\`, "/")
+
+	return fmt.Sprintf("%s(%s): %s %s: %s", file, location, severity, code, message)
+}
+
+// nonWord matches the characters a diagnostic code can't contain
+var nonWord = regexp.MustCompile(`\W+`)
+
+// Pattern is a regular expression matching a formatted diagnostic, with the
+// groups file, line, column, severity, code and message (in that order). It
+// is written in the syntax shared by Go and JavaScript so it can be used in
+// editor problem matchers
+const Pattern = `^(.*)\((\d+)(?:,(\d+))?\): (error|warning) (\w+): (.*)$`
+
+// compilerLines match the ways SPlusCC reports a problem, e.g.
+// "Error 1300 (Line 14) - Invalid constant",
+// "Error 1300 (Line 14, Column 5) - Invalid constant",
+// "Line 14: Error 1300: Invalid constant" and, naming the file,
+// "C:\Project\Room.usp(14,5) : error 1300: Invalid constant" (which is also
+// the format spc prints). A group index of 0 means the line has no such part
+var compilerLines = []struct {
+	re                                          *regexp.Regexp
+	file, severity, code, line, column, message int
+}{
+	{regexp.MustCompile(`(?i)^\s*(error|warning)\s+(\d+)\s*\(\s*line\s+(\d+)\s*(?:,\s*col(?:umn)?\s+(\d+)\s*)?\)\s*[-:]?\s*(.*)$`), 0, 1, 2, 3, 4, 5},
+	{regexp.MustCompile(`(?i)^\s*line\s+(\d+)\s*[-:]\s*(error|warning)\s+(\d+)\s*[-:]?\s*(.*)$`), 0, 2, 3, 1, 0, 4},
+	{regexp.MustCompile(`(?i)^\s*(.+?)\s*\((\d+)(?:,(\d+))?\)\s*:\s*(error|warning)\s+(\w+)\s*:\s*(.*)$`), 1, 4, 5, 2, 3, 6},
+}
+
+// Parse extracts the diagnostics from the compiler output for a source file
+func Parse(file string, output string) []Diagnostic {
+	var diagnostics []Diagnostic
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		text := strings.TrimRight(scanner.Text(), "\r")
+
+		for _, l := range compilerLines {
+			m := l.re.FindStringSubmatch(text)
+			if m == nil {
+				continue
+			}
+
+			d := Diagnostic{
+				File:     file,
+				Severity: Severity(strings.ToLower(m[l.severity])),
+				Code:     m[l.code],
+				Message:  strings.TrimSpace(m[l.message]),
+			}
+
+			d.Line, _ = strconv.Atoi(m[l.line])
+			if l.column > 0 {
+				d.Column, _ = strconv.Atoi(m[l.column])
+			}
+
+			// A library's errors can name the library
+			if l.file > 0 {
+				if named := filepath.FromSlash(m[l.file]); filepath.IsAbs(named) {
+					d.File = named
+				}
+			}
+
+			diagnostics = append(diagnostics, d)
+
+			break
+		}
+	}
+
+	return diagnostics
+}
+
+// errorLine matches lines of a compile log that report a failure, including
+// those without a line number such as "Fatal Error: could not open file"
+var errorLine = regexp.MustCompile(`(?i)\b(errors?|fatal)\b`)
+
+// ErrorLines returns the last max lines of a compile log that report an
+// error, for showing a failure when the compiler printed nothing
+func ErrorLines(output string, max int) []string {
+	var lines []string
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text != "" && errorLine.MatchString(text) {
+			lines = append(lines, text)
+		}
+	}
+
+	if len(lines) > max {
+		lines = lines[len(lines)-max:]
+	}
+
+	return lines
+}
@@ -0,0 +1,108 @@
+package diag
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	output := "Compiling C:\\Project\\Room.usp\r\n" +
+		"   Error 1300 (Line 14) - Invalid constant\r\n" +
+		"Line 20: Warning 1401: Unused variable 'x'\n" +
+		"Compile failed\n"
+
+	got := Parse("/p/Room.usp", output)
+	assert.Equal(t, []Diagnostic{
+		{File: "/p/Room.usp", Line: 14, Severity: SeverityError, Code: "1300", Message: "Invalid constant"},
+		{File: "/p/Room.usp", Line: 20, Severity: SeverityWarning, Code: "1401", Message: "Unused variable 'x'"},
+	}, got)
+
+	assert.Empty(t, Parse("/p/Room.usp", "Compiling...\nDone\n"))
+}
+
+func TestParse_Formats(t *testing.T) {
+	lib := filepath.Join(t.TempDir(), "Lib.usl")
+
+	output := "Error 1300 (Line 14, Column 5) - Invalid constant\n" +
+		"warning 1401 (line 20, col 2): Unused variable\n" +
+		filepath.ToSlash(lib) + "(7,3) : Error 1002 : Missing semicolon\n" +
+		"Room.usp(9): error 1003: Relative paths keep the source file\n"
+
+	assert.Equal(t, []Diagnostic{
+		{File: "/p/Room.usp", Line: 14, Column: 5, Severity: SeverityError, Code: "1300", Message: "Invalid constant"},
+		{File: "/p/Room.usp", Line: 20, Column: 2, Severity: SeverityWarning, Code: "1401", Message: "Unused variable"},
+		{File: lib, Line: 7, Column: 3, Severity: SeverityError, Code: "1002", Message: "Missing semicolon"},
+		{File: "/p/Room.usp", Line: 9, Severity: SeverityError, Code: "1003", Message: "Relative paths keep the source file"},
+	}, Parse("/p/Room.usp", output))
+}
+
+func TestDiagnostic_String_Contract(t *testing.T) {
+	re := regexp.MustCompile(Pattern)
+
+	tests := []struct {
+		d    Diagnostic
+		want string
+	}{
+		{Diagnostic{File: `C:\Project\Room.usp`, Line: 14, Severity: "ERROR", Code: "1300", Message: "Invalid constant"}, "C:/Project/Room.usp(14): error 1300: Invalid constant"},
+		{Diagnostic{File: "/p/Room.usp", Line: 2, Column: 7, Severity: "Warning", Code: "W-1401", Message: "Unused\n  variable\t'x'  "}, "/p/Room.usp(2,7): warning W1401: Unused variable 'x'"},
+		{Diagnostic{File: "/p/Room.usp", Line: 3, Severity: "fatal", Message: "Out of memory"}, "/p/Room.usp(3): error 0: Out of memory"},
+	}
+
+	for _, tt := range tests {
+		got := tt.d.String()
+		assert.Equal(t, tt.want, got)
+		assert.Regexp(t, re, got)
+
+		// Printed diagnostics read back unchanged
+		parsed := Parse("/other.usp", got)
+		if assert.Len(t, parsed, 1) && filepath.IsAbs(filepath.FromSlash(tt.d.File)) {
+			assert.Equal(t, got, parsed[0].String())
+		}
+	}
+
+	assert.NotRegexp(t, re, "3 |     y = z + 1;", "excerpt lines never match")
+}
+
+func TestErrorLines(t *testing.T) {
+	output := "Compiling C:\\Project\\Room.usp\r\n" +
+		"   Error 1300 (Line 14) - Invalid constant\r\n" +
+		"Line 20: Warning 1401: Unused variable 'x'\n" +
+		"Fatal: could not open include file\n" +
+		"Total Errors: 2\n" +
+		"Compile failed\n"
+
+	assert.Equal(t, []string{
+		"Error 1300 (Line 14) - Invalid constant",
+		"Fatal: could not open include file",
+		"Total Errors: 2",
+	}, ErrorLines(output, 10))
+
+	assert.Equal(t, []string{"Total Errors: 2"}, ErrorLines(output, 1))
+	assert.Empty(t, ErrorLines("Compiling...\nDone\n", 10))
+}
+
+func TestDiagnostic_String(t *testing.T) {
+	d := Diagnostic{File: "/p/Room.usp", Line: 14, Severity: SeverityError, Code: "1300", Message: "Invalid constant"}
+	assert.Equal(t, "/p/Room.usp(14): error 1300: Invalid constant", d.String())
+
+	d.Column = 3
+	assert.Equal(t, "/p/Room.usp(14,3): error 1300: Invalid constant", d.String())
+}
+
+func TestPattern(t *testing.T) {
+	re := regexp.MustCompile(Pattern)
+
+	d := Diagnostic{File: "C:/My Project/Room.usp", Line: 14, Column: 3, Severity: SeverityWarning, Code: "1401", Message: "Unused (x): y"}
+	m := re.FindStringSubmatch(d.String())
+	require.NotNil(t, m)
+	assert.Equal(t, []string{"C:/My Project/Room.usp", "14", "3", "warning", "1401", "Unused (x): y"}, m[1:])
+
+	d.Column = 0
+	m = re.FindStringSubmatch(d.String())
+	require.NotNil(t, m)
+	assert.Equal(t, "", m[3])
+}
@@ -0,0 +1,99 @@
+package diag
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// excerptContext is the number of source lines shown before and after the
+// line of a diagnostic
+const excerptContext = 1
+
+// Excerpt returns the source lines around a diagnostic, numbered, with a
+// caret under the reported line (at the column when the compiler gave one,
+// else under the whole statement):
+//
+//	13 |     x = 1;
+//	14 |     y = z + 1;
+//	   |     ^^^^^^^^^^
+//	15 | }
+//
+// Returns an empty string when the file can't be read or has no such line
+func Excerpt(d Diagnostic) string {
+	if d.Line <= 0 {
+		return ""
+	}
+
+	f, err := os.Open(d.File)
+	if err != nil {
+		return ""
+	}
+
+	defer f.Close()
+
+	first, last := max(d.Line-excerptContext, 1), d.Line+excerptContext
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for n := 1; n <= last && scanner.Scan(); n++ {
+		if n >= first {
+			lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+		}
+	}
+
+	if first+len(lines)-1 < d.Line {
+		return ""
+	}
+
+	width := len(strconv.Itoa(first + len(lines) - 1))
+
+	var b strings.Builder
+	for i, line := range lines {
+		n := first + i
+		fmt.Fprintf(&b, "%*d | %s\n", width, n, line)
+
+		if n == d.Line {
+			fmt.Fprintf(&b, "%*s | %s\n", width, "", caret(line, d.Column))
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// caret returns the marker under a source line: one caret at a column, or
+// carets under everything but the indentation. Tabs before the marker are
+// kept so it lines up however wide the terminal shows them
+func caret(line string, column int) string {
+	start, end := len(line)-len(strings.TrimLeft(line, " \t")), len(strings.TrimRight(line, " \t"))
+	if column > 0 && column <= len(line)+1 {
+		start, end = column-1, column
+	}
+
+	if end <= start {
+		end = start + 1
+	}
+
+	indent := []byte(line[:min(start, len(line))])
+	for i, c := range indent {
+		if c != '\t' {
+			indent[i] = ' '
+		}
+	}
+
+	return string(indent) + strings.Repeat(" ", max(start-len(line), 0)) + strings.Repeat("^", end-start)
+}
+
+// WithExcerpt formats the diagnostic followed by the source excerpt, when
+// the source can be read
+func (d Diagnostic) WithExcerpt() string {
+	if excerpt := Excerpt(d); excerpt != "" {
+		return d.String() + "\n" + excerpt
+	}
+
+	return d.String()
+}
@@ -0,0 +1,56 @@
+package diag
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSource(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "Room.usp")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	return path
+}
+
+func TestExcerpt(t *testing.T) {
+	path := writeSource(t, "FUNCTION Main()\r\n{\r\n    y = z + 1;\r\n}\r\n")
+
+	d := Diagnostic{File: path, Line: 3, Severity: SeverityError, Code: "1001", Message: "Undefined variable"}
+	assert.Equal(t, ""+
+		"2 | {\n"+
+		"3 |     y = z + 1;\n"+
+		"  |     ^^^^^^^^^^\n"+
+		"4 | }", Excerpt(d))
+
+	d.Column = 9
+	assert.Equal(t, ""+
+		"2 | {\n"+
+		"3 |     y = z + 1;\n"+
+		"  |         ^\n"+
+		"4 | }", Excerpt(d))
+}
+
+func TestExcerpt_Edges(t *testing.T) {
+	path := writeSource(t, "\tx = 1;\n")
+
+	assert.Equal(t, "1 | \tx = 1;\n  | \t^^^^^^", Excerpt(Diagnostic{File: path, Line: 1}), "tabs are kept so the caret lines up")
+	assert.Empty(t, Excerpt(Diagnostic{File: path, Line: 5}))
+	assert.Empty(t, Excerpt(Diagnostic{File: path}))
+	assert.Empty(t, Excerpt(Diagnostic{File: filepath.Join(t.TempDir(), "missing.usp"), Line: 1}))
+}
+
+func TestDiagnostic_WithExcerpt(t *testing.T) {
+	path := writeSource(t, "x = 1;\n")
+
+	d := Diagnostic{File: path, Line: 1, Severity: SeverityError, Code: "1001", Message: "Undefined variable"}
+	assert.Equal(t, d.String()+"\n1 | x = 1;\n  | ^^^^^^", d.WithExcerpt())
+
+	d.Line = 2
+	assert.Equal(t, d.String(), d.WithExcerpt())
+}
@@ -0,0 +1,219 @@
+// Package discovery expands file, directory and glob arguments into the list
+// of SIMPL+ source files to operate on.
+package discovery
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/glob"
+)
+
+// SourceExtensions are the file extensions of compilable SIMPL+ sources
+var SourceExtensions = []string{".usp", ".usl"}
+
+// IsSource reports whether a path has a SIMPL+ source extension
+func IsSource(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range SourceExtensions {
+		if ext == e {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Filter limits which sources directory and glob arguments pick up
+type Filter struct {
+	// Include patterns; when non-empty a discovered file must match one
+	Include []string
+
+	// Exclude patterns; matching files (and directories) are skipped
+	Exclude []string
+
+	// BaseDir is the directory patterns containing a slash are relative to
+	BaseDir string
+
+	// Ignore is the project's .spcignore, or nil
+	Ignore *Ignore
+}
+
+// NewFilter returns a filter for a project's include and exclude patterns and
+// the .spcignore in baseDir (the working directory when empty)
+func NewFilter(include, exclude []string, baseDir string) (*Filter, error) {
+	ignore, err := LoadIgnore(baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Filter{Include: include, Exclude: exclude, BaseDir: baseDir, Ignore: ignore}, nil
+}
+
+// Allows reports whether a discovered file passes the filter
+func (f *Filter) Allows(path string) bool {
+	if f == nil {
+		return true
+	}
+
+	abs := absPath(path)
+
+	if f.Ignore.Ignores(abs, false) {
+		return false
+	}
+
+	if len(f.Include) > 0 && !glob.MatchAny(f.Include, f.BaseDir, abs) {
+		return false
+	}
+
+	return !glob.MatchAny(f.Exclude, f.BaseDir, abs)
+}
+
+// excludesDir reports whether a directory is excluded or ignored, so it need
+// not be walked
+func (f *Filter) excludesDir(path string) bool {
+	return f != nil && (glob.MatchAny(f.Exclude, f.BaseDir, absPath(path)) || f.Ignore.Ignores(path, true))
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+
+	return path
+}
+
+// Expand resolves each argument into source files:
+//   - existing files are used as-is
+//   - directories are walked recursively for SIMPL+ sources
+//   - glob patterns are matched against the filesystem (** matches any
+//     number of directories)
+//
+// The returned list preserves argument order and contains no duplicates
+func Expand(args []string) ([]string, error) {
+	return ExpandFiltered(args, nil)
+}
+
+// ExpandFiltered is like Expand, but files found by walking directories or
+// matching globs must pass the filter. Files named explicitly are always kept
+func ExpandFiltered(args []string, filter *Filter) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		switch {
+		case err == nil && info.IsDir():
+			found, err := walkDir(arg, filter)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, f := range found {
+				add(f)
+			}
+		case err == nil:
+			add(arg)
+		case hasGlobMeta(arg):
+			matches, err := globFiles(arg, filter)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, m := range matches {
+				if IsSource(m) && filter.Allows(m) {
+					add(m)
+				}
+			}
+		default:
+			// Leave missing files in place so the build reports them
+			add(arg)
+		}
+	}
+
+	return files, nil
+}
+
+// walkDir returns all SIMPL+ sources beneath dir that pass the filter, sorted
+// by path. SPlsWork output directories, hidden and excluded directories are skipped
+func walkDir(dir string, filter *Filter) ([]string, error) {
+	var files []string
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			name := d.Name()
+			if path != dir && (strings.EqualFold(name, "SPlsWork") || strings.HasPrefix(name, ".") || filter.excludesDir(path)) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if IsSource(path) && filter.Allows(path) {
+			files = append(files, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// globFiles matches a glob pattern against the filesystem. Patterns containing
+// ** are matched by walking the directory before the first wildcard
+func globFiles(pattern string, filter *Filter) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+
+		return matches, nil
+	}
+
+	slashed := path.Clean(filepath.ToSlash(pattern))
+	root := slashed[:strings.IndexAny(slashed, "*?[")]
+	root = root[:strings.LastIndex(root, "/")+1]
+	if root == "" {
+		root = "."
+	}
+
+	found, err := walkDir(filepath.FromSlash(root), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, f := range found {
+		if glob.Match(slashed, filepath.ToSlash(f)) {
+			matches = append(matches, f)
+		}
+	}
+
+	return matches, nil
+}
+
+// hasGlobMeta reports whether a path contains glob metacharacters
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
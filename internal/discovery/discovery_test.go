@@ -0,0 +1,120 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func touch(t *testing.T, path string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte("x"), 0o644))
+}
+
+func TestExpand(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, filepath.Join(dir, "a.usp"))
+	touch(t, filepath.Join(dir, "lib.usl"))
+	touch(t, filepath.Join(dir, "readme.txt"))
+	touch(t, filepath.Join(dir, "sub", "b.usp"))
+	touch(t, filepath.Join(dir, "SPlsWork", "junk.usp"))
+	touch(t, filepath.Join(dir, ".git", "hidden.usp"))
+
+	t.Run("directory is walked recursively", func(t *testing.T) {
+		files, err := Expand([]string{dir})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(dir, "a.usp"),
+			filepath.Join(dir, "lib.usl"),
+			filepath.Join(dir, "sub", "b.usp"),
+		}, files)
+	})
+
+	t.Run("glob pattern", func(t *testing.T) {
+		files, err := Expand([]string{filepath.Join(dir, "*.usp")})
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(dir, "a.usp")}, files)
+	})
+
+	t.Run("files are kept in order without duplicates", func(t *testing.T) {
+		a := filepath.Join(dir, "a.usp")
+		lib := filepath.Join(dir, "lib.usl")
+		files, err := Expand([]string{lib, a, lib})
+		require.NoError(t, err)
+		assert.Equal(t, []string{lib, a}, files)
+	})
+
+	t.Run("missing files are passed through", func(t *testing.T) {
+		files, err := Expand([]string{"missing.usp"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"missing.usp"}, files)
+	})
+}
+
+func TestIsSource(t *testing.T) {
+	assert.True(t, IsSource("a.usp"))
+	assert.True(t, IsSource("A.USL"))
+	assert.False(t, IsSource("a.ush"))
+	assert.False(t, IsSource("a.clz"))
+}
+
+func TestExpandFiltered(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, filepath.Join(dir, "a.usp"))
+	touch(t, filepath.Join(dir, "a_old.usp"))
+	touch(t, filepath.Join(dir, "lib.usl"))
+	touch(t, filepath.Join(dir, "archive", "b.usp"))
+	touch(t, filepath.Join(dir, "src", "c.usp"))
+
+	filter := &Filter{Exclude: []string{"archive/**", "*_old.usp"}, BaseDir: dir}
+
+	t.Run("excluded files and directories are skipped", func(t *testing.T) {
+		files, err := ExpandFiltered([]string{dir}, filter)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(dir, "a.usp"),
+			filepath.Join(dir, "lib.usl"),
+			filepath.Join(dir, "src", "c.usp"),
+		}, files)
+	})
+
+	t.Run("include limits discovered files", func(t *testing.T) {
+		files, err := ExpandFiltered([]string{dir}, &Filter{Include: []string{"src/**", "*.usl"}, BaseDir: dir})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(dir, "lib.usl"),
+			filepath.Join(dir, "src", "c.usp"),
+		}, files)
+	})
+
+	t.Run("globs are filtered", func(t *testing.T) {
+		files, err := ExpandFiltered([]string{filepath.Join(dir, "*.usp")}, filter)
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(dir, "a.usp")}, files)
+	})
+
+	t.Run("explicit files are always kept", func(t *testing.T) {
+		old := filepath.Join(dir, "a_old.usp")
+		files, err := ExpandFiltered([]string{old}, filter)
+		require.NoError(t, err)
+		assert.Equal(t, []string{old}, files)
+	})
+}
+
+func TestExpand_RecursiveGlob(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, filepath.Join(dir, "a.usp"))
+	touch(t, filepath.Join(dir, "src", "b.usp"))
+	touch(t, filepath.Join(dir, "src", "deep", "c.usp"))
+	touch(t, filepath.Join(dir, "src", "deep", "lib.usl"))
+
+	files, err := Expand([]string{filepath.Join(dir, "src", "**", "*.usp")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "src", "b.usp"),
+		filepath.Join(dir, "src", "deep", "c.usp"),
+	}, files)
+}
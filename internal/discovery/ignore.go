@@ -0,0 +1,139 @@
+package discovery
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/glob"
+)
+
+// IgnoreFile is the file in a project directory listing paths that directory
+// and glob arguments never pick up, in gitignore syntax
+const IgnoreFile = ".spcignore"
+
+// Ignore holds the rules of a .spcignore file
+type Ignore struct {
+	// dir is the directory the file is in, which anchored patterns are
+	// relative to
+	dir   string
+	rules []ignoreRule
+}
+
+// ignoreRule is one pattern line of a .spcignore file
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// LoadIgnore reads the .spcignore file in dir (the working directory when
+// empty). Returns nil when there is none
+func LoadIgnore(dir string) (*Ignore, error) {
+	dir = absPath(dir)
+
+	f, err := os.Open(filepath.Join(dir, IgnoreFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	ig := &Ignore{dir: dir}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			ig.rules = append(ig.rules, rule)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Join(dir, IgnoreFile), err)
+	}
+
+	return ig, nil
+}
+
+// parseIgnoreRule parses a line of a .spcignore file. Blank lines and
+// comments are not rules
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var r ignoreRule
+
+	switch {
+	case strings.HasPrefix(line, "!"):
+		r.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	// A slash anywhere but the end ties the pattern to the file's directory
+	r.anchored = strings.Contains(line, "/")
+	r.pattern = strings.TrimPrefix(line, "/")
+
+	return r, r.pattern != ""
+}
+
+// Ignores reports whether a path is ignored, either itself or because a
+// directory it is in is. As in git, the last matching rule wins, and a file
+// in an ignored directory can't be brought back by a negated rule
+func (ig *Ignore) Ignores(path string, isDir bool) bool {
+	if ig == nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(ig.dir, absPath(path))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := 1; i < len(parts); i++ {
+		if ig.matches(parts[:i], true) {
+			return true
+		}
+	}
+
+	return ig.matches(parts, isDir)
+}
+
+// matches applies the rules to a path given as its segments below the
+// ignore file's directory
+func (ig *Ignore) matches(parts []string, isDir bool) bool {
+	ignored := false
+
+	for _, r := range ig.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+
+		name := parts[len(parts)-1]
+		if r.anchored {
+			name = strings.Join(parts, "/")
+		}
+
+		if glob.Match(r.pattern, name) {
+			ignored = !r.negate
+		}
+	}
+
+	return ignored
+}
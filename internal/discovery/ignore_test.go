@@ -0,0 +1,101 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeIgnore(t *testing.T, dir, content string) *Ignore {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, IgnoreFile), []byte(content), 0o644))
+
+	ig, err := LoadIgnore(dir)
+	require.NoError(t, err)
+	require.NotNil(t, ig)
+
+	return ig
+}
+
+func TestIgnore_Ignores(t *testing.T) {
+	dir := t.TempDir()
+	ig := writeIgnore(t, dir, `# Templates are copied, never built
+templates/
+/archive
+*_old.usp
+!keep_old.usp
+src/**/scratch.usp
+\#literal.usp
+`)
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"templates", true, true},
+		{"templates/room.usp", false, true},
+		{"sub/templates/room.usp", false, true},
+		{"templates.usp", false, false},
+		{"archive/2019/room.usp", false, true},
+		{"sub/archive/room.usp", false, false},
+		{"room_old.usp", false, true},
+		{"sub/room_old.usp", false, true},
+		{"keep_old.usp", false, false},
+		{"src/a/b/scratch.usp", false, true},
+		{"scratch.usp", false, false},
+		{"#literal.usp", false, true},
+		{"room.usp", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, ig.Ignores(filepath.Join(dir, filepath.FromSlash(tt.path)), tt.isDir))
+		})
+	}
+
+	// Paths outside the project are never ignored
+	assert.False(t, ig.Ignores(filepath.Join(filepath.Dir(dir), "templates", "room.usp"), false))
+}
+
+func TestIgnore_NegatedFileInIgnoredDirectory(t *testing.T) {
+	dir := t.TempDir()
+	ig := writeIgnore(t, dir, "archive/\n!archive/room.usp\n")
+
+	assert.True(t, ig.Ignores(filepath.Join(dir, "archive", "room.usp"), false))
+}
+
+func TestLoadIgnore_Missing(t *testing.T) {
+	ig, err := LoadIgnore(t.TempDir())
+	require.NoError(t, err)
+	assert.Nil(t, ig)
+	assert.False(t, ig.Ignores("room.usp", false))
+}
+
+func TestExpandFiltered_SpcIgnore(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, filepath.Join(dir, "room.usp"))
+	touch(t, filepath.Join(dir, "room_old.usp"))
+	touch(t, filepath.Join(dir, "templates", "blank.usp"))
+	writeIgnore(t, dir, "templates/\n*_old.usp\n")
+
+	filter, err := NewFilter(nil, nil, dir)
+	require.NoError(t, err)
+
+	files, err := ExpandFiltered([]string{dir}, filter)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "room.usp")}, files)
+
+	files, err = ExpandFiltered([]string{filepath.Join(dir, "**", "*.usp")}, filter)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "room.usp")}, files)
+
+	// Files named explicitly are always built
+	old := filepath.Join(dir, "room_old.usp")
+	files, err = ExpandFiltered([]string{old}, filter)
+	require.NoError(t, err)
+	assert.Equal(t, []string{old}, files)
+}
@@ -0,0 +1,409 @@
+// Package docs generates reference pages for SIMPL+ modules and libraries.
+//
+// The interface is read from the source: #SYMBOL_NAME, the #HELP_BEGIN ...
+// #HELP_END text, input, output and parameter declarations, parameter
+// properties and function declarations. Structured comments document it:
+//
+//	/// Summary of the module, before #SYMBOL_NAME or a blank line
+//
+//	/// Doc comment of the declaration or function below
+//	DIGITAL_INPUT Poll;
+//	STRING_OUTPUT To_Device$; // or a comment after it
+//
+//	/// Sends a command to the device
+//	/// @param command the command, without the delimiter
+//	/// @returns the number of bytes sent
+//	INTEGER_FUNCTION Send(STRING command)
+//
+// Modules whose source is not available are documented from their .ush
+// header, without comments.
+package docs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/Norgate-AV/spc/internal/deps"
+	"github.com/Norgate-AV/spc/internal/ush"
+)
+
+// Signal is a documented input, output or parameter
+type Signal struct {
+	// Name as declared, including any array size
+	Name string `json:"name"`
+
+	// Type of signal (Digital, Analog, Serial or Buffer) or parameter value
+	// (Integer, String, ...)
+	Type string `json:"type"`
+
+	// Default value of a parameter, from its properties
+	Default string `json:"default,omitempty"`
+
+	// Description from the doc comment, or a parameter's short description
+	Description string `json:"description,omitempty"`
+}
+
+// Arg documents a function parameter
+type Arg struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Function is a documented function
+type Function struct {
+	Name string `json:"name"`
+
+	// Returns is the return type (e.g. INTEGER), empty for FUNCTION
+	Returns string `json:"returns,omitempty"`
+
+	// Params is the parameter list as declared
+	Params string `json:"params,omitempty"`
+
+	// Description from the doc comment, without @param and @returns
+	Description string `json:"description,omitempty"`
+
+	// Args are the @param lines of the doc comment
+	Args []Arg `json:"args,omitempty"`
+
+	// ReturnsDoc is the @returns line of the doc comment
+	ReturnsDoc string `json:"returns_doc,omitempty"`
+}
+
+// Module is the documentation of one module or library
+type Module struct {
+	// Name of the symbol, or of the file when it has none
+	Name string `json:"name"`
+
+	// File the documentation was read from
+	File string `json:"file"`
+
+	// Library is set for .usl files
+	Library bool `json:"library,omitempty"`
+
+	// Summary from the doc comment at the top of the file
+	Summary string `json:"summary,omitempty"`
+
+	// Help is the #HELP_BEGIN ... #HELP_END text
+	Help string `json:"help,omitempty"`
+
+	Inputs     []Signal   `json:"inputs,omitempty"`
+	Outputs    []Signal   `json:"outputs,omitempty"`
+	Parameters []Signal   `json:"parameters,omitempty"`
+	Functions  []Function `json:"functions,omitempty"`
+}
+
+// declKind is the list a declaration belongs to and its type. Strings
+// declare their length after the name (and any array size)
+type declKind struct {
+	list, typ string
+	sized     bool
+}
+
+// declKinds maps declaration keywords (upper case) to their kind
+var declKinds = map[string]declKind{
+	"DIGITAL_INPUT":                 {"input", "Digital", false},
+	"ANALOG_INPUT":                  {"input", "Analog", false},
+	"STRING_INPUT":                  {"input", "Serial", true},
+	"BUFFER_INPUT":                  {"input", "Buffer", true},
+	"DIGITAL_OUTPUT":                {"output", "Digital", false},
+	"ANALOG_OUTPUT":                 {"output", "Analog", false},
+	"STRING_OUTPUT":                 {"output", "Serial", false},
+	"INTEGER_PARAMETER":             {"parameter", "Integer", false},
+	"SIGNED_INTEGER_PARAMETER":      {"parameter", "Signed Integer", false},
+	"LONG_INTEGER_PARAMETER":        {"parameter", "Long Integer", false},
+	"SIGNED_LONG_INTEGER_PARAMETER": {"parameter", "Signed Long Integer", false},
+	"STRING_PARAMETER":              {"parameter", "String", true},
+}
+
+// skipName is the placeholder for a gap between signals on the symbol
+const skipName = "_SKIP_"
+
+// propertyLine matches a parameter property assignment
+var propertyLine = regexp.MustCompile(`(?i)^(propShortDescription|propDefaultValue)\s*=\s*(.*?)\s*;?$`)
+
+// ParseFile documents a source file
+func ParseFile(path string) (*Module, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	m, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	m.File = path
+	m.Library = strings.EqualFold(filepath.Ext(path), ".usl")
+
+	if m.Name == "" {
+		m.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	return m, nil
+}
+
+// FromHeader documents a module from its .ush header, for when its source
+// is not available
+func FromHeader(h *ush.Module) *Module {
+	m := &Module{Name: h.Name, File: h.Header}
+
+	signals := func(from []ush.Signal) []Signal {
+		var to []Signal
+		for _, s := range from {
+			to = append(to, Signal{Name: s.Name, Type: s.Type})
+		}
+
+		return to
+	}
+
+	m.Inputs, m.Outputs, m.Parameters = signals(h.Inputs), signals(h.Outputs), signals(h.Parameters)
+
+	for _, f := range h.Functions {
+		m.Functions = append(m.Functions, Function{Name: f.Name, Returns: f.Returns, Params: f.Params})
+	}
+
+	return m
+}
+
+// parser holds the state of parsing a source file
+type parser struct {
+	m *Module
+
+	// doc is the /// comment waiting for a declaration
+	doc []string
+
+	// decl is a declaration continued on the next line
+	decl string
+
+	// properties are the names of the parameters in a properties block
+	properties []string
+
+	// inHelp is set between #HELP_BEGIN and #HELP_END
+	inHelp bool
+	help   []string
+
+	// declared is set once a declaration has been seen, after which a doc
+	// comment cannot be the summary
+	declared bool
+}
+
+// Parse documents SIMPL+ source
+func Parse(r io.Reader) (*Module, error) {
+	p := &parser{m: &Module{}}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	inComment := false
+
+	for scanner.Scan() {
+		raw := strings.TrimRight(scanner.Text(), "\r")
+
+		if p.inHelp {
+			p.helpLine(raw)
+			continue
+		}
+
+		if trimmed := strings.TrimSpace(raw); !inComment && strings.HasPrefix(trimmed, "///") {
+			p.doc = append(p.doc, strings.TrimSpace(strings.TrimPrefix(trimmed, "///")))
+			continue
+		}
+
+		var code string
+		code, inComment = deps.StripComments(raw, inComment)
+
+		p.line(strings.TrimSpace(code), trailingComment(raw, code))
+	}
+
+	p.m.Help = strings.TrimSpace(strings.Join(p.help, "\n"))
+
+	return p.m, scanner.Err()
+}
+
+// helpLine handles a line of the help text
+func (p *parser) helpLine(raw string) {
+	if strings.EqualFold(strings.TrimSpace(raw), "#HELP_END") {
+		p.inHelp = false
+		return
+	}
+
+	p.help = append(p.help, raw)
+}
+
+// line handles a line of code with its trailing comment
+func (p *parser) line(code, comment string) {
+	if p.decl != "" {
+		p.declaration(p.decl+" "+code, comment)
+		return
+	}
+
+	keyword, rest := cutKeyword(code)
+
+	switch {
+	case code == "":
+		p.endDoc()
+	case keyword == "#SYMBOL_NAME":
+		p.m.Name = strings.Trim(strings.TrimSpace(rest), `"`)
+		p.endDoc()
+	case keyword == "#HELP_BEGIN":
+		p.inHelp = true
+		p.endDoc()
+	case keyword == "#BEGIN_PARAMETER_PROPERTIES":
+		p.properties = strings.Split(rest, ",")
+		p.doc = nil
+	case keyword == "#END_PARAMETER_PROPERTIES":
+		p.properties = nil
+	case p.properties != nil:
+		p.property(code)
+	case declKinds[keyword].list != "":
+		p.declaration(code, comment)
+	default:
+		if fn, ok := ush.ParseFunction(code); ok {
+			p.function(fn)
+		}
+
+		p.doc = nil
+	}
+}
+
+// endDoc ends a doc comment not followed by a declaration. The first one
+// before any declaration is the summary of the module
+func (p *parser) endDoc() {
+	if len(p.doc) > 0 && !p.declared && p.m.Summary == "" {
+		p.m.Summary = strings.Join(p.doc, "\n")
+	}
+
+	p.doc = nil
+}
+
+// declaration adds the signals of a declaration, which may continue onto
+// the next line until its semicolon
+func (p *parser) declaration(code, comment string) {
+	if !strings.Contains(code, ";") {
+		p.decl = code
+		return
+	}
+
+	p.decl = ""
+	p.declared = true
+
+	keyword, rest := cutKeyword(code)
+	kind := declKinds[keyword]
+
+	description := strings.Join(p.doc, "\n")
+	if description == "" {
+		description = comment
+	}
+
+	p.doc = nil
+
+	rest, _, _ = strings.Cut(rest, ";")
+	for _, name := range strings.Split(rest, ",") {
+		name = strings.Join(strings.Fields(name), "")
+		if kind.sized {
+			if i := strings.LastIndex(name, "["); i > 0 {
+				name = name[:i]
+			}
+		}
+
+		if name == "" || strings.EqualFold(name, skipName) {
+			continue
+		}
+
+		s := Signal{Name: name, Type: kind.typ, Description: description}
+
+		switch kind.list {
+		case "input":
+			p.m.Inputs = append(p.m.Inputs, s)
+		case "output":
+			p.m.Outputs = append(p.m.Outputs, s)
+		default:
+			p.m.Parameters = append(p.m.Parameters, s)
+		}
+	}
+}
+
+// property applies a parameter property to the parameters of the block
+func (p *parser) property(code string) {
+	match := propertyLine.FindStringSubmatch(code)
+	if match == nil {
+		return
+	}
+
+	value := match[2]
+
+	for _, name := range p.properties {
+		name = strings.TrimSpace(name)
+
+		for i := range p.m.Parameters {
+			param := &p.m.Parameters[i]
+			if !strings.EqualFold(baseName(param.Name), name) {
+				continue
+			}
+
+			if strings.EqualFold(match[1], "propDefaultValue") {
+				param.Default = value
+			} else if param.Description == "" {
+				param.Description = strings.Trim(value, `"`)
+			}
+		}
+	}
+}
+
+// function adds a function with its doc comment
+func (p *parser) function(fn ush.Function) {
+	p.declared = true
+
+	f := Function{Name: fn.Name, Returns: fn.Returns, Params: fn.Params}
+
+	var description []string
+	for _, line := range p.doc {
+		switch {
+		case strings.HasPrefix(line, "@param "):
+			name, text, _ := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, "@param ")), " ")
+			f.Args = append(f.Args, Arg{Name: name, Description: strings.TrimSpace(text)})
+		case strings.HasPrefix(line, "@returns "):
+			f.ReturnsDoc = strings.TrimSpace(strings.TrimPrefix(line, "@returns "))
+		default:
+			description = append(description, line)
+		}
+	}
+
+	f.Description = strings.TrimSpace(strings.Join(description, "\n"))
+	p.m.Functions = append(p.m.Functions, f)
+}
+
+// cutKeyword splits a line of code into its first word, upper cased, and
+// the rest
+func cutKeyword(code string) (string, string) {
+	i := strings.IndexFunc(code, unicode.IsSpace)
+	if i < 0 {
+		return strings.ToUpper(code), ""
+	}
+
+	return strings.ToUpper(code[:i]), strings.TrimSpace(code[i:])
+}
+
+// trailingComment returns the // comment after the code on a line
+func trailingComment(raw, code string) string {
+	if len(code) > len(raw) || !strings.HasPrefix(raw[len(code):], "//") {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimLeft(raw[len(code):], "/"))
+}
+
+// baseName returns a declared name without its array size
+func baseName(name string) string {
+	name, _, _ = strings.Cut(name, "[")
+	return name
+}
@@ -0,0 +1,119 @@
+package docs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/ush"
+)
+
+const source = `/// Drives a projector over RS-232
+/// Supports power and input selection
+
+#SYMBOL_NAME "Projector Driver"
+#HELP_BEGIN
+Connect To_Device$ to the COM port
+#HELP_END
+
+/*******************************************************************************************
+  Inputs and Outputs
+*******************************************************************************************/
+/// Polls the device while high
+DIGITAL_INPUT Poll, _SKIP_, Power_On;
+STRING_INPUT	Send_Command$[255]; // sent followed by a carriage return
+BUFFER_INPUT From_Device$[1000];
+DIGITAL_INPUT Input[4];
+
+// not a doc comment
+STRING_OUTPUT To_Device$,
+              Response$[2];
+
+STRING_PARAMETER Poll_Command$[32];
+INTEGER_PARAMETER Poll_Interval;
+
+#BEGIN_PARAMETER_PROPERTIES Poll_Command$, Poll_Interval
+    propShortDescription = "Shared description";
+#END_PARAMETER_PROPERTIES
+
+#BEGIN_PARAMETER_PROPERTIES Poll_Interval
+    propDefaultValue = 5s;
+#END_PARAMETER_PROPERTIES
+
+/// Sends a command to the device
+/// followed by the delimiter
+/// @param command the command
+/// @returns the length sent
+INTEGER_FUNCTION Send(STRING command)
+{
+}
+
+// FUNCTION Commented()
+FUNCTION Main()
+{
+}
+`
+
+func TestParse(t *testing.T) {
+	m, err := Parse(strings.NewReader(source))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Projector Driver", m.Name)
+	assert.Equal(t, "Drives a projector over RS-232\nSupports power and input selection", m.Summary)
+	assert.Equal(t, "Connect To_Device$ to the COM port", m.Help)
+
+	assert.Equal(t, []Signal{
+		{Name: "Poll", Type: "Digital", Description: "Polls the device while high"},
+		{Name: "Power_On", Type: "Digital", Description: "Polls the device while high"},
+		{Name: "Send_Command$", Type: "Serial", Description: "sent followed by a carriage return"},
+		{Name: "From_Device$", Type: "Buffer"},
+		{Name: "Input[4]", Type: "Digital"},
+	}, m.Inputs)
+
+	assert.Equal(t, []Signal{
+		{Name: "To_Device$", Type: "Serial"},
+		{Name: "Response$[2]", Type: "Serial"},
+	}, m.Outputs, "declarations continue onto the next line and plain comments are not docs")
+
+	assert.Equal(t, []Signal{
+		{Name: "Poll_Command$", Type: "String", Description: "Shared description"},
+		{Name: "Poll_Interval", Type: "Integer", Default: "5s", Description: "Shared description"},
+	}, m.Parameters)
+
+	assert.Equal(t, []Function{
+		{
+			Name:        "Send",
+			Returns:     "INTEGER",
+			Params:      "STRING command",
+			Description: "Sends a command to the device\nfollowed by the delimiter",
+			Args:        []Arg{{Name: "command", Description: "the command"}},
+			ReturnsDoc:  "the length sent",
+		},
+		{Name: "Main"},
+	}, m.Functions)
+}
+
+func TestParse_SummaryOnlyBeforeDeclarations(t *testing.T) {
+	m, err := Parse(strings.NewReader("DIGITAL_INPUT Poll;\n\n/// Orphaned\n\nFUNCTION Main()\n"))
+	require.NoError(t, err)
+
+	assert.Empty(t, m.Summary)
+}
+
+func TestFromHeader(t *testing.T) {
+	m := FromHeader(&ush.Module{
+		Name:       "Projector",
+		Header:     "Projector.ush",
+		Inputs:     []ush.Signal{{Index: 1, Name: "Poll", Type: "Digital"}},
+		Parameters: []ush.Signal{{Index: 2, Name: "Poll_Interval", Type: "Constant"}},
+	})
+
+	assert.Equal(t, &Module{
+		Name:       "Projector",
+		File:       "Projector.ush",
+		Inputs:     []Signal{{Name: "Poll", Type: "Digital"}},
+		Parameters: []Signal{{Name: "Poll_Interval", Type: "Constant"}},
+	}, m)
+}
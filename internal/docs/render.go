@@ -0,0 +1,344 @@
+package docs
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Output formats
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// extensions are the page file extensions of each format
+var extensions = map[string]string{
+	FormatMarkdown: ".md",
+	FormatHTML:     ".html",
+}
+
+// Generate writes a page per module and an index page into dir and returns
+// the paths written, index last
+func Generate(modules []*Module, dir, format string) ([]string, error) {
+	ext, ok := extensions[format]
+	if !ok {
+		return nil, fmt.Errorf("invalid format: %s (expected markdown or html)", format)
+	}
+
+	sorted := append([]*Module(nil), modules...)
+	sort.SliceStable(sorted, func(i, j int) bool { return strings.ToLower(sorted[i].Name) < strings.ToLower(sorted[j].Name) })
+
+	pages := make(map[string]string)
+	for _, m := range sorted {
+		page := Page(m, format)
+		if other, ok := pages[page]; ok {
+			return nil, fmt.Errorf("%s and %s would both be documented in %s", other, m.File, page)
+		}
+
+		pages[page] = m.File
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	var paths []string
+
+	for _, m := range sorted {
+		path := filepath.Join(dir, Page(m, format))
+
+		err := writeFile(path, func(w io.Writer) error {
+			if format == FormatHTML {
+				return WriteHTML(w, m)
+			}
+
+			return WriteMarkdown(w, m)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, path)
+	}
+
+	index := filepath.Join(dir, "index"+ext)
+	if err := writeFile(index, func(w io.Writer) error { return WriteIndex(w, sorted, format) }); err != nil {
+		return nil, err
+	}
+
+	return append(paths, index), nil
+}
+
+// Page returns the file name of a module's page: its source file name with
+// the format's extension, and a suffix for libraries (Lib.usl is Lib.usl.md)
+func Page(m *Module, format string) string {
+	base := filepath.Base(m.File)
+	if !m.Library {
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	return base + extensions[format]
+}
+
+// writeFile writes a file through a buffered writer
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// WriteMarkdown writes a module's reference page as Markdown
+func WriteMarkdown(w io.Writer, m *Module) error {
+	b := &strings.Builder{}
+
+	fmt.Fprintf(b, "# %s\n\n", m.Name)
+
+	kind := "Module"
+	if m.Library {
+		kind = "Library"
+	}
+
+	fmt.Fprintf(b, "%s `%s`\n", kind, filepath.ToSlash(filepath.Base(m.File)))
+
+	if m.Summary != "" {
+		fmt.Fprintf(b, "\n%s\n", m.Summary)
+	}
+
+	if m.Help != "" {
+		fmt.Fprintf(b, "\n## Help\n\n```text\n%s\n```\n", m.Help)
+	}
+
+	signalTable(b, "Inputs", m.Inputs, false)
+	signalTable(b, "Outputs", m.Outputs, false)
+	signalTable(b, "Parameters", m.Parameters, true)
+
+	if len(m.Functions) > 0 {
+		b.WriteString("\n## Functions\n")
+
+		for _, f := range m.Functions {
+			fmt.Fprintf(b, "\n### %s\n\n```text\n%s\n```\n", f.Name, Signature(f))
+
+			if f.Description != "" {
+				fmt.Fprintf(b, "\n%s\n", f.Description)
+			}
+
+			if len(f.Args) > 0 {
+				b.WriteString("\n")
+				for _, arg := range f.Args {
+					fmt.Fprintf(b, "- `%s`: %s\n", arg.Name, arg.Description)
+				}
+			}
+
+			if f.ReturnsDoc != "" {
+				fmt.Fprintf(b, "\nReturns %s\n", f.ReturnsDoc)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// signalTable writes a Markdown table of signals, if there are any
+func signalTable(b *strings.Builder, title string, signals []Signal, withDefault bool) {
+	if len(signals) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "\n## %s\n\n", title)
+
+	if withDefault {
+		b.WriteString("| Name | Type | Default | Description |\n| --- | --- | --- | --- |\n")
+	} else {
+		b.WriteString("| Name | Type | Description |\n| --- | --- | --- |\n")
+	}
+
+	for _, s := range signals {
+		cells := []string{"`" + s.Name + "`", s.Type}
+		if withDefault {
+			cells = append(cells, cell(s.Default))
+		}
+
+		cells = append(cells, cell(s.Description))
+		fmt.Fprintf(b, "| %s |\n", strings.Join(cells, " | "))
+	}
+}
+
+// cell escapes text for a Markdown table cell
+func cell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", "<br>").Replace(s)
+}
+
+// Signature returns a function's declaration
+func Signature(f Function) string {
+	keyword := "FUNCTION"
+	if f.Returns != "" {
+		keyword = f.Returns + "_FUNCTION"
+	}
+
+	return fmt.Sprintf("%s %s(%s)", keyword, f.Name, f.Params)
+}
+
+// WriteIndex writes the page listing the modules
+func WriteIndex(w io.Writer, modules []*Module, format string) error {
+	if format == FormatHTML {
+		return indexTemplate.Execute(w, indexData{Modules: modules, Format: format})
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("# SIMPL+ Reference\n\n| Module | File | Summary |\n| --- | --- | --- |\n")
+
+	for _, m := range modules {
+		fmt.Fprintf(b, "| [%s](%s) | `%s` | %s |\n", cell(m.Name), Page(m, format), filepath.Base(m.File), cell(firstLine(m.Summary)))
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// firstLine returns the first line of text
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// WriteHTML writes a module's reference page as HTML
+func WriteHTML(w io.Writer, m *Module) error {
+	return pageTemplate.Execute(w, m)
+}
+
+// indexData is what the HTML index is rendered with
+type indexData struct {
+	Modules []*Module
+	Format  string
+}
+
+// section is a table of signals on an HTML page
+type section struct {
+	Title   string
+	Signals []Signal
+	Default bool
+}
+
+// sections returns the non-empty signal tables of a module
+func sections(m *Module) []section {
+	var all []section
+	for _, s := range []section{{"Inputs", m.Inputs, false}, {"Outputs", m.Outputs, false}, {"Parameters", m.Parameters, true}} {
+		if len(s.Signals) > 0 {
+			all = append(all, s)
+		}
+	}
+
+	return all
+}
+
+var funcs = template.FuncMap{
+	"sections":  sections,
+	"base":      filepath.Base,
+	"signature": Signature,
+	"firstLine": firstLine,
+	"page":      Page,
+}
+
+const style = `<style>
+body { font-family: system-ui, sans-serif; max-width: 60rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5rem; }
+th, td { border: 1px solid #ddd; padding: 0.3rem 0.6rem; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+code, pre { font-family: ui-monospace, monospace; }
+pre { background: #f4f4f4; padding: 0.6rem; overflow-x: auto; }
+td { white-space: pre-line; }
+</style>`
+
+var pageTemplate = template.Must(template.New("page").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+` + style + `
+</head>
+<body>
+<p><a href="index.html">Index</a></p>
+<h1>{{.Name}}</h1>
+<p>{{if .Library}}Library{{else}}Module{{end}} <code>{{base .File}}</code></p>
+{{- with .Summary}}
+<p style="white-space: pre-line">{{.}}</p>
+{{- end}}
+{{- with .Help}}
+<h2>Help</h2>
+<pre>{{.}}</pre>
+{{- end}}
+{{- range sections .}}
+<h2>{{.Title}}</h2>
+<table>
+<tr><th>Name</th><th>Type</th>{{if .Default}}<th>Default</th>{{end}}<th>Description</th></tr>
+{{- $default := .Default}}
+{{- range .Signals}}
+<tr><td><code>{{.Name}}</code></td><td>{{.Type}}</td>{{if $default}}<td>{{.Default}}</td>{{end}}<td>{{.Description}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- with .Functions}}
+<h2>Functions</h2>
+{{- range .}}
+<h3 id="{{.Name}}">{{.Name}}</h3>
+<pre>{{signature .}}</pre>
+{{- with .Description}}
+<p style="white-space: pre-line">{{.}}</p>
+{{- end}}
+{{- with .Args}}
+<ul>
+{{- range .}}
+<li><code>{{.Name}}</code>: {{.Description}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- with .ReturnsDoc}}
+<p>Returns {{.}}</p>
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+var indexTemplate = template.Must(template.New("index").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>SIMPL+ Reference</title>
+` + style + `
+</head>
+<body>
+<h1>SIMPL+ Reference</h1>
+<table>
+<tr><th>Module</th><th>File</th><th>Summary</th></tr>
+{{- range .Modules}}
+<tr><td><a href="{{page . $.Format}}">{{.Name}}</a></td><td><code>{{base .File}}</code></td><td>{{firstLine .Summary}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
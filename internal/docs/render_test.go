@@ -0,0 +1,79 @@
+package docs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testModules() []*Module {
+	return []*Module{
+		{
+			Name:       "Projector",
+			File:       filepath.Join("src", "Projector.usp"),
+			Summary:    "Drives a projector | over RS-232\nSecond line",
+			Inputs:     []Signal{{Name: "Poll", Type: "Digital", Description: "Polls <the> device"}},
+			Parameters: []Signal{{Name: "Poll_Interval", Type: "Integer", Default: "5s"}},
+			Functions: []Function{{
+				Name: "Send", Returns: "INTEGER", Params: "STRING command",
+				Args: []Arg{{Name: "command", Description: "the command"}}, ReturnsDoc: "the length sent",
+			}},
+		},
+		{Name: "Helpers", File: filepath.Join("src", "Helpers.usl"), Library: true},
+	}
+}
+
+func TestGenerate_Markdown(t *testing.T) {
+	dir := t.TempDir()
+
+	paths, err := Generate(testModules(), dir, FormatMarkdown)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "Helpers.usl.md"),
+		filepath.Join(dir, "Projector.md"),
+		filepath.Join(dir, "index.md"),
+	}, paths)
+
+	page, err := os.ReadFile(filepath.Join(dir, "Projector.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "# Projector\n\nModule `Projector.usp`\n\nDrives a projector | over RS-232\nSecond line\n"+
+		"\n## Inputs\n\n| Name | Type | Description |\n| --- | --- | --- |\n| `Poll` | Digital | Polls <the> device |\n"+
+		"\n## Parameters\n\n| Name | Type | Default | Description |\n| --- | --- | --- | --- |\n| `Poll_Interval` | Integer | 5s |  |\n"+
+		"\n## Functions\n\n### Send\n\n```text\nINTEGER_FUNCTION Send(STRING command)\n```\n\n- `command`: the command\n\nReturns the length sent\n",
+		string(page))
+
+	index, err := os.ReadFile(filepath.Join(dir, "index.md"))
+	require.NoError(t, err)
+	assert.Contains(t, string(index), "| [Helpers](Helpers.usl.md) | `Helpers.usl` |  |\n")
+	assert.Contains(t, string(index), "| [Projector](Projector.md) | `Projector.usp` | Drives a projector \\| over RS-232 |\n")
+}
+
+func TestGenerate_HTML(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := Generate(testModules(), dir, FormatHTML)
+	require.NoError(t, err)
+
+	page, err := os.ReadFile(filepath.Join(dir, "Projector.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), "<td><code>Poll</code></td><td>Digital</td><td>Polls &lt;the&gt; device</td>")
+	assert.Contains(t, string(page), "<td><code>Poll_Interval</code></td><td>Integer</td><td>5s</td><td></td>")
+	assert.Contains(t, string(page), "<pre>INTEGER_FUNCTION Send(STRING command)</pre>")
+	assert.NotContains(t, string(page), "<h2>Outputs</h2>")
+
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	require.NoError(t, err)
+	assert.Contains(t, string(index), `<a href="Helpers.usl.html">Helpers</a>`)
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	_, err := Generate(testModules(), t.TempDir(), "pdf")
+	assert.ErrorContains(t, err, "invalid format")
+
+	modules := append(testModules(), &Module{Name: "Other", File: filepath.Join("other", "Projector.usp")})
+	_, err = Generate(modules, t.TempDir(), FormatMarkdown)
+	assert.ErrorContains(t, err, "would both be documented in Projector.md")
+}
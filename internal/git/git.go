@@ -0,0 +1,100 @@
+// Package git runs the git commands spc needs: finding the files changed in a
+// working tree and installing hooks.
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotRepository is returned when a directory is not inside a git work tree
+var ErrNotRepository = errors.New("not a git repository")
+
+// run runs git in dir and returns its trimmed standard output
+func run(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if strings.Contains(msg, "not a git repository") {
+			return "", ErrNotRepository
+		}
+
+		if msg == "" {
+			msg = err.Error()
+		}
+
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Root returns the top-level directory of the work tree containing dir
+func Root(dir string) (string, error) {
+	root, err := run(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.FromSlash(root), nil
+}
+
+// ChangedFiles returns the absolute paths of the files in the work tree
+// containing dir that differ from ref (staged or not), plus untracked files
+// that are not ignored. Deleted files are not included
+func ChangedFiles(dir, ref string) ([]string, error) {
+	root, err := Root(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := run(root, "diff", "--name-only", "--diff-filter=d", "--no-renames", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := run(root, "ls-files", "--others", "--exclude-standard", "--full-name")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, name := range append(lines(diff), lines(untracked)...) {
+		files = append(files, filepath.Join(root, filepath.FromSlash(name)))
+	}
+
+	return files, nil
+}
+
+// UserName returns the user.name git is configured with in dir, or an
+// empty string
+func UserName(dir string) string {
+	name, err := run(dir, "config", "user.name")
+	if err != nil {
+		return ""
+	}
+
+	return name
+}
+
+// lines splits command output into non-empty lines
+func lines(s string) []string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+
+	return out
+}
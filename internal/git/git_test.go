@@ -0,0 +1,96 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// initRepo creates a repository with one committed file
+func initRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+
+	gitCmd(t, dir, "init", "-q")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.usp"), []byte("// a"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.usp"), []byte("// b"), 0o644))
+	gitCmd(t, dir, "add", ".")
+	gitCmd(t, dir, "-c", "user.name=spc", "-c", "user.email=spc@example.com", "commit", "-q", "-m", "initial")
+
+	return dir
+}
+
+func gitCmd(t *testing.T, dir string, args ...string) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func TestChangedFiles(t *testing.T) {
+	dir := initRepo(t)
+
+	changed, err := ChangedFiles(dir, "HEAD")
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.usp"), []byte("// changed"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "lib"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "c.usl"), []byte("// new"), 0o644))
+	require.NoError(t, os.Remove(filepath.Join(dir, "b.usp")))
+
+	changed, err = ChangedFiles(filepath.Join(dir, "lib"), "HEAD")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{filepath.Join(dir, "a.usp"), filepath.Join(dir, "lib", "c.usl")}, changed)
+
+	_, err = ChangedFiles(t.TempDir(), "HEAD")
+	assert.ErrorIs(t, err, ErrNotRepository)
+}
+
+func TestInstallHook(t *testing.T) {
+	dir := initRepo(t)
+
+	path, err := HookPath(dir, "pre-commit")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, ".git", "hooks", "pre-commit"), path)
+
+	script := "#!/bin/sh\n" + HookMarker + "\nexit 0\n"
+	require.NoError(t, InstallHook(path, script, false))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&0o100, "hook is executable")
+
+	// Reinstalling over our own hook is fine
+	require.NoError(t, InstallHook(path, script, false))
+
+	removed, err := UninstallHook(path)
+	require.NoError(t, err)
+	assert.True(t, removed)
+
+	removed, err = UninstallHook(path)
+	require.NoError(t, err)
+	assert.False(t, removed)
+}
+
+func TestInstallHook_Foreign(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pre-commit")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\nmake lint\n"), 0o755))
+
+	assert.ErrorContains(t, InstallHook(path, "#!/bin/sh\n"+HookMarker+"\n", false), "not installed by spc")
+
+	_, err := UninstallHook(path)
+	assert.ErrorContains(t, err, "not installed by spc")
+
+	require.NoError(t, InstallHook(path, "#!/bin/sh\n"+HookMarker+"\n", true))
+}
@@ -0,0 +1,78 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// HookMarker identifies hooks installed by spc, so they can be replaced and
+// removed without touching hooks written by hand or by other tools
+const HookMarker = "# Installed by spc"
+
+// Hooks are the hooks spc can install
+var Hooks = []string{"pre-commit", "pre-push"}
+
+// HookPath returns the path of a hook in the repository containing dir,
+// honoring core.hooksPath
+func HookPath(dir, name string) (string, error) {
+	root, err := Root(dir)
+	if err != nil {
+		return "", err
+	}
+
+	hooksDir, err := run(root, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+
+	hooksDir = filepath.FromSlash(hooksDir)
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(root, hooksDir)
+	}
+
+	return filepath.Join(hooksDir, name), nil
+}
+
+// InstallHook writes an executable hook script. An existing hook that was not
+// installed by spc is only replaced when force is set
+func InstallHook(path, script string, force bool) error {
+	if existing, err := os.ReadFile(path); err == nil && !force && !bytes.Contains(existing, []byte(HookMarker)) {
+		return fmt.Errorf("%s already exists and was not installed by spc (use --force to replace it)", path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		return fmt.Errorf("failed to write hook: %w", err)
+	}
+
+	// WriteFile keeps the mode of an existing file
+	return os.Chmod(path, 0o755)
+}
+
+// UninstallHook removes a hook installed by spc. Returns false if there is no
+// hook; a hook spc did not install is an error
+func UninstallHook(path string) (bool, error) {
+	existing, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if !bytes.Contains(existing, []byte(HookMarker)) {
+		return false, fmt.Errorf("%s was not installed by spc, leaving it in place", path)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,92 @@
+// Package glob matches file paths against the glob patterns used in config
+// files (overrides, include/exclude lists).
+//
+// Patterns use forward slashes and support the usual *, ? and [...] wildcards
+// within a path segment, plus ** to match any number of directories:
+//
+//	legacy/**        everything under legacy/
+//	**/test_*.usp    test modules at any depth
+//	*_old.usp        patterns without a slash match the base name at any depth
+package glob
+
+import (
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Match reports whether a slash-separated name matches the pattern
+func Match(pattern, name string) bool {
+	if runtime.GOOS == "windows" {
+		pattern = strings.ToLower(pattern)
+		name = strings.ToLower(name)
+	}
+
+	return matchSegments(split(pattern), split(name))
+}
+
+// MatchFile reports whether file matches pattern. Patterns containing a slash
+// are matched against the path relative to baseDir; others against the base name
+func MatchFile(pattern, baseDir, file string) bool {
+	pattern = filepath.ToSlash(pattern)
+
+	if !strings.Contains(pattern, "/") {
+		return Match(pattern, filepath.Base(file))
+	}
+
+	name := filepath.ToSlash(file)
+	if baseDir != "" {
+		if rel, err := filepath.Rel(baseDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+			name = filepath.ToSlash(rel)
+		}
+	}
+
+	return Match(strings.TrimPrefix(pattern, "./"), name)
+}
+
+// MatchAny reports whether file matches any of the patterns
+func MatchAny(patterns []string, baseDir, file string) bool {
+	for _, pattern := range patterns {
+		if MatchFile(pattern, baseDir, file) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func split(s string) []string {
+	return strings.Split(strings.Trim(s, "/"), "/")
+}
+
+func matchSegments(patterns, parts []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			patterns = patterns[1:]
+			if len(patterns) == 0 {
+				return true
+			}
+
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(patterns, parts[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+
+		if ok, err := path.Match(patterns[0], parts[0]); err != nil || !ok {
+			return false
+		}
+
+		patterns, parts = patterns[1:], parts[1:]
+	}
+
+	return len(parts) == 0
+}
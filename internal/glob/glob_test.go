@@ -0,0 +1,45 @@
+package glob
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.usp", "a.usp", true},
+		{"*.usp", "a.usl", false},
+		{"legacy/**", "legacy/a.usp", true},
+		{"legacy/**", "legacy/sub/a.usp", true},
+		{"legacy/**", "modern/a.usp", false},
+		{"**/test_*.usp", "test_a.usp", true},
+		{"**/test_*.usp", "a/b/test_a.usp", true},
+		{"a/**/b.usp", "a/x/y/b.usp", true},
+		{"a/**/b.usp", "a/b.usp", true},
+		{"a/*.usp", "a/sub/b.usp", false},
+		{"[ab].usp", "b.usp", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Match(tt.pattern, tt.name))
+		})
+	}
+}
+
+func TestMatchFile(t *testing.T) {
+	base := filepath.Join("project")
+
+	assert.True(t, MatchFile("*_old.usp", base, filepath.Join(base, "deep", "dir", "x_old.usp")))
+	assert.True(t, MatchFile("legacy/**", base, filepath.Join(base, "legacy", "a.usp")))
+	assert.True(t, MatchFile("./legacy/*.usp", base, filepath.Join(base, "legacy", "a.usp")))
+	assert.False(t, MatchFile("legacy/**", base, filepath.Join(base, "src", "legacy.usp")))
+	assert.True(t, MatchAny([]string{"*.usl", "legacy/**"}, base, filepath.Join(base, "lib.usl")))
+	assert.False(t, MatchAny(nil, base, filepath.Join(base, "lib.usl")))
+}
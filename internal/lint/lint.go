@@ -0,0 +1,273 @@
+// Package lint checks SIMPL+ sources for problems the compiler accepts:
+// unused variables, a missing #DEFAULT_VOLATILE, local names that shadow a
+// signal, deprecated functions and wait times written as bare numbers.
+//
+// Problems are reported as diag.Diagnostic values with the rule as the code,
+// so they print, annotate and publish like compile errors:
+//
+//	C:/Project/Room.usp(14,5): warning unused_variable: Variable 'x' is never used
+package lint
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+// Off is the severity of a rule that is not checked
+const Off = "off"
+
+// Rule IDs
+const (
+	RuleUnusedVariable     = "unused_variable"
+	RuleDefaultVolatile    = "default_volatile"
+	RuleShadowedSignal     = "shadowed_signal"
+	RuleDeprecatedFunction = "deprecated_function"
+	RuleMagicWait          = "magic_wait"
+)
+
+// Rule is a check made on every source
+type Rule struct {
+	// ID is the name of the rule in the config and the code of its diagnostics
+	ID string
+
+	// Severity is the severity of the rule's diagnostics unless configured
+	Severity diag.Severity
+
+	// Description says what the rule reports
+	Description string
+
+	check func(src *source, library bool, report func(line, column int, format string, args ...any))
+}
+
+// Rules are the rules, in the order their diagnostics are reported
+var Rules = []Rule{
+	{RuleUnusedVariable, diag.SeverityWarning, "Variables that are declared but never used", checkUnusedVariables},
+	{RuleDefaultVolatile, diag.SeverityWarning, "Modules without #DEFAULT_VOLATILE (or #DEFAULT_NONVOLATILE)", checkDefaultVolatile},
+	{RuleShadowedSignal, diag.SeverityWarning, "Local variables and function parameters named after a signal", checkShadowedSignals},
+	{RuleDeprecatedFunction, diag.SeverityWarning, "Calls to deprecated functions", checkDeprecatedFunctions},
+	{RuleMagicWait, diag.SeverityWarning, "Wait, delay and pulse times written as numbers instead of constants", checkMagicWaits},
+}
+
+// Lookup returns the rule with an ID
+func Lookup(id string) (Rule, bool) {
+	for _, r := range Rules {
+		if r.ID == id {
+			return r, true
+		}
+	}
+
+	return Rule{}, false
+}
+
+// ParseSeverity checks a configured severity: error, warning or off
+func ParseSeverity(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case string(diag.SeverityError), string(diag.SeverityWarning), Off:
+		return strings.ToLower(s), nil
+	default:
+		return "", fmt.Errorf("invalid severity %q (expected error, warning or off)", s)
+	}
+}
+
+// Linter checks sources with the rules' configured severities
+type Linter struct {
+	severities map[string]string
+}
+
+// New creates a linter. severities maps rule IDs to error, warning or off;
+// rules not listed keep their default severity
+func New(severities map[string]string) (*Linter, error) {
+	l := &Linter{severities: make(map[string]string)}
+
+	for _, r := range Rules {
+		l.severities[r.ID] = string(r.Severity)
+	}
+
+	for id, s := range severities {
+		if _, ok := Lookup(id); !ok {
+			return nil, fmt.Errorf("unknown lint rule: %s", id)
+		}
+
+		severity, err := ParseSeverity(s)
+		if err != nil {
+			return nil, fmt.Errorf("lint rule %s: %w", id, err)
+		}
+
+		l.severities[id] = severity
+	}
+
+	return l, nil
+}
+
+// File checks a source file
+func (l *Linter) File(path string) ([]diag.Diagnostic, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return l.Check(path, f)
+}
+
+// Check checks a source read from r. path names it in the diagnostics, and
+// its extension tells libraries (.usl) from modules
+func (l *Linter) Check(path string, r io.Reader) ([]diag.Diagnostic, error) {
+	src, err := parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	library := strings.EqualFold(filepath.Ext(path), ".usl")
+
+	var diagnostics []diag.Diagnostic
+
+	for _, rule := range Rules {
+		severity := l.severities[rule.ID]
+		if severity == Off {
+			continue
+		}
+
+		rule.check(src, library, func(line, column int, format string, args ...any) {
+			diagnostics = append(diagnostics, diag.Diagnostic{
+				File:     path,
+				Line:     line,
+				Column:   column,
+				Severity: diag.Severity(severity),
+				Code:     rule.ID,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		})
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+
+		return diagnostics[i].Column < diagnostics[j].Column
+	})
+
+	return diagnostics, nil
+}
+
+// checkUnusedVariables reports variables whose name appears nowhere but in
+// their declaration. A library's global variables can be used by the modules
+// that include it, so only its local variables are checked
+func checkUnusedVariables(src *source, library bool, report func(int, int, string, ...any)) {
+	for _, v := range src.variables {
+		if library && !v.local {
+			continue
+		}
+
+		if src.uses[strings.ToUpper(v.name.name)] <= 1 {
+			report(v.line, v.column, "Variable '%s' is never used", v.name.name)
+		}
+	}
+}
+
+// checkDefaultVolatile reports a module that leaves the storage of its
+// variables to the series' default
+func checkDefaultVolatile(src *source, library bool, report func(int, int, string, ...any)) {
+	if library {
+		return
+	}
+
+	if _, ok := src.directives["#DEFAULT_VOLATILE"]; ok {
+		return
+	}
+
+	if _, ok := src.directives["#DEFAULT_NONVOLATILE"]; ok {
+		return
+	}
+
+	line := 1
+	if symbol, ok := src.directives["#SYMBOL_NAME"]; ok {
+		line = symbol
+	}
+
+	report(line, 0, "Missing #DEFAULT_VOLATILE: variables without VOLATILE or NONVOLATILE get the series' default storage")
+}
+
+// checkShadowedSignals reports local variables and function parameters with
+// the name of a signal, which hide the signal in their function
+func checkShadowedSignals(src *source, _ bool, report func(int, int, string, ...any)) {
+	signals := make(map[string]signal, len(src.signals))
+	for _, s := range src.signals {
+		if _, ok := signals[strings.ToUpper(s.name.name)]; !ok {
+			signals[strings.ToUpper(s.name.name)] = s
+		}
+	}
+
+	var locals []name
+	for _, v := range src.variables {
+		if v.local {
+			locals = append(locals, v.name)
+		}
+	}
+
+	locals = append(locals, src.params...)
+
+	for _, n := range locals {
+		if s, ok := signals[strings.ToUpper(n.name)]; ok {
+			report(n.line, n.column, "'%s' shadows the %s declared on line %d", n.name, s.kind, s.line)
+		}
+	}
+}
+
+// deprecatedFunctions maps deprecated functions (upper case) to what to use
+// instead
+var deprecatedFunctions = map[string]string{
+	"PROCESSLOGIC": "Sleep or a WAIT",
+	"DELAY":        "a WAIT or Sleep",
+}
+
+// call matches a call and its first argument
+var call = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\s*\(\s*([^,()]*?)\s*[,)]`)
+
+// checkDeprecatedFunctions reports calls to deprecated functions
+func checkDeprecatedFunctions(src *source, _ bool, report func(int, int, string, ...any)) {
+	for _, l := range src.lines {
+		for _, m := range call.FindAllStringSubmatchIndex(l.code, -1) {
+			fn := l.code[m[2]:m[3]]
+			if instead, ok := deprecatedFunctions[strings.ToUpper(fn)]; ok {
+				report(l.num, m[2]+1, "%s is deprecated; use %s instead", fn, instead)
+			}
+		}
+	}
+}
+
+// timedFunctions are the statements and functions (upper case) whose first
+// argument is a time in hundredths of a second
+var timedFunctions = map[string]bool{
+	"WAIT":       true,
+	"RETIMEWAIT": true,
+	"DELAY":      true,
+	"PULSE":      true,
+}
+
+// number matches an integer literal
+var number = regexp.MustCompile(`(?i)^(\d+|0x[0-9a-f]+)$`)
+
+// checkMagicWaits reports times given as numbers other than 0, whose meaning
+// (and unit) a #DEFINE_CONSTANT or parameter would make clear
+func checkMagicWaits(src *source, _ bool, report func(int, int, string, ...any)) {
+	for _, l := range src.lines {
+		for _, m := range call.FindAllStringSubmatchIndex(l.code, -1) {
+			fn, arg := l.code[m[2]:m[3]], l.code[m[4]:m[5]]
+			if !timedFunctions[strings.ToUpper(fn)] || !number.MatchString(arg) || strings.Trim(arg, "0") == "" {
+				continue
+			}
+
+			report(l.num, m[4]+1, "%s time %s is a magic number; use a #DEFINE_CONSTANT or parameter", fn, arg)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+const module = `#SYMBOL_NAME "Room"
+
+DIGITAL_INPUT Power_On, _SKIP_, Poll;
+STRING_OUTPUT To_Device$;
+INTEGER_PARAMETER Poll_Time;
+
+INTEGER counter, spare;
+STRING buffer[100]; // "spare" in a comment is not a use
+
+STRUCTURE Settings
+{
+    INTEGER unused_member;
+};
+
+FUNCTION Send(STRING Poll)
+{
+    INTEGER i;
+    To_Device$ = Poll;
+    ProcessLogic();
+}
+
+PUSH Power_On
+{
+    INTEGER Power_On;
+    STRING note[10];
+
+    note = "counter";
+    counter = counter + 1;
+    Wait(500, power_wait)
+    {
+        Send(buffer);
+    }
+
+    Wait(Poll_Time)
+    {
+        Send(buffer);
+    }
+
+    Delay(0);
+}
+`
+
+func TestCheck(t *testing.T) {
+	l, err := New(nil)
+	require.NoError(t, err)
+
+	diagnostics, err := l.Check("Room.usp", strings.NewReader(module))
+	require.NoError(t, err)
+
+	var got []string
+	for _, d := range diagnostics {
+		got = append(got, d.String())
+	}
+
+	assert.Equal(t, []string{
+		"Room.usp(1): warning default_volatile: Missing #DEFAULT_VOLATILE: variables without VOLATILE or NONVOLATILE get the series' default storage",
+		"Room.usp(7,18): warning unused_variable: Variable 'spare' is never used",
+		"Room.usp(15,22): warning shadowed_signal: 'Poll' shadows the input declared on line 3",
+		"Room.usp(17,13): warning unused_variable: Variable 'i' is never used",
+		"Room.usp(19,5): warning deprecated_function: ProcessLogic is deprecated; use Sleep or a WAIT instead",
+		"Room.usp(24,13): warning shadowed_signal: 'Power_On' shadows the input declared on line 3",
+		"Room.usp(29,10): warning magic_wait: Wait time 500 is a magic number; use a #DEFINE_CONSTANT or parameter",
+		"Room.usp(39,5): warning deprecated_function: Delay is deprecated; use a WAIT or Sleep instead",
+	}, got)
+}
+
+func TestCheck_Severities(t *testing.T) {
+	l, err := New(map[string]string{
+		RuleDefaultVolatile: "off",
+		RuleMagicWait:       "Error",
+		RuleUnusedVariable:  Off,
+	})
+	require.NoError(t, err)
+
+	diagnostics, err := l.Check("Room.usp", strings.NewReader("#DEFAULT_NONVOLATILE\nINTEGER x;\nFUNCTION Main()\n{\n    Pulse(20, y);\n}\n"))
+	require.NoError(t, err)
+
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, diag.SeverityError, diagnostics[0].Severity)
+	assert.Equal(t, RuleMagicWait, diagnostics[0].Code)
+}
+
+func TestCheck_Library(t *testing.T) {
+	l, err := New(nil)
+	require.NoError(t, err)
+
+	diagnostics, err := l.Check("Helpers.usl", strings.NewReader("INTEGER shared;\n\nFUNCTION Helper()\n{\n    INTEGER unused;\n}\n"))
+	require.NoError(t, err)
+
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "Helpers.usl(5,13): warning unused_variable: Variable 'unused' is never used", diagnostics[0].String())
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(map[string]string{"no_such_rule": "error"})
+	assert.ErrorContains(t, err, "unknown lint rule: no_such_rule")
+
+	_, err = New(map[string]string{RuleMagicWait: "info"})
+	assert.ErrorContains(t, err, "invalid severity")
+}
@@ -0,0 +1,280 @@
+package lint
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/deps"
+	"github.com/Norgate-AV/spc/internal/ush"
+)
+
+// line is a line of code without comments, with the contents of string
+// literals blanked out so columns still match the file
+type line struct {
+	num  int
+	code string
+
+	// depth is the brace nesting at the start of the line: 0 outside any
+	// function or event handler
+	depth int
+}
+
+// name is a declared name and where it is declared
+type name struct {
+	name   string
+	line   int
+	column int
+}
+
+// variable is a declared variable
+type variable struct {
+	name
+
+	// local is set for variables declared in a function or event handler
+	local bool
+}
+
+// signal is a declared input, output or parameter
+type signal struct {
+	name
+
+	// kind is input, output or parameter
+	kind string
+}
+
+// source is a parsed SIMPL+ file
+type source struct {
+	lines []line
+
+	// directives are the compiler directives used, upper case
+	directives map[string]int
+
+	signals   []signal
+	variables []variable
+
+	// params are the parameters of the functions
+	params []name
+
+	// uses counts the occurrences of each identifier, upper case
+	uses map[string]int
+}
+
+// signalKinds maps signal and parameter declaration keywords to their kind
+var signalKinds = map[string]string{
+	"DIGITAL_INPUT":                 "input",
+	"ANALOG_INPUT":                  "input",
+	"STRING_INPUT":                  "input",
+	"BUFFER_INPUT":                  "input",
+	"DIGITAL_OUTPUT":                "output",
+	"ANALOG_OUTPUT":                 "output",
+	"STRING_OUTPUT":                 "output",
+	"INTEGER_PARAMETER":             "parameter",
+	"SIGNED_INTEGER_PARAMETER":      "parameter",
+	"LONG_INTEGER_PARAMETER":        "parameter",
+	"SIGNED_LONG_INTEGER_PARAMETER": "parameter",
+	"STRING_PARAMETER":              "parameter",
+}
+
+// variableTypes are the keywords that declare variables
+var variableTypes = map[string]bool{
+	"INTEGER":             true,
+	"LONG_INTEGER":        true,
+	"SIGNED_INTEGER":      true,
+	"SIGNED_LONG_INTEGER": true,
+	"STRING":              true,
+}
+
+// storageClasses may come before the type of a variable
+var storageClasses = map[string]bool{
+	"VOLATILE":    true,
+	"NONVOLATILE": true,
+	"DYNAMIC":     true,
+}
+
+// identifier matches SIMPL+ identifiers, including the $ of string signals
+var identifier = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_$]*`)
+
+// parse reads SIMPL+ source
+func parse(r io.Reader) (*source, error) {
+	src := &source{directives: make(map[string]int), uses: make(map[string]int)}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	inComment := false
+	depth := 0
+	num := 0
+
+	for scanner.Scan() {
+		num++
+
+		var code string
+		code, inComment = deps.StripComments(strings.TrimRight(scanner.Text(), "\r"), inComment)
+		code = blankStrings(code)
+
+		src.lines = append(src.lines, line{num: num, code: code, depth: depth})
+		depth = max(depth+strings.Count(code, "{")-strings.Count(code, "}"), 0)
+
+		for _, word := range identifier.FindAllString(code, -1) {
+			src.uses[strings.ToUpper(word)]++
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	src.declarations()
+
+	return src, nil
+}
+
+// declarations finds the directives, signals, variables and function
+// parameters. Declarations may continue onto the following lines up to
+// their semicolon. Members of structures are not variables
+func (src *source) declarations() {
+	structureDepth := -1
+
+	for i := 0; i < len(src.lines); i++ {
+		l := src.lines[i]
+		trimmed := strings.TrimSpace(l.code)
+		keyword := strings.ToUpper(identifier.FindString(trimmed))
+
+		if structureDepth >= 0 && l.depth <= structureDepth && !strings.HasPrefix(trimmed, "{") {
+			structureDepth = -1
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "#"):
+			directive, _, _ := strings.Cut(trimmed, " ")
+			if _, ok := src.directives[strings.ToUpper(directive)]; !ok {
+				src.directives[strings.ToUpper(directive)] = l.num
+			}
+		case keyword == "STRUCTURE":
+			structureDepth = l.depth
+		case signalKinds[keyword] != "" && l.depth == 0:
+			names, last := src.statement(i, keyword)
+			for _, n := range names {
+				src.signals = append(src.signals, signal{name: n, kind: signalKinds[keyword]})
+			}
+
+			i = last
+		case structureDepth < 0 && isVariableDeclaration(trimmed):
+			names, last := src.statement(i, "")
+			for _, n := range names {
+				src.variables = append(src.variables, variable{name: n, local: l.depth > 0})
+			}
+
+			i = last
+		default:
+			if fn, ok := ush.ParseFunction(trimmed); ok {
+				src.params = append(src.params, src.functionParams(l, fn)...)
+			}
+		}
+	}
+}
+
+// isVariableDeclaration reports whether a line of code starts a variable
+// declaration, e.g. "NONVOLATILE INTEGER a, b[10];"
+func isVariableDeclaration(code string) bool {
+	words := identifier.FindAllString(code, 2)
+	if len(words) == 2 && storageClasses[strings.ToUpper(words[0])] {
+		words = words[1:]
+	}
+
+	if len(words) == 0 || !variableTypes[strings.ToUpper(words[0])] {
+		return false
+	}
+
+	// the type must be followed by the names, not be a cast or call
+	rest := strings.TrimSpace(code[strings.Index(strings.ToUpper(code), strings.ToUpper(words[0]))+len(words[0]):])
+
+	return rest != "" && identifier.MatchString(rest[:1])
+}
+
+// statement returns the names declared by the statement starting at line i,
+// after the type keywords, and the index of its last line
+func (src *source) statement(i int, keyword string) ([]name, int) {
+	var names []name
+
+	typeSeen := false
+
+	for j := i; j < len(src.lines); j++ {
+		l := src.lines[j]
+		code, _, done := strings.Cut(l.code, ";")
+
+		for _, loc := range identifier.FindAllStringIndex(code, -1) {
+			word := code[loc[0]:loc[1]]
+			upper := strings.ToUpper(word)
+
+			// the sizes of arrays and strings are not names
+			inBrackets := strings.Count(code[:loc[0]], "[") > strings.Count(code[:loc[0]], "]")
+
+			switch {
+			case inBrackets:
+			case !typeSeen && (upper == keyword || storageClasses[upper] || variableTypes[upper]):
+				if upper == keyword || variableTypes[upper] {
+					typeSeen = true
+				}
+			case upper == "_SKIP_":
+			default:
+				names = append(names, name{name: word, line: l.num, column: loc[0] + 1})
+			}
+		}
+
+		if done {
+			return names, j
+		}
+	}
+
+	return names, len(src.lines) - 1
+}
+
+// functionParams returns the parameters of a function declared on a line:
+// the last word of each, e.g. command in "BYVAL STRING command"
+func (src *source) functionParams(l line, fn ush.Function) []name {
+	open := strings.Index(l.code, "(")
+	if open < 0 || fn.Params == "" {
+		return nil
+	}
+
+	var params []name
+
+	offset := open + 1
+	for _, param := range strings.Split(l.code[offset:], ",") {
+		param, _, last := strings.Cut(param, ")")
+
+		words := identifier.FindAllStringIndex(param, -1)
+		if len(words) > 1 {
+			loc := words[len(words)-1]
+			params = append(params, name{name: param[loc[0]:loc[1]], line: l.num, column: offset + loc[0] + 1})
+		}
+
+		if last {
+			break
+		}
+
+		offset += len(param) + 1
+	}
+
+	return params
+}
+
+// blankStrings replaces the contents of string literals with spaces
+func blankStrings(code string) string {
+	b := []byte(code)
+	inString := false
+
+	for i := range b {
+		switch {
+		case b[i] == '"':
+			inString = !inString
+		case inString:
+			b[i] = ' '
+		}
+	}
+
+	return string(b)
+}
@@ -0,0 +1,145 @@
+package lock
+
+import (
+	"fmt"
+)
+
+// Difference is something a build did differently from its lock. Locked or
+// Built is empty when the item is missing from that side
+type Difference struct {
+	// Module is the source of the module that differs, empty for compilers
+	Module string
+
+	// Item is what differs, e.g. "SPlsWork/example.dll" or "compiler series 3"
+	Item string
+
+	// Locked and Built describe the item in the lock and in the build
+	Locked string
+	Built  string
+}
+
+// String formats the difference on one line
+func (d Difference) String() string {
+	locked, built := d.Locked, d.Built
+	if locked == "" {
+		locked = "(none)"
+	}
+
+	if built == "" {
+		built = "(none)"
+	}
+
+	if d.Module == "" {
+		return fmt.Sprintf("%s: locked %s, built %s", d.Item, locked, built)
+	}
+
+	return fmt.Sprintf("%s: %s: locked %s, built %s", d.Module, d.Item, locked, built)
+}
+
+// Diff compares a build against its lock. Only the modules and series in the
+// build are compared, so a lock can be verified a few modules at a time.
+// Compiler paths are not compared, as they vary between machines
+func Diff(locked, built *File) []Difference {
+	var diffs []Difference
+
+	compilers := make(map[string]Compiler)
+	for _, c := range locked.Compilers {
+		compilers[c.Series] = c
+	}
+
+	for _, c := range built.Compilers {
+		item := "compiler series " + c.Series
+		if l, ok := compilers[c.Series]; !ok {
+			diffs = append(diffs, Difference{Item: item, Built: c.identity()})
+		} else if l.identity() != c.identity() {
+			diffs = append(diffs, Difference{Item: item, Locked: l.identity(), Built: c.identity()})
+		}
+	}
+
+	modules := make(map[string]Module)
+	for _, m := range locked.Modules {
+		modules[m.Source] = m
+	}
+
+	for _, m := range built.Modules {
+		l, ok := modules[m.Source]
+		if !ok {
+			diffs = append(diffs, Difference{Module: m.Source, Item: "module", Built: "target " + m.Target})
+			continue
+		}
+
+		diffs = append(diffs, diffModule(l, m)...)
+	}
+
+	return diffs
+}
+
+// diffModule compares a built module against its locked version
+func diffModule(locked, built Module) []Difference {
+	var diffs []Difference
+
+	add := func(item, l, b string) {
+		if l != b {
+			diffs = append(diffs, Difference{Module: built.Source, Item: item, Locked: l, Built: b})
+		}
+	}
+
+	add("source", locked.SHA256, built.SHA256)
+	add("target", locked.Target, built.Target)
+
+	lockedDeps, builtDeps := make(map[string]string), make(map[string]string)
+	for _, d := range locked.Dependencies {
+		lockedDeps[d.Path] = d.SHA256
+	}
+
+	for _, d := range built.Dependencies {
+		builtDeps[d.Path] = d.SHA256
+	}
+
+	for _, path := range union(lockedDeps, builtDeps) {
+		add(path, lockedDeps[path], builtDeps[path])
+	}
+
+	lockedArtifacts, builtArtifacts := make(map[string]string), make(map[string]string)
+	for _, a := range locked.Artifacts {
+		lockedArtifacts[a.Path] = a.describe()
+	}
+
+	for _, a := range built.Artifacts {
+		builtArtifacts[a.Path] = a.describe()
+	}
+
+	for _, path := range union(lockedArtifacts, builtArtifacts) {
+		add(path, lockedArtifacts[path], builtArtifacts[path])
+	}
+
+	return diffs
+}
+
+// identity is what identifies a compiler across machines
+func (c Compiler) identity() string {
+	if c.Image != "" {
+		return "image " + c.Image
+	}
+
+	return "sha256 " + c.SHA256
+}
+
+// describe summarizes an artifact for a difference
+func (a Artifact) describe() string {
+	return fmt.Sprintf("sha256 %s (%d bytes)", a.SHA256, a.Size)
+}
+
+// union returns the keys of both maps, sorted
+func union(a, b map[string]string) []string {
+	seen := make(map[string]bool)
+	for k := range a {
+		seen[k] = true
+	}
+
+	for k := range b {
+		seen[k] = true
+	}
+
+	return sortedKeys(seen)
+}
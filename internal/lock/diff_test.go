@@ -0,0 +1,88 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff_Match(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testConfig(t, dir)
+	source := writeBuilt(t, dir, "example", "// x")
+
+	locked, err := Generate(cfg, []string{source}, dir)
+	require.NoError(t, err)
+
+	built, err := Generate(cfg, []string{source}, dir)
+	require.NoError(t, err)
+
+	assert.Empty(t, Diff(locked, built))
+}
+
+func TestDiff_ChangedArtifact(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testConfig(t, dir)
+	source := writeBuilt(t, dir, "example", "// x")
+
+	locked, err := Generate(cfg, []string{source}, dir)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "SPlsWork", "example.dll"), []byte("tampered"), 0o644))
+
+	built, err := Generate(cfg, []string{source}, dir)
+	require.NoError(t, err)
+
+	diffs := Diff(locked, built)
+	require.Len(t, diffs, 1)
+	assert.Equal(t, "example.usp", diffs[0].Module)
+	assert.Equal(t, "SPlsWork/example.dll", diffs[0].Item)
+	assert.Contains(t, diffs[0].Locked, locked.Modules[0].Artifacts[0].SHA256)
+	assert.Contains(t, diffs[0].Built, "(8 bytes)")
+}
+
+func TestDiff_Modules(t *testing.T) {
+	locked := &File{
+		Compilers: []Compiler{{Series: "3", Path: `C:\SPlusCC.exe`, SHA256: "c1"}},
+		Modules: []Module{
+			{Source: "a.usp", SHA256: "a1", Target: "3", Artifacts: []Artifact{{Path: "a.ush", Size: 1, SHA256: "h1"}}},
+			{Source: "b.usp", SHA256: "b1", Target: "3"},
+		},
+	}
+
+	built := &File{
+		// The path differs between machines, only the hash matters
+		Compilers: []Compiler{{Series: "3", Path: "/opt/SPlusCC.exe", SHA256: "c1"}, {Series: "4", SHA256: "c2"}},
+		Modules: []Module{
+			{
+				Source: "a.usp", SHA256: "a2", Target: "3",
+				Dependencies: []Dependency{{Path: "lib.usl", SHA256: "l1"}},
+			},
+			{Source: "c.usp", SHA256: "c1", Target: "3"},
+		},
+	}
+
+	assert.Equal(t, []Difference{
+		{Item: "compiler series 4", Built: "sha256 c2"},
+		{Module: "a.usp", Item: "source", Locked: "a1", Built: "a2"},
+		{Module: "a.usp", Item: "lib.usl", Built: "l1"},
+		{Module: "a.usp", Item: "a.ush", Locked: "sha256 h1 (1 bytes)"},
+		{Module: "c.usp", Item: "module", Built: "target 3"},
+	}, Diff(locked, built))
+}
+
+func TestDifference_String(t *testing.T) {
+	d := Difference{Module: "a.usp", Item: "a.ush", Locked: "sha256 h1 (1 bytes)"}
+	assert.Equal(t, "a.usp: a.ush: locked sha256 h1 (1 bytes), built (none)", d.String())
+
+	d = Difference{Item: "compiler series 3", Locked: "sha256 c1", Built: "sha256 c2"}
+	assert.Equal(t, "compiler series 3: locked sha256 c1, built sha256 c2", d.String())
+}
+
+func TestSources(t *testing.T) {
+	f := &File{Modules: []Module{{Source: "src/a.usp"}}}
+	assert.Equal(t, []string{filepath.Join("/project", "src", "a.usp")}, f.Sources("/project"))
+}
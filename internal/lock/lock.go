@@ -0,0 +1,304 @@
+// Package lock reads and writes spc.lock, which pins a successful build set:
+// the compiler each series was built with, the hash of every source and
+// library it used, and the checksum of every artifact it produced, so release
+// builds can be reproduced and audited.
+//
+// The lock holds no timestamps, so locking the same build twice produces the
+// same file
+package lock
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/toolchain"
+	"github.com/Norgate-AV/spc/internal/utils"
+)
+
+// FileName is the name of the lock file in the project directory
+const FileName = "spc.lock"
+
+// FormatVersion is the version of the lock file format
+const FormatVersion = 1
+
+// File is the content of a lock file
+type File struct {
+	// Format is the lock file format version (FormatVersion)
+	Format int `json:"format"`
+
+	// SPC is the version of spc that wrote the lock
+	SPC string `json:"spc,omitempty"`
+
+	// Compilers are the compilers used, one per series built
+	Compilers []Compiler `json:"compilers"`
+
+	// Modules are the locked source files, sorted by source
+	Modules []Module `json:"modules"`
+}
+
+// Compiler identifies the compiler a series was built with
+type Compiler struct {
+	// Series is the series number ("2", "3" or "4")
+	Series string `json:"series"`
+
+	// Path and SHA256 identify the compiler executable of local builds
+	Path   string `json:"path,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Version is the file version of the compiler executable of local builds,
+	// empty when it has no version resource
+	Version string `json:"version,omitempty"`
+
+	// Image is the container image of builds run with the docker runner
+	Image string `json:"image,omitempty"`
+}
+
+// Module is a locked source file
+type Module struct {
+	// Source is the source file, relative to the project directory when inside it
+	Source string `json:"source"`
+
+	// SHA256 is the hash of the source file
+	SHA256 string `json:"sha256"`
+
+	// Target is the series the module was compiled for
+	Target string `json:"target"`
+
+	// Dependencies are the libraries the module uses
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+
+	// Artifacts are the module's compiled outputs
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// Dependency is a library a module was built against
+type Dependency struct {
+	// Path is the library, relative to the project directory when inside it
+	Path string `json:"path"`
+
+	// SHA256 is the hash of the library
+	SHA256 string `json:"sha256"`
+}
+
+// Artifact is a compiled output of a module
+type Artifact struct {
+	// Path is the output, relative to the project directory when inside it
+	Path string `json:"path"`
+
+	// Size is the output size in bytes
+	Size int64 `json:"size"`
+
+	// SHA256 is the hash of the output
+	SHA256 string `json:"sha256"`
+}
+
+// Generate locks the current build of each source file. Every file must have
+// been built; paths are recorded relative to projectDir
+func Generate(cfg *config.Config, files []string, projectDir string) (*File, error) {
+	lock := &File{Format: FormatVersion, Compilers: []Compiler{}, Modules: []Module{}}
+	hasher := cache.NewHasher(config.HashSHA256, 0)
+	series := make(map[string]bool)
+
+	for _, file := range files {
+		absFile, err := filepath.Abs(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve path for %s: %w", file, err)
+		}
+
+		fileCfg := cfg.ForFile(absFile)
+
+		m, err := lockModule(hasher, absFile, fileCfg, projectDir)
+		if err != nil {
+			return nil, err
+		}
+
+		lock.Modules = append(lock.Modules, m)
+
+		for _, s := range utils.ParseTarget(fileCfg.Target) {
+			series[s] = true
+		}
+	}
+
+	for _, s := range sortedKeys(series) {
+		compiler, err := lockCompiler(cfg, s)
+		if err != nil {
+			return nil, err
+		}
+
+		lock.Compilers = append(lock.Compilers, compiler)
+	}
+
+	sort.Slice(lock.Modules, func(i, j int) bool { return lock.Modules[i].Source < lock.Modules[j].Source })
+
+	return lock, nil
+}
+
+// lockModule records a built source file with its dependencies and artifacts
+func lockModule(hasher *cache.Hasher, absFile string, cfg *config.Config, projectDir string) (Module, error) {
+	m := Module{Source: relPath(projectDir, absFile), Target: cfg.Target, Artifacts: []Artifact{}}
+
+	sum, err := cache.HashFile(absFile)
+	if err != nil {
+		return m, fmt.Errorf("failed to hash %s: %w", absFile, err)
+	}
+
+	m.SHA256 = sum
+
+	dependencies, err := hasher.HashDependencies(absFile, cfg)
+	if err != nil {
+		return m, err
+	}
+
+	for _, d := range dependencies {
+		if d.Path == "" {
+			return m, fmt.Errorf("%s uses %s, which could not be found", filepath.Base(absFile), d.Name)
+		}
+
+		m.Dependencies = append(m.Dependencies, Dependency{Path: relPath(projectDir, d.Path), SHA256: d.Hash})
+	}
+
+	outputs, err := cache.CollectOutputs(absFile, cfg.Target)
+	if err != nil {
+		return m, err
+	}
+
+	if len(outputs) == 0 {
+		return m, fmt.Errorf("%s has not been built for target %s", filepath.Base(absFile), cfg.Target)
+	}
+
+	for _, output := range outputs {
+		path := filepath.Join(filepath.Dir(absFile), output)
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return m, err
+		}
+
+		sum, err := cache.HashFile(path)
+		if err != nil {
+			return m, fmt.Errorf("failed to hash %s: %w", output, err)
+		}
+
+		m.Artifacts = append(m.Artifacts, Artifact{Path: relPath(projectDir, path), Size: info.Size(), SHA256: sum})
+	}
+
+	sort.Slice(m.Artifacts, func(i, j int) bool { return m.Artifacts[i].Path < m.Artifacts[j].Path })
+
+	return m, nil
+}
+
+// lockCompiler identifies the compiler used for a series ("series3")
+func lockCompiler(cfg *config.Config, series string) (Compiler, error) {
+	c := Compiler{Series: strings.TrimPrefix(series, "series")}
+
+	if cfg.Runner == config.RunnerDocker {
+		c.Image = cfg.Docker.Image
+		return c, nil
+	}
+
+	c.Path = cfg.CompilerFor(series)
+
+	sum, err := cache.HashFile(c.Path)
+	if err != nil {
+		return c, fmt.Errorf("failed to hash compiler %s: %w", c.Path, err)
+	}
+
+	c.SHA256 = sum
+	c.Version, _ = toolchain.FileVersion(c.Path)
+
+	return c, nil
+}
+
+// Sources returns the locked source files, resolved against dir
+func (f *File) Sources(dir string) []string {
+	sources := make([]string, 0, len(f.Modules))
+	for _, m := range f.Modules {
+		source := filepath.FromSlash(m.Source)
+		if !filepath.IsAbs(source) {
+			source = filepath.Join(dir, source)
+		}
+
+		sources = append(sources, source)
+	}
+
+	return sources
+}
+
+// Read loads a lock file
+func Read(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lock File
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if lock.Format > FormatVersion {
+		return nil, fmt.Errorf("%s was written by a newer version of spc (format %d)", path, lock.Format)
+	}
+
+	return &lock, nil
+}
+
+// Write saves a lock file. It is written to a temporary file first, so an
+// existing lock is only replaced once the new one is complete
+func Write(path string, lock *File) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".spc-lock-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// relPath returns path relative to dir with forward slashes, or the absolute
+// path when it is outside dir
+func relPath(dir, path string) string {
+	if dir != "" {
+		if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+
+	return filepath.ToSlash(path)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
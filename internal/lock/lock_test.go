@@ -0,0 +1,148 @@
+package lock
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// writeBuilt creates a source file with the outputs the compiler would leave
+func writeBuilt(t *testing.T, dir, name, content string) string {
+	files := map[string]string{
+		name + ".usp":                                content,
+		name + ".ush":                                "header " + name,
+		filepath.Join("SPlsWork", name+".dll"):       "dll " + name,
+		filepath.Join("SPlsWork", "S2_"+name+".elf"): "elf " + name,
+	}
+
+	for f, data := range files {
+		path := filepath.Join(dir, f)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+
+	return filepath.Join(dir, name+".usp")
+}
+
+// testConfig returns a config building for series 3 and 4 with a fake
+// compiler, whose version resource says it is version 4.3100
+func testConfig(t *testing.T, dir string) *config.Config {
+	info := make([]byte, 16)
+	binary.LittleEndian.PutUint32(info, 0xfeef04bd)
+	binary.LittleEndian.PutUint32(info[4:], 0x00010000)
+	binary.LittleEndian.PutUint32(info[8:], 4<<16|3100)
+
+	compiler := filepath.Join(dir, "SPlusCC.exe")
+	require.NoError(t, os.WriteFile(compiler, append([]byte("compiler "), info...), 0o755))
+
+	return &config.Config{Target: "34", CompilerPath: compiler, ProjectDir: dir}
+}
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testConfig(t, dir)
+
+	b := writeBuilt(t, filepath.Join(dir, "src"), "b", "// b")
+	a := writeBuilt(t, filepath.Join(dir, "src"), "a", "// a")
+
+	lock, err := Generate(cfg, []string{b, a}, dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, FormatVersion, lock.Format)
+
+	require.Len(t, lock.Compilers, 2)
+	assert.Equal(t, "3", lock.Compilers[0].Series)
+	assert.Equal(t, "4", lock.Compilers[1].Series)
+	assert.Len(t, lock.Compilers[0].SHA256, 64)
+	assert.Equal(t, "4.3100.0.0", lock.Compilers[0].Version)
+
+	require.Len(t, lock.Modules, 2)
+	assert.Equal(t, "src/a.usp", lock.Modules[0].Source)
+	assert.Equal(t, "src/b.usp", lock.Modules[1].Source)
+	assert.Equal(t, "34", lock.Modules[0].Target)
+	assert.Len(t, lock.Modules[0].SHA256, 64)
+	assert.NotEqual(t, lock.Modules[0].SHA256, lock.Modules[1].SHA256)
+
+	var paths []string
+	for _, artifact := range lock.Modules[0].Artifacts {
+		paths = append(paths, artifact.Path)
+		assert.Len(t, artifact.SHA256, 64)
+		assert.Positive(t, artifact.Size)
+	}
+
+	assert.Equal(t, []string{"src/SPlsWork/a.dll", "src/a.ush"}, paths)
+}
+
+func TestGenerate_Docker(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Target: "4", Runner: config.RunnerDocker, Docker: config.DockerConfig{Image: "spc/simpl:1.0"}}
+	source := writeBuilt(t, dir, "example", "// x")
+
+	lock, err := Generate(cfg, []string{source}, dir)
+	require.NoError(t, err)
+
+	require.Len(t, lock.Compilers, 1)
+	assert.Equal(t, Compiler{Series: "4", Image: "spc/simpl:1.0"}, lock.Compilers[0])
+}
+
+func TestGenerate_NotBuilt(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testConfig(t, dir)
+
+	source := filepath.Join(dir, "example.usp")
+	require.NoError(t, os.WriteFile(source, []byte("// x"), 0o644))
+
+	_, err := Generate(cfg, []string{source}, dir)
+	assert.ErrorContains(t, err, "has not been built")
+}
+
+func TestGenerate_MissingCompiler(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Target: "3", CompilerPath: filepath.Join(dir, "missing.exe")}
+	source := writeBuilt(t, dir, "example", "// x")
+
+	_, err := Generate(cfg, []string{source}, dir)
+	assert.ErrorContains(t, err, "failed to hash compiler")
+}
+
+func TestWriteRead(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testConfig(t, dir)
+	source := writeBuilt(t, dir, "example", "// x")
+
+	lock, err := Generate(cfg, []string{source}, dir)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, FileName)
+	require.NoError(t, Write(path, lock))
+
+	first, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	read, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, lock, read)
+
+	// Locking an unchanged build writes the same file
+	again, err := Generate(cfg, []string{source}, dir)
+	require.NoError(t, err)
+	require.NoError(t, Write(path, again))
+
+	second, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(first), string(second))
+}
+
+func TestRead_NewerFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	require.NoError(t, os.WriteFile(path, []byte(`{"format": 99}`), 0o644))
+
+	_, err := Read(path)
+	assert.ErrorContains(t, err, "newer version of spc")
+}
@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// ColorMode controls whether output is colorized
+type ColorMode int
+
+const (
+	// ColorAuto colorizes output written to a terminal unless NO_COLOR is set
+	ColorAuto ColorMode = iota
+	// ColorNever disables color (--no-color)
+	ColorNever
+	// ColorAlways colorizes output regardless of the destination
+	ColorAlways
+)
+
+// NoColorEnv is the environment variable that disables color (https://no-color.org)
+const NoColorEnv = "NO_COLOR"
+
+// Color is an ANSI foreground color
+type Color string
+
+const (
+	ColorNone   Color = ""
+	ColorRed    Color = "\x1b[31m"
+	ColorGreen  Color = "\x1b[32m"
+	ColorYellow Color = "\x1b[33m"
+
+	colorReset = "\x1b[0m"
+)
+
+// useColor reports whether output written to w should be colorized
+func useColor(mode ColorMode, w io.Writer) bool {
+	switch mode {
+	case ColorNever:
+		return false
+	case ColorAlways:
+		return true
+	}
+
+	if os.Getenv(NoColorEnv) != "" {
+		return false
+	}
+
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// colorize wraps s in the given color's escape codes
+func colorize(c Color, s string) string {
+	if c == ColorNone {
+		return s
+	}
+
+	return string(c) + s + colorReset
+}
@@ -0,0 +1,385 @@
+// Package logger provides leveled console output for spc.
+//
+// Errors and warnings are written to stderr, informational and debug messages
+// to stdout. The level is controlled by --quiet, -v and -vv:
+//
+//	--quiet  errors only
+//	(none)   errors and warnings
+//	-v       + informational messages (files compiled, cache hits)
+//	-vv      + debug messages (every cache decision and file copy)
+//
+// When writing to a terminal, errors are shown in red, warnings in yellow and
+// successes (such as cache hits) in green. Color is disabled by NO_COLOR or
+// --no-color.
+//
+// Messages are log/slog records. With --log-file, every record, whatever the
+// console level, is also written to a file as text or JSON, tagged with the
+// source file, target and phase it belongs to (see ForFile and Phase).
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+)
+
+// Level is the severity of a log message
+type Level int
+
+const (
+	// LevelError shows only errors
+	LevelError Level = iota
+	// LevelWarn shows warnings and errors (default)
+	LevelWarn
+	// LevelInfo shows informational messages
+	LevelInfo
+	// LevelDebug shows everything
+	LevelDebug
+)
+
+// levelSuccess is the slog level of success messages: informational, but
+// shown in green
+const levelSuccess = slog.LevelInfo + 1
+
+// Format is the format of a log file
+type Format string
+
+const (
+	// FormatText writes logfmt-style key=value lines
+	FormatText Format = "text"
+	// FormatJSON writes one JSON object per line
+	FormatJSON Format = "json"
+)
+
+// ParseFormat parses the --log-format flag
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case FormatText, FormatJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown log format %q (want text or json)", name)
+	}
+}
+
+// Attribute keys that tag records with what they are about
+const (
+	FileKey   = "file"
+	TargetKey = "target"
+	PhaseKey  = "phase"
+)
+
+// Phases of building a file
+const (
+	PhaseHash    = "hash"
+	PhaseCache   = "cache"
+	PhaseCompile = "compile"
+	PhaseStore   = "store"
+	PhasePublish = "publish"
+)
+
+// Logger writes leveled messages to output streams
+type Logger struct {
+	sink *sink
+	log  *slog.Logger
+}
+
+// sink is the output shared by a logger and those derived from it with With
+type sink struct {
+	mu     sync.Mutex
+	level  Level
+	color  ColorMode
+	out    io.Writer
+	errOut io.Writer
+	file   slog.Handler // nil without a log file
+}
+
+// New creates a logger writing info/debug to out and warnings/errors to errOut
+func New(out, errOut io.Writer) *Logger {
+	s := &sink{
+		level:  LevelWarn,
+		out:    out,
+		errOut: errOut,
+	}
+
+	return &Logger{sink: s, log: slog.New(&handler{sink: s})}
+}
+
+// std is the default logger used by the package-level functions
+var std = New(os.Stdout, os.Stderr)
+
+// Default returns the default logger
+func Default() *Logger {
+	return std
+}
+
+// LevelFromFlags maps the --quiet flag and -v repeat count to a log level
+func LevelFromFlags(verbosity int, quiet bool) Level {
+	switch {
+	case quiet:
+		return LevelError
+	case verbosity >= 2:
+		return LevelDebug
+	case verbosity == 1:
+		return LevelInfo
+	default:
+		return LevelWarn
+	}
+}
+
+// SetLevel sets the minimum level that will be written to the console
+func (l *Logger) SetLevel(level Level) {
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+
+	l.sink.level = level
+}
+
+// SetOutput redirects info/debug output to out and warnings/errors to errOut
+func (l *Logger) SetOutput(out, errOut io.Writer) {
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+
+	l.sink.out = out
+	l.sink.errOut = errOut
+}
+
+// SetFile writes every record, including debug records, to w in the given
+// format as well as to the console. A nil w stops writing to the file
+func (l *Logger) SetFile(w io.Writer, format Format) {
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+
+	if w == nil {
+		l.sink.file = nil
+		return
+	}
+
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: replaceLevel}
+	if format == FormatJSON {
+		l.sink.file = slog.NewJSONHandler(w, opts)
+	} else {
+		l.sink.file = slog.NewTextHandler(w, opts)
+	}
+}
+
+// SetColorMode sets whether output is colorized
+func (l *Logger) SetColorMode(mode ColorMode) {
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+
+	l.sink.color = mode
+}
+
+// Level returns the current log level
+func (l *Logger) Level() Level {
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+
+	return l.sink.level
+}
+
+// Enabled reports whether messages at the given level will be written to the
+// console
+func (l *Logger) Enabled(level Level) bool {
+	return l.Level() >= level
+}
+
+// With returns a logger that tags its records with the given key/value pairs
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{sink: l.sink, log: l.log.With(args...)}
+}
+
+// ForFile returns a logger that tags its records with a source file and target
+func (l *Logger) ForFile(file, target string) *Logger {
+	return l.With(FileKey, file, TargetKey, target)
+}
+
+// Phase returns a logger that tags its records with a build phase
+func (l *Logger) Phase(phase string) *Logger {
+	return l.With(PhaseKey, phase)
+}
+
+// Errorf writes an error message to stderr
+func (l *Logger) Errorf(format string, args ...any) {
+	l.logf(slog.LevelError, format, args...)
+}
+
+// Warnf writes a warning message to stderr
+func (l *Logger) Warnf(format string, args ...any) {
+	l.logf(slog.LevelWarn, format, args...)
+}
+
+// Successf writes an informational success message (e.g. a cache hit) to stdout
+func (l *Logger) Successf(format string, args ...any) {
+	l.logf(levelSuccess, format, args...)
+}
+
+// Infof writes an informational message to stdout
+func (l *Logger) Infof(format string, args ...any) {
+	l.logf(slog.LevelInfo, format, args...)
+}
+
+// Debugf writes a debug message to stdout
+func (l *Logger) Debugf(format string, args ...any) {
+	l.logf(slog.LevelDebug, format, args...)
+}
+
+func (l *Logger) logf(level slog.Level, format string, args ...any) {
+	ctx := context.Background()
+	if !l.log.Enabled(ctx, level) {
+		return
+	}
+
+	l.log.Log(ctx, level, fmt.Sprintf(format, args...))
+}
+
+// handler is the slog handler behind a Logger: it writes records at or above
+// the console level as plain (optionally colored) lines, and every record to
+// the log file when there is one
+type handler struct {
+	sink  *sink
+	attrs []slog.Attr
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	h.sink.mu.Lock()
+	defer h.sink.mu.Unlock()
+
+	return h.sink.file != nil || h.sink.level >= consoleLevel(level)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	h.sink.mu.Lock()
+	defer h.sink.mu.Unlock()
+
+	if h.sink.file != nil {
+		if err := h.sink.file.WithAttrs(h.attrs).Handle(ctx, r); err != nil {
+			return err
+		}
+	}
+
+	level := consoleLevel(r.Level)
+	if h.sink.level < level {
+		return nil
+	}
+
+	w := h.sink.errOut
+	if level >= LevelInfo {
+		w = h.sink.out
+	}
+
+	msg := r.Message
+	color := ColorNone
+
+	switch {
+	case r.Level >= slog.LevelError:
+		color = ColorRed
+	case r.Level >= slog.LevelWarn:
+		msg, color = "Warning: "+msg, ColorYellow
+	case r.Level == levelSuccess:
+		color = ColorGreen
+	case r.Level < slog.LevelInfo:
+		msg = "debug: " + msg
+	}
+
+	if useColor(h.sink.color, w) {
+		msg = colorize(color, msg)
+	}
+
+	_, err := fmt.Fprintln(w, msg)
+
+	return err
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{sink: h.sink, attrs: append(slices.Clip(h.attrs), attrs...)}
+}
+
+// WithGroup is a no-op: spc's records are flat
+func (h *handler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// consoleLevel maps a slog level to the console level that shows it
+func consoleLevel(level slog.Level) Level {
+	switch {
+	case level >= slog.LevelError:
+		return LevelError
+	case level >= slog.LevelWarn:
+		return LevelWarn
+	case level >= slog.LevelInfo:
+		return LevelInfo
+	default:
+		return LevelDebug
+	}
+}
+
+// replaceLevel records success messages as INFO in log files
+func replaceLevel(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 && a.Value.Any() == levelSuccess {
+		a.Value = slog.StringValue(slog.LevelInfo.String())
+	}
+
+	return a
+}
+
+// SetLevel sets the level of the default logger
+func SetLevel(level Level) {
+	std.SetLevel(level)
+}
+
+// SetColorMode sets whether the default logger colorizes output
+func SetColorMode(mode ColorMode) {
+	std.SetColorMode(mode)
+}
+
+// SetFile writes every record of the default logger to w as well
+func SetFile(w io.Writer, format Format) {
+	std.SetFile(w, format)
+}
+
+// Enabled reports whether the default logger writes messages at the given level
+func Enabled(level Level) bool {
+	return std.Enabled(level)
+}
+
+// With returns a logger derived from the default logger that tags its records
+// with the given key/value pairs
+func With(args ...any) *Logger {
+	return std.With(args...)
+}
+
+// ForFile returns a logger derived from the default logger that tags its
+// records with a source file and target
+func ForFile(file, target string) *Logger {
+	return std.ForFile(file, target)
+}
+
+// Errorf writes an error message using the default logger
+func Errorf(format string, args ...any) {
+	std.Errorf(format, args...)
+}
+
+// Warnf writes a warning message using the default logger
+func Warnf(format string, args ...any) {
+	std.Warnf(format, args...)
+}
+
+// Successf writes a success message using the default logger
+func Successf(format string, args ...any) {
+	std.Successf(format, args...)
+}
+
+// Infof writes an informational message using the default logger
+func Infof(format string, args ...any) {
+	std.Infof(format, args...)
+}
+
+// Debugf writes a debug message using the default logger
+func Debugf(format string, args ...any) {
+	std.Debugf(format, args...)
+}
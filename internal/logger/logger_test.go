@@ -0,0 +1,174 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger_Levels(t *testing.T) {
+	tests := []struct {
+		name       string
+		level      Level
+		wantOut    string
+		wantErrOut string
+	}{
+		{
+			name:       "error level only shows errors",
+			level:      LevelError,
+			wantOut:    "",
+			wantErrOut: "error 1\n",
+		},
+		{
+			name:       "warn level shows warnings and errors",
+			level:      LevelWarn,
+			wantOut:    "",
+			wantErrOut: "error 1\nWarning: warn 2\n",
+		},
+		{
+			name:       "info level adds informational output",
+			level:      LevelInfo,
+			wantOut:    "info 3\n",
+			wantErrOut: "error 1\nWarning: warn 2\n",
+		},
+		{
+			name:       "debug level shows everything",
+			level:      LevelDebug,
+			wantOut:    "info 3\ndebug: debug 4\n",
+			wantErrOut: "error 1\nWarning: warn 2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			l := New(&out, &errOut)
+			l.SetLevel(tt.level)
+
+			l.Errorf("error %d", 1)
+			l.Warnf("warn %d", 2)
+			l.Infof("info %d", 3)
+			l.Debugf("debug %d", 4)
+
+			assert.Equal(t, tt.wantOut, out.String())
+			assert.Equal(t, tt.wantErrOut, errOut.String())
+		})
+	}
+}
+
+func TestLevelFromFlags(t *testing.T) {
+	assert.Equal(t, LevelWarn, LevelFromFlags(0, false))
+	assert.Equal(t, LevelInfo, LevelFromFlags(1, false))
+	assert.Equal(t, LevelDebug, LevelFromFlags(2, false))
+	assert.Equal(t, LevelDebug, LevelFromFlags(3, false))
+	assert.Equal(t, LevelError, LevelFromFlags(0, true))
+	assert.Equal(t, LevelError, LevelFromFlags(2, true))
+}
+
+func TestLogger_Enabled(t *testing.T) {
+	l := New(&bytes.Buffer{}, &bytes.Buffer{})
+	assert.True(t, l.Enabled(LevelWarn))
+	assert.False(t, l.Enabled(LevelInfo))
+
+	l.SetLevel(LevelDebug)
+	assert.True(t, l.Enabled(LevelInfo))
+	assert.Equal(t, LevelDebug, l.Level())
+}
+
+func TestLogger_Color(t *testing.T) {
+	t.Run("buffers are not colorized in auto mode", func(t *testing.T) {
+		var out, errOut bytes.Buffer
+		l := New(&out, &errOut)
+		l.SetLevel(LevelInfo)
+
+		l.Errorf("failed")
+		l.Successf("cached")
+
+		assert.Equal(t, "failed\n", errOut.String())
+		assert.Equal(t, "cached\n", out.String())
+	})
+
+	t.Run("always mode colorizes by severity", func(t *testing.T) {
+		var out, errOut bytes.Buffer
+		l := New(&out, &errOut)
+		l.SetLevel(LevelInfo)
+		l.SetColorMode(ColorAlways)
+
+		l.Errorf("failed")
+		l.Warnf("careful")
+		l.Successf("cached")
+		l.Infof("plain")
+
+		assert.Equal(t, "\x1b[31mfailed\x1b[0m\n\x1b[33mWarning: careful\x1b[0m\n", errOut.String())
+		assert.Equal(t, "\x1b[32mcached\x1b[0m\nplain\n", out.String())
+	})
+
+	t.Run("never mode disables color", func(t *testing.T) {
+		assert.False(t, useColor(ColorNever, os.Stdout))
+	})
+
+	t.Run("NO_COLOR disables auto color", func(t *testing.T) {
+		t.Setenv(NoColorEnv, "1")
+		assert.False(t, useColor(ColorAuto, os.Stdout))
+	})
+}
+
+func TestLogger_File(t *testing.T) {
+	var out, errOut, file bytes.Buffer
+	l := New(&out, &errOut)
+	l.SetFile(&file, FormatJSON)
+
+	log := l.ForFile("/src/a.usp", "34").Phase(PhaseCache)
+	log.Successf("cached %s", "a.usp")
+	log.Debugf("cache hit")
+	l.Warnf("careful")
+
+	// The console level still applies to the console
+	assert.Empty(t, out.String())
+	assert.Equal(t, "Warning: careful\n", errOut.String())
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(file.String()), "\n") {
+		var r map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &r))
+		records = append(records, r)
+	}
+
+	require.Len(t, records, 3)
+	assert.Equal(t, "INFO", records[0]["level"])
+	assert.Equal(t, "cached a.usp", records[0]["msg"])
+	assert.Equal(t, "/src/a.usp", records[0][FileKey])
+	assert.Equal(t, "34", records[0][TargetKey])
+	assert.Equal(t, PhaseCache, records[0][PhaseKey])
+	assert.Equal(t, "DEBUG", records[1]["level"])
+	assert.Equal(t, "WARN", records[2]["level"])
+	assert.NotContains(t, records[2], FileKey)
+
+	l.SetFile(nil, FormatText)
+	l.Warnf("again")
+	assert.Len(t, strings.Split(strings.TrimSpace(file.String()), "\n"), 3)
+}
+
+func TestLogger_TextFile(t *testing.T) {
+	var file bytes.Buffer
+	l := New(&bytes.Buffer{}, &bytes.Buffer{})
+	l.SetFile(&file, FormatText)
+
+	l.ForFile("a.usp", "3").Phase(PhaseCompile).Errorf("failed")
+
+	assert.Contains(t, file.String(), `level=ERROR msg=failed file=a.usp target=3 phase=compile`)
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("json")
+	require.NoError(t, err)
+	assert.Equal(t, FormatJSON, f)
+
+	_, err = ParseFormat("xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,304 @@
+// Package lsp implements a minimal Language Server for SIMPL+: when an editor
+// saves a .usp or .usl file, the file is compiled and the compiler's errors
+// and warnings are published as diagnostics. It speaks JSON-RPC 2.0 with
+// Content-Length framing, as every LSP client does, and supports only the
+// lifecycle messages and didSave.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+// CompileFunc compiles a source file and returns the diagnostics found. An
+// error means the file could not be compiled at all, or failed without any
+// diagnostics
+type CompileFunc func(file string) ([]diag.Diagnostic, error)
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+)
+
+// ErrExitWithoutShutdown is returned by Serve when the client sent exit
+// without asking the server to shut down first
+var ErrExitWithoutShutdown = errors.New("exit without shutdown")
+
+// Server is a language server reading requests from a client and writing
+// responses and notifications back
+type Server struct {
+	// Version is reported to the client in the initialize response
+	Version string
+
+	compile CompileFunc
+	in      *bufio.Reader
+	out     io.Writer
+
+	writeMu sync.Mutex
+
+	// buildMu allows one compile at a time
+	buildMu sync.Mutex
+	builds  sync.WaitGroup
+
+	mu       sync.Mutex
+	shutdown bool
+
+	// published maps each compiled source to the URIs its last compile
+	// published diagnostics for, so fixed problems are cleared
+	published map[string][]string
+}
+
+// NewServer creates a server talking to a client over in and out
+func NewServer(in io.Reader, out io.Writer, compile CompileFunc) *Server {
+	return &Server{
+		compile:   compile,
+		in:        bufio.NewReader(in),
+		out:       out,
+		published: make(map[string][]string),
+	}
+}
+
+// message is a JSON-RPC request or notification from the client
+type message struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// responseError is the error of a failed request
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Serve handles messages until the client sends exit or closes the
+// connection, then waits for running compiles to finish
+func (s *Server) Serve() error {
+	defer s.builds.Wait()
+
+	for {
+		body, err := s.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.reply(nil, nil, &responseError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+
+		if msg.Method == "exit" {
+			if !s.isShutdown() {
+				return ErrExitWithoutShutdown
+			}
+
+			return nil
+		}
+
+		s.handle(msg)
+	}
+}
+
+// read reads the body of the next message
+func (s *Server) read() ([]byte, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+
+		return nil, fmt.Errorf("failed to read message header: %w", err)
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+
+	return body, nil
+}
+
+// handle dispatches a request or notification
+func (s *Server) handle(msg message) {
+	isRequest := len(msg.ID) > 0
+
+	if isRequest && s.isShutdown() {
+		s.reply(msg.ID, nil, &responseError{Code: codeInvalidRequest, Message: "server is shut down"})
+		return
+	}
+
+	switch msg.Method {
+	case "initialize":
+		s.reply(msg.ID, s.initializeResult(), nil)
+	case "shutdown":
+		s.mu.Lock()
+		s.shutdown = true
+		s.mu.Unlock()
+
+		s.reply(msg.ID, nil, nil)
+	case "textDocument/didSave":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+
+		if err := json.Unmarshal(msg.Params, &params); err == nil {
+			s.didSave(params.TextDocument.URI)
+		}
+	default:
+		// Other notifications (initialized, didOpen, didClose, $/...) need
+		// no action
+		if isRequest {
+			s.reply(msg.ID, nil, &responseError{Code: codeMethodNotFound, Message: "method not supported: " + msg.Method})
+		}
+	}
+}
+
+// initializeResult tells the client to send open, close and save
+// notifications, without the text of saved files
+func (s *Server) initializeResult() any {
+	return map[string]any{
+		"capabilities": map[string]any{
+			"textDocumentSync": map[string]any{
+				"openClose": true,
+				"change":    0,
+				"save":      map[string]any{"includeText": false},
+			},
+		},
+		"serverInfo": map[string]any{"name": "spc", "version": s.Version},
+	}
+}
+
+// didSave compiles a saved SIMPL+ file in the background and publishes its
+// diagnostics
+func (s *Server) didSave(uri string) {
+	path, err := uriToPath(uri)
+	if err != nil {
+		return
+	}
+
+	if ext := strings.ToLower(filepath.Ext(path)); ext != ".usp" && ext != ".usl" {
+		return
+	}
+
+	s.builds.Add(1)
+
+	go func() {
+		defer s.builds.Done()
+
+		s.buildMu.Lock()
+		diagnostics, err := s.compile(path)
+		s.buildMu.Unlock()
+
+		if err != nil {
+			s.notify("window/showMessage", map[string]any{
+				"type":    messageTypeError,
+				"message": fmt.Sprintf("spc: %s: %v", filepath.Base(path), err),
+			})
+		}
+
+		s.publish(path, uri, diagnostics)
+	}()
+}
+
+// messageTypeError is the LSP MessageType of an error message
+const messageTypeError = 1
+
+// publish sends the diagnostics of a compile grouped by file, clearing those
+// files the previous compile of the source reported on that now have none
+func (s *Server) publish(source, uri string, diagnostics []diag.Diagnostic) {
+	byURI := map[string][]lspDiagnostic{uri: {}}
+	order := []string{uri}
+
+	for _, d := range diagnostics {
+		u := uri
+		if d.File != source {
+			u = pathToURI(d.File)
+		}
+
+		if _, ok := byURI[u]; !ok {
+			order = append(order, u)
+		}
+
+		byURI[u] = append(byURI[u], toLSP(d))
+	}
+
+	s.mu.Lock()
+	for _, u := range s.published[source] {
+		if _, ok := byURI[u]; !ok {
+			byURI[u] = []lspDiagnostic{}
+			order = append(order, u)
+		}
+	}
+
+	s.published[source] = order
+	s.mu.Unlock()
+
+	for _, u := range order {
+		s.notify("textDocument/publishDiagnostics", map[string]any{"uri": u, "diagnostics": byURI[u]})
+	}
+}
+
+// reply sends the response to a request
+func (s *Server) reply(id json.RawMessage, result any, respErr *responseError) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+
+	msg := map[string]any{"jsonrpc": "2.0", "id": id}
+	if respErr != nil {
+		msg["error"] = respErr
+	} else {
+		msg["result"] = result
+	}
+
+	s.write(msg)
+}
+
+// notify sends a notification to the client
+func (s *Server) notify(method string, params any) {
+	s.write(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// write sends a message with its Content-Length header
+func (s *Server) write(msg any) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	_, _ = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *Server) isShutdown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.shutdown
+}
@@ -0,0 +1,166 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+// frame encodes messages as a client sends them
+func frame(t *testing.T, messages ...map[string]any) io.Reader {
+	t.Helper()
+
+	var b bytes.Buffer
+	for _, msg := range messages {
+		msg["jsonrpc"] = "2.0"
+
+		body, err := json.Marshal(msg)
+		require.NoError(t, err)
+		fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	return &b
+}
+
+// received decodes the messages the server wrote
+func received(t *testing.T, out *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var messages []map[string]any
+
+	r := bufio.NewReader(out)
+	for {
+		header, err := textproto.NewReader(r).ReadMIMEHeader()
+		if err == io.EOF {
+			return messages
+		}
+
+		require.NoError(t, err)
+
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		require.NoError(t, err)
+
+		body := make([]byte, length)
+		_, err = io.ReadFull(r, body)
+		require.NoError(t, err)
+
+		var msg map[string]any
+		require.NoError(t, json.Unmarshal(body, &msg))
+		messages = append(messages, msg)
+	}
+}
+
+func TestServer_Serve(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "Room.usp")
+	require.NoError(t, os.WriteFile(source, []byte("FUNCTION Main()\n{\n    y = z + 1;\n}\n"), 0o644))
+
+	var compiled []string
+	compile := func(file string) ([]diag.Diagnostic, error) {
+		compiled = append(compiled, file)
+		return []diag.Diagnostic{{File: file, Line: 3, Severity: diag.SeverityError, Code: "1001", Message: "Undefined variable"}}, nil
+	}
+
+	in := frame(t,
+		map[string]any{"id": 1, "method": "initialize", "params": map[string]any{}},
+		map[string]any{"method": "initialized", "params": map[string]any{}},
+		map[string]any{"method": "textDocument/didSave", "params": map[string]any{"textDocument": map[string]any{"uri": pathToURI(source)}}},
+		map[string]any{"method": "textDocument/didSave", "params": map[string]any{"textDocument": map[string]any{"uri": pathToURI(filepath.Join(dir, "notes.txt"))}}},
+		map[string]any{"id": 2, "method": "textDocument/hover", "params": map[string]any{}},
+		map[string]any{"id": 3, "method": "shutdown"},
+		map[string]any{"method": "exit"},
+	)
+
+	var out bytes.Buffer
+	s := NewServer(in, &out, compile)
+	s.Version = "1.2.3"
+
+	require.NoError(t, s.Serve())
+	assert.Equal(t, []string{source}, compiled, "only SIMPL+ files are compiled")
+
+	messages := received(t, &out)
+	require.Len(t, messages, 4)
+
+	assert.Equal(t, float64(1), messages[0]["id"])
+	result := messages[0]["result"].(map[string]any)
+	assert.Equal(t, map[string]any{"name": "spc", "version": "1.2.3"}, result["serverInfo"])
+
+	byID := make(map[float64]map[string]any)
+	var published map[string]any
+	for _, msg := range messages[1:] {
+		if id, ok := msg["id"].(float64); ok {
+			byID[id] = msg
+		} else {
+			published = msg
+		}
+	}
+
+	assert.Equal(t, float64(codeMethodNotFound), byID[2]["error"].(map[string]any)["code"])
+	assert.Contains(t, byID[3], "result")
+	assert.Nil(t, byID[3]["result"])
+
+	require.NotNil(t, published)
+	assert.Equal(t, "textDocument/publishDiagnostics", published["method"])
+
+	params := published["params"].(map[string]any)
+	assert.Equal(t, pathToURI(source), params["uri"])
+	assert.Equal(t, []any{map[string]any{
+		"range": map[string]any{
+			"start": map[string]any{"line": float64(2), "character": float64(4)},
+			"end":   map[string]any{"line": float64(2), "character": float64(14)},
+		},
+		"severity": float64(severityError),
+		"code":     "1001",
+		"source":   "spc",
+		"message":  "Undefined variable",
+	}}, params["diagnostics"])
+}
+
+func TestServer_Serve_ExitWithoutShutdown(t *testing.T) {
+	var out bytes.Buffer
+	s := NewServer(frame(t, map[string]any{"method": "exit"}), &out, nil)
+
+	assert.ErrorIs(t, s.Serve(), ErrExitWithoutShutdown)
+}
+
+func TestServer_Publish_ClearsFixedFiles(t *testing.T) {
+	dir := t.TempDir()
+	source, library := filepath.Join(dir, "Room.usp"), filepath.Join(dir, "Lib.usl")
+
+	var out bytes.Buffer
+	s := NewServer(&bytes.Buffer{}, &out, nil)
+
+	s.publish(source, pathToURI(source), []diag.Diagnostic{{File: library, Line: 1, Severity: diag.SeverityWarning, Code: "1401", Message: "Unused"}})
+	s.publish(source, pathToURI(source), nil)
+
+	var got []string
+	for _, msg := range received(t, &out) {
+		params := msg["params"].(map[string]any)
+		got = append(got, fmt.Sprintf("%s %d", filepath.Base(params["uri"].(string)), len(params["diagnostics"].([]any))))
+	}
+
+	assert.Equal(t, []string{"Room.usp 0", "Lib.usl 1", "Room.usp 0", "Lib.usl 0"}, got)
+}
+
+func TestURIs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "My Project", "Room.usp")
+
+	got, err := uriToPath(pathToURI(path))
+	require.NoError(t, err)
+	assert.Equal(t, path, got)
+
+	_, err = uriToPath("untitled:Untitled-1")
+	assert.Error(t, err)
+}
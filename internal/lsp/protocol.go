@@ -0,0 +1,118 @@
+package lsp
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+// position is a zero-based line and character in a document
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// lspRange is the span of a document a diagnostic refers to
+type lspRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+// lspDiagnostic is a diagnostic as published to the client
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code,omitempty"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+// LSP DiagnosticSeverity values
+const (
+	severityError   = 1
+	severityWarning = 2
+)
+
+// toLSP converts a compiler diagnostic. Without a column, it spans the code
+// on the line
+func toLSP(d diag.Diagnostic) lspDiagnostic {
+	line := max(d.Line-1, 0)
+	text := sourceLine(d.File, d.Line)
+
+	start := len(text) - len(strings.TrimLeft(text, " \t"))
+	end := len(strings.TrimRight(text, " \t"))
+	if d.Column > 0 {
+		start, end = d.Column-1, d.Column
+	}
+
+	severity := severityError
+	if d.Severity == diag.SeverityWarning {
+		severity = severityWarning
+	}
+
+	return lspDiagnostic{
+		Range:    lspRange{Start: position{line, start}, End: position{line, max(end, start)}},
+		Severity: severity,
+		Code:     d.Code,
+		Source:   "spc",
+		Message:  d.Message,
+	}
+}
+
+// sourceLine returns a 1-based line of a file, or an empty string
+func sourceLine(path string, n int) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for i := 1; scanner.Scan(); i++ {
+		if i == n {
+			return strings.TrimRight(scanner.Text(), "\r")
+		}
+	}
+
+	return ""
+}
+
+// uriToPath converts a file:// URI to a local path
+func uriToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URI %q", uri)
+	}
+
+	path := u.Path
+
+	// file:///C:/Project/Room.usp has the path /C:/Project/Room.usp
+	if runtime.GOOS == "windows" && len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+
+	return filepath.FromSlash(path), nil
+}
+
+// pathToURI converts a local path to a file:// URI
+func pathToURI(path string) string {
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
@@ -0,0 +1,103 @@
+// Package metrics records build health as Prometheus metrics for long-running
+// spc processes (the daemon), served from a /metrics endpoint
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"os/exec"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/Norgate-AV/spc/internal/build"
+)
+
+// Path is where the metrics are served
+const Path = "/metrics"
+
+// Metrics holds the counters and histograms of a process
+type Metrics struct {
+	registry *prometheus.Registry
+
+	builds          *prometheus.CounterVec
+	cacheHits       prometheus.Counter
+	cacheMisses     prometheus.Counter
+	failures        *prometheus.CounterVec
+	compileDuration prometheus.Histogram
+}
+
+// New creates the metrics, registered with their own registry along with the
+// standard Go and process collectors
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		builds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "spc_builds_total",
+			Help: "Files built, by result (success, cached or failed).",
+		}, []string{"status"}),
+		cacheHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "spc_cache_hits_total",
+			Help: "Files restored from the build cache.",
+		}),
+		cacheMisses: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "spc_cache_misses_total",
+			Help: "Files that had to be compiled.",
+		}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "spc_build_failures_total",
+			Help: "Files that failed to build, by compiler exit code (none when the compiler did not run or exit).",
+		}, []string{"exit_code"}),
+		compileDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "spc_compile_duration_seconds",
+			Help:    "Time taken to compile a file.",
+			Buckets: prometheus.ExponentialBuckets(0.25, 2, 10),
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.builds, m.cacheHits, m.cacheMisses, m.failures, m.compileDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// Observe records the result of building a file
+func (m *Metrics) Observe(r build.Result) {
+	if !r.Status.Done() {
+		return
+	}
+
+	m.builds.WithLabelValues(r.Status.String()).Inc()
+
+	if r.Status == build.StatusCached {
+		m.cacheHits.Inc()
+		return
+	}
+
+	m.cacheMisses.Inc()
+	m.compileDuration.Observe(r.Duration.Seconds())
+
+	if r.Status == build.StatusFailed {
+		m.failures.WithLabelValues(exitCode(r.Err)).Inc()
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// exitCode returns the compiler exit code that caused a failure, or "none"
+func exitCode(err error) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return strconv.Itoa(exitErr.ExitCode())
+	}
+
+	return "none"
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/build"
+)
+
+// exitError returns the error of a process that exited with code 3
+func exitError(t *testing.T) error {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+
+	err := exec.Command("sh", "-c", "exit 3").Run()
+	require.Error(t, err)
+
+	return err
+}
+
+func TestMetrics(t *testing.T) {
+	m := New()
+
+	m.Observe(build.Result{Status: build.StatusSucceeded, Duration: 2 * time.Second})
+	m.Observe(build.Result{Status: build.StatusCached, Duration: time.Millisecond})
+	m.Observe(build.Result{Status: build.StatusFailed, Duration: time.Second, Err: exitError(t)})
+	m.Observe(build.Result{Status: build.StatusFailed, Err: errors.New("compiler not found")})
+	m.Observe(build.Result{Status: build.StatusCompiling})
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", Path, nil))
+
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+
+	for _, line := range []string{
+		`spc_builds_total{status="success"} 1`,
+		`spc_builds_total{status="cached"} 1`,
+		`spc_builds_total{status="failed"} 2`,
+		`spc_cache_hits_total 1`,
+		`spc_cache_misses_total 3`,
+		`spc_build_failures_total{exit_code="3"} 1`,
+		`spc_build_failures_total{exit_code="none"} 1`,
+		`spc_compile_duration_seconds_count 3`,
+	} {
+		assert.Contains(t, string(body), line+"\n")
+	}
+}
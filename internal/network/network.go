@@ -0,0 +1,53 @@
+// Package network builds the HTTP transport used for remote cache and
+// self-update traffic, so it works from behind corporate proxies
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// Transport returns an HTTP transport that goes through the proxies in
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY, and also trusts the CA certificates
+// in network.ca_file
+func Transport(cfg config.NetworkConfig) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+
+	if cfg.CAFile == "" {
+		return t, nil
+	}
+
+	pool, err := certPool(cfg.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	t.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+
+	return t, nil
+}
+
+// certPool returns the system's trusted certificates plus those in a PEM file
+func certPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read network.ca_file: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("network.ca_file %s has no PEM certificates", path)
+	}
+
+	return pool, nil
+}
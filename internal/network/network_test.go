@@ -0,0 +1,55 @@
+package network
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+func TestTransport_CAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	// The test server's certificate isn't trusted by default
+	transport, err := Transport(config.NetworkConfig{})
+	require.NoError(t, err)
+	assert.NotNil(t, transport.Proxy)
+
+	_, err = (&http.Client{Transport: transport}).Get(server.URL)
+	require.Error(t, err)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(block), 0o644))
+
+	transport, err = Transport(config.NetworkConfig{CAFile: caFile})
+	require.NoError(t, err)
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestTransport_BadCAFile(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := Transport(config.NetworkConfig{CAFile: filepath.Join(dir, "missing.pem")})
+	assert.ErrorContains(t, err, "failed to read network.ca_file")
+
+	notPEM := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o644))
+
+	_, err = Transport(config.NetworkConfig{CAFile: notPEM})
+	assert.ErrorContains(t, err, "has no PEM certificates")
+}
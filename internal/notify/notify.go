@@ -0,0 +1,105 @@
+// Package notify shows desktop notifications: a toast on Windows, Notification
+// Center on macOS and notify-send (libnotify) on Linux. They tell an engineer
+// who tabbed away during a long build that it has finished.
+package notify
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// appID is the Windows application the toast is shown for. Toasts need a
+// registered application, and spc isn't one, so it borrows PowerShell's
+const appID = `{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe`
+
+// toastScript shows a toast with the title and message from the environment,
+// so neither needs quoting for PowerShell
+const toastScript = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName('text')
+$text.Item(0).AppendChild($template.CreateTextNode($env:SPC_NOTIFY_TITLE)) > $null
+$text.Item(1).AppendChild($template.CreateTextNode($env:SPC_NOTIFY_MESSAGE)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('` + appID + `').Show([Windows.UI.Notifications.ToastNotification]::new($template))`
+
+// Notification is a desktop notification
+type Notification struct {
+	Title   string
+	Message string
+
+	// Failed marks the notification as urgent where the desktop supports it
+	Failed bool
+}
+
+// ForBuild returns the notification for a build of project (a directory,
+// empty for none) in which failed of files files failed to compile
+func ForBuild(project string, files, failed int, elapsed time.Duration) Notification {
+	name := "spc build"
+	if project != "" {
+		name += " of " + filepath.Base(project)
+	}
+
+	elapsed = elapsed.Round(time.Second / 10)
+
+	if failed > 0 {
+		return Notification{
+			Title:   "✗ " + name + " failed",
+			Message: fmt.Sprintf("%d of %d file(s) did not compile (%s)", failed, files, elapsed),
+			Failed:  true,
+		}
+	}
+
+	return Notification{
+		Title:   "✓ " + name + " succeeded",
+		Message: fmt.Sprintf("%d file(s) built in %s", files, elapsed),
+	}
+}
+
+// Send shows a notification on the desktop
+func Send(n Notification) error {
+	cmd, err := command(runtime.GOOS, n)
+	if err != nil {
+		return err
+	}
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s: %w: %s", filepath.Base(cmd.Path), err, msg)
+		}
+
+		return fmt.Errorf("%s: %w", filepath.Base(cmd.Path), err)
+	}
+
+	return nil
+}
+
+// command returns the command that shows a notification on goos
+func command(goos string, n Notification) (*exec.Cmd, error) {
+	switch goos {
+	case "windows":
+		cmd := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", toastScript)
+		cmd.Env = append(os.Environ(), "SPC_NOTIFY_TITLE="+n.Title, "SPC_NOTIFY_MESSAGE="+n.Message)
+
+		return cmd, nil
+	case "darwin":
+		// Passed as arguments, so neither needs quoting for AppleScript
+		return exec.Command("osascript",
+			"-e", "on run argv",
+			"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+			"-e", "end run",
+			n.Title, n.Message), nil
+	case "linux", "freebsd", "openbsd", "netbsd":
+		urgency := "normal"
+		if n.Failed {
+			urgency = "critical"
+		}
+
+		return exec.Command("notify-send", "--app-name=spc", "--urgency="+urgency, n.Title, n.Message), nil
+	default:
+		return nil, fmt.Errorf("desktop notifications are not supported on %s", goos)
+	}
+}
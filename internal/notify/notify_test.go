@@ -0,0 +1,38 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForBuild(t *testing.T) {
+	n := ForBuild("/work/Room", 3, 0, 90*time.Second)
+	assert.Equal(t, Notification{Title: "✓ spc build of Room succeeded", Message: "3 file(s) built in 1m30s"}, n)
+
+	n = ForBuild("", 3, 2, 1500*time.Millisecond)
+	assert.Equal(t, Notification{Title: "✗ spc build failed", Message: "2 of 3 file(s) did not compile (1.5s)", Failed: true}, n)
+}
+
+func TestCommand(t *testing.T) {
+	n := Notification{Title: `"Room" failed`, Message: "it's broken", Failed: true}
+
+	cmd, err := command("linux", n)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"notify-send", "--app-name=spc", "--urgency=critical", `"Room" failed`, "it's broken"}, cmd.Args)
+
+	cmd, err = command("darwin", n)
+	require.NoError(t, err)
+	assert.Equal(t, "osascript", cmd.Args[0])
+	assert.Equal(t, []string{`"Room" failed`, "it's broken"}, cmd.Args[len(cmd.Args)-2:])
+
+	cmd, err = command("windows", n)
+	require.NoError(t, err)
+	assert.Contains(t, cmd.Env, `SPC_NOTIFY_TITLE="Room" failed`)
+	assert.Contains(t, cmd.Env, "SPC_NOTIFY_MESSAGE=it's broken")
+
+	_, err = command("plan9", n)
+	assert.ErrorContains(t, err, "not supported on plan9")
+}
@@ -0,0 +1,241 @@
+// Package pack archives compiled SIMPL+ modules into a zip for handing off to
+// integrators. Each module's .ush and SPlsWork outputs go under a directory
+// named after the module, and manifest.json at the root lists every file
+// with its SHA256
+package pack
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+)
+
+// ManifestName is the name of the manifest inside the archive
+const ManifestName = "manifest.json"
+
+// FormatVersion is the version of the archive layout and manifest
+const FormatVersion = 1
+
+// Manifest describes the contents of an archive
+type Manifest struct {
+	// Format is the archive format version (FormatVersion)
+	Format int `json:"format"`
+
+	// Release is the version given to the package (--release), if any
+	Release string `json:"release,omitempty"`
+
+	// SPC is the version of spc that created the archive
+	SPC string `json:"spc,omitempty"`
+
+	// Created is when the archive was created
+	Created time.Time `json:"created"`
+
+	// Modules are the packed modules
+	Modules []Module `json:"modules"`
+}
+
+// Module is a compiled module in an archive
+type Module struct {
+	// Name is the module name (the source file name without extension), which
+	// is also its directory in the archive
+	Name string `json:"name"`
+
+	// Source is the source file, relative to the project directory when inside it
+	Source string `json:"source"`
+
+	// SourceHash is the SHA256 of the source file
+	SourceHash string `json:"source_hash"`
+
+	// Target is the series the module was compiled for
+	Target string `json:"target"`
+
+	// Key is the build cache key of the module
+	Key string `json:"key,omitempty"`
+
+	// Files are the module's artifacts
+	Files []File `json:"files"`
+
+	// dir is the source directory the artifact paths are relative to
+	dir string
+}
+
+// File is an artifact in an archive
+type File struct {
+	// Path is the file's path in the archive (e.g. "example/SPlsWork/example.dll")
+	Path string `json:"path"`
+
+	// Size is the file size in bytes
+	Size int64 `json:"size"`
+
+	// SHA256 is the hash of the file content
+	SHA256 string `json:"sha256"`
+
+	// src is the file on disk, relative to the module's source directory
+	src string
+}
+
+// Collect gathers the artifacts of a built source file for a target. Errors if
+// the module has not been built
+func Collect(sourceFile, target, projectDir string) (Module, error) {
+	name := strings.TrimSuffix(filepath.Base(sourceFile), filepath.Ext(sourceFile))
+
+	m := Module{Name: name, Source: filepath.ToSlash(sourceFile), Target: target, dir: filepath.Dir(sourceFile)}
+	if projectDir != "" {
+		if rel, err := filepath.Rel(projectDir, sourceFile); err == nil && !strings.HasPrefix(rel, "..") {
+			m.Source = filepath.ToSlash(rel)
+		}
+	}
+
+	hash, err := cache.HashFile(sourceFile)
+	if err != nil {
+		return m, fmt.Errorf("failed to hash %s: %w", sourceFile, err)
+	}
+
+	m.SourceHash = hash
+
+	outputs, err := cache.CollectOutputs(sourceFile, target)
+	if err != nil {
+		return m, err
+	}
+
+	if len(outputs) == 0 {
+		return m, fmt.Errorf("%s has not been built for target %s", filepath.Base(sourceFile), target)
+	}
+
+	for _, output := range outputs {
+		src := filepath.Join(m.dir, output)
+
+		info, err := os.Stat(src)
+		if err != nil {
+			return m, err
+		}
+
+		hash, err := cache.HashFile(src)
+		if err != nil {
+			return m, fmt.Errorf("failed to hash %s: %w", output, err)
+		}
+
+		m.Files = append(m.Files, File{
+			Path:   path.Join(name, filepath.ToSlash(output)),
+			Size:   info.Size(),
+			SHA256: hash,
+			src:    output,
+		})
+	}
+
+	return m, nil
+}
+
+// Write creates the archive at dest. The archive is written to a temporary
+// file first, so an existing archive is only replaced once the new one is complete
+func Write(dest string, manifest *Manifest) error {
+	seen := make(map[string]string)
+	for _, m := range manifest.Modules {
+		if other, ok := seen[strings.ToLower(m.Name)]; ok {
+			return fmt.Errorf("modules %s and %s have the same name", other, m.Source)
+		}
+
+		seen[strings.ToLower(m.Name)] = m.Source
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".spc-pack-*")
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if err := writeZip(tmp, manifest); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	return nil
+}
+
+// writeZip writes the manifest and every module's files to w
+func writeZip(w io.Writer, manifest *Manifest) error {
+	zw := zip.NewWriter(w)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: ManifestName, Method: zip.Deflate, Modified: manifest.Created})
+	if err != nil {
+		return err
+	}
+
+	if _, err := mw.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	for _, m := range manifest.Modules {
+		for _, f := range m.Files {
+			if err := addFile(zw, filepath.Join(m.dir, f.src), f.Path, manifest.Created); err != nil {
+				return fmt.Errorf("failed to add %s: %w", f.Path, err)
+			}
+		}
+	}
+
+	return zw.Close()
+}
+
+// addFile copies a file into the archive
+func addFile(zw *zip.Writer, src, name string, modified time.Time) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Read returns the manifest of an archive
+func Read(archive string) (*Manifest, error) {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	defer zr.Close()
+
+	f, err := zr.Open(ManifestName)
+	if err != nil {
+		return nil, fmt.Errorf("%s has no %s: %w", archive, ManifestName, err)
+	}
+
+	defer f.Close()
+
+	var m Manifest
+	if err := json.NewDecoder(f).Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid %s in %s: %w", ManifestName, archive, err)
+	}
+
+	return &m, nil
+}
@@ -0,0 +1,115 @@
+package pack
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeBuilt creates a source file with the outputs the compiler would leave
+func writeBuilt(t *testing.T, dir, name string) string {
+	files := []string{
+		name + ".usp",
+		name + ".ush",
+		filepath.Join("SPlsWork", name+".dll"),
+		filepath.Join("SPlsWork", "S2_"+name+".elf"),
+		filepath.Join("SPlsWork", "Version.ini"),
+	}
+
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(f), 0o644))
+	}
+
+	return filepath.Join(dir, name+".usp")
+}
+
+func TestCollect(t *testing.T) {
+	dir := t.TempDir()
+	source := writeBuilt(t, filepath.Join(dir, "src"), "example")
+
+	m, err := Collect(source, "34", dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, "example", m.Name)
+	assert.Equal(t, "src/example.usp", m.Source)
+	assert.Equal(t, "34", m.Target)
+	assert.Len(t, m.SourceHash, 64)
+
+	var paths []string
+	for _, f := range m.Files {
+		paths = append(paths, f.Path)
+		assert.Len(t, f.SHA256, 64)
+		assert.Positive(t, f.Size)
+	}
+
+	assert.ElementsMatch(t, []string{"example/example.ush", "example/SPlsWork/example.dll"}, paths)
+}
+
+func TestCollect_NotBuilt(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "example.usp")
+	require.NoError(t, os.WriteFile(source, []byte("// x"), 0o644))
+
+	_, err := Collect(source, "34", "")
+	assert.ErrorContains(t, err, "has not been built")
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	a, err := Collect(writeBuilt(t, filepath.Join(dir, "a"), "first"), "3", dir)
+	require.NoError(t, err)
+
+	b, err := Collect(writeBuilt(t, filepath.Join(dir, "b"), "second"), "2", dir)
+	require.NoError(t, err)
+
+	dest := filepath.Join(dir, "modules.zip")
+	manifest := &Manifest{Format: FormatVersion, Release: "1.2.0", Created: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Modules: []Module{a, b}}
+	require.NoError(t, Write(dest, manifest))
+
+	zr, err := zip.OpenReader(dest)
+	require.NoError(t, err)
+	defer zr.Close()
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+
+	assert.ElementsMatch(t, []string{
+		ManifestName,
+		"first/first.ush", "first/SPlsWork/first.dll",
+		"second/second.ush", "second/SPlsWork/S2_second.elf",
+	}, names)
+
+	read, err := Read(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.0", read.Release)
+	assert.Equal(t, manifest.Created, read.Created)
+	require.Len(t, read.Modules, 2)
+	assert.Equal(t, a.Name, read.Modules[0].Name)
+	require.Len(t, read.Modules[0].Files, len(a.Files))
+	assert.Equal(t, a.Files[0].SHA256, read.Modules[0].Files[0].SHA256)
+}
+
+func TestWrite_DuplicateNames(t *testing.T) {
+	dir := t.TempDir()
+
+	a, err := Collect(writeBuilt(t, filepath.Join(dir, "a"), "example"), "3", dir)
+	require.NoError(t, err)
+
+	b, err := Collect(writeBuilt(t, filepath.Join(dir, "b"), "example"), "3", dir)
+	require.NoError(t, err)
+
+	err = Write(filepath.Join(dir, "modules.zip"), &Manifest{Modules: []Module{a, b}})
+	assert.ErrorContains(t, err, "have the same name")
+
+	_, err = os.Stat(filepath.Join(dir, "modules.zip"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,162 @@
+// Package plugin runs git-style external subcommands: "spc foo" runs an
+// executable named spc-foo found on PATH, with a description of the project
+// (its config and source files) in the environment and a JSON context file.
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Prefix is the prefix of plugin executable names
+const Prefix = "spc-"
+
+// ContextEnv names the environment variable holding the path of the JSON
+// context file
+const ContextEnv = "SPC_CONTEXT"
+
+// namePattern matches plugin names: what can follow "spc-" in a command name
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Context describes the invocation and project to a plugin
+type Context struct {
+	// Version of spc
+	Version string `json:"version"`
+
+	// Executable is the path of spc, so plugins can run it
+	Executable string `json:"executable"`
+
+	// WorkDir is the directory spc was run from
+	WorkDir string `json:"work_dir"`
+
+	// ProjectDir is the project directory (where the local config is)
+	ProjectDir string `json:"project_dir,omitempty"`
+
+	// ConfigFile is the local config file, if there is one
+	ConfigFile string `json:"config_file,omitempty"`
+
+	// Config is the project's effective configuration
+	Config *Config `json:"config,omitempty"`
+
+	// Files are the project's source files
+	Files []string `json:"files"`
+
+	// ConfigError is why the config could not be loaded, in which case
+	// Config and Files are empty
+	ConfigError string `json:"config_error,omitempty"`
+}
+
+// Config is the part of the effective configuration plugins get
+type Config struct {
+	Profile       string            `json:"profile,omitempty"`
+	Target        string            `json:"target"`
+	CompilerPath  string            `json:"compiler_path"`
+	CompilerPaths map[string]string `json:"compiler_paths,omitempty"`
+	UserFolders   []string          `json:"usersplusfolder"`
+	OutDir        string            `json:"out_dir,omitempty"`
+	CacheDir      string            `json:"cache_dir,omitempty"`
+}
+
+// Find returns the executable of the plugin named by a command line
+// argument, if there is one on PATH
+func Find(name string) (string, bool) {
+	if !namePattern.MatchString(name) {
+		return "", false
+	}
+
+	path, err := exec.LookPath(Prefix + name)
+	if err != nil {
+		return "", false
+	}
+
+	return path, true
+}
+
+// Run runs a plugin with args, its context in the environment and a context
+// file, connected to the terminal. Returns the plugin's exit code
+func Run(path string, args []string, ctx Context, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	file, err := writeContext(ctx)
+	if err != nil {
+		return 1, err
+	}
+
+	defer os.Remove(file)
+
+	cmd := exec.Command(path, args...)
+	cmd.Env = append(os.Environ(), environ(ctx, file)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+
+	err = cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+
+	if err != nil {
+		return 1, fmt.Errorf("failed to run %s: %w", path, err)
+	}
+
+	return 0, nil
+}
+
+// environ returns the environment variables describing the context:
+//
+//	SPC_CONTEXT        path of the JSON context file
+//	SPC_BIN            path of spc
+//	SPC_VERSION        version of spc
+//	SPC_PROJECT_DIR    project directory
+//	SPC_CONFIG         local config file
+//	SPC_PLUGIN_TARGET  target series
+//	SPC_FILES          source files, separated by the path list separator
+//
+// The target isn't exported as SPC_TARGET, which would override the target
+// of any spc the plugin runs
+func environ(ctx Context, contextFile string) []string {
+	env := []string{
+		ContextEnv + "=" + contextFile,
+		"SPC_BIN=" + ctx.Executable,
+		"SPC_VERSION=" + ctx.Version,
+		"SPC_PROJECT_DIR=" + ctx.ProjectDir,
+		"SPC_CONFIG=" + ctx.ConfigFile,
+		"SPC_FILES=" + strings.Join(ctx.Files, string(os.PathListSeparator)),
+	}
+
+	if ctx.Config != nil {
+		env = append(env, "SPC_PLUGIN_TARGET="+ctx.Config.Target)
+	}
+
+	return env
+}
+
+// writeContext writes the context to a temporary JSON file
+func writeContext(ctx Context) (string, error) {
+	if ctx.Files == nil {
+		ctx.Files = []string{}
+	}
+
+	data, err := json.MarshalIndent(ctx, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp("", "spc-context-*.json")
+	if err != nil {
+		return "", fmt.Errorf("failed to write plugin context: %w", err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+
+		return "", fmt.Errorf("failed to write plugin context: %w", err)
+	}
+
+	return f.Name(), f.Close()
+}
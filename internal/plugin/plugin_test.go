@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// installPlugin puts a shell script plugin on PATH
+func installPlugin(t *testing.T, name, script string) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the plugin")
+	}
+
+	bin := t.TempDir()
+	path := filepath.Join(bin, Prefix+name)
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	return path
+}
+
+func TestFind(t *testing.T) {
+	path := installPlugin(t, "doctor", "")
+
+	got, ok := Find("doctor")
+	assert.True(t, ok)
+	assert.Equal(t, path, got)
+
+	_, ok = Find("missing")
+	assert.False(t, ok)
+
+	_, ok = Find("../doctor")
+	assert.False(t, ok, "only plain names are plugins")
+}
+
+func TestRun(t *testing.T) {
+	path := installPlugin(t, "report", `echo "args: $*"
+echo "target: $SPC_PLUGIN_TARGET files: $SPC_FILES"
+cat "$SPC_CONTEXT"
+exit 3
+`)
+
+	ctx := Context{
+		Version:    "1.2.3",
+		Executable: "/usr/bin/spc",
+		ProjectDir: "/work/project",
+		Config:     &Config{Target: "34", CompilerPath: "/bin/SPlusCC", UserFolders: []string{}},
+		Files:      []string{"/work/project/a.usp", "/work/project/b.usl"},
+	}
+
+	var stdout, stderr bytes.Buffer
+	code, err := Run(path, []string{"--all", "x"}, ctx, strings.NewReader(""), &stdout, &stderr)
+	require.NoError(t, err)
+	assert.Equal(t, 3, code, "the plugin's exit code is returned")
+
+	out := stdout.String()
+	assert.Contains(t, out, "args: --all x\n")
+	assert.Contains(t, out, "target: 34 files: /work/project/a.usp:/work/project/b.usl\n")
+
+	var got Context
+	require.NoError(t, json.Unmarshal([]byte(out[strings.Index(out, "{"):]), &got))
+	assert.Equal(t, ctx, got)
+}
+
+func TestRun_ContextFileRemoved(t *testing.T) {
+	path := installPlugin(t, "where", `echo "$SPC_CONTEXT"`)
+
+	var stdout bytes.Buffer
+	code, err := Run(path, nil, Context{}, nil, &stdout, &bytes.Buffer{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, code)
+
+	assert.NoFileExists(t, strings.TrimSpace(stdout.String()))
+}
@@ -0,0 +1,172 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// DefaultGitHubAPIURL is the GitHub API endpoint releases are created with
+const DefaultGitHubAPIURL = "https://api.github.com"
+
+// GitHub publishes archives as assets of a GitHub release tagged with the
+// version, creating the release if needed
+type GitHub struct {
+	Owner string
+	Repo  string
+
+	// Force replaces an asset already attached to the release
+	Force bool
+
+	apiURL string
+	token  string
+	client *http.Client
+}
+
+// NewGitHub creates a GitHub publisher authenticated with GITHUB_TOKEN (or GH_TOKEN)
+func NewGitHub(owner, repo string) *GitHub {
+	token := os.Getenv(GitHubTokenEnv)
+	if token == "" {
+		token = os.Getenv("GH_TOKEN")
+	}
+
+	return &GitHub{Owner: owner, Repo: repo, apiURL: DefaultGitHubAPIURL, token: token, client: newClient()}
+}
+
+// githubRelease is the part of a GitHub release the publisher uses
+type githubRelease struct {
+	ID        int64         `json:"id"`
+	UploadURL string        `json:"upload_url"`
+	Assets    []githubAsset `json:"assets"`
+}
+
+// githubAsset is a file attached to a GitHub release
+type githubAsset struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Publish uploads the archive to the release tagged with the version
+func (g *GitHub) Publish(ctx context.Context, archive, version string) (string, error) {
+	if g.token == "" {
+		return "", fmt.Errorf("publishing to GitHub requires %s", GitHubTokenEnv)
+	}
+
+	release, err := g.release(ctx, version)
+	if err != nil {
+		return "", err
+	}
+
+	name := AssetName(archive, version)
+	for _, asset := range release.Assets {
+		if asset.Name != name {
+			continue
+		}
+
+		if !g.Force {
+			return "", fmt.Errorf("release %s already has %s (use --force to replace it)", version, name)
+		}
+
+		endpoint := fmt.Sprintf("%s/repos/%s/%s/releases/assets/%d", g.apiURL, g.Owner, g.Repo, asset.ID)
+		if err := g.do(ctx, http.MethodDelete, endpoint, "", nil, nil); err != nil {
+			return "", fmt.Errorf("failed to remove existing %s: %w", name, err)
+		}
+	}
+
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		return "", err
+	}
+
+	uploadURL, _, _ := strings.Cut(release.UploadURL, "{")
+	uploadURL += "?name=" + url.QueryEscape(name)
+
+	var asset githubAsset
+	if err := g.do(ctx, http.MethodPost, uploadURL, "application/zip", data, &asset); err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+
+	return asset.URL, nil
+}
+
+// release returns the release for a tag, creating it if it doesn't exist
+func (g *GitHub) release(ctx context.Context, tag string) (*githubRelease, error) {
+	var release githubRelease
+
+	endpoint := fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", g.apiURL, g.Owner, g.Repo, url.PathEscape(tag))
+	err := g.do(ctx, http.MethodGet, endpoint, "", nil, &release)
+	if err == nil {
+		return &release, nil
+	}
+
+	if !isNotFound(err) {
+		return nil, fmt.Errorf("failed to look up release %s: %w", tag, err)
+	}
+
+	body, _ := json.Marshal(map[string]string{"tag_name": tag, "name": tag})
+	endpoint = fmt.Sprintf("%s/repos/%s/%s/releases", g.apiURL, g.Owner, g.Repo)
+	if err := g.do(ctx, http.MethodPost, endpoint, "application/json", body, &release); err != nil {
+		return nil, fmt.Errorf("failed to create release %s: %w", tag, err)
+	}
+
+	return &release, nil
+}
+
+// statusError is an unexpected response from the GitHub API
+type statusError struct {
+	status string
+	code   int
+}
+
+func (e *statusError) Error() string {
+	return "unexpected response: " + e.status
+}
+
+// isNotFound reports whether err is a 404 response
+func isNotFound(err error) bool {
+	se, ok := err.(*statusError)
+	return ok && se.code == http.StatusNotFound
+}
+
+// do sends an authenticated API request and decodes the JSON response into out
+func (g *GitHub) do(ctx context.Context, method, endpoint, contentType string, body []byte, out any) error {
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &statusError{status: resp.Status, code: resp.StatusCode}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,184 @@
+// Package publish uploads archives created by spc pack to where compiled
+// modules are distributed from: a directory (such as a network share), any
+// server accepting HTTP PUT (Artifactory, Nexus raw repositories, ...) or a
+// GitHub release. Uploads are tagged with a version: the file is named
+// <archive>-<version>.zip and, except on GitHub, placed in a <version>/ directory
+package publish
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Environment variables holding credentials
+const (
+	// TokenEnv is a bearer token for HTTP destinations
+	TokenEnv = "SPC_PUBLISH_TOKEN"
+	// UserEnv and PasswordEnv are basic auth credentials for HTTP destinations
+	UserEnv     = "SPC_PUBLISH_USER"
+	PasswordEnv = "SPC_PUBLISH_PASSWORD"
+	// GitHubTokenEnv is the token used for GitHub releases (GH_TOKEN also works)
+	GitHubTokenEnv = "GITHUB_TOKEN"
+)
+
+// Publisher uploads an archive to a destination
+type Publisher interface {
+	// Publish uploads the archive as the given version and returns where it went
+	Publish(ctx context.Context, archive, version string) (string, error)
+}
+
+// New returns the publisher for a destination:
+//   - "github:owner/repo" uploads a release asset
+//   - an http:// or https:// URL uploads with PUT
+//   - anything else is a directory
+//
+// With force, an archive already published with the same version is replaced
+// (HTTP servers decide that for themselves)
+func New(dest string, force bool) (Publisher, error) {
+	switch {
+	case dest == "":
+		return nil, fmt.Errorf("no publish destination (set publish.destination or pass --to)")
+	case strings.HasPrefix(dest, "github:"):
+		owner, repo, ok := strings.Cut(strings.TrimPrefix(dest, "github:"), "/")
+		if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+			return nil, fmt.Errorf("invalid GitHub destination %q (expected github:owner/repo)", dest)
+		}
+
+		g := NewGitHub(owner, repo)
+		g.Force = force
+
+		return g, nil
+	case strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://"):
+		return &HTTP{URL: strings.TrimSuffix(dest, "/"), client: newClient()}, nil
+	default:
+		return &Dir{Path: dest, Force: force}, nil
+	}
+}
+
+// AssetName returns the versioned name an archive is published as
+// (modules.zip at 1.2.0 becomes modules-1.2.0.zip)
+func AssetName(archive, version string) string {
+	base := filepath.Base(archive)
+	ext := filepath.Ext(base)
+
+	return strings.TrimSuffix(base, ext) + "-" + version + ext
+}
+
+// ValidVersion checks a version can be used in file names and URLs
+func ValidVersion(version string) error {
+	if version == "" {
+		return fmt.Errorf("no version (pass --release or pack with --release)")
+	}
+
+	if strings.ContainsAny(version, `/\:?#% `) || version == "." || version == ".." {
+		return fmt.Errorf("invalid version %q", version)
+	}
+
+	return nil
+}
+
+// newClient returns the HTTP client used for uploads
+func newClient() *http.Client {
+	return &http.Client{Timeout: 10 * time.Minute}
+}
+
+// Dir publishes to a directory, such as a mounted network share
+type Dir struct {
+	// Path is the directory archives are published to
+	Path string
+
+	// Force replaces an archive already published with the same version
+	Force bool
+}
+
+// Publish copies the archive to <Path>/<version>/<name>-<version>.zip
+func (d *Dir) Publish(ctx context.Context, archive, version string) (string, error) {
+	destDir := filepath.Join(d.Path, version)
+	dest := filepath.Join(destDir, AssetName(archive, version))
+
+	if _, err := os.Stat(dest); err == nil && !d.Force {
+		return "", fmt.Errorf("%s already exists (use --force to replace it)", dest)
+	}
+
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", destDir, err)
+	}
+
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		return "", err
+	}
+
+	// Write next to the destination and rename, so readers of the share never
+	// see a partial archive
+	tmp := dest + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+
+	return dest, nil
+}
+
+// HTTP publishes with PUT requests, as accepted by Artifactory generic
+// repositories and similar servers
+type HTTP struct {
+	// URL is the base URL archives are uploaded under
+	URL string
+
+	client *http.Client
+}
+
+// Publish uploads the archive to <URL>/<version>/<name>-<version>.zip,
+// authenticating with SPC_PUBLISH_TOKEN or SPC_PUBLISH_USER/SPC_PUBLISH_PASSWORD
+func (h *HTTP) Publish(ctx context.Context, archive, version string) (string, error) {
+	url := fmt.Sprintf("%s/%s/%s", h.URL, version, AssetName(archive, version))
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, f)
+	if err != nil {
+		return "", err
+	}
+
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/zip")
+
+	if token := os.Getenv(TokenEnv); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if user := os.Getenv(UserEnv); user != "" {
+		req.SetBasicAuth(user, os.Getenv(PasswordEnv))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload to %s: %w", url, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("failed to upload to %s: %s", url, resp.Status)
+	}
+
+	return url, nil
+}
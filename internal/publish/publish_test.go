@@ -0,0 +1,151 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeArchive(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "modules.zip")
+	require.NoError(t, os.WriteFile(path, []byte("zip"), 0o644))
+	return path
+}
+
+func TestNew(t *testing.T) {
+	p, err := New("github:Norgate-AV/modules", false)
+	require.NoError(t, err)
+	assert.IsType(t, &GitHub{}, p)
+
+	p, err = New("https://repo.example.com/modules/", false)
+	require.NoError(t, err)
+	assert.Equal(t, "https://repo.example.com/modules", p.(*HTTP).URL)
+
+	p, err = New("//server/share", true)
+	require.NoError(t, err)
+	assert.Equal(t, &Dir{Path: "//server/share", Force: true}, p)
+
+	_, err = New("", false)
+	assert.ErrorContains(t, err, "no publish destination")
+
+	_, err = New("github:owner", false)
+	assert.ErrorContains(t, err, "invalid GitHub destination")
+}
+
+func TestValidVersion(t *testing.T) {
+	assert.NoError(t, ValidVersion("1.2.0"))
+	assert.Error(t, ValidVersion(""))
+	assert.Error(t, ValidVersion("../1"))
+	assert.Error(t, ValidVersion("1 2"))
+}
+
+func TestDir_Publish(t *testing.T) {
+	archive := writeArchive(t)
+	dest := t.TempDir()
+
+	d := &Dir{Path: dest}
+	location, err := d.Publish(context.Background(), archive, "1.2.0")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dest, "1.2.0", "modules-1.2.0.zip"), location)
+
+	data, err := os.ReadFile(location)
+	require.NoError(t, err)
+	assert.Equal(t, "zip", string(data))
+
+	_, err = d.Publish(context.Background(), archive, "1.2.0")
+	assert.ErrorContains(t, err, "already exists")
+
+	d.Force = true
+	_, err = d.Publish(context.Background(), archive, "1.2.0")
+	assert.NoError(t, err)
+}
+
+func TestHTTP_Publish(t *testing.T) {
+	t.Setenv(TokenEnv, "secret")
+
+	var gotPath, gotAuth, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	h := &HTTP{URL: server.URL + "/modules", client: server.Client()}
+	location, err := h.Publish(context.Background(), writeArchive(t), "1.2.0")
+	require.NoError(t, err)
+
+	assert.Equal(t, server.URL+"/modules/1.2.0/modules-1.2.0.zip", location)
+	assert.Equal(t, "/modules/1.2.0/modules-1.2.0.zip", gotPath)
+	assert.Equal(t, "Bearer secret", gotAuth)
+	assert.Equal(t, "zip", gotBody)
+
+	h.URL = server.URL + "/denied"
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	_, err = h.Publish(context.Background(), writeArchive(t), "1.2.0")
+	assert.ErrorContains(t, err, "403")
+}
+
+func TestGitHub_Publish(t *testing.T) {
+	var created bool
+	var uploaded string
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("GET /repos/owner/repo/releases/tags/1.2.0", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("POST /repos/owner/repo/releases", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "1.2.0", body["tag_name"])
+		created = true
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(githubRelease{ID: 7, UploadURL: server.URL + "/uploads/7/assets{?name,label}"})
+	})
+	mux.HandleFunc("POST /uploads/7/assets", func(w http.ResponseWriter, r *http.Request) {
+		uploaded = r.URL.Query().Get("name")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(githubAsset{ID: 1, Name: uploaded, URL: "https://example.com/" + uploaded})
+	})
+
+	g := &GitHub{Owner: "owner", Repo: "repo", apiURL: server.URL, token: "token", client: server.Client()}
+	location, err := g.Publish(context.Background(), writeArchive(t), "1.2.0")
+	require.NoError(t, err)
+
+	assert.True(t, created)
+	assert.Equal(t, "modules-1.2.0.zip", uploaded)
+	assert.Equal(t, "https://example.com/modules-1.2.0.zip", location)
+}
+
+func TestGitHub_ExistingAsset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(githubRelease{ID: 7, Assets: []githubAsset{{ID: 1, Name: "modules-1.2.0.zip"}}})
+	}))
+	defer server.Close()
+
+	g := &GitHub{Owner: "owner", Repo: "repo", apiURL: server.URL, token: "token", client: server.Client()}
+	_, err := g.Publish(context.Background(), writeArchive(t), "1.2.0")
+	assert.ErrorContains(t, err, "already has modules-1.2.0.zip")
+
+	g.token = ""
+	_, err = g.Publish(context.Background(), writeArchive(t), "1.2.0")
+	assert.ErrorContains(t, err, GitHubTokenEnv)
+}
@@ -0,0 +1,195 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// Environment variables holding remote cache credentials. Credentials are
+// never read from config files
+const (
+	// TokenEnv is a bearer token allowed to read and write the cache
+	TokenEnv = "SPC_CACHE_TOKEN"
+	// ReadTokenEnv is a bearer token only used to read the cache, for builds
+	// that must not write to it (such as pull requests)
+	ReadTokenEnv = "SPC_CACHE_READ_TOKEN"
+	// OIDCTokenEnv is an OIDC identity token issued by the CI system (such as
+	// a GitLab id_tokens: entry), used outside GitHub Actions
+	OIDCTokenEnv = "SPC_CACHE_OIDC_TOKEN"
+)
+
+// The GitHub Actions runner sets these when the job has the id-token: write
+// permission
+const (
+	githubIDTokenURLEnv = "ACTIONS_ID_TOKEN_REQUEST_URL"
+	githubIDTokenEnv    = "ACTIONS_ID_TOKEN_REQUEST_TOKEN"
+)
+
+// credentials authorize requests to an http cache
+type credentials struct {
+	// token returns the bearer token to send, empty for none
+	token func(ctx context.Context) (string, error)
+
+	// readOnly is set when the credentials only allow reading, so uploads
+	// are not attempted
+	readOnly bool
+}
+
+// newCredentials returns the credentials for cache.remote.auth. Without a
+// setting, a token is used if one is set and none otherwise
+func newCredentials(cfg config.RemoteCacheConfig, client *http.Client) (*credentials, error) {
+	auth := cfg.Auth
+	if auth == "" {
+		auth = config.RemoteAuthNone
+		if os.Getenv(TokenEnv) != "" || os.Getenv(ReadTokenEnv) != "" {
+			auth = config.RemoteAuthToken
+		}
+	}
+
+	switch auth {
+	case config.RemoteAuthToken:
+		if token := os.Getenv(TokenEnv); token != "" {
+			return staticCredentials(token, false), nil
+		}
+
+		if token := os.Getenv(ReadTokenEnv); token != "" {
+			return staticCredentials(token, true), nil
+		}
+
+		return nil, fmt.Errorf("cache.remote.auth is %s but neither %s nor %s is set", auth, TokenEnv, ReadTokenEnv)
+	case config.RemoteAuthOIDC:
+		audience := cfg.Audience
+		if audience == "" {
+			audience = config.DefaultOIDCAudience
+		}
+
+		o := &oidc{audience: audience, tokenURL: cfg.TokenURL, client: client}
+
+		return &credentials{token: o.token}, nil
+	default:
+		return staticCredentials("", false), nil
+	}
+}
+
+// staticCredentials sends the same token with every request
+func staticCredentials(token string, readOnly bool) *credentials {
+	return &credentials{token: func(context.Context) (string, error) { return token, nil }, readOnly: readOnly}
+}
+
+// oidc authenticates with the CI system's OIDC identity token, so no secret
+// has to be stored in CI. The cache server (or the token endpoint) decides
+// from the token's claims, such as the branch, what the build may do
+type oidc struct {
+	audience string
+	tokenURL string
+	client   *http.Client
+
+	once  sync.Once
+	value string
+	err   error
+}
+
+// token returns the bearer token, fetched once per run
+func (o *oidc) token(ctx context.Context) (string, error) {
+	o.once.Do(func() {
+		o.value, o.err = o.identityToken(ctx)
+		if o.err == nil && o.tokenURL != "" {
+			o.value, o.err = o.exchange(ctx, o.value)
+		}
+	})
+
+	return o.value, o.err
+}
+
+// identityToken gets an identity token for the audience from GitHub Actions,
+// or takes the one in OIDCTokenEnv
+func (o *oidc) identityToken(ctx context.Context) (string, error) {
+	requestURL, requestToken := os.Getenv(githubIDTokenURLEnv), os.Getenv(githubIDTokenEnv)
+	if requestURL == "" || requestToken == "" {
+		if token := os.Getenv(OIDCTokenEnv); token != "" {
+			return token, nil
+		}
+
+		return "", fmt.Errorf("no CI OIDC token available (grant the job id-token: write in GitHub Actions, or set %s)", OIDCTokenEnv)
+	}
+
+	sep := "?"
+	if strings.Contains(requestURL, "?") {
+		sep = "&"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL+sep+"audience="+url.QueryEscape(o.audience), nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+
+	var response struct {
+		Value string `json:"value"`
+	}
+
+	if err := o.do(req, &response); err != nil {
+		return "", fmt.Errorf("failed to get a GitHub Actions OIDC token: %w", err)
+	}
+
+	return response.Value, nil
+}
+
+// exchange trades an identity token for an access token at the token
+// endpoint (RFC 8693 token exchange)
+func (o *oidc) exchange(ctx context.Context, identity string) (string, error) {
+	form := url.Values{
+		"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"subject_token":      {identity},
+		"subject_token_type": {"urn:ietf:params:oauth:token-type:jwt"},
+		"audience":           {o.audience},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var response struct {
+		AccessToken string `json:"access_token"`
+	}
+
+	if err := o.do(req, &response); err != nil {
+		return "", fmt.Errorf("failed to exchange the OIDC token: %w", err)
+	}
+
+	if response.AccessToken == "" {
+		return "", fmt.Errorf("failed to exchange the OIDC token: no access token returned")
+	}
+
+	return response.AccessToken, nil
+}
+
+// do sends a token request and decodes the JSON response
+func (o *oidc) do(req *http.Request, out any) error {
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
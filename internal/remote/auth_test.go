@@ -0,0 +1,87 @@
+package remote
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+func TestCredentials_OIDC_GitHubActions(t *testing.T) {
+	server := newFakeHTTPCache(t)
+	requests := 0
+
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.Header.Get("Authorization") != "Bearer request-token" || r.URL.Query().Get("audience") != "cache.example.com" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"value":"writer"}`))
+	}))
+	defer idp.Close()
+
+	t.Setenv(githubIDTokenURLEnv, idp.URL+"/token?api-version=2.0")
+	t.Setenv(githubIDTokenEnv, "request-token")
+
+	cfg := server.config()
+	cfg.Auth = config.RemoteAuthOIDC
+	cfg.Audience = "cache.example.com"
+
+	h, err := NewHTTP(cfg, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, h.Upload(context.Background(), "spc-key", []byte("archive")))
+	require.NoError(t, h.Upload(context.Background(), "spc-other", []byte("archive")))
+
+	assert.Len(t, server.blobs, 2)
+	assert.Equal(t, 1, requests, "the token is fetched once")
+}
+
+func TestCredentials_OIDC_Exchange(t *testing.T) {
+	server := newFakeHTTPCache(t)
+	server.blobs["spc-key"] = []byte("archive")
+
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("subject_token") != "ci-identity" || r.FormValue("audience") != config.DefaultOIDCAudience {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"access_token":"reader","token_type":"Bearer"}`))
+	}))
+	defer sts.Close()
+
+	t.Setenv(OIDCTokenEnv, "ci-identity")
+
+	cfg := server.config()
+	cfg.Auth = config.RemoteAuthOIDC
+	cfg.TokenURL = sts.URL
+
+	h, err := NewHTTP(cfg, nil)
+	require.NoError(t, err)
+
+	data, err := h.Fetch(context.Background(), "spc-key")
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(data))
+}
+
+func TestCredentials_OIDC_Unavailable(t *testing.T) {
+	server := newFakeHTTPCache(t)
+
+	cfg := server.config()
+	cfg.Auth = config.RemoteAuthOIDC
+
+	h, err := NewHTTP(cfg, nil)
+	require.NoError(t, err)
+
+	_, err = h.Fetch(context.Background(), "spc-key")
+	assert.ErrorContains(t, err, "no CI OIDC token available")
+}
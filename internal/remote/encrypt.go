@@ -0,0 +1,154 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// KeyEnv holds the key remote cache archives are encrypted with: 32 bytes,
+// base64 or hex encoded
+const KeyEnv = "SPC_CACHE_KEY"
+
+// encryptedMagic starts every encrypted archive
+var encryptedMagic = []byte("SPCAES1\n")
+
+// Encrypted encrypts the archives of another remote cache with AES-256-GCM,
+// so the remote only ever holds ciphertext. Archives are stored under keys
+// prefixed with the encryption key's fingerprint, so caches shared by
+// clients with different keys don't collide
+type Encrypted struct {
+	remote      cache.Remote
+	aead        cipher.AEAD
+	fingerprint string
+}
+
+// NewEncrypted wraps a remote cache with encryption by a 32 byte key
+func NewEncrypted(remote cache.Remote, key []byte) (*Encrypted, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("the cache encryption key must be 32 bytes, not %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(append([]byte("spc cache key\n"), key...))
+
+	return &Encrypted{remote: remote, aead: aead, fingerprint: hex.EncodeToString(sum[:4])}, nil
+}
+
+// Fetch downloads and decrypts the archive stored under key. Archives that
+// aren't encrypted, or were encrypted with another key, are refused
+func (e *Encrypted) Fetch(ctx context.Context, key string) ([]byte, error) {
+	sealed, err := e.remote.Fetch(ctx, e.key(key))
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(sealed, encryptedMagic) {
+		return nil, fmt.Errorf("%s is not encrypted", key)
+	}
+
+	sealed = sealed[len(encryptedMagic):]
+
+	size := e.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, fmt.Errorf("%s is truncated", key)
+	}
+
+	archive, err := e.aead.Open(nil, sealed[:size], sealed[size:], []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", key, err)
+	}
+
+	return archive, nil
+}
+
+// Upload encrypts an archive and stores it under key
+func (e *Encrypted) Upload(ctx context.Context, key string, archive []byte) error {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	// The cache key is authenticated, so an archive can't be moved to
+	// another key
+	sealed := append(append([]byte{}, encryptedMagic...), nonce...)
+	sealed = e.aead.Seal(sealed, nonce, archive, []byte(key))
+
+	return e.remote.Upload(ctx, e.key(key), sealed)
+}
+
+// key returns the remote key an archive is stored under
+func (e *Encrypted) key(key string) string {
+	return "enc-" + e.fingerprint + "-" + key
+}
+
+// encryptionKey reads the key from KeyEnv, or from the output of the
+// cache.remote.key_command
+func encryptionKey(cfg config.RemoteCacheConfig) ([]byte, error) {
+	encoded, source := os.Getenv(KeyEnv), KeyEnv
+
+	if encoded == "" && cfg.KeyCommand != "" {
+		out, err := shellCommand(cfg.KeyCommand).Output()
+		if err != nil {
+			return nil, fmt.Errorf("cache.remote.key_command failed: %w", err)
+		}
+
+		encoded, source = string(out), "cache.remote.key_command"
+	}
+
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil, fmt.Errorf("cache.remote.encrypt is set but there is no key (set %s or cache.remote.key_command)", KeyEnv)
+	}
+
+	key, err := decodeKey(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key from %s: %w", source, err)
+	}
+
+	return key, nil
+}
+
+// decodeKey decodes a 32 byte key written in hex or base64
+func decodeKey(s string) ([]byte, error) {
+	if key, err := hex.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("expected 32 bytes in hex or base64 (generate one with: openssl rand -base64 32)")
+}
+
+// shellCommand runs a command line with the platform's shell
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+
+	return exec.Command("sh", "-c", command)
+}
@@ -0,0 +1,162 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// memoryRemote keeps archives in memory
+type memoryRemote map[string][]byte
+
+func (m memoryRemote) Fetch(_ context.Context, key string) ([]byte, error) {
+	archive, ok := m[key]
+	if !ok {
+		return nil, cache.ErrRemoteMiss
+	}
+
+	return archive, nil
+}
+
+func (m memoryRemote) Upload(_ context.Context, key string, archive []byte) error {
+	m[key] = archive
+	return nil
+}
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestEncrypted_RoundTrip(t *testing.T) {
+	store := memoryRemote{}
+
+	e, err := NewEncrypted(store, testKey(1))
+	require.NoError(t, err)
+
+	require.NoError(t, e.Upload(context.Background(), "spc-key", []byte("secret module")))
+
+	require.Len(t, store, 1)
+	for key, sealed := range store {
+		assert.Contains(t, key, "spc-key")
+		assert.NotEqual(t, "spc-key", key)
+		assert.NotContains(t, string(sealed), "secret module")
+	}
+
+	archive, err := e.Fetch(context.Background(), "spc-key")
+	require.NoError(t, err)
+	assert.Equal(t, "secret module", string(archive))
+
+	_, err = e.Fetch(context.Background(), "spc-other")
+	assert.ErrorIs(t, err, cache.ErrRemoteMiss)
+}
+
+func TestEncrypted_OtherKeysMiss(t *testing.T) {
+	store := memoryRemote{}
+
+	e, err := NewEncrypted(store, testKey(1))
+	require.NoError(t, err)
+	require.NoError(t, e.Upload(context.Background(), "spc-key", []byte("secret module")))
+
+	other, err := NewEncrypted(store, testKey(2))
+	require.NoError(t, err)
+
+	_, err = other.Fetch(context.Background(), "spc-key")
+	assert.ErrorIs(t, err, cache.ErrRemoteMiss)
+}
+
+func TestEncrypted_RejectsTampering(t *testing.T) {
+	store := memoryRemote{}
+
+	e, err := NewEncrypted(store, testKey(1))
+	require.NoError(t, err)
+	require.NoError(t, e.Upload(context.Background(), "spc-key", []byte("secret module")))
+
+	// Flipped bits fail authentication
+	sealed := store[e.key("spc-key")]
+	sealed[len(sealed)-1] ^= 1
+
+	_, err = e.Fetch(context.Background(), "spc-key")
+	assert.ErrorContains(t, err, "failed to decrypt")
+
+	// An archive moved to another key fails too
+	require.NoError(t, e.Upload(context.Background(), "spc-key", []byte("secret module")))
+	store[e.key("spc-moved")] = store[e.key("spc-key")]
+
+	_, err = e.Fetch(context.Background(), "spc-moved")
+	assert.ErrorContains(t, err, "failed to decrypt")
+
+	// Plaintext planted under the key is refused
+	store[e.key("spc-plain")] = []byte("not encrypted")
+
+	_, err = e.Fetch(context.Background(), "spc-plain")
+	assert.ErrorContains(t, err, "not encrypted")
+}
+
+func TestNewEncrypted_KeySize(t *testing.T) {
+	_, err := NewEncrypted(memoryRemote{}, []byte("short"))
+	assert.ErrorContains(t, err, "32 bytes")
+}
+
+func TestEncryptionKey(t *testing.T) {
+	key := testKey(7)
+
+	t.Setenv(KeyEnv, base64.StdEncoding.EncodeToString(key))
+	got, err := encryptionKey(config.RemoteCacheConfig{Encrypt: true})
+	require.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	t.Setenv(KeyEnv, hex.EncodeToString(key))
+	got, err = encryptionKey(config.RemoteCacheConfig{Encrypt: true})
+	require.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	t.Setenv(KeyEnv, "not a key")
+	_, err = encryptionKey(config.RemoteCacheConfig{Encrypt: true})
+	assert.ErrorContains(t, err, "invalid key from "+KeyEnv)
+
+	t.Setenv(KeyEnv, "")
+	_, err = encryptionKey(config.RemoteCacheConfig{Encrypt: true})
+	assert.ErrorContains(t, err, "there is no key")
+}
+
+func TestEncryptionKey_Command(t *testing.T) {
+	t.Setenv(KeyEnv, "")
+
+	got, err := encryptionKey(config.RemoteCacheConfig{Encrypt: true, KeyCommand: "echo " + hex.EncodeToString(testKey(3))})
+	require.NoError(t, err)
+	assert.Equal(t, testKey(3), got)
+
+	_, err = encryptionKey(config.RemoteCacheConfig{Encrypt: true, KeyCommand: "exit 1"})
+	assert.ErrorContains(t, err, "key_command failed")
+}
+
+func TestNew_Encrypt(t *testing.T) {
+	server := newFakeHTTPCache(t)
+	t.Setenv(TokenEnv, "writer")
+
+	cfg := server.config()
+	cfg.Encrypt = true
+
+	t.Setenv(KeyEnv, "")
+	_, err := New(cfg, nil)
+	assert.ErrorContains(t, err, KeyEnv)
+
+	t.Setenv(KeyEnv, hex.EncodeToString(testKey(1)))
+
+	r, err := New(cfg, nil)
+	require.NoError(t, err)
+	require.IsType(t, &Encrypted{}, r)
+
+	require.NoError(t, r.Upload(context.Background(), "spc-key", []byte("secret module")))
+	for _, blob := range server.blobs {
+		assert.NotContains(t, string(blob), "secret module")
+	}
+}
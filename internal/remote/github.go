@@ -0,0 +1,208 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+)
+
+// Environment variables the GitHub Actions runner sets for actions. They are
+// not passed to run: steps, so a workflow has to expose them (for example
+// with crazy-max/ghaction-github-runtime)
+const (
+	// GitHubResultsURLEnv is the base URL of the cache service
+	GitHubResultsURLEnv = "ACTIONS_RESULTS_URL"
+	// GitHubRuntimeTokenEnv authenticates requests to it
+	GitHubRuntimeTokenEnv = "ACTIONS_RUNTIME_TOKEN"
+)
+
+// githubCacheService is the path of the cache service's Twirp API
+const githubCacheService = "twirp/github.actions.results.api.v1.CacheService/"
+
+// githubCacheVersion partitions the workflow's cache between tools, so
+// spc's entries never match keys another action saved
+var githubCacheVersion = func() string {
+	sum := sha256.Sum256([]byte("spc build cache"))
+	return hex.EncodeToString(sum[:])
+}()
+
+// GitHubActions stores builds in the cache service of the GitHub Actions
+// workflow spc runs in, the service actions/cache uses. Entries are scoped
+// like actions/cache's: a workflow run can restore entries saved on its own
+// branch and the default branch
+type GitHubActions struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewGitHubActions creates a backend for the cache service of the current
+// workflow, found through the runner's environment. A nil transport uses
+// http.DefaultTransport
+func NewGitHubActions(transport http.RoundTripper) (*GitHubActions, error) {
+	url, token := os.Getenv(GitHubResultsURLEnv), os.Getenv(GitHubRuntimeTokenEnv)
+	if url == "" || token == "" {
+		return nil, fmt.Errorf("the GitHub Actions cache needs %s and %s, which are only set for actions (expose them to run: steps with crazy-max/ghaction-github-runtime)",
+			GitHubResultsURLEnv, GitHubRuntimeTokenEnv)
+	}
+
+	return &GitHubActions{url: strings.TrimSuffix(url, "/") + "/", token: token, client: newClient(transport)}, nil
+}
+
+// Fetch downloads the archive saved under key
+func (g *GitHubActions) Fetch(ctx context.Context, key string) ([]byte, error) {
+	var lookup struct {
+		OK          bool   `json:"ok"`
+		DownloadURL string `json:"signed_download_url"`
+		MatchedKey  string `json:"matched_key"`
+	}
+
+	request := map[string]any{"key": key, "restore_keys": []string{}, "version": githubCacheVersion}
+	if err := g.call(ctx, "GetCacheEntryDownloadURL", request, &lookup); err != nil {
+		var twirp *twirpError
+		if errors.As(err, &twirp) && twirp.Code == "not_found" {
+			return nil, cache.ErrRemoteMiss
+		}
+
+		return nil, err
+	}
+
+	if !lookup.OK || lookup.DownloadURL == "" || lookup.MatchedKey != key {
+		return nil, cache.ErrRemoteMiss
+	}
+
+	// The signed URL carries its own authorization
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, lookup.DownloadURL, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// Upload saves an archive under key. Keys can only be saved once; an entry
+// already saved (or being saved by another job) counts as uploaded
+func (g *GitHubActions) Upload(ctx context.Context, key string, archive []byte) error {
+	var created struct {
+		OK        bool   `json:"ok"`
+		UploadURL string `json:"signed_upload_url"`
+	}
+
+	request := map[string]any{"key": key, "version": githubCacheVersion}
+	if err := g.call(ctx, "CreateCacheEntry", request, &created); err != nil {
+		var twirp *twirpError
+		if errors.As(err, &twirp) && twirp.Code == "already_exists" {
+			return nil
+		}
+
+		return err
+	}
+
+	if !created.OK || created.UploadURL == "" {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, created.UploadURL, bytes.NewReader(archive))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("upload failed: %s", resp.Status)
+	}
+
+	var finalized struct {
+		OK bool `json:"ok"`
+	}
+
+	request = map[string]any{"key": key, "version": githubCacheVersion, "size_bytes": strconv.Itoa(len(archive))}
+	if err := g.call(ctx, "FinalizeCacheEntryUpload", request, &finalized); err != nil {
+		return err
+	}
+
+	if !finalized.OK {
+		return fmt.Errorf("the cache service did not accept the upload of %s", key)
+	}
+
+	return nil
+}
+
+// twirpError is an error response from the cache service
+type twirpError struct {
+	Code    string `json:"code"`
+	Message string `json:"msg"`
+	status  string
+}
+
+func (e *twirpError) Error() string {
+	if e.Message == "" {
+		return "cache service: " + e.status
+	}
+
+	return fmt.Sprintf("cache service: %s (%s)", e.Message, e.status)
+}
+
+// call makes a request to the cache service's Twirp API
+func (g *GitHubActions) call(ctx context.Context, method string, request, response any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.url+githubCacheService+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+g.token)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		e := &twirpError{status: resp.Status}
+		_ = json.NewDecoder(resp.Body).Decode(e)
+
+		return e
+	}
+
+	return json.NewDecoder(resp.Body).Decode(response)
+}
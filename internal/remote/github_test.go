@@ -0,0 +1,165 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// fakeCacheService emulates the GitHub Actions cache service and the blob
+// storage its signed URLs point at
+type fakeCacheService struct {
+	mu      sync.Mutex
+	server  *httptest.Server
+	blobs   map[string][]byte
+	pending map[string]bool
+	sizes   map[string]string
+}
+
+func newFakeCacheService(t *testing.T) *fakeCacheService {
+	f := &fakeCacheService{blobs: make(map[string][]byte), pending: make(map[string]bool), sizes: make(map[string]string)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.server.Close)
+
+	t.Setenv(GitHubResultsURLEnv, f.server.URL+"/")
+	t.Setenv(GitHubRuntimeTokenEnv, "runtime-token")
+
+	return f
+}
+
+func (f *fakeCacheService) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if key, ok := strings.CutPrefix(r.URL.Path, "/blob/"); ok {
+		switch r.Method {
+		case http.MethodPut:
+			if r.Header.Get("x-ms-blob-type") != "BlockBlob" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			data, _ := io.ReadAll(r.Body)
+			f.blobs[key] = data
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			_, _ = w.Write(f.blobs[key])
+		}
+
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer runtime-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"code":"unauthenticated","msg":"bad token"}`))
+
+		return
+	}
+
+	var req struct {
+		Key     string `json:"key"`
+		Version string `json:"version"`
+		Size    string `json:"size_bytes"`
+	}
+
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	method := strings.TrimPrefix(r.URL.Path, "/"+githubCacheService)
+	switch method {
+	case "GetCacheEntryDownloadURL":
+		if _, ok := f.sizes[req.Key]; !ok {
+			_, _ = w.Write([]byte(`{"ok":false}`))
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "signed_download_url": f.server.URL + "/blob/" + req.Key, "matched_key": req.Key})
+	case "CreateCacheEntry":
+		if f.pending[req.Key] {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"code":"already_exists","msg":"cache entry already exists"}`))
+
+			return
+		}
+
+		f.pending[req.Key] = true
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "signed_upload_url": f.server.URL + "/blob/" + req.Key})
+	case "FinalizeCacheEntryUpload":
+		f.sizes[req.Key] = req.Size
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "entry_id": "1"})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestGitHubActions_UploadAndFetch(t *testing.T) {
+	service := newFakeCacheService(t)
+
+	g, err := NewGitHubActions(nil)
+	require.NoError(t, err)
+
+	_, err = g.Fetch(context.Background(), "spc-key")
+	assert.ErrorIs(t, err, cache.ErrRemoteMiss)
+
+	require.NoError(t, g.Upload(context.Background(), "spc-key", []byte("archive")))
+	assert.Equal(t, "7", service.sizes["spc-key"])
+
+	data, err := g.Fetch(context.Background(), "spc-key")
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(data))
+
+	// Saving a key again is not an error
+	require.NoError(t, g.Upload(context.Background(), "spc-key", []byte("again")))
+
+	data, err = g.Fetch(context.Background(), "spc-key")
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(data))
+}
+
+func TestGitHubActions_Errors(t *testing.T) {
+	newFakeCacheService(t)
+	t.Setenv(GitHubRuntimeTokenEnv, "expired")
+
+	g, err := NewGitHubActions(nil)
+	require.NoError(t, err)
+
+	_, err = g.Fetch(context.Background(), "spc-key")
+	assert.ErrorContains(t, err, "bad token")
+
+	err = g.Upload(context.Background(), "spc-key", []byte("archive"))
+	assert.ErrorContains(t, err, "401")
+}
+
+func TestNewGitHubActions_RequiresRuntime(t *testing.T) {
+	t.Setenv(GitHubResultsURLEnv, "")
+	t.Setenv(GitHubRuntimeTokenEnv, "")
+
+	_, err := NewGitHubActions(nil)
+	assert.ErrorContains(t, err, GitHubRuntimeTokenEnv)
+}
+
+func TestNew(t *testing.T) {
+	r, err := New(config.RemoteCacheConfig{}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, r)
+
+	r, err = New(config.RemoteCacheConfig{URL: "https://cache.example.com"}, nil)
+	require.NoError(t, err)
+	assert.IsType(t, &HTTP{}, r)
+
+	newFakeCacheService(t)
+
+	r, err = New(config.RemoteCacheConfig{Type: config.RemoteGitHubActions}, nil)
+	require.NoError(t, err)
+	assert.IsType(t, &GitHubActions{}, r)
+}
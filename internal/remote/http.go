@@ -0,0 +1,107 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// HTTP stores archives on a server at a base URL: GET <url>/<key> fetches an
+// archive (404 is a miss) and PUT <url>/<key> uploads one. Any server that
+// accepts PUT works, such as nginx with WebDAV or an Artifactory or Nexus raw
+// repository
+type HTTP struct {
+	url    string
+	creds  *credentials
+	client *http.Client
+}
+
+// NewHTTP creates a backend for the http cache at cfg.URL, authenticated as
+// cache.remote.auth says. A nil transport uses http.DefaultTransport
+func NewHTTP(cfg config.RemoteCacheConfig, transport http.RoundTripper) (*HTTP, error) {
+	client := newClient(transport)
+
+	creds, err := newCredentials(cfg, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HTTP{url: strings.TrimSuffix(cfg.URL, "/"), creds: creds, client: client}, nil
+}
+
+// Fetch downloads the archive stored under key
+func (h *HTTP) Fetch(ctx context.Context, key string) ([]byte, error) {
+	resp, err := h.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, cache.ErrRemoteMiss
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("GET %s: %s", key, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// Upload stores an archive under key. Nothing is uploaded with read-only
+// credentials, and a server refusing the upload returns cache.ErrRemoteForbidden
+func (h *HTTP) Upload(ctx context.Context, key string, archive []byte) error {
+	if h.creds.readOnly {
+		return nil
+	}
+
+	resp, err := h.do(ctx, http.MethodPut, key, archive)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: PUT %s: %s", cache.ErrRemoteForbidden, key, resp.Status)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("PUT %s: %s", key, resp.Status)
+	}
+
+	return nil
+}
+
+// do sends an authenticated request for a key
+func (h *HTTP) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	token, err := h.creds.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, h.url+"/"+key, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return h.client.Do(req)
+}
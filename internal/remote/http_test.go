@@ -0,0 +1,161 @@
+package remote
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// fakeHTTPCache is a cache server where readToken may GET and writeToken may
+// also PUT
+type fakeHTTPCache struct {
+	mu         sync.Mutex
+	server     *httptest.Server
+	blobs      map[string][]byte
+	readToken  string
+	writeToken string
+}
+
+func newFakeHTTPCache(t *testing.T) *fakeHTTPCache {
+	t.Setenv(TokenEnv, "")
+	t.Setenv(ReadTokenEnv, "")
+	t.Setenv(OIDCTokenEnv, "")
+	t.Setenv(githubIDTokenURLEnv, "")
+	t.Setenv(githubIDTokenEnv, "")
+
+	f := &fakeHTTPCache{blobs: make(map[string][]byte), readToken: "reader", writeToken: "writer"}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.server.Close)
+
+	return f
+}
+
+func (f *fakeHTTPCache) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	key := strings.TrimPrefix(r.URL.Path, "/spc/")
+
+	switch r.Method {
+	case http.MethodGet:
+		if token != f.readToken && token != f.writeToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		data, ok := f.blobs[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write(data)
+	case http.MethodPut:
+		if token != f.writeToken {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		data, _ := io.ReadAll(r.Body)
+		f.blobs[key] = data
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (f *fakeHTTPCache) config() config.RemoteCacheConfig {
+	return config.RemoteCacheConfig{URL: f.server.URL + "/spc/"}
+}
+
+func TestHTTP_UploadAndFetch(t *testing.T) {
+	server := newFakeHTTPCache(t)
+	t.Setenv(TokenEnv, "writer")
+
+	h, err := NewHTTP(server.config(), nil)
+	require.NoError(t, err)
+
+	_, err = h.Fetch(context.Background(), "spc-key")
+	assert.ErrorIs(t, err, cache.ErrRemoteMiss)
+
+	require.NoError(t, h.Upload(context.Background(), "spc-key", []byte("archive")))
+	assert.Equal(t, "archive", string(server.blobs["spc-key"]))
+
+	data, err := h.Fetch(context.Background(), "spc-key")
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(data))
+}
+
+func TestHTTP_ReadToken(t *testing.T) {
+	server := newFakeHTTPCache(t)
+	server.blobs["spc-key"] = []byte("archive")
+	t.Setenv(ReadTokenEnv, "reader")
+
+	h, err := NewHTTP(server.config(), nil)
+	require.NoError(t, err)
+
+	data, err := h.Fetch(context.Background(), "spc-key")
+	require.NoError(t, err)
+	assert.Equal(t, "archive", string(data))
+
+	// Read-only credentials never upload
+	require.NoError(t, h.Upload(context.Background(), "spc-other", []byte("poison")))
+	assert.NotContains(t, server.blobs, "spc-other")
+}
+
+func TestHTTP_Forbidden(t *testing.T) {
+	server := newFakeHTTPCache(t)
+	t.Setenv(TokenEnv, "reader")
+
+	h, err := NewHTTP(server.config(), nil)
+	require.NoError(t, err)
+
+	err = h.Upload(context.Background(), "spc-key", []byte("archive"))
+	assert.ErrorIs(t, err, cache.ErrRemoteForbidden)
+
+	t.Setenv(TokenEnv, "")
+
+	h, err = NewHTTP(server.config(), nil)
+	require.NoError(t, err)
+
+	_, err = h.Fetch(context.Background(), "spc-key")
+	assert.ErrorContains(t, err, "401")
+}
+
+func TestHTTP_Cancelled(t *testing.T) {
+	server := newFakeHTTPCache(t)
+	server.blobs["spc-key"] = []byte("archive")
+	t.Setenv(TokenEnv, "writer")
+
+	h, err := NewHTTP(server.config(), nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = h.Fetch(ctx, "spc-key")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	err = h.Upload(ctx, "spc-other", []byte("archive"))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotContains(t, server.blobs, "spc-other")
+}
+
+func TestHTTP_TokenRequired(t *testing.T) {
+	server := newFakeHTTPCache(t)
+
+	cfg := server.config()
+	cfg.Auth = config.RemoteAuthToken
+
+	_, err := NewHTTP(cfg, nil)
+	assert.ErrorContains(t, err, TokenEnv)
+}
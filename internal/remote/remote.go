@@ -0,0 +1,54 @@
+// Package remote implements the backends of cache.remote, the caches builds
+// are shared through between machines
+package remote
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+	"github.com/Norgate-AV/spc/internal/config"
+)
+
+// New returns the remote cache configured in the cache.remote: section, or
+// nil when none is. Requests go through transport (nil for
+// http.DefaultTransport). With cache.remote.encrypt, archives are encrypted
+// before they leave the machine
+func New(cfg config.RemoteCacheConfig, transport http.RoundTripper) (cache.Remote, error) {
+	var r cache.Remote
+
+	switch {
+	case cfg.Type == config.RemoteGitHubActions:
+		g, err := NewGitHubActions(transport)
+		if err != nil {
+			return nil, err
+		}
+
+		r = g
+	case cfg.URL != "":
+		h, err := NewHTTP(cfg, transport)
+		if err != nil {
+			return nil, err
+		}
+
+		r = h
+	default:
+		return nil, nil
+	}
+
+	if !cfg.Encrypt {
+		return r, nil
+	}
+
+	key, err := encryptionKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEncrypted(r, key)
+}
+
+// newClient returns the HTTP client used to reach a remote cache
+func newClient(transport http.RoundTripper) *http.Client {
+	return &http.Client{Transport: transport, Timeout: 5 * time.Minute}
+}
@@ -0,0 +1,242 @@
+// Package scaffold creates new SIMPL+ modules from templates. A template is a
+// directory of files rendered with text/template: "__Name__" in a path is
+// replaced by the module name and a ".tmpl" suffix is dropped. Templates are
+// built in, or found in the templates directory of the user's config, where
+// they override built-in templates of the same name.
+package scaffold
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// DefaultTemplate is the template used when none is named
+const DefaultTemplate = "basic"
+
+// BuiltIn is the source of templates shipped with spc
+const BuiltIn = "built-in"
+
+// namePlaceholder is replaced by the module name in template paths
+const namePlaceholder = "__Name__"
+
+// templateSuffix is dropped from template file names, so the templates in
+// this repository are not mistaken for sources
+const templateSuffix = ".tmpl"
+
+//go:embed all:templates
+var builtIn embed.FS
+
+// moduleName matches valid module names
+var moduleName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// Template is a named set of files to scaffold
+type Template struct {
+	// Name of the template, as given to --template
+	Name string
+
+	// Source is the template's directory, or BuiltIn
+	Source string
+
+	files fs.FS
+}
+
+// Data is what templates are rendered with
+type Data struct {
+	// Name of the module (e.g. MyModule)
+	Name string
+
+	// Author of the module
+	Author string
+
+	// Date the module was created (YYYY-MM-DD)
+	Date string
+
+	// Year the module was created
+	Year string
+
+	// Target series of the project (e.g. 34)
+	Target string
+}
+
+// ValidateName checks a module name can be used as a symbol and file name
+func ValidateName(name string) error {
+	if !moduleName.MatchString(name) {
+		return fmt.Errorf("invalid module name %q: use letters, digits and underscores, starting with a letter", name)
+	}
+
+	return nil
+}
+
+// Templates returns the available templates sorted by name. Templates in
+// earlier user directories override later ones and the built-in templates
+func Templates(userDirs []string) ([]Template, error) {
+	byName := make(map[string]Template)
+
+	root, err := fs.Sub(builtIn, "templates")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := addTemplates(byName, root, BuiltIn); err != nil {
+		return nil, err
+	}
+
+	for i := len(userDirs) - 1; i >= 0; i-- {
+		dir := userDirs[i]
+
+		if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err := addTemplates(byName, os.DirFS(dir), dir); err != nil {
+			return nil, fmt.Errorf("failed to read templates in %s: %w", dir, err)
+		}
+	}
+
+	templates := make([]Template, 0, len(byName))
+	for _, t := range byName {
+		templates = append(templates, t)
+	}
+
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+
+	return templates, nil
+}
+
+// addTemplates adds each directory of root as a template
+func addTemplates(byName map[string]Template, root fs.FS, source string) error {
+	entries, err := fs.ReadDir(root, ".")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		files, err := fs.Sub(root, entry.Name())
+		if err != nil {
+			return err
+		}
+
+		t := Template{Name: entry.Name(), Source: source, files: files}
+		if source != BuiltIn {
+			t.Source = filepath.Join(source, entry.Name())
+		}
+
+		byName[t.Name] = t
+	}
+
+	return nil
+}
+
+// Find returns the named template
+func Find(name string, userDirs []string) (Template, error) {
+	templates, err := Templates(userDirs)
+	if err != nil {
+		return Template{}, err
+	}
+
+	names := make([]string, 0, len(templates))
+	for _, t := range templates {
+		if t.Name == name {
+			return t, nil
+		}
+
+		names = append(names, t.Name)
+	}
+
+	return Template{}, fmt.Errorf("unknown template %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// Render writes the template's files for a module into dir and returns the
+// paths written. Nothing is written if any file exists, unless force is set
+func (t Template) Render(dir string, data Data, force bool) ([]string, error) {
+	if err := ValidateName(data.Name); err != nil {
+		return nil, err
+	}
+
+	rendered, err := t.render(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rendered) == 0 {
+		return nil, fmt.Errorf("template %q has no files", t.Name)
+	}
+
+	paths := make([]string, 0, len(rendered))
+	for _, file := range rendered {
+		p := filepath.Join(dir, filepath.FromSlash(file.path))
+
+		if !force {
+			if _, err := os.Stat(p); err == nil {
+				return nil, fmt.Errorf("%s already exists (use --force to overwrite)", p)
+			}
+		}
+
+		paths = append(paths, p)
+	}
+
+	for i, file := range rendered {
+		if err := os.MkdirAll(filepath.Dir(paths[i]), 0o755); err != nil {
+			return nil, err
+		}
+
+		if err := os.WriteFile(paths[i], file.data, 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	return paths, nil
+}
+
+// renderedFile is a template file rendered in memory
+type renderedFile struct {
+	path string
+	data []byte
+}
+
+// render renders every file of the template, in path order
+func (t Template) render(data Data) ([]renderedFile, error) {
+	var files []renderedFile
+
+	err := fs.WalkDir(t.files, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		text, err := fs.ReadFile(t.files, p)
+		if err != nil {
+			return err
+		}
+
+		tmpl, err := template.New(path.Base(p)).Option("missingkey=error").Parse(string(text))
+		if err != nil {
+			return fmt.Errorf("template %q: %w", t.Name, err)
+		}
+
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, data); err != nil {
+			return fmt.Errorf("template %q: %w", t.Name, err)
+		}
+
+		target := strings.TrimSuffix(strings.ReplaceAll(p, namePlaceholder, data.Name), templateSuffix)
+		files = append(files, renderedFile{path: target, data: out.Bytes()})
+
+		return nil
+	})
+
+	return files, err
+}
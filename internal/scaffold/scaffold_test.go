@@ -0,0 +1,133 @@
+package scaffold
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testData(name string) Data {
+	return Data{Name: name, Author: "Jo Bloggs", Date: "2026-01-02", Year: "2026", Target: "34"}
+}
+
+func TestTemplates_BuiltIn(t *testing.T) {
+	templates, err := Templates([]string{filepath.Join(t.TempDir(), "missing")})
+	require.NoError(t, err)
+
+	var names []string
+	for _, tmpl := range templates {
+		names = append(names, tmpl.Name)
+		assert.Equal(t, BuiltIn, tmpl.Source)
+	}
+
+	assert.Equal(t, []string{"basic", "serial-driver"}, names)
+}
+
+func TestTemplate_Render_SerialDriver(t *testing.T) {
+	tmpl, err := Find("serial-driver", nil)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	paths, err := tmpl.Render(dir, testData("Projector"), false)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		filepath.Join(dir, "Projector", "Projector.usp"),
+		filepath.Join(dir, "Projector", "tests", "Projector_Harness.usp"),
+	}, paths)
+
+	module, err := os.ReadFile(paths[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(module), `#SYMBOL_NAME "Projector"`)
+	assert.Contains(t, string(module), "Programmer:   Jo Bloggs")
+	assert.Contains(t, string(module), "Created:      2026-01-02")
+	assert.Contains(t, string(module), "Target:       34")
+
+	harness, err := os.ReadFile(paths[1])
+	require.NoError(t, err)
+	assert.Contains(t, string(harness), `#SYMBOL_NAME "Projector Harness"`)
+}
+
+func TestTemplate_Render_KeepsExistingFiles(t *testing.T) {
+	tmpl, err := Find("serial-driver", nil)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "Projector", "tests", "Projector_Harness.usp")
+	require.NoError(t, os.MkdirAll(filepath.Dir(existing), 0o755))
+	require.NoError(t, os.WriteFile(existing, []byte("mine"), 0o644))
+
+	_, err = tmpl.Render(dir, testData("Projector"), false)
+	assert.ErrorContains(t, err, "already exists")
+	assert.NoFileExists(t, filepath.Join(dir, "Projector", "Projector.usp"), "nothing is written when a file exists")
+
+	_, err = tmpl.Render(dir, testData("Projector"), true)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.NotEqual(t, "mine", string(data))
+}
+
+func TestTemplates_UserTemplates(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+
+	write := func(path, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	write(filepath.Join(first, "basic", "__Name__.usp.tmpl"), "// {{.Name}} by {{.Author}} ({{.Year}})\n")
+	write(filepath.Join(second, "basic", "ignored.usp"), "")
+	write(filepath.Join(second, "relay", "__Name__", "__Name__.usp"), "#SYMBOL_NAME \"{{.Name}}\"\n")
+
+	templates, err := Templates([]string{first, second})
+	require.NoError(t, err)
+
+	sources := make(map[string]string)
+	for _, tmpl := range templates {
+		sources[tmpl.Name] = tmpl.Source
+	}
+
+	assert.Equal(t, map[string]string{
+		"basic":         filepath.Join(first, "basic"),
+		"relay":         filepath.Join(second, "relay"),
+		"serial-driver": BuiltIn,
+	}, sources, "earlier directories override later ones and the built-in templates")
+
+	tmpl, err := Find("basic", []string{first, second})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	paths, err := tmpl.Render(dir, testData("Lights"), false)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "Lights.usp")}, paths)
+
+	data, err := os.ReadFile(paths[0])
+	require.NoError(t, err)
+	assert.Equal(t, "// Lights by Jo Bloggs (2026)\n", string(data))
+}
+
+func TestTemplate_Render_Errors(t *testing.T) {
+	_, err := Find("missing", nil)
+	assert.ErrorContains(t, err, "available: basic, serial-driver")
+
+	tmpl, err := Find(DefaultTemplate, nil)
+	require.NoError(t, err)
+
+	_, err = tmpl.Render(t.TempDir(), testData("My Module"), false)
+	assert.ErrorContains(t, err, "invalid module name")
+
+	user := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(user, "broken"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(user, "broken", "__Name__.usp"), []byte("{{.Unknown}}"), 0o644))
+
+	tmpl, err = Find("broken", []string{user})
+	require.NoError(t, err)
+
+	_, err = tmpl.Render(t.TempDir(), testData("Lights"), false)
+	assert.ErrorContains(t, err, `template "broken"`)
+}
@@ -0,0 +1,102 @@
+// Package toolchain finds the Crestron tools installed on the machine: the
+// SIMPL+ compiler spc runs and the SIMPL Windows install it belongs to.
+package toolchain
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// SIMPLWindowsKey is the registry key SIMPL Windows records its install
+// directory under (in the InstallPath value)
+const SIMPLWindowsKey = `HKLM\SOFTWARE\WOW6432Node\Crestron Electronics Inc.\SIMPL Windows`
+
+// installPathValue is the value of SIMPLWindowsKey holding the install directory
+const installPathValue = "InstallPath"
+
+// CompilerName is the file name of the SIMPL+ compiler in a SIMPL Windows
+// install directory
+const CompilerName = "SPlusCC.exe"
+
+// regValue is a value printed by reg query
+type regValue struct {
+	Key  string
+	Name string
+	Type string
+	Data string
+}
+
+// regValueLine matches a value line of reg query's output: the name, type
+// and data separated by four spaces
+var regValueLine = regexp.MustCompile(`^ {4}(.*?) {4}(REG_[A-Z_]+)(?: {4}(.*))?$`)
+
+// queryRegistry runs reg query for a key, recursing into its subkeys when
+// recurse is set. It is a variable so tests can fake the registry
+var queryRegistry = func(key string, recurse bool) ([]regValue, error) {
+	if runtime.GOOS != "windows" {
+		return nil, fmt.Errorf("the registry is only available on Windows")
+	}
+
+	args := []string{"query", key}
+	if recurse {
+		args = append(args, "/s")
+	}
+
+	out, err := exec.Command("reg", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("reg query %s: %w", key, err)
+	}
+
+	return parseRegQuery(string(out)), nil
+}
+
+// parseRegQuery parses the output of reg query into its values, each with
+// the key it was listed under
+func parseRegQuery(out string) []regValue {
+	var values []regValue
+	key := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if strings.HasPrefix(line, "HKEY_") {
+			key = line
+			continue
+		}
+
+		if m := regValueLine.FindStringSubmatch(line); m != nil {
+			name := m[1]
+			if name == "(Default)" {
+				name = ""
+			}
+
+			values = append(values, regValue{Key: key, Name: name, Type: m[2], Data: m[3]})
+		}
+	}
+
+	return values
+}
+
+// RegistryCompiler returns the SIMPL+ compiler of the SIMPL Windows install
+// recorded in the registry, or "" when there is none (or the registry can't
+// be read, as on every platform but Windows)
+func RegistryCompiler() string {
+	values, err := queryRegistry(SIMPLWindowsKey, false)
+	if err != nil {
+		return ""
+	}
+
+	for _, v := range values {
+		if strings.EqualFold(v.Name, installPathValue) && v.Data != "" {
+			return filepath.Join(v.Data, CompilerName)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,47 @@
+package toolchain
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const regOutput = "\r\n" +
+	"HKEY_LOCAL_MACHINE\\SOFTWARE\\WOW6432Node\\Crestron Electronics Inc.\\SIMPL Windows\r\n" +
+	"    (Default)    REG_SZ\r\n" +
+	"    InstallPath    REG_SZ    C:\\Program Files (x86)\\Crestron\\Simpl\r\n" +
+	"    Version    REG_SZ    4.3100.00\r\n" +
+	"\r\n"
+
+func TestParseRegQuery(t *testing.T) {
+	key := `HKEY_LOCAL_MACHINE\SOFTWARE\WOW6432Node\Crestron Electronics Inc.\SIMPL Windows`
+
+	assert.Equal(t, []regValue{
+		{Key: key, Name: "", Type: "REG_SZ"},
+		{Key: key, Name: "InstallPath", Type: "REG_SZ", Data: `C:\Program Files (x86)\Crestron\Simpl`},
+		{Key: key, Name: "Version", Type: "REG_SZ", Data: "4.3100.00"},
+	}, parseRegQuery(regOutput))
+}
+
+func fakeRegistry(t *testing.T, query func(key string, recurse bool) ([]regValue, error)) {
+	saved := queryRegistry
+	queryRegistry = query
+	t.Cleanup(func() { queryRegistry = saved })
+}
+
+func TestRegistryCompiler(t *testing.T) {
+	fakeRegistry(t, func(key string, recurse bool) ([]regValue, error) {
+		assert.Equal(t, SIMPLWindowsKey, key)
+		return parseRegQuery(regOutput), nil
+	})
+
+	assert.Equal(t, filepath.Join(`C:\Program Files (x86)\Crestron\Simpl`, CompilerName), RegistryCompiler())
+
+	fakeRegistry(t, func(string, bool) ([]regValue, error) {
+		return nil, errors.New("not found")
+	})
+
+	assert.Empty(t, RegistryCompiler())
+}
@@ -0,0 +1,272 @@
+package toolchain
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Kind is a kind of Crestron tool
+type Kind string
+
+const (
+	// Compiler is the SIMPL+ cross compiler (SPlusCC.exe)
+	Compiler Kind = "compiler"
+	// SIMPLWindows is SIMPL Windows (smpwin.exe)
+	SIMPLWindows Kind = "simpl-windows"
+	// Editor is the SIMPL+ editor (splus.exe)
+	Editor Kind = "editor"
+)
+
+// Sources a tool is found through
+const (
+	SourceRegistry = "registry"
+	SourceScan     = "scan"
+)
+
+// product describes how to recognise a kind of tool
+type product struct {
+	kind Kind
+
+	// exe is the file name of its executable
+	exe string
+
+	// names are the DisplayNames it is installed under (matched as substrings)
+	names []string
+}
+
+// products are the tools Discover looks for, in the order they are listed
+var products = []product{
+	{kind: Compiler, exe: CompilerName, names: []string{"SIMPL+ Cross Compiler", "SIMPL Plus Cross Compiler"}},
+	{kind: SIMPLWindows, exe: "smpwin.exe", names: []string{"SIMPL Windows"}},
+	{kind: Editor, exe: "splus.exe", names: []string{"SIMPL+ Editor", "SIMPL Plus Editor"}},
+}
+
+// uninstallKeys are the registry keys installed programs are listed under
+var uninstallKeys = []string{
+	`HKLM\SOFTWARE\WOW6432Node\Microsoft\Windows\CurrentVersion\Uninstall`,
+	`HKLM\SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`,
+}
+
+// maxScanDepth is how many directories below <root>/Crestron the scan looks
+// for executables
+const maxScanDepth = 3
+
+// Tool is an installed Crestron tool
+type Tool struct {
+	Kind Kind `json:"kind"`
+
+	// Name is the product name it was installed under, or its executable's
+	// file name when it was found by scanning
+	Name string `json:"name"`
+
+	// Version is the installed version, when known
+	Version string `json:"version,omitempty"`
+
+	// Path is the executable, or the install directory when the executable
+	// could not be found in it
+	Path string `json:"path"`
+
+	// Source is how the tool was found: registry or scan
+	Source string `json:"source"`
+}
+
+// Discover lists the SIMPL+ compilers, SIMPL Windows and SIMPL+ editors
+// installed in the Crestron directory of each of roots (see InstallRoots)
+// or recorded in the registry
+func Discover(roots []string) []Tool {
+	tools := registryTools()
+
+	for _, found := range scan(roots) {
+		i := slices.IndexFunc(tools, func(t Tool) bool { return samePath(t.Path, found.Path) })
+		if i < 0 {
+			tools = append(tools, found)
+			continue
+		}
+
+		if tools[i].Version == "" {
+			tools[i].Version = found.Version
+		}
+	}
+
+	slices.SortStableFunc(tools, func(a, b Tool) int {
+		if a.Kind != b.Kind {
+			return kindOrder(a.Kind) - kindOrder(b.Kind)
+		}
+
+		return strings.Compare(strings.ToLower(a.Path), strings.ToLower(b.Path))
+	})
+
+	return tools
+}
+
+// InstallRoots returns the directories Crestron software is installed under:
+// the Program Files directories on Windows, and the same directories of the
+// wine prefix ($WINEPREFIX or ~/.wine) elsewhere
+func InstallRoots() []string {
+	return installRoots(os.Getenv, runtime.GOOS)
+}
+
+func installRoots(getenv func(string) string, goos string) []string {
+	if goos == "windows" {
+		var roots []string
+		for _, name := range []string{"ProgramFiles(x86)", "ProgramFiles"} {
+			if dir := getenv(name); dir != "" && !slices.Contains(roots, dir) {
+				roots = append(roots, dir)
+			}
+		}
+
+		if len(roots) == 0 {
+			roots = append(roots, `C:\Program Files (x86)`)
+		}
+
+		return roots
+	}
+
+	prefix := getenv("WINEPREFIX")
+	if prefix == "" {
+		home := getenv("HOME")
+		if home == "" {
+			return nil
+		}
+
+		prefix = filepath.Join(home, ".wine")
+	}
+
+	return []string{
+		filepath.Join(prefix, "drive_c", "Program Files (x86)"),
+		filepath.Join(prefix, "drive_c", "Program Files"),
+	}
+}
+
+// registryTools lists the tools recorded in the registry: installed programs
+// whose name matches a product, and the compiler of the SIMPL Windows install
+func registryTools() []Tool {
+	var tools []Tool
+
+	for _, key := range uninstallKeys {
+		values, err := queryRegistry(key, true)
+		if err != nil {
+			continue
+		}
+
+		for _, program := range groupByKey(values) {
+			p, ok := matchProduct(program["DisplayName"])
+			if !ok {
+				continue
+			}
+
+			dir := program["InstallLocation"]
+			if dir == "" {
+				continue
+			}
+
+			tool := Tool{Kind: p.kind, Name: program["DisplayName"], Version: program["DisplayVersion"], Path: dir, Source: SourceRegistry}
+			if exe := filepath.Join(dir, p.exe); isFile(exe) {
+				tool.Path = exe
+			}
+
+			if !slices.ContainsFunc(tools, func(t Tool) bool { return samePath(t.Path, tool.Path) }) {
+				tools = append(tools, tool)
+			}
+		}
+	}
+
+	if compiler := RegistryCompiler(); compiler != "" && !slices.ContainsFunc(tools, func(t Tool) bool { return samePath(t.Path, compiler) }) {
+		tools = append(tools, Tool{Kind: Compiler, Name: CompilerName, Path: compiler, Source: SourceRegistry})
+	}
+
+	for i, t := range tools {
+		if t.Version == "" {
+			tools[i].Version, _ = FileVersion(t.Path)
+		}
+	}
+
+	return tools
+}
+
+// groupByKey collects the values reg query listed under each key, in the
+// order the keys were listed
+func groupByKey(values []regValue) []map[string]string {
+	var groups []map[string]string
+	last := ""
+
+	for _, v := range values {
+		if v.Key != last || len(groups) == 0 {
+			groups = append(groups, make(map[string]string))
+			last = v.Key
+		}
+
+		groups[len(groups)-1][v.Name] = v.Data
+	}
+
+	return groups
+}
+
+// matchProduct returns the product an installed program's name belongs to
+func matchProduct(name string) (product, bool) {
+	for _, p := range products {
+		for _, n := range p.names {
+			if strings.Contains(strings.ToLower(name), strings.ToLower(n)) {
+				return p, true
+			}
+		}
+	}
+
+	return product{}, false
+}
+
+// scan finds the executables of every product in the Crestron directory of
+// each root
+func scan(roots []string) []Tool {
+	var tools []Tool
+
+	for _, root := range roots {
+		base := filepath.Join(root, "Crestron")
+
+		_ = filepath.WalkDir(base, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+
+			if d.IsDir() {
+				if rel, _ := filepath.Rel(base, path); rel != "." && strings.Count(rel, string(filepath.Separator)) >= maxScanDepth {
+					return filepath.SkipDir
+				}
+
+				return nil
+			}
+
+			for _, p := range products {
+				if strings.EqualFold(d.Name(), p.exe) {
+					version, _ := FileVersion(path)
+					tools = append(tools, Tool{Kind: p.kind, Name: p.exe, Version: version, Path: path, Source: SourceScan})
+				}
+			}
+
+			return nil
+		})
+	}
+
+	return tools
+}
+
+// kindOrder returns the position of a kind in products
+func kindOrder(kind Kind) int {
+	return slices.IndexFunc(products, func(p product) bool { return p.kind == kind })
+}
+
+// samePath reports whether two paths name the same file, ignoring case as
+// Windows does
+func samePath(a, b string) bool {
+	return strings.EqualFold(filepath.Clean(a), filepath.Clean(b))
+}
+
+// isFile reports whether path is an existing regular file
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,65 @@
+package toolchain
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeExecutable(t *testing.T, path string, data []byte) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, data, 0o755))
+}
+
+func TestDiscover(t *testing.T) {
+	root := t.TempDir()
+	simpl := filepath.Join(root, "Crestron", "Simpl")
+	writeExecutable(t, filepath.Join(simpl, "SPlusCC.exe"), fakeExecutable(4, 3100, 0, 0))
+	writeExecutable(t, filepath.Join(simpl, "smpwin.exe"), fakeExecutable(4, 3100, 0, 0))
+	writeExecutable(t, filepath.Join(root, "Crestron", "Legacy", "Simpl", "SPlusCC.exe"), fakeExecutable(4, 2500, 0, 0))
+	writeExecutable(t, filepath.Join(root, "Crestron", "a", "b", "c", "d", "SPlusCC.exe"), fakeExecutable(1, 0, 0, 0))
+
+	fakeRegistry(t, func(key string, recurse bool) ([]regValue, error) {
+		switch key {
+		case uninstallKeys[0]:
+			return []regValue{
+				{Key: key + `\{SIMPL}`, Name: "DisplayName", Data: "Crestron SIMPL Windows"},
+				{Key: key + `\{SIMPL}`, Name: "DisplayVersion", Data: "4.31.00"},
+				{Key: key + `\{SIMPL}`, Name: "InstallLocation", Data: simpl},
+				{Key: key + `\{Other}`, Name: "DisplayName", Data: "Crestron Toolbox"},
+				{Key: key + `\{Other}`, Name: "InstallLocation", Data: root},
+			}, nil
+		case SIMPLWindowsKey:
+			return []regValue{{Key: key, Name: installPathValue, Data: simpl}}, nil
+		default:
+			return nil, os.ErrNotExist
+		}
+	})
+
+	assert.Equal(t, []Tool{
+		{Kind: Compiler, Name: "SPlusCC.exe", Version: "4.2500.0.0", Path: filepath.Join(root, "Crestron", "Legacy", "Simpl", "SPlusCC.exe"), Source: SourceScan},
+		{Kind: Compiler, Name: "SPlusCC.exe", Version: "4.3100.0.0", Path: filepath.Join(simpl, "SPlusCC.exe"), Source: SourceRegistry},
+		{Kind: SIMPLWindows, Name: "Crestron SIMPL Windows", Version: "4.31.00", Path: filepath.Join(simpl, "smpwin.exe"), Source: SourceRegistry},
+	}, Discover([]string{root}))
+}
+
+func TestInstallRoots(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(name string) string { return values[name] }
+	}
+
+	assert.Equal(t, []string{`C:\Program Files (x86)`, `C:\Program Files`},
+		installRoots(env(map[string]string{"ProgramFiles(x86)": `C:\Program Files (x86)`, "ProgramFiles": `C:\Program Files`}), "windows"))
+	assert.Equal(t, []string{`C:\Program Files (x86)`}, installRoots(env(nil), "windows"))
+
+	assert.Equal(t, []string{
+		filepath.Join("/wine", "drive_c", "Program Files (x86)"),
+		filepath.Join("/wine", "drive_c", "Program Files"),
+	}, installRoots(env(map[string]string{"WINEPREFIX": "/wine", "HOME": "/home/me"}), "linux"))
+	assert.Equal(t, filepath.Join("/home/me", ".wine", "drive_c", "Program Files (x86)"),
+		installRoots(env(map[string]string{"HOME": "/home/me"}), "linux")[0])
+	assert.Empty(t, installRoots(env(nil), "linux"))
+}
@@ -0,0 +1,48 @@
+package toolchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// fixedFileInfoSignature starts the VS_FIXEDFILEINFO structure of a Windows
+// executable's version resource (0xFEEF04BD, little-endian)
+var fixedFileInfoSignature = []byte{0xbd, 0x04, 0xef, 0xfe}
+
+// FileVersion returns the file version of a Windows executable (e.g.
+// "4.3100.0.0"), read from its version resource so it works on any platform
+func FileVersion(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return fileVersion(data)
+}
+
+// fileVersion finds the VS_FIXEDFILEINFO in the bytes of an executable and
+// formats its file version
+func fileVersion(data []byte) (string, error) {
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], fixedFileInfoSignature)
+		if i < 0 {
+			return "", fmt.Errorf("no version resource")
+		}
+
+		info := data[offset+i:]
+		offset += i + len(fixedFileInfoSignature)
+
+		// Signature, structure version (1.0), then the file version as two
+		// 32-bit halves
+		if len(info) < 16 || binary.LittleEndian.Uint32(info[4:]) != 0x00010000 {
+			continue
+		}
+
+		ms := binary.LittleEndian.Uint32(info[8:])
+		ls := binary.LittleEndian.Uint32(info[12:])
+
+		return fmt.Sprintf("%d.%d.%d.%d", ms>>16, ms&0xffff, ls>>16, ls&0xffff), nil
+	}
+}
@@ -0,0 +1,40 @@
+package toolchain
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExecutable returns the bytes of an executable whose version resource
+// holds the given file version
+func fakeExecutable(major, minor, build, revision uint16) []byte {
+	data := []byte("MZ padding VS_VERSION_INFO ")
+
+	// A stray signature that is not followed by structure version 1.0
+	data = append(data, 0xbd, 0x04, 0xef, 0xfe, 0, 0, 0, 0)
+
+	info := make([]byte, 16)
+	copy(info, fixedFileInfoSignature)
+	binary.LittleEndian.PutUint32(info[4:], 0x00010000)
+	binary.LittleEndian.PutUint32(info[8:], uint32(major)<<16|uint32(minor))
+	binary.LittleEndian.PutUint32(info[12:], uint32(build)<<16|uint32(revision))
+
+	return append(data, info...)
+}
+
+func TestFileVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "SPlusCC.exe")
+	require.NoError(t, os.WriteFile(path, fakeExecutable(4, 3100, 12, 1), 0o644))
+
+	version, err := FileVersion(path)
+	require.NoError(t, err)
+	assert.Equal(t, "4.3100.12.1", version)
+
+	_, err = fileVersion([]byte("MZ no resources"))
+	assert.Error(t, err)
+}
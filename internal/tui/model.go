@@ -0,0 +1,276 @@
+// Package tui implements the interactive terminal build mode (spc tui).
+//
+// The screen is split into a file list showing each file's build status
+// (with cache hit indicators) and an output pane showing the compiler output
+// of the selected file. Keybindings allow navigating, rebuilding files and
+// viewing a file's full log.
+//
+// The Model holds all state and renders to a string, independent of the
+// terminal, so it can be tested without one.
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/build"
+)
+
+// Key is a decoded keypress
+type Key int
+
+const (
+	KeyNone Key = iota
+	KeyUp
+	KeyDown
+	KeyRebuild
+	KeyRebuildAll
+	KeyLog
+	KeyBack
+	KeyQuit
+)
+
+// Action is what the runner should do in response to a keypress
+type Action int
+
+const (
+	ActionNone Action = iota
+	ActionRebuild
+	ActionRebuildAll
+	ActionQuit
+)
+
+// item is a single file in the build
+type item struct {
+	result build.Result
+	log    bytes.Buffer
+}
+
+// Model holds the state of the interactive build UI
+type Model struct {
+	mu       sync.Mutex
+	items    []*item
+	selected int
+	showLog  bool
+	scroll   int // lines scrolled up from the end of the log view
+}
+
+// NewModel creates a model for the given files, all pending
+func NewModel(files []string) *Model {
+	m := &Model{}
+	for _, file := range files {
+		m.items = append(m.items, &item{result: build.Result{File: file}})
+	}
+
+	return m
+}
+
+// Update records a status change for the file at index i
+func (m *Model) Update(i int, result build.Result) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.items[i].result = result
+}
+
+// ResetLog clears the captured output for the file at index i
+func (m *Model) ResetLog(i int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.items[i].log.Reset()
+}
+
+// AppendLog appends output captured for the file at index i
+func (m *Model) AppendLog(i int, p []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.items[i].log.Write(p)
+}
+
+// Selected returns the index of the selected file
+func (m *Model) Selected() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.selected
+}
+
+// Results returns a snapshot of every file's current result
+func (m *Model) Results() []build.Result {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := make([]build.Result, len(m.items))
+	for i, it := range m.items {
+		results[i] = it.result
+	}
+
+	return results
+}
+
+// HandleKey applies a keypress and returns the action the runner should take
+func (m *Model) HandleKey(key Key) Action {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch key {
+	case KeyUp:
+		if m.showLog {
+			m.scroll++
+		} else if m.selected > 0 {
+			m.selected--
+		}
+	case KeyDown:
+		if m.showLog {
+			if m.scroll > 0 {
+				m.scroll--
+			}
+		} else if m.selected < len(m.items)-1 {
+			m.selected++
+		}
+	case KeyLog:
+		m.showLog = !m.showLog
+		m.scroll = 0
+	case KeyBack:
+		m.showLog = false
+		m.scroll = 0
+	case KeyRebuild:
+		return ActionRebuild
+	case KeyRebuildAll:
+		return ActionRebuildAll
+	case KeyQuit:
+		return ActionQuit
+	case KeyNone:
+	}
+
+	return ActionNone
+}
+
+// Render draws the UI for a terminal of the given size
+func (m *Model) Render(width, height int) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var lines []string
+	if m.showLog {
+		lines = m.renderLog(height)
+	} else {
+		lines = m.renderMain(height)
+	}
+
+	for i, line := range lines {
+		lines[i] = truncate(line, width)
+	}
+
+	return strings.Join(lines, "\r\n")
+}
+
+func (m *Model) renderMain(height int) []string {
+	lines := []string{m.summary(), ""}
+
+	// File list takes at most half the screen
+	listHeight := min(len(m.items), max(height/2-2, 1))
+	first := 0
+	if m.selected >= listHeight {
+		first = m.selected - listHeight + 1
+	}
+
+	for i := first; i < first+listHeight && i < len(m.items); i++ {
+		r := m.items[i].result
+
+		cursor := "  "
+		if i == m.selected {
+			cursor = "> "
+		}
+
+		duration := ""
+		if r.Status.Done() {
+			duration = r.Duration.Round(time.Millisecond).String()
+		}
+
+		lines = append(lines, fmt.Sprintf("%s%s %-40s %-10s %s", cursor, statusIcon(r.Status), filepath.Base(r.File), r.Status, duration))
+	}
+
+	lines = append(lines, "", "── Output: "+filepath.Base(m.items[m.selected].result.File)+" ──")
+
+	// Output pane fills the rest, minus the footer
+	paneHeight := max(height-len(lines)-2, 0)
+	lines = append(lines, tail(m.items[m.selected].log.String(), paneHeight, 0)...)
+
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+
+	return append(lines, "↑/k ↓/j select  r rebuild  a rebuild all  l log  q quit")
+}
+
+func (m *Model) renderLog(height int) []string {
+	it := m.items[m.selected]
+	lines := []string{"── Log: " + it.result.File + " ──"}
+
+	lines = append(lines, tail(it.log.String(), max(height-2, 0), m.scroll)...)
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+
+	return append(lines, "↑/k ↓/j scroll  l/esc back  q quit")
+}
+
+// summary returns the status counts header line
+func (m *Model) summary() string {
+	counts := map[build.Status]int{}
+	for _, it := range m.items {
+		counts[it.result.Status]++
+	}
+
+	return fmt.Sprintf("spc — %d file(s)  ✓ %d compiled  ● %d cached  ✗ %d failed  … %d pending",
+		len(m.items), counts[build.StatusSucceeded], counts[build.StatusCached],
+		counts[build.StatusFailed], counts[build.StatusPending]+counts[build.StatusCompiling])
+}
+
+func statusIcon(s build.Status) string {
+	switch s {
+	case build.StatusPending:
+		return "·"
+	case build.StatusCompiling:
+		return "⟳"
+	case build.StatusCached:
+		return "●"
+	case build.StatusSucceeded:
+		return "✓"
+	case build.StatusFailed:
+		return "✗"
+	default:
+		return "?"
+	}
+}
+
+// tail returns the last n lines of text, skipping the final `skip` lines
+func tail(text string, n, skip int) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+
+	end := max(len(lines)-skip, 0)
+	start := max(end-n, 0)
+
+	return lines[start:end]
+}
+
+// truncate shortens a line to fit the terminal width
+func truncate(line string, width int) string {
+	runes := []rune(line)
+	if width <= 0 || len(runes) <= width {
+		return line
+	}
+
+	return string(runes[:width])
+}
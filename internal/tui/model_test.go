@@ -0,0 +1,74 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Norgate-AV/spc/internal/build"
+)
+
+func TestModel_Render(t *testing.T) {
+	m := NewModel([]string{"a.usp", "b.usp", "c.usp"})
+	m.Update(0, build.Result{File: "a.usp", Status: build.StatusCached, Duration: time.Millisecond})
+	m.Update(1, build.Result{File: "b.usp", Status: build.StatusFailed})
+	m.AppendLog(0, []byte("line 1\nline 2\n"))
+
+	out := m.Render(120, 20)
+	lines := strings.Split(out, "\r\n")
+
+	assert.Len(t, lines, 20)
+	assert.Contains(t, lines[0], "● 1 cached")
+	assert.Contains(t, lines[0], "✗ 1 failed")
+	assert.Contains(t, lines[0], "… 1 pending")
+	assert.Contains(t, out, "> ● a.usp")
+	assert.Contains(t, out, "  ✗ b.usp")
+	assert.Contains(t, out, "line 2")
+	assert.Contains(t, lines[len(lines)-1], "q quit")
+}
+
+func TestModel_HandleKey(t *testing.T) {
+	m := NewModel([]string{"a.usp", "b.usp"})
+
+	assert.Equal(t, ActionNone, m.HandleKey(KeyUp))
+	assert.Equal(t, 0, m.Selected())
+
+	m.HandleKey(KeyDown)
+	m.HandleKey(KeyDown)
+	assert.Equal(t, 1, m.Selected())
+
+	assert.Equal(t, ActionRebuild, m.HandleKey(KeyRebuild))
+	assert.Equal(t, ActionRebuildAll, m.HandleKey(KeyRebuildAll))
+	assert.Equal(t, ActionQuit, m.HandleKey(KeyQuit))
+
+	// Log view shows the selected file's full log
+	m.AppendLog(1, []byte("compiler says hi\n"))
+	m.HandleKey(KeyLog)
+	out := m.Render(80, 10)
+	assert.Contains(t, out, "Log: b.usp")
+	assert.Contains(t, out, "compiler says hi")
+
+	// Navigation scrolls the log instead of changing selection
+	m.HandleKey(KeyUp)
+	assert.Equal(t, 1, m.Selected())
+	assert.NotContains(t, m.Render(80, 10), "compiler says hi")
+
+	m.HandleKey(KeyBack)
+	assert.Contains(t, m.Render(80, 10), "Output: b.usp")
+}
+
+func TestDecodeKey(t *testing.T) {
+	assert.Equal(t, KeyUp, decodeKey([]byte("\x1b[A")))
+	assert.Equal(t, KeyDown, decodeKey([]byte("j")))
+	assert.Equal(t, KeyQuit, decodeKey([]byte{3}))
+	assert.Equal(t, KeyNone, decodeKey([]byte("x")))
+}
+
+func TestTail(t *testing.T) {
+	assert.Nil(t, tail("", 5, 0))
+	assert.Equal(t, []string{"b", "c"}, tail("a\nb\nc\n", 2, 0))
+	assert.Equal(t, []string{"a", "b"}, tail("a\nb\nc", 2, 1))
+	assert.Equal(t, "abc", truncate("abcdef", 3))
+}
@@ -0,0 +1,220 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/term"
+
+	"github.com/Norgate-AV/spc/internal/build"
+	"github.com/Norgate-AV/spc/internal/logger"
+)
+
+const (
+	enterAltScreen = "\x1b[?1049h\x1b[?25l"
+	exitAltScreen  = "\x1b[?25h\x1b[?1049l"
+	clearScreen    = "\x1b[H\x1b[2J"
+)
+
+// logWriter captures output for a single file and triggers a redraw
+type logWriter struct {
+	model *Model
+	index int
+	dirty chan<- struct{}
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.model.AppendLog(w.index, p)
+	signal(w.dirty)
+
+	return len(p), nil
+}
+
+// signal requests a redraw without blocking
+func signal(dirty chan<- struct{}) {
+	select {
+	case dirty <- struct{}{}:
+	default:
+	}
+}
+
+// Run starts the interactive build UI on the terminal and builds all files.
+// It returns the final results when the user quits or ctx is cancelled (by
+// SIGTERM, say), stopping any build in progress
+func Run(ctx context.Context, session *build.Session, files []string) ([]build.Result, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil, fmt.Errorf("interactive mode requires a terminal")
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize terminal: %w", err)
+	}
+
+	defer func() { _ = term.Restore(fd, state) }()
+
+	fmt.Print(enterAltScreen)
+	defer fmt.Print(exitAltScreen)
+
+	// Keep log output from drawing over the UI
+	logger.Default().SetOutput(io.Discard, io.Discard)
+	defer logger.Default().SetOutput(os.Stdout, os.Stderr)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	model := NewModel(files)
+	dirty := make(chan struct{}, 1)
+	queue := make(chan int, len(files)*2)
+	keys := make(chan Key)
+
+	// Stops the key reader once the UI is gone
+	done := make(chan struct{})
+	defer close(done)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		worker(ctx, session, model, files, queue, dirty)
+	}()
+
+	// Stop the build and wait for the worker, so it is done with the logger
+	// and cache before they are restored and closed
+	stop := func() []build.Result {
+		cancel()
+		close(queue)
+		wg.Wait()
+
+		return model.Results()
+	}
+
+	go readKeys(os.Stdin, keys, done)
+
+	for i := range files {
+		queue <- i
+	}
+
+	draw(model)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return stop(), ctx.Err()
+		case <-dirty:
+			draw(model)
+		case key := <-keys:
+			switch model.HandleKey(key) {
+			case ActionQuit:
+				return stop(), nil
+			case ActionRebuild:
+				enqueue(queue, model.Selected())
+			case ActionRebuildAll:
+				for i := range files {
+					enqueue(queue, i)
+				}
+			case ActionNone:
+			}
+
+			draw(model)
+		}
+	}
+}
+
+// enqueue schedules a rebuild without blocking the UI if the queue is full
+func enqueue(queue chan<- int, i int) {
+	select {
+	case queue <- i:
+	default:
+	}
+}
+
+// worker builds queued files one at a time, capturing their output
+func worker(ctx context.Context, session *build.Session, model *Model, files []string, queue <-chan int, dirty chan<- struct{}) {
+	for i := range queue {
+		if ctx.Err() != nil {
+			continue
+		}
+
+		model.ResetLog(i)
+
+		w := &logWriter{model: model, index: i, dirty: dirty}
+		session.Stdout = w
+		session.Stderr = w
+		logger.Default().SetOutput(w, w)
+
+		index := i
+		session.OnStatus = func(r build.Result) {
+			model.Update(index, r)
+			signal(dirty)
+		}
+
+		result := session.BuildFile(ctx, files[i])
+		if result.Err != nil {
+			fmt.Fprintf(w, "\nError: %v\n", result.Err)
+		}
+
+		logger.Default().SetOutput(io.Discard, io.Discard)
+	}
+}
+
+// readKeys decodes keypresses from the raw terminal until done is closed. A
+// read in progress can't be interrupted, so it returns after the next one
+func readKeys(r io.Reader, keys chan<- Key, done <-chan struct{}) {
+	send := func(key Key) bool {
+		select {
+		case keys <- key:
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	buf := make([]byte, 16)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			send(KeyQuit)
+			return
+		}
+
+		if key := decodeKey(buf[:n]); key != KeyNone && !send(key) {
+			return
+		}
+	}
+}
+
+// decodeKey maps raw terminal input to a Key
+func decodeKey(b []byte) Key {
+	switch string(b) {
+	case "\x1b[A", "k":
+		return KeyUp
+	case "\x1b[B", "j":
+		return KeyDown
+	case "r":
+		return KeyRebuild
+	case "a":
+		return KeyRebuildAll
+	case "l", "\r":
+		return KeyLog
+	case "\x1b":
+		return KeyBack
+	case "q", "\x03":
+		return KeyQuit
+	default:
+		return KeyNone
+	}
+}
+
+func draw(model *Model) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+
+	fmt.Print(clearScreen + model.Render(width, height))
+}
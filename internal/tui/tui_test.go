@@ -0,0 +1,39 @@
+package tui
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadKeys(t *testing.T) {
+	keys := make(chan Key)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		readKeys(io.MultiReader(strings.NewReader("j"), strings.NewReader("r")), keys, done)
+	}()
+
+	assert.Equal(t, KeyDown, <-keys)
+
+	// Nobody takes the next key once the UI is gone
+	close(done)
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("readKeys did not return after done was closed")
+	}
+}
+
+func TestReadKeys_QuitsAtEOF(t *testing.T) {
+	keys := make(chan Key, 1)
+
+	readKeys(strings.NewReader(""), keys, make(chan struct{}))
+	assert.Equal(t, KeyQuit, <-keys)
+}
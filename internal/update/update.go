@@ -0,0 +1,342 @@
+// Package update implements self-updating of the spc binary from GitHub releases.
+//
+// Releases are produced by GoReleaser, which publishes one archive per platform
+// named "spc_{version}_{os}_{arch}.zip" (or .tar.gz) alongside a checksums.txt
+// file containing the SHA256 of every archive. An update downloads the archive
+// for the running platform, verifies it against checksums.txt, extracts the
+// binary and swaps it in place of the running executable.
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultAPIURL is the GitHub API endpoint used to look up releases
+	DefaultAPIURL = "https://api.github.com"
+
+	// Owner is the GitHub owner of the spc repository
+	Owner = "Norgate-AV"
+
+	// Repo is the GitHub repository name
+	Repo = "spc"
+
+	// checksumsFile is the name of the GoReleaser checksum asset
+	checksumsFile = "checksums.txt"
+)
+
+// Asset is a downloadable file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Release describes a published GitHub release
+type Release struct {
+	TagName string  `json:"tag_name"`
+	Assets  []Asset `json:"assets"`
+}
+
+// Version returns the release version without the leading "v"
+func (r *Release) Version() string {
+	return strings.TrimPrefix(r.TagName, "v")
+}
+
+// Asset returns the asset with the given name, or nil if not present
+func (r *Release) Asset(name string) *Asset {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i]
+		}
+	}
+
+	return nil
+}
+
+// Updater checks for and installs new releases
+type Updater struct {
+	client *http.Client
+	apiURL string
+	goos   string
+	goarch string
+}
+
+// NewUpdater creates an updater for the given platform
+func NewUpdater(goos, goarch string) *Updater {
+	return &Updater{
+		client: &http.Client{Timeout: 60 * time.Second},
+		apiURL: DefaultAPIURL,
+		goos:   goos,
+		goarch: goarch,
+	}
+}
+
+// SetTransport sends the updater's requests through transport
+func (u *Updater) SetTransport(transport http.RoundTripper) {
+	u.client.Transport = transport
+}
+
+// Latest fetches the latest published release
+func (u *Updater) Latest(ctx context.Context) (*Release, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/releases/latest", u.apiURL, Owner, Repo)
+
+	body, err := u.get(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch latest release: %w", err)
+	}
+
+	var release Release
+	if err := json.Unmarshal(body, &release); err != nil {
+		return nil, fmt.Errorf("failed to parse release information: %w", err)
+	}
+
+	if release.TagName == "" {
+		return nil, fmt.Errorf("latest release has no tag")
+	}
+
+	return &release, nil
+}
+
+// Download fetches the archive for this platform, verifies its checksum and
+// returns the extracted binary
+func (u *Updater) Download(ctx context.Context, release *Release) ([]byte, error) {
+	name := AssetName(release.Version(), u.goos, u.goarch)
+
+	asset := release.Asset(name)
+	if asset == nil {
+		return nil, fmt.Errorf("release %s has no asset for %s/%s", release.TagName, u.goos, u.goarch)
+	}
+
+	checksums := release.Asset(checksumsFile)
+	if checksums == nil {
+		return nil, fmt.Errorf("release %s has no %s, refusing to install an unverified binary", release.TagName, checksumsFile)
+	}
+
+	sums, err := u.get(ctx, checksums.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", checksumsFile, err)
+	}
+
+	want, err := findChecksum(sums, name)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := u.get(ctx, asset.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+
+	sum := sha256.Sum256(archive)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, want, got)
+	}
+
+	return extractBinary(archive, name, BinaryName(u.goos))
+}
+
+// get performs a GET request and returns the response body
+func (u *Updater) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response from %s: %s", url, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// AssetName returns the GoReleaser archive name for a version and platform
+func AssetName(version, goos, goarch string) string {
+	ext := "tar.gz"
+	if goos == "windows" {
+		ext = "zip"
+	}
+
+	return fmt.Sprintf("%s_%s_%s_%s.%s", Repo, version, goos, goarch, ext)
+}
+
+// BinaryName returns the name of the spc executable inside a release archive
+func BinaryName(goos string) string {
+	if goos == "windows" {
+		return "spc.exe"
+	}
+
+	return "spc"
+}
+
+// IsNewer reports whether latest is a newer semantic version than current.
+// Returns an error if either version cannot be parsed (e.g., development builds)
+func IsNewer(current, latest string) (bool, error) {
+	cur, err := parseVersion(current)
+	if err != nil {
+		return false, err
+	}
+
+	lat, err := parseVersion(latest)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range cur {
+		if lat[i] != cur[i] {
+			return lat[i] > cur[i], nil
+		}
+	}
+
+	return false, nil
+}
+
+// parseVersion parses "v1.2.3" or "1.2.3-rc1" into its numeric components
+func parseVersion(v string) ([3]int, error) {
+	var parts [3]int
+
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+
+	fields := strings.Split(v, ".")
+	if len(fields) != len(parts) {
+		return parts, fmt.Errorf("invalid version: %q", v)
+	}
+
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return parts, fmt.Errorf("invalid version: %q", v)
+		}
+
+		parts[i] = n
+	}
+
+	return parts, nil
+}
+
+// findChecksum looks up the SHA256 for a file in a checksums.txt body
+func findChecksum(sums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+
+	return "", fmt.Errorf("no checksum found for %s", name)
+}
+
+// extractBinary pulls the named binary out of a zip or tar.gz archive
+func extractBinary(archive []byte, archiveName, binary string) ([]byte, error) {
+	if strings.HasSuffix(archiveName, ".zip") {
+		r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open archive: %w", err)
+		}
+
+		for _, f := range r.File {
+			if filepath.Base(f.Name) != binary {
+				continue
+			}
+
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+
+			defer rc.Close()
+
+			return io.ReadAll(rc)
+		}
+
+		return nil, fmt.Errorf("%s not found in %s", binary, archiveName)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		if header.Typeflag == tar.TypeReg && filepath.Base(header.Name) == binary {
+			return io.ReadAll(tr)
+		}
+	}
+
+	return nil, fmt.Errorf("%s not found in %s", binary, archiveName)
+}
+
+// Replace swaps the executable at exePath for the new binary.
+// The running executable is renamed aside first, which works on Windows where
+// a running executable cannot be overwritten but can be renamed
+func Replace(exePath string, binary []byte) error {
+	info, err := os.Stat(exePath)
+	if err != nil {
+		return err
+	}
+
+	newPath := exePath + ".new"
+	oldPath := exePath + ".old"
+
+	if err := os.WriteFile(newPath, binary, info.Mode()); err != nil {
+		return fmt.Errorf("failed to write new executable: %w", err)
+	}
+
+	// Remove any leftover from a previous upgrade
+	_ = os.Remove(oldPath)
+
+	if err := os.Rename(exePath, oldPath); err != nil {
+		_ = os.Remove(newPath)
+		return fmt.Errorf("failed to move current executable aside: %w", err)
+	}
+
+	if err := os.Rename(newPath, exePath); err != nil {
+		// Put the original back so the install isn't left broken
+		_ = os.Rename(oldPath, exePath)
+		_ = os.Remove(newPath)
+		return fmt.Errorf("failed to install new executable: %w", err)
+	}
+
+	// Best effort: this fails on Windows while the old binary is still running
+	_ = os.Remove(oldPath)
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeZip(t *testing.T, name string, content []byte) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create(name)
+	require.NoError(t, err)
+	_, err = f.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func makeTarGz(t *testing.T, name string, content []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+	_, err := tw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// newReleaseServer serves a fake GitHub release with the given archive
+func newReleaseServer(t *testing.T, archiveName string, archive []byte, checksum string) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/repos/Norgate-AV/spc/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		release := Release{
+			TagName: "v1.2.0",
+			Assets: []Asset{
+				{Name: archiveName, URL: server.URL + "/download/" + archiveName},
+				{Name: "checksums.txt", URL: server.URL + "/download/checksums.txt"},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(release)
+	})
+
+	mux.HandleFunc("/download/"+archiveName, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	})
+
+	mux.HandleFunc("/download/checksums.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  %s\n", checksum, archiveName)
+	})
+
+	return server
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestUpdater_LatestAndDownload(t *testing.T) {
+	binary := []byte("new spc binary")
+
+	t.Run("windows zip", func(t *testing.T) {
+		name := AssetName("1.2.0", "windows", "amd64")
+		archive := makeZip(t, "spc.exe", binary)
+		server := newReleaseServer(t, name, archive, sha256Hex(archive))
+
+		u := NewUpdater("windows", "amd64")
+		u.apiURL = server.URL
+
+		release, err := u.Latest(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "1.2.0", release.Version())
+
+		got, err := u.Download(context.Background(), release)
+		require.NoError(t, err)
+		assert.Equal(t, binary, got)
+	})
+
+	t.Run("linux tar.gz", func(t *testing.T) {
+		name := AssetName("1.2.0", "linux", "amd64")
+		archive := makeTarGz(t, "spc", binary)
+		server := newReleaseServer(t, name, archive, sha256Hex(archive))
+
+		u := NewUpdater("linux", "amd64")
+		u.apiURL = server.URL
+
+		release, err := u.Latest(context.Background())
+		require.NoError(t, err)
+
+		got, err := u.Download(context.Background(), release)
+		require.NoError(t, err)
+		assert.Equal(t, binary, got)
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		name := AssetName("1.2.0", "windows", "amd64")
+		archive := makeZip(t, "spc.exe", binary)
+		server := newReleaseServer(t, name, archive, sha256Hex([]byte("something else")))
+
+		u := NewUpdater("windows", "amd64")
+		u.apiURL = server.URL
+
+		release, err := u.Latest(context.Background())
+		require.NoError(t, err)
+
+		_, err = u.Download(context.Background(), release)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "checksum mismatch")
+	})
+
+	t.Run("no asset for platform", func(t *testing.T) {
+		name := AssetName("1.2.0", "windows", "amd64")
+		archive := makeZip(t, "spc.exe", binary)
+		server := newReleaseServer(t, name, archive, sha256Hex(archive))
+
+		u := NewUpdater("darwin", "arm64")
+		u.apiURL = server.URL
+
+		release, err := u.Latest(context.Background())
+		require.NoError(t, err)
+
+		_, err = u.Download(context.Background(), release)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no asset for darwin/arm64")
+	})
+}
+
+func TestIsNewer(t *testing.T) {
+	tests := []struct {
+		current string
+		latest  string
+		want    bool
+		wantErr bool
+	}{
+		{"1.0.0", "1.0.1", true, false},
+		{"v1.0.0", "1.1.0", true, false},
+		{"1.2.0", "1.2.0", false, false},
+		{"2.0.0", "1.9.9", false, false},
+		{"1.0.0-rc1", "1.0.0", false, false},
+		{"", "1.0.0", false, true},
+		{"dev", "1.0.0", false, true},
+		{"1.0.0", "latest", false, true},
+	}
+
+	for _, tt := range tests {
+		got, err := IsNewer(tt.current, tt.latest)
+		if tt.wantErr {
+			assert.Error(t, err, "IsNewer(%q, %q)", tt.current, tt.latest)
+			continue
+		}
+
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "IsNewer(%q, %q)", tt.current, tt.latest)
+	}
+}
+
+func TestAssetName(t *testing.T) {
+	assert.Equal(t, "spc_1.2.0_windows_amd64.zip", AssetName("1.2.0", "windows", "amd64"))
+	assert.Equal(t, "spc_1.2.0_linux_arm64.tar.gz", AssetName("1.2.0", "linux", "arm64"))
+}
+
+func TestReplace(t *testing.T) {
+	dir := t.TempDir()
+	exePath := filepath.Join(dir, "spc.exe")
+	require.NoError(t, os.WriteFile(exePath, []byte("old"), 0o755))
+
+	require.NoError(t, Replace(exePath, []byte("new")))
+
+	content, err := os.ReadFile(exePath)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+
+	_, err = os.Stat(exePath + ".new")
+	assert.True(t, os.IsNotExist(err))
+}
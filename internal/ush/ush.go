@@ -0,0 +1,270 @@
+// Package ush reads the public interface of a compiled SIMPL+ module from the
+// .ush header the compiler generates for SIMPL Windows.
+//
+// A header is a series of [BEGIN] ... [END] blocks of Key=Value lines. The
+// ObjTp=Symbol block describes the module: its name, the source it was built
+// from and one numbered InputCue/InputSigType, OutputCue/OutputSigType and
+// ParamCue/ParamSigType entry per signal and parameter. Functions are not
+// part of the header, so they are read from the source file when it is next
+// to the header.
+package ush
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Norgate-AV/spc/internal/deps"
+)
+
+// Signal is an input, output or parameter of a module
+type Signal struct {
+	// Index is the signal's position on the symbol, starting at 1
+	Index int `json:"index"`
+
+	// Name as shown on the symbol
+	Name string `json:"name"`
+
+	// Type is Digital, Analog or Serial for signals, and the kind of value
+	// (e.g. Constant or String) for parameters
+	Type string `json:"type"`
+}
+
+// Function is a function declared in the module's source
+type Function struct {
+	// Name of the function
+	Name string `json:"name"`
+
+	// Returns is the return type (e.g. INTEGER), empty for FUNCTION
+	Returns string `json:"returns,omitempty"`
+
+	// Params is the parameter list as declared
+	Params string `json:"params,omitempty"`
+
+	// Line of the declaration in the source
+	Line int `json:"line"`
+}
+
+// Module is the interface of a module described by a header
+type Module struct {
+	// Name of the symbol
+	Name string `json:"name"`
+
+	// Header is the path of the .ush file
+	Header string `json:"header"`
+
+	// SourceFile is the source the header was built from
+	SourceFile string `json:"source_file,omitempty"`
+
+	// CompilerVersion is the version of the SIMPL+ compiler that built it
+	CompilerVersion string `json:"compiler_version,omitempty"`
+
+	Inputs     []Signal `json:"inputs"`
+	Outputs    []Signal `json:"outputs"`
+	Parameters []Signal `json:"parameters"`
+
+	// Functions declared in the source file, nil when it was not found
+	Functions []Function `json:"functions"`
+}
+
+// hiddenNames are cues the compiler adds that are not part of the interface
+var hiddenNames = map[string]bool{
+	"[Reference Name]": true,
+}
+
+// cueKey matches the numbered keys of the symbol block
+var cueKey = regexp.MustCompile(`^(Input|Output|Param)(Cue|SigType)(\d+)$`)
+
+// Read parses a header file and the functions of its source, if present
+func Read(path string) (*Module, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	m, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	m.Header = path
+
+	source := m.SourceFile
+	if source == "" {
+		source = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + ".usp"
+	}
+
+	if functions, err := ScanFunctionsFile(filepath.Join(filepath.Dir(path), source)); err == nil {
+		m.Functions = functions
+	}
+
+	return m, nil
+}
+
+// Parse parses the symbol block of a header
+func Parse(r io.Reader) (*Module, error) {
+	values, err := symbolBlock(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if values == nil {
+		return nil, fmt.Errorf("no ObjTp=Symbol block: not a SIMPL+ header")
+	}
+
+	m := &Module{
+		Name:            values["Name"],
+		SourceFile:      values["SourceCode"],
+		CompilerVersion: values["SplusVersion"],
+	}
+
+	signals := map[string]map[int]*Signal{"Input": {}, "Output": {}, "Param": {}}
+
+	for key, value := range values {
+		match := cueKey.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+
+		index, _ := strconv.Atoi(match[3])
+
+		s := signals[match[1]][index]
+		if s == nil {
+			s = &Signal{Index: index}
+			signals[match[1]][index] = s
+		}
+
+		if match[2] == "Cue" {
+			s.Name = value
+		} else {
+			s.Type = value
+		}
+	}
+
+	m.Inputs = sortedSignals(signals["Input"])
+	m.Outputs = sortedSignals(signals["Output"])
+	m.Parameters = sortedSignals(signals["Param"])
+
+	return m, nil
+}
+
+// symbolBlock returns the Key=Value pairs of the ObjTp=Symbol block, or nil
+func symbolBlock(r io.Reader) (map[string]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var block map[string]string
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch line {
+		case "[BEGIN]":
+			block = make(map[string]string)
+			continue
+		case "[END]":
+			if block["ObjTp"] == "Symbol" {
+				return block, nil
+			}
+
+			block = nil
+			continue
+		}
+
+		if block == nil {
+			continue
+		}
+
+		if key, value, ok := strings.Cut(line, "="); ok {
+			block[key] = strings.TrimSpace(value)
+		}
+	}
+
+	return nil, scanner.Err()
+}
+
+// sortedSignals returns the named signals in symbol order
+func sortedSignals(byIndex map[int]*Signal) []Signal {
+	signals := make([]Signal, 0, len(byIndex))
+	for _, s := range byIndex {
+		if s.Name == "" || hiddenNames[s.Name] {
+			continue
+		}
+
+		signals = append(signals, *s)
+	}
+
+	sort.Slice(signals, func(i, j int) bool { return signals[i].Index < signals[j].Index })
+
+	return signals
+}
+
+// functionDecl matches a function declaration, with an optional qualifier
+// such as PUBLIC or CALLBACK before the return type
+var functionDecl = regexp.MustCompile(`(?i)^(?:\w+\s+)?((?:signed_)?(?:long_)?integer_function|string_function|function)\s+(\w+)\s*\(([^)]*)\)`)
+
+// ScanFunctionsFile returns the functions declared in a source file
+func ScanFunctionsFile(path string) ([]Function, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return ScanFunctions(f)
+}
+
+// ScanFunctions returns the functions declared in SIMPL+ source, ignoring
+// commented-out code
+func ScanFunctions(r io.Reader) ([]Function, error) {
+	functions := []Function{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	inComment := false
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+
+		var line string
+		line, inComment = deps.StripComments(scanner.Text(), inComment)
+
+		if fn, ok := ParseFunction(line); ok {
+			fn.Line = lineNum
+			functions = append(functions, fn)
+		}
+	}
+
+	return functions, scanner.Err()
+}
+
+// ParseFunction parses a function declaration from a line of code without
+// comments. The line number is not set
+func ParseFunction(line string) (Function, bool) {
+	match := functionDecl.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return Function{}, false
+	}
+
+	returns := strings.TrimSuffix(strings.ToUpper(match[1]), "_FUNCTION")
+	if returns == "FUNCTION" {
+		returns = ""
+	}
+
+	return Function{
+		Name:    match[2],
+		Returns: returns,
+		Params:  strings.Join(strings.Fields(match[3]), " "),
+	}, true
+}
@@ -0,0 +1,108 @@
+package ush
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const header = `[BEGIN]
+  Version=1
+[END]
+[BEGIN]
+  ObjTp=Symbol
+  Name=Projector
+  SmplCInputCue1=o#
+  Hint=
+  Code=1
+  SourceCode=Projector.usp
+  SplusVersion=4.02.20
+  NumFixedInputs=2
+  InputCue2=From_Device$
+  InputSigType2=Serial
+  InputCue1=Poll
+  InputSigType1=Digital
+  OutputCue1=To_Device$
+  OutputSigType1=Serial
+  ParamCue1=[Reference Name]
+  ParamCue2=Poll_Interval
+  ParamSigType2=Constant
+[END]
+[BEGIN]
+  ObjTp=Dp
+  H=1
+  Name=Ignored
+[END]
+`
+
+func TestParse(t *testing.T) {
+	m, err := Parse(strings.NewReader(header))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Projector", m.Name)
+	assert.Equal(t, "Projector.usp", m.SourceFile)
+	assert.Equal(t, "4.02.20", m.CompilerVersion)
+	assert.Equal(t, []Signal{{1, "Poll", "Digital"}, {2, "From_Device$", "Serial"}}, m.Inputs)
+	assert.Equal(t, []Signal{{1, "To_Device$", "Serial"}}, m.Outputs)
+	assert.Equal(t, []Signal{{2, "Poll_Interval", "Constant"}}, m.Parameters, "the reference name is not a parameter")
+}
+
+func TestParse_NotAHeader(t *testing.T) {
+	_, err := Parse(strings.NewReader("[BEGIN]\n  Version=1\n[END]\n"))
+	assert.ErrorContains(t, err, "not a SIMPL+ header")
+}
+
+func TestScanFunctions(t *testing.T) {
+	source := `FUNCTION Send(STRING command,  INTEGER retries)
+{
+}
+
+// INTEGER_FUNCTION Commented()
+/*
+STRING_FUNCTION AlsoCommented()
+*/
+integer_function Count() { return (1); }
+LONG_INTEGER_FUNCTION Total(LONG_INTEGER a)
+CALLBACK STRING_FUNCTION OnReply(STRING reply)
+PUSH Poll
+{
+    Send("POLL", 1);
+}
+FUNCTION Main()
+`
+
+	functions, err := ScanFunctions(strings.NewReader(source))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Function{
+		{Name: "Send", Params: "STRING command, INTEGER retries", Line: 1},
+		{Name: "Count", Returns: "INTEGER", Line: 9},
+		{Name: "Total", Returns: "LONG_INTEGER", Params: "LONG_INTEGER a", Line: 10},
+		{Name: "OnReply", Returns: "STRING", Params: "STRING reply", Line: 11},
+		{Name: "Main", Line: 16},
+	}, functions)
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Projector.ush")
+	require.NoError(t, os.WriteFile(path, []byte(header), 0o644))
+
+	m, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, path, m.Header)
+	assert.Nil(t, m.Functions, "no source next to the header")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Projector.usp"), []byte("FUNCTION Main()\n{\n}\n"), 0o644))
+
+	m, err = Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, []Function{{Name: "Main", Line: 1}}, m.Functions)
+
+	_, err = Read(filepath.Join(dir, "Missing.ush"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// maxPath is the length at which Windows APIs that are not long-path aware
+// start to fail. Directories are limited to MAX_PATH (260) less room for an
+// 8.3 file name, so the shorter limit is used for every path
+const maxPath = 248
+
+// LongPath returns path in a form Windows accepts beyond MAX_PATH: absolute,
+// with backslashes and the \\?\ prefix. Short paths, and every path on other
+// platforms, are returned unchanged
+func LongPath(path string) string {
+	if runtime.GOOS != "windows" || len(path) < maxPath {
+		return path
+	}
+
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	return extendedPath(path)
+}
+
+// extendedPath adds the \\?\ prefix to an absolute Windows path of maxPath
+// characters or more. The prefix turns off path normalisation, so separators
+// must already be backslashes and the path clean
+func extendedPath(path string) string {
+	if len(path) < maxPath || strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+
+	path = strings.ReplaceAll(path, "/", `\`)
+
+	// UNC paths (\\server\share) become \\?\UNC\server\share
+	if unc, ok := strings.CutPrefix(path, `\\`); ok {
+		return `\\?\UNC\` + unc
+	}
+
+	return `\\?\` + path
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtendedPath(t *testing.T) {
+	long := strings.Repeat("nested folder\\", 20)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`C:\Projects\room.usp`, `C:\Projects\room.usp`},
+		{`C:\` + long + `room.usp`, `\\?\C:\` + long + `room.usp`},
+		{`C:/` + strings.ReplaceAll(long, `\`, "/") + `room.usp`, `\\?\C:\` + long + `room.usp`},
+		{`\\server\share\` + long, `\\?\UNC\server\share\` + long},
+		{`\\?\C:\` + long, `\\?\C:\` + long},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, extendedPath(test.input), "extendedPath(%q)", test.input)
+	}
+}
+
+func TestLongPath_ShortPathsUnchanged(t *testing.T) {
+	assert.Equal(t, "room.usp", LongPath("room.usp"))
+}
@@ -2,13 +2,41 @@ package utils
 
 import (
 	"strconv"
+	"strings"
 )
 
-// ParseTarget parses target string into series slice
+// TargetAliases are the built-in names for targets, accepted wherever a
+// series target is
+var TargetAliases = map[string]string{
+	"all":    "234",
+	"modern": "34",
+}
+
+// ResolveTarget returns the series a target names: the target of its alias
+// in aliases (a project's target_aliases:, which may name a built-in alias)
+// or TargetAliases, or the target itself when it is not an alias. Aliases
+// are matched without regard to case
+func ResolveTarget(t string, aliases map[string]string) string {
+	name := strings.ToLower(strings.TrimSpace(t))
+
+	if target, ok := aliases[name]; ok {
+		t = target
+		name = strings.ToLower(strings.TrimSpace(target))
+	}
+
+	if target, ok := TargetAliases[name]; ok {
+		return target
+	}
+
+	return t
+}
+
+// ParseTarget parses target string (series digits or a built-in alias)
+// into series slice
 func ParseTarget(t string) []string {
 	series := make([]string, 0)
 
-	for _, r := range t {
+	for _, r := range ResolveTarget(t, nil) {
 		if s := int(r - '0'); s >= 2 && s <= 4 {
 			series = append(series, "series"+strconv.Itoa(s))
 		}
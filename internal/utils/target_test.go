@@ -27,3 +27,32 @@ func TestParseTarget(t *testing.T) {
 		assert.Equal(t, test.expected, result, "ParseTarget(%q)", test.input)
 	}
 }
+
+func TestParseTarget_Aliases(t *testing.T) {
+	assert.Equal(t, []string{"series2", "series3", "series4"}, ParseTarget("all"))
+	assert.Equal(t, []string{"series3", "series4"}, ParseTarget("Modern"))
+	assert.Equal(t, []string{}, ParseTarget("legacy"))
+}
+
+func TestResolveTarget(t *testing.T) {
+	aliases := map[string]string{"legacy": "2", "current": "modern", "all": "34"}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"34", "34"},
+		{"auto", "auto"},
+		{"modern", "34"},
+		{"ALL", "34"},
+		{"Legacy", "2"},
+		{"current", "34"},
+		{"unknown", "unknown"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, ResolveTarget(test.input, aliases), "ResolveTarget(%q)", test.input)
+	}
+
+	assert.Equal(t, "234", ResolveTarget("all", nil))
+}
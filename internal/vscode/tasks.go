@@ -0,0 +1,112 @@
+// Package vscode generates Visual Studio Code configuration for spc projects.
+package vscode
+
+import (
+	"encoding/json"
+
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+// TasksFile is the path of the tasks file within a project
+const TasksFile = ".vscode/tasks.json"
+
+// tasksFile is the schema of .vscode/tasks.json
+type tasksFile struct {
+	Version string `json:"version"`
+	Tasks   []task `json:"tasks"`
+}
+
+type task struct {
+	Label          string         `json:"label"`
+	Type           string         `json:"type"`
+	Command        string         `json:"command"`
+	Args           []string       `json:"args"`
+	Options        *taskOptions   `json:"options,omitempty"`
+	Group          *taskGroup     `json:"group,omitempty"`
+	Presentation   map[string]any `json:"presentation,omitempty"`
+	ProblemMatcher problemMatcher `json:"problemMatcher"`
+}
+
+type taskOptions struct {
+	Cwd string `json:"cwd"`
+}
+
+type taskGroup struct {
+	Kind      string `json:"kind"`
+	IsDefault bool   `json:"isDefault"`
+}
+
+type problemMatcher struct {
+	Owner        string         `json:"owner"`
+	Source       string         `json:"source"`
+	FileLocation []string       `json:"fileLocation"`
+	Pattern      matcherPattern `json:"pattern"`
+}
+
+type matcherPattern struct {
+	Regexp   string `json:"regexp"`
+	File     int    `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity int    `json:"severity"`
+	Code     int    `json:"code"`
+	Message  int    `json:"message"`
+}
+
+// matcher recognizes the diagnostics spc prints for compile errors (see the
+// diag package). Paths are absolute, but relative ones are resolved against
+// the workspace so the matcher keeps working if that changes
+var matcher = problemMatcher{
+	Owner:        "spc",
+	Source:       "spc",
+	FileLocation: []string{"autoDetect", "${workspaceFolder}"},
+	Pattern: matcherPattern{
+		Regexp:   diag.Pattern,
+		File:     1,
+		Line:     2,
+		Column:   3,
+		Severity: 4,
+		Code:     5,
+		Message:  6,
+	},
+}
+
+// Tasks returns the contents of a tasks.json with tasks to build the whole
+// project (the default build task) and the file open in the editor, both
+// reporting compile errors to the Problems panel
+func Tasks() ([]byte, error) {
+	presentation := map[string]any{"reveal": "silent", "clear": true}
+
+	file := tasksFile{
+		Version: "2.0.0",
+		Tasks: []task{
+			{
+				Label:          "spc: build",
+				Type:           "process",
+				Command:        "spc",
+				Args:           []string{"build", "--keep-going"},
+				Options:        &taskOptions{Cwd: "${workspaceFolder}"},
+				Group:          &taskGroup{Kind: "build", IsDefault: true},
+				Presentation:   presentation,
+				ProblemMatcher: matcher,
+			},
+			{
+				Label:          "spc: build current file",
+				Type:           "process",
+				Command:        "spc",
+				Args:           []string{"build", "${file}"},
+				Options:        &taskOptions{Cwd: "${workspaceFolder}"},
+				Group:          &taskGroup{Kind: "build"},
+				Presentation:   presentation,
+				ProblemMatcher: matcher,
+			},
+		},
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
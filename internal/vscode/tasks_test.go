@@ -0,0 +1,38 @@
+package vscode
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+func TestTasks(t *testing.T) {
+	data, err := Tasks()
+	require.NoError(t, err)
+
+	var file tasksFile
+	require.NoError(t, json.Unmarshal(data, &file))
+
+	require.Len(t, file.Tasks, 2)
+	assert.Equal(t, "2.0.0", file.Version)
+	assert.True(t, file.Tasks[0].Group.IsDefault)
+	assert.Equal(t, []string{"build", "${file}"}, file.Tasks[1].Args)
+
+	// The matcher must pick the fields out of spc's diagnostics
+	p := file.Tasks[0].ProblemMatcher.Pattern
+	d := diag.Diagnostic{File: "C:/Project/Room.usp", Line: 14, Column: 2, Severity: diag.SeverityError, Code: "1300", Message: "Invalid constant"}
+
+	m := regexp.MustCompile(p.Regexp).FindStringSubmatch(d.String())
+	require.NotNil(t, m)
+	assert.Equal(t, "C:/Project/Room.usp", m[p.File])
+	assert.Equal(t, "14", m[p.Line])
+	assert.Equal(t, "2", m[p.Column])
+	assert.Equal(t, "error", m[p.Severity])
+	assert.Equal(t, "1300", m[p.Code])
+	assert.Equal(t, "Invalid constant", m[p.Message])
+}
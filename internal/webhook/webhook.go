@@ -0,0 +1,223 @@
+// Package webhook notifies chat channels and other services when a build
+// finishes, as a generic JSON document or a Slack or Microsoft Teams message
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/build"
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+// Timeout bounds how long a webhook may hold up the end of a build
+const Timeout = 10 * time.Second
+
+// maxListed is how many failures chat messages list before summarising the rest
+const maxListed = 10
+
+// Report is the outcome of a build, sent as the json payload
+type Report struct {
+	Event    string    `json:"event"`
+	Status   string    `json:"status"`
+	Project  string    `json:"project,omitempty"`
+	Profile  string    `json:"profile,omitempty"`
+	Target   string    `json:"target"`
+	Files    int       `json:"files"`
+	Compiled int       `json:"compiled"`
+	Cached   int       `json:"cached"`
+	Failed   int       `json:"failed"`
+	Duration float64   `json:"duration_seconds"`
+	Saved    float64   `json:"cache_saved_seconds"`
+	Failures []Failure `json:"failures"`
+}
+
+// Failure is a file that failed to build
+type Failure struct {
+	File        string            `json:"file"`
+	Error       string            `json:"error"`
+	Diagnostics []diag.Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// NewReport summarises the results of a build. diagnostics holds the compiler
+// diagnostics of each failed file, by absolute path
+func NewReport(cfg *config.Config, results []build.Result, diagnostics map[string][]diag.Diagnostic, elapsed time.Duration) *Report {
+	r := &Report{
+		Event:    "build.finished",
+		Status:   "success",
+		Profile:  cfg.Profile,
+		Target:   cfg.Target,
+		Files:    len(results),
+		Duration: elapsed.Seconds(),
+		Failures: []Failure{},
+	}
+
+	if cfg.ProjectDir != "" {
+		r.Project = filepath.Base(cfg.ProjectDir)
+	}
+
+	var saved time.Duration
+	for _, res := range results {
+		switch res.Status {
+		case build.StatusSucceeded:
+			r.Compiled++
+		case build.StatusCached:
+			r.Cached++
+			saved += res.Saved
+		}
+
+		if res.Err != nil {
+			r.Failed++
+			r.Failures = append(r.Failures, Failure{File: res.File, Error: res.Err.Error(), Diagnostics: diagnostics[res.Path]})
+		}
+	}
+
+	r.Saved = saved.Seconds()
+
+	if r.Failed > 0 {
+		r.Status = "failure"
+	}
+
+	return r
+}
+
+// Title is the one-line summary used in chat messages
+func (r *Report) Title() string {
+	name := "spc build"
+	if r.Project != "" {
+		name += " of " + r.Project
+	}
+
+	duration := time.Duration(r.Duration * float64(time.Second)).Round(time.Second / 10)
+	if r.Failed > 0 {
+		return fmt.Sprintf("✗ %s failed: %d of %d file(s) did not compile (%s)", name, r.Failed, r.Files, duration)
+	}
+
+	return fmt.Sprintf("✓ %s succeeded: %d file(s) (%s)", name, r.Files, duration)
+}
+
+// Text is the body of chat messages: the counts, then each failure with its
+// first diagnostic
+func (r *Report) Text() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Target %s: %d compiled, %d cached, %d failed", r.Target, r.Compiled, r.Cached, r.Failed)
+	if saved := time.Duration(r.Saved * float64(time.Second)).Round(time.Second); saved > 0 {
+		fmt.Fprintf(&b, ", cache saved %s", saved)
+	}
+
+	for i, f := range r.Failures {
+		if i == maxListed {
+			fmt.Fprintf(&b, "\n…and %d more", len(r.Failures)-maxListed)
+			break
+		}
+
+		if len(f.Diagnostics) > 0 {
+			fmt.Fprintf(&b, "\n• %s", f.Diagnostics[0])
+		} else {
+			fmt.Fprintf(&b, "\n• %s: %s", f.File, f.Error)
+		}
+	}
+
+	return b.String()
+}
+
+// Payload returns the request body for a webhook format
+func (r *Report) Payload(format string) ([]byte, error) {
+	switch format {
+	case config.WebhookSlack:
+		return json.Marshal(map[string]string{"text": "*" + r.Title() + "*\n" + r.Text()})
+	case config.WebhookTeams:
+		color := "2EB886"
+		if r.Failed > 0 {
+			color = "D70000"
+		}
+
+		return json.Marshal(map[string]string{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    r.Title(),
+			"title":      r.Title(),
+			"themeColor": color,
+			"text":       strings.ReplaceAll(r.Text(), "\n", "\n\n"),
+		})
+	default:
+		return json.Marshal(r)
+	}
+}
+
+// Send POSTs the report to every webhook that fires for it. Every webhook is
+// tried; the errors of those that failed are returned together
+func Send(ctx context.Context, client *http.Client, webhooks []config.Webhook, r *Report) error {
+	var errs []string
+
+	for _, w := range webhooks {
+		if !w.Fires(r.Failed > 0) {
+			continue
+		}
+
+		if err := post(ctx, client, w, r); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// post sends the report to a single webhook
+func post(ctx context.Context, client *http.Client, w config.Webhook, r *Report) error {
+	body, err := r.Payload(w.Format)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The client's error repeats the full URL
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+
+		return fmt.Errorf("webhook %s: %w", redact(w.URL), err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: %s", redact(w.URL), resp.Status)
+	}
+
+	return nil
+}
+
+// redact shortens a webhook URL to its host, as the path usually holds a secret
+func redact(rawURL string) string {
+	scheme, rest, _ := strings.Cut(rawURL, "://")
+	host, _, _ := strings.Cut(rest, "/")
+
+	return scheme + "://" + host + "/…"
+}
@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Norgate-AV/spc/internal/build"
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/diag"
+)
+
+func testReport() *Report {
+	cfg := &config.Config{Target: "34", ProjectDir: "/src/room"}
+	results := []build.Result{
+		{File: "a.usp", Path: "/src/room/a.usp", Status: build.StatusSucceeded},
+		{File: "b.usp", Path: "/src/room/b.usp", Status: build.StatusCached, Saved: 4 * time.Second},
+		{File: "c.usp", Path: "/src/room/c.usp", Status: build.StatusFailed, Err: errors.New("compile errors")},
+	}
+	diagnostics := map[string][]diag.Diagnostic{
+		"/src/room/c.usp": {{File: "/src/room/c.usp", Line: 3, Severity: diag.SeverityError, Code: "1300", Message: "Invalid constant"}},
+	}
+
+	return NewReport(cfg, results, diagnostics, 12*time.Second)
+}
+
+func TestNewReport(t *testing.T) {
+	r := testReport()
+
+	assert.Equal(t, "failure", r.Status)
+	assert.Equal(t, "room", r.Project)
+	assert.Equal(t, 3, r.Files)
+	assert.Equal(t, 1, r.Compiled)
+	assert.Equal(t, 1, r.Cached)
+	assert.Equal(t, 1, r.Failed)
+	assert.Equal(t, 12.0, r.Duration)
+	assert.Equal(t, 4.0, r.Saved)
+	require.Len(t, r.Failures, 1)
+	assert.Len(t, r.Failures[0].Diagnostics, 1)
+
+	assert.Equal(t, "✗ spc build of room failed: 1 of 3 file(s) did not compile (12s)", r.Title())
+	assert.Equal(t, "Target 34: 1 compiled, 1 cached, 1 failed, cache saved 4s\n• /src/room/c.usp(3): error 1300: Invalid constant", r.Text())
+}
+
+func TestPayload(t *testing.T) {
+	r := testReport()
+
+	data, err := r.Payload(config.WebhookSlack)
+	require.NoError(t, err)
+
+	var slack map[string]string
+	require.NoError(t, json.Unmarshal(data, &slack))
+	assert.Contains(t, slack["text"], "*✗ spc build of room failed")
+
+	data, err = r.Payload(config.WebhookTeams)
+	require.NoError(t, err)
+
+	var teams map[string]string
+	require.NoError(t, json.Unmarshal(data, &teams))
+	assert.Equal(t, "MessageCard", teams["@type"])
+	assert.Equal(t, "D70000", teams["themeColor"])
+
+	data, err = r.Payload(config.WebhookJSON)
+	require.NoError(t, err)
+
+	var generic Report
+	require.NoError(t, json.Unmarshal(data, &generic))
+	assert.Equal(t, r.Failures, generic.Failures)
+}
+
+func TestSend(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		received = append(received, r.URL.Path)
+
+		if r.URL.Path == "/broken/secret" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	webhooks := []config.Webhook{
+		{URL: server.URL + "/always", Format: config.WebhookJSON, On: config.WebhookAlways},
+		{URL: server.URL + "/success", Format: config.WebhookSlack, On: config.WebhookSuccess},
+		{URL: server.URL + "/failure", Format: config.WebhookTeams, On: config.WebhookFailure},
+		{URL: server.URL + "/broken/secret", Format: config.WebhookJSON, On: config.WebhookAlways},
+	}
+
+	err := Send(context.Background(), server.Client(), webhooks, testReport())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "500")
+	assert.NotContains(t, err.Error(), "secret")
+
+	assert.Equal(t, []string{"/always", "/failure", "/broken/secret"}, received)
+}
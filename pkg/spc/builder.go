@@ -0,0 +1,128 @@
+package spc
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/build"
+	"github.com/Norgate-AV/spc/internal/cache"
+)
+
+// Status is the state of a source file within a build
+type Status string
+
+const (
+	// StatusPending means the file has not been built yet
+	StatusPending Status = "pending"
+	// StatusCompiling means the compiler is running for the file
+	StatusCompiling Status = "compiling"
+	// StatusCached means the file's artifacts were restored from the cache
+	StatusCached Status = "cached"
+	// StatusSucceeded means the file compiled successfully
+	StatusSucceeded Status = "success"
+	// StatusFailed means the file failed to compile
+	StatusFailed Status = "failed"
+)
+
+// Result is the outcome of building a single source file
+type Result struct {
+	// File is the source file as given to Build
+	File string
+
+	// Path is the absolute path to the source file
+	Path string
+
+	// Status is the current (or final) state of the build
+	Status Status
+
+	// Err is the build error when Status is StatusFailed
+	Err error
+
+	// Duration is how long the build took
+	Duration time.Duration
+}
+
+// Failed returns the results that failed to build
+func Failed(results []Result) []Result {
+	var failed []Result
+	for _, r := range results {
+		if r.Status == StatusFailed {
+			failed = append(failed, r)
+		}
+	}
+
+	return failed
+}
+
+// Builder compiles SIMPL+ source files
+type Builder struct {
+	cfg   *Config
+	cache *Cache
+
+	// Stdout and Stderr receive compiler output (nil means os.Stdout/os.Stderr)
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// OnStatus, if set, is called whenever a file's status changes
+	OnStatus func(Result)
+}
+
+// NewBuilder creates a builder. c may be nil to build without a cache
+func NewBuilder(cfg *Config, c *Cache) *Builder {
+	return &Builder{cfg: cfg, cache: c}
+}
+
+// Build builds the files, libraries first. Unless the config's KeepGoing is
+// set it stops at the first failure. Cancelling ctx stops the compiler and
+// the build; the results so far are returned along with ctx.Err()
+func (b *Builder) Build(ctx context.Context, files []string) ([]Result, error) {
+	cfg, err := b.cfg.internal()
+	if err != nil {
+		return nil, err
+	}
+
+	if ordered, err := BuildOrder(b.cfg, files); err == nil {
+		files = ordered
+	}
+
+	var buildCache *cache.Cache
+	if b.cache != nil {
+		buildCache = b.cache.c
+	}
+
+	session := build.NewSession(cfg, buildCache)
+	session.Stdout = b.Stdout
+	session.Stderr = b.Stderr
+
+	if b.OnStatus != nil {
+		session.OnStatus = func(r build.Result) { b.OnStatus(fromBuildResult(r)) }
+	}
+
+	results := make([]Result, 0, len(files))
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		result := fromBuildResult(session.BuildFile(ctx, file))
+		results = append(results, result)
+
+		if result.Status == StatusFailed && !cfg.KeepGoing {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+// fromBuildResult converts an internal build result to its public form
+func fromBuildResult(r build.Result) Result {
+	return Result{
+		File:     r.File,
+		Path:     r.Path,
+		Status:   Status(r.Status.String()),
+		Err:      r.Err,
+		Duration: r.Duration,
+	}
+}
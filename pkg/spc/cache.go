@@ -0,0 +1,46 @@
+package spc
+
+import (
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/cache"
+)
+
+// Cache is an spc build cache. It is safe to share with the spc CLI, but only
+// one process can have a cache open at a time
+type Cache struct {
+	c *cache.Cache
+}
+
+// OpenCache opens (creating if needed) the cache in dir, or .spc-cache in the
+// working directory when dir is empty
+func OpenCache(dir string) (*Cache, error) {
+	c, err := cache.New(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache{c: c}, nil
+}
+
+// Close closes the cache
+func (c *Cache) Close() error {
+	return c.c.Close()
+}
+
+// Stats returns the number of entries and the total size of cached artifacts
+func (c *Cache) Stats() (entries int, size int64, err error) {
+	return c.c.Stats()
+}
+
+// Clear removes every entry and artifact from the cache
+func (c *Cache) Clear() error {
+	return c.c.Clear()
+}
+
+// Prune evicts entries older than ttl, then the oldest entries until the
+// artifacts fit in maxSize bytes (0 disables a limit). Returns the number of
+// entries removed
+func (c *Cache) Prune(maxSize int64, ttl time.Duration) (int, error) {
+	return c.c.Prune(maxSize, ttl)
+}
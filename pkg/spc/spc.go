@@ -0,0 +1,189 @@
+// Package spc is the public Go API for building Crestron SIMPL+ programs with
+// spc, for tools that want to embed builds instead of running the spc CLI.
+//
+// A typical build loads the project configuration, finds the sources and
+// builds them with a cache:
+//
+//	cfg, err := spc.LoadConfig("path/to/project", "")
+//	files, err := spc.FindSources(cfg, "path/to/project")
+//	c, err := spc.OpenCache(cfg.CacheDir)
+//	defer c.Close()
+//	results, err := spc.NewBuilder(cfg, c).Build(ctx, files)
+//
+// The types in this package are stable; everything under internal/ may change
+// between releases.
+package spc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Norgate-AV/spc/internal/config"
+	"github.com/Norgate-AV/spc/internal/deps"
+	"github.com/Norgate-AV/spc/internal/discovery"
+)
+
+// Config holds the settings for a build
+type Config struct {
+	// CompilerPath is the path to SPlusCC.exe
+	CompilerPath string
+
+	// CompilerPaths maps series ("series2", "series3", "series4") to the
+	// compiler used for them, falling back to CompilerPath
+	CompilerPaths map[string]string
+
+	// Target is the series to compile for (e.g. "3", "34", "234")
+	Target string
+
+	// UserFolders are the user SIMPL+ folders
+	UserFolders []string
+
+	// OutputFile receives the compiler log (empty for none)
+	OutputFile string
+
+	// OutDir collects each module's artifacts into <OutDir>/<module>/ (empty to disable)
+	OutDir string
+
+	// ExtraArgs are appended to the compiler command line
+	ExtraArgs []string
+
+	// Silent suppresses console output from the compiler
+	Silent bool
+
+	// KeepGoing builds every file instead of stopping at the first failure
+	KeepGoing bool
+
+	// Depfile writes a make-style dependency file (<module>.d) next to each
+	// built source
+	Depfile bool
+
+	// CacheDir is the cache directory used by OpenCache (empty for .spc-cache
+	// in the working directory)
+	CacheDir string
+
+	// CacheMaxSize and CacheTTL are the cache eviction limits (0 for no limit)
+	CacheMaxSize int64
+	CacheTTL     time.Duration
+
+	// CacheHash is the algorithm used for cache keys and artifact comparison:
+	// "sha256" (or empty), "blake3" or "xxh3"
+	CacheHash string
+
+	// NoCache is true when the project disables the cache
+	NoCache bool
+
+	// base carries the settings that are not exposed (overrides, include and
+	// exclude patterns, the project directory) when loaded from config files
+	base *config.Config
+}
+
+// DefaultConfig returns a configuration with spc's defaults
+func DefaultConfig() *Config {
+	return &Config{
+		CompilerPath: config.DefaultCompilerPath,
+		Target:       config.DefaultTarget,
+	}
+}
+
+// LoadConfig loads the configuration of the project in dir the same way the
+// spc CLI does: defaults, then the global config file, the project's .spc.yml
+// (found in dir or its parents) and finally the named profile (empty for none)
+func LoadConfig(dir, profile string) (*Config, error) {
+	cfg, err := config.NewLoader().LoadProject(dir, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return fromInternal(cfg), nil
+}
+
+// fromInternal converts a loaded configuration to its public form
+func fromInternal(cfg *config.Config) *Config {
+	return &Config{
+		CompilerPath:  cfg.CompilerPath,
+		CompilerPaths: cfg.CompilerPaths,
+		Target:        cfg.Target,
+		UserFolders:   cfg.UserFolders,
+		OutputFile:    cfg.OutputFile,
+		OutDir:        cfg.OutDir,
+		ExtraArgs:     cfg.ExtraArgs,
+		Silent:        cfg.Silent,
+		KeepGoing:     cfg.KeepGoing,
+		Depfile:       cfg.Depfile,
+		CacheDir:      cfg.Cache.Dir,
+		CacheMaxSize:  cfg.Cache.MaxSize,
+		CacheTTL:      cfg.Cache.TTL,
+		CacheHash:     cfg.Cache.Hash,
+		NoCache:       cfg.NoCache,
+		base:          cfg,
+	}
+}
+
+// internal converts the configuration to the internal form, validating it
+func (c *Config) internal() (*config.Config, error) {
+	cfg := &config.Config{}
+	if c.base != nil {
+		copied := *c.base
+		cfg = &copied
+	}
+
+	cfg.CompilerPath = c.CompilerPath
+	cfg.CompilerPaths = make(map[string]string, len(c.CompilerPaths))
+	for series, path := range c.CompilerPaths {
+		cfg.CompilerPaths[series] = path
+	}
+
+	cfg.Target = c.Target
+	cfg.UserFolders = append([]string(nil), c.UserFolders...)
+	cfg.OutputFile = c.OutputFile
+	cfg.OutDir = c.OutDir
+	cfg.ExtraArgs = append([]string(nil), c.ExtraArgs...)
+	cfg.Silent = c.Silent
+	cfg.KeepGoing = c.KeepGoing
+	cfg.Depfile = c.Depfile
+	cfg.NoCache = c.NoCache
+	cfg.Cache.Dir = c.CacheDir
+	cfg.Cache.MaxSize = c.CacheMaxSize
+	cfg.Cache.TTL = c.CacheTTL
+	cfg.Cache.Hash = c.CacheHash
+
+	if cfg.CompilerPath == "" {
+		cfg.CompilerPath = config.DefaultCompilerPath
+	}
+
+	if cfg.Target == "" {
+		cfg.Target = config.DefaultTarget
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// FindSources expands files, directories (searched recursively) and glob
+// patterns into SIMPL+ source files, applying the project's include and
+// exclude patterns. With no paths, the project's files: list is used
+func FindSources(cfg *Config, paths ...string) ([]string, error) {
+	var filter *discovery.Filter
+	if cfg.base != nil {
+		filter = &discovery.Filter{Include: cfg.base.Include, Exclude: cfg.base.Exclude, BaseDir: cfg.base.ProjectDir}
+
+		if len(paths) == 0 {
+			paths = cfg.base.ProjectFiles()
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files specified")
+	}
+
+	return discovery.ExpandFiltered(paths, filter)
+}
+
+// BuildOrder sorts source files so that libraries are built before the files
+// that use them. Builder.Build does this itself
+func BuildOrder(cfg *Config, files []string) ([]string, error) {
+	return deps.BuildOrder(files, &deps.Resolver{UserFolders: cfg.UserFolders})
+}
@@ -0,0 +1,117 @@
+package spc
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+
+	dir := t.TempDir()
+	local := `target: "3"
+usersplusfolder:
+  - lib
+cache:
+  max_size: 1MB
+  ttl: 2d
+  hash: xxh3
+files:
+  - src
+profiles:
+  ci:
+    keep_going: true
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".spc.yml"), []byte(local), 0o644))
+
+	cfg, err := LoadConfig(dir, "ci")
+	require.NoError(t, err)
+
+	assert.Equal(t, "3", cfg.Target)
+	assert.Len(t, cfg.UserFolders, 1)
+	assert.Equal(t, int64(1<<20), cfg.CacheMaxSize)
+	assert.Equal(t, 48*time.Hour, cfg.CacheTTL)
+	assert.Equal(t, "xxh3", cfg.CacheHash)
+	assert.True(t, cfg.KeepGoing)
+
+	_, err = LoadConfig(dir, "missing")
+	assert.Error(t, err)
+}
+
+func TestConfig_Internal(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.CompilerPaths = map[string]string{"series4": "/opt/series4/SPlusCC.exe"}
+	cfg.ExtraArgs = []string{"/debug"}
+
+	internal, err := cfg.internal()
+	require.NoError(t, err)
+	assert.Equal(t, "34", internal.Target)
+	assert.Equal(t, []string{"/debug"}, internal.ExtraArgs)
+
+	// Validation must not write back into the caller's config
+	internal.ExtraArgs[0] = "/changed"
+	assert.Equal(t, []string{"/debug"}, cfg.ExtraArgs)
+
+	cfg.CompilerPaths = map[string]string{"series9": "x"}
+	_, err = cfg.internal()
+	assert.ErrorContains(t, err, "invalid compiler_paths key")
+}
+
+func TestFindSources(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.usp", "b.usl", "notes.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o644))
+	}
+
+	files, err := FindSources(DefaultConfig(), dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	_, err = FindSources(DefaultConfig())
+	assert.Error(t, err)
+}
+
+func TestBuilder_Build_Cancelled(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.usp")
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := NewBuilder(DefaultConfig(), nil).Build(ctx, []string{file})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, results)
+}
+
+func TestBuilder_Build_MissingCompiler(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "a.usp"), filepath.Join(dir, "b.usp")}
+	for _, f := range files {
+		require.NoError(t, os.WriteFile(f, nil, 0o644))
+	}
+
+	cfg := DefaultConfig()
+	cfg.CompilerPath = filepath.Join(dir, "missing", "SPlusCC.exe")
+	cfg.KeepGoing = true
+
+	var statuses []Status
+	b := NewBuilder(cfg, nil)
+	b.Stdout, b.Stderr = io.Discard, io.Discard
+	b.OnStatus = func(r Result) { statuses = append(statuses, r.Status) }
+
+	results, err := b.Build(context.Background(), files)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Len(t, Failed(results), 2)
+	assert.Error(t, results[0].Err)
+	assert.Contains(t, statuses, StatusFailed)
+}